@@ -1,32 +1,227 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"os"
+	"strconv"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/nexus-lab/iot-service-blockchain/contract"
 )
 
+// loadAttributePolicies read attribute policies of the smart contract functions, keyed by contract name,
+// from the JSON-formatted ATTRIBUTE_POLICIES environment variable
+func loadAttributePolicies() (map[string]contract.AttributePolicies, error) {
+	policies := make(map[string]contract.AttributePolicies)
+
+	data, ok := os.LookupEnv("ATTRIBUTE_POLICIES")
+	if !ok || data == "" {
+		return policies, nil
+	}
+
+	if err := json.Unmarshal([]byte(data), &policies); err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// loadAdminAttributes read certificate attributes identifying organization administrators
+// from the JSON-formatted ADMIN_ATTRIBUTES environment variable
+func loadAdminAttributes() (common.AttributePolicy, error) {
+	data, ok := os.LookupEnv("ADMIN_ATTRIBUTES")
+	if !ok || data == "" {
+		return nil, nil
+	}
+
+	policy := make(common.AttributePolicy)
+	if err := json.Unmarshal([]byte(data), &policy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// loadTreasuryOrganizations read identities of the organizations allowed to deposit tokens
+// from the JSON-formatted TREASURY_ORGANIZATIONS environment variable
+func loadTreasuryOrganizations() ([]string, error) {
+	data, ok := os.LookupEnv("TREASURY_ORGANIZATIONS")
+	if !ok || data == "" {
+		return nil, nil
+	}
+
+	organizationIds := make([]string, 0)
+	if err := json.Unmarshal([]byte(data), &organizationIds); err != nil {
+		return nil, err
+	}
+
+	return organizationIds, nil
+}
+
+// loadMigrationOrganizations read identities of the organizations allowed to migrate the ledger state
+// from the JSON-formatted MIGRATION_ORGANIZATIONS environment variable
+func loadMigrationOrganizations() ([]string, error) {
+	data, ok := os.LookupEnv("MIGRATION_ORGANIZATIONS")
+	if !ok || data == "" {
+		return nil, nil
+	}
+
+	organizationIds := make([]string, 0)
+	if err := json.Unmarshal([]byte(data), &organizationIds); err != nil {
+		return nil, err
+	}
+
+	return organizationIds, nil
+}
+
+// loadEventVersion read the version of the event envelope emitted by transactions from the EVENT_VERSION
+// environment variable, legacy events are emitted if it is not set
+func loadEventVersion() (int, error) {
+	data, ok := os.LookupEnv("EVENT_VERSION")
+	if !ok || data == "" {
+		return 0, nil
+	}
+
+	version, err := strconv.Atoi(data)
+	if err != nil {
+		return 0, err
+	}
+	if version != 0 && version != common.EventEnvelopeVersion {
+		return 0, fmt.Errorf("unsupported event version %d", version)
+	}
+
+	return version, nil
+}
+
+// loadMaxClockSkew read the maximum difference allowed between client times and transaction timestamps from the
+// MAX_CLOCK_SKEW environment variable, formatted as a duration such as 5m, or 0 to disable the check
+func loadMaxClockSkew() (time.Duration, error) {
+	data, ok := os.LookupEnv("MAX_CLOCK_SKEW")
+	if !ok || data == "" {
+		return contract.MaxClockSkew, nil
+	}
+
+	skew, err := time.ParseDuration(data)
+	if err != nil {
+		return 0, err
+	}
+	if skew < 0 {
+		return 0, fmt.Errorf("clock skew cannot be negative")
+	}
+
+	return skew, nil
+}
+
+// loadRequireEnrollment read whether devices registering themselves must consume an enrollment from the
+// REQUIRE_ENROLLMENT environment variable, enrollment is required unless it is set to false
+func loadRequireEnrollment() (bool, error) {
+	data, ok := os.LookupEnv("REQUIRE_ENROLLMENT")
+	if !ok || data == "" {
+		return contract.RequireEnrollment, nil
+	}
+
+	return strconv.ParseBool(data)
+}
+
+// transactionHooks hooks run around every transaction after the default ones, chaincode builders can append their
+// own hooks in an init function of this package
+var transactionHooks []contract.TransactionHook
+
+// latencyMetrics latency statistics of the successful calls to every smart contract function
+var latencyMetrics = new(contract.LatencyMetrics)
+
+// setTransactionHooks set up the transaction hook pipeline of a smart contract
+func setTransactionHooks(contract_ *contractapi.Contract, policies contract.AttributePolicies) {
+	hooks := contract.TransactionHooks{contract.IdentityHook{}, policies, contract.LoggingHook{}, latencyMetrics}
+	hooks = append(hooks, transactionHooks...)
+
+	contract_.BeforeTransaction = hooks.BeforeTransaction
+	contract_.AfterTransaction = hooks.AfterTransaction
+	contract_.UnknownTransaction = hooks.UnknownTransaction
+}
+
 func main() {
+	policies, err := loadAttributePolicies()
+	if err != nil {
+		log.Panicf("Failed to load attribute policies: %v", err)
+	}
+
+	if contract.OrganizationAdminAttributes, err = loadAdminAttributes(); err != nil {
+		log.Panicf("Failed to load administrator attributes: %v", err)
+	}
+
+	if contract.TreasuryOrganizationIds, err = loadTreasuryOrganizations(); err != nil {
+		log.Panicf("Failed to load treasury organizations: %v", err)
+	}
+
+	if contract.MigrationOrganizationIds, err = loadMigrationOrganizations(); err != nil {
+		log.Panicf("Failed to load migration organizations: %v", err)
+	}
+
+	if contract.EventVersion, err = loadEventVersion(); err != nil {
+		log.Panicf("Failed to load event version: %v", err)
+	}
+
+	if contract.MaxClockSkew, err = loadMaxClockSkew(); err != nil {
+		log.Panicf("Failed to load maximum clock skew: %v", err)
+	}
+
+	if contract.RequireEnrollment, err = loadRequireEnrollment(); err != nil {
+		log.Panicf("Failed to load enrollment requirement: %v", err)
+	}
+
 	deviceRegistryContract := new(contract.DeviceRegistrySmartContract)
 	deviceRegistryContract.TransactionContextHandler = new(contract.TransactionContext)
 	deviceRegistryContract.Name = "device_registry"
+	setTransactionHooks(&deviceRegistryContract.Contract, policies[deviceRegistryContract.Name])
 
 	serviceRegistryContract := new(contract.ServiceRegistrySmartContract)
 	serviceRegistryContract.TransactionContextHandler = new(contract.TransactionContext)
 	serviceRegistryContract.Name = "service_registry"
+	setTransactionHooks(&serviceRegistryContract.Contract, policies[serviceRegistryContract.Name])
 
 	serviceBrokerContract := new(contract.ServiceBrokerSmartContract)
 	serviceBrokerContract.TransactionContextHandler = new(contract.TransactionContext)
 	serviceBrokerContract.Name = "service_broker"
+	setTransactionHooks(&serviceBrokerContract.Contract, policies[serviceBrokerContract.Name])
+
+	accountLedgerContract := new(contract.AccountLedgerSmartContract)
+	accountLedgerContract.TransactionContextHandler = new(contract.TransactionContext)
+	accountLedgerContract.Name = "account_ledger"
+	setTransactionHooks(&accountLedgerContract.Contract, policies[accountLedgerContract.Name])
+
+	deviceGroupRegistryContract := new(contract.DeviceGroupRegistrySmartContract)
+	deviceGroupRegistryContract.TransactionContextHandler = new(contract.TransactionContext)
+	deviceGroupRegistryContract.Name = "device_group_registry"
+	setTransactionHooks(&deviceGroupRegistryContract.Contract, policies[deviceGroupRegistryContract.Name])
+
+	workflowRegistryContract := new(contract.WorkflowRegistrySmartContract)
+	workflowRegistryContract.TransactionContextHandler = new(contract.TransactionContext)
+	workflowRegistryContract.Name = "workflow_registry"
+	setTransactionHooks(&workflowRegistryContract.Contract, policies[workflowRegistryContract.Name])
+
+	firmwareRegistryContract := new(contract.FirmwareRegistrySmartContract)
+	firmwareRegistryContract.TransactionContextHandler = new(contract.TransactionContext)
+	firmwareRegistryContract.Name = "firmware_registry"
+	setTransactionHooks(&firmwareRegistryContract.Contract, policies[firmwareRegistryContract.Name])
+
+	migrationContract := new(contract.MigrationSmartContract)
+	migrationContract.TransactionContextHandler = new(contract.TransactionContext)
+	migrationContract.Name = "migration"
+	setTransactionHooks(&migrationContract.Contract, policies[migrationContract.Name])
 
-	chaincode, err := contractapi.NewChaincode(deviceRegistryContract, serviceRegistryContract, serviceBrokerContract)
+	chaincode, err := contractapi.NewChaincode(deviceRegistryContract, serviceRegistryContract, serviceBrokerContract, accountLedgerContract, deviceGroupRegistryContract, workflowRegistryContract, firmwareRegistryContract, migrationContract)
 
 	if err != nil {
 		log.Panicf("Failed to create chaincode: %v", err)
 	}
 
-	if err := chaincode.Start(); err != nil {
+	if err := shim.Start(contract.HookedChaincode{Chaincode: chaincode}); err != nil {
 		log.Panicf("Failed to start chaincode: %v", err)
 	}
 }
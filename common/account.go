@@ -0,0 +1,145 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AccountEntryKind the kind of a change made to an organization account
+type AccountEntryKind string
+
+const (
+	// AccountDeposit tokens have been added to the account
+	AccountDeposit AccountEntryKind = "deposit"
+
+	// AccountEscrow the price of a request has been moved from the balance of the requester into escrow
+	AccountEscrow AccountEntryKind = "escrow"
+
+	// AccountPayment the escrowed price of a request has been paid to the organization of the requested device
+	AccountPayment AccountEntryKind = "payment"
+
+	// AccountIncome the price of a request has been received from the requester
+	AccountIncome AccountEntryKind = "income"
+
+	// AccountRefund the escrowed price of a request has been returned to the balance of the requester
+	AccountRefund AccountEntryKind = "refund"
+)
+
+// Account a token account of an organization
+type Account struct {
+	// OrganizationId identity of the organization owning the account
+	OrganizationId string `json:"organizationId"`
+
+	// Balance number of tokens available for requests
+	Balance int64 `json:"balance"`
+
+	// Escrow number of tokens held for requests that have not been finished
+	Escrow int64 `json:"escrow"`
+
+	// LastUpdateTime the latest time that the account has been updated
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+}
+
+// GetKeyComponents return components that compose the account key
+func (a *Account) GetKeyComponents() []string {
+	return []string{a.OrganizationId}
+}
+
+// Serialize transform current account to JSON string
+func (a *Account) Serialize() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// Validate check if the account properties are valid
+func (a *Account) Validate() error {
+	if a.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in account definition")
+	}
+	if a.Balance < 0 || a.Escrow < 0 {
+		return fmt.Errorf("account balance cannot be negative")
+	}
+
+	return nil
+}
+
+// DeserializeAccount create an account instance from its JSON representation
+func DeserializeAccount(data []byte) (*Account, error) {
+	account := new(Account)
+
+	if err := json.Unmarshal(data, account); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// AccountEntry a line of the statement of an organization account
+type AccountEntry struct {
+	// OrganizationId identity of the organization owning the account
+	OrganizationId string `json:"organizationId"`
+
+	// TransactionId identity of the transaction that made the change
+	TransactionId string `json:"transactionId"`
+
+	// Kind the kind of the change
+	Kind AccountEntryKind `json:"kind"`
+
+	// Amount number of tokens involved in the change
+	Amount int64 `json:"amount"`
+
+	// Balance available balance of the account after the change
+	Balance int64 `json:"balance"`
+
+	// RequestId identity of the IoT service request that caused the change, if any
+	RequestId string `json:"requestId,omitempty"`
+
+	// Time time of the change
+	Time time.Time `json:"time"`
+}
+
+// GetKeyComponents return components that compose the account entry key, ordered by time
+func (e *AccountEntry) GetKeyComponents() []string {
+	components := []string{e.OrganizationId, e.Time.UTC().Format("20060102150405.000000000"), e.TransactionId, string(e.Kind)}
+
+	// a transaction can change the account for several requests, such as those expanded from a group request
+	if e.RequestId != "" {
+		components = append(components, e.RequestId)
+	}
+
+	return components
+}
+
+// Serialize transform current account entry to JSON string
+func (e *AccountEntry) Serialize() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Validate check if the account entry properties are valid
+func (e *AccountEntry) Validate() error {
+	if e.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in account entry definition")
+	}
+	if e.TransactionId == "" {
+		return fmt.Errorf("missing transaction ID in account entry definition")
+	}
+	if e.Kind == "" {
+		return fmt.Errorf("missing kind in account entry definition")
+	}
+	if e.Amount <= 0 {
+		return fmt.Errorf("account entry amount must be a positive integer")
+	}
+
+	return nil
+}
+
+// DeserializeAccountEntry create an account entry instance from its JSON representation
+func DeserializeAccountEntry(data []byte) (*AccountEntry, error) {
+	entry := new(AccountEntry)
+
+	if err := json.Unmarshal(data, entry); err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,84 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccountTestSuite struct {
+	suite.Suite
+}
+
+func (s *AccountTestSuite) TestGetKeyComponents() {
+	account := &Account{OrganizationId: "org1", Balance: 10}
+	assert.Equal(s.T(), []string{"org1"}, account.GetKeyComponents(), "should return correct key components")
+
+	entryTime, _ := time.Parse(time.RFC3339Nano, "2021-12-12T17:34:00.5-05:00")
+	entry := &AccountEntry{OrganizationId: "org1", TransactionId: "tx1", Kind: AccountDeposit, Amount: 10, Time: entryTime}
+	assert.Equal(s.T(), []string{"org1", "20211212223400.500000000", "tx1", "deposit"}, entry.GetKeyComponents(), "should return key components ordered by time")
+
+	entry = &AccountEntry{OrganizationId: "org1", TransactionId: "tx1", Kind: AccountEscrow, Amount: 10, RequestId: "request1", Time: entryTime}
+	assert.Equal(s.T(), []string{"org1", "20211212223400.500000000", "tx1", "escrow", "request1"}, entry.GetKeyComponents(), "should distinguish entries of different requests")
+}
+
+func (s *AccountTestSuite) TestSerialize() {
+	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	account := &Account{OrganizationId: "org1", Balance: 10, Escrow: 5, LastUpdateTime: updateTime}
+	serialized := "{\"organizationId\":\"org1\",\"balance\":10,\"escrow\":5,\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := account.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	entry := &AccountEntry{OrganizationId: "org1", TransactionId: "tx1", Kind: AccountEscrow, Amount: 5, Balance: 10, RequestId: "request1", Time: updateTime}
+	serialized = "{\"organizationId\":\"org1\",\"transactionId\":\"tx1\",\"kind\":\"escrow\",\"amount\":5,\"balance\":10," +
+		"\"requestId\":\"request1\",\"time\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err = entry.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *AccountTestSuite) TestValidate() {
+	account := &Account{}
+	assert.Error(s.T(), account.Validate(), "should error on empty organization ID")
+	account.OrganizationId = "org1"
+	account.Balance = -1
+	assert.Error(s.T(), account.Validate(), "should error on negative balance")
+	account.Balance = 0
+	assert.Nil(s.T(), account.Validate(), "should return no error")
+
+	entry := &AccountEntry{}
+	assert.Error(s.T(), entry.Validate(), "should error on empty organization ID")
+	entry.OrganizationId = "org1"
+	assert.Error(s.T(), entry.Validate(), "should error on empty transaction ID")
+	entry.TransactionId = "tx1"
+	assert.Error(s.T(), entry.Validate(), "should error on empty kind")
+	entry.Kind = AccountRefund
+	assert.Error(s.T(), entry.Validate(), "should error on non-positive amount")
+	entry.Amount = 1
+	assert.Nil(s.T(), entry.Validate(), "should return no error")
+}
+
+func (s *AccountTestSuite) TestDeserialize() {
+	serialized := "{\"organizationId\":\"org1\",\"balance\":10,\"escrow\":5,\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}"
+	account, err := DeserializeAccount([]byte(serialized))
+	assert.Equal(s.T(), int64(10), account.Balance, "should return parsed account")
+	assert.Nil(s.T(), err, "should return no error")
+	_, err = DeserializeAccount([]byte{0x00})
+	assert.Error(s.T(), err, "should return an error")
+
+	serialized = "{\"organizationId\":\"org1\",\"transactionId\":\"tx1\",\"kind\":\"escrow\",\"amount\":5,\"balance\":10,\"time\":\"2021-12-12T17:34:00-05:00\"}"
+	entry, err := DeserializeAccountEntry([]byte(serialized))
+	assert.Equal(s.T(), AccountEscrow, entry.Kind, "should return parsed account entry")
+	assert.Nil(s.T(), err, "should return no error")
+	_, err = DeserializeAccountEntry([]byte{0x00})
+	assert.Error(s.T(), err, "should return an error")
+}
+
+func TestAccountTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountTestSuite))
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+)
+
+// AttributePolicy certificate attributes required to perform an operation, keyed by attribute name.
+// A client satisfies the policy if its certificate carries every attribute with one of the listed
+// values, or with any value if no value is listed.
+type AttributePolicy map[string][]string
+
+// Check check if a client satisfies the policy, given a function reading the client's certificate attributes
+func (p AttributePolicy) Check(getAttributeValue func(name string) (string, bool, error)) error {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	// report missing attributes in a stable order
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, found, err := getAttributeValue(name)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return &AccessDeniedError{Reason: fmt.Sprintf("missing attribute %s", name)}
+		}
+
+		values := p[name]
+		if len(values) == 0 {
+			continue
+		}
+
+		allowed := false
+		for _, v := range values {
+			if v == value {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return &AccessDeniedError{Reason: fmt.Sprintf("attribute %s has value %s", name, value)}
+		}
+	}
+
+	return nil
+}
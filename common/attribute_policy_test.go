@@ -0,0 +1,44 @@
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AttributePolicyTestSuite struct {
+	suite.Suite
+}
+
+func (s *AttributePolicyTestSuite) TestCheck() {
+	attributes := map[string]string{"role": "operator", "hf.Type": "client"}
+	getAttributeValue := func(name string) (string, bool, error) {
+		value, found := attributes[name]
+		return value, found, nil
+	}
+
+	var policy AttributePolicy
+	assert.Nil(s.T(), policy.Check(getAttributeValue), "should allow everyone without policy")
+
+	policy = AttributePolicy{"role": {"operator", "admin"}, "hf.Type": {}}
+	assert.Nil(s.T(), policy.Check(getAttributeValue), "should allow clients with required attributes")
+
+	policy = AttributePolicy{"role": {"consumer"}}
+	assert.IsType(s.T(), new(AccessDeniedError), policy.Check(getAttributeValue), "should deny clients with wrong attribute value")
+
+	policy = AttributePolicy{"department": {}}
+	err := policy.Check(getAttributeValue)
+	assert.IsType(s.T(), new(AccessDeniedError), err, "should deny clients without required attribute")
+	assert.Regexp(s.T(), "department", err.Error())
+
+	err = policy.Check(func(name string) (string, bool, error) {
+		return "", false, errors.New("bad certificate")
+	})
+	assert.EqualError(s.T(), err, "bad certificate", "should return attribute reading error")
+}
+
+func TestAttributePolicyTestSuite(t *testing.T) {
+	suite.Run(t, new(AttributePolicyTestSuite))
+}
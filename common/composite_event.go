@@ -0,0 +1,54 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CompositeEventPrefix prefix of the names of events bundling all changes made by a transaction
+const CompositeEventPrefix = "composite://"
+
+// ChangeEvent a change to a device, service, or request made by a transaction
+type ChangeEvent struct {
+	// Name name of the event as if it were emitted on its own, such as device://org1/device1/deregister
+	Name string `json:"name"`
+
+	// Payload payload of the event as if it were emitted on its own
+	Payload []byte `json:"payload,omitempty"`
+}
+
+// CompositeEvent an event bundling all changes made by a transaction, since Fabric keeps only one event per transaction
+type CompositeEvent struct {
+	// TransactionId ID of the transaction that made the changes
+	TransactionId string `json:"transactionId"`
+
+	// Events changes made by the transaction, starting with the change directly requested by the client
+	Events []*ChangeEvent `json:"events"`
+}
+
+// GetName return the name of the composite event
+func (e *CompositeEvent) GetName() string {
+	return fmt.Sprintf("%s%s", CompositeEventPrefix, e.TransactionId)
+}
+
+// Serialize transform current composite event to JSON string
+func (e *CompositeEvent) Serialize() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// IsCompositeEvent check if an event with the given name is a composite event
+func IsCompositeEvent(name string) bool {
+	return strings.HasPrefix(name, CompositeEventPrefix)
+}
+
+// DeserializeCompositeEvent create a composite event instance from its JSON representation
+func DeserializeCompositeEvent(data []byte) (*CompositeEvent, error) {
+	event := new(CompositeEvent)
+
+	if err := json.Unmarshal(data, event); err != nil {
+		return nil, err
+	}
+
+	return event, nil
+}
@@ -0,0 +1,56 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CompositeEventTestSuite struct {
+	suite.Suite
+}
+
+func (s *CompositeEventTestSuite) TestGetName() {
+	event := &CompositeEvent{TransactionId: "tx1"}
+	assert.Equal(s.T(), "composite://tx1", event.GetName(), "should return name with transaction ID")
+}
+
+func (s *CompositeEventTestSuite) TestIsCompositeEvent() {
+	assert.True(s.T(), IsCompositeEvent("composite://tx1"), "should recognize composite event")
+	assert.False(s.T(), IsCompositeEvent("device://org1/device1/deregister"), "should not recognize other events")
+}
+
+func (s *CompositeEventTestSuite) TestSerialize() {
+	event := &CompositeEvent{
+		TransactionId: "tx1",
+		Events: []*ChangeEvent{
+			{Name: "service://org1/device1/service1/deregister"},
+			{Name: "request://org1/device1/service1/request1/remove", Payload: []byte("request1")},
+		},
+	}
+	serialized := "{\"transactionId\":\"tx1\",\"events\":[{\"name\":\"service://org1/device1/service1/deregister\"},{\"name\":\"request://org1/device1/service1/request1/remove\",\"payload\":\"cmVxdWVzdDE=\"}]}"
+
+	data, err := event.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *CompositeEventTestSuite) TestDeserializeCompositeEvent() {
+	expected := &CompositeEvent{
+		TransactionId: "tx1",
+		Events:        []*ChangeEvent{{Name: "request://org1/device1/service1/request1/remove", Payload: []byte("request1")}},
+	}
+	serialized := "{\"transactionId\":\"tx1\",\"events\":[{\"name\":\"request://org1/device1/service1/request1/remove\",\"payload\":\"cmVxdWVzdDE=\"}]}"
+
+	actual, err := DeserializeCompositeEvent([]byte(serialized))
+	assert.Equal(s.T(), expected, actual, "should return parsed composite event")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeCompositeEvent([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func TestCompositeEventTestSuite(t *testing.T) {
+	suite.Run(t, new(CompositeEventTestSuite))
+}
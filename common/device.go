@@ -20,8 +20,14 @@ type Device struct {
 	// Description a brief summary of the device's functions
 	Description string `json:"description"`
 
+	// Model model of the device, matched against the target models of firmware releases
+	Model string `json:"model,omitempty"`
+
 	// LastUpdateTime the latest time that the device state has been updated
 	LastUpdateTime time.Time `json:"lastUpdateTime"`
+
+	// HeartbeatTimeout seconds after the last heartbeat when the device is considered offline, the network default is used if zero
+	HeartbeatTimeout int64 `json:"heartbeatTimeout,omitempty"`
 }
 
 // GetKeyComponents return components that compose the device key
@@ -48,6 +54,9 @@ func (d *Device) Validate() error {
 	if d.LastUpdateTime.IsZero() {
 		return fmt.Errorf("missing device last update time in device definition")
 	}
+	if d.HeartbeatTimeout < 0 {
+		return fmt.Errorf("device heartbeat timeout cannot be negative")
+	}
 
 	return nil
 }
@@ -0,0 +1,125 @@
+package common
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeviceEnrollment an authorization issued by an organization administrator for a device to register itself, which
+// is consumed by the first registration matching it
+type DeviceEnrollment struct {
+	// Id identity of the enrollment, a UUID
+	Id string `json:"id"`
+
+	// OrganizationId identity of the organization the device joins
+	OrganizationId string `json:"organizationId"`
+
+	// CreatorId identity of the administrator that issued the enrollment, maintained by the device registry
+	CreatorId string `json:"creatorId,omitempty"`
+
+	// Subject expected distinguished name of the device certificate subject, such as CN=device1,OU=client
+	Subject string `json:"subject,omitempty"`
+
+	// SerialNumber expected serial number of the device certificate in hexadecimal
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// ClaimCodeHash hex-encoded SHA-256 hash of the one-time claim code the device presents
+	ClaimCodeHash string `json:"claimCodeHash,omitempty"`
+
+	// ExpiryTime time after which the enrollment can no longer be consumed
+	ExpiryTime time.Time `json:"expiryTime"`
+
+	// CreateTime time when the enrollment has been issued
+	CreateTime time.Time `json:"createTime"`
+
+	// DeviceId identity of the device that consumed the enrollment, empty while it is open
+	DeviceId string `json:"deviceId,omitempty"`
+
+	// ConsumeTime time when the enrollment has been consumed
+	ConsumeTime time.Time `json:"consumeTime,omitempty"`
+}
+
+// IsConsumed check if a device has registered with the enrollment
+func (e *DeviceEnrollment) IsConsumed() bool {
+	return e.DeviceId != ""
+}
+
+// IsExpired check if the enrollment has expired at the given time
+func (e *DeviceEnrollment) IsExpired(now time.Time) bool {
+	return !now.Before(e.ExpiryTime)
+}
+
+// Matches check if a device with the certificate and claim code satisfies every criterion of the enrollment
+func (e *DeviceEnrollment) Matches(cert *x509.Certificate, claimCode string) bool {
+	if cert == nil {
+		return false
+	}
+	if e.Subject != "" && e.Subject != GetSubjectDN(cert) {
+		return false
+	}
+	if e.SerialNumber != "" && NormalizeSerialNumber(e.SerialNumber) != cert.SerialNumber.Text(16) {
+		return false
+	}
+	if e.ClaimCodeHash != "" && (claimCode == "" || !strings.EqualFold(e.ClaimCodeHash, HashClaimCode(claimCode))) {
+		return false
+	}
+
+	return true
+}
+
+// GetKeyComponents return components that compose the device enrollment key
+func (e *DeviceEnrollment) GetKeyComponents() []string {
+	return []string{e.OrganizationId, e.Id}
+}
+
+// Serialize transform current device enrollment to JSON string
+func (e *DeviceEnrollment) Serialize() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// Validate check if the device enrollment properties are valid
+func (e *DeviceEnrollment) Validate() error {
+	if _, err := uuid.Parse(e.Id); err != nil {
+		return fmt.Errorf("invalid enrollment ID in device enrollment definition")
+	}
+	if e.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in device enrollment definition")
+	}
+	if e.Subject == "" && e.SerialNumber == "" && e.ClaimCodeHash == "" {
+		return fmt.Errorf("missing subject, serial number or claim code hash in device enrollment definition")
+	}
+	if e.ClaimCodeHash != "" {
+		if hash, err := hex.DecodeString(e.ClaimCodeHash); err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("invalid claim code hash in device enrollment definition")
+		}
+	}
+	if e.ExpiryTime.IsZero() {
+		return fmt.Errorf("missing expiry time in device enrollment definition")
+	}
+
+	return nil
+}
+
+// DeserializeDeviceEnrollment create a device enrollment instance from its JSON representation
+func DeserializeDeviceEnrollment(data []byte) (*DeviceEnrollment, error) {
+	enrollment := new(DeviceEnrollment)
+
+	if err := json.Unmarshal(data, enrollment); err != nil {
+		return nil, err
+	}
+
+	return enrollment, nil
+}
+
+// HashClaimCode return the hex-encoded SHA-256 hash of a claim code, as stored in device enrollments
+func HashClaimCode(claimCode string) string {
+	hash := sha256.Sum256([]byte(claimCode))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceEnrollmentTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceEnrollmentTestSuite) TestMatches() {
+	cert, _ := ParseCertificate([]byte(CERTIFICATE1))
+	enrollment := &DeviceEnrollment{Subject: "CN=user1,C=US,ST=North Carolina,O=Hyperledger,OU=client"}
+	assert.True(s.T(), enrollment.Matches(cert, ""), "should match certificate subject")
+
+	enrollment.SerialNumber = "77FBB30888189EFAF9215AC6827557205FC9BD63"
+	assert.True(s.T(), enrollment.Matches(cert, ""), "should match certificate serial number")
+	enrollment.SerialNumber = "77fbb30888189efaf9215ac6827557205fc9bd64"
+	assert.False(s.T(), enrollment.Matches(cert, ""), "should refuse other serial numbers")
+	enrollment.SerialNumber = ""
+
+	enrollment.ClaimCodeHash = HashClaimCode("code1")
+	assert.True(s.T(), enrollment.Matches(cert, "code1"), "should match claim code")
+	assert.False(s.T(), enrollment.Matches(cert, "code2"), "should refuse other claim codes")
+	assert.False(s.T(), enrollment.Matches(cert, ""), "should refuse missing claim code")
+
+	enrollment.Subject = "CN=user2,C=US,ST=North Carolina,O=Hyperledger,OU=client"
+	assert.False(s.T(), enrollment.Matches(cert, "code1"), "should refuse other subjects")
+	assert.False(s.T(), enrollment.Matches(nil, "code1"), "should refuse missing certificate")
+}
+
+func (s *DeviceEnrollmentTestSuite) TestIsExpired() {
+	now := time.Now()
+	enrollment := &DeviceEnrollment{ExpiryTime: now}
+
+	assert.False(s.T(), enrollment.IsExpired(now.Add(-time.Second)), "should be open before expiry time")
+	assert.True(s.T(), enrollment.IsExpired(now), "should expire at expiry time")
+	assert.False(s.T(), enrollment.IsConsumed(), "should not be consumed")
+
+	enrollment.DeviceId = "device1"
+	assert.True(s.T(), enrollment.IsConsumed(), "should be consumed")
+}
+
+func (s *DeviceEnrollmentTestSuite) TestGetKeyComponents() {
+	enrollment := &DeviceEnrollment{OrganizationId: "org1", Id: "enrollment1"}
+	assert.Equal(s.T(), []string{"org1", "enrollment1"}, enrollment.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *DeviceEnrollmentTestSuite) TestSerialize() {
+	expiryTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	enrollment := &DeviceEnrollment{
+		Id:             "enrollment1",
+		OrganizationId: "org1",
+		Subject:        "CN=device1",
+		ExpiryTime:     expiryTime,
+		CreateTime:     expiryTime,
+		ConsumeTime:    expiryTime,
+	}
+	serialized := "{\"id\":\"enrollment1\",\"organizationId\":\"org1\",\"subject\":\"CN=device1\",\"expiryTime\":\"2021-12-12T17:34:00-05:00\"," +
+		"\"createTime\":\"2021-12-12T17:34:00-05:00\",\"consumeTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := enrollment.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeDeviceEnrollment(data)
+	assert.Equal(s.T(), enrollment, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeDeviceEnrollment([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *DeviceEnrollmentTestSuite) TestValidate() {
+	enrollment := DeviceEnrollment{Id: "enrollment1"}
+
+	assert.Error(s.T(), enrollment.Validate(), "should error on invalid enrollment ID")
+	assert.Regexp(s.T(), "enrollment ID", enrollment.Validate().Error())
+	enrollment.Id = "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1"
+
+	assert.Error(s.T(), enrollment.Validate(), "should error on empty organization ID")
+	assert.Regexp(s.T(), "organization ID", enrollment.Validate().Error())
+	enrollment.OrganizationId = "org1"
+
+	assert.Error(s.T(), enrollment.Validate(), "should error on missing criteria")
+	assert.Regexp(s.T(), "subject, serial number or claim code hash", enrollment.Validate().Error())
+	enrollment.ClaimCodeHash = "code1"
+
+	assert.Error(s.T(), enrollment.Validate(), "should error on invalid claim code hash")
+	assert.Regexp(s.T(), "claim code hash", enrollment.Validate().Error())
+	enrollment.ClaimCodeHash = HashClaimCode("code1")
+
+	assert.Error(s.T(), enrollment.Validate(), "should error on empty expiry time")
+	assert.Regexp(s.T(), "expiry time", enrollment.Validate().Error())
+	enrollment.ExpiryTime = time.Now()
+
+	assert.Nil(s.T(), enrollment.Validate(), "should return no error")
+}
+
+func TestDeviceEnrollmentTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceEnrollmentTestSuite))
+}
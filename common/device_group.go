@@ -0,0 +1,122 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeviceGroupPolicy policy deciding which devices can join a device group by themselves
+type DeviceGroupPolicy string
+
+const (
+	// DeviceGroupClosed only the owner of the group can add members
+	DeviceGroupClosed DeviceGroupPolicy = "closed"
+
+	// DeviceGroupOrganization devices of the group's organization can also join by themselves
+	DeviceGroupOrganization DeviceGroupPolicy = "organization"
+
+	// DeviceGroupOpen devices of any organization can also join by themselves
+	DeviceGroupOpen DeviceGroupPolicy = "open"
+)
+
+// DeviceGroupMember a device belonging to a device group
+type DeviceGroupMember struct {
+	// OrganizationId identity of the organization of the member device
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the member device
+	DeviceId string `json:"deviceId"`
+}
+
+// DeviceGroup a named group of IoT devices that can be requested together
+type DeviceGroup struct {
+	// OrganizationId identity of the organization to which the group belongs
+	OrganizationId string `json:"organizationId"`
+
+	// Name name of the group, unique within its organization
+	Name string `json:"name"`
+
+	// Description a brief summary of the group
+	Description string `json:"description,omitempty"`
+
+	// OwnerId identity of the client that owns the group, maintained by the device group registry
+	OwnerId string `json:"ownerId,omitempty"`
+
+	// Policy membership policy of the group, closed if empty
+	Policy DeviceGroupPolicy `json:"policy,omitempty"`
+
+	// Members devices belonging to the group, maintained by the device group registry
+	Members []*DeviceGroupMember `json:"members,omitempty"`
+
+	// LastUpdateTime the latest time that the group has been updated
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+}
+
+// CanJoin check if a device can join the group by itself under the membership policy
+func (g *DeviceGroup) CanJoin(organizationId string) bool {
+	switch g.Policy {
+	case DeviceGroupOpen:
+		return true
+	case DeviceGroupOrganization:
+		return organizationId == g.OrganizationId
+	default:
+		return false
+	}
+}
+
+// IndexOfMember return the index of a member device in the group, -1 if the device is not a member
+func (g *DeviceGroup) IndexOfMember(organizationId string, deviceId string) int {
+	for i, member := range g.Members {
+		if member.OrganizationId == organizationId && member.DeviceId == deviceId {
+			return i
+		}
+	}
+	return -1
+}
+
+// GetKeyComponents return components that compose the device group key
+func (g *DeviceGroup) GetKeyComponents() []string {
+	return []string{g.OrganizationId, g.Name}
+}
+
+// Serialize transform current device group to JSON string
+func (g *DeviceGroup) Serialize() ([]byte, error) {
+	return json.Marshal(g)
+}
+
+// Validate check if the device group properties are valid
+func (g *DeviceGroup) Validate() error {
+	if g.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in device group definition")
+	}
+	if g.Name == "" {
+		return fmt.Errorf("missing group name in device group definition")
+	}
+	switch g.Policy {
+	case "", DeviceGroupClosed, DeviceGroupOrganization, DeviceGroupOpen:
+	default:
+		return fmt.Errorf("unknown membership policy %s in device group definition", g.Policy)
+	}
+	for _, member := range g.Members {
+		if member == nil || member.OrganizationId == "" || member.DeviceId == "" {
+			return fmt.Errorf("missing member device in device group definition")
+		}
+	}
+	if g.LastUpdateTime.IsZero() {
+		return fmt.Errorf("missing group last update time in device group definition")
+	}
+
+	return nil
+}
+
+// DeserializeDeviceGroup create a device group instance from its JSON representation
+func DeserializeDeviceGroup(data []byte) (*DeviceGroup, error) {
+	group := new(DeviceGroup)
+
+	if err := json.Unmarshal(data, group); err != nil {
+		return nil, err
+	}
+
+	return group, nil
+}
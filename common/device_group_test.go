@@ -0,0 +1,97 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceGroupTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceGroupTestSuite) TestGetKeyComponents() {
+	group := &DeviceGroup{OrganizationId: "org1", Name: "group1"}
+	assert.Equal(s.T(), []string{"org1", "group1"}, group.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *DeviceGroupTestSuite) TestSerialize() {
+	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	group := &DeviceGroup{
+		OrganizationId: "org1",
+		Name:           "group1",
+		OwnerId:        "device1",
+		Policy:         DeviceGroupOpen,
+		Members:        []*DeviceGroupMember{{OrganizationId: "org2", DeviceId: "device2"}},
+		LastUpdateTime: updateTime,
+	}
+	serialized := "{\"organizationId\":\"org1\",\"name\":\"group1\",\"ownerId\":\"device1\",\"policy\":\"open\"," +
+		"\"members\":[{\"organizationId\":\"org2\",\"deviceId\":\"device2\"}],\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := group.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceGroupTestSuite) TestValidate() {
+	group := DeviceGroup{}
+
+	assert.Error(s.T(), group.Validate(), "should error on empty organization ID")
+	assert.Regexp(s.T(), "organization ID", group.Validate().Error())
+	group.OrganizationId = "org1"
+
+	assert.Error(s.T(), group.Validate(), "should error on empty name")
+	assert.Regexp(s.T(), "group name", group.Validate().Error())
+	group.Name = "group1"
+
+	group.Policy = "unknown"
+	assert.Error(s.T(), group.Validate(), "should error on unknown policy")
+	assert.Regexp(s.T(), "policy", group.Validate().Error())
+	group.Policy = DeviceGroupOrganization
+
+	group.Members = []*DeviceGroupMember{{OrganizationId: "org1"}}
+	assert.Error(s.T(), group.Validate(), "should error on incomplete member")
+	assert.Regexp(s.T(), "member", group.Validate().Error())
+	group.Members[0].DeviceId = "device1"
+
+	assert.Error(s.T(), group.Validate(), "should error on empty last update time")
+	assert.Regexp(s.T(), "last update time", group.Validate().Error())
+	group.LastUpdateTime = time.Now()
+
+	assert.Nil(s.T(), group.Validate(), "should return no error")
+}
+
+func (s *DeviceGroupTestSuite) TestCanJoin() {
+	group := &DeviceGroup{OrganizationId: "org1"}
+	assert.False(s.T(), group.CanJoin("org1"), "should refuse devices of closed groups")
+
+	group.Policy = DeviceGroupOrganization
+	assert.True(s.T(), group.CanJoin("org1"), "should admit devices of the group's organization")
+	assert.False(s.T(), group.CanJoin("org2"), "should refuse devices of other organizations")
+
+	group.Policy = DeviceGroupOpen
+	assert.True(s.T(), group.CanJoin("org2"), "should admit devices of any organization")
+}
+
+func (s *DeviceGroupTestSuite) TestIndexOfMember() {
+	group := &DeviceGroup{Members: []*DeviceGroupMember{{OrganizationId: "org1", DeviceId: "device1"}, {OrganizationId: "org2", DeviceId: "device1"}}}
+	assert.Equal(s.T(), 1, group.IndexOfMember("org2", "device1"), "should return index of the member")
+	assert.Equal(s.T(), -1, group.IndexOfMember("org2", "device2"), "should return -1 for non-members")
+}
+
+func (s *DeviceGroupTestSuite) TestDeserializeDeviceGroup() {
+	expected := &DeviceGroup{OrganizationId: "org1", Name: "group1", Policy: DeviceGroupClosed}
+
+	actual, err := DeserializeDeviceGroup([]byte("{\"organizationId\":\"org1\",\"name\":\"group1\",\"policy\":\"closed\",\"lastUpdateTime\":\"0001-01-01T00:00:00Z\"}"))
+	assert.Equal(s.T(), expected, actual, "should return parsed device group")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeDeviceGroup([]byte{0x00})
+	assert.Error(s.T(), err, "should return error")
+}
+
+func TestDeviceGroupTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceGroupTestSuite))
+}
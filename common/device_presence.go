@@ -0,0 +1,66 @@
+package common
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// DevicePresenceState whether a device is reachable or not
+type DevicePresenceState string
+
+const (
+	// DevicePresenceOnline the device has sent a heartbeat within its heartbeat timeout
+	DevicePresenceOnline DevicePresenceState = "online"
+
+	// DevicePresenceOffline the device has not sent a heartbeat within its heartbeat timeout or has gone offline
+	DevicePresenceOffline DevicePresenceState = "offline"
+)
+
+// DevicePresence the liveness of a device reported by its heartbeats
+type DevicePresence struct {
+	// OrganizationId identity of the organization to which the device belongs
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the device
+	DeviceId string `json:"deviceId"`
+
+	// State presence state of the device
+	State DevicePresenceState `json:"state"`
+
+	// LastSeenTime time of the last heartbeat sent by the device
+	LastSeenTime time.Time `json:"lastSeenTime"`
+
+	// Status optional status fields reported by the device in its last heartbeat
+	Status map[string]string `json:"status,omitempty"`
+}
+
+// IsOnline check if the device is online at the given time
+func (p *DevicePresence) IsOnline(now time.Time, timeout time.Duration) bool {
+	return p.State == DevicePresenceOnline && !now.After(p.LastSeenTime.Add(timeout))
+}
+
+// GetKeyComponents return components that compose the device presence key
+func (p *DevicePresence) GetKeyComponents() []string {
+	return []string{p.OrganizationId, p.DeviceId}
+}
+
+// Serialize transform current device presence to JSON string
+func (p *DevicePresence) Serialize() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// Validate check if the device presence properties are valid
+func (p *DevicePresence) Validate() error {
+	return nil
+}
+
+// DeserializeDevicePresence create a device presence instance from its JSON representation
+func DeserializeDevicePresence(data []byte) (*DevicePresence, error) {
+	presence := new(DevicePresence)
+
+	if err := json.Unmarshal(data, presence); err != nil {
+		return nil, err
+	}
+
+	return presence, nil
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DevicePresenceTestSuite struct {
+	suite.Suite
+}
+
+func (s *DevicePresenceTestSuite) TestIsOnline() {
+	lastSeenTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	presence := &DevicePresence{State: DevicePresenceOnline, LastSeenTime: lastSeenTime}
+
+	assert.True(s.T(), presence.IsOnline(lastSeenTime.Add(time.Minute), time.Minute), "should be online within the timeout")
+	assert.False(s.T(), presence.IsOnline(lastSeenTime.Add(time.Minute+time.Second), time.Minute), "should be offline after the timeout")
+
+	presence.State = DevicePresenceOffline
+	assert.False(s.T(), presence.IsOnline(lastSeenTime, time.Minute), "should be offline if the device has gone offline")
+}
+
+func (s *DevicePresenceTestSuite) TestGetKeyComponents() {
+	presence := &DevicePresence{OrganizationId: "org1", DeviceId: "device1"}
+	assert.Equal(s.T(), []string{"org1", "device1"}, presence.GetKeyComponents(), "should return organization ID and device ID")
+}
+
+func (s *DevicePresenceTestSuite) TestSerialize() {
+	lastSeenTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	presence := &DevicePresence{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		State:          DevicePresenceOnline,
+		LastSeenTime:   lastSeenTime,
+		Status:         map[string]string{"battery": "80"},
+	}
+	serialized := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"state\":\"online\",\"lastSeenTime\":\"2021-12-12T17:34:00-05:00\",\"status\":{\"battery\":\"80\"}}"
+
+	data, err := presence.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DevicePresenceTestSuite) TestDeserializeDevicePresence() {
+	lastSeenTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	expected := &DevicePresence{OrganizationId: "org1", DeviceId: "device1", State: DevicePresenceOffline, LastSeenTime: lastSeenTime}
+	serialized := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"state\":\"offline\",\"lastSeenTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	actual, err := DeserializeDevicePresence([]byte(serialized))
+	assert.Equal(s.T(), expected, actual, "should return parsed device presence")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeDevicePresence([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func TestDevicePresenceTestSuite(t *testing.T) {
+	suite.Run(t, new(DevicePresenceTestSuite))
+}
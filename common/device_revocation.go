@@ -0,0 +1,87 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// DeviceRevocation a revocation of a device or one of its certificates by the administrators of its organization,
+// which rejects every change to the ledger made by the revoked identity and every request made to the device
+type DeviceRevocation struct {
+	// OrganizationId identity of the organization of the device
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the revoked device
+	DeviceId string `json:"deviceId"`
+
+	// SerialNumber serial number of the revoked certificate in hexadecimal, every certificate of the device is revoked
+	// if it is empty
+	SerialNumber string `json:"serialNumber,omitempty"`
+
+	// Reason description of why the device has been revoked
+	Reason string `json:"reason,omitempty"`
+
+	// RevokerId identity of the administrator that revoked the device, maintained by the device registry
+	RevokerId string `json:"revokerId,omitempty"`
+
+	// Time time when the device has been revoked
+	Time time.Time `json:"time"`
+}
+
+// Matches check if the revocation applies to a certificate of the device with the serial number, an empty serial
+// number only matches revocations of every certificate
+func (r *DeviceRevocation) Matches(serialNumber string) bool {
+	return r.SerialNumber == "" || (serialNumber != "" && r.SerialNumber == NormalizeSerialNumber(serialNumber))
+}
+
+// GetKeyComponents return components that compose the device revocation key
+func (r *DeviceRevocation) GetKeyComponents() []string {
+	return []string{r.OrganizationId, r.DeviceId, r.SerialNumber}
+}
+
+// Serialize transform current device revocation to JSON string
+func (r *DeviceRevocation) Serialize() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Validate check if the device revocation properties are valid
+func (r *DeviceRevocation) Validate() error {
+	if r.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in device revocation definition")
+	}
+	if r.DeviceId == "" {
+		return fmt.Errorf("missing device ID in device revocation definition")
+	}
+	if r.SerialNumber != "" && r.SerialNumber != NormalizeSerialNumber(r.SerialNumber) {
+		return fmt.Errorf("invalid serial number in device revocation definition")
+	}
+	if r.Time.IsZero() {
+		return fmt.Errorf("missing revocation time in device revocation definition")
+	}
+
+	return nil
+}
+
+// DeserializeDeviceRevocation create a device revocation instance from its JSON representation
+func DeserializeDeviceRevocation(data []byte) (*DeviceRevocation, error) {
+	revocation := new(DeviceRevocation)
+
+	if err := json.Unmarshal(data, revocation); err != nil {
+		return nil, err
+	}
+
+	return revocation, nil
+}
+
+// NormalizeSerialNumber return the lowercase hexadecimal form of a certificate serial number without leading zeros,
+// or the input unchanged if it is not hexadecimal
+func NormalizeSerialNumber(serialNumber string) string {
+	number, ok := new(big.Int).SetString(strings.TrimPrefix(strings.ToLower(serialNumber), "0x"), 16)
+	if !ok {
+		return serialNumber
+	}
+	return number.Text(16)
+}
@@ -0,0 +1,86 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceRevocationTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceRevocationTestSuite) TestMatches() {
+	revocation := &DeviceRevocation{OrganizationId: "org1", DeviceId: "device1"}
+	assert.True(s.T(), revocation.Matches("77fbb30888189efaf9215ac6827557205fc9bd63"), "should match every certificate")
+	assert.True(s.T(), revocation.Matches(""), "should match the device")
+
+	revocation.SerialNumber = "77fbb30888189efaf9215ac6827557205fc9bd63"
+	assert.True(s.T(), revocation.Matches("77FBB30888189EFAF9215AC6827557205FC9BD63"), "should match certificate serial number")
+	assert.False(s.T(), revocation.Matches("77fbb30888189efaf9215ac6827557205fc9bd64"), "should refuse other serial numbers")
+	assert.False(s.T(), revocation.Matches(""), "should not match the whole device")
+}
+
+func (s *DeviceRevocationTestSuite) TestNormalizeSerialNumber() {
+	assert.Equal(s.T(), "1a", NormalizeSerialNumber("0x001A"), "should return lowercase hexadecimal without leading zeros")
+	assert.Equal(s.T(), "serial1", NormalizeSerialNumber("serial1"), "should return invalid serial numbers unchanged")
+}
+
+func (s *DeviceRevocationTestSuite) TestGetKeyComponents() {
+	revocation := &DeviceRevocation{OrganizationId: "org1", DeviceId: "device1", SerialNumber: "1a"}
+	assert.Equal(s.T(), []string{"org1", "device1", "1a"}, revocation.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *DeviceRevocationTestSuite) TestSerialize() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	revocation := &DeviceRevocation{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		SerialNumber:   "1a",
+		Reason:         "stolen",
+		RevokerId:      "admin1",
+		Time:           now,
+	}
+	serialized := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serialNumber\":\"1a\",\"reason\":\"stolen\"," +
+		"\"revokerId\":\"admin1\",\"time\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := revocation.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeDeviceRevocation(data)
+	assert.Equal(s.T(), revocation, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeDeviceRevocation([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *DeviceRevocationTestSuite) TestValidate() {
+	revocation := DeviceRevocation{}
+
+	assert.Error(s.T(), revocation.Validate(), "should error on empty organization ID")
+	assert.Regexp(s.T(), "organization ID", revocation.Validate().Error())
+	revocation.OrganizationId = "org1"
+
+	assert.Error(s.T(), revocation.Validate(), "should error on empty device ID")
+	assert.Regexp(s.T(), "device ID", revocation.Validate().Error())
+	revocation.DeviceId = "device1"
+
+	revocation.SerialNumber = "0x1A"
+	assert.Error(s.T(), revocation.Validate(), "should error on unnormalized serial number")
+	assert.Regexp(s.T(), "serial number", revocation.Validate().Error())
+	revocation.SerialNumber = "1a"
+
+	assert.Error(s.T(), revocation.Validate(), "should error on empty revocation time")
+	assert.Regexp(s.T(), "revocation time", revocation.Validate().Error())
+	revocation.Time = time.Now()
+
+	assert.Nil(s.T(), revocation.Validate(), "should return no error")
+}
+
+func TestDeviceRevocationTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceRevocationTestSuite))
+}
@@ -60,6 +60,11 @@ func (s *DeviceTestSuite) TestValidate() {
 	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
 	device.LastUpdateTime = updateTime
 
+	device.HeartbeatTimeout = -1
+	assert.Error(s.T(), device.Validate(), "should error on negative heartbeat timeout")
+	assert.Regexp(s.T(), "heartbeat timeout", device.Validate().Error())
+	device.HeartbeatTimeout = 60
+
 	assert.Nil(s.T(), device.Validate(), "should return no error")
 }
 
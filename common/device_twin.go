@@ -0,0 +1,108 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// DeviceTwin the intended and actual configuration of a device
+type DeviceTwin struct {
+	// OrganizationId identity of the organization to which the device belongs
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the device
+	DeviceId string `json:"deviceId"`
+
+	// Desired properties that the device should apply, written by its organization administrators
+	Desired map[string]string `json:"desired,omitempty"`
+
+	// Reported properties that the device has applied, written by the device itself
+	Reported map[string]string `json:"reported,omitempty"`
+
+	// Version number of updates made to the twin
+	Version int64 `json:"version,omitempty"`
+
+	// LastUpdateTime the latest time that the twin has been updated
+	LastUpdateTime time.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// GetDelta return the desired properties whose values differ from the reported ones
+func (t *DeviceTwin) GetDelta() map[string]string {
+	delta := make(map[string]string)
+	for name, value := range t.Desired {
+		if reported, ok := t.Reported[name]; !ok || reported != value {
+			delta[name] = value
+		}
+	}
+
+	return delta
+}
+
+// ApplyDesired merge the properties into the desired properties, where properties with empty values are removed
+func (t *DeviceTwin) ApplyDesired(properties map[string]string) {
+	t.Desired = mergeTwinProperties(t.Desired, properties)
+}
+
+// ApplyReported merge the properties into the reported properties, where properties with empty values are removed
+func (t *DeviceTwin) ApplyReported(properties map[string]string) {
+	t.Reported = mergeTwinProperties(t.Reported, properties)
+}
+
+// GetKeyComponents return components that compose the device twin key
+func (t *DeviceTwin) GetKeyComponents() []string {
+	return []string{t.OrganizationId, t.DeviceId}
+}
+
+// Serialize transform current device twin to JSON string
+func (t *DeviceTwin) Serialize() ([]byte, error) {
+	return json.Marshal(t)
+}
+
+// Validate check if the device twin properties are valid
+func (t *DeviceTwin) Validate() error {
+	if t.OrganizationId == "" || t.DeviceId == "" {
+		return fmt.Errorf("missing device in device twin definition")
+	}
+	for name := range t.Desired {
+		if name == "" {
+			return fmt.Errorf("missing property name in desired properties")
+		}
+	}
+	for name := range t.Reported {
+		if name == "" {
+			return fmt.Errorf("missing property name in reported properties")
+		}
+	}
+
+	return nil
+}
+
+// DeserializeDeviceTwin create a device twin instance from its JSON representation
+func DeserializeDeviceTwin(data []byte) (*DeviceTwin, error) {
+	twin := new(DeviceTwin)
+
+	if err := json.Unmarshal(data, twin); err != nil {
+		return nil, err
+	}
+
+	return twin, nil
+}
+
+func mergeTwinProperties(properties map[string]string, patch map[string]string) map[string]string {
+	if properties == nil {
+		properties = make(map[string]string)
+	}
+	for name, value := range patch {
+		if value == "" {
+			delete(properties, name)
+		} else {
+			properties[name] = value
+		}
+	}
+	if len(properties) == 0 {
+		return nil
+	}
+
+	return properties
+}
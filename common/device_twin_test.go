@@ -0,0 +1,96 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceTwinTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceTwinTestSuite) TestGetDelta() {
+	twin := &DeviceTwin{
+		Desired:  map[string]string{"interval": "60", "unit": "celsius", "mode": "eco"},
+		Reported: map[string]string{"interval": "30", "unit": "celsius", "firmware": "1.0"},
+	}
+
+	assert.Equal(s.T(), map[string]string{"interval": "60", "mode": "eco"}, twin.GetDelta(), "should return desired properties not yet reported")
+
+	twin.Desired = nil
+	assert.Empty(s.T(), twin.GetDelta(), "should return no delta without desired properties")
+}
+
+func (s *DeviceTwinTestSuite) TestApply() {
+	twin := new(DeviceTwin)
+
+	twin.ApplyDesired(map[string]string{"interval": "60", "unit": "celsius"})
+	assert.Equal(s.T(), map[string]string{"interval": "60", "unit": "celsius"}, twin.Desired, "should add desired properties")
+
+	twin.ApplyDesired(map[string]string{"interval": "30", "unit": ""})
+	assert.Equal(s.T(), map[string]string{"interval": "30"}, twin.Desired, "should update and remove desired properties")
+
+	twin.ApplyDesired(map[string]string{"interval": ""})
+	assert.Nil(s.T(), twin.Desired, "should clear desired properties")
+
+	twin.ApplyReported(map[string]string{"interval": "30"})
+	assert.Equal(s.T(), map[string]string{"interval": "30"}, twin.Reported, "should add reported properties")
+	assert.Nil(s.T(), twin.Desired, "should not change desired properties")
+}
+
+func (s *DeviceTwinTestSuite) TestGetKeyComponents() {
+	twin := &DeviceTwin{OrganizationId: "org1", DeviceId: "device1"}
+	assert.Equal(s.T(), []string{"org1", "device1"}, twin.GetKeyComponents(), "should return organization ID and device ID")
+}
+
+func (s *DeviceTwinTestSuite) TestSerialize() {
+	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	twin := &DeviceTwin{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		Desired:        map[string]string{"interval": "60"},
+		Reported:       map[string]string{"interval": "30"},
+		Version:        2,
+		LastUpdateTime: updateTime,
+	}
+	serialized := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"desired\":{\"interval\":\"60\"},\"reported\":{\"interval\":\"30\"}," +
+		"\"version\":2,\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := twin.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeDeviceTwin(data)
+	assert.Equal(s.T(), twin, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeDeviceTwin([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *DeviceTwinTestSuite) TestValidate() {
+	twin := DeviceTwin{}
+
+	assert.Error(s.T(), twin.Validate(), "should error on empty device")
+	assert.Regexp(s.T(), "missing device", twin.Validate().Error())
+	twin.OrganizationId, twin.DeviceId = "org1", "device1"
+
+	twin.Desired = map[string]string{"": "60"}
+	assert.Error(s.T(), twin.Validate(), "should error on empty desired property name")
+	assert.Regexp(s.T(), "desired properties", twin.Validate().Error())
+	twin.Desired = nil
+
+	twin.Reported = map[string]string{"": "60"}
+	assert.Error(s.T(), twin.Validate(), "should error on empty reported property name")
+	assert.Regexp(s.T(), "reported properties", twin.Validate().Error())
+	twin.Reported = nil
+
+	assert.Nil(s.T(), twin.Validate(), "should return no error")
+}
+
+func TestDeviceTwinTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceTwinTestSuite))
+}
@@ -0,0 +1,220 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// EventEnvelopeVersion the current version of the event envelope
+const EventEnvelopeVersion = 1
+
+// EventEntityType type of the entity changed by an event
+type EventEntityType string
+
+const (
+	// EventEntityDevice the event changes a device
+	EventEntityDevice EventEntityType = "device"
+
+	// EventEntityService the event changes an IoT service
+	EventEntityService EventEntityType = "service"
+
+	// EventEntityRequest the event changes an IoT service request
+	EventEntityRequest EventEntityType = "request"
+
+	// EventEntityAccount the event changes a token account
+	EventEntityAccount EventEntityType = "account"
+
+	// EventEntityGroup the event changes a device group
+	EventEntityGroup EventEntityType = "group"
+
+	// EventEntityWorkflow the event changes a workflow or one of its runs
+	EventEntityWorkflow EventEntityType = "workflow"
+
+	// EventEntityStream the event carries a message published on a stream of an IoT service
+	EventEntityStream EventEntityType = "stream"
+
+	// EventEntityFirmware the event changes a firmware release
+	EventEntityFirmware EventEntityType = "firmware"
+
+	// EventEntityCampaign the event changes a firmware campaign or the progress of one of its updates
+	EventEntityCampaign EventEntityType = "campaign"
+)
+
+// legacyEventNamePatterns patterns of legacy event names. Device IDs are base64-encoded and may contain
+// slashes, so every other component is matched up to the next slash and the device ID takes the rest.
+var legacyEventNamePatterns = map[EventEntityType]*regexp.Regexp{
+	EventEntityDevice:   regexp.MustCompile(`^device:\/\/([^/]+)\/(.+)\/([^/]+)$`),
+	EventEntityService:  regexp.MustCompile(`^service:\/\/([^/]+)\/(.+)\/([^/]+)\/([^/]+)$`),
+	EventEntityRequest:  regexp.MustCompile(`^request:\/\/([^/]+)\/(.+)\/([^/]+)\/([^/]+)\/([^/]+)$`),
+	EventEntityAccount:  regexp.MustCompile(`^account:\/\/([^/]+)\/([^/]+)$`),
+	EventEntityGroup:    regexp.MustCompile(`^group:\/\/([^/]+)\/(.+)\/([^/]+)$`),
+	EventEntityWorkflow: regexp.MustCompile(`^workflow:\/\/([^/]+)\/(.+)\/([^/]+)$`),
+	EventEntityStream:   regexp.MustCompile(`^stream:\/\/([^/]+)\/(.+)\/([^/]+)\/([^/]+)\/([^/]+)$`),
+	EventEntityFirmware: regexp.MustCompile(`^firmware:\/\/([^/]+)\/(.+)\/([^/]+)$`),
+	EventEntityCampaign: regexp.MustCompile(`^campaign:\/\/([^/]+)\/(.+)\/([^/]+)$`),
+}
+
+// Event a change to a device, service, request, or account
+type Event struct {
+	// EntityType type of the changed entity
+	EntityType EventEntityType `json:"entityType"`
+
+	// OrganizationId identity of the organization to which the entity belongs
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the device, or of the device providing the service or request
+	DeviceId string `json:"deviceId,omitempty"`
+
+	// ServiceName name of the service, or of the service of the request
+	ServiceName string `json:"serviceName,omitempty"`
+
+	// RequestId identity of the request
+	RequestId string `json:"requestId,omitempty"`
+
+	// Topic topic of the stream of the service
+	Topic string `json:"topic,omitempty"`
+
+	// GroupName name of the device group
+	GroupName string `json:"groupName,omitempty"`
+
+	// WorkflowName name of the workflow
+	WorkflowName string `json:"workflowName,omitempty"`
+
+	// FirmwareName name of the firmware
+	FirmwareName string `json:"firmwareName,omitempty"`
+
+	// CampaignId identity of the firmware campaign
+	CampaignId string `json:"campaignId,omitempty"`
+
+	// Action name of the action performed on the entity
+	Action string `json:"action"`
+
+	// Payload JSON representation of the entity or of the action details
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// NewEventPayload wrap an event payload as JSON, payloads other than JSON objects and arrays become JSON strings
+func NewEventPayload(data []byte) json.RawMessage {
+	if len(data) > 0 && (data[0] == '{' || data[0] == '[') && json.Valid(data) {
+		return json.RawMessage(data)
+	}
+
+	payload, _ := json.Marshal(string(data))
+	return json.RawMessage(payload)
+}
+
+// GetLegacyPayload return the payload as it is emitted in legacy events, with JSON strings unwrapped
+func (e *Event) GetLegacyPayload() []byte {
+	var text string
+	if err := json.Unmarshal(e.Payload, &text); err == nil {
+		return []byte(text)
+	}
+	return e.Payload
+}
+
+// GetLegacyName return the URL-style name of the event as it is emitted in legacy events
+func (e *Event) GetLegacyName() string {
+	switch e.EntityType {
+	case EventEntityDevice:
+		return fmt.Sprintf("device://%s/%s/%s", e.OrganizationId, e.DeviceId, e.Action)
+	case EventEntityService:
+		return fmt.Sprintf("service://%s/%s/%s/%s", e.OrganizationId, e.DeviceId, e.ServiceName, e.Action)
+	case EventEntityRequest:
+		return fmt.Sprintf("request://%s/%s/%s/%s/%s", e.OrganizationId, e.DeviceId, e.ServiceName, e.RequestId, e.Action)
+	case EventEntityStream:
+		return fmt.Sprintf("stream://%s/%s/%s/%s/%s", e.OrganizationId, e.DeviceId, e.ServiceName, e.Topic, e.Action)
+	case EventEntityGroup:
+		return fmt.Sprintf("group://%s/%s/%s", e.OrganizationId, e.GroupName, e.Action)
+	case EventEntityWorkflow:
+		return fmt.Sprintf("workflow://%s/%s/%s", e.OrganizationId, e.WorkflowName, e.Action)
+	case EventEntityFirmware:
+		return fmt.Sprintf("firmware://%s/%s/%s", e.OrganizationId, e.FirmwareName, e.Action)
+	case EventEntityCampaign:
+		return fmt.Sprintf("campaign://%s/%s/%s", e.OrganizationId, e.CampaignId, e.Action)
+	default:
+		return fmt.Sprintf("%s://%s/%s", e.EntityType, e.OrganizationId, e.Action)
+	}
+}
+
+// ParseLegacyEvent create an event instance from the URL-style name and payload of a legacy event
+func ParseLegacyEvent(name string, payload []byte) (*Event, error) {
+	for entityType, pattern := range legacyEventNamePatterns {
+		matches := pattern.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+
+		event := &Event{EntityType: entityType, OrganizationId: matches[1], Action: matches[len(matches)-1], Payload: NewEventPayload(payload)}
+		switch entityType {
+		case EventEntityDevice:
+			event.DeviceId = matches[2]
+		case EventEntityService:
+			event.DeviceId, event.ServiceName = matches[2], matches[3]
+		case EventEntityRequest:
+			event.DeviceId, event.ServiceName, event.RequestId = matches[2], matches[3], matches[4]
+		case EventEntityStream:
+			event.DeviceId, event.ServiceName, event.Topic = matches[2], matches[3], matches[4]
+		case EventEntityGroup:
+			event.GroupName = matches[2]
+		case EventEntityWorkflow:
+			event.WorkflowName = matches[2]
+		case EventEntityFirmware:
+			event.FirmwareName = matches[2]
+		case EventEntityCampaign:
+			event.CampaignId = matches[2]
+		}
+
+		return event, nil
+	}
+
+	return nil, fmt.Errorf("unknown event %s", name)
+}
+
+// EventEnvelope versioned envelope of all changes made by a transaction
+type EventEnvelope struct {
+	// Version version of the envelope format
+	Version int `json:"version"`
+
+	// TransactionId ID of the transaction that made the changes
+	TransactionId string `json:"transactionId"`
+
+	// Timestamp time at which the transaction was created
+	Timestamp time.Time `json:"timestamp"`
+
+	// ActorOrganizationId organization ID of the client that submitted the transaction
+	ActorOrganizationId string `json:"actorOrganizationId"`
+
+	// ActorId ID of the client that submitted the transaction
+	ActorId string `json:"actorId"`
+
+	// Events changes made by the transaction, starting with the change directly requested by the client
+	Events []*Event `json:"events"`
+}
+
+// GetName return the name of the event carrying the envelope
+func (e *EventEnvelope) GetName() string {
+	return GetEventEnvelopeName(e.Version)
+}
+
+// Serialize transform current envelope to JSON string
+func (e *EventEnvelope) Serialize() ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// GetEventEnvelopeName return the name of the events carrying envelopes of the given version
+func GetEventEnvelopeName(version int) string {
+	return fmt.Sprintf("event://v%d", version)
+}
+
+// DeserializeEventEnvelope create an envelope instance from its JSON representation
+func DeserializeEventEnvelope(data []byte) (*EventEnvelope, error) {
+	envelope := new(EventEnvelope)
+
+	if err := json.Unmarshal(data, envelope); err != nil {
+		return nil, err
+	}
+
+	return envelope, nil
+}
@@ -0,0 +1,135 @@
+package common
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventTestSuite) TestNewEventPayload() {
+	assert.Equal(s.T(), json.RawMessage("{\"id\":\"device1\"}"), NewEventPayload([]byte("{\"id\":\"device1\"}")), "should keep JSON objects")
+	assert.Equal(s.T(), json.RawMessage("[1]"), NewEventPayload([]byte("[1]")), "should keep JSON arrays")
+	assert.Equal(s.T(), json.RawMessage("\"123\""), NewEventPayload([]byte("123")), "should wrap other payloads as JSON strings")
+	assert.Equal(s.T(), json.RawMessage("\"{bad\""), NewEventPayload([]byte("{bad")), "should wrap invalid JSON as JSON strings")
+}
+
+func (s *EventTestSuite) TestGetLegacyPayload() {
+	event := &Event{Payload: NewEventPayload([]byte("request1"))}
+	assert.Equal(s.T(), []byte("request1"), event.GetLegacyPayload(), "should unwrap JSON strings")
+
+	event = &Event{Payload: NewEventPayload([]byte("{}"))}
+	assert.Equal(s.T(), []byte("{}"), event.GetLegacyPayload(), "should keep JSON objects")
+}
+
+func (s *EventTestSuite) TestGetLegacyName() {
+	event := &Event{EntityType: EventEntityDevice, OrganizationId: "org1", DeviceId: "device1", Action: "register"}
+	assert.Equal(s.T(), "device://org1/device1/register", event.GetLegacyName(), "should return device event name")
+
+	event = &Event{EntityType: EventEntityService, OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Action: "register"}
+	assert.Equal(s.T(), "service://org1/device1/service1/register", event.GetLegacyName(), "should return service event name")
+
+	event = &Event{EntityType: EventEntityRequest, OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", RequestId: "request1", Action: "request"}
+	assert.Equal(s.T(), "request://org1/device1/service1/request1/request", event.GetLegacyName(), "should return request event name")
+
+	event = &Event{EntityType: EventEntityAccount, OrganizationId: "org1", Action: "deposit"}
+	assert.Equal(s.T(), "account://org1/deposit", event.GetLegacyName(), "should return account event name")
+
+	event = &Event{EntityType: EventEntityGroup, OrganizationId: "org1", GroupName: "group1", Action: "join"}
+	assert.Equal(s.T(), "group://org1/group1/join", event.GetLegacyName(), "should return group event name")
+
+	event = &Event{EntityType: EventEntityWorkflow, OrganizationId: "org1", WorkflowName: "workflow1", Action: "start"}
+	assert.Equal(s.T(), "workflow://org1/workflow1/start", event.GetLegacyName(), "should return workflow event name")
+
+	event = &Event{EntityType: EventEntityStream, OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Action: "publish"}
+	assert.Equal(s.T(), "stream://org1/device1/service1/temperature/publish", event.GetLegacyName(), "should return stream event name")
+
+	event = &Event{EntityType: EventEntityFirmware, OrganizationId: "org1", FirmwareName: "firmware1", Action: "publish"}
+	assert.Equal(s.T(), "firmware://org1/firmware1/publish", event.GetLegacyName(), "should return firmware event name")
+
+	event = &Event{EntityType: EventEntityCampaign, OrganizationId: "org1", CampaignId: "campaign1", Action: "report"}
+	assert.Equal(s.T(), "campaign://org1/campaign1/report", event.GetLegacyName(), "should return campaign event name")
+}
+
+func (s *EventTestSuite) TestParseLegacyEvent() {
+	event, err := ParseLegacyEvent("device://org1/device1/authorize", []byte("device9"))
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), &Event{EntityType: EventEntityDevice, OrganizationId: "org1", DeviceId: "device1", Action: "authorize", Payload: json.RawMessage("\"device9\"")}, event, "should parse device event")
+
+	event, _ = ParseLegacyEvent("service://org1/device1/service1/register", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityService, OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Action: "register", Payload: json.RawMessage("{}")}, event, "should parse service event")
+
+	event, _ = ParseLegacyEvent("request://org1/device1/service1/request1/remove", []byte("request1"))
+	assert.Equal(s.T(), "request1", event.RequestId, "should parse request event")
+	assert.Equal(s.T(), "remove", event.Action, "should parse request event")
+
+	event, _ = ParseLegacyEvent("device://org1/a/b+c=/register", []byte("{}"))
+	assert.Equal(s.T(), "a/b+c=", event.DeviceId, "should parse device ID containing slashes")
+	assert.Equal(s.T(), "register", event.Action, "should parse device ID containing slashes")
+
+	event, _ = ParseLegacyEvent("request://org1/a/b+c=/service1/request1/respond", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityRequest, OrganizationId: "org1", DeviceId: "a/b+c=", ServiceName: "service1", RequestId: "request1", Action: "respond", Payload: json.RawMessage("{}")}, event, "should parse request event of device ID containing slashes")
+
+	event, _ = ParseLegacyEvent("account://org1/deposit", []byte("{}"))
+	assert.Equal(s.T(), EventEntityAccount, event.EntityType, "should parse account event")
+	assert.Equal(s.T(), "deposit", event.Action, "should parse account event")
+
+	event, _ = ParseLegacyEvent("group://org1/group1/join", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityGroup, OrganizationId: "org1", GroupName: "group1", Action: "join", Payload: json.RawMessage("{}")}, event, "should parse group event")
+
+	event, _ = ParseLegacyEvent("workflow://org1/workflow1/start", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityWorkflow, OrganizationId: "org1", WorkflowName: "workflow1", Action: "start", Payload: json.RawMessage("{}")}, event, "should parse workflow event")
+
+	event, _ = ParseLegacyEvent("stream://org1/device1/service1/temperature/publish", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityStream, OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Action: "publish", Payload: json.RawMessage("{}")}, event, "should parse stream event")
+
+	event, _ = ParseLegacyEvent("firmware://org1/firmware1/publish", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityFirmware, OrganizationId: "org1", FirmwareName: "firmware1", Action: "publish", Payload: json.RawMessage("{}")}, event, "should parse firmware event")
+
+	event, _ = ParseLegacyEvent("campaign://org1/campaign1/report", []byte("{}"))
+	assert.Equal(s.T(), &Event{EntityType: EventEntityCampaign, OrganizationId: "org1", CampaignId: "campaign1", Action: "report", Payload: json.RawMessage("{}")}, event, "should parse campaign event")
+
+	_, err = ParseLegacyEvent("unknown://org1", nil)
+	assert.Error(s.T(), err, "should return unknown event error")
+}
+
+func (s *EventTestSuite) TestSerialize() {
+	timestamp, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	envelope := &EventEnvelope{
+		Version:             EventEnvelopeVersion,
+		TransactionId:       "tx1",
+		Timestamp:           timestamp,
+		ActorOrganizationId: "org1",
+		ActorId:             "device1",
+		Events:              []*Event{{EntityType: EventEntityRequest, OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", RequestId: "request1", Action: "remove", Payload: json.RawMessage("\"request1\"")}},
+	}
+	serialized := "{\"version\":1,\"transactionId\":\"tx1\",\"timestamp\":\"2021-12-12T17:34:00-05:00\",\"actorOrganizationId\":\"org1\",\"actorId\":\"device1\"," +
+		"\"events\":[{\"entityType\":\"request\",\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serviceName\":\"service1\",\"requestId\":\"request1\",\"action\":\"remove\",\"payload\":\"request1\"}]}"
+
+	data, err := envelope.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "event://v1", envelope.GetName(), "should return versioned event name")
+}
+
+func (s *EventTestSuite) TestDeserializeEventEnvelope() {
+	serialized := "{\"version\":1,\"transactionId\":\"tx1\",\"events\":[{\"entityType\":\"device\",\"organizationId\":\"org1\",\"deviceId\":\"a/b+c=\",\"action\":\"register\",\"payload\":{}}]}"
+
+	envelope, err := DeserializeEventEnvelope([]byte(serialized))
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "tx1", envelope.TransactionId, "should return parsed envelope")
+	assert.Equal(s.T(), "a/b+c=", envelope.Events[0].DeviceId, "should keep identifiers containing slashes")
+
+	_, err = DeserializeEventEnvelope([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func TestEventTestSuite(t *testing.T) {
+	suite.Run(t, new(EventTestSuite))
+}
@@ -13,3 +13,33 @@ type NotFoundError struct {
 func (e NotFoundError) Error() string {
 	return fmt.Sprintf("%s not found", e.What)
 }
+
+// AccessDeniedError an error indicates the client is not allowed to perform an operation
+type AccessDeniedError struct {
+	Reason string
+}
+
+// Error get the error message
+func (e AccessDeniedError) Error() string {
+	return fmt.Sprintf("access denied: %s", e.Reason)
+}
+
+// LimitExceededError an error indicates the client has exceeded a rate limit or quota
+type LimitExceededError struct {
+	Limit string
+}
+
+// Error get the error message
+func (e LimitExceededError) Error() string {
+	return fmt.Sprintf("limit exceeded: %s", e.Limit)
+}
+
+// InvalidTimeError an error indicates a time supplied by the client is inconsistent with the ledger
+type InvalidTimeError struct {
+	Reason string
+}
+
+// Error get the error message
+func (e InvalidTimeError) Error() string {
+	return fmt.Sprintf("invalid time: %s", e.Reason)
+}
@@ -0,0 +1,190 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FirmwareUpdateStatus progress of a firmware update on a device
+type FirmwareUpdateStatus string
+
+const (
+	// FirmwareUpdatePending the device has not started the update
+	FirmwareUpdatePending FirmwareUpdateStatus = "pending"
+
+	// FirmwareUpdateDownloading the device is downloading the firmware image
+	FirmwareUpdateDownloading FirmwareUpdateStatus = "downloading"
+
+	// FirmwareUpdateInstalling the device is installing the firmware image
+	FirmwareUpdateInstalling FirmwareUpdateStatus = "installing"
+
+	// FirmwareUpdateSucceeded the device has installed the firmware
+	FirmwareUpdateSucceeded FirmwareUpdateStatus = "succeeded"
+
+	// FirmwareUpdateFailed the device has failed to install the firmware
+	FirmwareUpdateFailed FirmwareUpdateStatus = "failed"
+
+	// FirmwareUpdateCancelled the update has been superseded by a later campaign before the device finished it
+	FirmwareUpdateCancelled FirmwareUpdateStatus = "cancelled"
+)
+
+// firmwareUpdateStages order of the statuses a device goes through while updating
+var firmwareUpdateStages = map[FirmwareUpdateStatus]int{
+	FirmwareUpdatePending:     0,
+	FirmwareUpdateDownloading: 1,
+	FirmwareUpdateInstalling:  2,
+	FirmwareUpdateSucceeded:   3,
+}
+
+// FirmwareCampaign a rollout of a firmware release to devices of an organization
+type FirmwareCampaign struct {
+	// Id identity of the campaign, a UUID
+	Id string `json:"id"`
+
+	// OrganizationId identity of the organization whose devices are updated
+	OrganizationId string `json:"organizationId"`
+
+	// CreatorId identity of the client that created the campaign, maintained by the firmware registry
+	CreatorId string `json:"creatorId,omitempty"`
+
+	// FirmwareOrganizationId identity of the vendor organization of the release
+	FirmwareOrganizationId string `json:"firmwareOrganizationId"`
+
+	// FirmwareName name of the firmware of the release
+	FirmwareName string `json:"firmwareName"`
+
+	// FirmwareVersion version of the release
+	FirmwareVersion string `json:"firmwareVersion"`
+
+	// DeviceIds identities of the targeted devices
+	DeviceIds []string `json:"deviceIds,omitempty"`
+
+	// GroupNames names of the targeted device groups of the organization, whose members of the organization are updated
+	GroupNames []string `json:"groupNames,omitempty"`
+
+	// Targets identities of the devices updated by the campaign, maintained by the firmware registry
+	Targets []string `json:"targets,omitempty"`
+
+	// Time time when the campaign has been created
+	Time time.Time `json:"time"`
+}
+
+// GetKeyComponents return components that compose the firmware campaign key
+func (c *FirmwareCampaign) GetKeyComponents() []string {
+	return []string{c.Id}
+}
+
+// Serialize transform current firmware campaign to JSON string
+func (c *FirmwareCampaign) Serialize() ([]byte, error) {
+	return json.Marshal(c)
+}
+
+// Validate check if the firmware campaign properties are valid
+func (c *FirmwareCampaign) Validate() error {
+	if _, err := uuid.Parse(c.Id); err != nil {
+		return fmt.Errorf("invalid campaign ID in firmware campaign definition")
+	}
+	if c.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in firmware campaign definition")
+	}
+	if c.FirmwareOrganizationId == "" || c.FirmwareName == "" || c.FirmwareVersion == "" {
+		return fmt.Errorf("missing firmware release in firmware campaign definition")
+	}
+	if len(c.DeviceIds) == 0 && len(c.GroupNames) == 0 {
+		return fmt.Errorf("missing target devices or groups in firmware campaign definition")
+	}
+	if c.Time.IsZero() {
+		return fmt.Errorf("missing campaign time in firmware campaign definition")
+	}
+
+	return nil
+}
+
+// DeserializeFirmwareCampaign create a firmware campaign instance from its JSON representation
+func DeserializeFirmwareCampaign(data []byte) (*FirmwareCampaign, error) {
+	campaign := new(FirmwareCampaign)
+
+	if err := json.Unmarshal(data, campaign); err != nil {
+		return nil, err
+	}
+
+	return campaign, nil
+}
+
+// FirmwareUpdate the progress of a firmware campaign on one of its target devices
+type FirmwareUpdate struct {
+	// OrganizationId identity of the organization of the device
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the device
+	DeviceId string `json:"deviceId"`
+
+	// CampaignId identity of the campaign
+	CampaignId string `json:"campaignId"`
+
+	// FirmwareOrganizationId identity of the vendor organization of the release to install
+	FirmwareOrganizationId string `json:"firmwareOrganizationId"`
+
+	// FirmwareName name of the firmware of the release to install
+	FirmwareName string `json:"firmwareName"`
+
+	// FirmwareVersion version of the release to install
+	FirmwareVersion string `json:"firmwareVersion"`
+
+	// Status progress of the update
+	Status FirmwareUpdateStatus `json:"status"`
+
+	// Message details reported by the device, such as the cause of a failure
+	Message string `json:"message,omitempty"`
+
+	// LastUpdateTime the latest time that the status has changed
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+}
+
+// IsFinished check if the update has reached a final status
+func (u *FirmwareUpdate) IsFinished() bool {
+	return u.Status == FirmwareUpdateSucceeded || u.Status == FirmwareUpdateFailed || u.Status == FirmwareUpdateCancelled
+}
+
+// CanTransition check if the update can move to the status, which is only allowed forward through the stages or
+// to failure before the update finishes
+func (u *FirmwareUpdate) CanTransition(status FirmwareUpdateStatus) bool {
+	if u.IsFinished() {
+		return false
+	}
+	if status == FirmwareUpdateFailed {
+		return true
+	}
+
+	stage, ok := firmwareUpdateStages[status]
+	return ok && stage > firmwareUpdateStages[u.Status]
+}
+
+// GetKeyComponents return components that compose the firmware update key
+func (u *FirmwareUpdate) GetKeyComponents() []string {
+	return []string{u.OrganizationId, u.DeviceId, u.CampaignId}
+}
+
+// Serialize transform current firmware update to JSON string
+func (u *FirmwareUpdate) Serialize() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+// Validate check if the firmware update properties are valid
+func (u *FirmwareUpdate) Validate() error {
+	return nil
+}
+
+// DeserializeFirmwareUpdate create a firmware update instance from its JSON representation
+func DeserializeFirmwareUpdate(data []byte) (*FirmwareUpdate, error) {
+	update := new(FirmwareUpdate)
+
+	if err := json.Unmarshal(data, update); err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FirmwareCampaignTestSuite struct {
+	suite.Suite
+}
+
+func (s *FirmwareCampaignTestSuite) TestGetKeyComponents() {
+	campaign := &FirmwareCampaign{Id: "campaign1"}
+	assert.Equal(s.T(), []string{"campaign1"}, campaign.GetKeyComponents(), "should return correct key components")
+
+	update := &FirmwareUpdate{OrganizationId: "org1", DeviceId: "device1", CampaignId: "campaign1"}
+	assert.Equal(s.T(), []string{"org1", "device1", "campaign1"}, update.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *FirmwareCampaignTestSuite) TestSerialize() {
+	campaignTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	campaign := &FirmwareCampaign{
+		Id:                     "campaign1",
+		OrganizationId:         "org1",
+		FirmwareOrganizationId: "vendor1",
+		FirmwareName:           "firmware1",
+		FirmwareVersion:        "1.2.0",
+		GroupNames:             []string{"group1"},
+		Targets:                []string{"device1"},
+		Time:                   campaignTime,
+	}
+	serialized := "{\"id\":\"campaign1\",\"organizationId\":\"org1\",\"firmwareOrganizationId\":\"vendor1\",\"firmwareName\":\"firmware1\"," +
+		"\"firmwareVersion\":\"1.2.0\",\"groupNames\":[\"group1\"],\"targets\":[\"device1\"],\"time\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := campaign.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeFirmwareCampaign(data)
+	assert.Equal(s.T(), campaign, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeFirmwareCampaign([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+
+	update := &FirmwareUpdate{OrganizationId: "org1", DeviceId: "device1", CampaignId: "campaign1", Status: FirmwareUpdateFailed, Message: "out of space", LastUpdateTime: campaignTime}
+	data, _ = update.Serialize()
+	deserializedUpdate, err := DeserializeFirmwareUpdate(data)
+	assert.Equal(s.T(), update, deserializedUpdate, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeFirmwareUpdate([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *FirmwareCampaignTestSuite) TestValidate() {
+	campaign := FirmwareCampaign{Id: "campaign1"}
+
+	assert.Error(s.T(), campaign.Validate(), "should error on invalid campaign ID")
+	assert.Regexp(s.T(), "campaign ID", campaign.Validate().Error())
+	campaign.Id = "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1"
+
+	assert.Error(s.T(), campaign.Validate(), "should error on empty organization ID")
+	assert.Regexp(s.T(), "organization ID", campaign.Validate().Error())
+	campaign.OrganizationId = "org1"
+
+	assert.Error(s.T(), campaign.Validate(), "should error on empty firmware release")
+	assert.Regexp(s.T(), "firmware release", campaign.Validate().Error())
+	campaign.FirmwareOrganizationId, campaign.FirmwareName, campaign.FirmwareVersion = "vendor1", "firmware1", "1.2.0"
+
+	assert.Error(s.T(), campaign.Validate(), "should error on empty targets")
+	assert.Regexp(s.T(), "target", campaign.Validate().Error())
+	campaign.DeviceIds = []string{"device1"}
+
+	assert.Error(s.T(), campaign.Validate(), "should error on empty campaign time")
+	assert.Regexp(s.T(), "campaign time", campaign.Validate().Error())
+	campaign.Time = time.Now()
+
+	assert.Nil(s.T(), campaign.Validate(), "should return no error")
+}
+
+func (s *FirmwareCampaignTestSuite) TestCanTransition() {
+	update := &FirmwareUpdate{Status: FirmwareUpdatePending}
+
+	assert.True(s.T(), update.CanTransition(FirmwareUpdateDownloading), "should move forward")
+	assert.True(s.T(), update.CanTransition(FirmwareUpdateInstalling), "should skip stages forward")
+	assert.True(s.T(), update.CanTransition(FirmwareUpdateFailed), "should fail before finishing")
+	assert.False(s.T(), update.CanTransition(FirmwareUpdatePending), "should not stay in the same stage")
+	assert.False(s.T(), update.CanTransition(FirmwareUpdateCancelled), "should not be cancelled by the device")
+	assert.False(s.T(), update.CanTransition("unknown"), "should not move to unknown status")
+
+	update.Status = FirmwareUpdateInstalling
+	assert.False(s.T(), update.CanTransition(FirmwareUpdateDownloading), "should not move backward")
+	assert.True(s.T(), update.CanTransition(FirmwareUpdateSucceeded), "should succeed")
+
+	for _, status := range []FirmwareUpdateStatus{FirmwareUpdateSucceeded, FirmwareUpdateFailed, FirmwareUpdateCancelled} {
+		update.Status = status
+		assert.True(s.T(), update.IsFinished(), "should be finished")
+		assert.False(s.T(), update.CanTransition(FirmwareUpdateFailed), "should not change finished update")
+	}
+}
+
+func TestFirmwareCampaignTestSuite(t *testing.T) {
+	suite.Run(t, new(FirmwareCampaignTestSuite))
+}
@@ -0,0 +1,178 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// FirmwareRelease a firmware release manifest published by a device vendor
+type FirmwareRelease struct {
+	// OrganizationId identity of the vendor organization publishing the firmware
+	OrganizationId string `json:"organizationId"`
+
+	// Name name of the firmware, unique within its organization
+	Name string `json:"name"`
+
+	// Version version of the release, unique within its firmware
+	Version string `json:"version"`
+
+	// Hash hex-encoded SHA-256 hash of the firmware image
+	Hash string `json:"hash"`
+
+	// Models device models that can install the release
+	Models []string `json:"models"`
+
+	// Location URL from which devices download the firmware image
+	Location string `json:"location"`
+
+	// PublisherId identity of the client that published the release, maintained by the firmware registry
+	PublisherId string `json:"publisherId,omitempty"`
+
+	// Certificate PEM-formated X509 certificate of the publisher, maintained by the firmware registry
+	Certificate string `json:"certificate,omitempty"`
+
+	// Signature base64-encoded signature of the manifest digest by the publisher
+	Signature string `json:"signature"`
+
+	// PublishTime time when the release has been published
+	PublishTime time.Time `json:"publishTime"`
+}
+
+// GetDigest return the SHA-256 digest of the manifest fields signed by the publisher
+func (r *FirmwareRelease) GetDigest() []byte {
+	manifest := &FirmwareRelease{
+		OrganizationId: r.OrganizationId,
+		Name:           r.Name,
+		Version:        r.Version,
+		Hash:           r.Hash,
+		Models:         r.Models,
+		Location:       r.Location,
+	}
+	data, _ := json.Marshal(manifest)
+	digest := sha256.Sum256(data)
+
+	return digest[:]
+}
+
+// Sign sign the manifest digest with the signing function of the publisher, such as the one of the Fabric gateway
+func (r *FirmwareRelease) Sign(sign func(digest []byte) ([]byte, error)) error {
+	signature, err := sign(r.GetDigest())
+	if err != nil {
+		return err
+	}
+
+	r.Signature = base64.StdEncoding.EncodeToString(signature)
+	return nil
+}
+
+// Verify check the signature of the manifest against the certificate of the publisher
+func (r *FirmwareRelease) Verify() error {
+	cert, err := ParseCertificate([]byte(r.Certificate))
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(r.Signature)
+	if err != nil {
+		return err
+	}
+
+	valid := false
+	switch key := cert.PublicKey.(type) {
+	case *ecdsa.PublicKey:
+		valid = ecdsa.VerifyASN1(key, r.GetDigest(), signature)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(key, r.GetDigest(), signature)
+	default:
+		return fmt.Errorf("unsupported publisher key type %T", key)
+	}
+	if !valid {
+		return fmt.Errorf("invalid signature of firmware release manifest")
+	}
+
+	return nil
+}
+
+// VerifyImage check if the firmware image matches the hash of the release
+func (r *FirmwareRelease) VerifyImage(image []byte) error {
+	hash := sha256.Sum256(image)
+	if !strings.EqualFold(hex.EncodeToString(hash[:]), r.Hash) {
+		return fmt.Errorf("firmware image does not match the release hash")
+	}
+
+	return nil
+}
+
+// SupportsModel check if a device model can install the release
+func (r *FirmwareRelease) SupportsModel(model string) bool {
+	for _, model_ := range r.Models {
+		if model_ == model {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetKeyComponents return components that compose the firmware release key
+func (r *FirmwareRelease) GetKeyComponents() []string {
+	return []string{r.OrganizationId, r.Name, r.Version}
+}
+
+// Serialize transform current firmware release to JSON string
+func (r *FirmwareRelease) Serialize() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Validate check if the firmware release properties are valid
+func (r *FirmwareRelease) Validate() error {
+	if r.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in firmware release definition")
+	}
+	if r.Name == "" {
+		return fmt.Errorf("missing firmware name in firmware release definition")
+	}
+	if r.Version == "" {
+		return fmt.Errorf("missing version in firmware release definition")
+	}
+	if hash, err := hex.DecodeString(r.Hash); err != nil || len(hash) != sha256.Size {
+		return fmt.Errorf("invalid SHA-256 hash in firmware release definition")
+	}
+	if len(r.Models) == 0 {
+		return fmt.Errorf("missing target device models in firmware release definition")
+	}
+	for _, model := range r.Models {
+		if model == "" {
+			return fmt.Errorf("empty target device model in firmware release definition")
+		}
+	}
+	if r.Location == "" {
+		return fmt.Errorf("missing image location in firmware release definition")
+	}
+	if r.Signature == "" {
+		return fmt.Errorf("missing signature in firmware release definition")
+	}
+	if r.PublishTime.IsZero() {
+		return fmt.Errorf("missing publish time in firmware release definition")
+	}
+
+	return nil
+}
+
+// DeserializeFirmwareRelease create a firmware release instance from its JSON representation
+func DeserializeFirmwareRelease(data []byte) (*FirmwareRelease, error) {
+	release := new(FirmwareRelease)
+
+	if err := json.Unmarshal(data, release); err != nil {
+		return nil, err
+	}
+
+	return release, nil
+}
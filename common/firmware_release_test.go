@@ -0,0 +1,150 @@
+package common
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FirmwareReleaseTestSuite struct {
+	suite.Suite
+}
+
+func newTestFirmwareRelease() *FirmwareRelease {
+	return &FirmwareRelease{
+		OrganizationId: "vendor1",
+		Name:           "firmware1",
+		Version:        "1.2.0",
+		Hash:           "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Models:         []string{"model1", "model2"},
+		Location:       "https://example.com/firmware1-1.2.0.bin",
+	}
+}
+
+func (s *FirmwareReleaseTestSuite) TestSignAndVerify() {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "vendor1-admin"}}
+	data, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	release := newTestFirmwareRelease()
+	release.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: data}))
+	err := release.Sign(func(digest []byte) ([]byte, error) {
+		return ecdsa.SignASN1(rand.Reader, key, digest)
+	})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.NotEmpty(s.T(), release.Signature, "should record the signature")
+	assert.Nil(s.T(), release.Verify(), "should verify the signature")
+
+	release.PublisherId, release.PublishTime = "admin1", time.Now()
+	assert.Nil(s.T(), release.Verify(), "should not sign the fields maintained by the firmware registry")
+
+	release.Models = append(release.Models, "model3")
+	assert.Error(s.T(), release.Verify(), "should refuse tampered manifest")
+
+	release.Certificate = ""
+	assert.Error(s.T(), release.Verify(), "should refuse manifest without certificate")
+}
+
+func (s *FirmwareReleaseTestSuite) TestVerifyImage() {
+	release := newTestFirmwareRelease()
+
+	assert.Nil(s.T(), release.VerifyImage([]byte("test")), "should accept image matching the hash")
+	assert.Error(s.T(), release.VerifyImage([]byte("test2")), "should refuse image not matching the hash")
+}
+
+func (s *FirmwareReleaseTestSuite) TestSupportsModel() {
+	release := newTestFirmwareRelease()
+
+	assert.True(s.T(), release.SupportsModel("model2"), "should support target models")
+	assert.False(s.T(), release.SupportsModel("model3"), "should not support other models")
+	assert.False(s.T(), release.SupportsModel(""), "should not support devices without model")
+}
+
+func (s *FirmwareReleaseTestSuite) TestGetKeyComponents() {
+	release := newTestFirmwareRelease()
+	assert.Equal(s.T(), []string{"vendor1", "firmware1", "1.2.0"}, release.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *FirmwareReleaseTestSuite) TestSerialize() {
+	publishTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	release := &FirmwareRelease{
+		OrganizationId: "vendor1",
+		Name:           "firmware1",
+		Version:        "1.2.0",
+		Hash:           "00",
+		Models:         []string{"model1"},
+		Location:       "https://example.com/firmware1.bin",
+		PublisherId:    "admin1",
+		Signature:      "c2lnbmF0dXJl",
+		PublishTime:    publishTime,
+	}
+	serialized := "{\"organizationId\":\"vendor1\",\"name\":\"firmware1\",\"version\":\"1.2.0\",\"hash\":\"00\",\"models\":[\"model1\"]," +
+		"\"location\":\"https://example.com/firmware1.bin\",\"publisherId\":\"admin1\",\"signature\":\"c2lnbmF0dXJl\"," +
+		"\"publishTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := release.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeFirmwareRelease(data)
+	assert.Equal(s.T(), release, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeFirmwareRelease([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *FirmwareReleaseTestSuite) TestValidate() {
+	release := FirmwareRelease{}
+
+	assert.Error(s.T(), release.Validate(), "should error on empty organization ID")
+	assert.Regexp(s.T(), "organization ID", release.Validate().Error())
+	release.OrganizationId = "vendor1"
+
+	assert.Error(s.T(), release.Validate(), "should error on empty firmware name")
+	assert.Regexp(s.T(), "firmware name", release.Validate().Error())
+	release.Name = "firmware1"
+
+	assert.Error(s.T(), release.Validate(), "should error on empty version")
+	assert.Regexp(s.T(), "version", release.Validate().Error())
+	release.Version = "1.2.0"
+
+	release.Hash = "9f86d081"
+	assert.Error(s.T(), release.Validate(), "should error on invalid hash")
+	assert.Regexp(s.T(), "hash", release.Validate().Error())
+	release.Hash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+	assert.Error(s.T(), release.Validate(), "should error on empty target models")
+	assert.Regexp(s.T(), "models", release.Validate().Error())
+	release.Models = []string{""}
+	assert.Error(s.T(), release.Validate(), "should error on empty target model")
+	assert.Regexp(s.T(), "model", release.Validate().Error())
+	release.Models = []string{"model1"}
+
+	assert.Error(s.T(), release.Validate(), "should error on empty location")
+	assert.Regexp(s.T(), "location", release.Validate().Error())
+	release.Location = "https://example.com/firmware1.bin"
+
+	assert.Error(s.T(), release.Validate(), "should error on empty signature")
+	assert.Regexp(s.T(), "signature", release.Validate().Error())
+	release.Signature = "c2lnbmF0dXJl"
+
+	assert.Error(s.T(), release.Validate(), "should error on empty publish time")
+	assert.Regexp(s.T(), "publish time", release.Validate().Error())
+	release.PublishTime = time.Now()
+
+	assert.Nil(s.T(), release.Validate(), "should return no error")
+}
+
+func TestFirmwareReleaseTestSuite(t *testing.T) {
+	suite.Run(t, new(FirmwareReleaseTestSuite))
+}
@@ -0,0 +1,151 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// GroupServiceRequest an IoT service request addressed to every member of a device group
+type GroupServiceRequest struct {
+	// Id identity of the group request
+	Id string `json:"id"`
+
+	// Time time of the group request
+	Time time.Time `json:"time"`
+
+	// GroupOrganizationId identity of the organization to which the requested device group belongs
+	GroupOrganizationId string `json:"groupOrganizationId"`
+
+	// GroupName name of the requested device group
+	GroupName string `json:"groupName"`
+
+	// ServiceName name of the IoT service requested from every member device
+	ServiceName string `json:"serviceName"`
+
+	// Method IoT service request method
+	Method string `json:"method"`
+
+	// Arguments IoT service request arguments
+	Arguments []string `json:"arguments"`
+
+	// Timeout number of seconds after the request time when the member requests expire, never expire if zero
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// RequesterOrganizationId identity of the organization of the client that made the request
+	RequesterOrganizationId string `json:"requesterOrganizationId,omitempty"`
+
+	// RequesterId identity of the client that made the request
+	RequesterId string `json:"requesterId,omitempty"`
+
+	// RequestIds identities of the requests made to the member devices, maintained by the service broker
+	RequestIds []string `json:"requestIds,omitempty"`
+
+	// Failures reasons why requests could not be made to member devices, keyed by the organization ID and device ID
+	// of the member joined by a slash, maintained by the service broker
+	Failures map[string]string `json:"failures,omitempty"`
+}
+
+// NewRequest create the request made to a member device of the group, whose ID is derived from the group request ID
+func (r *GroupServiceRequest) NewRequest(member *DeviceGroupMember) *ServiceRequest {
+	id := uuid.NewSHA1(uuid.MustParse(r.Id), []byte(member.OrganizationId+"\x00"+member.DeviceId))
+
+	return &ServiceRequest{
+		Id:   id.String(),
+		Time: r.Time,
+		Service: Service{
+			OrganizationId: member.OrganizationId,
+			DeviceId:       member.DeviceId,
+			Name:           r.ServiceName,
+		},
+		Method:                  r.Method,
+		Arguments:               r.Arguments,
+		RequesterOrganizationId: r.RequesterOrganizationId,
+		RequesterId:             r.RequesterId,
+		Timeout:                 r.Timeout,
+		GroupRequestId:          r.Id,
+	}
+}
+
+// GetKeyComponents return components that compose the group request key
+func (r *GroupServiceRequest) GetKeyComponents() []string {
+	return []string{r.Id}
+}
+
+// Serialize transform current group request to JSON string
+func (r *GroupServiceRequest) Serialize() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Validate check if the group request properties are valid
+func (r *GroupServiceRequest) Validate() error {
+	if _, err := uuid.Parse(r.Id); err != nil {
+		return fmt.Errorf("invalid request ID in group request definition")
+	}
+	if r.GroupOrganizationId == "" || r.GroupName == "" {
+		return fmt.Errorf("missing requested device group in group request definition")
+	}
+	if r.ServiceName == "" {
+		return fmt.Errorf("missing requested service in group request definition")
+	}
+	if r.Method == "" {
+		return fmt.Errorf("missing request method in group request definition")
+	}
+	if r.Arguments == nil {
+		return fmt.Errorf("request arguments cannot be null in group request definition")
+	}
+	if r.Time.IsZero() {
+		return fmt.Errorf("missing request time in group request definition")
+	}
+	if r.Timeout < 0 {
+		return fmt.Errorf("request timeout cannot be negative in group request definition")
+	}
+
+	return nil
+}
+
+// DeserializeGroupServiceRequest create a group request instance from its JSON representation
+func DeserializeGroupServiceRequest(data []byte) (*GroupServiceRequest, error) {
+	request := new(GroupServiceRequest)
+
+	if err := json.Unmarshal(data, request); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// GroupServiceRequestResult a group request with the requests and responses of its member devices
+type GroupServiceRequestResult struct {
+	// Request the group request
+	Request *GroupServiceRequest `json:"request"`
+
+	// Responses requests made to the member devices and their responses if any
+	Responses []*ServiceRequestResponse `json:"responses"`
+
+	// Progress number of member requests in each status
+	Progress map[ServiceRequestStatus]int `json:"progress"`
+}
+
+// IsFinished check if every member request has reached a final status
+func (r *GroupServiceRequestResult) IsFinished() bool {
+	for status, count := range r.Progress {
+		if count > 0 && !status.IsFinal() {
+			return false
+		}
+	}
+	return true
+}
+
+// DeserializeGroupServiceRequestResult create a group request result instance from its JSON representation
+func DeserializeGroupServiceRequestResult(data []byte) (*GroupServiceRequestResult, error) {
+	result := new(GroupServiceRequestResult)
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type GroupServiceRequestTestSuite struct {
+	suite.Suite
+}
+
+func (s *GroupServiceRequestTestSuite) TestGetKeyComponents() {
+	request := &GroupServiceRequest{Id: "ffbc9005-c62a-4563-a8f7-b32bba27d707"}
+	assert.Equal(s.T(), []string{request.Id}, request.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *GroupServiceRequestTestSuite) TestNewRequest() {
+	requestTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	request := &GroupServiceRequest{
+		Id:                      "ffbc9005-c62a-4563-a8f7-b32bba27d707",
+		Time:                    requestTime,
+		GroupOrganizationId:     "org1",
+		GroupName:               "group1",
+		ServiceName:             "service1",
+		Method:                  "GET",
+		Arguments:               []string{"1"},
+		Timeout:                 60,
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+
+	member1 := request.NewRequest(&DeviceGroupMember{OrganizationId: "org1", DeviceId: "device1"})
+	assert.Nil(s.T(), member1.Validate(), "should create a valid request")
+	assert.Equal(s.T(), Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"}, member1.Service, "should address the member service")
+	assert.Equal(s.T(), request.Id, member1.GroupRequestId, "should link to the group request")
+	assert.Equal(s.T(), "device2", member1.RequesterId, "should keep the requester")
+	assert.Equal(s.T(), int64(60), member1.Timeout, "should keep the timeout")
+
+	again := request.NewRequest(&DeviceGroupMember{OrganizationId: "org1", DeviceId: "device1"})
+	assert.Equal(s.T(), member1.Id, again.Id, "should derive the same ID for the same member")
+
+	member2 := request.NewRequest(&DeviceGroupMember{OrganizationId: "org1", DeviceId: "device2"})
+	assert.NotEqual(s.T(), member1.Id, member2.Id, "should derive different IDs for different members")
+}
+
+func (s *GroupServiceRequestTestSuite) TestValidate() {
+	request := GroupServiceRequest{}
+
+	assert.Error(s.T(), request.Validate(), "should error on invalid ID")
+	assert.Regexp(s.T(), "request ID", request.Validate().Error())
+	request.Id = "ffbc9005-c62a-4563-a8f7-b32bba27d707"
+
+	assert.Error(s.T(), request.Validate(), "should error on empty group")
+	assert.Regexp(s.T(), "device group", request.Validate().Error())
+	request.GroupOrganizationId, request.GroupName = "org1", "group1"
+
+	assert.Error(s.T(), request.Validate(), "should error on empty service")
+	assert.Regexp(s.T(), "service", request.Validate().Error())
+	request.ServiceName = "service1"
+
+	assert.Error(s.T(), request.Validate(), "should error on empty method")
+	assert.Regexp(s.T(), "method", request.Validate().Error())
+	request.Method = "GET"
+
+	assert.Error(s.T(), request.Validate(), "should error on null arguments")
+	assert.Regexp(s.T(), "arguments", request.Validate().Error())
+	request.Arguments = []string{}
+
+	assert.Error(s.T(), request.Validate(), "should error on empty time")
+	assert.Regexp(s.T(), "time", request.Validate().Error())
+	request.Time = time.Now()
+
+	request.Timeout = -1
+	assert.Error(s.T(), request.Validate(), "should error on negative timeout")
+	assert.Regexp(s.T(), "timeout", request.Validate().Error())
+	request.Timeout = 0
+
+	assert.Nil(s.T(), request.Validate(), "should return no error")
+}
+
+func (s *GroupServiceRequestTestSuite) TestDeserializeGroupServiceRequest() {
+	expected := &GroupServiceRequest{Id: "ffbc9005-c62a-4563-a8f7-b32bba27d707", GroupOrganizationId: "org1", GroupName: "group1", RequestIds: []string{"request1"}}
+
+	actual, err := DeserializeGroupServiceRequest([]byte("{\"id\":\"ffbc9005-c62a-4563-a8f7-b32bba27d707\",\"time\":\"0001-01-01T00:00:00Z\",\"groupOrganizationId\":\"org1\",\"groupName\":\"group1\",\"requestIds\":[\"request1\"]}"))
+	assert.Equal(s.T(), expected, actual, "should return parsed group request")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeGroupServiceRequest([]byte{0x00})
+	assert.Error(s.T(), err, "should return error")
+}
+
+func (s *GroupServiceRequestTestSuite) TestIsFinished() {
+	result := &GroupServiceRequestResult{Progress: map[ServiceRequestStatus]int{ServiceRequestCompleted: 1, ServiceRequestPending: 1}}
+	assert.False(s.T(), result.IsFinished(), "should not be finished with pending requests")
+
+	result.Progress[ServiceRequestPending] = 0
+	result.Progress[ServiceRequestRejected] = 1
+	assert.True(s.T(), result.IsFinished(), "should be finished when every request is final")
+
+	_, err := DeserializeGroupServiceRequestResult([]byte{0x00})
+	assert.Error(s.T(), err, "should return error")
+}
+
+func TestGroupServiceRequestTestSuite(t *testing.T) {
+	suite.Run(t, new(GroupServiceRequestTestSuite))
+}
@@ -119,3 +119,8 @@ func GetClientId(cert *x509.Certificate) (string, error) {
 	id := fmt.Sprintf("x509::%s::%s", formatDN(cert.Subject.ToRDNSequence()), formatDN(cert.Issuer.ToRDNSequence()))
 	return base64.StdEncoding.EncodeToString([]byte(id)), nil
 }
+
+// GetSubjectDN return the distinguished name of the certificate subject in the format used by client IDs
+func GetSubjectDN(cert *x509.Certificate) string {
+	return formatDN(cert.Subject.ToRDNSequence())
+}
@@ -122,6 +122,11 @@ func (s *IdentityTestSuite) TestGetClientId() {
 	assert.Nil(s.T(), err, "should return no error if certificate is valid")
 }
 
+func (s *IdentityTestSuite) TestGetSubjectDN() {
+	cert, _ := ParseCertificate([]byte(CERTIFICATE1))
+	assert.Equal(s.T(), "CN=user1,C=US,ST=North Carolina,O=Hyperledger,OU=client", GetSubjectDN(cert), "should return correct subject")
+}
+
 func TestIdentityTestSuite(t *testing.T) {
 	suite.Run(t, new(IdentityTestSuite))
 }
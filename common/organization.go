@@ -0,0 +1,77 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// OrganizationSummary an organization that has registered devices and the number of its devices and services
+type OrganizationSummary struct {
+	// OrganizationId identity of the organization
+	OrganizationId string `json:"organizationId"`
+
+	// Devices number of devices registered by the organization
+	Devices int `json:"devices"`
+
+	// Services number of services registered by devices of the organization
+	Services int `json:"services"`
+}
+
+// GetKeyComponents return components that compose the organization summary key
+func (o *OrganizationSummary) GetKeyComponents() []string {
+	return []string{o.OrganizationId}
+}
+
+// Serialize transform current organization summary to JSON string
+func (o *OrganizationSummary) Serialize() ([]byte, error) {
+	return json.Marshal(o)
+}
+
+// Validate check if the organization summary properties are valid
+func (o *OrganizationSummary) Validate() error {
+	if o.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in organization summary")
+	}
+
+	if o.Devices < 0 || o.Services < 0 {
+		return fmt.Errorf("number of devices and services cannot be negative")
+	}
+
+	return nil
+}
+
+// DeserializeOrganizationSummary create an organization summary instance from its JSON representation
+func DeserializeOrganizationSummary(data []byte) (*OrganizationSummary, error) {
+	summary := new(OrganizationSummary)
+
+	if err := json.Unmarshal(data, summary); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// DevicePage a page of devices across organizations
+type DevicePage struct {
+	// Devices devices in the page
+	Devices []*Device `json:"devices"`
+
+	// Bookmark position from which to list the next page, there are no more devices if it is empty
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// Serialize transform current device page to JSON string
+func (p *DevicePage) Serialize() ([]byte, error) {
+	return json.Marshal(p)
+}
+
+// DeserializeDevicePage create a device page instance from its JSON representation
+func DeserializeDevicePage(data []byte) (*DevicePage, error) {
+	page := new(DevicePage)
+
+	if err := json.Unmarshal(data, page); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,67 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type OrganizationTestSuite struct {
+	suite.Suite
+}
+
+func (s *OrganizationTestSuite) TestGetKeyComponents() {
+	summary := &OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3}
+	assert.Equal(s.T(), []string{"org1"}, summary.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *OrganizationTestSuite) TestSerialize() {
+	summary := &OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3}
+	serialized := "{\"organizationId\":\"org1\",\"devices\":2,\"services\":3}"
+
+	data, err := summary.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *OrganizationTestSuite) TestValidate() {
+	summary := &OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3}
+	assert.Nil(s.T(), summary.Validate(), "should return no error")
+
+	summary.OrganizationId = ""
+	assert.Error(s.T(), summary.Validate(), "should return organization ID error")
+
+	summary.OrganizationId = "org1"
+	summary.Services = -1
+	assert.Error(s.T(), summary.Validate(), "should return negative count error")
+}
+
+func (s *OrganizationTestSuite) TestDeserializeOrganizationSummary() {
+	expected := &OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3}
+	serialized := "{\"organizationId\":\"org1\",\"devices\":2,\"services\":3}"
+
+	actual, err := DeserializeOrganizationSummary([]byte(serialized))
+	assert.Equal(s.T(), expected, actual, "should return parsed organization summary")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeOrganizationSummary([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *OrganizationTestSuite) TestDeserializeDevicePage() {
+	expected := &DevicePage{Devices: []*Device{{Id: "device1", OrganizationId: "org1"}}, Bookmark: "bookmark1"}
+
+	data, _ := expected.Serialize()
+	actual, err := DeserializeDevicePage(data)
+	assert.Equal(s.T(), expected.Bookmark, actual.Bookmark, "should return parsed device page")
+	assert.Equal(s.T(), "device1", actual.Devices[0].Id, "should return parsed device page")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeDevicePage([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func TestOrganizationTestSuite(t *testing.T) {
+	suite.Run(t, new(OrganizationTestSuite))
+}
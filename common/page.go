@@ -0,0 +1,29 @@
+package common
+
+import (
+	"fmt"
+)
+
+const (
+	// DefaultPageSize number of states returned in a page if the page size is not specified
+	DefaultPageSize = 50
+
+	// MaxPageSize maximum number of states returned in a page
+	MaxPageSize = 500
+)
+
+// GetPageSize return the effective page size, DefaultPageSize if it is zero
+func GetPageSize(pageSize int) int {
+	if pageSize == 0 {
+		return DefaultPageSize
+	}
+	return pageSize
+}
+
+// ValidatePageSize check if the page size is within the allowed range
+func ValidatePageSize(pageSize int) error {
+	if pageSize < 0 || pageSize > MaxPageSize {
+		return fmt.Errorf("page size must be between 0 and %d", MaxPageSize)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type PageTestSuite struct {
+	suite.Suite
+}
+
+func (s *PageTestSuite) TestGetPageSize() {
+	assert.Equal(s.T(), DefaultPageSize, GetPageSize(0), "should return default page size")
+	assert.Equal(s.T(), 10, GetPageSize(10), "should return the given page size")
+}
+
+func (s *PageTestSuite) TestValidatePageSize() {
+	assert.Error(s.T(), ValidatePageSize(-1), "should error on negative page size")
+	assert.Error(s.T(), ValidatePageSize(MaxPageSize+1), "should error on too large page size")
+	assert.Nil(s.T(), ValidatePageSize(MaxPageSize), "should return no error")
+}
+
+func TestPageTestSuite(t *testing.T) {
+	suite.Run(t, new(PageTestSuite))
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// SchemaVersion version of the ledger state schema and progress of the migration to the next version
+type SchemaVersion struct {
+	// Version schema version of the ledger state, up to which all migrations have been applied
+	Version int `json:"version"`
+
+	// LatestVersion schema version expected by the deployed chaincode
+	LatestVersion int `json:"latestVersion"`
+
+	// Bookmark position after the last state migrated to the next version, empty if the migration has not started
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// IsMigrated check if all migrations of the deployed chaincode have been applied to the ledger state
+func (v *SchemaVersion) IsMigrated() bool {
+	return v.Version >= v.LatestVersion
+}
+
+// GetKeyComponents return components that compose the schema version key
+func (v *SchemaVersion) GetKeyComponents() []string {
+	return []string{"schema"}
+}
+
+// Serialize transform current schema version to JSON string
+func (v *SchemaVersion) Serialize() ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Validate check if the schema version properties are valid
+func (v *SchemaVersion) Validate() error {
+	if v.Version < 0 || v.LatestVersion < 0 {
+		return fmt.Errorf("schema version cannot be negative")
+	}
+
+	return nil
+}
+
+// DeserializeSchemaVersion create a schema version instance from its JSON representation
+func DeserializeSchemaVersion(data []byte) (*SchemaVersion, error) {
+	version := new(SchemaVersion)
+
+	if err := json.Unmarshal(data, version); err != nil {
+		return nil, err
+	}
+
+	return version, nil
+}
@@ -0,0 +1,53 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchemaVersionTestSuite struct {
+	suite.Suite
+}
+
+func (s *SchemaVersionTestSuite) TestIsMigrated() {
+	version := &SchemaVersion{Version: 1, LatestVersion: 2}
+	assert.False(s.T(), version.IsMigrated(), "should not be migrated if the latest version has not been reached")
+	version.Version = 2
+	assert.True(s.T(), version.IsMigrated(), "should be migrated if the latest version has been reached")
+}
+
+func (s *SchemaVersionTestSuite) TestGetKeyComponents() {
+	version := &SchemaVersion{Version: 1}
+	assert.Equal(s.T(), []string{"schema"}, version.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *SchemaVersionTestSuite) TestSerialize() {
+	version := &SchemaVersion{Version: 1, LatestVersion: 2, Bookmark: "b1"}
+	serialized := "{\"version\":1,\"latestVersion\":2,\"bookmark\":\"b1\"}"
+
+	data, err := version.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *SchemaVersionTestSuite) TestValidate() {
+	version := &SchemaVersion{Version: -1}
+	assert.Error(s.T(), version.Validate(), "should error on negative version")
+	version.Version = 0
+	assert.Nil(s.T(), version.Validate(), "should return no error")
+}
+
+func (s *SchemaVersionTestSuite) TestDeserializeSchemaVersion() {
+	version, err := DeserializeSchemaVersion([]byte("{\"version\":1,\"latestVersion\":2}"))
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), &SchemaVersion{Version: 1, LatestVersion: 2}, version, "should return correct schema version")
+
+	_, err = DeserializeSchemaVersion([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func TestSchemaVersionTestSuite(t *testing.T) {
+	suite.Run(t, new(SchemaVersionTestSuite))
+}
@@ -25,6 +25,28 @@ type Service struct {
 
 	// LastUpdateTime the latest time that the service state has been updated
 	LastUpdateTime time.Time `json:"lastUpdateTime"`
+
+	// Acl access control list of the IoT service, any member of the channel can request the service if it is empty
+	Acl *ServiceAcl `json:"acl,omitempty"`
+
+	// Price number of tokens charged to the requester's organization for each request, the service is free if zero
+	Price int64 `json:"price,omitempty"`
+
+	// Quota rate limits and quotas of the IoT service, requests are not limited if it is empty
+	Quota *ServiceQuota `json:"quota,omitempty"`
+
+	// Streams topics on which the IoT service publishes telemetry messages
+	Streams []*ServiceStream `json:"streams,omitempty"`
+}
+
+// GetStream return a stream of the IoT service by its topic, nil if there is no such stream
+func (s *Service) GetStream(topic string) *ServiceStream {
+	for _, stream := range s.Streams {
+		if stream.Topic == topic {
+			return stream
+		}
+	}
+	return nil
 }
 
 // GetKeyComponents return components that compose the IoT service key
@@ -57,6 +79,32 @@ func (s *Service) Validate() error {
 	if s.LastUpdateTime.IsZero() {
 		return fmt.Errorf("missing service last update time in device definition")
 	}
+	if s.Price < 0 {
+		return fmt.Errorf("service price cannot be negative")
+	}
+	if s.Acl != nil {
+		if err := s.Acl.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Quota != nil {
+		if err := s.Quota.Validate(); err != nil {
+			return err
+		}
+	}
+	for i, stream := range s.Streams {
+		if stream == nil {
+			return fmt.Errorf("stream definition cannot be null")
+		}
+		if err := stream.Validate(); err != nil {
+			return err
+		}
+		for _, other := range s.Streams[:i] {
+			if other.Topic == stream.Topic {
+				return fmt.Errorf("duplicate stream topic %s in service definition", stream.Topic)
+			}
+		}
+	}
 
 	return nil
 }
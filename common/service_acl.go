@@ -0,0 +1,98 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// ServiceAclRule a set of clients allowed to access an IoT service or one of its methods
+type ServiceAclRule struct {
+	// OwnOrganization allow all members of the organization to which the IoT service belongs
+	OwnOrganization bool `json:"ownOrganization,omitempty"`
+
+	// OrganizationIds identities of the organizations whose members are allowed
+	OrganizationIds []string `json:"organizationIds,omitempty"`
+
+	// ClientIds identities of the clients that are allowed
+	ClientIds []string `json:"clientIds,omitempty"`
+}
+
+// IsEmpty check if the rule does not restrict any client
+func (r *ServiceAclRule) IsEmpty() bool {
+	return !r.OwnOrganization && len(r.OrganizationIds) == 0 && len(r.ClientIds) == 0
+}
+
+// Allows check if the rule allows a client to access an IoT service owned by an organization
+func (r *ServiceAclRule) Allows(ownerId string, organizationId string, clientId string) bool {
+	if r.IsEmpty() {
+		return true
+	}
+	if r.OwnOrganization && organizationId == ownerId {
+		return true
+	}
+	for _, id := range r.OrganizationIds {
+		if id == organizationId {
+			return true
+		}
+	}
+	for _, id := range r.ClientIds {
+		if id == clientId {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ServiceAcl access control list of an IoT service
+type ServiceAcl struct {
+	ServiceAclRule
+
+	// Methods additional rules restricting individual IoT service methods
+	Methods map[string]*ServiceAclRule `json:"methods,omitempty"`
+
+	// Attributes certificate attributes that clients must carry to request the IoT service
+	Attributes AttributePolicy `json:"attributes,omitempty"`
+}
+
+// Allows check if a client is allowed to call a method of an IoT service owned by an organization
+func (a *ServiceAcl) Allows(ownerId string, organizationId string, clientId string, method string) bool {
+	if a == nil {
+		return true
+	}
+	if !a.ServiceAclRule.Allows(ownerId, organizationId, clientId) {
+		return false
+	}
+	if rule, ok := a.Methods[method]; ok && rule != nil {
+		return rule.Allows(ownerId, organizationId, clientId)
+	}
+
+	return true
+}
+
+// Serialize transform current access control list to JSON string
+func (a *ServiceAcl) Serialize() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+// Validate check if the access control list properties are valid
+func (a *ServiceAcl) Validate() error {
+	for method := range a.Methods {
+		if method == "" {
+			return fmt.Errorf("empty method name in access control list definition")
+		}
+	}
+
+	return nil
+}
+
+// DeserializeServiceAcl create an access control list instance from its JSON representation
+func DeserializeServiceAcl(data []byte) (*ServiceAcl, error) {
+	acl := new(ServiceAcl)
+
+	if err := json.Unmarshal(data, acl); err != nil {
+		return nil, err
+	}
+
+	return acl, nil
+}
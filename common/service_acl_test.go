@@ -0,0 +1,77 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceAclTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServiceAclTestSuite) TestAllows() {
+	var acl *ServiceAcl
+	assert.True(s.T(), acl.Allows("org1", "org2", "client2", "GET"), "should allow everyone without access control list")
+
+	acl = new(ServiceAcl)
+	assert.True(s.T(), acl.Allows("org1", "org2", "client2", "GET"), "should allow everyone with empty access control list")
+
+	acl.OwnOrganization = true
+	assert.True(s.T(), acl.Allows("org1", "org1", "client1", "GET"), "should allow members of own organization")
+	assert.False(s.T(), acl.Allows("org1", "org2", "client2", "GET"), "should deny members of other organizations")
+
+	acl.OrganizationIds = []string{"org2"}
+	assert.True(s.T(), acl.Allows("org1", "org2", "client2", "GET"), "should allow members of listed organizations")
+	assert.False(s.T(), acl.Allows("org1", "org3", "client3", "GET"), "should deny members of unlisted organizations")
+
+	acl.ClientIds = []string{"client3"}
+	assert.True(s.T(), acl.Allows("org1", "org3", "client3", "GET"), "should allow listed clients")
+	assert.False(s.T(), acl.Allows("org1", "org3", "client4", "GET"), "should deny unlisted clients")
+
+	acl.Methods = map[string]*ServiceAclRule{"SET": {OwnOrganization: true}}
+	assert.True(s.T(), acl.Allows("org1", "org2", "client2", "GET"), "should allow methods without rules")
+	assert.False(s.T(), acl.Allows("org1", "org2", "client2", "SET"), "should deny clients not allowed by method rule")
+	assert.True(s.T(), acl.Allows("org1", "org1", "client1", "SET"), "should allow clients allowed by method rule")
+	assert.False(s.T(), acl.Allows("org1", "org4", "client4", "GET"), "should deny clients not allowed by service rule")
+}
+
+func (s *ServiceAclTestSuite) TestSerialize() {
+	acl := &ServiceAcl{
+		ServiceAclRule: ServiceAclRule{OwnOrganization: true, OrganizationIds: []string{"org2"}},
+		Methods:        map[string]*ServiceAclRule{"SET": {ClientIds: []string{"client1"}}},
+	}
+	serialized := "{\"ownOrganization\":true,\"organizationIds\":[\"org2\"],\"methods\":{\"SET\":{\"clientIds\":[\"client1\"]}}}"
+
+	data, err := acl.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *ServiceAclTestSuite) TestValidate() {
+	acl := &ServiceAcl{Methods: map[string]*ServiceAclRule{"": {}}}
+	assert.Error(s.T(), acl.Validate(), "should error on empty method name")
+
+	acl.Methods = map[string]*ServiceAclRule{"GET": {}}
+	assert.Nil(s.T(), acl.Validate(), "should return no error")
+}
+
+func (s *ServiceAclTestSuite) TestDeserializeServiceAcl() {
+	expected := &ServiceAcl{
+		ServiceAclRule: ServiceAclRule{OwnOrganization: true, OrganizationIds: []string{"org2"}},
+		Methods:        map[string]*ServiceAclRule{"SET": {ClientIds: []string{"client1"}}},
+	}
+	serialized := "{\"ownOrganization\":true,\"organizationIds\":[\"org2\"],\"methods\":{\"SET\":{\"clientIds\":[\"client1\"]}}}"
+
+	actual, err := DeserializeServiceAcl([]byte(serialized))
+	assert.Equal(s.T(), expected, actual, "should return parsed access control list")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeServiceAcl([]byte{0x00})
+	assert.Error(s.T(), err, "should return an error")
+}
+
+func TestServiceAclTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceAclTestSuite))
+}
@@ -0,0 +1,126 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ServiceQuery filters for discovering IoT services across organizations and devices, all non-empty filters must match
+type ServiceQuery struct {
+	// OrganizationId only search services of the organization if it is not empty
+	OrganizationId string `json:"organizationId,omitempty"`
+
+	// DeviceId only search services of the device if it is not empty, requires the organization ID
+	DeviceId string `json:"deviceId,omitempty"`
+
+	// Name exact name of the IoT service
+	Name string `json:"name,omitempty"`
+
+	// NamePrefix prefix of the IoT service name
+	NamePrefix string `json:"namePrefix,omitempty"`
+
+	// Keywords case-insensitive words that must all appear in the IoT service description
+	Keywords []string `json:"keywords,omitempty"`
+
+	// Version exact version number of the IoT service
+	Version int32 `json:"version,omitempty"`
+
+	// MinVersion minimum version number of the IoT service
+	MinVersion int32 `json:"minVersion,omitempty"`
+
+	// PageSize maximum number of services in the result, DefaultPageSize is used if it is zero
+	PageSize int `json:"pageSize,omitempty"`
+
+	// Bookmark position from which to continue the search, returned by the previous page of the result
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// GetPageSize return the effective number of services in a page of the result
+func (q *ServiceQuery) GetPageSize() int {
+	return GetPageSize(q.PageSize)
+}
+
+// Match check if an IoT service satisfies all filters of the query
+func (q *ServiceQuery) Match(service *Service) bool {
+	if q.OrganizationId != "" && service.OrganizationId != q.OrganizationId {
+		return false
+	}
+	if q.DeviceId != "" && service.DeviceId != q.DeviceId {
+		return false
+	}
+	if q.Name != "" && service.Name != q.Name {
+		return false
+	}
+	if q.NamePrefix != "" && !strings.HasPrefix(service.Name, q.NamePrefix) {
+		return false
+	}
+	if q.Version != 0 && service.Version != q.Version {
+		return false
+	}
+	if q.MinVersion != 0 && service.Version < q.MinVersion {
+		return false
+	}
+
+	description := strings.ToLower(service.Description)
+	for _, keyword := range q.Keywords {
+		if !strings.Contains(description, strings.ToLower(keyword)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Serialize transform current query to JSON string
+func (q *ServiceQuery) Serialize() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+// Validate check if the query properties are valid
+func (q *ServiceQuery) Validate() error {
+	if q.DeviceId != "" && q.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in service query")
+	}
+	if q.Version < 0 || q.MinVersion < 0 {
+		return fmt.Errorf("service version must be a positive integer")
+	}
+
+	return ValidatePageSize(q.PageSize)
+}
+
+// DeserializeServiceQuery create a query instance from its JSON representation
+func DeserializeServiceQuery(data []byte) (*ServiceQuery, error) {
+	query := new(ServiceQuery)
+
+	if err := json.Unmarshal(data, query); err != nil {
+		return nil, err
+	}
+
+	return query, nil
+}
+
+// ServiceQueryResult a page of IoT services matching a query
+type ServiceQueryResult struct {
+	// Services matching services in the page
+	Services []*Service `json:"services"`
+
+	// Bookmark position from which to search the next page, there are no more results if it is empty
+	Bookmark string `json:"bookmark,omitempty"`
+}
+
+// Serialize transform current query result to JSON string
+func (r *ServiceQueryResult) Serialize() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// DeserializeServiceQueryResult create a query result instance from its JSON representation
+func DeserializeServiceQueryResult(data []byte) (*ServiceQueryResult, error) {
+	result := new(ServiceQueryResult)
+
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
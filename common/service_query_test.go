@@ -0,0 +1,96 @@
+package common
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceQueryTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServiceQueryTestSuite) TestGetPageSize() {
+	query := &ServiceQuery{}
+	assert.Equal(s.T(), DefaultPageSize, query.GetPageSize(), "should return default page size")
+
+	query.PageSize = 10
+	assert.Equal(s.T(), 10, query.GetPageSize(), "should return page size of the query")
+}
+
+func (s *ServiceQueryTestSuite) TestMatch() {
+	service := &Service{
+		Name:           "thermometer",
+		DeviceId:       "device1",
+		OrganizationId: "org1",
+		Version:        2,
+		Description:    "Reads the Room Temperature in Celsius",
+	}
+
+	assert.True(s.T(), (&ServiceQuery{}).Match(service), "should match any service with empty query")
+	assert.True(s.T(), (&ServiceQuery{OrganizationId: "org1", DeviceId: "device1"}).Match(service), "should match by organization and device")
+	assert.False(s.T(), (&ServiceQuery{OrganizationId: "org2"}).Match(service), "should not match other organizations")
+	assert.True(s.T(), (&ServiceQuery{Name: "thermometer"}).Match(service), "should match by name")
+	assert.False(s.T(), (&ServiceQuery{Name: "thermo"}).Match(service), "should not match partial name")
+	assert.True(s.T(), (&ServiceQuery{NamePrefix: "thermo"}).Match(service), "should match by name prefix")
+	assert.False(s.T(), (&ServiceQuery{NamePrefix: "hygro"}).Match(service), "should not match other name prefixes")
+	assert.True(s.T(), (&ServiceQuery{Keywords: []string{"room", "celsius"}}).Match(service), "should match keywords case-insensitively")
+	assert.False(s.T(), (&ServiceQuery{Keywords: []string{"room", "humidity"}}).Match(service), "should require all keywords")
+	assert.True(s.T(), (&ServiceQuery{Version: 2}).Match(service), "should match by version")
+	assert.False(s.T(), (&ServiceQuery{Version: 1}).Match(service), "should not match other versions")
+	assert.True(s.T(), (&ServiceQuery{MinVersion: 2}).Match(service), "should match by minimum version")
+	assert.False(s.T(), (&ServiceQuery{MinVersion: 3}).Match(service), "should not match older versions")
+}
+
+func (s *ServiceQueryTestSuite) TestSerialize() {
+	query := &ServiceQuery{NamePrefix: "thermo", Keywords: []string{"room"}, PageSize: 10}
+	serialized := "{\"namePrefix\":\"thermo\",\"keywords\":[\"room\"],\"pageSize\":10}"
+
+	data, err := query.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *ServiceQueryTestSuite) TestValidate() {
+	query := &ServiceQuery{DeviceId: "device1"}
+	assert.Error(s.T(), query.Validate(), "should error on missing organization ID")
+
+	query = &ServiceQuery{Version: -1}
+	assert.Error(s.T(), query.Validate(), "should error on negative version")
+
+	query = &ServiceQuery{PageSize: MaxPageSize + 1}
+	assert.Error(s.T(), query.Validate(), "should error on too large page size")
+
+	query = &ServiceQuery{OrganizationId: "org1", DeviceId: "device1", PageSize: 10}
+	assert.Nil(s.T(), query.Validate(), "should return no error")
+}
+
+func (s *ServiceQueryTestSuite) TestDeserializeServiceQuery() {
+	expected := &ServiceQuery{Name: "thermometer", Version: 2, Bookmark: "bookmark1"}
+	serialized := "{\"name\":\"thermometer\",\"version\":2,\"bookmark\":\"bookmark1\"}"
+
+	actual, err := DeserializeServiceQuery([]byte(serialized))
+	assert.Equal(s.T(), expected, actual, "should return parsed query")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeServiceQuery([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *ServiceQueryTestSuite) TestDeserializeServiceQueryResult() {
+	expected := &ServiceQueryResult{Services: []*Service{{Name: "service1"}}, Bookmark: "bookmark1"}
+
+	data, _ := expected.Serialize()
+	actual, err := DeserializeServiceQueryResult(data)
+	assert.Equal(s.T(), expected.Bookmark, actual.Bookmark, "should return parsed query result")
+	assert.Equal(s.T(), "service1", actual.Services[0].Name, "should return parsed query result")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeServiceQueryResult([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func TestServiceQueryTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceQueryTestSuite))
+}
@@ -0,0 +1,106 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ServiceQuotaScope the kind of requester to which the limits of a service quota apply
+type ServiceQuotaScope string
+
+const (
+	// ServiceQuotaPerOrganization limits are shared by all clients of a requester organization
+	ServiceQuotaPerOrganization ServiceQuotaScope = "organization"
+
+	// ServiceQuotaPerClient limits apply to each requester client separately
+	ServiceQuotaPerClient ServiceQuotaScope = "client"
+)
+
+// ServiceQuota rate limits and quotas of an IoT service
+type ServiceQuota struct {
+	// Scope whether limits apply per requester organization or per requester client
+	Scope ServiceQuotaScope `json:"scope"`
+
+	// MaxRequests maximum number of requests in each window, unlimited if zero
+	MaxRequests int `json:"maxRequests,omitempty"`
+
+	// Window length of the rate limiting window in seconds
+	Window int64 `json:"window,omitempty"`
+
+	// MaxPending maximum number of unfinished requests at a time, unlimited if zero
+	MaxPending int `json:"maxPending,omitempty"`
+}
+
+// GetWindow return the length of the rate limiting window
+func (q *ServiceQuota) GetWindow() time.Duration {
+	return time.Duration(q.Window) * time.Second
+}
+
+// Serialize transform current quota to JSON string
+func (q *ServiceQuota) Serialize() ([]byte, error) {
+	return json.Marshal(q)
+}
+
+// Validate check if the quota properties are valid
+func (q *ServiceQuota) Validate() error {
+	if q.Scope != ServiceQuotaPerOrganization && q.Scope != ServiceQuotaPerClient {
+		return fmt.Errorf("invalid scope %s in quota definition", q.Scope)
+	}
+	if q.MaxRequests < 0 || q.MaxPending < 0 {
+		return fmt.Errorf("quota limits must not be negative")
+	}
+	if q.MaxRequests > 0 && q.Window <= 0 {
+		return fmt.Errorf("missing window in quota definition")
+	}
+
+	return nil
+}
+
+// DeserializeServiceQuota create a quota instance from its JSON representation
+func DeserializeServiceQuota(data []byte) (*ServiceQuota, error) {
+	quota := new(ServiceQuota)
+
+	if err := json.Unmarshal(data, quota); err != nil {
+		return nil, err
+	}
+
+	return quota, nil
+}
+
+// ServiceQuotaStatus the remaining quota of a requester on an IoT service
+type ServiceQuotaStatus struct {
+	// Quota the quota of the IoT service, nil if the service is not limited
+	Quota *ServiceQuota `json:"quota,omitempty"`
+
+	// Requests number of requests made in the current window
+	Requests int `json:"requests"`
+
+	// Pending number of unfinished requests
+	Pending int `json:"pending"`
+
+	// RemainingRequests number of requests that can still be made in the current window, -1 if unlimited
+	RemainingRequests int `json:"remainingRequests"`
+
+	// RemainingPending number of requests that can still be left unfinished, -1 if unlimited
+	RemainingPending int `json:"remainingPending"`
+
+	// ResetTime the time when the current window ends, zero if requests are not rate limited
+	ResetTime time.Time `json:"resetTime"`
+}
+
+// Serialize transform current quota status to JSON string
+func (s *ServiceQuotaStatus) Serialize() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// DeserializeServiceQuotaStatus create a quota status instance from its JSON representation
+func DeserializeServiceQuotaStatus(data []byte) (*ServiceQuotaStatus, error) {
+	status := new(ServiceQuotaStatus)
+
+	if err := json.Unmarshal(data, status); err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
@@ -0,0 +1,71 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceQuotaTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServiceQuotaTestSuite) TestGetWindow() {
+	quota := &ServiceQuota{Window: 3600}
+	assert.Equal(s.T(), time.Hour, quota.GetWindow(), "should return window as duration")
+}
+
+func (s *ServiceQuotaTestSuite) TestSerialize() {
+	quota := &ServiceQuota{Scope: ServiceQuotaPerOrganization, MaxRequests: 10, Window: 3600}
+	serialized := "{\"scope\":\"organization\",\"maxRequests\":10,\"window\":3600}"
+
+	data, err := quota.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *ServiceQuotaTestSuite) TestValidate() {
+	quota := &ServiceQuota{Scope: "device"}
+	assert.Error(s.T(), quota.Validate(), "should error on invalid scope")
+
+	quota = &ServiceQuota{Scope: ServiceQuotaPerClient, MaxPending: -1}
+	assert.Error(s.T(), quota.Validate(), "should error on negative limit")
+
+	quota = &ServiceQuota{Scope: ServiceQuotaPerClient, MaxRequests: 10}
+	assert.Error(s.T(), quota.Validate(), "should error on missing window")
+
+	quota.Window = 60
+	assert.Nil(s.T(), quota.Validate(), "should return no error")
+}
+
+func (s *ServiceQuotaTestSuite) TestDeserializeServiceQuota() {
+	expected := &ServiceQuota{Scope: ServiceQuotaPerClient, MaxPending: 5}
+	serialized := "{\"scope\":\"client\",\"maxPending\":5}"
+
+	actual, err := DeserializeServiceQuota([]byte(serialized))
+	assert.Equal(s.T(), expected, actual, "should return parsed quota")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeServiceQuota([]byte{0x00})
+	assert.Error(s.T(), err, "should return an error")
+}
+
+func (s *ServiceQuotaTestSuite) TestDeserializeServiceQuotaStatus() {
+	resetTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	expected := &ServiceQuotaStatus{Requests: 1, RemainingRequests: 9, RemainingPending: -1, ResetTime: resetTime}
+	data, _ := expected.Serialize()
+
+	actual, err := DeserializeServiceQuotaStatus(data)
+	assert.True(s.T(), expected.ResetTime.Equal(actual.ResetTime), "should return parsed reset time")
+	assert.Equal(s.T(), expected.RemainingRequests, actual.RemainingRequests, "should return parsed quota status")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeServiceQuotaStatus([]byte{0x00})
+	assert.Error(s.T(), err, "should return an error")
+}
+
+func TestServiceQuotaTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceQuotaTestSuite))
+}
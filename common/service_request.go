@@ -8,6 +8,80 @@ import (
 	"github.com/google/uuid"
 )
 
+// ServiceRequestStatus lifecycle status of an IoT service request
+type ServiceRequestStatus string
+
+const (
+	// ServiceRequestPending the request is waiting to be handled by the requested device
+	ServiceRequestPending ServiceRequestStatus = "pending"
+
+	// ServiceRequestAccepted the requested device has accepted the request
+	ServiceRequestAccepted ServiceRequestStatus = "accepted"
+
+	// ServiceRequestInProgress the requested device is working on the request
+	ServiceRequestInProgress ServiceRequestStatus = "in-progress"
+
+	// ServiceRequestCompleted the requested device has responded to the request successfully
+	ServiceRequestCompleted ServiceRequestStatus = "completed"
+
+	// ServiceRequestFailed the requested device has responded to the request with an error status code
+	ServiceRequestFailed ServiceRequestStatus = "failed"
+
+	// ServiceRequestCancelled the requester has withdrawn the request
+	ServiceRequestCancelled ServiceRequestStatus = "cancelled"
+
+	// ServiceRequestRejected the requested device has refused to handle the request
+	ServiceRequestRejected ServiceRequestStatus = "rejected"
+
+	// ServiceRequestExpired the request has not been responded to before its timeout
+	ServiceRequestExpired ServiceRequestStatus = "expired"
+)
+
+var serviceRequestTransitions = map[ServiceRequestStatus][]ServiceRequestStatus{
+	ServiceRequestPending: {
+		ServiceRequestAccepted,
+		ServiceRequestCompleted,
+		ServiceRequestFailed,
+		ServiceRequestCancelled,
+		ServiceRequestRejected,
+		ServiceRequestExpired,
+	},
+	ServiceRequestAccepted: {
+		ServiceRequestInProgress,
+		ServiceRequestCompleted,
+		ServiceRequestFailed,
+		ServiceRequestCancelled,
+		ServiceRequestExpired,
+	},
+	ServiceRequestInProgress: {
+		ServiceRequestCompleted,
+		ServiceRequestFailed,
+		ServiceRequestCancelled,
+		ServiceRequestExpired,
+	},
+}
+
+// CanTransitionTo check if a request in the current status is allowed to move to the next status
+func (s ServiceRequestStatus) CanTransitionTo(next ServiceRequestStatus) bool {
+	// requests created before status tracking was introduced have no status
+	if s == "" {
+		s = ServiceRequestPending
+	}
+
+	for _, status := range serviceRequestTransitions[s] {
+		if status == next {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsFinal check if no further transition is allowed from the status
+func (s ServiceRequestStatus) IsFinal() bool {
+	return s != "" && len(serviceRequestTransitions[s]) == 0
+}
+
 // ServiceRequest an IoT service request
 type ServiceRequest struct {
 	// Id identity of the IoT service request
@@ -24,6 +98,52 @@ type ServiceRequest struct {
 
 	// Arguments IoT service request arguments
 	Arguments []string `json:"arguments"`
+
+	// Status lifecycle status of the IoT service request, maintained by the service broker
+	Status ServiceRequestStatus `json:"status,omitempty"`
+
+	// RequesterOrganizationId identity of the organization of the client that made the request
+	RequesterOrganizationId string `json:"requesterOrganizationId,omitempty"`
+
+	// RequesterId identity of the client that made the request
+	RequesterId string `json:"requesterId,omitempty"`
+
+	// Timeout number of seconds after the request time when the request expires, never expires if zero
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// Price number of tokens escrowed from the requester's organization, maintained by the service broker
+	Price int64 `json:"price,omitempty"`
+
+	// Rating rating given by the requester after the request has been completed, zero if not rated
+	Rating int `json:"rating,omitempty"`
+
+	// GroupRequestId identity of the group request from which the request has been expanded, empty if none
+	GroupRequestId string `json:"groupRequestId,omitempty"`
+
+	// WorkflowRunId identity of the workflow run that made the request, empty if none
+	WorkflowRunId string `json:"workflowRunId,omitempty"`
+
+	// WorkflowStep name of the workflow step that made the request, empty if none
+	WorkflowStep string `json:"workflowStep,omitempty"`
+
+	// ChannelId channel of the chaincode providing the requested service, the channel of the request if empty
+	ChannelId string `json:"channelId,omitempty"`
+
+	// ChaincodeId name of the chaincode providing the requested service, the chaincode of the request if empty
+	ChaincodeId string `json:"chaincodeId,omitempty"`
+}
+
+// IsRemote check if the requested service is registered in another chaincode than the one storing the request
+func (r *ServiceRequest) IsRemote() bool {
+	return r.ChaincodeId != ""
+}
+
+// GetExpiryTime return the time when the request expires, zero if the request never expires
+func (r *ServiceRequest) GetExpiryTime() time.Time {
+	if r.Timeout <= 0 {
+		return time.Time{}
+	}
+	return r.Time.Add(time.Duration(r.Timeout) * time.Second)
 }
 
 // GetKeyComponents return components that compose the IoT service request key
@@ -53,6 +173,18 @@ func (r *ServiceRequest) Validate() error {
 	if r.Time.IsZero() {
 		return fmt.Errorf("missing request time in request definition")
 	}
+	if r.Timeout < 0 {
+		return fmt.Errorf("request timeout cannot be negative in request definition")
+	}
+	if r.ChannelId != "" && r.ChaincodeId == "" {
+		return fmt.Errorf("missing chaincode of the requested channel in request definition")
+	}
+	if r.Rating != 0 && r.Status != ServiceRequestCompleted {
+		return fmt.Errorf("only completed requests can be rated in request definition")
+	}
+	if r.Rating != 0 && (r.Rating < MinServiceRating || r.Rating > MaxServiceRating) {
+		return fmt.Errorf("request rating must be between %d and %d in request definition", MinServiceRating, MaxServiceRating)
+	}
 
 	return nil
 }
@@ -83,9 +83,45 @@ func (s *ServiceRequestTestSuite) TestValidate() {
 	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
 	request.Time = updateTime
 
+	request.Timeout = -1
+	assert.Error(s.T(), request.Validate(), "should error on negative timeout")
+	assert.Regexp(s.T(), "request timeout", request.Validate().Error())
+	request.Timeout = 60
+
+	request.ChannelId = "channel2"
+	assert.Error(s.T(), request.Validate(), "should error on channel without chaincode")
+	assert.Regexp(s.T(), "chaincode", request.Validate().Error())
+	request.ChaincodeId = "iotservice"
+
+	request.Rating = 5
+	assert.Error(s.T(), request.Validate(), "should error on rating of a request not completed")
+	assert.Regexp(s.T(), "rated", request.Validate().Error())
+	request.Status = ServiceRequestCompleted
+	request.Rating = MaxServiceRating + 1
+	assert.Error(s.T(), request.Validate(), "should error on rating out of range")
+	assert.Regexp(s.T(), "rating", request.Validate().Error())
+	request.Rating = MaxServiceRating
+
 	assert.Nil(s.T(), request.Validate(), "should return no error")
 }
 
+func (s *ServiceRequestTestSuite) TestIsRemote() {
+	request := &ServiceRequest{}
+	assert.False(s.T(), request.IsRemote(), "should be local without chaincode")
+
+	request.ChaincodeId = "iotservice"
+	assert.True(s.T(), request.IsRemote(), "should be remote with chaincode")
+}
+
+func (s *ServiceRequestTestSuite) TestGetExpiryTime() {
+	requestTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	request := &ServiceRequest{Time: requestTime}
+	assert.True(s.T(), request.GetExpiryTime().IsZero(), "should never expire without timeout")
+
+	request.Timeout = 60
+	assert.Equal(s.T(), requestTime.Add(time.Minute), request.GetExpiryTime(), "should expire after timeout")
+}
+
 func (s *ServiceRequestTestSuite) TestDeserializeService() {
 	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
 	expected := &ServiceRequest{
@@ -111,6 +147,23 @@ func (s *ServiceRequestTestSuite) TestDeserializeService() {
 	assert.Error(s.T(), err, "should return an error")
 }
 
+func (s *ServiceRequestTestSuite) TestStatusTransition() {
+	assert.True(s.T(), ServiceRequestPending.CanTransitionTo(ServiceRequestAccepted), "should accept a pending request")
+	assert.True(s.T(), ServiceRequestAccepted.CanTransitionTo(ServiceRequestInProgress), "should progress an accepted request")
+	assert.True(s.T(), ServiceRequestInProgress.CanTransitionTo(ServiceRequestCompleted), "should complete a request in progress")
+	assert.True(s.T(), ServiceRequestInProgress.CanTransitionTo(ServiceRequestCancelled), "should cancel a request in progress")
+	assert.True(s.T(), ServiceRequestStatus("").CanTransitionTo(ServiceRequestFailed), "should treat empty status as pending")
+	assert.True(s.T(), ServiceRequestInProgress.CanTransitionTo(ServiceRequestExpired), "should expire a request in progress")
+	assert.False(s.T(), ServiceRequestPending.CanTransitionTo(ServiceRequestInProgress), "should not skip acceptance")
+	assert.False(s.T(), ServiceRequestAccepted.CanTransitionTo(ServiceRequestRejected), "should not reject an accepted request")
+	assert.False(s.T(), ServiceRequestCompleted.CanTransitionTo(ServiceRequestCancelled), "should not cancel a completed request")
+
+	assert.True(s.T(), ServiceRequestRejected.IsFinal(), "should be a final status")
+	assert.True(s.T(), ServiceRequestExpired.IsFinal(), "should be a final status")
+	assert.False(s.T(), ServiceRequestAccepted.IsFinal(), "should not be a final status")
+	assert.False(s.T(), ServiceRequestStatus("").IsFinal(), "should not be a final status")
+}
+
 func TestServiceRequestTestSuite(t *testing.T) {
 	suite.Run(t, new(ServiceRequestTestSuite))
 }
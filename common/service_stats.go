@@ -0,0 +1,107 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MinServiceRating the lowest rating a requester can give to a completed IoT service request
+const MinServiceRating = 1
+
+// MaxServiceRating the highest rating a requester can give to a completed IoT service request
+const MaxServiceRating = 5
+
+// ServiceStats quality metrics of an IoT service
+type ServiceStats struct {
+	// OrganizationId identity of the organization to which the IoT service belongs
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the device to which the IoT service belongs
+	DeviceId string `json:"deviceId"`
+
+	// ServiceName name of the IoT service
+	ServiceName string `json:"serviceName"`
+
+	// Requests total number of requests made to the IoT service
+	Requests int64 `json:"requests"`
+
+	// Responses total number of responses made by the IoT service
+	Responses int64 `json:"responses"`
+
+	// ErrorResponses number of responses with an error status code
+	ErrorResponses int64 `json:"errorResponses"`
+
+	// TotalLatency cumulative time between request time and response time of all responses in milliseconds
+	TotalLatency int64 `json:"totalLatency"`
+
+	// Ratings number of ratings given by requesters
+	Ratings int64 `json:"ratings"`
+
+	// TotalRating sum of all ratings given by requesters
+	TotalRating int64 `json:"totalRating"`
+}
+
+// GetAverageLatency return the average time taken to respond to a request
+func (s *ServiceStats) GetAverageLatency() time.Duration {
+	if s.Responses == 0 {
+		return 0
+	}
+	return time.Duration(s.TotalLatency/s.Responses) * time.Millisecond
+}
+
+// GetErrorRate return the fraction of responses with an error status code
+func (s *ServiceStats) GetErrorRate() float64 {
+	if s.Responses == 0 {
+		return 0
+	}
+	return float64(s.ErrorResponses) / float64(s.Responses)
+}
+
+// GetReputation return the average rating given by requesters, zero if the IoT service has never been rated
+func (s *ServiceStats) GetReputation() float64 {
+	if s.Ratings == 0 {
+		return 0
+	}
+	return float64(s.TotalRating) / float64(s.Ratings)
+}
+
+// Add count the quality metrics of another IoT service stats in the current one
+func (s *ServiceStats) Add(other *ServiceStats) {
+	s.Requests += other.Requests
+	s.Responses += other.Responses
+	s.ErrorResponses += other.ErrorResponses
+	s.TotalLatency += other.TotalLatency
+	s.Ratings += other.Ratings
+	s.TotalRating += other.TotalRating
+}
+
+// GetKeyComponents return components that compose the IoT service stats key
+func (s *ServiceStats) GetKeyComponents() []string {
+	return []string{s.OrganizationId, s.DeviceId, s.ServiceName}
+}
+
+// Serialize transform current IoT service stats to JSON string
+func (s *ServiceStats) Serialize() ([]byte, error) {
+	return json.Marshal(s)
+}
+
+// Validate check if the IoT service stats properties are valid
+func (s *ServiceStats) Validate() error {
+	if s.OrganizationId == "" || s.DeviceId == "" || s.ServiceName == "" {
+		return fmt.Errorf("missing service in stats definition")
+	}
+
+	return nil
+}
+
+// DeserializeServiceStats create an IoT service stats instance from its JSON representation
+func DeserializeServiceStats(data []byte) (*ServiceStats, error) {
+	stats := new(ServiceStats)
+
+	if err := json.Unmarshal(data, stats); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,80 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceStatsTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServiceStatsTestSuite) TestGetKeyComponents() {
+	stats := &ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1"}
+	assert.Equal(s.T(), []string{"org1", "device1", "service1"}, stats.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *ServiceStatsTestSuite) TestMetrics() {
+	stats := new(ServiceStats)
+	assert.Zero(s.T(), stats.GetAverageLatency(), "should return zero latency without responses")
+	assert.Zero(s.T(), stats.GetErrorRate(), "should return zero error rate without responses")
+	assert.Zero(s.T(), stats.GetReputation(), "should return zero reputation without ratings")
+
+	stats = &ServiceStats{Responses: 4, ErrorResponses: 1, TotalLatency: 2000, Ratings: 2, TotalRating: 7}
+	assert.Equal(s.T(), 500*time.Millisecond, stats.GetAverageLatency(), "should return average latency")
+	assert.Equal(s.T(), 0.25, stats.GetErrorRate(), "should return error rate")
+	assert.Equal(s.T(), 3.5, stats.GetReputation(), "should return average rating")
+}
+
+func (s *ServiceStatsTestSuite) TestAdd() {
+	stats := &ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 2, Responses: 1, TotalLatency: 500}
+	stats.Add(&ServiceStats{Requests: 1, Responses: 1, ErrorResponses: 1, TotalLatency: 1500, Ratings: 1, TotalRating: 4})
+	assert.Equal(s.T(), &ServiceStats{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		ServiceName:    "service1",
+		Requests:       3,
+		Responses:      2,
+		ErrorResponses: 1,
+		TotalLatency:   2000,
+		Ratings:        1,
+		TotalRating:    4,
+	}, stats, "should sum the metrics")
+}
+
+func (s *ServiceStatsTestSuite) TestSerialize() {
+	stats := &ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 2, Responses: 1}
+	serialized := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serviceName\":\"service1\",\"requests\":2,\"responses\":1," +
+		"\"errorResponses\":0,\"totalLatency\":0,\"ratings\":0,\"totalRating\":0}"
+
+	data, err := stats.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *ServiceStatsTestSuite) TestValidate() {
+	stats := &ServiceStats{OrganizationId: "org1", DeviceId: "device1"}
+	assert.Error(s.T(), stats.Validate(), "should error on missing service name")
+
+	stats.ServiceName = "service1"
+	assert.Nil(s.T(), stats.Validate(), "should return no error")
+}
+
+func (s *ServiceStatsTestSuite) TestDeserializeServiceStats() {
+	expected := &ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Ratings: 1, TotalRating: 5}
+	data, _ := expected.Serialize()
+
+	actual, err := DeserializeServiceStats(data)
+	assert.Equal(s.T(), expected, actual, "should return parsed stats")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeServiceStats([]byte{0x00})
+	assert.Error(s.T(), err, "should return an error")
+}
+
+func TestServiceStatsTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceStatsTestSuite))
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ServiceStream a topic on which an IoT service publishes telemetry messages
+type ServiceStream struct {
+	// Topic name of the stream, unique within its service
+	Topic string `json:"topic"`
+
+	// Description a brief summary of the published messages
+	Description string `json:"description,omitempty"`
+
+	// Interval expected number of seconds between two messages, unknown if zero
+	Interval int64 `json:"interval,omitempty"`
+}
+
+// Validate check if the stream properties are valid
+func (s *ServiceStream) Validate() error {
+	if s.Topic == "" {
+		return fmt.Errorf("missing topic in stream definition")
+	}
+	if strings.Contains(s.Topic, "/") {
+		return fmt.Errorf("stream topic cannot contain slashes")
+	}
+	if s.Interval < 0 {
+		return fmt.Errorf("stream interval cannot be negative")
+	}
+
+	return nil
+}
+
+// StreamMessage a telemetry message published by an IoT service on one of its streams
+type StreamMessage struct {
+	// OrganizationId identity of the organization of the publishing device
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the publishing device
+	DeviceId string `json:"deviceId"`
+
+	// ServiceName name of the publishing service
+	ServiceName string `json:"serviceName"`
+
+	// Topic topic of the stream
+	Topic string `json:"topic"`
+
+	// Sequence sequence number of the message within its stream, maintained by the service broker
+	Sequence int64 `json:"sequence,omitempty"`
+
+	// Time time when the message has been published
+	Time time.Time `json:"time"`
+
+	// Data reading carried by the message, empty if the message carries a batch hash
+	Data string `json:"data,omitempty"`
+
+	// Hash hash of a batch of readings kept off the ledger, empty if the message carries a reading
+	Hash string `json:"hash,omitempty"`
+
+	// Count number of readings in the batch, if the message carries a batch hash
+	Count int64 `json:"count,omitempty"`
+}
+
+// Serialize transform current stream message to JSON string
+func (m *StreamMessage) Serialize() ([]byte, error) {
+	return json.Marshal(m)
+}
+
+// Validate check if the stream message properties are valid
+func (m *StreamMessage) Validate() error {
+	if m.OrganizationId == "" || m.DeviceId == "" || m.ServiceName == "" {
+		return fmt.Errorf("missing publishing service in stream message definition")
+	}
+	if m.Topic == "" {
+		return fmt.Errorf("missing topic in stream message definition")
+	}
+	if m.Time.IsZero() {
+		return fmt.Errorf("missing publish time in stream message definition")
+	}
+	if (m.Data == "") == (m.Hash == "") {
+		return fmt.Errorf("stream message must carry either a reading or a batch hash")
+	}
+	if m.Count < 0 || (m.Count > 0 && m.Hash == "") {
+		return fmt.Errorf("invalid batch size in stream message definition")
+	}
+
+	return nil
+}
+
+// DeserializeStreamMessage create a stream message instance from its JSON representation
+func DeserializeStreamMessage(data []byte) (*StreamMessage, error) {
+	message := new(StreamMessage)
+
+	if err := json.Unmarshal(data, message); err != nil {
+		return nil, err
+	}
+
+	return message, nil
+}
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ServiceStreamTestSuite struct {
+	suite.Suite
+}
+
+func (s *ServiceStreamTestSuite) TestValidateStream() {
+	stream := ServiceStream{}
+
+	assert.Error(s.T(), stream.Validate(), "should error on empty topic")
+	assert.Regexp(s.T(), "topic", stream.Validate().Error())
+	stream.Topic = "temperature/celsius"
+
+	assert.Error(s.T(), stream.Validate(), "should error on topic with slashes")
+	assert.Regexp(s.T(), "slashes", stream.Validate().Error())
+	stream.Topic = "temperature"
+
+	stream.Interval = -1
+	assert.Error(s.T(), stream.Validate(), "should error on negative interval")
+	assert.Regexp(s.T(), "interval", stream.Validate().Error())
+	stream.Interval = 60
+
+	assert.Nil(s.T(), stream.Validate(), "should return no error")
+}
+
+func (s *ServiceStreamTestSuite) TestSerializeMessage() {
+	publishTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	message := &StreamMessage{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		ServiceName:    "service1",
+		Topic:          "temperature",
+		Sequence:       3,
+		Time:           publishTime,
+		Hash:           "9f86d081",
+		Count:          60,
+	}
+	serialized := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serviceName\":\"service1\",\"topic\":\"temperature\"," +
+		"\"sequence\":3,\"time\":\"2021-12-12T17:34:00-05:00\",\"hash\":\"9f86d081\",\"count\":60}"
+
+	data, err := message.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeStreamMessage(data)
+	assert.Equal(s.T(), message, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeStreamMessage([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *ServiceStreamTestSuite) TestValidateMessage() {
+	message := StreamMessage{OrganizationId: "org1", DeviceId: "device1"}
+
+	assert.Error(s.T(), message.Validate(), "should error on incomplete publishing service")
+	assert.Regexp(s.T(), "publishing service", message.Validate().Error())
+	message.ServiceName = "service1"
+
+	assert.Error(s.T(), message.Validate(), "should error on empty topic")
+	assert.Regexp(s.T(), "topic", message.Validate().Error())
+	message.Topic = "temperature"
+
+	assert.Error(s.T(), message.Validate(), "should error on empty publish time")
+	assert.Regexp(s.T(), "publish time", message.Validate().Error())
+	message.Time = time.Now()
+
+	assert.Error(s.T(), message.Validate(), "should error on empty content")
+	assert.Regexp(s.T(), "either", message.Validate().Error())
+	message.Data, message.Hash = "21.5", "9f86d081"
+	assert.Error(s.T(), message.Validate(), "should error on both reading and batch hash")
+	assert.Regexp(s.T(), "either", message.Validate().Error())
+	message.Hash = ""
+
+	message.Count = 10
+	assert.Error(s.T(), message.Validate(), "should error on batch size of a single reading")
+	assert.Regexp(s.T(), "batch size", message.Validate().Error())
+	message.Count = 0
+
+	assert.Nil(s.T(), message.Validate(), "should return no error")
+}
+
+func TestServiceStreamTestSuite(t *testing.T) {
+	suite.Run(t, new(ServiceStreamTestSuite))
+}
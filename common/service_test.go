@@ -70,9 +70,32 @@ func (s *ServiceTestSuite) TestValidate() {
 	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
 	service.LastUpdateTime = updateTime
 
+	service.Price = -1
+	assert.Error(s.T(), service.Validate(), "should error on negative price")
+	assert.Regexp(s.T(), "price", service.Validate().Error())
+	service.Price = 10
+
+	service.Quota = &ServiceQuota{Scope: "device"}
+	assert.Error(s.T(), service.Validate(), "should error on invalid quota")
+	service.Quota = &ServiceQuota{Scope: ServiceQuotaPerClient, MaxPending: 1}
+
+	service.Streams = []*ServiceStream{{Topic: "temperature/celsius"}}
+	assert.Error(s.T(), service.Validate(), "should error on invalid stream")
+	assert.Regexp(s.T(), "topic", service.Validate().Error())
+	service.Streams = []*ServiceStream{{Topic: "temperature"}, {Topic: "temperature"}}
+	assert.Error(s.T(), service.Validate(), "should error on duplicate stream topic")
+	assert.Regexp(s.T(), "duplicate", service.Validate().Error())
+	service.Streams[1].Topic = "humidity"
+
 	assert.Nil(s.T(), service.Validate(), "should return no error")
 }
 
+func (s *ServiceTestSuite) TestGetStream() {
+	service := &Service{Streams: []*ServiceStream{{Topic: "temperature"}, {Topic: "humidity"}}}
+	assert.Equal(s.T(), service.Streams[1], service.GetStream("humidity"), "should return the stream of the topic")
+	assert.Nil(s.T(), service.GetStream("pressure"), "should return no stream")
+}
+
 func (s *ServiceTestSuite) TestDeserializeService() {
 	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
 	expected := &Service{
@@ -0,0 +1,272 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WorkflowInputs name of the pseudo step whose fields are the inputs of a workflow run
+const WorkflowInputs = "input"
+
+// workflowReferencePattern a reference to an input of the run or a response field of an earlier step, such as
+// ${input.threshold} or ${read.returnValue}
+var workflowReferencePattern = regexp.MustCompile(`\$\{([^.}]+)\.([^}]+)\}`)
+
+// WorkflowCondition a comparison of a response field of an earlier step deciding whether a step runs
+type WorkflowCondition struct {
+	// Step name of the earlier step whose response is compared
+	Step string `json:"step"`
+
+	// Field response field to compare, either returnValue or statusCode, returnValue if empty
+	Field string `json:"field,omitempty"`
+
+	// Operator comparison operator, one of eq, ne, gt, ge, lt and le
+	Operator string `json:"operator"`
+
+	// Value value to compare the response field against, compared as numbers if both are numeric
+	Value string `json:"value"`
+}
+
+// Validate check if the condition properties are valid
+func (c *WorkflowCondition) Validate() error {
+	if c.Step == "" {
+		return fmt.Errorf("missing step in workflow condition")
+	}
+	if c.Field != "" && c.Field != "returnValue" && c.Field != "statusCode" {
+		return fmt.Errorf("unknown response field %s in workflow condition", c.Field)
+	}
+	switch c.Operator {
+	case "eq", "ne", "gt", "ge", "lt", "le":
+	default:
+		return fmt.Errorf("unknown operator %s in workflow condition", c.Operator)
+	}
+
+	return nil
+}
+
+// Evaluate compare the response field of the condition step against the condition value
+func (c *WorkflowCondition) Evaluate(state *WorkflowStepState) bool {
+	actual := state.ReturnValue
+	if c.Field == "statusCode" {
+		actual = strconv.FormatInt(int64(state.StatusCode), 10)
+	}
+
+	// compare as numbers if possible, or as strings otherwise
+	result := strings.Compare(actual, c.Value)
+	if x, err := strconv.ParseFloat(actual, 64); err == nil {
+		if y, err := strconv.ParseFloat(c.Value, 64); err == nil {
+			switch {
+			case x < y:
+				result = -1
+			case x > y:
+				result = 1
+			default:
+				result = 0
+			}
+		}
+	}
+
+	switch c.Operator {
+	case "eq":
+		return result == 0
+	case "ne":
+		return result != 0
+	case "gt":
+		return result > 0
+	case "ge":
+		return result >= 0
+	case "lt":
+		return result < 0
+	case "le":
+		return result <= 0
+	}
+
+	return false
+}
+
+// WorkflowStep a call to an IoT service in a workflow
+type WorkflowStep struct {
+	// Name name of the step, unique within its workflow
+	Name string `json:"name"`
+
+	// OrganizationId identity of the organization of the requested device
+	OrganizationId string `json:"organizationId"`
+
+	// DeviceId identity of the requested device
+	DeviceId string `json:"deviceId"`
+
+	// ServiceName name of the requested service
+	ServiceName string `json:"serviceName"`
+
+	// Method IoT service request method
+	Method string `json:"method"`
+
+	// Arguments IoT service request arguments, which may reference run inputs and responses of earlier steps
+	Arguments []string `json:"arguments"`
+
+	// Timeout number of seconds after the request time when the request expires, never expire if zero
+	Timeout int64 `json:"timeout,omitempty"`
+
+	// DependsOn names of the steps that must complete before the step runs
+	DependsOn []string `json:"dependsOn,omitempty"`
+
+	// Condition condition on the response of an earlier step for the step to run, always run if empty
+	Condition *WorkflowCondition `json:"condition,omitempty"`
+}
+
+// Workflow a directed acyclic graph of IoT service calls
+type Workflow struct {
+	// OrganizationId identity of the organization to which the workflow belongs
+	OrganizationId string `json:"organizationId"`
+
+	// Name name of the workflow, unique within its organization
+	Name string `json:"name"`
+
+	// Description a brief summary of the workflow
+	Description string `json:"description,omitempty"`
+
+	// OwnerId identity of the client that owns the workflow, maintained by the workflow registry
+	OwnerId string `json:"ownerId,omitempty"`
+
+	// Steps service calls of the workflow
+	Steps []*WorkflowStep `json:"steps"`
+
+	// LastUpdateTime the latest time that the workflow has been updated
+	LastUpdateTime time.Time `json:"lastUpdateTime"`
+}
+
+// GetStep return a step of the workflow by its name, nil if there is no such step
+func (w *Workflow) GetStep(name string) *WorkflowStep {
+	for _, step := range w.Steps {
+		if step.Name == name {
+			return step
+		}
+	}
+	return nil
+}
+
+// GetKeyComponents return components that compose the workflow key
+func (w *Workflow) GetKeyComponents() []string {
+	return []string{w.OrganizationId, w.Name}
+}
+
+// Serialize transform current workflow to JSON string
+func (w *Workflow) Serialize() ([]byte, error) {
+	return json.Marshal(w)
+}
+
+// Validate check if the workflow properties are valid
+func (w *Workflow) Validate() error {
+	if w.OrganizationId == "" {
+		return fmt.Errorf("missing organization ID in workflow definition")
+	}
+	if w.Name == "" {
+		return fmt.Errorf("missing workflow name in workflow definition")
+	}
+	if len(w.Steps) == 0 {
+		return fmt.Errorf("missing steps in workflow definition")
+	}
+
+	for i, step := range w.Steps {
+		if step == nil || step.Name == "" || step.Name == WorkflowInputs {
+			return fmt.Errorf("invalid step name in workflow definition")
+		}
+		for _, other := range w.Steps[:i] {
+			if other.Name == step.Name {
+				return fmt.Errorf("duplicate step %s in workflow definition", step.Name)
+			}
+		}
+		if step.OrganizationId == "" || step.DeviceId == "" || step.ServiceName == "" {
+			return fmt.Errorf("missing requested service of step %s in workflow definition", step.Name)
+		}
+		if step.Method == "" {
+			return fmt.Errorf("missing request method of step %s in workflow definition", step.Name)
+		}
+		if step.Arguments == nil {
+			return fmt.Errorf("request arguments of step %s cannot be null in workflow definition", step.Name)
+		}
+		if step.Timeout < 0 {
+			return fmt.Errorf("request timeout of step %s cannot be negative in workflow definition", step.Name)
+		}
+	}
+
+	// steps can only refer to the steps they depend on, directly or not
+	for _, step := range w.Steps {
+		ancestors, err := w.getAncestors(step, map[string]bool{})
+		if err != nil {
+			return err
+		}
+
+		if step.Condition != nil {
+			if err = step.Condition.Validate(); err != nil {
+				return err
+			}
+			if !ancestors[step.Condition.Step] {
+				return fmt.Errorf("condition of step %s refers to step %s it does not depend on", step.Name, step.Condition.Step)
+			}
+		}
+
+		for _, argument := range step.Arguments {
+			for _, match := range workflowReferencePattern.FindAllStringSubmatch(argument, -1) {
+				if match[1] == WorkflowInputs {
+					continue
+				}
+				if !ancestors[match[1]] {
+					return fmt.Errorf("arguments of step %s refer to step %s it does not depend on", step.Name, match[1])
+				}
+				if match[2] != "returnValue" && match[2] != "statusCode" {
+					return fmt.Errorf("arguments of step %s refer to unknown response field %s", step.Name, match[2])
+				}
+			}
+		}
+	}
+
+	if w.LastUpdateTime.IsZero() {
+		return fmt.Errorf("missing workflow last update time in workflow definition")
+	}
+
+	return nil
+}
+
+// return the names of the steps a step depends on directly or not, failing on unknown steps and cycles
+func (w *Workflow) getAncestors(step *WorkflowStep, visiting map[string]bool) (map[string]bool, error) {
+	if visiting[step.Name] {
+		return nil, fmt.Errorf("steps of workflow definition cannot depend on each other in a cycle")
+	}
+	visiting[step.Name] = true
+	defer delete(visiting, step.Name)
+
+	ancestors := make(map[string]bool)
+	for _, name := range step.DependsOn {
+		dependency := w.GetStep(name)
+		if dependency == nil {
+			return nil, fmt.Errorf("step %s depends on unknown step %s", step.Name, name)
+		}
+
+		ancestors[name] = true
+		ancestors_, err := w.getAncestors(dependency, visiting)
+		if err != nil {
+			return nil, err
+		}
+		for ancestor := range ancestors_ {
+			ancestors[ancestor] = true
+		}
+	}
+
+	return ancestors, nil
+}
+
+// DeserializeWorkflow create a workflow instance from its JSON representation
+func DeserializeWorkflow(data []byte) (*Workflow, error) {
+	workflow := new(Workflow)
+
+	if err := json.Unmarshal(data, workflow); err != nil {
+		return nil, err
+	}
+
+	return workflow, nil
+}
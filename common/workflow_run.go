@@ -0,0 +1,260 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// WorkflowRunStatus lifecycle status of a workflow run
+type WorkflowRunStatus string
+
+const (
+	// WorkflowRunRunning some steps of the run have not finished
+	WorkflowRunRunning WorkflowRunStatus = "running"
+
+	// WorkflowRunCompleted every step of the run has completed or been skipped
+	WorkflowRunCompleted WorkflowRunStatus = "completed"
+
+	// WorkflowRunFailed every step of the run has finished and some of them have failed
+	WorkflowRunFailed WorkflowRunStatus = "failed"
+)
+
+// WorkflowStepStatus lifecycle status of a step in a workflow run
+type WorkflowStepStatus string
+
+const (
+	// WorkflowStepWaiting the step is waiting for the steps it depends on
+	WorkflowStepWaiting WorkflowStepStatus = "waiting"
+
+	// WorkflowStepRequested the service of the step has been requested
+	WorkflowStepRequested WorkflowStepStatus = "requested"
+
+	// WorkflowStepCompleted the requested device has responded to the step request successfully
+	WorkflowStepCompleted WorkflowStepStatus = "completed"
+
+	// WorkflowStepFailed the step could not be requested, or its request did not complete
+	WorkflowStepFailed WorkflowStepStatus = "failed"
+
+	// WorkflowStepSkipped the step condition is not met, or a step it depends on has not completed
+	WorkflowStepSkipped WorkflowStepStatus = "skipped"
+)
+
+// IsFinal check if the step will not change any more
+func (s WorkflowStepStatus) IsFinal() bool {
+	return s == WorkflowStepCompleted || s == WorkflowStepFailed || s == WorkflowStepSkipped
+}
+
+// WorkflowStepState progress of a step in a workflow run
+type WorkflowStepState struct {
+	// Name name of the step
+	Name string `json:"name"`
+
+	// Status lifecycle status of the step
+	Status WorkflowStepStatus `json:"status"`
+
+	// RequestId identity of the request made by the step
+	RequestId string `json:"requestId,omitempty"`
+
+	// StatusCode status code of the response to the step request
+	StatusCode int32 `json:"statusCode,omitempty"`
+
+	// ReturnValue return value of the response to the step request
+	ReturnValue string `json:"returnValue,omitempty"`
+
+	// Error reason why the step failed
+	Error string `json:"error,omitempty"`
+}
+
+// WorkflowRun an execution of a workflow
+type WorkflowRun struct {
+	// Id identity of the run
+	Id string `json:"id"`
+
+	// Time time when the run started
+	Time time.Time `json:"time"`
+
+	// WorkflowOrganizationId identity of the organization to which the workflow belongs
+	WorkflowOrganizationId string `json:"workflowOrganizationId"`
+
+	// WorkflowName name of the workflow
+	WorkflowName string `json:"workflowName"`
+
+	// Inputs values referenced by the step arguments as ${input.<name>}
+	Inputs map[string]string `json:"inputs,omitempty"`
+
+	// RequesterOrganizationId identity of the organization of the client that started the run
+	RequesterOrganizationId string `json:"requesterOrganizationId,omitempty"`
+
+	// RequesterId identity of the client that started the run, which becomes the requester of every step
+	RequesterId string `json:"requesterId,omitempty"`
+
+	// Status lifecycle status of the run, maintained by the workflow registry
+	Status WorkflowRunStatus `json:"status,omitempty"`
+
+	// Steps steps of the workflow when the run started, maintained by the workflow registry
+	Steps []*WorkflowStep `json:"steps,omitempty"`
+
+	// StepStates progress of every step, maintained by the workflow registry
+	StepStates []*WorkflowStepState `json:"stepStates,omitempty"`
+
+	// LastUpdateTime the latest time that the run has advanced
+	LastUpdateTime time.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// GetStepState return the progress of a step by its name, nil if there is no such step
+func (r *WorkflowRun) GetStepState(name string) *WorkflowStepState {
+	for _, state := range r.StepStates {
+		if state.Name == name {
+			return state
+		}
+	}
+	return nil
+}
+
+// GetReadySteps skip the waiting steps that cannot run any more and return those whose dependencies have all completed
+func (r *WorkflowRun) GetReadySteps() []*WorkflowStep {
+	ready := make([]*WorkflowStep, 0)
+
+	for changed := true; changed; {
+		changed = false
+		ready = ready[:0]
+
+		for _, step := range r.Steps {
+			state := r.GetStepState(step.Name)
+			if state.Status != WorkflowStepWaiting {
+				continue
+			}
+
+			finished, completed := true, true
+			for _, name := range step.DependsOn {
+				status := r.GetStepState(name).Status
+				finished = finished && status.IsFinal()
+				completed = completed && status == WorkflowStepCompleted
+			}
+			if !finished {
+				continue
+			}
+
+			// skipping a step may in turn skip the steps depending on it
+			if !completed || (step.Condition != nil && !step.Condition.Evaluate(r.GetStepState(step.Condition.Step))) {
+				state.Status = WorkflowStepSkipped
+				changed = true
+				continue
+			}
+
+			ready = append(ready, step)
+		}
+	}
+
+	return ready
+}
+
+// ResolveArguments replace the references in the arguments of a step with run inputs and earlier responses
+func (r *WorkflowRun) ResolveArguments(step *WorkflowStep) ([]string, error) {
+	var err error
+	arguments := make([]string, 0, len(step.Arguments))
+
+	for _, argument := range step.Arguments {
+		arguments = append(arguments, workflowReferencePattern.ReplaceAllStringFunc(argument, func(reference string) string {
+			match := workflowReferencePattern.FindStringSubmatch(reference)
+			if match[1] == WorkflowInputs {
+				value, ok := r.Inputs[match[2]]
+				if !ok {
+					err = fmt.Errorf("missing input %s of the workflow run", match[2])
+				}
+				return value
+			}
+
+			state := r.GetStepState(match[1])
+			if state == nil {
+				err = fmt.Errorf("unknown step %s referenced by step %s", match[1], step.Name)
+				return ""
+			}
+			if match[2] == "statusCode" {
+				return strconv.FormatInt(int64(state.StatusCode), 10)
+			}
+			return state.ReturnValue
+		}))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return arguments, nil
+}
+
+// NewRequest create the request made by a step of the run, whose ID is derived from the run ID, the step name, and
+// the ID of the transaction making the request, so that it cannot be taken by another request beforehand
+func (r *WorkflowRun) NewRequest(step *WorkflowStep, arguments []string, transactionId string) *ServiceRequest {
+	id := uuid.NewSHA1(uuid.MustParse(r.Id), []byte(step.Name+"\x00"+transactionId))
+
+	return &ServiceRequest{
+		Id: id.String(),
+		Service: Service{
+			OrganizationId: step.OrganizationId,
+			DeviceId:       step.DeviceId,
+			Name:           step.ServiceName,
+		},
+		Method:                  step.Method,
+		Arguments:               arguments,
+		RequesterOrganizationId: r.RequesterOrganizationId,
+		RequesterId:             r.RequesterId,
+		Timeout:                 step.Timeout,
+		WorkflowRunId:           r.Id,
+		WorkflowStep:            step.Name,
+	}
+}
+
+// UpdateStatus derive the status of the run from the progress of its steps
+func (r *WorkflowRun) UpdateStatus() {
+	r.Status = WorkflowRunCompleted
+	for _, state := range r.StepStates {
+		if !state.Status.IsFinal() {
+			r.Status = WorkflowRunRunning
+			return
+		}
+		if state.Status == WorkflowStepFailed {
+			r.Status = WorkflowRunFailed
+		}
+	}
+}
+
+// GetKeyComponents return components that compose the workflow run key
+func (r *WorkflowRun) GetKeyComponents() []string {
+	return []string{r.Id}
+}
+
+// Serialize transform current workflow run to JSON string
+func (r *WorkflowRun) Serialize() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Validate check if the workflow run properties are valid
+func (r *WorkflowRun) Validate() error {
+	if _, err := uuid.Parse(r.Id); err != nil {
+		return fmt.Errorf("invalid run ID in workflow run definition")
+	}
+	if r.WorkflowOrganizationId == "" || r.WorkflowName == "" {
+		return fmt.Errorf("missing workflow in workflow run definition")
+	}
+	if r.Time.IsZero() {
+		return fmt.Errorf("missing run time in workflow run definition")
+	}
+
+	return nil
+}
+
+// DeserializeWorkflowRun create a workflow run instance from its JSON representation
+func DeserializeWorkflowRun(data []byte) (*WorkflowRun, error) {
+	run := new(WorkflowRun)
+
+	if err := json.Unmarshal(data, run); err != nil {
+		return nil, err
+	}
+
+	return run, nil
+}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkflowRunTestSuite struct {
+	suite.Suite
+}
+
+func newTestWorkflowRun() *WorkflowRun {
+	return &WorkflowRun{
+		Id:                      "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1",
+		WorkflowOrganizationId:  "org1",
+		WorkflowName:            "workflow1",
+		Inputs:                  map[string]string{"unit": "celsius"},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "app1",
+		Steps: []*WorkflowStep{
+			{Name: "read", OrganizationId: "org1", DeviceId: "sensor1", ServiceName: "temperature", Method: "GET", Arguments: []string{"${input.unit}"}},
+			{Name: "check", OrganizationId: "org1", DeviceId: "sensor2", ServiceName: "humidity", Method: "GET", Arguments: []string{}},
+			{
+				Name: "actuate", OrganizationId: "org1", DeviceId: "fan1", ServiceName: "speed", Method: "SET", Timeout: 60,
+				Arguments: []string{"speed=${read.returnValue}", "${check.statusCode}"},
+				DependsOn: []string{"read", "check"},
+				Condition: &WorkflowCondition{Step: "read", Operator: "gt", Value: "30"},
+			},
+			{Name: "notify", OrganizationId: "org1", DeviceId: "phone1", ServiceName: "notify", Method: "POST", Arguments: []string{}, DependsOn: []string{"actuate"}},
+		},
+		StepStates: []*WorkflowStepState{
+			{Name: "read", Status: WorkflowStepWaiting},
+			{Name: "check", Status: WorkflowStepWaiting},
+			{Name: "actuate", Status: WorkflowStepWaiting},
+			{Name: "notify", Status: WorkflowStepWaiting},
+		},
+	}
+}
+
+func (s *WorkflowRunTestSuite) TestGetReadySteps() {
+	run := newTestWorkflowRun()
+
+	steps := run.GetReadySteps()
+	assert.Equal(s.T(), []*WorkflowStep{run.Steps[0], run.Steps[1]}, steps, "should return the steps without dependencies")
+
+	run.StepStates[0].Status = WorkflowStepCompleted
+	run.StepStates[0].ReturnValue = "31"
+	run.StepStates[1].Status = WorkflowStepRequested
+	assert.Empty(s.T(), run.GetReadySteps(), "should wait for every dependency")
+
+	run.StepStates[1].Status = WorkflowStepCompleted
+	assert.Equal(s.T(), []*WorkflowStep{run.Steps[2]}, run.GetReadySteps(), "should return the steps whose dependencies have completed")
+
+	run.StepStates[0].ReturnValue = "29"
+	assert.Empty(s.T(), run.GetReadySteps(), "should return no step")
+	assert.Equal(s.T(), WorkflowStepSkipped, run.StepStates[2].Status, "should skip the step whose condition is not met")
+	assert.Equal(s.T(), WorkflowStepSkipped, run.StepStates[3].Status, "should skip the steps depending on skipped steps")
+
+	run = newTestWorkflowRun()
+	run.StepStates[0].Status = WorkflowStepFailed
+	run.StepStates[1].Status = WorkflowStepCompleted
+	assert.Empty(s.T(), run.GetReadySteps(), "should return no step")
+	assert.Equal(s.T(), WorkflowStepSkipped, run.StepStates[2].Status, "should skip the steps depending on failed steps")
+}
+
+func (s *WorkflowRunTestSuite) TestResolveArguments() {
+	run := newTestWorkflowRun()
+	run.StepStates[0].ReturnValue = "31"
+	run.StepStates[1].StatusCode = 2
+
+	arguments, err := run.ResolveArguments(run.Steps[0])
+	assert.Equal(s.T(), []string{"celsius"}, arguments, "should resolve run inputs")
+	assert.Nil(s.T(), err, "should return no error")
+
+	arguments, err = run.ResolveArguments(run.Steps[2])
+	assert.Equal(s.T(), []string{"speed=31", "2"}, arguments, "should resolve response fields of earlier steps")
+	assert.Nil(s.T(), err, "should return no error")
+
+	run.Inputs = nil
+	_, err = run.ResolveArguments(run.Steps[0])
+	assert.Error(s.T(), err, "should error on missing input")
+	assert.Regexp(s.T(), "missing input", err.Error())
+}
+
+func (s *WorkflowRunTestSuite) TestNewRequest() {
+	run := newTestWorkflowRun()
+
+	request := run.NewRequest(run.Steps[2], []string{"speed=31", "0"}, "tx1")
+	assert.Equal(s.T(), Service{OrganizationId: "org1", DeviceId: "fan1", Name: "speed"}, request.Service, "should request the step service")
+	assert.Equal(s.T(), "SET", request.Method, "should request the step method")
+	assert.Equal(s.T(), []string{"speed=31", "0"}, request.Arguments, "should request with the arguments")
+	assert.Equal(s.T(), int64(60), request.Timeout, "should request with the step timeout")
+	assert.Equal(s.T(), "org2", request.RequesterOrganizationId, "should request on behalf of the run requester")
+	assert.Equal(s.T(), "app1", request.RequesterId, "should request on behalf of the run requester")
+	assert.Equal(s.T(), run.Id, request.WorkflowRunId, "should link the request to the run")
+	assert.Equal(s.T(), "actuate", request.WorkflowStep, "should link the request to the step")
+	assert.Equal(s.T(), request.Id, run.NewRequest(run.Steps[2], []string{}, "tx1").Id, "should derive the same request ID for the same step and transaction")
+	assert.NotEqual(s.T(), request.Id, run.NewRequest(run.Steps[3], []string{}, "tx1").Id, "should derive different request IDs for different steps")
+	assert.NotEqual(s.T(), request.Id, run.NewRequest(run.Steps[2], []string{}, "tx2").Id, "should derive different request IDs for different transactions")
+}
+
+func (s *WorkflowRunTestSuite) TestUpdateStatus() {
+	run := newTestWorkflowRun()
+
+	run.UpdateStatus()
+	assert.Equal(s.T(), WorkflowRunRunning, run.Status, "should be running if some steps have not finished")
+
+	run.StepStates[0].Status = WorkflowStepCompleted
+	run.StepStates[1].Status = WorkflowStepCompleted
+	run.StepStates[2].Status = WorkflowStepSkipped
+	run.StepStates[3].Status = WorkflowStepSkipped
+	run.UpdateStatus()
+	assert.Equal(s.T(), WorkflowRunCompleted, run.Status, "should complete if every step has completed or been skipped")
+
+	run.StepStates[1].Status = WorkflowStepFailed
+	run.UpdateStatus()
+	assert.Equal(s.T(), WorkflowRunFailed, run.Status, "should fail if some steps have failed")
+}
+
+func (s *WorkflowRunTestSuite) TestGetKeyComponents() {
+	run := &WorkflowRun{Id: "run1"}
+	assert.Equal(s.T(), []string{"run1"}, run.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *WorkflowRunTestSuite) TestSerialize() {
+	runTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	run := &WorkflowRun{
+		Id:                     "run1",
+		Time:                   runTime,
+		WorkflowOrganizationId: "org1",
+		WorkflowName:           "workflow1",
+		Status:                 WorkflowRunRunning,
+		StepStates:             []*WorkflowStepState{{Name: "read", Status: WorkflowStepRequested, RequestId: "request1"}},
+		LastUpdateTime:         runTime,
+	}
+	serialized := "{\"id\":\"run1\",\"time\":\"2021-12-12T17:34:00-05:00\",\"workflowOrganizationId\":\"org1\",\"workflowName\":\"workflow1\"," +
+		"\"status\":\"running\",\"stepStates\":[{\"name\":\"read\",\"status\":\"requested\",\"requestId\":\"request1\"}]," +
+		"\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := run.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeWorkflowRun(data)
+	assert.Equal(s.T(), run, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeWorkflowRun([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *WorkflowRunTestSuite) TestValidate() {
+	run := WorkflowRun{Id: "run1"}
+
+	assert.Error(s.T(), run.Validate(), "should error on invalid run ID")
+	assert.Regexp(s.T(), "run ID", run.Validate().Error())
+	run.Id = "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1"
+
+	assert.Error(s.T(), run.Validate(), "should error on empty workflow")
+	assert.Regexp(s.T(), "workflow", run.Validate().Error())
+	run.WorkflowOrganizationId, run.WorkflowName = "org1", "workflow1"
+
+	assert.Error(s.T(), run.Validate(), "should error on empty run time")
+	assert.Regexp(s.T(), "run time", run.Validate().Error())
+	run.Time = time.Now()
+
+	assert.Nil(s.T(), run.Validate(), "should return no error")
+}
+
+func TestWorkflowRunTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowRunTestSuite))
+}
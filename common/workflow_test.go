@@ -0,0 +1,147 @@
+package common
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkflowTestSuite struct {
+	suite.Suite
+}
+
+func (s *WorkflowTestSuite) TestEvaluateCondition() {
+	state := &WorkflowStepState{ReturnValue: "9.5", StatusCode: 0}
+
+	assert.True(s.T(), (&WorkflowCondition{Operator: "lt", Value: "10"}).Evaluate(state), "should compare numbers as numbers")
+	assert.False(s.T(), (&WorkflowCondition{Operator: "ge", Value: "10"}).Evaluate(state), "should compare numbers as numbers")
+	assert.True(s.T(), (&WorkflowCondition{Operator: "eq", Value: "9.50"}).Evaluate(state), "should compare numbers as numbers")
+	assert.True(s.T(), (&WorkflowCondition{Operator: "gt", Value: "10a"}).Evaluate(state), "should compare other values as strings")
+	assert.True(s.T(), (&WorkflowCondition{Field: "statusCode", Operator: "eq", Value: "0"}).Evaluate(state), "should compare status codes")
+	assert.False(s.T(), (&WorkflowCondition{Field: "statusCode", Operator: "ne", Value: "0"}).Evaluate(state), "should compare status codes")
+	assert.True(s.T(), (&WorkflowCondition{Operator: "le", Value: "9.5"}).Evaluate(state), "should compare numbers as numbers")
+}
+
+func (s *WorkflowTestSuite) TestGetKeyComponents() {
+	workflow := &Workflow{OrganizationId: "org1", Name: "workflow1"}
+	assert.Equal(s.T(), []string{"org1", "workflow1"}, workflow.GetKeyComponents(), "should return correct key components")
+}
+
+func (s *WorkflowTestSuite) TestSerialize() {
+	updateTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	workflow := &Workflow{
+		OrganizationId: "org1",
+		Name:           "workflow1",
+		OwnerId:        "device1",
+		Steps: []*WorkflowStep{
+			{Name: "read", OrganizationId: "org1", DeviceId: "sensor1", ServiceName: "temperature", Method: "GET", Arguments: []string{}},
+			{
+				Name: "actuate", OrganizationId: "org1", DeviceId: "fan1", ServiceName: "speed", Method: "SET", Arguments: []string{"${read.returnValue}"},
+				DependsOn: []string{"read"}, Condition: &WorkflowCondition{Step: "read", Operator: "gt", Value: "30"},
+			},
+		},
+		LastUpdateTime: updateTime,
+	}
+	serialized := "{\"organizationId\":\"org1\",\"name\":\"workflow1\",\"ownerId\":\"device1\",\"steps\":[" +
+		"{\"name\":\"read\",\"organizationId\":\"org1\",\"deviceId\":\"sensor1\",\"serviceName\":\"temperature\",\"method\":\"GET\",\"arguments\":[]}," +
+		"{\"name\":\"actuate\",\"organizationId\":\"org1\",\"deviceId\":\"fan1\",\"serviceName\":\"speed\",\"method\":\"SET\",\"arguments\":[\"${read.returnValue}\"]," +
+		"\"dependsOn\":[\"read\"],\"condition\":{\"step\":\"read\",\"operator\":\"gt\",\"value\":\"30\"}}]," +
+		"\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}"
+
+	data, err := workflow.Serialize()
+	assert.Equal(s.T(), serialized, string(data), "should serialize to JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deserialized, err := DeserializeWorkflow(data)
+	assert.Equal(s.T(), workflow, deserialized, "should deserialize from JSON")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = DeserializeWorkflow([]byte("[]"))
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *WorkflowTestSuite) TestValidate() {
+	workflow := Workflow{}
+
+	assert.Error(s.T(), workflow.Validate(), "should error on empty organization ID")
+	assert.Regexp(s.T(), "organization ID", workflow.Validate().Error())
+	workflow.OrganizationId = "org1"
+
+	assert.Error(s.T(), workflow.Validate(), "should error on empty name")
+	assert.Regexp(s.T(), "workflow name", workflow.Validate().Error())
+	workflow.Name = "workflow1"
+
+	assert.Error(s.T(), workflow.Validate(), "should error on empty steps")
+	assert.Regexp(s.T(), "steps", workflow.Validate().Error())
+
+	read := &WorkflowStep{Name: "input"}
+	workflow.Steps = []*WorkflowStep{read}
+	assert.Error(s.T(), workflow.Validate(), "should error on reserved step name")
+	assert.Regexp(s.T(), "step name", workflow.Validate().Error())
+	read.Name = "read"
+
+	assert.Error(s.T(), workflow.Validate(), "should error on incomplete requested service")
+	assert.Regexp(s.T(), "requested service", workflow.Validate().Error())
+	read.OrganizationId, read.DeviceId, read.ServiceName = "org1", "sensor1", "temperature"
+
+	assert.Error(s.T(), workflow.Validate(), "should error on empty method")
+	assert.Regexp(s.T(), "method", workflow.Validate().Error())
+	read.Method = "GET"
+
+	assert.Error(s.T(), workflow.Validate(), "should error on null arguments")
+	assert.Regexp(s.T(), "arguments", workflow.Validate().Error())
+	read.Arguments = []string{}
+
+	read.Timeout = -1
+	assert.Error(s.T(), workflow.Validate(), "should error on negative timeout")
+	assert.Regexp(s.T(), "timeout", workflow.Validate().Error())
+	read.Timeout = 0
+
+	actuate := &WorkflowStep{Name: "read", OrganizationId: "org1", DeviceId: "fan1", ServiceName: "speed", Method: "SET", Arguments: []string{}}
+	workflow.Steps = append(workflow.Steps, actuate)
+	assert.Error(s.T(), workflow.Validate(), "should error on duplicate step")
+	assert.Regexp(s.T(), "duplicate", workflow.Validate().Error())
+	actuate.Name = "actuate"
+
+	actuate.DependsOn = []string{"unknown"}
+	assert.Error(s.T(), workflow.Validate(), "should error on unknown dependency")
+	assert.Regexp(s.T(), "unknown step", workflow.Validate().Error())
+
+	actuate.DependsOn = []string{"read"}
+	read.DependsOn = []string{"actuate"}
+	assert.Error(s.T(), workflow.Validate(), "should error on dependency cycle")
+	assert.Regexp(s.T(), "cycle", workflow.Validate().Error())
+	read.DependsOn = nil
+
+	actuate.Condition = &WorkflowCondition{Step: "read", Operator: "between"}
+	assert.Error(s.T(), workflow.Validate(), "should error on unknown condition operator")
+	assert.Regexp(s.T(), "operator", workflow.Validate().Error())
+	actuate.Condition.Operator = "gt"
+
+	read.Condition = &WorkflowCondition{Step: "actuate", Operator: "gt"}
+	assert.Error(s.T(), workflow.Validate(), "should error on condition referring to a later step")
+	assert.Regexp(s.T(), "does not depend on", workflow.Validate().Error())
+	read.Condition = nil
+
+	read.Arguments = []string{"${actuate.returnValue}"}
+	assert.Error(s.T(), workflow.Validate(), "should error on argument referring to a later step")
+	assert.Regexp(s.T(), "does not depend on", workflow.Validate().Error())
+	read.Arguments = []string{"${input.unit}"}
+
+	actuate.Arguments = []string{"${read.time}"}
+	assert.Error(s.T(), workflow.Validate(), "should error on unknown response field")
+	assert.Regexp(s.T(), "response field", workflow.Validate().Error())
+	actuate.Arguments = []string{"speed=${read.returnValue}", "${read.statusCode}"}
+
+	assert.Error(s.T(), workflow.Validate(), "should error on empty last update time")
+	assert.Regexp(s.T(), "last update time", workflow.Validate().Error())
+	workflow.LastUpdateTime = time.Now()
+
+	assert.Nil(s.T(), workflow.Validate(), "should return no error")
+}
+
+func TestWorkflowTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowTestSuite))
+}
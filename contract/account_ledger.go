@@ -0,0 +1,198 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// AccountLedgerInterface core utilities for managing token accounts of organizations on the ledger
+type AccountLedgerInterface interface {
+	// Get return the account of an organization, which is empty if the organization has never been credited
+	Get(organizationId string) (*common.Account, error)
+
+	// Deposit add tokens to the account of an organization
+	Deposit(organizationId string, amount int64) (*common.Account, error)
+
+	// Escrow hold the price of an IoT service request from the account of the requester's organization
+	Escrow(request *common.ServiceRequest) error
+
+	// Release pay the escrowed price of an IoT service request to the organization of the requested device
+	Release(request *common.ServiceRequest) error
+
+	// Refund return the escrowed price of an IoT service request to the account of the requester's organization
+	Refund(request *common.ServiceRequest) error
+
+	// GetStatement return the changes made to the account of an organization in chronological order
+	GetStatement(organizationId string) ([]*common.AccountEntry, error)
+}
+
+// AccountLedger core utilities for managing token accounts of organizations on the ledger
+type AccountLedger struct {
+	ctx             TransactionContextInterface
+	accountRegistry StateRegistryInterface
+	entryRegistry   StateRegistryInterface
+
+	// accounts accounts updated by the transaction, kept because the ledger does not read its own writes
+	accounts map[string]*common.Account
+}
+
+// Get return the account of an organization, which is empty if the organization has never been credited
+func (l *AccountLedger) Get(organizationId string) (*common.Account, error) {
+	if account, ok := l.accounts[organizationId]; ok {
+		return account, nil
+	}
+
+	account, err := l.accountRegistry.GetState(organizationId)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return &common.Account{OrganizationId: organizationId}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return account.(*common.Account), nil
+}
+
+// Deposit add tokens to the account of an organization
+func (l *AccountLedger) Deposit(organizationId string, amount int64) (*common.Account, error) {
+	if amount <= 0 {
+		return nil, fmt.Errorf("deposit amount must be a positive integer")
+	}
+
+	account, err := l.Get(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	account.Balance += amount
+	if err = l.record(account, common.AccountDeposit, amount, ""); err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// Escrow hold the price of an IoT service request from the account of the requester's organization
+func (l *AccountLedger) Escrow(request *common.ServiceRequest) error {
+	account, err := l.Get(request.RequesterOrganizationId)
+	if err != nil {
+		return err
+	}
+	if account.Balance < request.Price {
+		return fmt.Errorf("insufficient balance to pay %d tokens for the request", request.Price)
+	}
+
+	account.Balance -= request.Price
+	account.Escrow += request.Price
+	return l.record(account, common.AccountEscrow, request.Price, request.Id)
+}
+
+// Release pay the escrowed price of an IoT service request to the organization of the requested device
+func (l *AccountLedger) Release(request *common.ServiceRequest) error {
+	payer, err := l.Get(request.RequesterOrganizationId)
+	if err != nil {
+		return err
+	}
+	if payer.Escrow < request.Price {
+		return fmt.Errorf("escrowed tokens are less than the price of the request")
+	}
+
+	// reuse the payer's account when it pays itself because the ledger does not read its own writes
+	payee := payer
+	if request.Service.OrganizationId != payer.OrganizationId {
+		if payee, err = l.Get(request.Service.OrganizationId); err != nil {
+			return err
+		}
+	}
+
+	payer.Escrow -= request.Price
+	if err = l.record(payer, common.AccountPayment, request.Price, request.Id); err != nil {
+		return err
+	}
+
+	payee.Balance += request.Price
+	return l.record(payee, common.AccountIncome, request.Price, request.Id)
+}
+
+// Refund return the escrowed price of an IoT service request to the account of the requester's organization
+func (l *AccountLedger) Refund(request *common.ServiceRequest) error {
+	account, err := l.Get(request.RequesterOrganizationId)
+	if err != nil {
+		return err
+	}
+	if account.Escrow < request.Price {
+		return fmt.Errorf("escrowed tokens are less than the price of the request")
+	}
+
+	account.Escrow -= request.Price
+	account.Balance += request.Price
+	return l.record(account, common.AccountRefund, request.Price, request.Id)
+}
+
+// GetStatement return the changes made to the account of an organization in chronological order
+func (l *AccountLedger) GetStatement(organizationId string) ([]*common.AccountEntry, error) {
+	states, err := l.entryRegistry.GetStates(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*common.AccountEntry, 0)
+	for _, state := range states {
+		entries = append(entries, state.(*common.AccountEntry))
+	}
+
+	return entries, nil
+}
+
+// record save an updated account along with an entry describing the change
+func (l *AccountLedger) record(account *common.Account, kind common.AccountEntryKind, amount int64, requestId string) error {
+	now, err := l.ctx.GetTimestamp()
+	if err != nil {
+		return err
+	}
+
+	account.LastUpdateTime = now
+	if err = l.accountRegistry.PutState(account); err != nil {
+		return err
+	}
+
+	if l.accounts == nil {
+		l.accounts = make(map[string]*common.Account)
+	}
+	l.accounts[account.OrganizationId] = account
+
+	return l.entryRegistry.PutState(
+		&common.AccountEntry{
+			OrganizationId: account.OrganizationId,
+			TransactionId:  l.ctx.GetStub().GetTxID(),
+			Kind:           kind,
+			Amount:         amount,
+			Balance:        account.Balance,
+			RequestId:      requestId,
+			Time:           now,
+		},
+	)
+}
+
+func createAccountLedger(ctx TransactionContextInterface) *AccountLedger {
+	accountRegistry := new(StateRegistry)
+	accountRegistry.ctx = ctx
+	accountRegistry.Name = "accounts"
+	accountRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeAccount(data)
+	}
+
+	entryRegistry := new(StateRegistry)
+	entryRegistry.ctx = ctx
+	entryRegistry.Name = "account_entries"
+	entryRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeAccountEntry(data)
+	}
+
+	ledger := new(AccountLedger)
+	ledger.ctx = ctx
+	ledger.accountRegistry = accountRegistry
+	ledger.entryRegistry = entryRegistry
+
+	return ledger
+}
@@ -0,0 +1,63 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// TreasuryOrganizationIds identities of the organizations whose administrators can deposit tokens into accounts,
+// no one can deposit tokens if it is empty
+var TreasuryOrganizationIds []string
+
+// AccountLedgerSmartContract smart contract for managing token accounts of organizations
+type AccountLedgerSmartContract struct {
+	contractapi.Contract
+}
+
+// Deposit add tokens to the account of an organization
+func (s *AccountLedgerSmartContract) Deposit(ctx TransactionContextInterface, organizationId string, amount int64) error {
+	// only administrators of treasury organizations can create tokens
+	if ok, err := isTreasuryAdmin(ctx); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("cannot deposit tokens from a client other than a treasury administrator")
+	}
+
+	account, err := ctx.GetAccountLedger().Deposit(organizationId, amount)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := account.Serialize()
+		err = ctx.SetEvent(newAccountEvent(organizationId, "deposit", payload))
+	}
+
+	return err
+}
+
+// Get return the account of an organization
+func (s *AccountLedgerSmartContract) Get(ctx TransactionContextInterface, organizationId string) (*common.Account, error) {
+	return ctx.GetAccountLedger().Get(organizationId)
+}
+
+// GetStatement return the changes made to the account of an organization in chronological order
+func (s *AccountLedgerSmartContract) GetStatement(ctx TransactionContextInterface, organizationId string) ([]*common.AccountEntry, error) {
+	return ctx.GetAccountLedger().GetStatement(organizationId)
+}
+
+// isTreasuryAdmin check if the invoking identity is an administrator of a treasury organization
+func isTreasuryAdmin(ctx TransactionContextInterface) (bool, error) {
+	organizationId, err := ctx.GetOrganizationId()
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range TreasuryOrganizationIds {
+		if id == organizationId {
+			return ctx.IsOrganizationAdmin()
+		}
+	}
+
+	return false, nil
+}
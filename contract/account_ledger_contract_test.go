@@ -0,0 +1,73 @@
+package contract
+
+import (
+	"testing"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccountLedgerContractTestSuite struct {
+	suite.Suite
+}
+
+func (s *AccountLedgerContractTestSuite) TestDeposit() {
+	TreasuryOrganizationIds = []string{"org0"}
+	defer func() { TreasuryOrganizationIds = nil }()
+
+	ctx := &MockTransactionContext{DeviceId: "admin0", OrganizationId: "org0", IsAdmin: true}
+	accountLedger := new(MockAccountLedger)
+	ctx.accountLedger = accountLedger
+
+	accountLedger.On("Deposit", "org1", int64(10)).Return(&common.Account{OrganizationId: "org1", Balance: 10}, nil)
+
+	contract := new(AccountLedgerSmartContract)
+	err := contract.Deposit(ctx, "org1", 10)
+	assert.Nil(s.T(), err, "should return no error")
+	account, _ := common.DeserializeAccount(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "account://org1/deposit", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), int64(10), account.Balance, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	ctx.IsAdmin = false
+	err = contract.Deposit(ctx, "org1", 10)
+	assert.Error(s.T(), err, "should refuse deposit from a non-administrator")
+
+	ctx.IsAdmin = true
+	ctx.OrganizationId = "org1"
+	err = contract.Deposit(ctx, "org1", 10)
+	assert.Error(s.T(), err, "should refuse deposit from an administrator of another organization")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+	accountLedger.AssertNumberOfCalls(s.T(), "Deposit", 1)
+}
+
+func (s *AccountLedgerContractTestSuite) TestGet() {
+	ctx := new(MockTransactionContext)
+	accountLedger := new(MockAccountLedger)
+	ctx.accountLedger = accountLedger
+
+	accountLedger.On("Get", "org1").Return(new(common.Account), nil)
+
+	contract := new(AccountLedgerSmartContract)
+	_, _ = contract.Get(ctx, "org1")
+	called := accountLedger.AssertCalled(s.T(), "Get", "org1")
+	assert.True(s.T(), called, "should retrieve account from account ledger")
+}
+
+func (s *AccountLedgerContractTestSuite) TestGetStatement() {
+	ctx := new(MockTransactionContext)
+	accountLedger := new(MockAccountLedger)
+	ctx.accountLedger = accountLedger
+
+	accountLedger.On("GetStatement", "org1").Return([]*common.AccountEntry{}, nil)
+
+	contract := new(AccountLedgerSmartContract)
+	_, _ = contract.GetStatement(ctx, "org1")
+	called := accountLedger.AssertCalled(s.T(), "GetStatement", "org1")
+	assert.True(s.T(), called, "should retrieve statement from account ledger")
+}
+
+func TestAccountLedgerContractTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountLedgerContractTestSuite))
+}
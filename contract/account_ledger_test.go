@@ -0,0 +1,200 @@
+package contract
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockAccountLedger struct {
+	mock.Mock
+}
+
+func (l *MockAccountLedger) Get(organizationId string) (*common.Account, error) {
+	args := l.Called(organizationId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.Account), args.Error(1)
+}
+
+func (l *MockAccountLedger) Deposit(organizationId string, amount int64) (*common.Account, error) {
+	args := l.Called(organizationId, amount)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.Account), args.Error(1)
+}
+
+func (l *MockAccountLedger) Escrow(request *common.ServiceRequest) error {
+	args := l.Called(request)
+	return args.Error(0)
+}
+
+func (l *MockAccountLedger) Release(request *common.ServiceRequest) error {
+	args := l.Called(request)
+	return args.Error(0)
+}
+
+func (l *MockAccountLedger) Refund(request *common.ServiceRequest) error {
+	args := l.Called(request)
+	return args.Error(0)
+}
+
+func (l *MockAccountLedger) GetStatement(organizationId string) ([]*common.AccountEntry, error) {
+	args := l.Called(organizationId)
+	return args.Get(0).([]*common.AccountEntry), args.Error(1)
+}
+
+type AccountLedgerTestSuite struct {
+	suite.Suite
+	now             time.Time
+	accountRegistry *MockStateRegistry
+	entryRegistry   *MockStateRegistry
+	ledger          *AccountLedger
+}
+
+func (s *AccountLedgerTestSuite) SetupTest() {
+	s.now, _ = time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	s.accountRegistry = new(MockStateRegistry)
+	s.entryRegistry = new(MockStateRegistry)
+
+	transactionContext := &MockTransactionContext{Timestamp: s.now}
+	transactionContext.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+
+	s.ledger = new(AccountLedger)
+	s.ledger.ctx = transactionContext
+	s.ledger.accountRegistry = s.accountRegistry
+	s.ledger.entryRegistry = s.entryRegistry
+
+	s.accountRegistry.On("PutState", mock.Anything).Return(nil)
+	s.entryRegistry.On("PutState", mock.Anything).Return(nil)
+}
+
+func (s *AccountLedgerTestSuite) entry(index int) *common.AccountEntry {
+	return s.entryRegistry.Calls[index].Arguments[0].(*common.AccountEntry)
+}
+
+func (s *AccountLedgerTestSuite) TestGet() {
+	account := &common.Account{OrganizationId: "org1", Balance: 10}
+	s.accountRegistry.On("GetState", []string{"org1"}).Return(account, nil)
+	s.accountRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	result, err := s.ledger.Get("org1")
+	assert.Equal(s.T(), account, result, "should return the account")
+	assert.Nil(s.T(), err, "should return no error")
+
+	result, err = s.ledger.Get("org2")
+	assert.Equal(s.T(), &common.Account{OrganizationId: "org2"}, result, "should return an empty account")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *AccountLedgerTestSuite) TestDeposit() {
+	s.accountRegistry.On("GetState", mock.Anything).Return(&common.Account{OrganizationId: "org1", Balance: 10}, nil)
+
+	account, err := s.ledger.Deposit("org1", 5)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(15), account.Balance, "should add tokens to balance")
+	assert.True(s.T(), s.now.Equal(account.LastUpdateTime), "should update the account time")
+	assert.Equal(s.T(), common.AccountDeposit, s.entry(0).Kind, "should record a deposit entry")
+	assert.Equal(s.T(), "tx1", s.entry(0).TransactionId, "should record the transaction ID")
+	assert.Equal(s.T(), int64(15), s.entry(0).Balance, "should record the balance after the change")
+
+	_, err = s.ledger.Deposit("org1", 0)
+	assert.Error(s.T(), err, "should refuse non-positive amount")
+}
+
+func (s *AccountLedgerTestSuite) TestEscrow() {
+	account := &common.Account{OrganizationId: "org2", Balance: 10}
+	s.accountRegistry.On("GetState", []string{"org2"}).Return(account, nil)
+
+	request := &common.ServiceRequest{Id: "request1", RequesterOrganizationId: "org2", Price: 4}
+	err := s.ledger.Escrow(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(6), account.Balance, "should take price from balance")
+	assert.Equal(s.T(), int64(4), account.Escrow, "should hold price in escrow")
+	assert.Equal(s.T(), common.AccountEscrow, s.entry(0).Kind, "should record an escrow entry")
+	assert.Equal(s.T(), "request1", s.entry(0).RequestId, "should record the request ID")
+
+	request.Price = 7
+	err = s.ledger.Escrow(request)
+	assert.Error(s.T(), err, "should refuse to escrow more than the balance")
+	s.entryRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *AccountLedgerTestSuite) TestEscrowSameTransaction() {
+	s.accountRegistry.On("GetState", []string{"org2"}).Return(&common.Account{OrganizationId: "org2", Balance: 10}, nil).Once()
+	s.accountRegistry.On("GetState", []string{"org2"}).Return(&common.Account{OrganizationId: "org2", Balance: 10}, nil).Once()
+
+	_ = s.ledger.Escrow(&common.ServiceRequest{Id: "request1", RequesterOrganizationId: "org2", Price: 4})
+	_ = s.ledger.Escrow(&common.ServiceRequest{Id: "request2", RequesterOrganizationId: "org2", Price: 4})
+	account, _ := s.ledger.Get("org2")
+	assert.Equal(s.T(), int64(2), account.Balance, "should take prices of all requests of the transaction from balance")
+	assert.Equal(s.T(), int64(8), account.Escrow, "should hold prices of all requests of the transaction in escrow")
+	s.accountRegistry.AssertNumberOfCalls(s.T(), "GetState", 1)
+}
+
+func (s *AccountLedgerTestSuite) TestRelease() {
+	payer := &common.Account{OrganizationId: "org2", Balance: 6, Escrow: 4}
+	payee := &common.Account{OrganizationId: "org1", Balance: 1}
+	s.accountRegistry.On("GetState", []string{"org2"}).Return(payer, nil)
+	s.accountRegistry.On("GetState", []string{"org1"}).Return(payee, nil)
+
+	request := &common.ServiceRequest{
+		Id:                      "request1",
+		Service:                 common.Service{OrganizationId: "org1"},
+		RequesterOrganizationId: "org2",
+		Price:                   4,
+	}
+	err := s.ledger.Release(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(0), payer.Escrow, "should take price from escrow")
+	assert.Equal(s.T(), int64(6), payer.Balance, "should not change payer balance")
+	assert.Equal(s.T(), int64(5), payee.Balance, "should pay price to the device organization")
+	assert.Equal(s.T(), common.AccountPayment, s.entry(0).Kind, "should record a payment entry")
+	assert.Equal(s.T(), common.AccountIncome, s.entry(1).Kind, "should record an income entry")
+
+	err = s.ledger.Release(request)
+	assert.Error(s.T(), err, "should refuse to release more than escrowed")
+
+	payer.Escrow = 4
+	request.Service.OrganizationId = "org2"
+	err = s.ledger.Release(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(10), payer.Balance, "should pay price back to the same organization")
+	assert.Equal(s.T(), int64(0), payer.Escrow, "should take price from escrow")
+}
+
+func (s *AccountLedgerTestSuite) TestRefund() {
+	account := &common.Account{OrganizationId: "org2", Balance: 6, Escrow: 4}
+	s.accountRegistry.On("GetState", []string{"org2"}).Return(account, nil)
+
+	request := &common.ServiceRequest{Id: "request1", RequesterOrganizationId: "org2", Price: 4}
+	err := s.ledger.Refund(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(10), account.Balance, "should return price to balance")
+	assert.Equal(s.T(), int64(0), account.Escrow, "should take price from escrow")
+	assert.Equal(s.T(), common.AccountRefund, s.entry(0).Kind, "should record a refund entry")
+
+	err = s.ledger.Refund(request)
+	assert.Error(s.T(), err, "should refuse to refund more than escrowed")
+}
+
+func (s *AccountLedgerTestSuite) TestGetStatement() {
+	entries := []StateInterface{&common.AccountEntry{Kind: common.AccountDeposit}, &common.AccountEntry{Kind: common.AccountEscrow}}
+	s.entryRegistry.On("GetStates", []string{"org1"}).Return(entries, nil)
+
+	result, err := s.ledger.GetStatement("org1")
+	assert.Len(s.T(), result, 2, "should return all entries")
+	assert.Equal(s.T(), common.AccountEscrow, result[1].Kind, "should keep the order of entries")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func TestAccountLedgerTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountLedgerTestSuite))
+}
@@ -0,0 +1,24 @@
+package contract
+
+import (
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// AttributePolicies certificate attribute policies of smart contract functions, keyed by function name
+type AttributePolicies map[string]common.AttributePolicy
+
+// Before check if the calling client satisfies the attribute policy of the invoked function,
+// to be used as a transaction hook
+func (p AttributePolicies) Before(ctx TransactionContextInterface) error {
+	policy, ok := p[getFunctionName(ctx)]
+	if !ok {
+		return nil
+	}
+
+	return policy.Check(ctx.GetClientIdentity().GetAttributeValue)
+}
+
+// After do nothing
+func (p AttributePolicies) After(ctx TransactionContextInterface, result interface{}) error {
+	return nil
+}
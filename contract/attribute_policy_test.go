@@ -0,0 +1,46 @@
+package contract
+
+import (
+	"testing"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AttributePoliciesTestSuite struct {
+	suite.Suite
+}
+
+func (s *AttributePoliciesTestSuite) TestBefore() {
+	ctx := new(MockTransactionContext)
+	ctx.identity = &mockClientIdentity{Attributes: map[string]string{"role": "operator"}}
+	ctx.stub = new(mockChaincodeStub)
+
+	policies := AttributePolicies{
+		"Register":   common.AttributePolicy{"role": {"operator"}},
+		"Deregister": common.AttributePolicy{"role": {"admin"}},
+	}
+
+	ctx.stub.Function = "device_registry:Register"
+	assert.Nil(s.T(), policies.Before(ctx), "should allow clients satisfying the function policy")
+
+	ctx.stub.Function = "device_registry:Deregister"
+	assert.IsType(s.T(), new(common.AccessDeniedError), policies.Before(ctx), "should deny clients not satisfying the function policy")
+
+	ctx.stub.Function = "Deregister"
+	assert.IsType(s.T(), new(common.AccessDeniedError), policies.Before(ctx), "should apply policy to functions of the default contract")
+
+	ctx.stub.Function = "device_registry:Get"
+	assert.Nil(s.T(), policies.Before(ctx), "should allow functions without policy")
+
+	assert.Nil(s.T(), policies.After(ctx, nil), "should return no error")
+
+	var empty AttributePolicies
+	ctx.stub.Function = "device_registry:Deregister"
+	assert.Nil(s.T(), empty.Before(ctx), "should allow every function without policies")
+}
+
+func TestAttributePoliciesTestSuite(t *testing.T) {
+	suite.Run(t, new(AttributePoliciesTestSuite))
+}
@@ -0,0 +1,110 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// DeviceGroupRegistryInterface core utilities for managing device groups on the ledger
+type DeviceGroupRegistryInterface interface {
+	// Register create or update a device group in the ledger
+	Register(group *common.DeviceGroup) error
+
+	// Get return a device group by its organization ID and name
+	Get(organizationId string, name string) (*common.DeviceGroup, error)
+
+	// GetAll return a list of device groups by their organization ID
+	GetAll(organizationId string) ([]*common.DeviceGroup, error)
+
+	// Deregister remove a device group from the ledger
+	Deregister(group *common.DeviceGroup) error
+
+	// AddMember add a registered device to a device group
+	AddMember(group *common.DeviceGroup, organizationId string, deviceId string) error
+
+	// RemoveMember remove a member device from a device group
+	RemoveMember(group *common.DeviceGroup, organizationId string, deviceId string) error
+}
+
+// DeviceGroupRegistry core utilities for managing device groups on the ledger
+type DeviceGroupRegistry struct {
+	ctx           TransactionContextInterface
+	stateRegistry StateRegistryInterface
+}
+
+// Register create or update a device group in the ledger
+func (r *DeviceGroupRegistry) Register(group *common.DeviceGroup) error {
+	return r.stateRegistry.PutState(group)
+}
+
+// Get return a device group by its organization ID and name
+func (r *DeviceGroupRegistry) Get(organizationId string, name string) (*common.DeviceGroup, error) {
+	state, err := r.stateRegistry.GetState(organizationId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.DeviceGroup), nil
+}
+
+// GetAll return a list of device groups by their organization ID
+func (r *DeviceGroupRegistry) GetAll(organizationId string) ([]*common.DeviceGroup, error) {
+	states, err := r.stateRegistry.GetStates(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*common.DeviceGroup, 0)
+	for _, state := range states {
+		groups = append(groups, state.(*common.DeviceGroup))
+	}
+
+	return groups, err
+}
+
+// Deregister remove a device group from the ledger
+func (r *DeviceGroupRegistry) Deregister(group *common.DeviceGroup) error {
+	return r.stateRegistry.RemoveState(group)
+}
+
+// AddMember add a registered device to a device group
+func (r *DeviceGroupRegistry) AddMember(group *common.DeviceGroup, organizationId string, deviceId string) error {
+	// check if device exists
+	if _, err := r.ctx.GetDeviceRegistry().Get(organizationId, deviceId); err != nil {
+		return err
+	}
+
+	if group.IndexOfMember(organizationId, deviceId) >= 0 {
+		return fmt.Errorf("device %s of organization %s is already a member of the group", deviceId, organizationId)
+	}
+
+	group.Members = append(group.Members, &common.DeviceGroupMember{OrganizationId: organizationId, DeviceId: deviceId})
+	return r.stateRegistry.PutState(group)
+}
+
+// RemoveMember remove a member device from a device group
+func (r *DeviceGroupRegistry) RemoveMember(group *common.DeviceGroup, organizationId string, deviceId string) error {
+	i := group.IndexOfMember(organizationId, deviceId)
+	if i < 0 {
+		return &common.NotFoundError{What: fmt.Sprintf("member %s/%s", organizationId, deviceId)}
+	}
+
+	group.Members = append(group.Members[:i], group.Members[i+1:]...)
+	return r.stateRegistry.PutState(group)
+}
+
+func createDeviceGroupRegistry(ctx TransactionContextInterface) *DeviceGroupRegistry {
+	stateRegistry := new(StateRegistry)
+	stateRegistry.ctx = ctx
+	stateRegistry.Name = "device_groups"
+	stateRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeDeviceGroup(data)
+	}
+
+	registry := new(DeviceGroupRegistry)
+	registry.ctx = ctx
+	registry.stateRegistry = stateRegistry
+
+	return registry
+}
@@ -0,0 +1,185 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// DeviceGroupRegistrySmartContract smart contract for managing device groups on the ledger
+type DeviceGroupRegistrySmartContract struct {
+	contractapi.Contract
+}
+
+// Register create or update a device group in the ledger
+func (s *DeviceGroupRegistrySmartContract) Register(ctx TransactionContextInterface, data string) error {
+	var err error
+	var organizationId, deviceId string
+
+	group, err := common.DeserializeDeviceGroup([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if deviceId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetDeviceGroupRegistry().Get(group.OrganizationId, group.Name)
+	if err == nil {
+		// only the group owner or its organization administrators can update the group, which keeps its owner and members
+		if err := checkDeviceManager(ctx, existing.OrganizationId, existing.OwnerId, "cannot update a device group other than one owned by the client"); err != nil {
+			return err
+		}
+		group.OwnerId, group.Members = existing.OwnerId, existing.Members
+	} else if _, ok := err.(*common.NotFoundError); ok {
+		// clients can only create groups in their own organization, which they own
+		if group.OrganizationId != organizationId {
+			return fmt.Errorf("cannot create a device group of an organization other than the client's")
+		}
+		group.OwnerId, group.Members = deviceId, nil
+	} else {
+		return err
+	}
+
+	if group.LastUpdateTime, err = getTrustedTime(ctx, group.LastUpdateTime); err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceGroupRegistry().Register(group)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := group.Serialize()
+		err = ctx.SetEvent(newGroupEvent(group.OrganizationId, group.Name, "register", payload))
+	}
+
+	return err
+}
+
+// Get return a device group by its organization ID and name
+func (s *DeviceGroupRegistrySmartContract) Get(ctx TransactionContextInterface, organizationId string, name string) (*common.DeviceGroup, error) {
+	return ctx.GetDeviceGroupRegistry().Get(organizationId, name)
+}
+
+// GetAll return a list of device groups by their organization ID
+func (s *DeviceGroupRegistrySmartContract) GetAll(ctx TransactionContextInterface, organizationId string) ([]*common.DeviceGroup, error) {
+	return ctx.GetDeviceGroupRegistry().GetAll(organizationId)
+}
+
+// Deregister remove a device group from the ledger
+func (s *DeviceGroupRegistrySmartContract) Deregister(ctx TransactionContextInterface, organizationId string, name string) error {
+	group, err := ctx.GetDeviceGroupRegistry().Get(organizationId, name)
+	if err != nil {
+		return err
+	}
+
+	// only the group owner or its organization administrators can deregister the group
+	if err := checkDeviceManager(ctx, group.OrganizationId, group.OwnerId, "cannot deregister a device group other than one owned by the client"); err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceGroupRegistry().Deregister(group)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := group.Serialize()
+		err = ctx.SetEvent(newGroupEvent(group.OrganizationId, group.Name, "deregister", payload))
+	}
+
+	return err
+}
+
+// Join add the calling device to a device group whose membership policy allows it
+func (s *DeviceGroupRegistrySmartContract) Join(ctx TransactionContextInterface, organizationId string, name string) error {
+	var err error
+	var memberOrganizationId, memberId string
+
+	group, err := ctx.GetDeviceGroupRegistry().Get(organizationId, name)
+	if err != nil {
+		return err
+	}
+
+	if memberOrganizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if memberId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	if !group.CanJoin(memberOrganizationId) {
+		return fmt.Errorf("cannot join a device group whose membership policy does not admit the client")
+	}
+
+	return s.addMember(ctx, group, memberOrganizationId, memberId)
+}
+
+// AddMember add a registered device to a device group as the group owner
+func (s *DeviceGroupRegistrySmartContract) AddMember(ctx TransactionContextInterface, organizationId string, name string, memberOrganizationId string, memberId string) error {
+	group, err := ctx.GetDeviceGroupRegistry().Get(organizationId, name)
+	if err != nil {
+		return err
+	}
+
+	// only the group owner or its organization administrators can add members
+	if err := checkDeviceManager(ctx, group.OrganizationId, group.OwnerId, "cannot add members to a device group other than one owned by the client"); err != nil {
+		return err
+	}
+
+	return s.addMember(ctx, group, memberOrganizationId, memberId)
+}
+
+func (s *DeviceGroupRegistrySmartContract) addMember(ctx TransactionContextInterface, group *common.DeviceGroup, memberOrganizationId string, memberId string) error {
+	var err error
+
+	if group.LastUpdateTime, err = ctx.GetTimestamp(); err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceGroupRegistry().AddMember(group, memberOrganizationId, memberId)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := group.Serialize()
+		err = ctx.SetEvent(newGroupEvent(group.OrganizationId, group.Name, "join", payload))
+	}
+
+	return err
+}
+
+// RemoveMember remove a member device from a device group as the group owner or the member itself
+func (s *DeviceGroupRegistrySmartContract) RemoveMember(ctx TransactionContextInterface, organizationId string, name string, memberOrganizationId string, memberId string) error {
+	group, err := ctx.GetDeviceGroupRegistry().Get(organizationId, name)
+	if err != nil {
+		return err
+	}
+
+	// the group owner, its organization administrators, and the member device or its administrators can remove a member
+	if ok, err := canManageDevice(ctx, group.OrganizationId, group.OwnerId); err != nil {
+		return err
+	} else if !ok {
+		if ok, err = canManageDevice(ctx, memberOrganizationId, memberId); err != nil {
+			return err
+		} else if !ok {
+			return fmt.Errorf("cannot remove a member other than the client from a device group not owned by the client")
+		}
+	}
+
+	if group.LastUpdateTime, err = ctx.GetTimestamp(); err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceGroupRegistry().RemoveMember(group, memberOrganizationId, memberId)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := group.Serialize()
+		err = ctx.SetEvent(newGroupEvent(group.OrganizationId, group.Name, "leave", payload))
+	}
+
+	return err
+}
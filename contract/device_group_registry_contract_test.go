@@ -0,0 +1,190 @@
+package contract
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceGroupRegistryContractTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestRegister() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:37:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	existing := &common.DeviceGroup{OrganizationId: "org1", Name: "group2", OwnerId: "device2", Members: []*common.DeviceGroupMember{{OrganizationId: "org1", DeviceId: "device3"}}}
+	groupRegistry.On("Get", "org1", "group2").Return(existing, nil)
+	groupRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	groupRegistry.On("Register", mock.AnythingOfType("*common.DeviceGroup")).Return(nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	err := contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"group1\",\"ownerId\":\"device9\",\"policy\":\"open\",\"members\":[{\"organizationId\":\"org2\",\"deviceId\":\"device2\"}]}")
+	assert.Nil(s.T(), err, "should return no error")
+	group, _ := common.DeserializeDeviceGroup(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "group://org1/group1/register", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), "device1", group.OwnerId, "should record the client as the group owner")
+	assert.Empty(s.T(), group.Members, "should create group without members")
+	assert.True(s.T(), now.Equal(group.LastUpdateTime), "should record transaction time")
+	ctx.stub.ResetEvent()
+
+	err = contract.Register(ctx, "{\"organizationId\":\"org2\",\"name\":\"group1\"}")
+	assert.Error(s.T(), err, "should refuse group of another organization")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"group2\",\"description\":\"updated\"}")
+	assert.Error(s.T(), err, "should refuse update by a client other than the owner")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.IsAdmin = true
+	err = contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"group2\",\"description\":\"updated\"}")
+	assert.Nil(s.T(), err, "should allow update by organization administrators")
+	group, _ = common.DeserializeDeviceGroup(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "updated", group.Description, "should update the group")
+	assert.Equal(s.T(), "device2", group.OwnerId, "should keep the group owner")
+	assert.Equal(s.T(), existing.Members, group.Members, "should keep the group members")
+	ctx.stub.ResetEvent()
+
+	err = contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"group1\",\"lastUpdateTime\":\"2021-12-12T17:20:00-05:00\"}")
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+
+	err = contract.Register(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestGet() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	groupRegistry.On("Get", "org1", "group1").Return(new(common.DeviceGroup), nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	_, _ = contract.Get(ctx, "org1", "group1")
+	called := groupRegistry.AssertCalled(s.T(), "Get", "org1", "group1")
+	assert.True(s.T(), called, "should retrieve device group from device group registry")
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestGetAll() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	groupRegistry.On("GetAll", "org1").Return([]*common.DeviceGroup{{}, {}}, nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	_, _ = contract.GetAll(ctx, "org1")
+	called := groupRegistry.AssertCalled(s.T(), "GetAll", "org1")
+	assert.True(s.T(), called, "should retrieve device groups from device group registry")
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestDeregister() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1", OwnerId: "device1"}
+	groupRegistry.On("Get", "org1", "group1").Return(group, nil)
+	groupRegistry.On("Get", "org1", "group2").Return(&common.DeviceGroup{OrganizationId: "org1", Name: "group2", OwnerId: "device2"}, nil)
+	groupRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	groupRegistry.On("Deregister", group).Return(nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	err := contract.Deregister(ctx, "org1", "group1")
+	assert.Nil(s.T(), err, "should return no error")
+	groupRegistry.AssertCalled(s.T(), "Deregister", group)
+	assert.Equal(s.T(), "group://org1/group1/deregister", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.Deregister(ctx, "org1", "group2")
+	assert.Error(s.T(), err, "should refuse group owned by another client")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Deregister(ctx, "org1", "group3")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestJoin() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:37:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2", Timestamp: now}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	open := &common.DeviceGroup{OrganizationId: "org1", Name: "group1", OwnerId: "device1", Policy: common.DeviceGroupOpen}
+	closed := &common.DeviceGroup{OrganizationId: "org1", Name: "group2", OwnerId: "device1", Policy: common.DeviceGroupOrganization}
+	groupRegistry.On("Get", "org1", "group1").Return(open, nil)
+	groupRegistry.On("Get", "org1", "group2").Return(closed, nil)
+	groupRegistry.On("AddMember", open, "org2", "device2").Return(nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	err := contract.Join(ctx, "org1", "group1")
+	assert.Nil(s.T(), err, "should return no error")
+	groupRegistry.AssertCalled(s.T(), "AddMember", open, "org2", "device2")
+	assert.Equal(s.T(), "group://org1/group1/join", ctx.stub.EventName, "should emit event with name")
+	assert.True(s.T(), now.Equal(open.LastUpdateTime), "should record transaction time")
+	ctx.stub.ResetEvent()
+
+	err = contract.Join(ctx, "org1", "group2")
+	assert.Error(s.T(), err, "should refuse group whose policy does not admit the client")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestAddMember() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1", OwnerId: "device1"}
+	groupRegistry.On("Get", "org1", "group1").Return(group, nil)
+	groupRegistry.On("AddMember", group, "org2", "device2").Return(nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	err := contract.AddMember(ctx, "org1", "group1", "org2", "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	groupRegistry.AssertCalled(s.T(), "AddMember", group, "org2", "device2")
+	assert.Equal(s.T(), "group://org1/group1/join", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	ctx.DeviceId = "device3"
+	err = contract.AddMember(ctx, "org1", "group1", "org2", "device2")
+	assert.Error(s.T(), err, "should refuse client other than the group owner")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceGroupRegistryContractTestSuite) TestRemoveMember() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	groupRegistry := new(MockDeviceGroupRegistry)
+	ctx.groupRegistry = groupRegistry
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1", OwnerId: "device1"}
+	groupRegistry.On("Get", "org1", "group1").Return(group, nil)
+	groupRegistry.On("RemoveMember", group, mock.Anything, mock.Anything).Return(nil)
+
+	contract := new(DeviceGroupRegistrySmartContract)
+	err := contract.RemoveMember(ctx, "org1", "group1", "org2", "device2")
+	assert.Nil(s.T(), err, "should allow the member to leave")
+	groupRegistry.AssertCalled(s.T(), "RemoveMember", group, "org2", "device2")
+	assert.Equal(s.T(), "group://org1/group1/leave", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.RemoveMember(ctx, "org1", "group1", "org2", "device3")
+	assert.Error(s.T(), err, "should refuse removing another member by a client other than the owner")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.DeviceId, ctx.OrganizationId = "device1", "org1"
+	err = contract.RemoveMember(ctx, "org1", "group1", "org2", "device3")
+	assert.Nil(s.T(), err, "should allow the group owner to remove members")
+	groupRegistry.AssertCalled(s.T(), "RemoveMember", group, "org2", "device3")
+}
+
+func TestDeviceGroupRegistryContractTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceGroupRegistryContractTestSuite))
+}
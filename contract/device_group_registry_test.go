@@ -0,0 +1,184 @@
+package contract
+
+import (
+	"testing"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockDeviceGroupRegistry struct {
+	mock.Mock
+}
+
+func (r *MockDeviceGroupRegistry) Register(group *common.DeviceGroup) error {
+	args := r.Called(group)
+	return args.Error(0)
+}
+
+func (r *MockDeviceGroupRegistry) Get(organizationId string, name string) (*common.DeviceGroup, error) {
+	args := r.Called(organizationId, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceGroup), args.Error(1)
+}
+
+func (r *MockDeviceGroupRegistry) GetAll(organizationId string) ([]*common.DeviceGroup, error) {
+	args := r.Called(organizationId)
+	return args.Get(0).([]*common.DeviceGroup), args.Error(1)
+}
+
+func (r *MockDeviceGroupRegistry) Deregister(group *common.DeviceGroup) error {
+	args := r.Called(group)
+	return args.Error(0)
+}
+
+func (r *MockDeviceGroupRegistry) AddMember(group *common.DeviceGroup, organizationId string, deviceId string) error {
+	args := r.Called(group, organizationId, deviceId)
+	return args.Error(0)
+}
+
+func (r *MockDeviceGroupRegistry) RemoveMember(group *common.DeviceGroup, organizationId string, deviceId string) error {
+	args := r.Called(group, organizationId, deviceId)
+	return args.Error(0)
+}
+
+type DeviceGroupRegistryTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestRegister() {
+	stateRegistry := new(MockStateRegistry)
+
+	groupRegistry := new(DeviceGroupRegistry)
+	groupRegistry.ctx = new(MockTransactionContext)
+	groupRegistry.stateRegistry = stateRegistry
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1"}
+	stateRegistry.On("PutState", group).Return(nil)
+
+	err := groupRegistry.Register(group)
+	called := stateRegistry.AssertCalled(s.T(), "PutState", group)
+	assert.True(s.T(), called, "should put device group to state registry")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestGet() {
+	stateRegistry := new(MockStateRegistry)
+
+	groupRegistry := new(DeviceGroupRegistry)
+	groupRegistry.ctx = new(MockTransactionContext)
+	groupRegistry.stateRegistry = stateRegistry
+
+	group := new(common.DeviceGroup)
+	stateRegistry.On("GetState", []string{"org1", "group1"}).Return(group, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	result, err := groupRegistry.Get("org1", "group1")
+	assert.Equal(s.T(), group, result, "should return the correct device group")
+	assert.Nil(s.T(), err, "should return no error")
+
+	result, err = groupRegistry.Get("org2", "group2")
+	assert.Nil(s.T(), result, "should return no device group")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestGetAll() {
+	stateRegistry := new(MockStateRegistry)
+
+	groupRegistry := new(DeviceGroupRegistry)
+	groupRegistry.ctx = new(MockTransactionContext)
+	groupRegistry.stateRegistry = stateRegistry
+
+	groups := []StateInterface{new(common.DeviceGroup), new(common.DeviceGroup)}
+	stateRegistry.On("GetStates", []string{"org1"}).Return(groups, nil)
+	stateRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+
+	results, err := groupRegistry.GetAll("org1")
+	assert.Equal(s.T(), len(groups), len(results), "should return the correct number of device groups")
+	assert.Nil(s.T(), err, "should return no error")
+	for i := range results {
+		assert.Equal(s.T(), groups[i], results[i], "should return correct device group")
+	}
+
+	results, err = groupRegistry.GetAll("org2")
+	assert.Zero(s.T(), len(results), "should return no device group")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestDeregister() {
+	stateRegistry := new(MockStateRegistry)
+
+	groupRegistry := new(DeviceGroupRegistry)
+	groupRegistry.ctx = new(MockTransactionContext)
+	groupRegistry.stateRegistry = stateRegistry
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1"}
+	stateRegistry.On("RemoveState", group).Return(nil)
+
+	err := groupRegistry.Deregister(group)
+	called := stateRegistry.AssertCalled(s.T(), "RemoveState", group)
+	assert.True(s.T(), called, "should remove device group from state registry")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestAddMember() {
+	stateRegistry := new(MockStateRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.deviceRegistry = deviceRegistry
+
+	groupRegistry := new(DeviceGroupRegistry)
+	groupRegistry.ctx = transactionContext
+	groupRegistry.stateRegistry = stateRegistry
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1"}
+	stateRegistry.On("PutState", group).Return(nil)
+	deviceRegistry.On("Get", "org2", "device1").Return(new(common.Device), nil)
+	deviceRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+
+	err := groupRegistry.AddMember(group, "org2", "device1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.DeviceGroupMember{{OrganizationId: "org2", DeviceId: "device1"}}, group.Members, "should add the device to the group")
+	stateRegistry.AssertCalled(s.T(), "PutState", group)
+
+	err = groupRegistry.AddMember(group, "org2", "device1")
+	assert.Error(s.T(), err, "should refuse a device that is already a member")
+
+	err = groupRegistry.AddMember(group, "org2", "device2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should refuse an unregistered device")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestRemoveMember() {
+	stateRegistry := new(MockStateRegistry)
+
+	groupRegistry := new(DeviceGroupRegistry)
+	groupRegistry.ctx = new(MockTransactionContext)
+	groupRegistry.stateRegistry = stateRegistry
+
+	group := &common.DeviceGroup{
+		OrganizationId: "org1",
+		Name:           "group1",
+		Members: []*common.DeviceGroupMember{
+			{OrganizationId: "org1", DeviceId: "device1"},
+			{OrganizationId: "org2", DeviceId: "device2"},
+		},
+	}
+	stateRegistry.On("PutState", group).Return(nil)
+
+	err := groupRegistry.RemoveMember(group, "org1", "device1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.DeviceGroupMember{{OrganizationId: "org2", DeviceId: "device2"}}, group.Members, "should remove the device from the group")
+	stateRegistry.AssertCalled(s.T(), "PutState", group)
+
+	err = groupRegistry.RemoveMember(group, "org1", "device1")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func TestDeviceGroupRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceGroupRegistryTestSuite))
+}
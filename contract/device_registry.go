@@ -1,9 +1,24 @@
 package contract
 
 import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"time"
+
 	"github.com/nexus-lab/iot-service-blockchain/common"
 )
 
+// DeviceAliasGracePeriod how long the previous ID of a rekeyed device remains resolvable
+var DeviceAliasGracePeriod = 7 * 24 * time.Hour
+
+// DefaultHeartbeatTimeout how long a device remains online after its last heartbeat if it does not define its own timeout
+var DefaultHeartbeatTimeout = 5 * time.Minute
+
+// RequireEnrollment whether devices registering themselves for the first time must consume an open enrollment issued
+// by their organization administrators
+var RequireEnrollment = true
+
 // DeviceRegistryInterface core utilities for managing devices on the ledger
 type DeviceRegistryInterface interface {
 	// Register create or update a device in the ledger
@@ -15,24 +30,177 @@ type DeviceRegistryInterface interface {
 	// GetAll return a list of devices by their organization ID
 	GetAll(organizationId string) ([]*common.Device, error)
 
+	// GetOrganizations return a list of organizations that have registered devices, with their device and service counts
+	GetOrganizations() ([]*common.OrganizationSummary, error)
+
+	// UpdateOrganization add the changes in the numbers of devices and services of an organization to its summary
+	UpdateOrganization(organizationId string, devices int, services int) error
+
+	// List return a page of devices across all organizations
+	List(pageSize int, bookmark string) (*common.DevicePage, error)
+
 	// Deregister remove a device from the ledger
 	Deregister(device *common.Device) error
+
+	// AuthorizeRekey allow a new identity to take over a device
+	AuthorizeRekey(device *common.Device, newDeviceId string) error
+
+	// Rekey move the device authorized to be taken over by the new identity, and return the device and its previous ID
+	Rekey(organizationId string, newDeviceId string) (*common.Device, string, error)
+
+	// Resolve return the current ID of a device that has been rekeyed within the grace period
+	Resolve(organizationId string, deviceId string) (string, error)
+
+	// Heartbeat record that a device is alive, and return its presence and whether it has just come online
+	Heartbeat(device *common.Device, status map[string]string) (*common.DevicePresence, bool, error)
+
+	// Offline mark a device as offline, which is only allowed after its heartbeat timeout unless forced
+	Offline(device *common.Device, force bool) (*common.DevicePresence, error)
+
+	// GetPresence return the presence of a device by its organization ID and device ID
+	GetPresence(organizationId string, deviceId string) (*common.DevicePresence, error)
+
+	// GetAllByPresence return a list of devices by their organization ID and presence state, or all devices if the state is empty
+	GetAllByPresence(organizationId string, state common.DevicePresenceState) ([]*common.Device, error)
+
+	// GetTwin return the twin of a device by its organization ID and device ID
+	GetTwin(organizationId string, deviceId string) (*common.DeviceTwin, error)
+
+	// UpdateDesired merge the properties into the desired properties of a device twin
+	UpdateDesired(device *common.Device, properties map[string]string) (*common.DeviceTwin, error)
+
+	// UpdateReported merge the properties into the reported properties of a device twin
+	UpdateReported(device *common.Device, properties map[string]string) (*common.DeviceTwin, error)
+
+	// AuthorizeEnrollment issue an enrollment allowing a device to register itself
+	AuthorizeEnrollment(enrollment *common.DeviceEnrollment) error
+
+	// GetEnrollment return a device enrollment by its organization ID and enrollment ID
+	GetEnrollment(organizationId string, enrollmentId string) (*common.DeviceEnrollment, error)
+
+	// GetEnrollments return a list of open and consumed device enrollments by their organization ID
+	GetEnrollments(organizationId string) ([]*common.DeviceEnrollment, error)
+
+	// Enroll register a new device by consuming the open enrollment matching its certificate and claim code, and
+	// return the consumed enrollment
+	Enroll(device *common.Device, cert *x509.Certificate, claimCode string) (*common.DeviceEnrollment, error)
+
+	// Revoke create or update the revocation of a device or one of its certificates
+	Revoke(revocation *common.DeviceRevocation) error
+
+	// Reinstate remove the revocation of a device or one of its certificates, and return the removed revocation
+	Reinstate(organizationId string, deviceId string, serialNumber string) (*common.DeviceRevocation, error)
+
+	// GetRevocations return a list of device revocations by their organization ID
+	GetRevocations(organizationId string) ([]*common.DeviceRevocation, error)
+
+	// IsRevoked check if a device or its certificate with the serial number has been revoked, only revocations of
+	// every certificate of the device are checked if the serial number is empty
+	IsRevoked(organizationId string, deviceId string, serialNumber string) (bool, error)
+}
+
+// Dummy alias object mapping the previous ID of a rekeyed device to its new ID
+type deviceAlias struct {
+	OrganizationId string    `json:"organizationId"`
+	DeviceId       string    `json:"deviceId"`
+	NewDeviceId    string    `json:"newDeviceId"`
+	ExpiryTime     time.Time `json:"expiryTime"`
+}
+
+func (a *deviceAlias) GetKeyComponents() []string {
+	return []string{a.OrganizationId, a.DeviceId}
+}
+
+func (a *deviceAlias) Serialize() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func (a *deviceAlias) Validate() error {
+	return nil
+}
+
+func deserializeDeviceAlias(data []byte) (*deviceAlias, error) {
+	alias := new(deviceAlias)
+
+	if err := json.Unmarshal(data, alias); err != nil {
+		return nil, err
+	}
+
+	return alias, nil
+}
+
+// Dummy authorization object allowing a new identity to take over a device
+type deviceRekeyAuthorization struct {
+	OrganizationId string    `json:"organizationId"`
+	NewDeviceId    string    `json:"newDeviceId"`
+	DeviceId       string    `json:"deviceId"`
+	Time           time.Time `json:"time"`
+}
+
+func (a *deviceRekeyAuthorization) GetKeyComponents() []string {
+	return []string{a.OrganizationId, a.NewDeviceId}
+}
+
+func (a *deviceRekeyAuthorization) Serialize() ([]byte, error) {
+	return json.Marshal(a)
+}
+
+func (a *deviceRekeyAuthorization) Validate() error {
+	return nil
+}
+
+func deserializeDeviceRekeyAuthorization(data []byte) (*deviceRekeyAuthorization, error) {
+	authorization := new(deviceRekeyAuthorization)
+
+	if err := json.Unmarshal(data, authorization); err != nil {
+		return nil, err
+	}
+
+	return authorization, nil
 }
 
 // DeviceRegistry core utilities for managing devices on the ledger
 type DeviceRegistry struct {
-	ctx           TransactionContextInterface
-	stateRegistry StateRegistryInterface
+	ctx                   TransactionContextInterface
+	stateRegistry         StateRegistryInterface
+	aliasRegistry         StateRegistryInterface
+	authorizationRegistry StateRegistryInterface
+	presenceRegistry      StateRegistryInterface
+	twinRegistry          StateRegistryInterface
+	enrollmentRegistry    StateRegistryInterface
+	revocationRegistry    StateRegistryInterface
+	summaryRegistry       StateRegistryInterface
+
+	// organization summaries updated in the current transaction, which the ledger does not return until it is committed
+	summaries map[string]*common.OrganizationSummary
 }
 
 // Register create or update a device in the ledger
 func (r *DeviceRegistry) Register(device *common.Device) error {
-	return r.stateRegistry.PutState(device)
+	_, err := r.stateRegistry.GetState(device.OrganizationId, device.Id)
+	if _, ok := err.(*common.NotFoundError); err != nil && !ok {
+		return err
+	}
+	exists := err == nil
+
+	if err = r.stateRegistry.PutState(device); err != nil || exists {
+		return err
+	}
+
+	return r.UpdateOrganization(device.OrganizationId, 1, 0)
 }
 
 // Get return a device by its organization ID and device ID
 func (r *DeviceRegistry) Get(organizationId string, deviceId string) (*common.Device, error) {
 	state, err := r.stateRegistry.GetState(organizationId, deviceId)
+	if _, ok := err.(*common.NotFoundError); ok {
+		// look up the device by its new ID if it has been rekeyed
+		newDeviceId, err_ := r.Resolve(organizationId, deviceId)
+		if err_ != nil || newDeviceId == deviceId {
+			return nil, err
+		}
+		state, err = r.stateRegistry.GetState(organizationId, newDeviceId)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -55,6 +223,61 @@ func (r *DeviceRegistry) GetAll(organizationId string) ([]*common.Device, error)
 	return devices, err
 }
 
+// GetOrganizations return a list of organizations that have registered devices, with their device and service counts
+func (r *DeviceRegistry) GetOrganizations() ([]*common.OrganizationSummary, error) {
+	states, err := r.summaryRegistry.GetStates()
+	if err != nil {
+		return nil, err
+	}
+
+	organizations := make([]*common.OrganizationSummary, 0)
+	for _, state := range states {
+		organizations = append(organizations, state.(*common.OrganizationSummary))
+	}
+
+	return organizations, nil
+}
+
+// UpdateOrganization add the changes in the numbers of devices and services of an organization to its summary
+func (r *DeviceRegistry) UpdateOrganization(organizationId string, devices int, services int) error {
+	summary, err := r.getSummary(organizationId)
+	if err != nil {
+		return err
+	}
+
+	summary.Devices += devices
+	summary.Services += services
+
+	// devices and services registered before schema version 1 are not counted until the ledger state is migrated
+	if summary.Devices < 0 {
+		summary.Devices = 0
+	}
+	if summary.Services < 0 {
+		summary.Services = 0
+	}
+
+	return r.putSummary(summary)
+}
+
+// List return a page of devices across all organizations
+func (r *DeviceRegistry) List(pageSize int, bookmark string) (*common.DevicePage, error) {
+	if err := common.ValidatePageSize(pageSize); err != nil {
+		return nil, err
+	}
+
+	states, next, err := r.stateRegistry.GetPage(common.GetPageSize(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &common.DevicePage{Devices: make([]*common.Device, 0), Bookmark: next}
+	for _, state := range states {
+		page.Devices = append(page.Devices, state.(*common.Device))
+	}
+
+	return page, nil
+}
+
 // Deregister remove a device from the ledger
 func (r *DeviceRegistry) Deregister(device *common.Device) error {
 	// deregister services of the device
@@ -66,9 +289,593 @@ func (r *DeviceRegistry) Deregister(device *common.Device) error {
 		if err = r.ctx.GetServiceRegistry().Deregister(service); err != nil {
 			return err
 		}
+
+		// notify listening clients of the cascaded deregistration together with the transaction event
+		payload, _ := service.Serialize()
+		r.ctx.AddEvent(newServiceEvent(service.OrganizationId, service.DeviceId, service.Name, "deregister", payload))
+	}
+
+	if err = r.removePresence(device.OrganizationId, device.Id); err != nil {
+		return err
+	}
+	if err = r.removeTwin(device.OrganizationId, device.Id); err != nil {
+		return err
+	}
+
+	if err = r.stateRegistry.RemoveState(device); err != nil {
+		return err
+	}
+
+	return r.UpdateOrganization(device.OrganizationId, -1, 0)
+}
+
+// AuthorizeRekey allow a new identity to take over a device
+func (r *DeviceRegistry) AuthorizeRekey(device *common.Device, newDeviceId string) error {
+	if newDeviceId == "" || newDeviceId == device.Id {
+		return fmt.Errorf("invalid new device ID")
+	}
+
+	// a new identity would escape the revocation of the device
+	if revoked, err := r.IsRevoked(device.OrganizationId, device.Id, ""); err != nil {
+		return err
+	} else if revoked {
+		return &common.AccessDeniedError{Reason: fmt.Sprintf("device %s has been revoked", device.Id)}
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return err
+	}
+
+	return r.authorizationRegistry.PutState(
+		&deviceRekeyAuthorization{
+			OrganizationId: device.OrganizationId,
+			NewDeviceId:    newDeviceId,
+			DeviceId:       device.Id,
+			Time:           now,
+		},
+	)
+}
+
+// Rekey move the device authorized to be taken over by the new identity, and return the device and its previous ID
+func (r *DeviceRegistry) Rekey(organizationId string, newDeviceId string) (*common.Device, string, error) {
+	state, err := r.authorizationRegistry.GetState(organizationId, newDeviceId)
+	if err != nil {
+		return nil, "", err
+	}
+	authorization := state.(*deviceRekeyAuthorization)
+
+	state, err = r.stateRegistry.GetState(organizationId, authorization.DeviceId)
+	if err != nil {
+		return nil, "", err
+	}
+	device := state.(*common.Device)
+
+	// the device may have been revoked after the rekey was authorized
+	if revoked, err := r.IsRevoked(organizationId, device.Id, ""); err != nil {
+		return nil, "", err
+	} else if revoked {
+		return nil, "", &common.AccessDeniedError{Reason: fmt.Sprintf("device %s has been revoked", device.Id)}
+	}
+
+	// check if the new identity is already a device
+	_, err = r.stateRegistry.GetState(organizationId, newDeviceId)
+	if _, ok := err.(*common.NotFoundError); err != nil && !ok {
+		return nil, "", err
+	}
+	if err == nil {
+		return nil, "", fmt.Errorf("device already exists")
+	}
+
+	// move services of the device, together with the requests made to them
+	services, err := r.ctx.GetServiceRegistry().GetAll(organizationId, device.Id)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, service := range services {
+		if err = r.ctx.GetServiceRegistry().Rekey(service, newDeviceId); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// move stream heads and quota usages of the services of the device, which outlive the services
+	if err = r.ctx.GetServiceBroker().RekeyDevice(organizationId, device.Id, newDeviceId); err != nil {
+		return nil, "", err
+	}
+
+	// move requests made by the device
+	pairs, err := r.ctx.GetServiceBroker().GetAllByRequester(organizationId, device.Id)
+	if err != nil {
+		return nil, "", err
+	}
+	for _, pair := range pairs {
+		if err = r.ctx.GetServiceBroker().Rekey(pair.Request.Id, organizationId, device.Id, newDeviceId); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err = r.stateRegistry.RemoveState(device); err != nil {
+		return nil, "", err
+	}
+	if err = r.authorizationRegistry.RemoveState(authorization); err != nil {
+		return nil, "", err
+	}
+	if err = r.removePresence(organizationId, device.Id); err != nil {
+		return nil, "", err
+	}
+	if err = r.moveTwin(organizationId, device.Id, newDeviceId); err != nil {
+		return nil, "", err
+	}
+
+	deviceId := device.Id
+	device.Id = newDeviceId
+	if err = r.stateRegistry.PutState(device); err != nil {
+		return nil, "", err
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, "", err
+	}
+
+	err = r.aliasRegistry.PutState(
+		&deviceAlias{
+			OrganizationId: organizationId,
+			DeviceId:       deviceId,
+			NewDeviceId:    newDeviceId,
+			ExpiryTime:     now.Add(DeviceAliasGracePeriod),
+		},
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return device, deviceId, nil
+}
+
+// Resolve return the current ID of a device that has been rekeyed within the grace period
+func (r *DeviceRegistry) Resolve(organizationId string, deviceId string) (string, error) {
+	state, err := r.aliasRegistry.GetState(organizationId, deviceId)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return deviceId, nil
+	} else if err != nil {
+		return "", err
+	}
+	alias := state.(*deviceAlias)
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return "", err
+	}
+	if now.After(alias.ExpiryTime) {
+		return deviceId, nil
+	}
+
+	return alias.NewDeviceId, nil
+}
+
+// Heartbeat record that a device is alive, and return its presence and whether it has just come online
+func (r *DeviceRegistry) Heartbeat(device *common.Device, status map[string]string) (*common.DevicePresence, bool, error) {
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, false, err
+	}
+
+	presence, err := r.getPresence(device, now)
+	if err != nil {
+		return nil, false, err
+	}
+
+	online := presence.State == common.DevicePresenceOnline
+	presence.State = common.DevicePresenceOnline
+	presence.LastSeenTime = now
+	presence.Status = status
+
+	if err = r.presenceRegistry.PutState(presence); err != nil {
+		return nil, false, err
+	}
+
+	return presence, !online, nil
+}
+
+// Offline mark a device as offline, which is only allowed after its heartbeat timeout unless forced
+func (r *DeviceRegistry) Offline(device *common.Device, force bool) (*common.DevicePresence, error) {
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := r.presenceRegistry.GetState(device.OrganizationId, device.Id)
+	if err != nil {
+		return nil, err
+	}
+	presence := state.(*common.DevicePresence)
+
+	if presence.State == common.DevicePresenceOffline {
+		return nil, fmt.Errorf("device is already offline")
+	}
+	if !force && presence.IsOnline(now, getHeartbeatTimeout(device)) {
+		return nil, fmt.Errorf("device has sent a heartbeat within its heartbeat timeout")
+	}
+
+	presence.State = common.DevicePresenceOffline
+	if err = r.presenceRegistry.PutState(presence); err != nil {
+		return nil, err
+	}
+
+	return presence, nil
+}
+
+// GetPresence return the presence of a device by its organization ID and device ID
+func (r *DeviceRegistry) GetPresence(organizationId string, deviceId string) (*common.DevicePresence, error) {
+	device, err := r.Get(organizationId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getPresence(device, now)
+}
+
+// GetAllByPresence return a list of devices by their organization ID and presence state, or all devices if the state is empty
+func (r *DeviceRegistry) GetAllByPresence(organizationId string, state common.DevicePresenceState) ([]*common.Device, error) {
+	if state != "" && state != common.DevicePresenceOnline && state != common.DevicePresenceOffline {
+		return nil, fmt.Errorf("invalid device presence state %s", state)
+	}
+
+	devices, err := r.GetAll(organizationId)
+	if err != nil || state == "" {
+		return devices, err
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.Device, 0)
+	for _, device := range devices {
+		presence, err := r.getPresence(device, now)
+		if err != nil {
+			return nil, err
+		}
+		if presence.State == state {
+			results = append(results, device)
+		}
+	}
+
+	return results, nil
+}
+
+// GetTwin return the twin of a device by its organization ID and device ID
+func (r *DeviceRegistry) GetTwin(organizationId string, deviceId string) (*common.DeviceTwin, error) {
+	device, err := r.Get(organizationId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getTwin(device)
+}
+
+// UpdateDesired merge the properties into the desired properties of a device twin
+func (r *DeviceRegistry) UpdateDesired(device *common.Device, properties map[string]string) (*common.DeviceTwin, error) {
+	twin, err := r.getTwin(device)
+	if err != nil {
+		return nil, err
+	}
+
+	twin.ApplyDesired(properties)
+	return twin, r.putTwin(twin)
+}
+
+// UpdateReported merge the properties into the reported properties of a device twin
+func (r *DeviceRegistry) UpdateReported(device *common.Device, properties map[string]string) (*common.DeviceTwin, error) {
+	twin, err := r.getTwin(device)
+	if err != nil {
+		return nil, err
+	}
+
+	twin.ApplyReported(properties)
+	return twin, r.putTwin(twin)
+}
+
+// AuthorizeEnrollment issue an enrollment allowing a device to register itself
+func (r *DeviceRegistry) AuthorizeEnrollment(enrollment *common.DeviceEnrollment) error {
+	// check if enrollment already exists
+	if _, err := r.enrollmentRegistry.GetState(enrollment.GetKeyComponents()...); err == nil {
+		return fmt.Errorf("device enrollment already exists")
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return err
+	}
+
+	return r.enrollmentRegistry.PutState(enrollment)
+}
+
+// GetEnrollment return a device enrollment by its organization ID and enrollment ID
+func (r *DeviceRegistry) GetEnrollment(organizationId string, enrollmentId string) (*common.DeviceEnrollment, error) {
+	state, err := r.enrollmentRegistry.GetState(organizationId, enrollmentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.DeviceEnrollment), nil
+}
+
+// GetEnrollments return a list of open and consumed device enrollments by their organization ID
+func (r *DeviceRegistry) GetEnrollments(organizationId string) ([]*common.DeviceEnrollment, error) {
+	states, err := r.enrollmentRegistry.GetStates(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments := make([]*common.DeviceEnrollment, 0)
+	for _, state := range states {
+		enrollments = append(enrollments, state.(*common.DeviceEnrollment))
+	}
+
+	return enrollments, nil
+}
+
+// Enroll register a new device by consuming the open enrollment matching its certificate and claim code, and
+// return the consumed enrollment
+func (r *DeviceRegistry) Enroll(device *common.Device, cert *x509.Certificate, claimCode string) (*common.DeviceEnrollment, error) {
+	// check if the device is already registered
+	if _, err := r.stateRegistry.GetState(device.OrganizationId, device.Id); err == nil {
+		return nil, fmt.Errorf("device already exists")
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return nil, err
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	enrollments, err := r.GetEnrollments(device.OrganizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	expired := false
+	for _, enrollment := range enrollments {
+		if enrollment.IsConsumed() || !enrollment.Matches(cert, claimCode) {
+			continue
+		}
+		if enrollment.IsExpired(now) {
+			expired = true
+			continue
+		}
+
+		// keep the consumed enrollment for audit
+		enrollment.DeviceId = device.Id
+		enrollment.ConsumeTime = now
+		if err = r.enrollmentRegistry.PutState(enrollment); err != nil {
+			return nil, err
+		}
+		if err = r.stateRegistry.PutState(device); err != nil {
+			return nil, err
+		}
+		if err = r.UpdateOrganization(device.OrganizationId, 1, 0); err != nil {
+			return nil, err
+		}
+
+		return enrollment, nil
+	}
+
+	if expired {
+		return nil, &common.AccessDeniedError{Reason: "device enrollment has expired"}
+	}
+	return nil, &common.AccessDeniedError{Reason: "no open device enrollment matches the device"}
+}
+
+// Revoke create or update the revocation of a device or one of its certificates
+func (r *DeviceRegistry) Revoke(revocation *common.DeviceRevocation) error {
+	return r.revocationRegistry.PutState(revocation)
+}
+
+// Reinstate remove the revocation of a device or one of its certificates, and return the removed revocation
+func (r *DeviceRegistry) Reinstate(organizationId string, deviceId string, serialNumber string) (*common.DeviceRevocation, error) {
+	state, err := r.revocationRegistry.GetState(organizationId, deviceId, serialNumber)
+	if err != nil {
+		return nil, err
+	}
+	revocation := state.(*common.DeviceRevocation)
+
+	if err = r.revocationRegistry.RemoveState(revocation); err != nil {
+		return nil, err
+	}
+
+	return revocation, nil
+}
+
+// GetRevocations return a list of device revocations by their organization ID
+func (r *DeviceRegistry) GetRevocations(organizationId string) ([]*common.DeviceRevocation, error) {
+	states, err := r.revocationRegistry.GetStates(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	revocations := make([]*common.DeviceRevocation, 0)
+	for _, state := range states {
+		revocations = append(revocations, state.(*common.DeviceRevocation))
+	}
+
+	return revocations, nil
+}
+
+// IsRevoked check if a device or its certificate with the serial number has been revoked, only revocations of
+// every certificate of the device are checked if the serial number is empty
+func (r *DeviceRegistry) IsRevoked(organizationId string, deviceId string, serialNumber string) (bool, error) {
+	states, err := r.revocationRegistry.GetStates(organizationId, deviceId)
+	if err != nil {
+		return false, err
+	}
+
+	for _, state := range states {
+		if state.(*common.DeviceRevocation).Matches(serialNumber) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// getPresence return the presence of a device at the given time, which is offline if the device has never sent a heartbeat
+func (r *DeviceRegistry) getPresence(device *common.Device, now time.Time) (*common.DevicePresence, error) {
+	state, err := r.presenceRegistry.GetState(device.OrganizationId, device.Id)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return &common.DevicePresence{
+			OrganizationId: device.OrganizationId,
+			DeviceId:       device.Id,
+			State:          common.DevicePresenceOffline,
+		}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	presence := state.(*common.DevicePresence)
+
+	if !presence.IsOnline(now, getHeartbeatTimeout(device)) {
+		presence.State = common.DevicePresenceOffline
+	}
+
+	return presence, nil
+}
+
+func (r *DeviceRegistry) removePresence(organizationId string, deviceId string) error {
+	err := r.presenceRegistry.RemoveState(&common.DevicePresence{OrganizationId: organizationId, DeviceId: deviceId})
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// getTwin return the twin of a device, which is empty if the device has never been configured
+func (r *DeviceRegistry) getTwin(device *common.Device) (*common.DeviceTwin, error) {
+	state, err := r.twinRegistry.GetState(device.OrganizationId, device.Id)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return &common.DeviceTwin{OrganizationId: device.OrganizationId, DeviceId: device.Id}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.DeviceTwin), nil
+}
+
+func (r *DeviceRegistry) putTwin(twin *common.DeviceTwin) error {
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return err
+	}
+
+	twin.Version++
+	twin.LastUpdateTime = now
+	return r.twinRegistry.PutState(twin)
+}
+
+func (r *DeviceRegistry) removeTwin(organizationId string, deviceId string) error {
+	err := r.twinRegistry.RemoveState(&common.DeviceTwin{OrganizationId: organizationId, DeviceId: deviceId})
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// moveTwin keep the twin of a rekeyed device under its new ID
+func (r *DeviceRegistry) moveTwin(organizationId string, deviceId string, newDeviceId string) error {
+	state, err := r.twinRegistry.GetState(organizationId, deviceId)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	twin := state.(*common.DeviceTwin)
+
+	if err = r.twinRegistry.RemoveState(twin); err != nil {
+		return err
+	}
+
+	twin.DeviceId = newDeviceId
+	return r.twinRegistry.PutState(twin)
+}
+
+// getSummary return the summary of an organization, which is empty if the organization has no devices
+func (r *DeviceRegistry) getSummary(organizationId string) (*common.OrganizationSummary, error) {
+	if summary, ok := r.summaries[organizationId]; ok {
+		return summary, nil
+	}
+
+	state, err := r.summaryRegistry.GetState(organizationId)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return &common.OrganizationSummary{OrganizationId: organizationId}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.OrganizationSummary), nil
+}
+
+// putSummary store the summary of an organization, which is removed once the organization has no devices
+func (r *DeviceRegistry) putSummary(summary *common.OrganizationSummary) error {
+	if r.summaries == nil {
+		r.summaries = make(map[string]*common.OrganizationSummary)
+	}
+	r.summaries[summary.OrganizationId] = summary
+
+	if summary.Devices > 0 {
+		return r.summaryRegistry.PutState(summary)
+	}
+
+	err := r.summaryRegistry.RemoveState(summary)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// countOrganization recount the devices and services of an organization into its summary
+func (r *DeviceRegistry) countOrganization(organizationId string) error {
+	devices, err := r.GetAll(organizationId)
+	if err != nil {
+		return err
+	}
+
+	summary := &common.OrganizationSummary{OrganizationId: organizationId, Devices: len(devices)}
+	for _, device := range devices {
+		services, err := r.ctx.GetServiceRegistry().GetAll(organizationId, device.Id)
+		if err != nil {
+			return err
+		}
+		summary.Services += len(services)
 	}
 
-	return r.stateRegistry.RemoveState(device)
+	return r.putSummary(summary)
+}
+
+// migrateSummaries count the devices and services of the organizations of at most chunkSize devices after the
+// bookmark, which were not summarized before schema version 1
+func (r *DeviceRegistry) migrateSummaries(bookmark string, chunkSize int) (string, error) {
+	// recounting is idempotent, so an organization whose devices span several chunks is recounted by each of them
+	counted := make(map[string]bool)
+	return migrateStates(r.stateRegistry, bookmark, chunkSize, func(state StateInterface) error {
+		organizationId := state.(*common.Device).OrganizationId
+		if counted[organizationId] {
+			return nil
+		}
+		counted[organizationId] = true
+
+		return r.countOrganization(organizationId)
+	})
+}
+
+func getHeartbeatTimeout(device *common.Device) time.Duration {
+	if device.HeartbeatTimeout > 0 {
+		return time.Duration(device.HeartbeatTimeout) * time.Second
+	}
+	return DefaultHeartbeatTimeout
 }
 
 func createDeviceRegistry(ctx TransactionContextInterface) *DeviceRegistry {
@@ -79,9 +886,65 @@ func createDeviceRegistry(ctx TransactionContextInterface) *DeviceRegistry {
 		return common.DeserializeDevice(data)
 	}
 
+	aliasRegistry := new(StateRegistry)
+	aliasRegistry.ctx = ctx
+	aliasRegistry.Name = "device_aliases"
+	aliasRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeDeviceAlias(data)
+	}
+
+	authorizationRegistry := new(StateRegistry)
+	authorizationRegistry.ctx = ctx
+	authorizationRegistry.Name = "device_rekey_authorizations"
+	authorizationRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeDeviceRekeyAuthorization(data)
+	}
+
+	presenceRegistry := new(StateRegistry)
+	presenceRegistry.ctx = ctx
+	presenceRegistry.Name = "device_presences"
+	presenceRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeDevicePresence(data)
+	}
+
+	twinRegistry := new(StateRegistry)
+	twinRegistry.ctx = ctx
+	twinRegistry.Name = "device_twins"
+	twinRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeDeviceTwin(data)
+	}
+
+	enrollmentRegistry := new(StateRegistry)
+	enrollmentRegistry.ctx = ctx
+	enrollmentRegistry.Name = "device_enrollments"
+	enrollmentRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeDeviceEnrollment(data)
+	}
+
+	revocationRegistry := new(StateRegistry)
+	revocationRegistry.ctx = ctx
+	revocationRegistry.Name = "device_revocations"
+	revocationRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeDeviceRevocation(data)
+	}
+
+	summaryRegistry := new(StateRegistry)
+	summaryRegistry.ctx = ctx
+	summaryRegistry.Name = "organization_summaries"
+	summaryRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeOrganizationSummary(data)
+	}
+
 	registry := new(DeviceRegistry)
 	registry.ctx = ctx
 	registry.stateRegistry = stateRegistry
+	registry.aliasRegistry = aliasRegistry
+	registry.authorizationRegistry = authorizationRegistry
+	registry.presenceRegistry = presenceRegistry
+	registry.twinRegistry = twinRegistry
+	registry.enrollmentRegistry = enrollmentRegistry
+	registry.revocationRegistry = revocationRegistry
+	registry.summaryRegistry = summaryRegistry
 
 	return registry
 }
@@ -1,7 +1,10 @@
 package contract
 
 import (
+	"crypto/x509"
+	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/nexus-lab/iot-service-blockchain/common"
@@ -12,37 +15,70 @@ type DeviceRegistrySmartContract struct {
 	contractapi.Contract
 }
 
-// Register create or update a device in the ledger
+// Register create or update a device in the ledger, a device registering itself for the first time must match an
+// open enrollment by its certificate
 func (s *DeviceRegistrySmartContract) Register(ctx TransactionContextInterface, data string) error {
-	var err error
-	var organizationId, deviceId string
+	return s.register(ctx, data, "")
+}
 
-	device, err := common.DeserializeDevice([]byte(data))
+// Enroll register a new device by consuming the open enrollment matching its certificate and one-time claim code
+func (s *DeviceRegistrySmartContract) Enroll(ctx TransactionContextInterface, data string, claimCode string) error {
+	return s.register(ctx, data, claimCode)
+}
+
+// AuthorizeEnrollment issue an enrollment allowing a device to register itself, only administrators of the
+// organization can do so
+func (s *DeviceRegistrySmartContract) AuthorizeEnrollment(ctx TransactionContextInterface, data string) error {
+	enrollment, err := common.DeserializeDeviceEnrollment([]byte(data))
 	if err != nil {
 		return err
 	}
 
-	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+	if err = checkOrganizationAdmin(ctx, enrollment.OrganizationId, "cannot authorize enrollment of a device of an organization other than the administered one"); err != nil {
 		return err
 	}
-	if deviceId, err = ctx.GetDeviceId(); err != nil {
+	deviceId, err := ctx.GetDeviceId()
+	if err != nil {
 		return err
 	}
 
-	if device.OrganizationId != organizationId || device.Id != deviceId {
-		return fmt.Errorf("cannot register a device other than the requested device")
+	now, err := ctx.GetTimestamp()
+	if err != nil {
+		return err
+	}
+	if !enrollment.ExpiryTime.After(now) {
+		return &common.InvalidTimeError{Reason: fmt.Sprintf("enrollment expires at %s before transaction time %s", enrollment.ExpiryTime.Format(time.RFC3339), now.Format(time.RFC3339))}
 	}
 
-	err = ctx.GetDeviceRegistry().Register(device)
+	enrollment.CreatorId = deviceId
+	enrollment.CreateTime = now
+	enrollment.DeviceId = ""
+	enrollment.ConsumeTime = time.Time{}
+	if err = enrollment.Validate(); err != nil {
+		return err
+	}
 
-	// notify listening clients of the update
-	if err == nil {
-		event := fmt.Sprintf("device://%s/%s/register", device.OrganizationId, device.Id)
-		payload, _ := device.Serialize()
-		err = ctx.GetStub().SetEvent(event, payload)
+	return ctx.GetDeviceRegistry().AuthorizeEnrollment(enrollment)
+}
+
+// GetEnrollment return a device enrollment by its organization ID and enrollment ID, only administrators of the
+// organization can do so
+func (s *DeviceRegistrySmartContract) GetEnrollment(ctx TransactionContextInterface, organizationId string, enrollmentId string) (*common.DeviceEnrollment, error) {
+	if err := checkEnrollmentAdmin(ctx, organizationId); err != nil {
+		return nil, err
 	}
 
-	return err
+	return ctx.GetDeviceRegistry().GetEnrollment(organizationId, enrollmentId)
+}
+
+// GetEnrollments return a list of open and consumed device enrollments by their organization ID, only administrators
+// of the organization can do so
+func (s *DeviceRegistrySmartContract) GetEnrollments(ctx TransactionContextInterface, organizationId string) ([]*common.DeviceEnrollment, error) {
+	if err := checkEnrollmentAdmin(ctx, organizationId); err != nil {
+		return nil, err
+	}
+
+	return ctx.GetDeviceRegistry().GetEnrollments(organizationId)
 }
 
 // Get return a device by its organization ID and device ID
@@ -55,16 +91,195 @@ func (s *DeviceRegistrySmartContract) GetAll(ctx TransactionContextInterface, or
 	return ctx.GetDeviceRegistry().GetAll(organizationId)
 }
 
+// GetOrganizations return a list of organizations that have registered devices, with their device and service counts
+func (s *DeviceRegistrySmartContract) GetOrganizations(ctx TransactionContextInterface) ([]*common.OrganizationSummary, error) {
+	return ctx.GetDeviceRegistry().GetOrganizations()
+}
+
+// List return a page of devices across all organizations
+func (s *DeviceRegistrySmartContract) List(ctx TransactionContextInterface, pageSize int, bookmark string) (*common.DevicePage, error) {
+	return ctx.GetDeviceRegistry().List(pageSize, bookmark)
+}
+
 // Deregister remove a device and its services from the ledger
 func (s *DeviceRegistrySmartContract) Deregister(ctx TransactionContextInterface, data string) error {
+	device, err := common.DeserializeDevice([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	// only the device itself or its organization administrators can deregister it
+	if err := checkDeviceManager(ctx, device.OrganizationId, device.Id, "cannot deregister a device other than the requested device"); err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceRegistry().Deregister(device)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := device.Serialize()
+		err = ctx.SetEvent(newDeviceEvent(device.OrganizationId, device.Id, "deregister", payload))
+	}
+
+	return err
+}
+
+// AuthorizeRekey allow a new identity to take over a device, its services and requests
+func (s *DeviceRegistrySmartContract) AuthorizeRekey(ctx TransactionContextInterface, organizationId string, deviceId string, newDeviceId string) error {
+	// only the device itself or its organization administrators can hand it over
+	if err := checkDeviceManager(ctx, organizationId, deviceId, "cannot authorize rekey of a device other than the requested device"); err != nil {
+		return err
+	}
+
+	device, err := ctx.GetDeviceRegistry().Get(organizationId, deviceId)
+	if err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceRegistry().AuthorizeRekey(device, newDeviceId)
+
+	// notify listening clients of the update
+	if err == nil {
+		err = ctx.SetEvent(newDeviceEvent(device.OrganizationId, device.Id, "authorize", []byte(newDeviceId)))
+	}
+
+	return err
+}
+
+// Rekey take over the device that has authorized the invoking identity as its new identity
+func (s *DeviceRegistrySmartContract) Rekey(ctx TransactionContextInterface) error {
 	var err error
 	var organizationId, deviceId string
 
-	device, err := common.DeserializeDevice([]byte(data))
+	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if deviceId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	device, previousId, err := ctx.GetDeviceRegistry().Rekey(organizationId, deviceId)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := device.Serialize()
+		err = ctx.SetEvent(newDeviceEvent(device.OrganizationId, previousId, "rekey", payload))
+	}
+
+	return err
+}
+
+// Heartbeat report that the invoking device is alive, with optional status fields as a JSON object of strings
+func (s *DeviceRegistrySmartContract) Heartbeat(ctx TransactionContextInterface, data string) error {
+	var err error
+	var organizationId, deviceId string
+	var status map[string]string
+
+	if data != "" {
+		if err = json.Unmarshal([]byte(data), &status); err != nil {
+			return err
+		}
+	}
+
+	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if deviceId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	device, err := ctx.GetDeviceRegistry().Get(organizationId, deviceId)
+	if err != nil {
+		return err
+	}
+
+	presence, online, err := ctx.GetDeviceRegistry().Heartbeat(device, status)
+
+	// notify listening clients only when the device comes online to keep heartbeats lightweight
+	if err == nil && online {
+		payload, _ := presence.Serialize()
+		err = ctx.SetEvent(newDeviceEvent(device.OrganizationId, device.Id, "online", payload))
+	}
+
+	return err
+}
+
+// Offline mark a device as offline, anyone can do so after its heartbeat timeout while the device itself or its organization administrators can do so at any time
+func (s *DeviceRegistrySmartContract) Offline(ctx TransactionContextInterface, organizationId string, deviceId string) error {
+	device, err := ctx.GetDeviceRegistry().Get(organizationId, deviceId)
+	if err != nil {
+		return err
+	}
+
+	force, err := canManageDevice(ctx, device.OrganizationId, device.Id)
+	if err != nil {
+		return err
+	}
+
+	presence, err := ctx.GetDeviceRegistry().Offline(device, force)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := presence.Serialize()
+		err = ctx.SetEvent(newDeviceEvent(device.OrganizationId, device.Id, "offline", payload))
+	}
+
+	return err
+}
+
+// GetPresence return the presence of a device by its organization ID and device ID
+func (s *DeviceRegistrySmartContract) GetPresence(ctx TransactionContextInterface, organizationId string, deviceId string) (*common.DevicePresence, error) {
+	return ctx.GetDeviceRegistry().GetPresence(organizationId, deviceId)
+}
+
+// GetAllByPresence return a list of devices by their organization ID and presence state, or all devices if the state is empty
+func (s *DeviceRegistrySmartContract) GetAllByPresence(ctx TransactionContextInterface, organizationId string, state string) ([]*common.Device, error) {
+	return ctx.GetDeviceRegistry().GetAllByPresence(organizationId, common.DevicePresenceState(state))
+}
+
+// GetTwin return the twin of a device by its organization ID and device ID
+func (s *DeviceRegistrySmartContract) GetTwin(ctx TransactionContextInterface, organizationId string, deviceId string) (*common.DeviceTwin, error) {
+	return ctx.GetDeviceRegistry().GetTwin(organizationId, deviceId)
+}
+
+// UpdateDesired merge properties as a JSON object of strings into the desired properties of a device twin, where
+// properties with empty values are removed, only administrators of the device's organization can do so
+func (s *DeviceRegistrySmartContract) UpdateDesired(ctx TransactionContextInterface, organizationId string, deviceId string, data string) error {
+	var properties map[string]string
+	if err := json.Unmarshal([]byte(data), &properties); err != nil {
+		return err
+	}
+
+	if err := checkOrganizationAdmin(ctx, organizationId, "cannot update desired properties of a device other than one of the administered organization"); err != nil {
+		return err
+	}
+
+	device, err := ctx.GetDeviceRegistry().Get(organizationId, deviceId)
 	if err != nil {
 		return err
 	}
 
+	twin, err := ctx.GetDeviceRegistry().UpdateDesired(device, properties)
+
+	// notify listening clients of the update
+	if err == nil {
+		err = setTwinEvent(ctx, twin, "desire")
+	}
+
+	return err
+}
+
+// UpdateReported merge properties as a JSON object of strings into the reported properties of the invoking device's
+// twin, where properties with empty values are removed
+func (s *DeviceRegistrySmartContract) UpdateReported(ctx TransactionContextInterface, data string) error {
+	var err error
+	var organizationId, deviceId string
+	var properties map[string]string
+
+	if err = json.Unmarshal([]byte(data), &properties); err != nil {
+		return err
+	}
+
 	if organizationId, err = ctx.GetOrganizationId(); err != nil {
 		return err
 	}
@@ -72,18 +287,154 @@ func (s *DeviceRegistrySmartContract) Deregister(ctx TransactionContextInterface
 		return err
 	}
 
-	if device.OrganizationId != organizationId || device.Id != deviceId {
-		return fmt.Errorf("cannot deregister a device other than the requested device")
+	device, err := ctx.GetDeviceRegistry().Get(organizationId, deviceId)
+	if err != nil {
+		return err
 	}
 
-	err = ctx.GetDeviceRegistry().Deregister(device)
+	twin, err := ctx.GetDeviceRegistry().UpdateReported(device, properties)
+
+	// notify listening clients of the update
+	if err == nil {
+		err = setTwinEvent(ctx, twin, "report")
+	}
+
+	return err
+}
+
+// Revoke revoke a device or one of its certificates, rejecting its changes to the ledger and the requests made to
+// it until reinstated, only administrators of the device's organization can do so
+func (s *DeviceRegistrySmartContract) Revoke(ctx TransactionContextInterface, data string) error {
+	revocation, err := common.DeserializeDeviceRevocation([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	if err = checkOrganizationAdmin(ctx, revocation.OrganizationId, "cannot revoke a device of an organization other than the administered one"); err != nil {
+		return err
+	}
+	deviceId, err := ctx.GetDeviceId()
+	if err != nil {
+		return err
+	}
+	if revocation.DeviceId == deviceId {
+		return fmt.Errorf("cannot revoke the invoking identity")
+	}
+
+	revocation.RevokerId = deviceId
+	if revocation.SerialNumber != "" {
+		revocation.SerialNumber = common.NormalizeSerialNumber(revocation.SerialNumber)
+	}
+	if revocation.Time, err = getTrustedTime(ctx, revocation.Time); err != nil {
+		return err
+	}
+
+	err = ctx.GetDeviceRegistry().Revoke(revocation)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := revocation.Serialize()
+		err = ctx.SetEvent(newDeviceEvent(revocation.OrganizationId, revocation.DeviceId, "revoke", payload))
+	}
+
+	return err
+}
+
+// Reinstate remove the revocation of a device or one of its certificates, only administrators of the device's
+// organization can do so
+func (s *DeviceRegistrySmartContract) Reinstate(ctx TransactionContextInterface, organizationId string, deviceId string, serialNumber string) error {
+	if err := checkOrganizationAdmin(ctx, organizationId, "cannot reinstate a device of an organization other than the administered one"); err != nil {
+		return err
+	}
+
+	if serialNumber != "" {
+		serialNumber = common.NormalizeSerialNumber(serialNumber)
+	}
+	revocation, err := ctx.GetDeviceRegistry().Reinstate(organizationId, deviceId, serialNumber)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := revocation.Serialize()
+		err = ctx.SetEvent(newDeviceEvent(revocation.OrganizationId, revocation.DeviceId, "reinstate", payload))
+	}
+
+	return err
+}
+
+// GetRevocations return a list of device revocations by their organization ID
+func (s *DeviceRegistrySmartContract) GetRevocations(ctx TransactionContextInterface, organizationId string) ([]*common.DeviceRevocation, error) {
+	return ctx.GetDeviceRegistry().GetRevocations(organizationId)
+}
+
+// register create or update a device, consuming a matching enrollment if the device is new and registers itself or
+// presents a claim code
+func (s *DeviceRegistrySmartContract) register(ctx TransactionContextInterface, data string, claimCode string) error {
+	device, err := common.DeserializeDevice([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	// only the device itself or its organization administrators can register it
+	if err := checkDeviceManager(ctx, device.OrganizationId, device.Id, "cannot register a device other than the requested device"); err != nil {
+		return err
+	}
+
+	if device.LastUpdateTime, err = getTrustedTime(ctx, device.LastUpdateTime); err != nil {
+		return err
+	}
+
+	enroll := claimCode != ""
+	if !enroll && RequireEnrollment {
+		if enroll, err = isSelfEnrollment(ctx, device); err != nil {
+			return err
+		}
+	}
+
+	if enroll {
+		var cert *x509.Certificate
+		if cert, err = ctx.GetClientIdentity().GetX509Certificate(); err != nil {
+			return err
+		}
+		_, err = ctx.GetDeviceRegistry().Enroll(device, cert, claimCode)
+	} else {
+		err = ctx.GetDeviceRegistry().Register(device)
+	}
 
 	// notify listening clients of the update
 	if err == nil {
-		event := fmt.Sprintf("device://%s/%s/deregister", device.OrganizationId, device.Id)
 		payload, _ := device.Serialize()
-		err = ctx.GetStub().SetEvent(event, payload)
+		err = ctx.SetEvent(newDeviceEvent(device.OrganizationId, device.Id, "register", payload))
 	}
 
 	return err
 }
+
+// isSelfEnrollment check if a device not registered yet is registering itself rather than through its organization
+// administrators
+func isSelfEnrollment(ctx TransactionContextInterface, device *common.Device) (bool, error) {
+	if ok, err := ctx.IsOrganizationAdmin(); err != nil || ok {
+		return false, err
+	}
+
+	_, err := ctx.GetDeviceRegistry().Get(device.OrganizationId, device.Id)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return true, nil
+	}
+
+	return false, err
+}
+
+// checkEnrollmentAdmin check if the invoking identity administers the organization of the device enrollments
+func checkEnrollmentAdmin(ctx TransactionContextInterface, organizationId string) error {
+	return checkOrganizationAdmin(ctx, organizationId, "cannot access device enrollments of an organization other than the administered one")
+}
+
+// setTwinEvent emit the twin update, together with a delta event if the desired and reported properties diverge
+func setTwinEvent(ctx TransactionContextInterface, twin *common.DeviceTwin, action string) error {
+	payload, _ := twin.Serialize()
+	if len(twin.GetDelta()) > 0 {
+		ctx.AddEvent(newDeviceEvent(twin.OrganizationId, twin.DeviceId, "delta", payload))
+	}
+
+	return ctx.SetEvent(newDeviceEvent(twin.OrganizationId, twin.DeviceId, action, payload))
+}
@@ -1,8 +1,10 @@
 package contract
 
 import (
+	"crypto/x509"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
@@ -15,11 +17,13 @@ type DeviceRegistryContractTestSuite struct {
 }
 
 func (s *DeviceRegistryContractTestSuite) TestRegister() {
-	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
 	deviceRegistry := new(MockDeviceRegistry)
 	ctx.deviceRegistry = deviceRegistry
 
 	deviceRegistry.On("Register", mock.AnythingOfType("*common.Device")).Return(nil)
+	deviceRegistry.On("Get", "org1", "device1").Return(&common.Device{Id: "device1", OrganizationId: "org1"}, nil)
 
 	contract := new(DeviceRegistrySmartContract)
 	err := contract.Register(ctx, fmt.Sprintf("{\"id\":\"%s\",\"organizationId\":\"%s\",\"name\":\"Device1\",\"description\":\"Device of Org1 User1\",\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}", ctx.DeviceId, ctx.OrganizationId))
@@ -29,17 +33,133 @@ func (s *DeviceRegistryContractTestSuite) TestRegister() {
 	device, _ := common.DeserializeDevice(ctx.stub.EventPayload)
 	assert.Equal(s.T(), fmt.Sprintf("device://%s/%s/register", ctx.OrganizationId, ctx.DeviceId), ctx.stub.EventName, "should emit event with name")
 	assert.Equal(s.T(), ctx.DeviceId, device.Id, "should emit event with payload")
+	assert.True(s.T(), now.Equal(device.LastUpdateTime), "should record transaction time")
 	ctx.stub.ResetEvent()
 
+	err = contract.Register(ctx, fmt.Sprintf("{\"id\":\"%s\",\"organizationId\":\"%s\",\"name\":\"Device1\",\"lastUpdateTime\":\"2021-12-12T17:45:00-05:00\"}", ctx.DeviceId, ctx.OrganizationId))
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
 	err = contract.Register(ctx, "{\"id\":\"device2\",\"organizationId\":\"org2\",\"name\":\"device2\",\"description\":\"Device of Org2 User1\",\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}")
 	assert.Error(s.T(), err, "should return mismatch device ID and organization ID error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
 
+	ctx.IsAdmin = true
+	err = contract.Register(ctx, "{\"id\":\"device3\",\"organizationId\":\"org1\",\"name\":\"device3\",\"description\":\"Device of Org1 User3\",\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}")
+	assert.Nil(s.T(), err, "should allow organization administrators to register devices of the organization")
+	assert.Equal(s.T(), "device://org1/device3/register", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.Register(ctx, "{\"id\":\"device2\",\"organizationId\":\"org2\",\"name\":\"device2\",\"description\":\"Device of Org2 User1\",\"lastUpdateTime\":\"2021-12-12T17:34:00-05:00\"}")
+	assert.Error(s.T(), err, "should refuse organization administrators to register devices of other organizations")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+	ctx.IsAdmin = false
+
 	err = contract.Register(ctx, "[]")
 	assert.Error(s.T(), err, "should return deserialization error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
 }
 
+func (s *DeviceRegistryContractTestSuite) TestEnroll() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	enrollment := &common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1", DeviceId: "device1"}
+	deviceRegistry.On("Get", "org1", mock.Anything).Return(nil, new(common.NotFoundError))
+	deviceRegistry.On("Enroll", mock.AnythingOfType("*common.Device"), mock.Anything, "").Return(nil, &common.AccessDeniedError{Reason: "no open device enrollment matches the device"})
+	deviceRegistry.On("Enroll", mock.AnythingOfType("*common.Device"), mock.Anything, "code1").Return(enrollment, nil)
+	deviceRegistry.On("Register", mock.AnythingOfType("*common.Device")).Return(nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.Register(ctx, "{\"id\":\"device1\",\"organizationId\":\"org1\",\"name\":\"device1\"}")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse new device without matching enrollment")
+	deviceRegistry.AssertNotCalled(s.T(), "Register", mock.Anything)
+
+	err = contract.Enroll(ctx, "{\"id\":\"device1\",\"organizationId\":\"org1\",\"name\":\"device1\"}", "code1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device://org1/device1/register", ctx.stub.EventName, "should emit event with name")
+	cert := deviceRegistry.Calls[2].Arguments[1].(*x509.Certificate)
+	assert.NotNil(s.T(), cert, "should match enrollment by the certificate of the device")
+	ctx.stub.ResetEvent()
+
+	ctx.IsAdmin = true
+	err = contract.Register(ctx, "{\"id\":\"device2\",\"organizationId\":\"org1\",\"name\":\"device2\"}")
+	assert.Nil(s.T(), err, "should allow organization administrators to register devices without enrollment")
+	assert.Equal(s.T(), "device://org1/device2/register", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+	ctx.IsAdmin = false
+
+	RequireEnrollment = false
+	defer func() { RequireEnrollment = true }()
+	err = contract.Register(ctx, "{\"id\":\"device1\",\"organizationId\":\"org1\",\"name\":\"device1\"}")
+	assert.Nil(s.T(), err, "should register new device without enrollment if opted out")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "Register", 2)
+}
+
+func (s *DeviceRegistryContractTestSuite) TestAuthorizeEnrollment() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", Timestamp: now, IsAdmin: true}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("AuthorizeEnrollment", mock.AnythingOfType("*common.DeviceEnrollment")).Return(nil)
+
+	data := "{\"id\":\"d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1\",\"organizationId\":\"org1\",\"subject\":\"CN=device1\"," +
+		"\"expiryTime\":\"2021-12-13T17:35:00-05:00\",\"deviceId\":\"device9\"}"
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.AuthorizeEnrollment(ctx, data)
+	assert.Nil(s.T(), err, "should return no error")
+	enrollment := deviceRegistry.Calls[0].Arguments[0].(*common.DeviceEnrollment)
+	assert.Equal(s.T(), "admin1", enrollment.CreatorId, "should record the issuing administrator")
+	assert.Equal(s.T(), now, enrollment.CreateTime, "should record transaction time")
+	assert.False(s.T(), enrollment.IsConsumed(), "should issue an open enrollment")
+
+	err = contract.AuthorizeEnrollment(ctx, "{\"id\":\"d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1\",\"organizationId\":\"org1\",\"subject\":\"CN=device1\",\"expiryTime\":\"2021-12-12T17:35:00-05:00\"}")
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse enrollment already expired")
+
+	err = contract.AuthorizeEnrollment(ctx, "{\"id\":\"d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1\",\"organizationId\":\"org1\",\"expiryTime\":\"2021-12-13T17:35:00-05:00\"}")
+	assert.Error(s.T(), err, "should refuse enrollment without criteria")
+
+	err = contract.AuthorizeEnrollment(ctx, "{\"id\":\"d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1\",\"organizationId\":\"org2\",\"subject\":\"CN=device1\",\"expiryTime\":\"2021-12-13T17:35:00-05:00\"}")
+	assert.Error(s.T(), err, "should refuse to authorize enrollment in other organizations")
+
+	ctx.IsAdmin = false
+	err = contract.AuthorizeEnrollment(ctx, data)
+	assert.Error(s.T(), err, "should refuse to authorize enrollment by clients other than administrators")
+
+	err = contract.AuthorizeEnrollment(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "AuthorizeEnrollment", 1)
+}
+
+func (s *DeviceRegistryContractTestSuite) TestGetEnrollments() {
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", IsAdmin: true}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	expected := &common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1"}
+	deviceRegistry.On("GetEnrollment", "org1", "enrollment1").Return(expected, nil)
+	deviceRegistry.On("GetEnrollments", "org1").Return([]*common.DeviceEnrollment{expected}, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	enrollment, err := contract.GetEnrollment(ctx, "org1", "enrollment1")
+	assert.Equal(s.T(), expected, enrollment, "should return the enrollment")
+	assert.Nil(s.T(), err, "should return no error")
+
+	enrollments, err := contract.GetEnrollments(ctx, "org1")
+	assert.Equal(s.T(), []*common.DeviceEnrollment{expected}, enrollments, "should return enrollments of the organization")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = contract.GetEnrollments(ctx, "org2")
+	assert.Error(s.T(), err, "should refuse to return enrollments of other organizations")
+
+	ctx.IsAdmin = false
+	_, err = contract.GetEnrollment(ctx, "org1", "enrollment1")
+	assert.Error(s.T(), err, "should refuse to return enrollments to clients other than administrators")
+}
+
 func (s *DeviceRegistryContractTestSuite) TestGet() {
 	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
 	deviceRegistry := new(MockDeviceRegistry)
@@ -66,6 +186,32 @@ func (s *DeviceRegistryContractTestSuite) TestGetAll() {
 	assert.True(s.T(), called, "should retrieve devices from device registry")
 }
 
+func (s *DeviceRegistryContractTestSuite) TestGetOrganizations() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("GetOrganizations").Return([]*common.OrganizationSummary{{}}, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	_, _ = contract.GetOrganizations(ctx)
+	called := deviceRegistry.AssertCalled(s.T(), "GetOrganizations")
+	assert.True(s.T(), called, "should retrieve organizations from device registry")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestList() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("List", 10, "bookmark1").Return(new(common.DevicePage), nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	_, _ = contract.List(ctx, 10, "bookmark1")
+	called := deviceRegistry.AssertCalled(s.T(), "List", 10, "bookmark1")
+	assert.True(s.T(), called, "should retrieve a page of devices from device registry")
+}
+
 func (s *DeviceRegistryContractTestSuite) TestDeregister() {
 	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
 	deviceRegistry := new(MockDeviceRegistry)
@@ -95,6 +241,289 @@ func (s *DeviceRegistryContractTestSuite) TestDeregister() {
 	err = contract.Deregister(ctx, fmt.Sprintf("{\"id\":\"%s\",\"organizationId\":\"%s\"}", ctx.DeviceId, ctx.OrganizationId))
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.IsAdmin = true
+	err = contract.Deregister(ctx, "{\"id\":\"device1\",\"organizationId\":\"org1\"}")
+	assert.Nil(s.T(), err, "should allow organization administrators to deregister devices of the organization")
+	assert.Equal(s.T(), "device://org1/device1/deregister", ctx.stub.EventName, "should emit event with name")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestAuthorizeRekey() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	deviceRegistry.On("Get", "org1", "device1").Return(device, nil)
+	deviceRegistry.On("AuthorizeRekey", device, "device9").Return(nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.AuthorizeRekey(ctx, "org1", "device1", "device9")
+	assert.Nil(s.T(), err, "should return no error")
+	called := deviceRegistry.AssertCalled(s.T(), "AuthorizeRekey", device, "device9")
+	assert.True(s.T(), called, "should authorize rekey in device registry")
+	assert.Equal(s.T(), "device://org1/device1/authorize", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), "device9", string(ctx.stub.EventPayload), "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	ctx.DeviceId = "device2"
+	err = contract.AuthorizeRekey(ctx, "org1", "device1", "device9")
+	assert.Error(s.T(), err, "should refuse to authorize rekey of another device")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestRekey() {
+	ctx := &MockTransactionContext{DeviceId: "device9", OrganizationId: "org1"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("Rekey", "org1", "device9").Return(&common.Device{Id: "device9", OrganizationId: "org1"}, "device1", nil)
+	deviceRegistry.On("Rekey", mock.Anything, mock.Anything).Return(nil, "", new(common.NotFoundError))
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.Rekey(ctx)
+	assert.Nil(s.T(), err, "should return no error")
+	device, _ := common.DeserializeDevice(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "device://org1/device1/rekey", ctx.stub.EventName, "should emit event with the previous device ID")
+	assert.Equal(s.T(), "device9", device.Id, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	ctx.DeviceId = "device8"
+	err = contract.Rekey(ctx)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestHeartbeat() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	status := map[string]string{"battery": "80"}
+	presence := &common.DevicePresence{OrganizationId: "org1", DeviceId: "device1", State: common.DevicePresenceOnline}
+	deviceRegistry.On("Get", "org1", "device1").Return(device, nil)
+	deviceRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	deviceRegistry.On("Heartbeat", device, status).Return(presence, true, nil).Once()
+	deviceRegistry.On("Heartbeat", device, map[string]string(nil)).Return(presence, false, nil).Once()
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.Heartbeat(ctx, "{\"battery\":\"80\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	actual, _ := common.DeserializeDevicePresence(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "device://org1/device1/online", ctx.stub.EventName, "should emit event when the device comes online")
+	assert.Equal(s.T(), presence, actual, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	err = contract.Heartbeat(ctx, "")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event when the device is already online")
+
+	err = contract.Heartbeat(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+
+	ctx.DeviceId = "device2"
+	err = contract.Heartbeat(ctx, "")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestOffline() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	presence := &common.DevicePresence{OrganizationId: "org1", DeviceId: "device1", State: common.DevicePresenceOffline}
+	deviceRegistry.On("Get", "org1", "device1").Return(device, nil)
+	deviceRegistry.On("Offline", device, mock.Anything).Return(presence, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.Offline(ctx, "org1", "device1")
+	assert.Nil(s.T(), err, "should return no error")
+	called := deviceRegistry.AssertCalled(s.T(), "Offline", device, false)
+	assert.True(s.T(), called, "should not force other clients to mark the device offline")
+	assert.Equal(s.T(), "device://org1/device1/offline", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	ctx.DeviceId = "device1"
+	ctx.OrganizationId = "org1"
+	err = contract.Offline(ctx, "org1", "device1")
+	assert.Nil(s.T(), err, "should return no error")
+	called = deviceRegistry.AssertCalled(s.T(), "Offline", device, true)
+	assert.True(s.T(), called, "should allow the device itself to go offline at any time")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestGetPresence() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("GetPresence", "org1", "device1").Return(new(common.DevicePresence), nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	_, _ = contract.GetPresence(ctx, "org1", "device1")
+	called := deviceRegistry.AssertCalled(s.T(), "GetPresence", "org1", "device1")
+	assert.True(s.T(), called, "should retrieve device presence from device registry")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestGetAllByPresence() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("GetAllByPresence", "org1", common.DevicePresenceOnline).Return([]*common.Device{{}}, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	_, _ = contract.GetAllByPresence(ctx, "org1", "online")
+	called := deviceRegistry.AssertCalled(s.T(), "GetAllByPresence", "org1", common.DevicePresenceOnline)
+	assert.True(s.T(), called, "should retrieve devices by presence from device registry")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestUpdateDesired() {
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", IsAdmin: true}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	properties := map[string]string{"interval": "60"}
+	twin := &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Desired: properties, Reported: map[string]string{"interval": "30"}}
+	deviceRegistry.On("Get", "org1", "device1").Return(device, nil)
+	deviceRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	deviceRegistry.On("UpdateDesired", device, properties).Return(twin, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.UpdateDesired(ctx, "org1", "device1", "{\"interval\":\"60\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	event, _ := common.DeserializeCompositeEvent(ctx.stub.EventPayload)
+	assert.Equal(s.T(), 2, len(event.Events), "should emit the update with the delta")
+	assert.Equal(s.T(), "device://org1/device1/desire", event.Events[0].Name, "should emit update event first")
+	assert.Equal(s.T(), "device://org1/device1/delta", event.Events[1].Name, "should emit delta event when desired and reported properties diverge")
+	actual, _ := common.DeserializeDeviceTwin(event.Events[1].Payload)
+	assert.Equal(s.T(), twin, actual, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	err = contract.UpdateDesired(ctx, "org1", "device2", "{}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	err = contract.UpdateDesired(ctx, "org1", "device1", "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+
+	err = contract.UpdateDesired(ctx, "org2", "device1", "{}")
+	assert.Error(s.T(), err, "should not allow updating devices of other organizations")
+
+	ctx.IsAdmin = false
+	ctx.DeviceId = "device1"
+	err = contract.UpdateDesired(ctx, "org1", "device1", "{}")
+	assert.Error(s.T(), err, "should not allow devices to update their desired properties")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestUpdateReported() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	properties := map[string]string{"interval": "60"}
+	twin := &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Desired: properties, Reported: properties}
+	deviceRegistry.On("Get", "org1", "device1").Return(device, nil)
+	deviceRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	deviceRegistry.On("UpdateReported", device, properties).Return(twin, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.UpdateReported(ctx, "{\"interval\":\"60\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device://org1/device1/report", ctx.stub.EventName, "should emit no delta event when desired and reported properties agree")
+	actual, _ := common.DeserializeDeviceTwin(ctx.stub.EventPayload)
+	assert.Equal(s.T(), twin, actual, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	err = contract.UpdateReported(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+
+	ctx.DeviceId = "device2"
+	err = contract.UpdateReported(ctx, "{}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *DeviceRegistryContractTestSuite) TestRevoke() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", Timestamp: now, IsAdmin: true}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	deviceRegistry.On("Revoke", mock.AnythingOfType("*common.DeviceRevocation")).Return(nil)
+
+	data := "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serialNumber\":\"0x001A\",\"revokerId\":\"admin9\"}"
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.Revoke(ctx, data)
+	assert.Nil(s.T(), err, "should return no error")
+	revocation := deviceRegistry.Calls[0].Arguments[0].(*common.DeviceRevocation)
+	assert.Equal(s.T(), "admin1", revocation.RevokerId, "should ignore client-supplied revoker")
+	assert.Equal(s.T(), "1a", revocation.SerialNumber, "should normalize the serial number")
+	assert.Equal(s.T(), now, revocation.Time, "should record transaction time")
+	assert.Equal(s.T(), "device://org1/device1/revoke", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.Revoke(ctx, "{\"organizationId\":\"org1\",\"deviceId\":\"admin1\"}")
+	assert.Error(s.T(), err, "should refuse to revoke the invoking identity")
+
+	err = contract.Revoke(ctx, "{\"organizationId\":\"org2\",\"deviceId\":\"device1\"}")
+	assert.Error(s.T(), err, "should refuse to revoke devices of other organizations")
+
+	ctx.IsAdmin = false
+	err = contract.Revoke(ctx, data)
+	assert.Error(s.T(), err, "should refuse to revoke by clients other than administrators")
+
+	err = contract.Revoke(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "Revoke", 1)
+}
+
+func (s *DeviceRegistryContractTestSuite) TestReinstate() {
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", IsAdmin: true}
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	revocation := &common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1", SerialNumber: "1a"}
+	deviceRegistry.On("Reinstate", "org1", "device1", "1a").Return(revocation, nil)
+	deviceRegistry.On("Reinstate", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+
+	contract := new(DeviceRegistrySmartContract)
+	err := contract.Reinstate(ctx, "org1", "device1", "0x1A")
+	assert.Nil(s.T(), err, "should return no error")
+	actual, _ := common.DeserializeDeviceRevocation(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "device://org1/device1/reinstate", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), revocation, actual, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	err = contract.Reinstate(ctx, "org1", "device2", "")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	err = contract.Reinstate(ctx, "org2", "device1", "1a")
+	assert.Error(s.T(), err, "should refuse to reinstate devices of other organizations")
+
+	ctx.IsAdmin = false
+	err = contract.Reinstate(ctx, "org1", "device1", "1a")
+	assert.Error(s.T(), err, "should refuse to reinstate by clients other than administrators")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "Reinstate", 2)
+}
+
+func (s *DeviceRegistryContractTestSuite) TestGetRevocations() {
+	ctx := new(MockTransactionContext)
+	deviceRegistry := new(MockDeviceRegistry)
+	ctx.deviceRegistry = deviceRegistry
+
+	expected := []*common.DeviceRevocation{{OrganizationId: "org1", DeviceId: "device1"}}
+	deviceRegistry.On("GetRevocations", "org1").Return(expected, nil)
+
+	contract := new(DeviceRegistrySmartContract)
+	actual, err := contract.GetRevocations(ctx, "org1")
+	assert.Equal(s.T(), expected, actual, "should return revocations of the organization")
+	assert.Nil(s.T(), err, "should return no error")
 }
 
 func TestDeviceRegistryContractTestSuite(t *testing.T) {
@@ -1,7 +1,10 @@
 package contract
 
 import (
+	"crypto/x509"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
@@ -31,46 +34,211 @@ func (r *MockDeviceRegistry) GetAll(organizationId string) ([]*common.Device, er
 	return args.Get(0).([]*common.Device), args.Error(1)
 }
 
+func (r *MockDeviceRegistry) GetOrganizations() ([]*common.OrganizationSummary, error) {
+	args := r.Called()
+	return args.Get(0).([]*common.OrganizationSummary), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) UpdateOrganization(organizationId string, devices int, services int) error {
+	args := r.Called(organizationId, devices, services)
+	return args.Error(0)
+}
+
+func (r *MockDeviceRegistry) List(pageSize int, bookmark string) (*common.DevicePage, error) {
+	args := r.Called(pageSize, bookmark)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DevicePage), args.Error(1)
+}
+
 func (r *MockDeviceRegistry) Deregister(device *common.Device) error {
 	args := r.Called(device)
 	return args.Error(0)
 }
 
+func (r *MockDeviceRegistry) AuthorizeRekey(device *common.Device, newDeviceId string) error {
+	args := r.Called(device, newDeviceId)
+	return args.Error(0)
+}
+
+func (r *MockDeviceRegistry) Rekey(organizationId string, newDeviceId string) (*common.Device, string, error) {
+	args := r.Called(organizationId, newDeviceId)
+	if args.Get(0) == nil {
+		return nil, "", args.Error(2)
+	}
+	return args.Get(0).(*common.Device), args.String(1), args.Error(2)
+}
+
+func (r *MockDeviceRegistry) Resolve(organizationId string, deviceId string) (string, error) {
+	args := r.Called(organizationId, deviceId)
+	return args.String(0), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) Heartbeat(device *common.Device, status map[string]string) (*common.DevicePresence, bool, error) {
+	args := r.Called(device, status)
+	if args.Get(0) == nil {
+		return nil, false, args.Error(2)
+	}
+	return args.Get(0).(*common.DevicePresence), args.Bool(1), args.Error(2)
+}
+
+func (r *MockDeviceRegistry) Offline(device *common.Device, force bool) (*common.DevicePresence, error) {
+	args := r.Called(device, force)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DevicePresence), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) GetPresence(organizationId string, deviceId string) (*common.DevicePresence, error) {
+	args := r.Called(organizationId, deviceId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DevicePresence), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) GetAllByPresence(organizationId string, state common.DevicePresenceState) ([]*common.Device, error) {
+	args := r.Called(organizationId, state)
+	return args.Get(0).([]*common.Device), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) GetTwin(organizationId string, deviceId string) (*common.DeviceTwin, error) {
+	args := r.Called(organizationId, deviceId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceTwin), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) UpdateDesired(device *common.Device, properties map[string]string) (*common.DeviceTwin, error) {
+	args := r.Called(device, properties)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceTwin), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) UpdateReported(device *common.Device, properties map[string]string) (*common.DeviceTwin, error) {
+	args := r.Called(device, properties)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceTwin), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) AuthorizeEnrollment(enrollment *common.DeviceEnrollment) error {
+	args := r.Called(enrollment)
+	return args.Error(0)
+}
+
+func (r *MockDeviceRegistry) GetEnrollment(organizationId string, enrollmentId string) (*common.DeviceEnrollment, error) {
+	args := r.Called(organizationId, enrollmentId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceEnrollment), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) GetEnrollments(organizationId string) ([]*common.DeviceEnrollment, error) {
+	args := r.Called(organizationId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*common.DeviceEnrollment), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) Enroll(device *common.Device, cert *x509.Certificate, claimCode string) (*common.DeviceEnrollment, error) {
+	args := r.Called(device, cert, claimCode)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceEnrollment), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) Revoke(revocation *common.DeviceRevocation) error {
+	args := r.Called(revocation)
+	return args.Error(0)
+}
+
+func (r *MockDeviceRegistry) Reinstate(organizationId string, deviceId string, serialNumber string) (*common.DeviceRevocation, error) {
+	args := r.Called(organizationId, deviceId, serialNumber)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.DeviceRevocation), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) GetRevocations(organizationId string) ([]*common.DeviceRevocation, error) {
+	args := r.Called(organizationId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*common.DeviceRevocation), args.Error(1)
+}
+
+func (r *MockDeviceRegistry) IsRevoked(organizationId string, deviceId string, serialNumber string) (bool, error) {
+	args := r.Called(organizationId, deviceId, serialNumber)
+	return args.Bool(0), args.Error(1)
+}
+
 type DeviceRegistryTestSuite struct {
 	suite.Suite
 }
 
 func (s *DeviceRegistryTestSuite) TestRegister() {
 	stateRegistry := new(MockStateRegistry)
+	summaryRegistry := new(MockStateRegistry)
 
 	deviceRegistry := new(DeviceRegistry)
 	deviceRegistry.ctx = new(MockTransactionContext)
 	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.summaryRegistry = summaryRegistry
 
-	device := new(common.Device)
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	stateRegistry.On("GetState", []string{"org1", "device1"}).Return(nil, new(common.NotFoundError)).Once()
+	stateRegistry.On("GetState", []string{"org1", "device1"}).Return(device, nil)
 	stateRegistry.On("PutState", device).Return(nil)
+	summaryRegistry.On("GetState", []string{"org1"}).Return(&common.OrganizationSummary{OrganizationId: "org1", Devices: 1}, nil)
+	summaryRegistry.On("PutState", mock.Anything).Return(nil)
 
 	err := deviceRegistry.Register(device)
 	called := stateRegistry.AssertCalled(s.T(), "PutState", device)
 	assert.True(s.T(), called, "should put device to state registry")
 	assert.Nil(s.T(), err, "should return no error")
+	called = summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org1", Devices: 2})
+	assert.True(s.T(), called, "should count new device in the organization summary")
+
+	err = deviceRegistry.Register(device)
+	assert.Nil(s.T(), err, "should return no error")
+	summaryRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
 }
 
 func (s *DeviceRegistryTestSuite) TestGet() {
 	stateRegistry := new(MockStateRegistry)
+	aliasRegistry := new(MockStateRegistry)
 
 	deviceRegistry := new(DeviceRegistry)
 	deviceRegistry.ctx = new(MockTransactionContext)
 	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.aliasRegistry = aliasRegistry
 
 	device := new(common.Device)
 	stateRegistry.On("GetState", []string{"org1", "device1"}).Return(device, nil)
 	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	aliasRegistry.On("GetState", []string{"org1", "device0"}).Return(&deviceAlias{NewDeviceId: "device1", ExpiryTime: time.Now().Add(time.Hour)}, nil)
+	aliasRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
 
 	result, err := deviceRegistry.Get("org1", "device1")
 	assert.Equal(s.T(), device, result, "should return the correct device")
 	assert.Nil(s.T(), err, "should return no error")
 
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: time.Now()}
+	result, err = deviceRegistry.Get("org1", "device0")
+	assert.Equal(s.T(), device, result, "should return the device by its previous ID")
+	assert.Nil(s.T(), err, "should return no error")
+
 	result, err = deviceRegistry.Get("org2", "device2")
 	assert.Nil(s.T(), result, "should return no device")
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
@@ -99,6 +267,121 @@ func (s *DeviceRegistryTestSuite) TestGetAll() {
 	assert.Nil(s.T(), err, "should return no error")
 }
 
+func (s *DeviceRegistryTestSuite) TestGetOrganizations() {
+	summaryRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.summaryRegistry = summaryRegistry
+
+	summaries := []StateInterface{
+		&common.OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3},
+		&common.OrganizationSummary{OrganizationId: "org2", Devices: 1, Services: 1},
+	}
+	summaryRegistry.On("GetStates", []string(nil)).Return(summaries, nil)
+
+	results, err := deviceRegistry.GetOrganizations()
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.OrganizationSummary{
+		{OrganizationId: "org1", Devices: 2, Services: 3},
+		{OrganizationId: "org2", Devices: 1, Services: 1},
+	}, results, "should return organizations with their device and service counts")
+}
+
+func (s *DeviceRegistryTestSuite) TestUpdateOrganization() {
+	summaryRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.summaryRegistry = summaryRegistry
+
+	summaryRegistry.On("GetState", []string{"org1"}).Return(&common.OrganizationSummary{OrganizationId: "org1", Devices: 1, Services: 1}, nil)
+	summaryRegistry.On("GetState", []string{"org2"}).Return(nil, new(common.NotFoundError))
+	summaryRegistry.On("PutState", mock.Anything).Return(nil)
+	summaryRegistry.On("RemoveState", mock.Anything).Return(nil)
+
+	err := deviceRegistry.UpdateOrganization("org1", 0, 2)
+	assert.Nil(s.T(), err, "should return no error")
+	summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org1", Devices: 1, Services: 3})
+
+	_ = deviceRegistry.UpdateOrganization("org1", 1, 0)
+	summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3})
+	summaryRegistry.AssertNumberOfCalls(s.T(), "GetState", 1)
+
+	_ = deviceRegistry.UpdateOrganization("org1", -2, -3)
+	summaryRegistry.AssertCalled(s.T(), "RemoveState", &common.OrganizationSummary{OrganizationId: "org1"})
+
+	_ = deviceRegistry.UpdateOrganization("org2", -1, -1)
+	summaryRegistry.AssertCalled(s.T(), "RemoveState", &common.OrganizationSummary{OrganizationId: "org2"})
+
+	_ = deviceRegistry.UpdateOrganization("org2", 1, 0)
+	summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org2", Devices: 1})
+}
+
+func (s *DeviceRegistryTestSuite) TestMigrateSummaries() {
+	stateRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	summaryRegistry := new(MockStateRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceRegistry = serviceRegistry
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = transactionContext
+	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.summaryRegistry = summaryRegistry
+
+	devices := []StateInterface{
+		&common.Device{Id: "device1", OrganizationId: "org1"},
+		&common.Device{Id: "device2", OrganizationId: "org1"},
+		&common.Device{Id: "device3", OrganizationId: "org2"},
+	}
+	stateRegistry.On("GetChunk", 2, "", []string(nil)).Return(devices[:2], "k2", nil)
+	stateRegistry.On("GetStates", []string{"org1"}).Return(devices[:2], nil)
+	serviceRegistry.On("GetAll", "org1", "device1").Return([]*common.Service{{}, {}}, nil)
+	serviceRegistry.On("GetAll", mock.Anything, mock.Anything).Return([]*common.Service{{}}, nil)
+	summaryRegistry.On("PutState", mock.Anything).Return(nil)
+
+	bookmark, err := deviceRegistry.migrateSummaries("", 2)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "k2", bookmark, "should return resume key of the next chunk")
+	summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org1", Devices: 2, Services: 3})
+	summaryRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *DeviceRegistryTestSuite) TestList() {
+	stateRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.stateRegistry = stateRegistry
+
+	devices := []StateInterface{
+		&common.Device{Id: "device1", OrganizationId: "org1"},
+		&common.Device{Id: "device2", OrganizationId: "org1"},
+		&common.Device{Id: "device3", OrganizationId: "org2"},
+	}
+	stateRegistry.On("GetPage", 2, "", []string(nil)).Return(devices[:2], "bookmark1", nil)
+	stateRegistry.On("GetPage", 2, "bookmark1", []string(nil)).Return(devices[2:], "", nil)
+	stateRegistry.On("GetPage", 2, "!", []string(nil)).Return(nil, "", fmt.Errorf("invalid bookmark !"))
+
+	page, err := deviceRegistry.List(2, "")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Device{devices[0].(*common.Device), devices[1].(*common.Device)}, page.Devices, "should return the first page of devices")
+	assert.Equal(s.T(), "bookmark1", page.Bookmark, "should return bookmark of the next page")
+
+	page, err = deviceRegistry.List(2, page.Bookmark)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Device{devices[2].(*common.Device)}, page.Devices, "should return the next page of devices")
+	assert.Empty(s.T(), page.Bookmark, "should return no bookmark on the last page")
+
+	_, err = deviceRegistry.List(-1, "")
+	assert.Error(s.T(), err, "should return invalid page size error")
+
+	_, err = deviceRegistry.List(2, "!")
+	assert.Error(s.T(), err, "should return invalid bookmark error")
+}
+
 func (s *DeviceRegistryTestSuite) TestDeregister() {
 	stateRegistry := new(MockStateRegistry)
 	serviceRegistry := new(MockServiceRegistry)
@@ -106,27 +389,550 @@ func (s *DeviceRegistryTestSuite) TestDeregister() {
 
 	transactionContext.serviceRegistry = serviceRegistry
 
+	presenceRegistry := new(MockStateRegistry)
+	twinRegistry := new(MockStateRegistry)
+	summaryRegistry := new(MockStateRegistry)
 	deviceRegistry := new(DeviceRegistry)
 	deviceRegistry.ctx = transactionContext
 	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.presenceRegistry = presenceRegistry
+	deviceRegistry.twinRegistry = twinRegistry
+	deviceRegistry.summaryRegistry = summaryRegistry
 
 	device := new(common.Device)
 	device.Id = "device1"
 	device.OrganizationId = "org1"
 
-	services := []*common.Service{new(common.Service), new(common.Service)}
+	services := []*common.Service{
+		{Name: "service1", DeviceId: "device1", OrganizationId: "org1"},
+		{Name: "service2", DeviceId: "device1", OrganizationId: "org1"},
+	}
 
 	serviceRegistry.On("GetAll", "org1", "device1").Return(services, nil)
 	serviceRegistry.On("Deregister", mock.AnythingOfType("*common.Service")).Return(nil)
 	stateRegistry.On("RemoveState", device).Return(nil)
+	presenceRegistry.On("RemoveState", mock.Anything).Return(new(common.NotFoundError))
+	twinRegistry.On("RemoveState", mock.Anything).Return(nil)
+	summaryRegistry.On("GetState", []string{"org1"}).Return(&common.OrganizationSummary{OrganizationId: "org1", Devices: 2}, nil)
+	summaryRegistry.On("PutState", mock.Anything).Return(nil)
 
 	err := deviceRegistry.Deregister(device)
 	called := stateRegistry.AssertCalled(s.T(), "RemoveState", device)
 	assert.True(s.T(), called, "should remove device from state registry")
 	assert.Nil(s.T(), err, "should return no error")
+	called = twinRegistry.AssertCalled(s.T(), "RemoveState", &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1"})
+	assert.True(s.T(), called, "should remove twin of the device")
+	called = summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org1", Devices: 1})
+	assert.True(s.T(), called, "should uncount device from the organization summary")
 
 	called = serviceRegistry.AssertCalled(s.T(), "Deregister", services[1])
 	assert.True(s.T(), called, "should deregister service by the service registry")
+	assert.Equal(s.T(), len(services), len(transactionContext.events), "should record cascaded deregistration of services")
+	assert.Equal(s.T(), "service://org1/device1/service1/deregister", transactionContext.events[0].GetLegacyName(), "should record cascaded deregistration of services")
+}
+
+func (s *DeviceRegistryTestSuite) TestAuthorizeRekey() {
+	authorizationRegistry := new(MockStateRegistry)
+	revocationRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.authorizationRegistry = authorizationRegistry
+	deviceRegistry.revocationRegistry = revocationRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	authorizationRegistry.On("PutState", mock.Anything).Return(nil)
+	revocationRegistry.On("GetStates", []string{"org1", "device3"}).Return([]StateInterface{
+		&common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device3"},
+	}, nil)
+	revocationRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+
+	err := deviceRegistry.AuthorizeRekey(device, "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	authorization := authorizationRegistry.Calls[0].Arguments[0].(*deviceRekeyAuthorization)
+	assert.Equal(s.T(), []string{"org1", "device2"}, authorization.GetKeyComponents(), "should key authorization by the new identity")
+	assert.Equal(s.T(), "device1", authorization.DeviceId, "should record the authorizing device")
+
+	err = deviceRegistry.AuthorizeRekey(device, "device1")
+	assert.Error(s.T(), err, "should refuse to authorize the same identity")
+	err = deviceRegistry.AuthorizeRekey(device, "")
+	assert.Error(s.T(), err, "should refuse to authorize an empty identity")
+
+	err = deviceRegistry.AuthorizeRekey(&common.Device{Id: "device3", OrganizationId: "org1"}, "device4")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse to rekey revoked devices")
+	authorizationRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *DeviceRegistryTestSuite) TestRekey() {
+	stateRegistry := new(MockStateRegistry)
+	aliasRegistry := new(MockStateRegistry)
+	authorizationRegistry := new(MockStateRegistry)
+	presenceRegistry := new(MockStateRegistry)
+	twinRegistry := new(MockStateRegistry)
+	revocationRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	serviceBroker := new(MockServiceBroker)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	transactionContext := &MockTransactionContext{Timestamp: now}
+
+	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.serviceBroker = serviceBroker
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = transactionContext
+	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.aliasRegistry = aliasRegistry
+	deviceRegistry.authorizationRegistry = authorizationRegistry
+	deviceRegistry.presenceRegistry = presenceRegistry
+	deviceRegistry.twinRegistry = twinRegistry
+	deviceRegistry.revocationRegistry = revocationRegistry
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	twin := &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Desired: map[string]string{"interval": "60"}}
+	service := &common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"}
+	pairs := []*common.ServiceRequestResponse{{Request: &common.ServiceRequest{Id: "request1"}}}
+
+	authorizationRegistry.On("GetState", []string{"org1", "device2"}).Return(&deviceRekeyAuthorization{OrganizationId: "org1", NewDeviceId: "device2", DeviceId: "device1"}, nil)
+	authorizationRegistry.On("GetState", []string{"org1", "device3"}).Return(&deviceRekeyAuthorization{OrganizationId: "org1", NewDeviceId: "device3", DeviceId: "device1"}, nil)
+	authorizationRegistry.On("GetState", []string{"org1", "device6"}).Return(&deviceRekeyAuthorization{OrganizationId: "org1", NewDeviceId: "device6", DeviceId: "device5"}, nil)
+	authorizationRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	authorizationRegistry.On("RemoveState", mock.Anything).Return(nil)
+	stateRegistry.On("GetState", []string{"org1", "device1"}).Return(device, nil)
+	stateRegistry.On("GetState", []string{"org1", "device3"}).Return(new(common.Device), nil)
+	stateRegistry.On("GetState", []string{"org1", "device5"}).Return(&common.Device{Id: "device5", OrganizationId: "org1"}, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	stateRegistry.On("RemoveState", mock.Anything).Return(nil)
+	stateRegistry.On("PutState", mock.Anything).Return(nil)
+	aliasRegistry.On("PutState", mock.Anything).Return(nil)
+	presenceRegistry.On("RemoveState", mock.Anything).Return(nil)
+	twinRegistry.On("GetState", []string{"org1", "device1"}).Return(twin, nil)
+	twinRegistry.On("RemoveState", mock.Anything).Return(nil)
+	twinRegistry.On("PutState", mock.Anything).Return(nil)
+	revocationRegistry.On("GetStates", []string{"org1", "device5"}).Return([]StateInterface{
+		&common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device5"},
+	}, nil)
+	revocationRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+	serviceRegistry.On("GetAll", "org1", "device1").Return([]*common.Service{service}, nil)
+	serviceRegistry.On("Rekey", service, "device2").Return(nil)
+	serviceBroker.On("RekeyDevice", "org1", "device1", "device2").Return(nil)
+	serviceBroker.On("GetAllByRequester", "org1", "device1").Return(pairs, nil)
+	serviceBroker.On("Rekey", "request1", "org1", "device1", "device2").Return(nil)
+
+	result, previousId, err := deviceRegistry.Rekey("org1", "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device1", previousId, "should return the previous device ID")
+	assert.Equal(s.T(), "device2", result.Id, "should change device ID")
+	called := serviceRegistry.AssertCalled(s.T(), "Rekey", service, "device2")
+	assert.True(s.T(), called, "should move services of the device")
+	called = serviceBroker.AssertCalled(s.T(), "RekeyDevice", "org1", "device1", "device2")
+	assert.True(s.T(), called, "should move stream heads and quota usages of the device")
+	called = serviceBroker.AssertCalled(s.T(), "Rekey", "request1", "org1", "device1", "device2")
+	assert.True(s.T(), called, "should move requests made by the device")
+	called = authorizationRegistry.AssertCalled(s.T(), "RemoveState", mock.Anything)
+	assert.True(s.T(), called, "should consume the authorization")
+	moved := twinRegistry.Calls[2].Arguments[0].(*common.DeviceTwin)
+	assert.Equal(s.T(), []string{"org1", "device2"}, moved.GetKeyComponents(), "should move the twin to the new ID")
+	assert.Equal(s.T(), map[string]string{"interval": "60"}, moved.Desired, "should keep the twin properties")
+	alias := aliasRegistry.Calls[0].Arguments[0].(*deviceAlias)
+	assert.Equal(s.T(), []string{"org1", "device1"}, alias.GetKeyComponents(), "should keep the previous ID as an alias")
+	assert.Equal(s.T(), "device2", alias.NewDeviceId, "should point the alias to the new ID")
+	assert.True(s.T(), now.Add(DeviceAliasGracePeriod).Equal(alias.ExpiryTime), "should expire the alias after the grace period")
+
+	_, _, err = deviceRegistry.Rekey("org1", "device3")
+	assert.EqualError(s.T(), err, "device already exists", "should refuse to take over by an existing device")
+
+	_, _, err = deviceRegistry.Rekey("org1", "device4")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return authorization not found error")
+
+	_, _, err = deviceRegistry.Rekey("org1", "device6")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse to rekey devices revoked after the authorization")
+	serviceRegistry.AssertNotCalled(s.T(), "GetAll", "org1", "device5")
+}
+
+func (s *DeviceRegistryTestSuite) TestResolve() {
+	aliasRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.aliasRegistry = aliasRegistry
+
+	aliasRegistry.On("GetState", []string{"org1", "device1"}).Return(&deviceAlias{NewDeviceId: "device2", ExpiryTime: now.Add(time.Hour)}, nil)
+	aliasRegistry.On("GetState", []string{"org1", "device3"}).Return(&deviceAlias{NewDeviceId: "device4", ExpiryTime: now.Add(-time.Hour)}, nil)
+	aliasRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	deviceId, err := deviceRegistry.Resolve("org1", "device1")
+	assert.Equal(s.T(), "device2", deviceId, "should resolve to the new device ID")
+	assert.Nil(s.T(), err, "should return no error")
+
+	deviceId, _ = deviceRegistry.Resolve("org1", "device3")
+	assert.Equal(s.T(), "device3", deviceId, "should not resolve expired alias")
+
+	deviceId, _ = deviceRegistry.Resolve("org1", "device5")
+	assert.Equal(s.T(), "device5", deviceId, "should return the same ID without alias")
+}
+
+func (s *DeviceRegistryTestSuite) TestHeartbeat() {
+	presenceRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.presenceRegistry = presenceRegistry
+
+	presenceRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.DevicePresence{OrganizationId: "org1", DeviceId: "device1", State: common.DevicePresenceOnline, LastSeenTime: now.Add(-time.Minute)}, nil)
+	presenceRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	presenceRegistry.On("PutState", mock.Anything).Return(nil)
+
+	status := map[string]string{"battery": "80"}
+	presence, online, err := deviceRegistry.Heartbeat(&common.Device{Id: "device1", OrganizationId: "org1"}, status)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.False(s.T(), online, "should not report an online device as coming online")
+	assert.Equal(s.T(), now, presence.LastSeenTime, "should record the last seen time")
+	assert.Equal(s.T(), status, presence.Status, "should record the status fields")
+	called := presenceRegistry.AssertCalled(s.T(), "PutState", presence)
+	assert.True(s.T(), called, "should put presence to state registry")
+
+	presence, online, err = deviceRegistry.Heartbeat(&common.Device{Id: "device2", OrganizationId: "org1"}, nil)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.True(s.T(), online, "should report a device without presence as coming online")
+	assert.Equal(s.T(), common.DevicePresenceOnline, presence.State, "should mark the device as online")
+	assert.Equal(s.T(), []string{"org1", "device2"}, presence.GetKeyComponents(), "should create presence of the device")
+}
+
+func (s *DeviceRegistryTestSuite) TestOffline() {
+	presenceRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.presenceRegistry = presenceRegistry
+
+	presenceRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.DevicePresence{State: common.DevicePresenceOnline, LastSeenTime: now.Add(-time.Minute)}, nil)
+	presenceRegistry.On("GetState", []string{"org1", "device2"}).Return(&common.DevicePresence{State: common.DevicePresenceOnline, LastSeenTime: now.Add(-time.Minute)}, nil)
+	presenceRegistry.On("GetState", []string{"org1", "device3"}).Return(&common.DevicePresence{State: common.DevicePresenceOffline, LastSeenTime: now.Add(-time.Hour)}, nil)
+	presenceRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	presenceRegistry.On("PutState", mock.Anything).Return(nil)
+
+	_, err := deviceRegistry.Offline(&common.Device{Id: "device1", OrganizationId: "org1"}, false)
+	assert.Error(s.T(), err, "should refuse to mark a device offline within its heartbeat timeout")
+
+	presence, err := deviceRegistry.Offline(&common.Device{Id: "device1", OrganizationId: "org1"}, true)
+	assert.Nil(s.T(), err, "should allow forcing a device offline")
+	assert.Equal(s.T(), common.DevicePresenceOffline, presence.State, "should mark the device as offline")
+
+	presence, err = deviceRegistry.Offline(&common.Device{Id: "device2", OrganizationId: "org1", HeartbeatTimeout: 30}, false)
+	assert.Nil(s.T(), err, "should allow marking a device offline after its heartbeat timeout")
+	assert.Equal(s.T(), common.DevicePresenceOffline, presence.State, "should mark the device as offline")
+
+	_, err = deviceRegistry.Offline(&common.Device{Id: "device3", OrganizationId: "org1"}, true)
+	assert.Error(s.T(), err, "should refuse to mark an offline device offline")
+
+	_, err = deviceRegistry.Offline(&common.Device{Id: "device4", OrganizationId: "org1"}, true)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetPresence() {
+	stateRegistry := new(MockStateRegistry)
+	presenceRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.presenceRegistry = presenceRegistry
+
+	stateRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.Device{Id: "device1", OrganizationId: "org1"}, nil)
+	stateRegistry.On("GetState", []string{"org1", "device2"}).Return(&common.Device{Id: "device2", OrganizationId: "org1", HeartbeatTimeout: 30}, nil)
+	stateRegistry.On("GetState", []string{"org1", "device3"}).Return(&common.Device{Id: "device3", OrganizationId: "org1"}, nil)
+	presenceRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.DevicePresence{State: common.DevicePresenceOnline, LastSeenTime: now.Add(-time.Minute)}, nil)
+	presenceRegistry.On("GetState", []string{"org1", "device2"}).Return(&common.DevicePresence{State: common.DevicePresenceOnline, LastSeenTime: now.Add(-time.Minute)}, nil)
+	presenceRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	presence, err := deviceRegistry.GetPresence("org1", "device1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.DevicePresenceOnline, presence.State, "should be online within the default heartbeat timeout")
+
+	presence, err = deviceRegistry.GetPresence("org1", "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.DevicePresenceOffline, presence.State, "should be offline after the heartbeat timeout of the device")
+
+	presence, err = deviceRegistry.GetPresence("org1", "device3")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.DevicePresenceOffline, presence.State, "should be offline if the device has never sent a heartbeat")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetAllByPresence() {
+	stateRegistry := new(MockStateRegistry)
+	presenceRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.presenceRegistry = presenceRegistry
+
+	devices := []StateInterface{
+		&common.Device{Id: "device1", OrganizationId: "org1"},
+		&common.Device{Id: "device2", OrganizationId: "org1"},
+	}
+	stateRegistry.On("GetStates", []string{"org1"}).Return(devices, nil)
+	presenceRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.DevicePresence{State: common.DevicePresenceOnline, LastSeenTime: now}, nil)
+	presenceRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	results, err := deviceRegistry.GetAllByPresence("org1", common.DevicePresenceOnline)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Device{devices[0].(*common.Device)}, results, "should return online devices")
+
+	results, err = deviceRegistry.GetAllByPresence("org1", common.DevicePresenceOffline)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Device{devices[1].(*common.Device)}, results, "should return offline devices")
+
+	results, err = deviceRegistry.GetAllByPresence("org1", "")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), 2, len(results), "should return all devices")
+
+	_, err = deviceRegistry.GetAllByPresence("org1", "away")
+	assert.Error(s.T(), err, "should return invalid presence state error")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetTwin() {
+	stateRegistry := new(MockStateRegistry)
+	twinRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.twinRegistry = twinRegistry
+
+	expected := &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Desired: map[string]string{"interval": "60"}, Version: 1}
+	stateRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.Device{Id: "device1", OrganizationId: "org1"}, nil)
+	stateRegistry.On("GetState", []string{"org1", "device2"}).Return(&common.Device{Id: "device2", OrganizationId: "org1"}, nil)
+	twinRegistry.On("GetState", []string{"org1", "device1"}).Return(expected, nil)
+	twinRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	twin, err := deviceRegistry.GetTwin("org1", "device1")
+	assert.Equal(s.T(), expected, twin, "should return twin of the device")
+	assert.Nil(s.T(), err, "should return no error")
+
+	twin, err = deviceRegistry.GetTwin("org1", "device2")
+	assert.Equal(s.T(), &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device2"}, twin, "should return empty twin if the device has never been configured")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceRegistryTestSuite) TestUpdateTwin() {
+	twinRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.twinRegistry = twinRegistry
+
+	twinRegistry.On("GetState", []string{"org1", "device1"}).Return(&common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Reported: map[string]string{"interval": "30"}, Version: 3}, nil)
+	twinRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	twinRegistry.On("PutState", mock.Anything).Return(nil)
+
+	twin, err := deviceRegistry.UpdateDesired(&common.Device{Id: "device1", OrganizationId: "org1"}, map[string]string{"interval": "60"})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), map[string]string{"interval": "60"}, twin.Desired, "should update desired properties")
+	assert.Equal(s.T(), map[string]string{"interval": "30"}, twin.Reported, "should keep reported properties")
+	assert.Equal(s.T(), int64(4), twin.Version, "should increase twin version")
+	assert.Equal(s.T(), now, twin.LastUpdateTime, "should record transaction time")
+	called := twinRegistry.AssertCalled(s.T(), "PutState", twin)
+	assert.True(s.T(), called, "should put twin to state registry")
+
+	twin, err = deviceRegistry.UpdateReported(&common.Device{Id: "device2", OrganizationId: "org1"}, map[string]string{"interval": "30"})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []string{"org1", "device2"}, twin.GetKeyComponents(), "should create twin of the device")
+	assert.Equal(s.T(), map[string]string{"interval": "30"}, twin.Reported, "should update reported properties")
+	assert.Equal(s.T(), int64(1), twin.Version, "should start twin version from one")
+}
+
+func (s *DeviceRegistryTestSuite) TestAuthorizeEnrollment() {
+	enrollmentRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.enrollmentRegistry = enrollmentRegistry
+
+	enrollmentRegistry.On("GetState", []string{"org1", "enrollment1"}).Return(new(common.DeviceEnrollment), nil)
+	enrollmentRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	enrollmentRegistry.On("PutState", mock.Anything).Return(nil)
+
+	enrollment := &common.DeviceEnrollment{Id: "enrollment2", OrganizationId: "org1"}
+	err := deviceRegistry.AuthorizeEnrollment(enrollment)
+	assert.Nil(s.T(), err, "should return no error")
+	called := enrollmentRegistry.AssertCalled(s.T(), "PutState", enrollment)
+	assert.True(s.T(), called, "should put enrollment to state registry")
+
+	err = deviceRegistry.AuthorizeEnrollment(&common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1"})
+	assert.Error(s.T(), err, "should refuse to overwrite an existing enrollment")
+	enrollmentRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *DeviceRegistryTestSuite) TestGetEnrollments() {
+	enrollmentRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.enrollmentRegistry = enrollmentRegistry
+
+	expected := &common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1"}
+	enrollmentRegistry.On("GetState", []string{"org1", "enrollment1"}).Return(expected, nil)
+	enrollmentRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	enrollmentRegistry.On("GetStates", []string{"org1"}).Return([]StateInterface{expected}, nil)
+
+	enrollment, err := deviceRegistry.GetEnrollment("org1", "enrollment1")
+	assert.Equal(s.T(), expected, enrollment, "should return the enrollment")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.GetEnrollment("org1", "enrollment2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	enrollments, err := deviceRegistry.GetEnrollments("org1")
+	assert.Equal(s.T(), []*common.DeviceEnrollment{expected}, enrollments, "should return enrollments of the organization")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceRegistryTestSuite) TestEnroll() {
+	stateRegistry := new(MockStateRegistry)
+	enrollmentRegistry := new(MockStateRegistry)
+	summaryRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	deviceRegistry.stateRegistry = stateRegistry
+	deviceRegistry.enrollmentRegistry = enrollmentRegistry
+	deviceRegistry.summaryRegistry = summaryRegistry
+
+	cert, _ := common.ParseCertificate([]byte(CERTIFICATE))
+	subject := common.GetSubjectDN(cert)
+	consumed := &common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1", Subject: subject, ExpiryTime: now.Add(time.Hour), DeviceId: "device0"}
+	expired := &common.DeviceEnrollment{Id: "enrollment2", OrganizationId: "org1", Subject: subject, ExpiryTime: now}
+	other := &common.DeviceEnrollment{Id: "enrollment3", OrganizationId: "org1", Subject: "CN=device9", ExpiryTime: now.Add(time.Hour)}
+	claimed := &common.DeviceEnrollment{Id: "enrollment4", OrganizationId: "org1", Subject: subject, ClaimCodeHash: common.HashClaimCode("code1"), ExpiryTime: now.Add(time.Hour)}
+
+	stateRegistry.On("GetState", []string{"org1", "device2"}).Return(new(common.Device), nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	stateRegistry.On("PutState", mock.Anything).Return(nil)
+	enrollmentRegistry.On("GetStates", []string{"org1"}).Return([]StateInterface{consumed, expired, other, claimed}, nil)
+	enrollmentRegistry.On("GetStates", []string{"org2"}).Return([]StateInterface{}, nil)
+	enrollmentRegistry.On("PutState", mock.Anything).Return(nil)
+	summaryRegistry.On("GetState", []string{"org1"}).Return(nil, new(common.NotFoundError))
+	summaryRegistry.On("PutState", mock.Anything).Return(nil)
+
+	device := &common.Device{Id: "device1", OrganizationId: "org1"}
+	_, err := deviceRegistry.Enroll(device, cert, "")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse device matching only expired enrollments")
+	assert.Regexp(s.T(), "expired", err.Error())
+
+	_, err = deviceRegistry.Enroll(device, cert, "code2")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse device with a wrong claim code")
+
+	enrollment, err := deviceRegistry.Enroll(device, cert, "code1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "enrollment4", enrollment.Id, "should consume the matching enrollment")
+	assert.Equal(s.T(), "device1", enrollment.DeviceId, "should record the enrolled device")
+	assert.Equal(s.T(), now, enrollment.ConsumeTime, "should record transaction time")
+	called := enrollmentRegistry.AssertCalled(s.T(), "PutState", enrollment)
+	assert.True(s.T(), called, "should keep the consumed enrollment")
+	called = stateRegistry.AssertCalled(s.T(), "PutState", device)
+	assert.True(s.T(), called, "should put device to state registry")
+	called = summaryRegistry.AssertCalled(s.T(), "PutState", &common.OrganizationSummary{OrganizationId: "org1", Devices: 1})
+	assert.True(s.T(), called, "should count enrolled device in the organization summary")
+
+	_, err = deviceRegistry.Enroll(&common.Device{Id: "device2", OrganizationId: "org1"}, cert, "code1")
+	assert.Error(s.T(), err, "should refuse to enroll an existing device")
+
+	_, err = deviceRegistry.Enroll(&common.Device{Id: "device1", OrganizationId: "org2"}, cert, "")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse device without enrollment")
+}
+
+func (s *DeviceRegistryTestSuite) TestRevoke() {
+	revocationRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.revocationRegistry = revocationRegistry
+
+	revocationRegistry.On("PutState", mock.Anything).Return(nil)
+
+	revocation := &common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1"}
+	err := deviceRegistry.Revoke(revocation)
+	assert.Nil(s.T(), err, "should return no error")
+	called := revocationRegistry.AssertCalled(s.T(), "PutState", revocation)
+	assert.True(s.T(), called, "should put revocation to state registry")
+}
+
+func (s *DeviceRegistryTestSuite) TestReinstate() {
+	revocationRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.revocationRegistry = revocationRegistry
+
+	expected := &common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1", SerialNumber: "1a"}
+	revocationRegistry.On("GetState", []string{"org1", "device1", "1a"}).Return(expected, nil)
+	revocationRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	revocationRegistry.On("RemoveState", mock.Anything).Return(nil)
+
+	revocation, err := deviceRegistry.Reinstate("org1", "device1", "1a")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), expected, revocation, "should return the removed revocation")
+	called := revocationRegistry.AssertCalled(s.T(), "RemoveState", expected)
+	assert.True(s.T(), called, "should remove revocation from state registry")
+
+	_, err = deviceRegistry.Reinstate("org1", "device1", "")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	revocationRegistry.AssertNumberOfCalls(s.T(), "RemoveState", 1)
+}
+
+func (s *DeviceRegistryTestSuite) TestGetRevocations() {
+	revocationRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.revocationRegistry = revocationRegistry
+
+	expected := &common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1"}
+	revocationRegistry.On("GetStates", []string{"org1"}).Return([]StateInterface{expected}, nil)
+
+	revocations, err := deviceRegistry.GetRevocations("org1")
+	assert.Equal(s.T(), []*common.DeviceRevocation{expected}, revocations, "should return revocations of the organization")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceRegistryTestSuite) TestIsRevoked() {
+	revocationRegistry := new(MockStateRegistry)
+
+	deviceRegistry := new(DeviceRegistry)
+	deviceRegistry.ctx = new(MockTransactionContext)
+	deviceRegistry.revocationRegistry = revocationRegistry
+
+	revocationRegistry.On("GetStates", []string{"org1", "device1"}).Return([]StateInterface{
+		&common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1", SerialNumber: "1a"},
+	}, nil)
+	revocationRegistry.On("GetStates", []string{"org1", "device2"}).Return([]StateInterface{
+		&common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device2"},
+	}, nil)
+	revocationRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+
+	revoked, err := deviceRegistry.IsRevoked("org1", "device1", "1A")
+	assert.True(s.T(), revoked, "should match revoked certificate")
+	assert.Nil(s.T(), err, "should return no error")
+
+	revoked, _ = deviceRegistry.IsRevoked("org1", "device1", "1b")
+	assert.False(s.T(), revoked, "should not match other certificates of the device")
+
+	revoked, _ = deviceRegistry.IsRevoked("org1", "device1", "")
+	assert.False(s.T(), revoked, "should not match the device if only a certificate is revoked")
+
+	revoked, _ = deviceRegistry.IsRevoked("org1", "device2", "")
+	assert.True(s.T(), revoked, "should match revoked device")
+
+	revoked, _ = deviceRegistry.IsRevoked("org1", "device3", "1a")
+	assert.False(s.T(), revoked, "should not match devices without revocation")
 }
 
 func TestDeviceRegistryTestSuite(t *testing.T) {
@@ -0,0 +1,143 @@
+package contract
+
+import (
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// EventVersion version of the event envelope emitted by transactions, legacy URL-style events are emitted if it is zero
+var EventVersion = 0
+
+func newDeviceEvent(organizationId string, deviceId string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityDevice,
+		OrganizationId: organizationId,
+		DeviceId:       deviceId,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newServiceEvent(organizationId string, deviceId string, serviceName string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityService,
+		OrganizationId: organizationId,
+		DeviceId:       deviceId,
+		ServiceName:    serviceName,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newRequestEvent(organizationId string, deviceId string, serviceName string, requestId string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityRequest,
+		OrganizationId: organizationId,
+		DeviceId:       deviceId,
+		ServiceName:    serviceName,
+		RequestId:      requestId,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newStreamEvent(organizationId string, deviceId string, serviceName string, topic string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityStream,
+		OrganizationId: organizationId,
+		DeviceId:       deviceId,
+		ServiceName:    serviceName,
+		Topic:          topic,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newAccountEvent(organizationId string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityAccount,
+		OrganizationId: organizationId,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newGroupEvent(organizationId string, groupName string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityGroup,
+		OrganizationId: organizationId,
+		GroupName:      groupName,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newWorkflowEvent(organizationId string, workflowName string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityWorkflow,
+		OrganizationId: organizationId,
+		WorkflowName:   workflowName,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newFirmwareEvent(organizationId string, firmwareName string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityFirmware,
+		OrganizationId: organizationId,
+		FirmwareName:   firmwareName,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+func newCampaignEvent(organizationId string, campaignId string, action string, payload []byte) *common.Event {
+	return &common.Event{
+		EntityType:     common.EventEntityCampaign,
+		OrganizationId: organizationId,
+		CampaignId:     campaignId,
+		Action:         action,
+		Payload:        common.NewEventPayload(payload),
+	}
+}
+
+// setEvent emit the changes made by a transaction as one event, either as a legacy URL-style event, a legacy
+// composite event if there are cascaded changes, or a versioned envelope if EventVersion is set
+func setEvent(ctx TransactionContextInterface, events []*common.Event) error {
+	var err error
+	stub := ctx.GetStub()
+
+	if EventVersion == 0 {
+		if len(events) == 1 {
+			return stub.SetEvent(events[0].GetLegacyName(), events[0].GetLegacyPayload())
+		}
+
+		composite := &common.CompositeEvent{TransactionId: stub.GetTxID(), Events: make([]*common.ChangeEvent, 0)}
+		for _, event := range events {
+			composite.Events = append(composite.Events, &common.ChangeEvent{Name: event.GetLegacyName(), Payload: event.GetLegacyPayload()})
+		}
+		data, err := composite.Serialize()
+		if err != nil {
+			return err
+		}
+
+		return stub.SetEvent(composite.GetName(), data)
+	}
+
+	envelope := &common.EventEnvelope{Version: EventVersion, TransactionId: stub.GetTxID(), Events: events}
+	if envelope.Timestamp, err = ctx.GetTimestamp(); err != nil {
+		return err
+	}
+	if envelope.ActorOrganizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if envelope.ActorId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+	data, err := envelope.Serialize()
+	if err != nil {
+		return err
+	}
+
+	return stub.SetEvent(envelope.GetName(), data)
+}
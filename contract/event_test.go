@@ -0,0 +1,76 @@
+package contract
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventTestSuite) TestNewEvents() {
+	event := newDeviceEvent("org1", "device1", "authorize", []byte("device9"))
+	assert.Equal(s.T(), "device://org1/device1/authorize", event.GetLegacyName(), "should create device event")
+	assert.Equal(s.T(), json.RawMessage("\"device9\""), event.Payload, "should wrap text payload as JSON string")
+
+	event = newServiceEvent("org1", "device1", "service1", "register", []byte("{\"name\":\"service1\"}"))
+	assert.Equal(s.T(), "service://org1/device1/service1/register", event.GetLegacyName(), "should create service event")
+	assert.Equal(s.T(), json.RawMessage("{\"name\":\"service1\"}"), event.Payload, "should keep JSON payload")
+
+	event = newRequestEvent("org1", "device1", "service1", "request1", "remove", []byte("request1"))
+	assert.Equal(s.T(), "request://org1/device1/service1/request1/remove", event.GetLegacyName(), "should create request event")
+
+	event = newAccountEvent("org1", "deposit", []byte("{}"))
+	assert.Equal(s.T(), "account://org1/deposit", event.GetLegacyName(), "should create account event")
+
+	event = newGroupEvent("org1", "group1", "join", []byte("{}"))
+	assert.Equal(s.T(), "group://org1/group1/join", event.GetLegacyName(), "should create group event")
+
+	event = newWorkflowEvent("org1", "workflow1", "start", []byte("{}"))
+	assert.Equal(s.T(), "workflow://org1/workflow1/start", event.GetLegacyName(), "should create workflow event")
+
+	event = newStreamEvent("org1", "device1", "service1", "temperature", "publish", []byte("{}"))
+	assert.Equal(s.T(), "stream://org1/device1/service1/temperature/publish", event.GetLegacyName(), "should create stream event")
+
+	event = newFirmwareEvent("org1", "firmware1", "publish", []byte("{}"))
+	assert.Equal(s.T(), "firmware://org1/firmware1/publish", event.GetLegacyName(), "should create firmware event")
+
+	event = newCampaignEvent("org1", "campaign1", "create", []byte("{}"))
+	assert.Equal(s.T(), "campaign://org1/campaign1/create", event.GetLegacyName(), "should create campaign event")
+}
+
+func (s *EventTestSuite) TestSetEvent() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	ctx.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+
+	EventVersion = common.EventEnvelopeVersion
+	defer func() { EventVersion = 0 }()
+
+	events := []*common.Event{
+		newDeviceEvent("org1", "device1", "deregister", []byte("{}")),
+		newServiceEvent("org1", "device1", "service1", "deregister", []byte("{}")),
+	}
+	err := setEvent(ctx, events)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "event://v1", ctx.stub.EventName, "should emit envelope with versioned name")
+
+	envelope, _ := common.DeserializeEventEnvelope(ctx.stub.EventPayload)
+	assert.Equal(s.T(), common.EventEnvelopeVersion, envelope.Version, "should emit envelope with version")
+	assert.Equal(s.T(), "tx1", envelope.TransactionId, "should emit envelope with transaction ID")
+	assert.True(s.T(), now.Equal(envelope.Timestamp), "should emit envelope with transaction timestamp")
+	assert.Equal(s.T(), "org1", envelope.ActorOrganizationId, "should emit envelope with actor")
+	assert.Equal(s.T(), "device1", envelope.ActorId, "should emit envelope with actor")
+	assert.Equal(s.T(), events, envelope.Events, "should emit envelope with all changes")
+}
+
+func TestEventTestSuite(t *testing.T) {
+	suite.Run(t, new(EventTestSuite))
+}
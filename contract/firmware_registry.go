@@ -0,0 +1,298 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// FirmwareRegistryInterface core utilities for managing firmware releases and their update campaigns on the ledger
+type FirmwareRegistryInterface interface {
+	// Publish create a firmware release in the ledger, which cannot be changed once published
+	Publish(release *common.FirmwareRelease) error
+
+	// Get return a firmware release by its organization ID, firmware name and version
+	Get(organizationId string, name string, version string) (*common.FirmwareRelease, error)
+
+	// GetAll return a list of firmware releases by their organization ID
+	GetAll(organizationId string) ([]*common.FirmwareRelease, error)
+
+	// CreateCampaign create a campaign updating its target devices, and return the unfinished updates of earlier
+	// campaigns that it cancels
+	CreateCampaign(campaign *common.FirmwareCampaign) ([]*common.FirmwareUpdate, error)
+
+	// GetCampaign return a firmware campaign by its ID
+	GetCampaign(campaignId string) (*common.FirmwareCampaign, error)
+
+	// GetUpdates return the updates of the target devices of a firmware campaign
+	GetUpdates(campaignId string) ([]*common.FirmwareUpdate, error)
+
+	// GetPending return the unfinished firmware update of a device
+	GetPending(organizationId string, deviceId string) (*common.FirmwareUpdate, error)
+
+	// Report record the status of the firmware update of a device in a campaign
+	Report(organizationId string, deviceId string, campaignId string, status common.FirmwareUpdateStatus, message string) (*common.FirmwareUpdate, error)
+}
+
+// FirmwareRegistry core utilities for managing firmware releases and their update campaigns on the ledger
+type FirmwareRegistry struct {
+	ctx              TransactionContextInterface
+	stateRegistry    StateRegistryInterface
+	campaignRegistry StateRegistryInterface
+	updateRegistry   StateRegistryInterface
+}
+
+// Publish create a firmware release in the ledger, which cannot be changed once published
+func (r *FirmwareRegistry) Publish(release *common.FirmwareRelease) error {
+	// check if release already exists
+	if _, err := r.stateRegistry.GetState(release.GetKeyComponents()...); err == nil {
+		return fmt.Errorf("firmware release already exists")
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return err
+	}
+
+	return r.stateRegistry.PutState(release)
+}
+
+// Get return a firmware release by its organization ID, firmware name and version
+func (r *FirmwareRegistry) Get(organizationId string, name string, version string) (*common.FirmwareRelease, error) {
+	state, err := r.stateRegistry.GetState(organizationId, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.FirmwareRelease), nil
+}
+
+// GetAll return a list of firmware releases by their organization ID
+func (r *FirmwareRegistry) GetAll(organizationId string) ([]*common.FirmwareRelease, error) {
+	states, err := r.stateRegistry.GetStates(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	releases := make([]*common.FirmwareRelease, 0)
+	for _, state := range states {
+		releases = append(releases, state.(*common.FirmwareRelease))
+	}
+
+	return releases, err
+}
+
+// CreateCampaign create a campaign updating its target devices, and return the unfinished updates of earlier
+// campaigns that it cancels
+func (r *FirmwareRegistry) CreateCampaign(campaign *common.FirmwareCampaign) ([]*common.FirmwareUpdate, error) {
+	if err := campaign.Validate(); err != nil {
+		return nil, err
+	}
+
+	// check if campaign already exists
+	if _, err := r.campaignRegistry.GetState(campaign.Id); err == nil {
+		return nil, fmt.Errorf("firmware campaign already exists")
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return nil, err
+	}
+
+	release, err := r.Get(campaign.FirmwareOrganizationId, campaign.FirmwareName, campaign.FirmwareVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := r.getTargets(campaign)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign.Targets = make([]string, 0)
+	cancelled := make([]*common.FirmwareUpdate, 0)
+	for _, deviceId := range targets {
+		device, err := r.ctx.GetDeviceRegistry().Get(campaign.OrganizationId, deviceId)
+		if err != nil {
+			return nil, err
+		}
+		if !release.SupportsModel(device.Model) {
+			return nil, fmt.Errorf("model %q of device %s is not targeted by the firmware release", device.Model, device.Id)
+		}
+
+		// a device works on one update at a time, so the latest campaign supersedes earlier ones
+		updates, err := r.updateRegistry.GetStates(device.OrganizationId, device.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, state := range updates {
+			update := state.(*common.FirmwareUpdate)
+			if update.IsFinished() {
+				continue
+			}
+
+			update.Status = common.FirmwareUpdateCancelled
+			update.Message = fmt.Sprintf("superseded by campaign %s", campaign.Id)
+			update.LastUpdateTime = campaign.Time
+			if err = r.updateRegistry.PutState(update); err != nil {
+				return nil, err
+			}
+			cancelled = append(cancelled, update)
+		}
+
+		err = r.updateRegistry.PutState(
+			&common.FirmwareUpdate{
+				OrganizationId:         device.OrganizationId,
+				DeviceId:               device.Id,
+				CampaignId:             campaign.Id,
+				FirmwareOrganizationId: release.OrganizationId,
+				FirmwareName:           release.Name,
+				FirmwareVersion:        release.Version,
+				Status:                 common.FirmwareUpdatePending,
+				LastUpdateTime:         campaign.Time,
+			},
+		)
+		if err != nil {
+			return nil, err
+		}
+		campaign.Targets = append(campaign.Targets, device.Id)
+	}
+
+	if err = r.campaignRegistry.PutState(campaign); err != nil {
+		return nil, err
+	}
+
+	return cancelled, nil
+}
+
+// GetCampaign return a firmware campaign by its ID
+func (r *FirmwareRegistry) GetCampaign(campaignId string) (*common.FirmwareCampaign, error) {
+	state, err := r.campaignRegistry.GetState(campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.FirmwareCampaign), nil
+}
+
+// GetUpdates return the updates of the target devices of a firmware campaign
+func (r *FirmwareRegistry) GetUpdates(campaignId string) ([]*common.FirmwareUpdate, error) {
+	campaign, err := r.GetCampaign(campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make([]*common.FirmwareUpdate, 0)
+	for _, deviceId := range campaign.Targets {
+		state, err := r.updateRegistry.GetState(campaign.OrganizationId, deviceId, campaign.Id)
+		if err != nil {
+			return nil, err
+		}
+		updates = append(updates, state.(*common.FirmwareUpdate))
+	}
+
+	return updates, nil
+}
+
+// GetPending return the unfinished firmware update of a device
+func (r *FirmwareRegistry) GetPending(organizationId string, deviceId string) (*common.FirmwareUpdate, error) {
+	states, err := r.updateRegistry.GetStates(organizationId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, state := range states {
+		if update := state.(*common.FirmwareUpdate); !update.IsFinished() {
+			return update, nil
+		}
+	}
+
+	return nil, &common.NotFoundError{What: fmt.Sprintf("pending firmware update of device %s/%s", organizationId, deviceId)}
+}
+
+// Report record the status of the firmware update of a device in a campaign
+func (r *FirmwareRegistry) Report(organizationId string, deviceId string, campaignId string, status common.FirmwareUpdateStatus, message string) (*common.FirmwareUpdate, error) {
+	state, err := r.updateRegistry.GetState(organizationId, deviceId, campaignId)
+	if err != nil {
+		return nil, err
+	}
+	update := state.(*common.FirmwareUpdate)
+
+	if !update.CanTransition(status) {
+		return nil, fmt.Errorf("cannot change firmware update status from %s to %s", update.Status, status)
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	update.Status = status
+	update.Message = message
+	update.LastUpdateTime = now
+	if err = r.updateRegistry.PutState(update); err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
+// getTargets return the identities of the devices listed by a campaign and of the members of its groups in the
+// campaign's organization, without duplicates
+func (r *FirmwareRegistry) getTargets(campaign *common.FirmwareCampaign) ([]string, error) {
+	targets := make([]string, 0)
+	seen := make(map[string]bool)
+
+	add := func(deviceId string) {
+		if !seen[deviceId] {
+			seen[deviceId] = true
+			targets = append(targets, deviceId)
+		}
+	}
+
+	for _, deviceId := range campaign.DeviceIds {
+		add(deviceId)
+	}
+	for _, name := range campaign.GroupNames {
+		group, err := r.ctx.GetDeviceGroupRegistry().Get(campaign.OrganizationId, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range group.Members {
+			if member.OrganizationId == campaign.OrganizationId {
+				add(member.DeviceId)
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("firmware campaign targets no device")
+	}
+
+	return targets, nil
+}
+
+func createFirmwareRegistry(ctx TransactionContextInterface) *FirmwareRegistry {
+	stateRegistry := new(StateRegistry)
+	stateRegistry.ctx = ctx
+	stateRegistry.Name = "firmware_releases"
+	stateRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeFirmwareRelease(data)
+	}
+
+	campaignRegistry := new(StateRegistry)
+	campaignRegistry.ctx = ctx
+	campaignRegistry.Name = "firmware_campaigns"
+	campaignRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeFirmwareCampaign(data)
+	}
+
+	updateRegistry := new(StateRegistry)
+	updateRegistry.ctx = ctx
+	updateRegistry.Name = "firmware_updates"
+	updateRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeFirmwareUpdate(data)
+	}
+
+	registry := new(FirmwareRegistry)
+	registry.ctx = ctx
+	registry.stateRegistry = stateRegistry
+	registry.campaignRegistry = campaignRegistry
+	registry.updateRegistry = updateRegistry
+
+	return registry
+}
@@ -0,0 +1,141 @@
+package contract
+
+import (
+	"encoding/pem"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// FirmwareRegistrySmartContract smart contract for managing firmware releases and their update campaigns on the ledger
+type FirmwareRegistrySmartContract struct {
+	contractapi.Contract
+}
+
+// Publish create a signed firmware release manifest in the ledger, only administrators of the vendor organization can do so
+func (s *FirmwareRegistrySmartContract) Publish(ctx TransactionContextInterface, data string) error {
+	release, err := common.DeserializeFirmwareRelease([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	if err = checkOrganizationAdmin(ctx, release.OrganizationId, "cannot publish a firmware release of an organization other than the administered one"); err != nil {
+		return err
+	}
+	deviceId, err := ctx.GetDeviceId()
+	if err != nil {
+		return err
+	}
+
+	// the manifest must be signed by the key of the publishing identity
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return err
+	}
+	release.PublisherId = deviceId
+	release.Certificate = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+
+	if release.PublishTime, err = getTrustedTime(ctx, release.PublishTime); err != nil {
+		return err
+	}
+	if err = release.Validate(); err != nil {
+		return err
+	}
+	if err = release.Verify(); err != nil {
+		return err
+	}
+
+	err = ctx.GetFirmwareRegistry().Publish(release)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := release.Serialize()
+		err = ctx.SetEvent(newFirmwareEvent(release.OrganizationId, release.Name, "publish", payload))
+	}
+
+	return err
+}
+
+// Get return a firmware release by its organization ID, firmware name and version
+func (s *FirmwareRegistrySmartContract) Get(ctx TransactionContextInterface, organizationId string, name string, version string) (*common.FirmwareRelease, error) {
+	return ctx.GetFirmwareRegistry().Get(organizationId, name, version)
+}
+
+// GetAll return a list of firmware releases by their organization ID
+func (s *FirmwareRegistrySmartContract) GetAll(ctx TransactionContextInterface, organizationId string) ([]*common.FirmwareRelease, error) {
+	return ctx.GetFirmwareRegistry().GetAll(organizationId)
+}
+
+// CreateCampaign create a campaign updating devices of an organization to a firmware release, only administrators
+// of the organization can do so
+func (s *FirmwareRegistrySmartContract) CreateCampaign(ctx TransactionContextInterface, data string) error {
+	campaign, err := common.DeserializeFirmwareCampaign([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	if err = checkOrganizationAdmin(ctx, campaign.OrganizationId, "cannot create a firmware campaign of an organization other than the administered one"); err != nil {
+		return err
+	}
+	deviceId, err := ctx.GetDeviceId()
+	if err != nil {
+		return err
+	}
+
+	campaign.CreatorId = deviceId
+	if campaign.Time, err = getTrustedTime(ctx, campaign.Time); err != nil {
+		return err
+	}
+
+	cancelled, err := ctx.GetFirmwareRegistry().CreateCampaign(campaign)
+	if err != nil {
+		return err
+	}
+
+	// notify listening clients of the cancelled updates together with the transaction event
+	for _, update := range cancelled {
+		payload, _ := update.Serialize()
+		ctx.AddEvent(newCampaignEvent(update.OrganizationId, update.CampaignId, "report", payload))
+	}
+
+	payload, _ := campaign.Serialize()
+	return ctx.SetEvent(newCampaignEvent(campaign.OrganizationId, campaign.Id, "create", payload))
+}
+
+// GetCampaign return a firmware campaign by its ID
+func (s *FirmwareRegistrySmartContract) GetCampaign(ctx TransactionContextInterface, campaignId string) (*common.FirmwareCampaign, error) {
+	return ctx.GetFirmwareRegistry().GetCampaign(campaignId)
+}
+
+// GetUpdates return the updates of the target devices of a firmware campaign
+func (s *FirmwareRegistrySmartContract) GetUpdates(ctx TransactionContextInterface, campaignId string) ([]*common.FirmwareUpdate, error) {
+	return ctx.GetFirmwareRegistry().GetUpdates(campaignId)
+}
+
+// GetPending return the unfinished firmware update of a device
+func (s *FirmwareRegistrySmartContract) GetPending(ctx TransactionContextInterface, organizationId string, deviceId string) (*common.FirmwareUpdate, error) {
+	return ctx.GetFirmwareRegistry().GetPending(organizationId, deviceId)
+}
+
+// Report record the status of the invoking device's firmware update in a campaign
+func (s *FirmwareRegistrySmartContract) Report(ctx TransactionContextInterface, campaignId string, status string, message string) error {
+	var err error
+	var organizationId, deviceId string
+
+	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if deviceId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	update, err := ctx.GetFirmwareRegistry().Report(organizationId, deviceId, campaignId, common.FirmwareUpdateStatus(status), message)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := update.Serialize()
+		err = ctx.SetEvent(newCampaignEvent(update.OrganizationId, update.CampaignId, "report", payload))
+	}
+
+	return err
+}
@@ -0,0 +1,175 @@
+package contract
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type FirmwareRegistryContractTestSuite struct {
+	suite.Suite
+}
+
+// newTestPublisher create a client identity with a fresh key, and return the signing function of the key
+func newTestPublisher() (*mockClientIdentity, func(digest []byte) ([]byte, error)) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "vendor1-admin"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	data, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	cert, _ := x509.ParseCertificate(data)
+
+	sign := func(digest []byte) ([]byte, error) {
+		return ecdsa.SignASN1(rand.Reader, key, digest)
+	}
+
+	return &mockClientIdentity{Certificate: cert}, sign
+}
+
+func (s *FirmwareRegistryContractTestSuite) TestPublish() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "vendor1", Timestamp: now, IsAdmin: true}
+	firmwareRegistry := new(MockFirmwareRegistry)
+	ctx.firmwareRegistry = firmwareRegistry
+
+	identity, sign := newTestPublisher()
+	ctx.identity = identity
+	firmwareRegistry.On("Publish", mock.AnythingOfType("*common.FirmwareRelease")).Return(nil)
+
+	release := newTestFirmwareRelease()
+	release.Sign(sign)
+	data, _ := release.Serialize()
+
+	contract := new(FirmwareRegistrySmartContract)
+	err := contract.Publish(ctx, string(data))
+	assert.Nil(s.T(), err, "should return no error")
+	published := firmwareRegistry.Calls[0].Arguments[0].(*common.FirmwareRelease)
+	assert.Equal(s.T(), "admin1", published.PublisherId, "should record the publisher")
+	assert.Nil(s.T(), published.Verify(), "should record the certificate of the publisher")
+	assert.Equal(s.T(), now, published.PublishTime, "should record transaction time")
+	assert.Equal(s.T(), "firmware://vendor1/firmware1/publish", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	release.Location = "https://example.com/tampered.bin"
+	data, _ = release.Serialize()
+	err = contract.Publish(ctx, string(data))
+	assert.Error(s.T(), err, "should refuse manifest whose signature does not match")
+	assert.Regexp(s.T(), "signature", err.Error())
+
+	release = newTestFirmwareRelease()
+	_, otherSign := newTestPublisher()
+	release.Sign(otherSign)
+	data, _ = release.Serialize()
+	err = contract.Publish(ctx, string(data))
+	assert.Error(s.T(), err, "should refuse manifest signed by another key than the publisher's")
+
+	release = newTestFirmwareRelease()
+	release.Sign(sign)
+	release.Hash = ""
+	data, _ = release.Serialize()
+	err = contract.Publish(ctx, string(data))
+	assert.Error(s.T(), err, "should refuse invalid manifest")
+
+	ctx.OrganizationId = "vendor2"
+	err = contract.Publish(ctx, string(data))
+	assert.Error(s.T(), err, "should refuse to publish releases of other organizations")
+
+	ctx.OrganizationId, ctx.IsAdmin = "vendor1", false
+	err = contract.Publish(ctx, string(data))
+	assert.Error(s.T(), err, "should refuse to publish by clients other than administrators")
+
+	err = contract.Publish(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+	firmwareRegistry.AssertNumberOfCalls(s.T(), "Publish", 1)
+}
+
+func (s *FirmwareRegistryContractTestSuite) TestCreateCampaign() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", Timestamp: now, IsAdmin: true}
+	firmwareRegistry := new(MockFirmwareRegistry)
+	ctx.firmwareRegistry = firmwareRegistry
+
+	cancelled := []*common.FirmwareUpdate{{OrganizationId: "org1", DeviceId: "device2", CampaignId: "campaign0", Status: common.FirmwareUpdateCancelled}}
+	firmwareRegistry.On("CreateCampaign", mock.MatchedBy(func(c *common.FirmwareCampaign) bool { return c.Id == "campaign1" })).Return(cancelled, nil)
+	firmwareRegistry.On("CreateCampaign", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	contract := new(FirmwareRegistrySmartContract)
+	err := contract.CreateCampaign(ctx, "{\"id\":\"campaign1\",\"organizationId\":\"org1\",\"creatorId\":\"admin9\",\"time\":\"2021-12-12T17:34:00-05:00\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	campaign := firmwareRegistry.Calls[0].Arguments[0].(*common.FirmwareCampaign)
+	assert.Equal(s.T(), "admin1", campaign.CreatorId, "should ignore client-supplied creator")
+	assert.Equal(s.T(), now, campaign.Time, "should record transaction time")
+	event, _ := common.DeserializeCompositeEvent(ctx.stub.EventPayload)
+	assert.Equal(s.T(), 2, len(event.Events), "should emit the campaign with the cancelled updates")
+	assert.Equal(s.T(), "campaign://org1/campaign1/create", event.Events[0].Name, "should emit campaign event first")
+	assert.Equal(s.T(), "campaign://org1/campaign0/report", event.Events[1].Name, "should emit cancelled update events")
+	ctx.stub.ResetEvent()
+
+	err = contract.CreateCampaign(ctx, "{\"id\":\"campaign2\",\"organizationId\":\"org1\"}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	err = contract.CreateCampaign(ctx, "{\"id\":\"campaign1\",\"organizationId\":\"org2\"}")
+	assert.Error(s.T(), err, "should refuse to create campaigns of other organizations")
+
+	ctx.IsAdmin = false
+	err = contract.CreateCampaign(ctx, "{\"id\":\"campaign1\",\"organizationId\":\"org1\"}")
+	assert.Error(s.T(), err, "should refuse to create campaigns by clients other than administrators")
+
+	err = contract.CreateCampaign(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *FirmwareRegistryContractTestSuite) TestReport() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	firmwareRegistry := new(MockFirmwareRegistry)
+	ctx.firmwareRegistry = firmwareRegistry
+
+	update := &common.FirmwareUpdate{OrganizationId: "org1", DeviceId: "device1", CampaignId: "campaign1", Status: common.FirmwareUpdateInstalling}
+	firmwareRegistry.On("Report", "org1", "device1", "campaign1", common.FirmwareUpdateInstalling, "").Return(update, nil)
+	firmwareRegistry.On("Report", "org1", "device1", "campaign2", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+
+	contract := new(FirmwareRegistrySmartContract)
+	err := contract.Report(ctx, "campaign1", "installing", "")
+	assert.Nil(s.T(), err, "should return no error")
+	actual, _ := common.DeserializeFirmwareUpdate(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "campaign://org1/campaign1/report", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), update, actual, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	err = contract.Report(ctx, "campaign2", "failed", "out of space")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *FirmwareRegistryContractTestSuite) TestGetPending() {
+	ctx := new(MockTransactionContext)
+	firmwareRegistry := new(MockFirmwareRegistry)
+	ctx.firmwareRegistry = firmwareRegistry
+
+	expected := &common.FirmwareUpdate{OrganizationId: "org1", DeviceId: "device1", CampaignId: "campaign1"}
+	firmwareRegistry.On("GetPending", "org1", "device1").Return(expected, nil)
+
+	contract := new(FirmwareRegistrySmartContract)
+	actual, err := contract.GetPending(ctx, "org1", "device1")
+	assert.Equal(s.T(), expected, actual, "should return the pending update")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func TestFirmwareRegistryContractTestSuite(t *testing.T) {
+	suite.Run(t, new(FirmwareRegistryContractTestSuite))
+}
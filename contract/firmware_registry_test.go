@@ -0,0 +1,302 @@
+package contract
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockFirmwareRegistry struct {
+	mock.Mock
+}
+
+func (r *MockFirmwareRegistry) Publish(release *common.FirmwareRelease) error {
+	args := r.Called(release)
+	return args.Error(0)
+}
+
+func (r *MockFirmwareRegistry) Get(organizationId string, name string, version string) (*common.FirmwareRelease, error) {
+	args := r.Called(organizationId, name, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.FirmwareRelease), args.Error(1)
+}
+
+func (r *MockFirmwareRegistry) GetAll(organizationId string) ([]*common.FirmwareRelease, error) {
+	args := r.Called(organizationId)
+	return args.Get(0).([]*common.FirmwareRelease), args.Error(1)
+}
+
+func (r *MockFirmwareRegistry) CreateCampaign(campaign *common.FirmwareCampaign) ([]*common.FirmwareUpdate, error) {
+	args := r.Called(campaign)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*common.FirmwareUpdate), args.Error(1)
+}
+
+func (r *MockFirmwareRegistry) GetCampaign(campaignId string) (*common.FirmwareCampaign, error) {
+	args := r.Called(campaignId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.FirmwareCampaign), args.Error(1)
+}
+
+func (r *MockFirmwareRegistry) GetUpdates(campaignId string) ([]*common.FirmwareUpdate, error) {
+	args := r.Called(campaignId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*common.FirmwareUpdate), args.Error(1)
+}
+
+func (r *MockFirmwareRegistry) GetPending(organizationId string, deviceId string) (*common.FirmwareUpdate, error) {
+	args := r.Called(organizationId, deviceId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.FirmwareUpdate), args.Error(1)
+}
+
+func (r *MockFirmwareRegistry) Report(organizationId string, deviceId string, campaignId string, status common.FirmwareUpdateStatus, message string) (*common.FirmwareUpdate, error) {
+	args := r.Called(organizationId, deviceId, campaignId, status, message)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.FirmwareUpdate), args.Error(1)
+}
+
+func newTestFirmwareRelease() *common.FirmwareRelease {
+	return &common.FirmwareRelease{
+		OrganizationId: "vendor1",
+		Name:           "firmware1",
+		Version:        "1.2.0",
+		Hash:           "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Models:         []string{"model1", "model2"},
+		Location:       "https://example.com/firmware1-1.2.0.bin",
+	}
+}
+
+type FirmwareRegistryTestSuite struct {
+	suite.Suite
+}
+
+func (s *FirmwareRegistryTestSuite) TestPublish() {
+	stateRegistry := new(MockStateRegistry)
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = new(MockTransactionContext)
+	firmwareRegistry.stateRegistry = stateRegistry
+
+	release := newTestFirmwareRelease()
+	stateRegistry.On("GetState", []string{"vendor1", "firmware1", "1.1.0"}).Return(new(common.FirmwareRelease), nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	stateRegistry.On("PutState", release).Return(nil)
+
+	err := firmwareRegistry.Publish(release)
+	called := stateRegistry.AssertCalled(s.T(), "PutState", release)
+	assert.True(s.T(), called, "should put release to state registry")
+	assert.Nil(s.T(), err, "should return no error")
+
+	release = newTestFirmwareRelease()
+	release.Version = "1.1.0"
+	err = firmwareRegistry.Publish(release)
+	assert.Error(s.T(), err, "should refuse to overwrite a published release")
+	stateRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *FirmwareRegistryTestSuite) TestGet() {
+	stateRegistry := new(MockStateRegistry)
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = new(MockTransactionContext)
+	firmwareRegistry.stateRegistry = stateRegistry
+
+	release := newTestFirmwareRelease()
+	stateRegistry.On("GetState", []string{"vendor1", "firmware1", "1.2.0"}).Return(release, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	result, err := firmwareRegistry.Get("vendor1", "firmware1", "1.2.0")
+	assert.Equal(s.T(), release, result, "should return the correct release")
+	assert.Nil(s.T(), err, "should return no error")
+
+	result, err = firmwareRegistry.Get("vendor1", "firmware1", "1.3.0")
+	assert.Nil(s.T(), result, "should return no release")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetAll() {
+	stateRegistry := new(MockStateRegistry)
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = new(MockTransactionContext)
+	firmwareRegistry.stateRegistry = stateRegistry
+
+	releases := []StateInterface{new(common.FirmwareRelease), new(common.FirmwareRelease)}
+	stateRegistry.On("GetStates", []string{"vendor1"}).Return(releases, nil)
+
+	results, err := firmwareRegistry.GetAll("vendor1")
+	assert.Equal(s.T(), len(releases), len(results), "should return the correct number of releases")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestCreateCampaign() {
+	stateRegistry := new(MockStateRegistry)
+	campaignRegistry := new(MockStateRegistry)
+	updateRegistry := new(MockStateRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	groupRegistry := new(MockDeviceGroupRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.deviceRegistry = deviceRegistry
+	transactionContext.groupRegistry = groupRegistry
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = transactionContext
+	firmwareRegistry.stateRegistry = stateRegistry
+	firmwareRegistry.campaignRegistry = campaignRegistry
+	firmwareRegistry.updateRegistry = updateRegistry
+
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	group := &common.DeviceGroup{
+		OrganizationId: "org1",
+		Name:           "group1",
+		Members: []*common.DeviceGroupMember{
+			{OrganizationId: "org1", DeviceId: "device1"},
+			{OrganizationId: "org1", DeviceId: "device2"},
+			{OrganizationId: "org2", DeviceId: "device3"},
+		},
+	}
+	previous := &common.FirmwareUpdate{OrganizationId: "org1", DeviceId: "device2", CampaignId: "campaign0", Status: common.FirmwareUpdateDownloading}
+
+	stateRegistry.On("GetState", []string{"vendor1", "firmware1", "1.2.0"}).Return(newTestFirmwareRelease(), nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	campaignRegistry.On("GetState", []string{"5d7e0a36-3d5a-45b1-a9a4-51e0c5c1e8a2"}).Return(new(common.FirmwareCampaign), nil)
+	campaignRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	campaignRegistry.On("PutState", mock.Anything).Return(nil)
+	groupRegistry.On("Get", "org1", "group1").Return(group, nil)
+	deviceRegistry.On("Get", "org1", "device1").Return(&common.Device{Id: "device1", OrganizationId: "org1", Model: "model1"}, nil)
+	deviceRegistry.On("Get", "org1", "device2").Return(&common.Device{Id: "device2", OrganizationId: "org1", Model: "model2"}, nil)
+	deviceRegistry.On("Get", "org1", "device4").Return(&common.Device{Id: "device4", OrganizationId: "org1", Model: "model3"}, nil)
+	updateRegistry.On("GetStates", []string{"org1", "device1"}).Return([]StateInterface{&common.FirmwareUpdate{Status: common.FirmwareUpdateSucceeded}}, nil)
+	updateRegistry.On("GetStates", []string{"org1", "device2"}).Return([]StateInterface{previous}, nil)
+	updateRegistry.On("PutState", mock.Anything).Return(nil)
+
+	campaign := &common.FirmwareCampaign{
+		Id:                     "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1",
+		OrganizationId:         "org1",
+		FirmwareOrganizationId: "vendor1",
+		FirmwareName:           "firmware1",
+		FirmwareVersion:        "1.2.0",
+		DeviceIds:              []string{"device1"},
+		GroupNames:             []string{"group1"},
+		Time:                   now,
+	}
+
+	cancelled, err := firmwareRegistry.CreateCampaign(campaign)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []string{"device1", "device2"}, campaign.Targets, "should target listed devices and group members of the organization once")
+	assert.Equal(s.T(), []*common.FirmwareUpdate{previous}, cancelled, "should return the cancelled updates")
+	assert.Equal(s.T(), common.FirmwareUpdateCancelled, previous.Status, "should cancel unfinished updates of earlier campaigns")
+	update := updateRegistry.Calls[1].Arguments[0].(*common.FirmwareUpdate)
+	assert.Equal(s.T(), []string{"org1", "device1", campaign.Id}, update.GetKeyComponents(), "should create updates of the target devices")
+	assert.Equal(s.T(), common.FirmwareUpdatePending, update.Status, "should create pending updates")
+	assert.Equal(s.T(), "1.2.0", update.FirmwareVersion, "should record the release to install")
+	campaignRegistry.AssertCalled(s.T(), "PutState", campaign)
+
+	campaign.Id = "5d7e0a36-3d5a-45b1-a9a4-51e0c5c1e8a2"
+	_, err = firmwareRegistry.CreateCampaign(campaign)
+	assert.Error(s.T(), err, "should refuse existing campaign")
+
+	campaign.Id = "8f14e45f-ceea-467f-a0e6-7f6b3f3b6e1d"
+	campaign.DeviceIds, campaign.GroupNames = []string{"device4"}, nil
+	_, err = firmwareRegistry.CreateCampaign(campaign)
+	assert.Error(s.T(), err, "should refuse devices of models not targeted by the release")
+	assert.Regexp(s.T(), "model", err.Error())
+
+	campaign.FirmwareVersion = "1.3.0"
+	_, err = firmwareRegistry.CreateCampaign(campaign)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	campaign.DeviceIds = nil
+	_, err = firmwareRegistry.CreateCampaign(campaign)
+	assert.Error(s.T(), err, "should refuse campaign without targets")
+	campaignRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetUpdates() {
+	campaignRegistry := new(MockStateRegistry)
+	updateRegistry := new(MockStateRegistry)
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = new(MockTransactionContext)
+	firmwareRegistry.campaignRegistry = campaignRegistry
+	firmwareRegistry.updateRegistry = updateRegistry
+
+	update := &common.FirmwareUpdate{OrganizationId: "org1", DeviceId: "device1", CampaignId: "campaign1"}
+	campaignRegistry.On("GetState", []string{"campaign1"}).Return(&common.FirmwareCampaign{Id: "campaign1", OrganizationId: "org1", Targets: []string{"device1"}}, nil)
+	campaignRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	updateRegistry.On("GetState", []string{"org1", "device1", "campaign1"}).Return(update, nil)
+
+	updates, err := firmwareRegistry.GetUpdates("campaign1")
+	assert.Equal(s.T(), []*common.FirmwareUpdate{update}, updates, "should return updates of the target devices")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = firmwareRegistry.GetUpdates("campaign2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetPending() {
+	updateRegistry := new(MockStateRegistry)
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = new(MockTransactionContext)
+	firmwareRegistry.updateRegistry = updateRegistry
+
+	pending := &common.FirmwareUpdate{CampaignId: "campaign2", Status: common.FirmwareUpdateInstalling}
+	updateRegistry.On("GetStates", []string{"org1", "device1"}).Return([]StateInterface{&common.FirmwareUpdate{CampaignId: "campaign1", Status: common.FirmwareUpdateFailed}, pending}, nil)
+	updateRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+
+	update, err := firmwareRegistry.GetPending("org1", "device1")
+	assert.Equal(s.T(), pending, update, "should return the unfinished update")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = firmwareRegistry.GetPending("org1", "device2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestReport() {
+	updateRegistry := new(MockStateRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	firmwareRegistry := new(FirmwareRegistry)
+	firmwareRegistry.ctx = &MockTransactionContext{Timestamp: now}
+	firmwareRegistry.updateRegistry = updateRegistry
+
+	updateRegistry.On("GetState", []string{"org1", "device1", "campaign1"}).Return(&common.FirmwareUpdate{Status: common.FirmwareUpdatePending}, nil)
+	updateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	updateRegistry.On("PutState", mock.Anything).Return(nil)
+
+	update, err := firmwareRegistry.Report("org1", "device1", "campaign1", common.FirmwareUpdateDownloading, "50%")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.FirmwareUpdateDownloading, update.Status, "should record the status")
+	assert.Equal(s.T(), "50%", update.Message, "should record the message")
+	assert.Equal(s.T(), now, update.LastUpdateTime, "should record transaction time")
+	updateRegistry.AssertCalled(s.T(), "PutState", update)
+
+	_, err = firmwareRegistry.Report("org1", "device1", "campaign1", common.FirmwareUpdatePending, "")
+	assert.Error(s.T(), err, "should refuse to move the status backward")
+
+	_, err = firmwareRegistry.Report("org1", "device1", "campaign2", common.FirmwareUpdateDownloading, "")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func TestFirmwareRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(FirmwareRegistryTestSuite))
+}
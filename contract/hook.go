@@ -0,0 +1,332 @@
+package contract
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// transactionStartTimeKey key of the transaction context value holding the time at which the hooks started
+const transactionStartTimeKey = "transactionStartTime"
+
+// transactionHooksKey key of the transaction context value holding the pipeline whose failure hooks are run if the
+// invoked function fails
+const transactionHooksKey = "transactionHooks"
+
+// transactionErrorKey key of the transaction context value holding the error already wrapped by the pipeline
+const transactionErrorKey = "transactionError"
+
+// TransactionHook a step of the pipeline run around every transaction of a smart contract
+type TransactionHook interface {
+	// Before run before the invoked function, the transaction is rejected if it returns an error
+	Before(ctx TransactionContextInterface) error
+
+	// After run after the invoked function has returned the result without error
+	After(ctx TransactionContextInterface, result interface{}) error
+}
+
+// TransactionFailureHook a transaction hook that is also notified of the transactions that fail
+type TransactionFailureHook interface {
+	TransactionHook
+
+	// Failed run instead of After if a hook rejects the transaction or the invoked function returns an error
+	Failed(ctx TransactionContextInterface, err error)
+}
+
+// TransactionHooks a pipeline of transaction hooks, to be used as the before, after and unknown transaction
+// handlers of a smart contract; after hooks are run in reverse order, and the failure hooks are run instead if
+// the transaction fails, provided that the chaincode is wrapped in a HookedChaincode
+type TransactionHooks []TransactionHook
+
+// BeforeTransaction run the before hooks in order until one of them rejects the transaction
+func (h TransactionHooks) BeforeTransaction(ctx TransactionContextInterface) error {
+	ctx.SetValue(transactionStartTimeKey, time.Now())
+
+	// let HookedChaincode find the context of the transaction it invoked
+	if stub, ok := ctx.GetStub().(*hookedStub); ok {
+		stub.ctx = ctx
+	}
+
+	for _, hook := range h {
+		if err := hook.Before(ctx); err != nil {
+			err = newTransactionError(ctx, err)
+			h.fail(ctx, err)
+			return err
+		}
+	}
+
+	ctx.SetValue(transactionHooksKey, h)
+	return nil
+}
+
+// AfterTransaction run the after hooks in reverse order until one of them fails, in which case the failure hooks of
+// the failed hook and those that have not run yet are run
+func (h TransactionHooks) AfterTransaction(ctx TransactionContextInterface, result interface{}) error {
+	ctx.SetValue(transactionHooksKey, nil)
+
+	for i := len(h) - 1; i >= 0; i-- {
+		if err := h[i].After(ctx, result); err != nil {
+			err = newTransactionError(ctx, err)
+			h[:i+1].fail(ctx, err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// fail run the failure hooks in reverse order
+func (h TransactionHooks) fail(ctx TransactionContextInterface, err error) {
+	for i := len(h) - 1; i >= 0; i-- {
+		if hook, ok := h[i].(TransactionFailureHook); ok {
+			hook.Failed(ctx, err)
+		}
+	}
+}
+
+// UnknownTransaction reject calls to functions that the smart contract does not define
+func (h TransactionHooks) UnknownTransaction(ctx TransactionContextInterface) error {
+	return newTransactionError(ctx, fmt.Errorf("unknown function"))
+}
+
+// HookedChaincode a chaincode wrapping the errors of the invoked functions like those of the hooks, and running the
+// failure hooks of the transactions whose invoked function fails, which the before and after transaction handlers
+// of a smart contract are never told about
+type HookedChaincode struct {
+	shim.Chaincode
+}
+
+// hookedStub a chaincode stub linked to the context that the smart contract creates for the transaction
+type hookedStub struct {
+	shim.ChaincodeStubInterface
+	ctx TransactionContextInterface
+}
+
+// Invoke invoke the chaincode, wrap the error of the transaction and run the failure hooks if the invoked function
+// fails
+func (c HookedChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	hooked := &hookedStub{ChaincodeStubInterface: stub}
+	response := c.Chaincode.Invoke(hooked)
+	if response.Status < shim.ERRORTHRESHOLD {
+		return response
+	}
+
+	var err *TransactionError
+	if hooked.ctx != nil {
+		err, _ = hooked.ctx.GetValue(transactionErrorKey).(*TransactionError)
+	}
+	if err == nil {
+		err = wrapTransactionError(stub, errors.New(response.Message))
+		response.Message = err.Error()
+	}
+
+	// the pipeline is only set if the before hooks passed and the after hooks have not run
+	if hooked.ctx != nil {
+		if hooks, ok := hooked.ctx.GetValue(transactionHooksKey).(TransactionHooks); ok {
+			hooks.fail(hooked.ctx, err)
+		}
+	}
+
+	return response
+}
+
+// TransactionError an error raised by a transaction hook or the invoked function, annotated with the invoked function and transaction
+type TransactionError struct {
+	// Function name of the invoked function, including its contract name
+	Function string
+
+	// TransactionId ID of the transaction
+	TransactionId string
+
+	// Err the error raised by the hook or the invoked function
+	Err error
+}
+
+// Error get the error message
+func (e TransactionError) Error() string {
+	return fmt.Sprintf("%s failed in transaction %s: %v", e.Function, e.TransactionId, e.Err)
+}
+
+// Unwrap get the error raised by the hook or the invoked function
+func (e TransactionError) Unwrap() error {
+	return e.Err
+}
+
+// newTransactionError wrap the error raised by a hook and record it so that HookedChaincode does not wrap it again
+func newTransactionError(ctx TransactionContextInterface, err error) *TransactionError {
+	transactionErr := wrapTransactionError(ctx.GetStub(), err)
+	ctx.SetValue(transactionErrorKey, transactionErr)
+	return transactionErr
+}
+
+func wrapTransactionError(stub shim.ChaincodeStubInterface, err error) *TransactionError {
+	function, _ := stub.GetFunctionAndParameters()
+	return &TransactionError{Function: function, TransactionId: stub.GetTxID(), Err: err}
+}
+
+// getFunctionName return the name of the invoked function without its contract name
+func getFunctionName(ctx TransactionContextInterface) string {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	if i := strings.LastIndex(function, ":"); i != -1 {
+		function = function[i+1:]
+	}
+	return function
+}
+
+// getTransactionLatency return the time elapsed since the transaction hooks started
+func getTransactionLatency(ctx TransactionContextInterface) time.Duration {
+	start, ok := ctx.GetValue(transactionStartTimeKey).(time.Time)
+	if !ok {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// IdentityHook reject transactions from clients whose organization and device identity cannot be determined
+type IdentityHook struct{}
+
+// Before check that the organization and device identity of the client can be determined
+func (IdentityHook) Before(ctx TransactionContextInterface) error {
+	if _, err := ctx.GetOrganizationId(); err != nil {
+		return &common.AccessDeniedError{Reason: fmt.Sprintf("cannot determine organization: %v", err)}
+	}
+	if _, err := ctx.GetDeviceId(); err != nil {
+		return &common.AccessDeniedError{Reason: fmt.Sprintf("cannot determine device: %v", err)}
+	}
+	return nil
+}
+
+// After do nothing
+func (IdentityHook) After(ctx TransactionContextInterface, result interface{}) error {
+	return nil
+}
+
+// LoggingHook log the start, completion and failure of transactions with their IDs and callers
+type LoggingHook struct {
+	// Logger destination of the log entries, the standard logger is used if it is nil
+	Logger *log.Logger
+}
+
+// Before log the start of the transaction
+func (h LoggingHook) Before(ctx TransactionContextInterface) error {
+	h.log(ctx, "started", 0)
+	return nil
+}
+
+// After log the completion of the transaction
+func (h LoggingHook) After(ctx TransactionContextInterface, result interface{}) error {
+	h.log(ctx, "completed", getTransactionLatency(ctx))
+	return nil
+}
+
+// Failed log the failure of the transaction with its error
+func (h LoggingHook) Failed(ctx TransactionContextInterface, err error) {
+	h.log(ctx, "failed", getTransactionLatency(ctx), err)
+}
+
+func (h LoggingHook) log(ctx TransactionContextInterface, status string, latency time.Duration, err ...error) {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	organizationId, _ := ctx.GetOrganizationId()
+	deviceId, _ := ctx.GetDeviceId()
+
+	format := "function=%s transaction=%s organization=%s device=%s status=%s latency=%s"
+	args := []interface{}{function, ctx.GetStub().GetTxID(), organizationId, deviceId, status, latency}
+	if len(err) > 0 {
+		format += " error=%q"
+		args = append(args, err[0].Error())
+	}
+	if h.Logger != nil {
+		h.Logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
+
+// FunctionLatency latency statistics of the calls to a smart contract function
+type FunctionLatency struct {
+	// Calls number of calls, including the failed ones
+	Calls int64
+
+	// Failures number of failed calls
+	Failures int64
+
+	// Total cumulative latency of the calls
+	Total time.Duration
+
+	// Max highest latency of the calls
+	Max time.Duration
+}
+
+// GetAverage return the average latency of the calls
+func (l FunctionLatency) GetAverage() time.Duration {
+	if l.Calls == 0 {
+		return 0
+	}
+	return l.Total / time.Duration(l.Calls)
+}
+
+// LatencyMetrics hook collecting the latency of the calls to each smart contract function in memory
+type LatencyMetrics struct {
+	mutex     sync.Mutex
+	functions map[string]*FunctionLatency
+}
+
+// Before do nothing, the start time is recorded by the pipeline
+func (m *LatencyMetrics) Before(ctx TransactionContextInterface) error {
+	return nil
+}
+
+// After record the latency of the transaction
+func (m *LatencyMetrics) After(ctx TransactionContextInterface, result interface{}) error {
+	m.record(ctx, false)
+	return nil
+}
+
+// Failed record the latency of the failed transaction
+func (m *LatencyMetrics) Failed(ctx TransactionContextInterface, err error) {
+	m.record(ctx, true)
+}
+
+func (m *LatencyMetrics) record(ctx TransactionContextInterface, failed bool) {
+	function, _ := ctx.GetStub().GetFunctionAndParameters()
+	latency := getTransactionLatency(ctx)
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.functions == nil {
+		m.functions = make(map[string]*FunctionLatency)
+	}
+	stats, ok := m.functions[function]
+	if !ok {
+		stats = new(FunctionLatency)
+		m.functions[function] = stats
+	}
+	stats.Calls++
+	if failed {
+		stats.Failures++
+	}
+	stats.Total += latency
+	if latency > stats.Max {
+		stats.Max = latency
+	}
+}
+
+// GetLatencies return a copy of the latency statistics keyed by function name, including the contract name
+func (m *LatencyMetrics) GetLatencies() map[string]FunctionLatency {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	latencies := make(map[string]FunctionLatency)
+	for function, stats := range m.functions {
+		latencies[function] = *stats
+	}
+	return latencies
+}
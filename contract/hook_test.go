@@ -0,0 +1,220 @@
+package contract
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"log"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockTransactionHook struct {
+	mock.Mock
+}
+
+func (h *MockTransactionHook) Before(ctx TransactionContextInterface) error {
+	args := h.Called(ctx)
+	return args.Error(0)
+}
+
+func (h *MockTransactionHook) After(ctx TransactionContextInterface, result interface{}) error {
+	args := h.Called(ctx, result)
+	return args.Error(0)
+}
+
+type MockTransactionFailureHook struct {
+	MockTransactionHook
+}
+
+func (h *MockTransactionFailureHook) Failed(ctx TransactionContextInterface, err error) {
+	h.Called(ctx, err)
+}
+
+type mockChaincode struct {
+	mock.Mock
+}
+
+func (c *mockChaincode) Init(stub shim.ChaincodeStubInterface) peer.Response {
+	args := c.Called(stub)
+	return args.Get(0).(peer.Response)
+}
+
+func (c *mockChaincode) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
+	args := c.Called(stub)
+	return args.Get(0).(peer.Response)
+}
+
+type unknownClientIdentity struct {
+	mockClientIdentity
+}
+
+func (i *unknownClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, errors.New("")
+}
+
+type TransactionHooksTestSuite struct {
+	suite.Suite
+	ctx *MockTransactionContext
+}
+
+func (s *TransactionHooksTestSuite) SetupTest() {
+	s.ctx = &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	s.ctx.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}, Function: "device_registry:Register"}
+}
+
+func (s *TransactionHooksTestSuite) TestBeforeTransaction() {
+	order := make([]int, 0)
+	hook1, hook2, hook3 := new(MockTransactionHook), new(MockTransactionHook), new(MockTransactionHook)
+	hook1.On("Before", s.ctx).Run(func(mock.Arguments) { order = append(order, 1) }).Return(nil)
+	hook2.On("Before", s.ctx).Run(func(mock.Arguments) { order = append(order, 2) }).Return(nil)
+	hook3.On("Before", s.ctx).Return(nil)
+
+	err := TransactionHooks{hook1, hook2}.BeforeTransaction(s.ctx)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []int{1, 2}, order, "should run hooks in order")
+	assert.IsType(s.T(), time.Time{}, s.ctx.GetValue(transactionStartTimeKey), "should record start time")
+
+	hook2.ExpectedCalls = nil
+	hook2.On("Before", s.ctx).Return(&common.AccessDeniedError{Reason: "test"})
+	err = TransactionHooks{hook1, hook2, hook3}.BeforeTransaction(s.ctx)
+	assert.Equal(s.T(), &TransactionError{Function: "device_registry:Register", TransactionId: "tx1", Err: &common.AccessDeniedError{Reason: "test"}}, err, "should wrap hook error")
+	assert.Equal(s.T(), "device_registry:Register failed in transaction tx1: access denied: test", err.Error(), "should return error message with function and transaction")
+	hook3.AssertNotCalled(s.T(), "Before", s.ctx)
+
+	failureHook := new(MockTransactionFailureHook)
+	failureHook.On("Failed", s.ctx, err).Return()
+	err = TransactionHooks{hook2, failureHook}.BeforeTransaction(s.ctx)
+	failureHook.AssertCalled(s.T(), "Failed", s.ctx, err)
+	failureHook.AssertNotCalled(s.T(), "Before", s.ctx)
+}
+
+func (s *TransactionHooksTestSuite) TestAfterTransaction() {
+	order := make([]int, 0)
+	hook1, hook2 := new(MockTransactionHook), new(MockTransactionHook)
+	hook1.On("After", s.ctx, "result").Run(func(mock.Arguments) { order = append(order, 1) }).Return(nil)
+	hook2.On("After", s.ctx, "result").Run(func(mock.Arguments) { order = append(order, 2) }).Return(nil)
+
+	err := TransactionHooks{hook1, hook2}.AfterTransaction(s.ctx, "result")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []int{2, 1}, order, "should run hooks in reverse order")
+
+	hook2.ExpectedCalls = nil
+	hook2.On("After", s.ctx, "result").Return(errors.New(""))
+	err = TransactionHooks{hook1, hook2}.AfterTransaction(s.ctx, "result")
+	assert.IsType(s.T(), new(TransactionError), err, "should wrap hook error")
+	hook1.AssertNumberOfCalls(s.T(), "After", 1)
+
+	failureHook1, failureHook2 := new(MockTransactionFailureHook), new(MockTransactionFailureHook)
+	failureHook1.On("Failed", s.ctx, mock.Anything).Return()
+	failureHook2.On("After", s.ctx, "result").Return(nil)
+	err = TransactionHooks{failureHook1, hook2, failureHook2}.AfterTransaction(s.ctx, "result")
+	failureHook1.AssertCalled(s.T(), "Failed", s.ctx, err)
+	failureHook2.AssertNotCalled(s.T(), "Failed", s.ctx, mock.Anything)
+}
+
+// simulateContract make the chaincode run the hooks around a function returning the response like a smart contract
+func simulateContract(chaincode *mockChaincode, hooks TransactionHooks, response peer.Response) {
+	chaincode.On("Invoke", mock.Anything).Run(func(args mock.Arguments) {
+		ctx := new(TransactionContext)
+		ctx.SetStub(args.Get(0).(shim.ChaincodeStubInterface))
+		if hooks.BeforeTransaction(ctx) == nil && response.Status < shim.ERRORTHRESHOLD {
+			_ = hooks.AfterTransaction(ctx, nil)
+		}
+	}).Return(response).Once()
+}
+
+func (s *TransactionHooksTestSuite) TestHookedChaincode() {
+	chaincode := new(mockChaincode)
+	failureHook := new(MockTransactionFailureHook)
+	failureHook.On("Before", mock.Anything).Return(nil).Once()
+	failureHook.On("After", mock.Anything, nil).Return(nil)
+	failureHook.On("Failed", mock.Anything, mock.Anything).Return()
+	hooks := TransactionHooks{failureHook}
+
+	simulateContract(chaincode, hooks, shim.Error("not found"))
+	response := HookedChaincode{Chaincode: chaincode}.Invoke(s.ctx.stub)
+	assert.Equal(s.T(), int32(shim.ERROR), response.Status, "should return chaincode response")
+	assert.Equal(s.T(), "device_registry:Register failed in transaction tx1: not found", response.Message, "should wrap function error")
+	failureHook.AssertCalled(s.T(), "Failed", mock.Anything, &TransactionError{Function: "device_registry:Register", TransactionId: "tx1", Err: errors.New("not found")})
+
+	failureHook.On("Before", mock.Anything).Return(nil).Once()
+	simulateContract(chaincode, hooks, shim.Success(nil))
+	response = HookedChaincode{Chaincode: chaincode}.Invoke(s.ctx.stub)
+	assert.Equal(s.T(), int32(shim.OK), response.Status, "should return chaincode response")
+	failureHook.AssertNumberOfCalls(s.T(), "Failed", 1)
+
+	failureHook.On("Before", mock.Anything).Return(errors.New("denied")).Once()
+	simulateContract(chaincode, hooks, shim.Error("device_registry:Register failed in transaction tx1: denied"))
+	response = HookedChaincode{Chaincode: chaincode}.Invoke(s.ctx.stub)
+	assert.Equal(s.T(), "device_registry:Register failed in transaction tx1: denied", response.Message, "should not wrap hook error again")
+	failureHook.AssertNumberOfCalls(s.T(), "Failed", 2)
+
+	chaincode.On("Invoke", mock.Anything).Return(shim.Error("bad request")).Once()
+	response = HookedChaincode{Chaincode: chaincode}.Invoke(s.ctx.stub)
+	assert.Equal(s.T(), "device_registry:Register failed in transaction tx1: bad request", response.Message, "should wrap errors raised before the hooks")
+	failureHook.AssertNumberOfCalls(s.T(), "Failed", 2)
+}
+
+func (s *TransactionHooksTestSuite) TestUnknownTransaction() {
+	err := TransactionHooks{}.UnknownTransaction(s.ctx)
+	assert.IsType(s.T(), new(TransactionError), err, "should reject unknown functions")
+}
+
+func (s *TransactionHooksTestSuite) TestIdentityHook() {
+	assert.Nil(s.T(), IdentityHook{}.Before(s.ctx), "should allow identified clients")
+	assert.Nil(s.T(), IdentityHook{}.After(s.ctx, nil), "should return no error")
+
+	ctx := new(TransactionContext)
+	ctx.SetClientIdentity(new(unknownClientIdentity))
+	assert.IsType(s.T(), new(common.AccessDeniedError), IdentityHook{}.Before(ctx), "should reject unidentified clients")
+}
+
+func (s *TransactionHooksTestSuite) TestLoggingHook() {
+	var buffer bytes.Buffer
+	hook := LoggingHook{Logger: log.New(&buffer, "", 0)}
+
+	assert.Nil(s.T(), hook.Before(s.ctx), "should return no error")
+	assert.Equal(s.T(), "function=device_registry:Register transaction=tx1 organization=org1 device=device1 status=started latency=0s\n", buffer.String(), "should log transaction start")
+
+	buffer.Reset()
+	s.ctx.SetValue(transactionStartTimeKey, time.Now())
+	assert.Nil(s.T(), hook.After(s.ctx, nil), "should return no error")
+	assert.Contains(s.T(), buffer.String(), "transaction=tx1 organization=org1 device=device1 status=completed", "should log transaction completion")
+
+	buffer.Reset()
+	hook.Failed(s.ctx, errors.New("not found"))
+	assert.Contains(s.T(), buffer.String(), "transaction=tx1 organization=org1 device=device1 status=failed", "should log transaction failure")
+	assert.Contains(s.T(), buffer.String(), "error=\"not found\"\n", "should log transaction error")
+}
+
+func (s *TransactionHooksTestSuite) TestLatencyMetrics() {
+	metrics := new(LatencyMetrics)
+	assert.Nil(s.T(), metrics.Before(s.ctx), "should return no error")
+
+	s.ctx.SetValue(transactionStartTimeKey, time.Now().Add(-2*time.Second))
+	assert.Nil(s.T(), metrics.After(s.ctx, nil), "should return no error")
+	s.ctx.SetValue(transactionStartTimeKey, time.Now().Add(-4*time.Second))
+	_ = metrics.After(s.ctx, nil)
+
+	s.ctx.SetValue(transactionStartTimeKey, time.Now().Add(-3*time.Second))
+	metrics.Failed(s.ctx, errors.New(""))
+
+	latency := metrics.GetLatencies()["device_registry:Register"]
+	assert.Equal(s.T(), int64(3), latency.Calls, "should count calls")
+	assert.Equal(s.T(), int64(1), latency.Failures, "should count failed calls")
+	assert.GreaterOrEqual(s.T(), int64(latency.Max), int64(4*time.Second), "should record maximum latency")
+	assert.GreaterOrEqual(s.T(), int64(latency.GetAverage()), int64(3*time.Second), "should record average latency")
+	assert.Equal(s.T(), time.Duration(0), FunctionLatency{}.GetAverage(), "should return zero average without calls")
+}
+
+func TestTransactionHooksTestSuite(t *testing.T) {
+	suite.Run(t, new(TransactionHooksTestSuite))
+}
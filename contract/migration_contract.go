@@ -0,0 +1,51 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// MigrationOrganizationIds identities of the organizations whose administrators can migrate the ledger state,
+// no one can migrate the ledger state if it is empty
+var MigrationOrganizationIds []string
+
+// MigrationSmartContract smart contract for migrating the ledger state after chaincode upgrades
+type MigrationSmartContract struct {
+	contractapi.Contract
+}
+
+// Migrate apply the next chunk of the pending migrations, to be repeated until the ledger state is migrated
+func (s *MigrationSmartContract) Migrate(ctx TransactionContextInterface, chunkSize int) (*common.SchemaVersion, error) {
+	// the ledger state is shared by every organization, so only administrators of migration organizations can
+	// rewrite it
+	if ok, err := isMigrationAdmin(ctx); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, fmt.Errorf("cannot migrate ledger state from a client other than a migration administrator")
+	}
+
+	return ctx.GetMigrator().Migrate(chunkSize)
+}
+
+// GetVersion return the schema version of the ledger state and the version expected by the deployed chaincode
+func (s *MigrationSmartContract) GetVersion(ctx TransactionContextInterface) (*common.SchemaVersion, error) {
+	return ctx.GetMigrator().GetVersion()
+}
+
+// isMigrationAdmin check if the invoking identity is an administrator of a migration organization
+func isMigrationAdmin(ctx TransactionContextInterface) (bool, error) {
+	organizationId, err := ctx.GetOrganizationId()
+	if err != nil {
+		return false, err
+	}
+
+	for _, id := range MigrationOrganizationIds {
+		if id == organizationId {
+			return ctx.IsOrganizationAdmin()
+		}
+	}
+
+	return false, nil
+}
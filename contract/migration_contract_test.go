@@ -0,0 +1,56 @@
+package contract
+
+import (
+	"testing"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationContractTestSuite struct {
+	suite.Suite
+}
+
+func (s *MigrationContractTestSuite) TestMigrate() {
+	ctx := &MockTransactionContext{DeviceId: "admin1", OrganizationId: "org1", IsAdmin: true}
+	migrator := new(MockMigrator)
+	ctx.migrator = migrator
+
+	migrator.On("Migrate", 10).Return(&common.SchemaVersion{Version: 1, LatestVersion: 1}, nil)
+
+	MigrationOrganizationIds = []string{"org1"}
+	defer func() { MigrationOrganizationIds = nil }()
+
+	contract := new(MigrationSmartContract)
+	version, err := contract.Migrate(ctx, 10)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), 1, version.Version, "should return migrated version")
+
+	ctx.OrganizationId = "org2"
+	_, err = contract.Migrate(ctx, 10)
+	assert.Error(s.T(), err, "should refuse migration from an administrator of another organization")
+
+	ctx.OrganizationId = "org1"
+	ctx.IsAdmin = false
+	_, err = contract.Migrate(ctx, 10)
+	assert.Error(s.T(), err, "should refuse migration from a non-administrator")
+	migrator.AssertNumberOfCalls(s.T(), "Migrate", 1)
+}
+
+func (s *MigrationContractTestSuite) TestGetVersion() {
+	ctx := new(MockTransactionContext)
+	migrator := new(MockMigrator)
+	ctx.migrator = migrator
+
+	migrator.On("GetVersion").Return(new(common.SchemaVersion), nil)
+
+	contract := new(MigrationSmartContract)
+	_, _ = contract.GetVersion(ctx)
+	called := migrator.AssertCalled(s.T(), "GetVersion")
+	assert.True(s.T(), called, "should retrieve schema version from migrator")
+}
+
+func TestMigrationContractTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationContractTestSuite))
+}
@@ -0,0 +1,135 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// Migration an idempotent step that transforms the ledger state to a new schema version
+type Migration struct {
+	// Version schema version of the ledger state after the migration is applied
+	Version int
+
+	// Description summary of the changes made by the migration
+	Description string
+
+	// Apply migrate at most chunkSize states after the bookmark, and return the bookmark after the last migrated
+	// state, or an empty bookmark if all states have been migrated
+	Apply func(ctx TransactionContextInterface, bookmark string, chunkSize int) (string, error)
+}
+
+// Migrations migration steps registered by chaincode upgrades, ordered by their versions starting from 1
+var Migrations = []*Migration{
+	{
+		Version:     1,
+		Description: "count the devices and services of organizations into their summaries",
+		Apply: func(ctx TransactionContextInterface, bookmark string, chunkSize int) (string, error) {
+			return createDeviceRegistry(ctx).migrateSummaries(bookmark, chunkSize)
+		},
+	},
+}
+
+// MigratorInterface core utilities for migrating the ledger state between schema versions
+type MigratorInterface interface {
+	// GetVersion return the schema version of the ledger state
+	GetVersion() (*common.SchemaVersion, error)
+
+	// Migrate apply the next chunk of the pending migrations
+	Migrate(chunkSize int) (*common.SchemaVersion, error)
+}
+
+// Migrator core utilities for migrating the ledger state between schema versions
+type Migrator struct {
+	ctx           TransactionContextInterface
+	stateRegistry StateRegistryInterface
+	migrations    []*Migration
+}
+
+// GetVersion return the schema version of the ledger state
+func (m *Migrator) GetVersion() (*common.SchemaVersion, error) {
+	version := &common.SchemaVersion{}
+
+	// the ledger state of chaincode that has never been migrated is of the initial schema version
+	state, err := m.stateRegistry.GetState(version.GetKeyComponents()...)
+	if err == nil {
+		version = state.(*common.SchemaVersion)
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return nil, err
+	}
+
+	version.LatestVersion = 0
+	if len(m.migrations) > 0 {
+		version.LatestVersion = m.migrations[len(m.migrations)-1].Version
+	}
+
+	return version, nil
+}
+
+// Migrate apply the next chunk of the pending migrations
+func (m *Migrator) Migrate(chunkSize int) (*common.SchemaVersion, error) {
+	if err := common.ValidatePageSize(chunkSize); err != nil {
+		return nil, err
+	}
+
+	version, err := m.GetVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version.IsMigrated() {
+		return version, nil
+	}
+
+	var migration *Migration
+	for _, migration_ := range m.migrations {
+		if migration_.Version == version.Version+1 {
+			migration = migration_
+			break
+		}
+	}
+	if migration == nil {
+		return nil, fmt.Errorf("missing migration to schema version %d", version.Version+1)
+	}
+
+	if version.Bookmark, err = migration.Apply(m.ctx, version.Bookmark, common.GetPageSize(chunkSize)); err != nil {
+		return nil, err
+	}
+	if version.Bookmark == "" {
+		version.Version = migration.Version
+	}
+
+	return version, m.stateRegistry.PutState(version)
+}
+
+// migrateStates apply a migration function to at most chunkSize states of a registry after the bookmark, and return
+// the bookmark after the last migrated state, or an empty bookmark if all states have been migrated
+func migrateStates(registry StateRegistryInterface, bookmark string, chunkSize int, migrate func(StateInterface) error) (string, error) {
+	states, bookmark, err := registry.GetChunk(chunkSize, bookmark)
+	if err != nil {
+		return "", err
+	}
+
+	for _, state := range states {
+		if err = migrate(state); err != nil {
+			return "", err
+		}
+	}
+
+	return bookmark, nil
+}
+
+func createMigrator(ctx TransactionContextInterface) *Migrator {
+	stateRegistry := new(StateRegistry)
+	stateRegistry.ctx = ctx
+	stateRegistry.Name = "schema_versions"
+	stateRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeSchemaVersion(data)
+	}
+
+	migrator := new(Migrator)
+	migrator.ctx = ctx
+	migrator.stateRegistry = stateRegistry
+	migrator.migrations = Migrations
+
+	return migrator
+}
@@ -0,0 +1,140 @@
+package contract
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockMigrator struct {
+	mock.Mock
+}
+
+func (m *MockMigrator) GetVersion() (*common.SchemaVersion, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.SchemaVersion), args.Error(1)
+}
+
+func (m *MockMigrator) Migrate(chunkSize int) (*common.SchemaVersion, error) {
+	args := m.Called(chunkSize)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.SchemaVersion), args.Error(1)
+}
+
+type MigratorTestSuite struct {
+	suite.Suite
+	stateRegistry *MockStateRegistry
+	migrator      *Migrator
+	applied       []string
+}
+
+func (s *MigratorTestSuite) SetupTest() {
+	s.applied = make([]string, 0)
+	s.stateRegistry = new(MockStateRegistry)
+	s.stateRegistry.On("PutState", mock.Anything).Return(nil)
+
+	s.migrator = new(Migrator)
+	s.migrator.ctx = new(MockTransactionContext)
+	s.migrator.stateRegistry = s.stateRegistry
+	s.migrator.migrations = []*Migration{
+		{
+			Version: 1,
+			Apply: func(ctx TransactionContextInterface, bookmark string, chunkSize int) (string, error) {
+				s.applied = append(s.applied, "v1:"+bookmark)
+				if bookmark == "" {
+					return "b1", nil
+				}
+				return "", nil
+			},
+		},
+		{
+			Version: 2,
+			Apply: func(ctx TransactionContextInterface, bookmark string, chunkSize int) (string, error) {
+				s.applied = append(s.applied, "v2:"+bookmark)
+				return "", errors.New("")
+			},
+		},
+	}
+}
+
+func (s *MigratorTestSuite) TestGetVersion() {
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(nil, &common.NotFoundError{}).Once()
+	version, err := s.migrator.GetVersion()
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), &common.SchemaVersion{Version: 0, LatestVersion: 2}, version, "should return initial version if the ledger has never been migrated")
+
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(&common.SchemaVersion{Version: 1, LatestVersion: 1}, nil).Once()
+	version, _ = s.migrator.GetVersion()
+	assert.Equal(s.T(), &common.SchemaVersion{Version: 1, LatestVersion: 2}, version, "should return latest version of the deployed chaincode")
+
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(nil, errors.New("")).Once()
+	_, err = s.migrator.GetVersion()
+	assert.Error(s.T(), err, "should return ledger error")
+}
+
+func (s *MigratorTestSuite) TestMigrate() {
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(nil, &common.NotFoundError{}).Once()
+	version, err := s.migrator.Migrate(0)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), &common.SchemaVersion{Version: 0, LatestVersion: 2, Bookmark: "b1"}, version, "should record progress of the migration")
+	s.stateRegistry.AssertCalled(s.T(), "PutState", version)
+
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(&common.SchemaVersion{Version: 0, Bookmark: "b1"}, nil).Once()
+	version, _ = s.migrator.Migrate(0)
+	assert.Equal(s.T(), &common.SchemaVersion{Version: 1, LatestVersion: 2}, version, "should record applied version when the migration is finished")
+
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(&common.SchemaVersion{Version: 1}, nil).Once()
+	_, err = s.migrator.Migrate(0)
+	assert.Error(s.T(), err, "should return migration error")
+	assert.Equal(s.T(), []string{"v1:", "v1:b1", "v2:"}, s.applied, "should apply migrations in order from their bookmarks")
+	s.stateRegistry.AssertNumberOfCalls(s.T(), "PutState", 2)
+
+	s.stateRegistry.On("GetState", []string{"schema"}).Return(&common.SchemaVersion{Version: 2}, nil).Once()
+	version, _ = s.migrator.Migrate(0)
+	assert.True(s.T(), version.IsMigrated(), "should do nothing if the ledger has been migrated")
+	assert.Equal(s.T(), 3, len(s.applied), "should do nothing if the ledger has been migrated")
+
+	_, err = s.migrator.Migrate(common.MaxPageSize + 1)
+	assert.Error(s.T(), err, "should return error on invalid chunk size")
+}
+
+func (s *MigratorTestSuite) TestMigrateStates() {
+	registry := new(MockStateRegistry)
+	registry.On("GetChunk", 2, "", []string(nil)).Return([]StateInterface{&mockState{Id: "s1"}, &mockState{Id: "s2"}}, "k2", nil)
+	registry.On("GetChunk", 2, "k2", []string(nil)).Return([]StateInterface{&mockState{Id: "s3"}}, "", nil)
+	registry.On("GetChunk", 2, "!", []string(nil)).Return(nil, "", errors.New(""))
+
+	migrated := make([]string, 0)
+	migrate := func(state StateInterface) error {
+		migrated = append(migrated, state.(*mockState).Id)
+		return nil
+	}
+
+	bookmark, err := migrateStates(registry, "", 2, migrate)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []string{"s1", "s2"}, migrated, "should migrate a chunk of states")
+	assert.Equal(s.T(), "k2", bookmark, "should return resume key if there are more states")
+
+	bookmark, _ = migrateStates(registry, bookmark, 2, migrate)
+	assert.Equal(s.T(), []string{"s1", "s2", "s3"}, migrated, "should migrate states after the resume key")
+	assert.Empty(s.T(), bookmark, "should return empty bookmark if all states have been migrated")
+
+	_, err = migrateStates(registry, "", 2, func(StateInterface) error { return errors.New("") })
+	assert.Error(s.T(), err, "should return migration error")
+
+	_, err = migrateStates(registry, "!", 2, migrate)
+	assert.Error(s.T(), err, "should return error on invalid bookmark")
+}
+
+func TestMigratorTestSuite(t *testing.T) {
+	suite.Run(t, new(MigratorTestSuite))
+}
@@ -3,7 +3,11 @@ package contract
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 )
 
@@ -15,14 +19,45 @@ type ServiceBrokerInterface interface {
 	// Respond respond to an IoT service request
 	Respond(response *common.ServiceResponse) error
 
+	// Transition move an IoT service request to another lifecycle status
+	Transition(requestId string, status common.ServiceRequestStatus) (*common.ServiceRequest, error)
+
 	// Get return an IoT service request and its response by the request ID
 	Get(requestId string) (*common.ServiceRequestResponse, error)
 
 	// GetAll return a list of IoT service requests and their responses by their organization ID, device ID, and service name
 	GetAll(organizationId string, deviceId string, serviceName string) ([]*common.ServiceRequestResponse, error)
 
+	// GetAllByRequester return a list of IoT service requests and their responses by their requester's organization ID and client ID
+	GetAllByRequester(organizationId string, requesterId string) ([]*common.ServiceRequestResponse, error)
+
 	// Remove remove a (request, response) pair from the ledger
 	Remove(requestId string) error
+
+	// Rekey replace a device ID with its new ID as the requested device or the requester of a request
+	Rekey(requestId string, organizationId string, deviceId string, newDeviceId string) error
+
+	// RekeyDevice move the stream heads and quota usages of the services of a device to its new device ID
+	RekeyDevice(organizationId string, deviceId string, newDeviceId string) error
+
+	// Rate record the rating given by the requester to a completed IoT service request
+	Rate(requestId string, rating int) (*common.ServiceRequest, error)
+
+	// GetQuota return the remaining quota of a requester on an IoT service
+	GetQuota(organizationId string, deviceId string, serviceName string, requesterOrganizationId string, requesterId string) (*common.ServiceQuotaStatus, error)
+
+	// RequestGroup make a request to an IoT service of every member device of a device group
+	RequestGroup(request *common.GroupServiceRequest) ([]*common.ServiceRequest, error)
+
+	// GetGroupRequest return a group request and the requests made to the member devices with their responses
+	GetGroupRequest(requestId string) (*common.GroupServiceRequestResult, error)
+
+	// Publish assign the next sequence number of its stream to a telemetry message
+	Publish(message *common.StreamMessage) error
+
+	// Relay count in the quality metrics of a service of this chaincode the progress of a request made to it from
+	// another chaincode, as read from the service broker of that chaincode
+	Relay(channelId string, chaincodeId string, requestId string) error
 }
 
 // Dummy index object
@@ -55,22 +90,210 @@ func deserializeServiceRequestIndex(data []byte) (*serviceRequestIndex, error) {
 	return index, nil
 }
 
+// Dummy index object of requests made by a client
+type serviceRequesterIndex struct {
+	OrganizationId string `json:"organizationId"`
+	RequesterId    string `json:"requesterId"`
+	RequestId      string `json:"requestId"`
+}
+
+func (i *serviceRequesterIndex) GetKeyComponents() []string {
+	return []string{i.OrganizationId, i.RequesterId, i.RequestId}
+}
+
+func (i *serviceRequesterIndex) Serialize() ([]byte, error) {
+	return json.Marshal(i)
+}
+
+func (i *serviceRequesterIndex) Validate() error {
+	return nil
+}
+
+func deserializeServiceRequesterIndex(data []byte) (*serviceRequesterIndex, error) {
+	index := new(serviceRequesterIndex)
+
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Usage counters of a requester on an IoT service with a quota
+type serviceUsage struct {
+	OrganizationId          string    `json:"organizationId"`
+	DeviceId                string    `json:"deviceId"`
+	ServiceName             string    `json:"serviceName"`
+	RequesterOrganizationId string    `json:"requesterOrganizationId"`
+	RequesterId             string    `json:"requesterId,omitempty"`
+	WindowStart             time.Time `json:"windowStart"`
+	Requests                int       `json:"requests"`
+	Pending                 int       `json:"pending"`
+}
+
+func (u *serviceUsage) GetKeyComponents() []string {
+	components := []string{u.OrganizationId, u.DeviceId, u.ServiceName, u.RequesterOrganizationId}
+	if u.RequesterId != "" {
+		components = append(components, u.RequesterId)
+	}
+	return components
+}
+
+func (u *serviceUsage) Serialize() ([]byte, error) {
+	return json.Marshal(u)
+}
+
+func (u *serviceUsage) Validate() error {
+	return nil
+}
+
+// start a new window if the current one has ended
+func (u *serviceUsage) refresh(quota *common.ServiceQuota, now time.Time) {
+	if quota.MaxRequests == 0 {
+		return
+	}
+	if u.WindowStart.IsZero() || !now.Before(u.WindowStart.Add(quota.GetWindow())) {
+		u.WindowStart = now
+		u.Requests = 0
+	}
+}
+
+func deserializeServiceUsage(data []byte) (*serviceUsage, error) {
+	usage := new(serviceUsage)
+
+	if err := json.Unmarshal(data, usage); err != nil {
+		return nil, err
+	}
+
+	return usage, nil
+}
+
+// Head of a telemetry stream, kept apart from the service record so that publishing does not conflict with
+// transactions reading the service
+type serviceStreamHead struct {
+	OrganizationId  string    `json:"organizationId"`
+	DeviceId        string    `json:"deviceId"`
+	ServiceName     string    `json:"serviceName"`
+	Topic           string    `json:"topic"`
+	Sequence        int64     `json:"sequence"`
+	LastPublishTime time.Time `json:"lastPublishTime"`
+}
+
+func (h *serviceStreamHead) GetKeyComponents() []string {
+	return []string{h.OrganizationId, h.DeviceId, h.ServiceName, h.Topic}
+}
+
+func (h *serviceStreamHead) Serialize() ([]byte, error) {
+	return json.Marshal(h)
+}
+
+func (h *serviceStreamHead) Validate() error {
+	return nil
+}
+
+func deserializeServiceStreamHead(data []byte) (*serviceStreamHead, error) {
+	head := new(serviceStreamHead)
+
+	if err := json.Unmarshal(data, head); err != nil {
+		return nil, err
+	}
+
+	return head, nil
+}
+
+// Progress of a request made from another chaincode that has been counted in the quality metrics of the requested
+// service, the request itself is counted when it is relayed for the first time
+type serviceRequestRelay struct {
+	ChannelId   string `json:"channelId"`
+	ChaincodeId string `json:"chaincodeId"`
+	RequestId   string `json:"requestId"`
+	Responded   bool   `json:"responded,omitempty"`
+	Rated       bool   `json:"rated,omitempty"`
+}
+
+func (r *serviceRequestRelay) GetKeyComponents() []string {
+	return []string{r.ChannelId, r.ChaincodeId, r.RequestId}
+}
+
+func (r *serviceRequestRelay) Serialize() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+func (r *serviceRequestRelay) Validate() error {
+	return nil
+}
+
+func deserializeServiceRequestRelay(data []byte) (*serviceRequestRelay, error) {
+	relay := new(serviceRequestRelay)
+
+	if err := json.Unmarshal(data, relay); err != nil {
+		return nil, err
+	}
+
+	return relay, nil
+}
+
+// RemoteServiceRegistryName name of the service registry contract of other chaincodes, in which requests to their
+// services look up the requested service
+var RemoteServiceRegistryName = "service_registry"
+
+// RemoteDeviceRegistryName name of the device registry contract of other chaincodes, in which requests to their
+// services check the revocation of the requested device
+var RemoteDeviceRegistryName = "device_registry"
+
+// RemoteServiceBrokerName name of the service broker contract of other chaincodes, from which the progress of the
+// requests made there to services of this chaincode is relayed
+var RemoteServiceBrokerName = "service_broker"
+
 // ServiceBroker core utilities for managing IoT service requests and responses on the ledger
 type ServiceBroker struct {
-	ctx              TransactionContextInterface
-	requestRegistry  StateRegistryInterface
-	responseRegistry StateRegistryInterface
-	indexRegistry    StateRegistryInterface
+	ctx                    TransactionContextInterface
+	requestRegistry        StateRegistryInterface
+	responseRegistry       StateRegistryInterface
+	indexRegistry          StateRegistryInterface
+	requesterIndexRegistry StateRegistryInterface
+	usageRegistry          StateRegistryInterface
+	groupRequestRegistry   StateRegistryInterface
+	streamHeadRegistry     StateRegistryInterface
+	relayRegistry          StateRegistryInterface
+
+	// usages quota usages updated by the transaction, kept because the ledger does not read its own writes
+	usages map[string]*serviceUsage
+
+	// rekeyedUsages keys of the quota usages moved by the transaction, which the ledger still returns until committed
+	rekeyedUsages map[string]bool
 }
 
 // Request make a request to an IoT service
 func (b *ServiceBroker) Request(request *common.ServiceRequest) error {
+	if request.RequesterOrganizationId == "" || request.RequesterId == "" {
+		return fmt.Errorf("missing requester in request definition")
+	}
+
 	// check if service exists
-	service := request.Service
-	_, err := b.ctx.GetServiceRegistry().Get(service.OrganizationId, service.DeviceId, service.Name)
+	service, err := b.getService(request)
 	if err != nil {
 		return err
 	}
+	// address the request to the current ID of the device
+	request.Service.DeviceId = service.DeviceId
+
+	// revoked devices cannot be requested
+	if revoked, err := b.isDeviceRevoked(request); err != nil {
+		return err
+	} else if revoked {
+		return &common.AccessDeniedError{Reason: fmt.Sprintf("device %s has been revoked", service.DeviceId)}
+	}
+
+	// check if the requester is allowed to call the service method
+	if !service.Acl.Allows(service.OrganizationId, request.RequesterOrganizationId, request.RequesterId, request.Method) {
+		return &common.AccessDeniedError{Reason: fmt.Sprintf("requester is not allowed to call method %s of the service", request.Method)}
+	}
+	if service.Acl != nil {
+		if err = service.Acl.Attributes.Check(b.ctx.GetClientIdentity().GetAttributeValue); err != nil {
+			return err
+		}
+	}
 
 	// check if request already exists
 	request_, err := b.getRequest(request.Id)
@@ -81,11 +304,38 @@ func (b *ServiceBroker) Request(request *common.ServiceRequest) error {
 		return fmt.Errorf("request already exists")
 	}
 
+	// check the quota of the requester, which is consumed only after every other check passes, usages of services
+	// of other chaincodes cannot be recorded since those chaincodes can only be queried
+	var usage *serviceUsage
+	if service.Quota != nil && request.IsRemote() {
+		return &common.AccessDeniedError{Reason: "services with a quota cannot be requested from another chaincode"}
+	}
+	if service.Quota != nil {
+		if usage, err = b.checkQuota(service, request); err != nil {
+			return err
+		}
+	}
+
+	// hold the price of the service until the request is finished
+	request.Price = service.Price
+	if request.Price > 0 {
+		if err = b.ctx.GetAccountLedger().Escrow(request); err != nil {
+			return err
+		}
+	}
+
+	if usage != nil {
+		if err = b.putUsage(usage); err != nil {
+			return err
+		}
+	}
+
+	request.Status = common.ServiceRequestPending
 	if err = b.requestRegistry.PutState(request); err != nil {
 		return err
 	}
 
-	return b.indexRegistry.PutState(
+	err = b.indexRegistry.PutState(
 		&serviceRequestIndex{
 			OrganizationId: request.Service.OrganizationId,
 			DeviceId:       request.Service.DeviceId,
@@ -93,12 +343,30 @@ func (b *ServiceBroker) Request(request *common.ServiceRequest) error {
 			RequestId:      request.Id,
 		},
 	)
+	if err != nil {
+		return err
+	}
+
+	err = b.requesterIndexRegistry.PutState(
+		&serviceRequesterIndex{
+			OrganizationId: request.RequesterOrganizationId,
+			RequesterId:    request.RequesterId,
+			RequestId:      request.Id,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	return b.updateStats(request, func(stats *common.ServiceStats) {
+		stats.Requests++
+	})
 }
 
 // Respond respond to an IoT service request
 func (b *ServiceBroker) Respond(response *common.ServiceResponse) error {
 	// check if the request exists
-	_, err := b.getRequest(response.RequestId)
+	request, err := b.getRequest(response.RequestId)
 	if err != nil {
 		return err
 	}
@@ -112,7 +380,612 @@ func (b *ServiceBroker) Respond(response *common.ServiceResponse) error {
 		return fmt.Errorf("response already exists")
 	}
 
-	return b.responseRegistry.PutState(response)
+	if response.Time.Before(request.Time) {
+		return &common.InvalidTimeError{Reason: "response cannot be made before its request"}
+	}
+
+	// devices of other chaincodes are revoked there, out of reach of the revocation check of the ledger
+	if request.IsRemote() {
+		if revoked, err := b.isDeviceRevoked(request); err != nil {
+			return err
+		} else if revoked {
+			return &common.AccessDeniedError{Reason: fmt.Sprintf("device %s has been revoked", request.Service.DeviceId)}
+		}
+	}
+
+	status := common.ServiceRequestCompleted
+	if response.StatusCode != 0 {
+		status = common.ServiceRequestFailed
+	}
+	if !request.Status.CanTransitionTo(status) {
+		return fmt.Errorf("cannot respond to a request with status %s", request.Status)
+	}
+
+	if err = b.responseRegistry.PutState(response); err != nil {
+		return err
+	}
+
+	request.Status = status
+	if err = b.requestRegistry.PutState(request); err != nil {
+		return err
+	}
+
+	err = b.updateStats(request, func(stats *common.ServiceStats) {
+		addResponseStats(stats, request, response)
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.finish(request)
+}
+
+// Rate record the rating given by the requester to a completed IoT service request
+func (b *ServiceBroker) Rate(requestId string, rating int) (*common.ServiceRequest, error) {
+	if rating < common.MinServiceRating || rating > common.MaxServiceRating {
+		return nil, fmt.Errorf("rating must be between %d and %d", common.MinServiceRating, common.MaxServiceRating)
+	}
+
+	request, err := b.getRequest(requestId)
+	if err != nil {
+		return nil, err
+	}
+	if request.Status != common.ServiceRequestCompleted {
+		return nil, fmt.Errorf("cannot rate a request with status %s", request.Status)
+	}
+	if request.Rating != 0 {
+		return nil, fmt.Errorf("request has already been rated")
+	}
+
+	request.Rating = rating
+	if err = b.requestRegistry.PutState(request); err != nil {
+		return nil, err
+	}
+
+	err = b.updateStats(request, func(stats *common.ServiceStats) {
+		addRatingStats(stats, rating)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// getService return the requested service from the service registry of the chaincode providing it
+func (b *ServiceBroker) getService(request *common.ServiceRequest) (*common.Service, error) {
+	if !request.IsRemote() {
+		return b.ctx.GetServiceRegistry().Get(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name)
+	}
+
+	// chaincodes of other channels can only be queried, so the request is stored in the current chaincode
+	payload, err := b.invokeRemote(request.ChannelId, request.ChaincodeId, RemoteServiceRegistryName+":Get", request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	service, err := common.DeserializeService(payload)
+	if err != nil {
+		return nil, err
+	}
+	if service.OrganizationId != request.Service.OrganizationId || service.Name != request.Service.Name {
+		return nil, fmt.Errorf("chaincode %s of channel %s returned another service than the requested one", request.ChaincodeId, request.ChannelId)
+	}
+
+	return service, nil
+}
+
+// isDeviceRevoked check if the requested device has been revoked in the device registry of the chaincode providing
+// the requested service
+func (b *ServiceBroker) isDeviceRevoked(request *common.ServiceRequest) (bool, error) {
+	if !request.IsRemote() {
+		return b.ctx.GetDeviceRegistry().IsRevoked(request.Service.OrganizationId, request.Service.DeviceId, "")
+	}
+
+	payload, err := b.invokeRemote(request.ChannelId, request.ChaincodeId, RemoteDeviceRegistryName+":GetRevocations", request.Service.OrganizationId)
+	if err != nil {
+		return false, err
+	}
+
+	var revocations []*common.DeviceRevocation
+	if len(payload) > 0 {
+		if err = json.Unmarshal(payload, &revocations); err != nil {
+			return false, err
+		}
+	}
+	for _, revocation := range revocations {
+		if revocation.DeviceId == request.Service.DeviceId && revocation.Matches("") {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// invokeRemote query a function of another chaincode, possibly of another channel, and return its result
+func (b *ServiceBroker) invokeRemote(channelId string, chaincodeId string, function string, args ...string) ([]byte, error) {
+	args_ := [][]byte{[]byte(function)}
+	for _, arg := range args {
+		args_ = append(args_, []byte(arg))
+	}
+
+	response := b.ctx.GetStub().InvokeChaincode(chaincodeId, args_, channelId)
+	if response.Status != shim.OK {
+		return nil, fmt.Errorf("cannot call %s on chaincode %s of channel %s: %s", function, chaincodeId, channelId, response.Message)
+	}
+
+	return response.Payload, nil
+}
+
+// return the name of the chaincode invoked by the transaction, as found in the signed proposal
+func (b *ServiceBroker) getChaincodeId() (string, error) {
+	signedProposal, err := b.ctx.GetStub().GetSignedProposal()
+	if err != nil {
+		return "", err
+	}
+	if signedProposal == nil {
+		return "", fmt.Errorf("missing signed proposal of the transaction")
+	}
+
+	proposal := new(peer.Proposal)
+	if err = proto.Unmarshal(signedProposal.ProposalBytes, proposal); err != nil {
+		return "", err
+	}
+	payload := new(peer.ChaincodeProposalPayload)
+	if err = proto.Unmarshal(proposal.Payload, payload); err != nil {
+		return "", err
+	}
+	spec := new(peer.ChaincodeInvocationSpec)
+	if err = proto.Unmarshal(payload.Input, spec); err != nil {
+		return "", err
+	}
+
+	return spec.GetChaincodeSpec().GetChaincodeId().GetName(), nil
+}
+
+// Relay count in the quality metrics of a service of this chaincode the progress of a request made to it from
+// another chaincode, as read from the service broker of that chaincode
+func (b *ServiceBroker) Relay(channelId string, chaincodeId string, requestId string) error {
+	if chaincodeId == "" {
+		return fmt.Errorf("missing chaincode of the relayed request")
+	}
+
+	currentChannelId := b.ctx.GetStub().GetChannelID()
+	if channelId == "" {
+		channelId = currentChannelId
+	}
+
+	payload, err := b.invokeRemote(channelId, chaincodeId, RemoteServiceBrokerName+":Get", requestId)
+	if err != nil {
+		return err
+	}
+	pair, err := common.DeserializeServiceRequestResponse(payload)
+	if err != nil {
+		return err
+	}
+
+	// the requested service must be one of this channel, requests naming no channel target the channel they are made on
+	request := pair.Request
+	targetChannelId := request.ChannelId
+	if targetChannelId == "" {
+		targetChannelId = channelId
+	}
+	if request.Id != requestId || !request.IsRemote() || targetChannelId != currentChannelId {
+		return fmt.Errorf("request %s was not made to a service of this channel", requestId)
+	}
+	currentChaincodeId, err := b.getChaincodeId()
+	if err != nil {
+		return err
+	}
+	if request.ChaincodeId != currentChaincodeId {
+		return fmt.Errorf("request %s was not made to a service of this chaincode", requestId)
+	}
+
+	relay := &serviceRequestRelay{ChannelId: channelId, ChaincodeId: chaincodeId, RequestId: requestId}
+	state, err := b.relayRegistry.GetState(relay.GetKeyComponents()...)
+	requested := false
+	if err == nil {
+		relay = state.(*serviceRequestRelay)
+	} else if _, ok := err.(*common.NotFoundError); ok {
+		requested = true
+	} else {
+		return err
+	}
+
+	responded := pair.Response != nil && !relay.Responded
+	// the remote ledger is not trusted to have checked the rating
+	rated := request.Status == common.ServiceRequestCompleted && request.Rating >= common.MinServiceRating &&
+		request.Rating <= common.MaxServiceRating && !relay.Rated
+	if !requested && !responded && !rated {
+		return nil
+	}
+
+	stats := &common.ServiceStats{OrganizationId: request.Service.OrganizationId, DeviceId: request.Service.DeviceId, ServiceName: request.Service.Name}
+	if requested {
+		stats.Requests++
+	}
+	if responded {
+		addResponseStats(stats, request, pair.Response)
+		relay.Responded = true
+	}
+	if rated {
+		addRatingStats(stats, request.Rating)
+		relay.Rated = true
+	}
+
+	if err = b.ctx.GetServiceRegistry().AddStats(stats); err != nil {
+		return err
+	}
+	return b.relayRegistry.PutState(relay)
+}
+
+// addResponseStats count a response to a request in the quality metrics of the requested service
+func addResponseStats(stats *common.ServiceStats, request *common.ServiceRequest, response *common.ServiceResponse) {
+	stats.Responses++
+	if response.StatusCode != 0 {
+		stats.ErrorResponses++
+	}
+	if latency := response.Time.Sub(request.Time).Milliseconds(); latency > 0 {
+		stats.TotalLatency += latency
+	}
+}
+
+// addRatingStats count the rating of a request in the quality metrics of the requested service
+func addRatingStats(stats *common.ServiceStats, rating int) {
+	stats.Ratings++
+	stats.TotalRating += int64(rating)
+}
+
+// updateStats apply a change to the quality metrics of the requested service, if it still exists
+func (b *ServiceBroker) updateStats(request *common.ServiceRequest, update func(*common.ServiceStats)) error {
+	// quality metrics of services of other chaincodes are counted there when the progress of the request is relayed
+	if request.IsRemote() {
+		return nil
+	}
+
+	stats := &common.ServiceStats{OrganizationId: request.Service.OrganizationId, DeviceId: request.Service.DeviceId, ServiceName: request.Service.Name}
+	update(stats)
+
+	err := b.ctx.GetServiceRegistry().AddStats(stats)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	}
+	return err
+}
+
+// Transition move an IoT service request to another lifecycle status
+func (b *ServiceBroker) Transition(requestId string, status common.ServiceRequestStatus) (*common.ServiceRequest, error) {
+	request, err := b.getRequest(requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	// completed and failed are reached by responding to the request
+	if status == common.ServiceRequestCompleted || status == common.ServiceRequestFailed {
+		return nil, fmt.Errorf("cannot move a request to status %s without a response", status)
+	}
+	if !request.Status.CanTransitionTo(status) {
+		return nil, fmt.Errorf("cannot move a request from status %s to %s", request.Status, status)
+	}
+	if status == common.ServiceRequestExpired {
+		if err = b.checkExpiry(request); err != nil {
+			return nil, err
+		}
+	}
+
+	request.Status = status
+	if err = b.requestRegistry.PutState(request); err != nil {
+		return nil, err
+	}
+
+	if status.IsFinal() {
+		if err = b.finish(request); err != nil {
+			return nil, err
+		}
+	}
+
+	return request, nil
+}
+
+func (b *ServiceBroker) checkExpiry(request *common.ServiceRequest) error {
+	expiryTime := request.GetExpiryTime()
+	if expiryTime.IsZero() {
+		return fmt.Errorf("cannot expire a request without timeout")
+	}
+
+	now, err := b.ctx.GetTimestamp()
+	if err != nil {
+		return err
+	}
+	if now.Before(expiryTime) {
+		return fmt.Errorf("cannot expire a request before %s", expiryTime.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// finish release the quota and the escrowed tokens held by a request that has reached a final status
+func (b *ServiceBroker) finish(request *common.ServiceRequest) error {
+	if err := b.releaseQuota(request); err != nil {
+		return err
+	}
+	if request.Price == 0 {
+		return nil
+	}
+
+	// only successful requests are paid for
+	if request.Status == common.ServiceRequestCompleted {
+		return b.ctx.GetAccountLedger().Release(request)
+	}
+	return b.ctx.GetAccountLedger().Refund(request)
+}
+
+func (b *ServiceBroker) getUsage(service *common.Service, requesterOrganizationId string, requesterId string) (*serviceUsage, error) {
+	usage := &serviceUsage{
+		OrganizationId:          service.OrganizationId,
+		DeviceId:                service.DeviceId,
+		ServiceName:             service.Name,
+		RequesterOrganizationId: requesterOrganizationId,
+	}
+	if service.Quota.Scope == common.ServiceQuotaPerClient {
+		usage.RequesterId = requesterId
+	}
+
+	if usage_, ok := b.usages[getStateKey(usage)]; ok {
+		return usage_, nil
+	}
+
+	state, err := b.usageRegistry.GetState(usage.GetKeyComponents()...)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return usage, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return state.(*serviceUsage), nil
+}
+
+// check the quota of the requester and return its usage counting the request, to be written by the caller
+func (b *ServiceBroker) checkQuota(service *common.Service, request *common.ServiceRequest) (*serviceUsage, error) {
+	now, err := b.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := b.getUsage(service, request.RequesterOrganizationId, request.RequesterId)
+	if err != nil {
+		return nil, err
+	}
+	usage.refresh(service.Quota, now)
+
+	if service.Quota.MaxRequests > 0 && usage.Requests >= service.Quota.MaxRequests {
+		return nil, &common.LimitExceededError{Limit: fmt.Sprintf("at most %d requests per %s", service.Quota.MaxRequests, service.Quota.GetWindow())}
+	}
+	if service.Quota.MaxPending > 0 && usage.Pending >= service.Quota.MaxPending {
+		return nil, &common.LimitExceededError{Limit: fmt.Sprintf("at most %d unfinished requests", service.Quota.MaxPending)}
+	}
+
+	usage.Requests++
+	usage.Pending++
+	return usage, nil
+}
+
+// release the pending slot taken by a request when it is finished
+func (b *ServiceBroker) releaseQuota(request *common.ServiceRequest) error {
+	// requests to services of other chaincodes take no quota
+	if request.IsRemote() {
+		return nil
+	}
+
+	service, err := b.ctx.GetServiceRegistry().Get(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if service.Quota == nil {
+		return nil
+	}
+
+	usage, err := b.getUsage(service, request.RequesterOrganizationId, request.RequesterId)
+	if err != nil {
+		return err
+	}
+	if usage.Pending == 0 {
+		return nil
+	}
+
+	usage.Pending--
+	return b.putUsage(usage)
+}
+
+func (b *ServiceBroker) putUsage(usage *serviceUsage) error {
+	if err := b.usageRegistry.PutState(usage); err != nil {
+		return err
+	}
+
+	if b.usages == nil {
+		b.usages = make(map[string]*serviceUsage)
+	}
+	b.usages[getStateKey(usage)] = usage
+
+	return nil
+}
+
+// GetQuota return the remaining quota of a requester on an IoT service
+func (b *ServiceBroker) GetQuota(organizationId string, deviceId string, serviceName string, requesterOrganizationId string, requesterId string) (*common.ServiceQuotaStatus, error) {
+	service, err := b.ctx.GetServiceRegistry().Get(organizationId, deviceId, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &common.ServiceQuotaStatus{RemainingRequests: -1, RemainingPending: -1}
+	if service.Quota == nil {
+		return status, nil
+	}
+	status.Quota = service.Quota
+
+	now, err := b.ctx.GetTimestamp()
+	if err != nil {
+		return nil, err
+	}
+
+	usage, err := b.getUsage(service, requesterOrganizationId, requesterId)
+	if err != nil {
+		return nil, err
+	}
+	usage.refresh(service.Quota, now)
+
+	status.Requests = usage.Requests
+	status.Pending = usage.Pending
+	if service.Quota.MaxRequests > 0 {
+		status.RemainingRequests = service.Quota.MaxRequests - usage.Requests
+		if status.RemainingRequests < 0 {
+			status.RemainingRequests = 0
+		}
+		status.ResetTime = usage.WindowStart.Add(service.Quota.GetWindow())
+	}
+	if service.Quota.MaxPending > 0 {
+		status.RemainingPending = service.Quota.MaxPending - usage.Pending
+		if status.RemainingPending < 0 {
+			status.RemainingPending = 0
+		}
+	}
+
+	return status, nil
+}
+
+// RequestGroup make a request to an IoT service of every member device of a device group
+func (b *ServiceBroker) RequestGroup(request *common.GroupServiceRequest) ([]*common.ServiceRequest, error) {
+	if request.RequesterOrganizationId == "" || request.RequesterId == "" {
+		return nil, fmt.Errorf("missing requester in group request definition")
+	}
+	if err := request.Validate(); err != nil {
+		return nil, err
+	}
+
+	// check if group request already exists
+	if _, err := b.groupRequestRegistry.GetState(request.Id); err == nil {
+		return nil, fmt.Errorf("group request already exists")
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return nil, err
+	}
+
+	group, err := b.ctx.GetDeviceGroupRegistry().Get(request.GroupOrganizationId, request.GroupName)
+	if err != nil {
+		return nil, err
+	}
+
+	// fan the request out to the members providing the service, skipping those that do not or have been revoked
+	requests := make([]*common.ServiceRequest, 0)
+	request.RequestIds = make([]string, 0)
+	request.Failures = nil
+	for _, member := range group.Members {
+		if revoked, err := b.ctx.GetDeviceRegistry().IsRevoked(member.OrganizationId, member.DeviceId, ""); err != nil {
+			return nil, err
+		} else if revoked {
+			continue
+		}
+		if _, err = b.ctx.GetServiceRegistry().Get(member.OrganizationId, member.DeviceId, request.ServiceName); err != nil {
+			if _, ok := err.(*common.NotFoundError); ok {
+				continue
+			}
+			return nil, err
+		}
+
+		// requests are refused before anything is written, so the other members can still be requested
+		memberRequest := request.NewRequest(member)
+		if err = b.Request(memberRequest); err != nil {
+			if request.Failures == nil {
+				request.Failures = make(map[string]string)
+			}
+			request.Failures[member.OrganizationId+"/"+member.DeviceId] = err.Error()
+			continue
+		}
+		requests = append(requests, memberRequest)
+		request.RequestIds = append(request.RequestIds, memberRequest.Id)
+	}
+	if len(requests) == 0 && len(request.Failures) > 0 {
+		return nil, fmt.Errorf("no member of the device group providing service %s accepted the request", request.ServiceName)
+	}
+	if len(requests) == 0 {
+		return nil, fmt.Errorf("no member of the device group provides service %s", request.ServiceName)
+	}
+
+	if err = b.groupRequestRegistry.PutState(request); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// GetGroupRequest return a group request and the requests made to the member devices with their responses
+func (b *ServiceBroker) GetGroupRequest(requestId string) (*common.GroupServiceRequestResult, error) {
+	state, err := b.groupRequestRegistry.GetState(requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	request := state.(*common.GroupServiceRequest)
+	result := &common.GroupServiceRequestResult{
+		Request:   request,
+		Responses: make([]*common.ServiceRequestResponse, 0),
+		Progress:  make(map[common.ServiceRequestStatus]int),
+	}
+	for _, id := range request.RequestIds {
+		// member requests may have been removed along with their services
+		pair, err := b.Get(id)
+		if _, ok := err.(*common.NotFoundError); ok {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		result.Responses = append(result.Responses, pair)
+		result.Progress[pair.Request.Status]++
+	}
+
+	return result, nil
+}
+
+// Publish assign the next sequence number of its stream to a telemetry message
+func (b *ServiceBroker) Publish(message *common.StreamMessage) error {
+	if err := message.Validate(); err != nil {
+		return err
+	}
+
+	service, err := b.ctx.GetServiceRegistry().Get(message.OrganizationId, message.DeviceId, message.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	if service.GetStream(message.Topic) == nil {
+		return &common.NotFoundError{What: fmt.Sprintf("stream %s of service %s", message.Topic, message.ServiceName)}
+	}
+
+	// heads outlive their services, so that sequence numbers never go back when a service is registered again
+	head := &serviceStreamHead{
+		OrganizationId: message.OrganizationId,
+		DeviceId:       message.DeviceId,
+		ServiceName:    message.ServiceName,
+		Topic:          message.Topic,
+	}
+	state, err := b.streamHeadRegistry.GetState(head.GetKeyComponents()...)
+	if err == nil {
+		head = state.(*serviceStreamHead)
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return err
+	}
+	if message.Time.Before(head.LastPublishTime) {
+		return &common.InvalidTimeError{Reason: "stream message cannot be published before the previous one"}
+	}
+
+	// messages are not kept on the ledger, only the head of their stream is
+	head.Sequence++
+	head.LastPublishTime = message.Time
+	message.Sequence = head.Sequence
+
+	return b.streamHeadRegistry.PutState(head)
 }
 
 func (b *ServiceBroker) getRequest(requestId string) (*common.ServiceRequest, error) {
@@ -155,15 +1028,38 @@ func (b *ServiceBroker) GetAll(organizationId string, deviceId string, serviceNa
 		return nil, err
 	}
 
-	results := make([]*common.ServiceRequestResponse, 0)
+	requestIds := make([]string, 0)
+	for _, state := range states {
+		requestIds = append(requestIds, state.(*serviceRequestIndex).RequestId)
+	}
+
+	return b.getPairs(requestIds)
+}
+
+// GetAllByRequester return a list of IoT service requests and their responses by their requester's organization ID and client ID
+func (b *ServiceBroker) GetAllByRequester(organizationId string, requesterId string) ([]*common.ServiceRequestResponse, error) {
+	states, err := b.requesterIndexRegistry.GetStates(organizationId, requesterId)
+	if err != nil {
+		return nil, err
+	}
 
+	requestIds := make([]string, 0)
 	for _, state := range states {
-		index := state.(*serviceRequestIndex)
-		request, err := b.getRequest(index.RequestId)
+		requestIds = append(requestIds, state.(*serviceRequesterIndex).RequestId)
+	}
+
+	return b.getPairs(requestIds)
+}
+
+func (b *ServiceBroker) getPairs(requestIds []string) ([]*common.ServiceRequestResponse, error) {
+	results := make([]*common.ServiceRequestResponse, 0)
+
+	for _, requestId := range requestIds {
+		request, err := b.getRequest(requestId)
 		if err != nil {
 			return nil, err
 		}
-		response, err := b.getResponse(index.RequestId)
+		response, err := b.getResponse(requestId)
 		if _, ok := err.(*common.NotFoundError); err != nil && !ok {
 			return nil, err
 		}
@@ -171,7 +1067,7 @@ func (b *ServiceBroker) GetAll(organizationId string, deviceId string, serviceNa
 		results = append(results, &common.ServiceRequestResponse{Request: request, Response: response})
 	}
 
-	return results, err
+	return results, nil
 }
 
 // Remove remove a (request, response) pair from the ledger
@@ -195,15 +1091,175 @@ func (b *ServiceBroker) Remove(requestId string) error {
 	if err = b.requestRegistry.RemoveState(request); err != nil {
 		return err
 	}
+	if !request.Status.IsFinal() && request.RequesterOrganizationId != "" {
+		if err = b.releaseQuota(request); err != nil {
+			return err
+		}
+		if request.Price > 0 {
+			if err = b.ctx.GetAccountLedger().Refund(request); err != nil {
+				return err
+			}
+		}
+	}
 
-	// remove index from global state
+	// remove indices from global state
 	index := &serviceRequestIndex{
 		OrganizationId: request.Service.OrganizationId,
 		DeviceId:       request.Service.DeviceId,
 		ServiceName:    request.Service.Name,
 		RequestId:      request.Id,
 	}
-	return b.indexRegistry.RemoveState(index)
+	if err = b.indexRegistry.RemoveState(index); err != nil {
+		return err
+	}
+
+	// requests made before requesters were recorded have no requester index
+	if request.RequesterOrganizationId == "" || request.RequesterId == "" {
+		return nil
+	}
+	requesterIndex := &serviceRequesterIndex{
+		OrganizationId: request.RequesterOrganizationId,
+		RequesterId:    request.RequesterId,
+		RequestId:      request.Id,
+	}
+	return b.requesterIndexRegistry.RemoveState(requesterIndex)
+}
+
+// Rekey replace a device ID with its new ID as the requested device or the requester of a request
+func (b *ServiceBroker) Rekey(requestId string, organizationId string, deviceId string, newDeviceId string) error {
+	request, err := b.getRequest(requestId)
+	if err != nil {
+		return err
+	}
+
+	if request.Service.OrganizationId == organizationId && request.Service.DeviceId == deviceId {
+		index := &serviceRequestIndex{
+			OrganizationId: request.Service.OrganizationId,
+			DeviceId:       request.Service.DeviceId,
+			ServiceName:    request.Service.Name,
+			RequestId:      request.Id,
+		}
+		if err = b.indexRegistry.RemoveState(index); err != nil {
+			return err
+		}
+
+		index.DeviceId = newDeviceId
+		if err = b.indexRegistry.PutState(index); err != nil {
+			return err
+		}
+
+		request.Service.DeviceId = newDeviceId
+	}
+
+	if request.RequesterOrganizationId == organizationId && request.RequesterId == deviceId {
+		index := &serviceRequesterIndex{
+			OrganizationId: request.RequesterOrganizationId,
+			RequesterId:    request.RequesterId,
+			RequestId:      request.Id,
+		}
+		if err = b.requesterIndexRegistry.RemoveState(index); err != nil {
+			return err
+		}
+
+		index.RequesterId = newDeviceId
+		if err = b.requesterIndexRegistry.PutState(index); err != nil {
+			return err
+		}
+
+		if err = b.rekeyRequesterUsage(request, newDeviceId); err != nil {
+			return err
+		}
+
+		request.RequesterId = newDeviceId
+	}
+
+	return b.requestRegistry.PutState(request)
+}
+
+// move the usage of the requester of a request on a service with a per-client quota to its new device ID, so that
+// rekeying does not reset the quota of the requester
+func (b *ServiceBroker) rekeyRequesterUsage(request *common.ServiceRequest, newDeviceId string) error {
+	if request.IsRemote() {
+		return nil
+	}
+
+	usage := &serviceUsage{
+		OrganizationId:          request.Service.OrganizationId,
+		DeviceId:                request.Service.DeviceId,
+		ServiceName:             request.Service.Name,
+		RequesterOrganizationId: request.RequesterOrganizationId,
+		RequesterId:             request.RequesterId,
+	}
+	// other requests of the requester to the same service share the usage
+	if b.rekeyedUsages[getStateKey(usage)] {
+		return nil
+	}
+
+	state, err := b.usageRegistry.GetState(usage.GetKeyComponents()...)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return b.moveUsage(state.(*serviceUsage), func(usage *serviceUsage) {
+		usage.RequesterId = newDeviceId
+	})
+}
+
+// RekeyDevice move the stream heads and quota usages of the services of a device to its new device ID
+func (b *ServiceBroker) RekeyDevice(organizationId string, deviceId string, newDeviceId string) error {
+	states, err := b.streamHeadRegistry.GetStates(organizationId, deviceId)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		head := state.(*serviceStreamHead)
+		if err = b.streamHeadRegistry.RemoveState(head); err != nil {
+			return err
+		}
+
+		head.DeviceId = newDeviceId
+		if err = b.streamHeadRegistry.PutState(head); err != nil {
+			return err
+		}
+	}
+
+	states, err = b.usageRegistry.GetStates(organizationId, deviceId)
+	if err != nil {
+		return err
+	}
+	for _, state := range states {
+		usage := state.(*serviceUsage)
+		if b.rekeyedUsages[getStateKey(usage)] {
+			continue
+		}
+
+		err = b.moveUsage(usage, func(usage *serviceUsage) {
+			usage.DeviceId = newDeviceId
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replace the key of a quota usage, keeping its counters
+func (b *ServiceBroker) moveUsage(usage *serviceUsage, rekey func(usage *serviceUsage)) error {
+	if err := b.usageRegistry.RemoveState(usage); err != nil {
+		return err
+	}
+
+	if b.rekeyedUsages == nil {
+		b.rekeyedUsages = make(map[string]bool)
+	}
+	b.rekeyedUsages[getStateKey(usage)] = true
+	delete(b.usages, getStateKey(usage))
+
+	rekey(usage)
+	return b.putUsage(usage)
 }
 
 func createServiceBroker(ctx TransactionContextInterface) *ServiceBroker {
@@ -228,11 +1284,51 @@ func createServiceBroker(ctx TransactionContextInterface) *ServiceBroker {
 		return deserializeServiceRequestIndex(data)
 	}
 
+	requesterIndexRegistry := new(StateRegistry)
+	requesterIndexRegistry.ctx = ctx
+	requesterIndexRegistry.Name = "requester_indices"
+	requesterIndexRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeServiceRequesterIndex(data)
+	}
+
+	usageRegistry := new(StateRegistry)
+	usageRegistry.ctx = ctx
+	usageRegistry.Name = "request_usages"
+	usageRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeServiceUsage(data)
+	}
+
+	groupRequestRegistry := new(StateRegistry)
+	groupRequestRegistry.ctx = ctx
+	groupRequestRegistry.Name = "group_requests"
+	groupRequestRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeGroupServiceRequest(data)
+	}
+
+	streamHeadRegistry := new(StateRegistry)
+	streamHeadRegistry.ctx = ctx
+	streamHeadRegistry.Name = "service_stream_heads"
+	streamHeadRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeServiceStreamHead(data)
+	}
+
+	relayRegistry := new(StateRegistry)
+	relayRegistry.ctx = ctx
+	relayRegistry.Name = "request_relays"
+	relayRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeServiceRequestRelay(data)
+	}
+
 	broker := new(ServiceBroker)
 	broker.ctx = ctx
 	broker.requestRegistry = requestRegistry
 	broker.responseRegistry = responseRegistry
 	broker.indexRegistry = indexRegistry
+	broker.requesterIndexRegistry = requesterIndexRegistry
+	broker.usageRegistry = usageRegistry
+	broker.groupRequestRegistry = groupRequestRegistry
+	broker.streamHeadRegistry = streamHeadRegistry
+	broker.relayRegistry = relayRegistry
 
 	return broker
 }
@@ -19,22 +19,77 @@ func (s *ServiceBrokerSmartContract) Request(ctx TransactionContextInterface, da
 		return err
 	}
 
+	// record the calling client as the requester regardless of what the client claims
+	if request.RequesterOrganizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if request.RequesterId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	if request.Time, err = getTrustedTime(ctx, request.Time); err != nil {
+		return err
+	}
+
+	// only the service broker and workflow registry link requests to group requests and workflow runs
+	request.GroupRequestId = ""
+	request.WorkflowRunId, request.WorkflowStep = "", ""
+	// requests are rated only after they are completed
+	request.Rating = 0
+
 	err = ctx.GetServiceBroker().Request(request)
 
 	// notify listening clients of the update
 	if err == nil {
-		event := fmt.Sprintf("request://%s/%s/%s/%s/request", request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id)
 		payload, _ := request.Serialize()
-		err = ctx.GetStub().SetEvent(event, payload)
+		err = ctx.SetEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "request", payload))
 	}
 
 	return err
 }
 
+// RequestGroup make a request to an IoT service of every member device of a device group
+func (s *ServiceBrokerSmartContract) RequestGroup(ctx TransactionContextInterface, data string) error {
+	request, err := common.DeserializeGroupServiceRequest([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	// record the calling client as the requester regardless of what the client claims
+	if request.RequesterOrganizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if request.RequesterId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	if request.Time, err = getTrustedTime(ctx, request.Time); err != nil {
+		return err
+	}
+
+	requests, err := ctx.GetServiceBroker().RequestGroup(request)
+	if err != nil {
+		return err
+	}
+
+	// notify the member devices of their requests together with the group request
+	for _, request := range requests {
+		payload, _ := request.Serialize()
+		ctx.AddEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "request", payload))
+	}
+
+	payload, _ := request.Serialize()
+	return ctx.SetEvent(newGroupEvent(request.GroupOrganizationId, request.GroupName, "request", payload))
+}
+
+// GetGroupRequest return a group request and the requests made to the member devices with their responses
+func (s *ServiceBrokerSmartContract) GetGroupRequest(ctx TransactionContextInterface, requestId string) (*common.GroupServiceRequestResult, error) {
+	return ctx.GetServiceBroker().GetGroupRequest(requestId)
+}
+
 // Respond respond to an IoT service request
 func (s *ServiceBrokerSmartContract) Respond(ctx TransactionContextInterface, data string) error {
 	var err error
-	var organizationId, deviceId string
 	var response *common.ServiceResponse
 
 	if response, err = common.DeserializeServiceResponse([]byte(data)); err != nil {
@@ -47,31 +102,165 @@ func (s *ServiceBrokerSmartContract) Respond(ctx TransactionContextInterface, da
 		return err
 	}
 
-	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+	// check if the client creating the response is the client requested for service
+	request := pair.Request
+	if err = checkDevice(ctx, request.Service.OrganizationId, request.Service.DeviceId, "cannot create response from a device other than the requested device"); err != nil {
 		return err
 	}
-	if deviceId, err = ctx.GetDeviceId(); err != nil {
+
+	if response.Time, err = getTrustedTime(ctx, response.Time); err != nil {
 		return err
 	}
 
-	// check if the client creating the response is the client requested for service
+	err = ctx.GetServiceBroker().Respond(response)
+
+	// advance the workflow run that made the request, if any
+	if err == nil {
+		err = advanceWorkflow(ctx, request, response)
+	}
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := response.Serialize()
+		err = ctx.SetEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "respond", payload))
+	}
+
+	return err
+}
+
+// Publish publish a telemetry message on a stream of an IoT service of the calling device
+func (s *ServiceBrokerSmartContract) Publish(ctx TransactionContextInterface, data string) error {
+	var err error
+	var message *common.StreamMessage
+
+	if message, err = common.DeserializeStreamMessage([]byte(data)); err != nil {
+		return err
+	}
+
+	// check if the client publishing the message is the device providing the service
+	if err = checkDevice(ctx, message.OrganizationId, message.DeviceId, "cannot publish a message from a device other than the publishing device"); err != nil {
+		return err
+	}
+
+	if message.Time, err = getTrustedTime(ctx, message.Time); err != nil {
+		return err
+	}
+
+	err = ctx.GetServiceBroker().Publish(message)
+
+	// deliver the message to the subscribers
+	if err == nil {
+		payload, _ := message.Serialize()
+		err = ctx.SetEvent(newStreamEvent(message.OrganizationId, message.DeviceId, message.ServiceName, message.Topic, "publish", payload))
+	}
+
+	return err
+}
+
+// Accept acknowledge an IoT service request as the requested device
+func (s *ServiceBrokerSmartContract) Accept(ctx TransactionContextInterface, requestId string) error {
+	return s.transition(ctx, requestId, common.ServiceRequestAccepted, "accept", true)
+}
+
+// Progress report that the requested device is working on an IoT service request
+func (s *ServiceBrokerSmartContract) Progress(ctx TransactionContextInterface, requestId string) error {
+	return s.transition(ctx, requestId, common.ServiceRequestInProgress, "progress", true)
+}
+
+// Reject refuse to handle an IoT service request as the requested device
+func (s *ServiceBrokerSmartContract) Reject(ctx TransactionContextInterface, requestId string) error {
+	return s.transition(ctx, requestId, common.ServiceRequestRejected, "reject", true)
+}
+
+// Cancel withdraw an IoT service request that has not been responded to
+func (s *ServiceBrokerSmartContract) Cancel(ctx TransactionContextInterface, requestId string) error {
+	return s.transition(ctx, requestId, common.ServiceRequestCancelled, "cancel", false)
+}
+
+// Rate give a rating to a completed IoT service request as its requester
+func (s *ServiceBrokerSmartContract) Rate(ctx TransactionContextInterface, requestId string, rating int) error {
+	// check if corresponding request exists
+	pair, err := ctx.GetServiceBroker().Get(requestId)
+	if err != nil {
+		return err
+	}
+
+	// only the requester can rate the request
 	request := pair.Request
-	if request.Service.OrganizationId != organizationId || request.Service.DeviceId != deviceId {
-		return fmt.Errorf("cannot create response from a device other than the requested device")
+	if err = checkDevice(ctx, request.RequesterOrganizationId, request.RequesterId, "cannot rate a request from a client other than the requester"); err != nil {
+		return err
 	}
 
-	err = ctx.GetServiceBroker().Respond(response)
+	request, err = ctx.GetServiceBroker().Rate(requestId, rating)
 
 	// notify listening clients of the update
 	if err == nil {
-		event := fmt.Sprintf("request://%s/%s/%s/%s/respond", request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id)
-		payload, _ := response.Serialize()
-		err = ctx.GetStub().SetEvent(event, payload)
+		payload, _ := request.Serialize()
+		err = ctx.SetEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "rate", payload))
+	}
+
+	return err
+}
+
+// Expire close an IoT service request that has not been responded to before its timeout
+func (s *ServiceBrokerSmartContract) Expire(ctx TransactionContextInterface, requestId string) error {
+	// anyone can expire a request once its timeout has passed
+	request, err := ctx.GetServiceBroker().Transition(requestId, common.ServiceRequestExpired)
+
+	// advance the workflow run that made the request, if any
+	if err == nil {
+		err = advanceWorkflow(ctx, request, nil)
+	}
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := request.Serialize()
+		err = ctx.SetEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "expire", payload))
+	}
+
+	return err
+}
+
+func (s *ServiceBrokerSmartContract) transition(ctx TransactionContextInterface, requestId string, status common.ServiceRequestStatus, action string, byDevice bool) error {
+	// check if corresponding request exists
+	pair, err := ctx.GetServiceBroker().Get(requestId)
+	if err != nil {
+		return err
+	}
+
+	// only the requested device can work on the request, while only the requester can cancel it
+	request := pair.Request
+	if byDevice {
+		err = checkDevice(ctx, request.Service.OrganizationId, request.Service.DeviceId, fmt.Sprintf("cannot %s a request from a device other than the requested device", action))
+	} else {
+		err = checkDevice(ctx, request.RequesterOrganizationId, request.RequesterId, fmt.Sprintf("cannot %s a request from a client other than the requester", action))
+	}
+	if err != nil {
+		return err
+	}
+
+	request, err = ctx.GetServiceBroker().Transition(requestId, status)
+
+	// advance the workflow run that made the request, if any
+	if err == nil {
+		err = advanceWorkflow(ctx, request, nil)
+	}
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := request.Serialize()
+		err = ctx.SetEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, action, payload))
 	}
 
 	return err
 }
 
+// Relay count in the quality metrics of a service of this chaincode the progress of a request made to it from
+// another chaincode, which anyone can relay since it is read from the service broker of that chaincode
+func (s *ServiceBrokerSmartContract) Relay(ctx TransactionContextInterface, channelId string, chaincodeId string, requestId string) error {
+	return ctx.GetServiceBroker().Relay(channelId, chaincodeId, requestId)
+}
+
 // Get return an IoT service request and its response by the request ID
 func (s *ServiceBrokerSmartContract) Get(ctx TransactionContextInterface, requestId string) (*common.ServiceRequestResponse, error) {
 	return ctx.GetServiceBroker().Get(requestId)
@@ -82,36 +271,55 @@ func (s *ServiceBrokerSmartContract) GetAll(ctx TransactionContextInterface, org
 	return ctx.GetServiceBroker().GetAll(organizationId, deviceId, serviceName)
 }
 
-// Remove remove a (request, response) pair from the ledger
-func (s *ServiceBrokerSmartContract) Remove(ctx TransactionContextInterface, requestId string) error {
+// GetMyRequests return a list of IoT service requests made by the calling client and their responses
+func (s *ServiceBrokerSmartContract) GetMyRequests(ctx TransactionContextInterface) ([]*common.ServiceRequestResponse, error) {
 	var err error
 	var organizationId, deviceId string
 
-	// check if corresponding request exists
-	pair, err := ctx.GetServiceBroker().Get(requestId)
-	if err != nil {
-		return err
-	}
-
 	if organizationId, err = ctx.GetOrganizationId(); err != nil {
-		return err
+		return nil, err
 	}
 	if deviceId, err = ctx.GetDeviceId(); err != nil {
+		return nil, err
+	}
+
+	return ctx.GetServiceBroker().GetAllByRequester(organizationId, deviceId)
+}
+
+// GetQuota return the remaining quota of the calling client on an IoT service
+func (s *ServiceBrokerSmartContract) GetQuota(ctx TransactionContextInterface, organizationId string, deviceId string, serviceName string) (*common.ServiceQuotaStatus, error) {
+	var err error
+	var requesterOrganizationId, requesterId string
+
+	if requesterOrganizationId, err = ctx.GetOrganizationId(); err != nil {
+		return nil, err
+	}
+	if requesterId, err = ctx.GetDeviceId(); err != nil {
+		return nil, err
+	}
+
+	return ctx.GetServiceBroker().GetQuota(organizationId, deviceId, serviceName, requesterOrganizationId, requesterId)
+}
+
+// Remove remove a (request, response) pair from the ledger
+func (s *ServiceBrokerSmartContract) Remove(ctx TransactionContextInterface, requestId string) error {
+	// check if corresponding request exists
+	pair, err := ctx.GetServiceBroker().Get(requestId)
+	if err != nil {
 		return err
 	}
 
-	// check if the client creating the response is the client requested for service
+	// check if the client removing the request is the requested device or its organization administrator
 	request := pair.Request
-	if pair.Request.Service.OrganizationId != organizationId || pair.Request.Service.DeviceId != deviceId {
-		return fmt.Errorf("cannot remove response from a device other than the requested device")
+	if err := checkDeviceManager(ctx, request.Service.OrganizationId, request.Service.DeviceId, "cannot remove response from a device other than the requested device"); err != nil {
+		return err
 	}
 
 	err = ctx.GetServiceBroker().Remove(requestId)
 
 	// notify listening clients of the update
 	if err == nil {
-		event := fmt.Sprintf("request://%s/%s/%s/%s/remove", request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id)
-		err = ctx.GetStub().SetEvent(event, []byte(requestId))
+		err = ctx.SetEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "remove", []byte(requestId)))
 	}
 
 	return err
@@ -3,7 +3,9 @@ package contract
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -15,7 +17,8 @@ type ServiceBrokerContractTestSuite struct {
 }
 
 func (s *ServiceBrokerContractTestSuite) TestRequest() {
-	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:38:30-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
 	serviceBroker := new(MockServiceBroker)
 	ctx.serviceBroker = serviceBroker
 
@@ -28,21 +31,90 @@ func (s *ServiceBrokerContractTestSuite) TestRequest() {
 	assert.True(s.T(), called, "should put request to service broker")
 	request := serviceBroker.Calls[0].Arguments[0].(*common.ServiceRequest)
 	assert.Equal(s.T(), "request1", request.Id, "should have correct request ID")
+	assert.Equal(s.T(), ctx.OrganizationId, request.RequesterOrganizationId, "should record requester organization ID")
+	assert.Equal(s.T(), ctx.DeviceId, request.RequesterId, "should record requester client ID")
+	assert.Equal(s.T(), now, request.Time, "should record transaction time")
 	request, _ = common.DeserializeServiceRequest(ctx.stub.EventPayload)
 	assert.Equal(s.T(), fmt.Sprintf("request://%s/%s/%s/%s/request", ctx.OrganizationId, ctx.DeviceId, "service1", "request1"), ctx.stub.EventName, "should emit event with name")
 	assert.Equal(s.T(), "request1", request.Id, "should emit event with payload")
 	ctx.stub.ResetEvent()
 
+	err = contract.Request(ctx, fmt.Sprintf("{\"id\":\"request2\",\"service\":{\"name\":\"service1\",\"organizationId\":\"%s\",\"deviceId\":\"%s\"},\"requesterOrganizationId\":\"org9\",\"requesterId\":\"device9\",\"groupRequestId\":\"group-request1\",\"workflowRunId\":\"run1\",\"workflowStep\":\"read\",\"rating\":5}", ctx.OrganizationId, ctx.DeviceId))
+	assert.Nil(s.T(), err, "should return no error")
+	request = serviceBroker.Calls[1].Arguments[0].(*common.ServiceRequest)
+	assert.Equal(s.T(), ctx.OrganizationId, request.RequesterOrganizationId, "should ignore client-supplied requester organization ID")
+	assert.Equal(s.T(), ctx.DeviceId, request.RequesterId, "should ignore client-supplied requester client ID")
+	assert.Empty(s.T(), request.GroupRequestId, "should ignore client-supplied group request ID")
+	assert.Empty(s.T(), request.WorkflowRunId, "should ignore client-supplied workflow run ID")
+	assert.Empty(s.T(), request.WorkflowStep, "should ignore client-supplied workflow step")
+	assert.Zero(s.T(), request.Rating, "should ignore client-supplied rating")
+	ctx.stub.ResetEvent()
+
+	err = contract.Request(ctx, fmt.Sprintf("{\"id\":\"request3\",\"time\":\"2021-12-12T16:38:00-05:00\",\"service\":{\"name\":\"service1\",\"organizationId\":\"%s\",\"deviceId\":\"%s\"}}", ctx.OrganizationId, ctx.DeviceId))
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
 	err = contract.Request(ctx, "[]")
 	assert.Error(s.T(), err, "should return deserialization error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
 }
 
-func (s *ServiceBrokerContractTestSuite) TestRespond() {
+func (s *ServiceBrokerContractTestSuite) TestRequestGroup() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:38:30-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	ctx.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	requests := []*common.ServiceRequest{
+		{Id: "request1", Service: common.Service{OrganizationId: "org1", DeviceId: "device2", Name: "service1"}},
+		{Id: "request2", Service: common.Service{OrganizationId: "org2", DeviceId: "device3", Name: "service1"}},
+	}
+	serviceBroker.On("RequestGroup", mock.MatchedBy(func(r *common.GroupServiceRequest) bool { return r.GroupName == "group1" })).Return(requests, nil)
+	serviceBroker.On("RequestGroup", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	contract := new(ServiceBrokerSmartContract)
+	err := contract.RequestGroup(ctx, "{\"id\":\"group-request1\",\"time\":\"2021-12-12T17:38:00-05:00\",\"groupOrganizationId\":\"org1\",\"groupName\":\"group1\",\"serviceName\":\"service1\",\"method\":\"GET\",\"arguments\":[],\"requesterId\":\"device9\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	request := serviceBroker.Calls[0].Arguments[0].(*common.GroupServiceRequest)
+	assert.Equal(s.T(), ctx.OrganizationId, request.RequesterOrganizationId, "should record requester organization ID")
+	assert.Equal(s.T(), ctx.DeviceId, request.RequesterId, "should ignore client-supplied requester client ID")
+	assert.Equal(s.T(), now, request.Time, "should record transaction time")
+	event, _ := common.DeserializeCompositeEvent(ctx.stub.EventPayload)
+	assert.Equal(s.T(), 3, len(event.Events), "should emit the group request with the member requests")
+	assert.Equal(s.T(), "group://org1/group1/request", event.Events[0].Name, "should emit group request event first")
+	assert.Equal(s.T(), "request://org1/device2/service1/request1/request", event.Events[1].Name, "should emit member request events")
+	assert.Equal(s.T(), "request://org2/device3/service1/request2/request", event.Events[2].Name, "should emit member request events")
+	ctx.stub.ResetEvent()
+
+	err = contract.RequestGroup(ctx, "{\"id\":\"group-request2\",\"groupOrganizationId\":\"org1\",\"groupName\":\"group2\"}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.RequestGroup(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestGetGroupRequest() {
 	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
 	serviceBroker := new(MockServiceBroker)
 	ctx.serviceBroker = serviceBroker
 
+	serviceBroker.On("GetGroupRequest", "group-request1").Return(new(common.GroupServiceRequestResult), nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	_, _ = contract.GetGroupRequest(ctx, "group-request1")
+	called := serviceBroker.AssertCalled(s.T(), "GetGroupRequest", "group-request1")
+	assert.True(s.T(), called, "should retrieve group request from service broker")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestRespond() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:40:30-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
 	serviceBroker.On("Respond", mock.AnythingOfType("*common.ServiceResponse")).Return(nil)
 
 	pair := &common.ServiceRequestResponse{
@@ -66,11 +138,16 @@ func (s *ServiceBrokerContractTestSuite) TestRespond() {
 	assert.True(s.T(), called, "should put response to service broker")
 	response := serviceBroker.Calls[1].Arguments[0].(*common.ServiceResponse)
 	assert.Equal(s.T(), "request1", response.RequestId, "should change device ID")
+	assert.Equal(s.T(), now, response.Time, "should record transaction time")
 	response, _ = common.DeserializeServiceResponse(ctx.stub.EventPayload)
 	assert.Equal(s.T(), fmt.Sprintf("request://%s/%s/%s/%s/respond", ctx.OrganizationId, ctx.DeviceId, "service1", "request1"), ctx.stub.EventName, "should emit event with name")
 	assert.Equal(s.T(), "request1", response.RequestId, "should emit event with payload")
 	ctx.stub.ResetEvent()
 
+	err = contract.Respond(ctx, "{\"requestId\":\"request1\",\"time\":\"2021-12-12T18:40:00-05:00\"}")
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
 	err = contract.Respond(ctx, "[]")
 	assert.Error(s.T(), err, "should return deserialization error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
@@ -85,6 +162,212 @@ func (s *ServiceBrokerContractTestSuite) TestRespond() {
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
 }
 
+func (s *ServiceBrokerContractTestSuite) TestRespondWorkflow() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:40:30-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	ctx.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+	serviceBroker := new(MockServiceBroker)
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.serviceBroker = serviceBroker
+	ctx.workflowRegistry = workflowRegistry
+
+	request := &common.ServiceRequest{
+		Id:            "request1",
+		Service:       common.Service{Name: "service1", DeviceId: ctx.DeviceId, OrganizationId: ctx.OrganizationId},
+		WorkflowRunId: "run1",
+		WorkflowStep:  "read",
+	}
+	serviceBroker.On("Get", "request1").Return(&common.ServiceRequestResponse{Request: request}, nil)
+	serviceBroker.On("Respond", mock.AnythingOfType("*common.ServiceResponse")).Return(nil)
+	run := &common.WorkflowRun{Id: "run1", WorkflowOrganizationId: "org1", WorkflowName: "workflow1", Status: common.WorkflowRunRunning}
+	next := []*common.ServiceRequest{{Id: "request2", Service: common.Service{OrganizationId: "org2", DeviceId: "device2", Name: "service2"}}}
+	workflowRegistry.On("Advance", request, mock.AnythingOfType("*common.ServiceResponse")).Return(run, next, nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	err := contract.Respond(ctx, "{\"requestId\":\"request1\",\"returnValue\":\"31.5\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	workflowRegistry.AssertCalled(s.T(), "Advance", request, mock.AnythingOfType("*common.ServiceResponse"))
+	event, _ := common.DeserializeCompositeEvent(ctx.stub.EventPayload)
+	assert.Equal(s.T(), 3, len(event.Events), "should emit the response with the workflow changes")
+	assert.Equal(s.T(), "request://org1/device1/service1/request1/respond", event.Events[0].Name, "should emit response event first")
+	assert.Equal(s.T(), "request://org2/device2/service2/request2/request", event.Events[1].Name, "should emit next step request events")
+	assert.Equal(s.T(), "workflow://org1/workflow1/advance", event.Events[2].Name, "should emit workflow advance event")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestPublish() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:40:30-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	serviceBroker.On("Publish", mock.MatchedBy(func(m *common.StreamMessage) bool { return m.Topic == "temperature" })).Run(func(args mock.Arguments) {
+		args.Get(0).(*common.StreamMessage).Sequence = 7
+	}).Return(nil)
+	serviceBroker.On("Publish", mock.Anything).Return(new(common.NotFoundError))
+
+	contract := new(ServiceBrokerSmartContract)
+	err := contract.Publish(ctx, "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serviceName\":\"service1\",\"topic\":\"temperature\",\"sequence\":99,\"time\":\"2021-12-12T17:40:00-05:00\",\"data\":\"21.5\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	message, _ := common.DeserializeStreamMessage(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "stream://org1/device1/service1/temperature/publish", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), int64(7), message.Sequence, "should emit the sequence number assigned by the service broker")
+	assert.Equal(s.T(), "21.5", message.Data, "should emit event with payload")
+	assert.True(s.T(), now.Equal(message.Time), "should record transaction time")
+	ctx.stub.ResetEvent()
+
+	err = contract.Publish(ctx, "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"serviceName\":\"service1\",\"topic\":\"humidity\",\"data\":\"40\"}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Publish(ctx, "{\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"topic\":\"temperature\",\"time\":\"2021-12-12T16:40:00-05:00\"}")
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Publish(ctx, "{\"organizationId\":\"org1\",\"deviceId\":\"device2\",\"serviceName\":\"service1\",\"topic\":\"temperature\",\"data\":\"21.5\"}")
+	assert.Error(s.T(), err, "should refuse to publish for another device")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Publish(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestTransition() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	request := &common.ServiceRequest{
+		Id: "request1",
+		Service: common.Service{
+			Name:           "service1",
+			DeviceId:       ctx.DeviceId,
+			OrganizationId: ctx.OrganizationId,
+		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	serviceBroker.On("Get", "request1").Return(&common.ServiceRequestResponse{Request: request}, nil)
+	serviceBroker.On("Get", mock.Anything).Return(nil, new(common.NotFoundError))
+	serviceBroker.On("Transition", "request1", mock.Anything).Return(request, nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	transitions := []struct {
+		Action string
+		Status common.ServiceRequestStatus
+		Invoke func(TransactionContextInterface, string) error
+	}{
+		{"accept", common.ServiceRequestAccepted, contract.Accept},
+		{"progress", common.ServiceRequestInProgress, contract.Progress},
+		{"reject", common.ServiceRequestRejected, contract.Reject},
+	}
+
+	for _, transition := range transitions {
+		ctx.DeviceId = "device1"
+		err := transition.Invoke(ctx, "request1")
+		assert.Nil(s.T(), err, "should return no error")
+		called := serviceBroker.AssertCalled(s.T(), "Transition", "request1", transition.Status)
+		assert.True(s.T(), called, "should change request status in service broker")
+		assert.Equal(s.T(), fmt.Sprintf("request://%s/%s/%s/%s/%s", "org1", "device1", "service1", "request1", transition.Action), ctx.stub.EventName, "should emit event with name")
+		ctx.stub.ResetEvent()
+
+		err = transition.Invoke(ctx, "request2")
+		assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+		assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+		ctx.DeviceId = "device2"
+		err = transition.Invoke(ctx, "request1")
+		assert.Error(s.T(), err, "should refuse to change status for another device")
+		assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+	}
+
+	ctx.OrganizationId = "org2"
+	ctx.DeviceId = "device2"
+	err := contract.Cancel(ctx, "request1")
+	assert.Nil(s.T(), err, "should return no error")
+	called := serviceBroker.AssertCalled(s.T(), "Transition", "request1", common.ServiceRequestCancelled)
+	assert.True(s.T(), called, "should cancel request in service broker")
+	assert.Equal(s.T(), fmt.Sprintf("request://%s/%s/%s/%s/cancel", "org1", "device1", "service1", "request1"), ctx.stub.EventName, "should emit event with name")
+	request, _ = common.DeserializeServiceRequest(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "request1", request.Id, "should emit event with payload")
+	ctx.stub.ResetEvent()
+
+	ctx.DeviceId = "device3"
+	err = contract.Cancel(ctx, "request1")
+	assert.Error(s.T(), err, "should refuse to cancel from a client other than the requester")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestRate() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	request := &common.ServiceRequest{
+		Id:                      "request1",
+		Service:                 common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	serviceBroker.On("Get", "request1").Return(&common.ServiceRequestResponse{Request: request}, nil)
+	serviceBroker.On("Get", mock.Anything).Return(nil, new(common.NotFoundError))
+	serviceBroker.On("Rate", "request1", 5).Return(request, nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	err := contract.Rate(ctx, "request1", 5)
+	assert.Nil(s.T(), err, "should return no error")
+	called := serviceBroker.AssertCalled(s.T(), "Rate", "request1", 5)
+	assert.True(s.T(), called, "should rate request in service broker")
+	assert.Equal(s.T(), "request://org1/device1/service1/request1/rate", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.Rate(ctx, "request2", 5)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	ctx.DeviceId = "device1"
+	ctx.OrganizationId = "org1"
+	err = contract.Rate(ctx, "request1", 5)
+	assert.Error(s.T(), err, "should refuse to rate from a client other than the requester")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestExpire() {
+	ctx := &MockTransactionContext{DeviceId: "device3", OrganizationId: "org3"}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	request := &common.ServiceRequest{
+		Id:      "request1",
+		Service: common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"},
+	}
+	serviceBroker.On("Transition", "request1", common.ServiceRequestExpired).Return(request, nil)
+	serviceBroker.On("Transition", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+
+	contract := new(ServiceBrokerSmartContract)
+	err := contract.Expire(ctx, "request1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "request://org1/device1/service1/request1/expire", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.Expire(ctx, "request2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestRelay() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	serviceBroker.On("Relay", "channel2", "iotservice", "request1").Return(nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	err := contract.Relay(ctx, "channel2", "iotservice", "request1")
+	assert.Nil(s.T(), err, "should return no error")
+	called := serviceBroker.AssertCalled(s.T(), "Relay", "channel2", "iotservice", "request1")
+	assert.True(s.T(), called, "should relay the request by the service broker")
+}
+
 func (s *ServiceBrokerContractTestSuite) TestGet() {
 	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
 	serviceBroker := new(MockServiceBroker)
@@ -111,6 +394,32 @@ func (s *ServiceBrokerContractTestSuite) TestGetAll() {
 	assert.True(s.T(), called, "should retrieve requests & responses from service broker")
 }
 
+func (s *ServiceBrokerContractTestSuite) TestGetMyRequests() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	serviceBroker.On("GetAllByRequester", "org2", "device2").Return([]*common.ServiceRequestResponse{{}, {}}, nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	_, _ = contract.GetMyRequests(ctx)
+	called := serviceBroker.AssertCalled(s.T(), "GetAllByRequester", "org2", "device2")
+	assert.True(s.T(), called, "should retrieve requests of the calling client from service broker")
+}
+
+func (s *ServiceBrokerContractTestSuite) TestGetQuota() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	serviceBroker := new(MockServiceBroker)
+	ctx.serviceBroker = serviceBroker
+
+	serviceBroker.On("GetQuota", "org1", "device1", "service1", "org2", "device2").Return(new(common.ServiceQuotaStatus), nil)
+
+	contract := new(ServiceBrokerSmartContract)
+	_, _ = contract.GetQuota(ctx, "org1", "device1", "service1")
+	called := serviceBroker.AssertCalled(s.T(), "GetQuota", "org1", "device1", "service1", "org2", "device2")
+	assert.True(s.T(), called, "should retrieve quota of the calling client from service broker")
+}
+
 func (s *ServiceBrokerContractTestSuite) TestRemove() {
 	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
 	serviceBroker := new(MockServiceBroker)
@@ -151,6 +460,17 @@ func (s *ServiceBrokerContractTestSuite) TestRemove() {
 	err = contract.Remove(ctx, "request1")
 	assert.Error(s.T(), err, "should refuse to respond for another device")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.IsAdmin = true
+	err = contract.Remove(ctx, "request1")
+	assert.Nil(s.T(), err, "should allow organization administrators to remove requests")
+	assert.Equal(s.T(), fmt.Sprintf("request://%s/%s/%s/%s/remove", "org1", "device1", "service1", "request1"), ctx.stub.EventName, "should emit event with name")
+
+	ctx.OrganizationId = "org2"
+	ctx.stub.ResetEvent()
+	err = contract.Remove(ctx, "request1")
+	assert.Error(s.T(), err, "should refuse administrators of other organizations")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
 }
 
 func TestServiceBrokerContractTestSuite(t *testing.T) {
@@ -1,8 +1,13 @@
 package contract
 
 import (
+	"encoding/json"
+	"fmt"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -23,6 +28,14 @@ func (r *MockServiceBroker) Respond(response *common.ServiceResponse) error {
 	return args.Error(0)
 }
 
+func (r *MockServiceBroker) Transition(requestId string, status common.ServiceRequestStatus) (*common.ServiceRequest, error) {
+	args := r.Called(requestId, status)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.ServiceRequest), args.Error(1)
+}
+
 func (r *MockServiceBroker) Get(requestId string) (*common.ServiceRequestResponse, error) {
 	args := r.Called(requestId)
 	if args.Get(0) == nil {
@@ -36,11 +49,68 @@ func (r *MockServiceBroker) GetAll(organizationId string, deviceId string, servi
 	return args.Get(0).([]*common.ServiceRequestResponse), args.Error(1)
 }
 
+func (r *MockServiceBroker) GetAllByRequester(organizationId string, requesterId string) ([]*common.ServiceRequestResponse, error) {
+	args := r.Called(organizationId, requesterId)
+	return args.Get(0).([]*common.ServiceRequestResponse), args.Error(1)
+}
+
 func (r *MockServiceBroker) Remove(requestId string) error {
 	args := r.Called(requestId)
 	return args.Error(0)
 }
 
+func (r *MockServiceBroker) Rekey(requestId string, organizationId string, deviceId string, newDeviceId string) error {
+	args := r.Called(requestId, organizationId, deviceId, newDeviceId)
+	return args.Error(0)
+}
+
+func (r *MockServiceBroker) RekeyDevice(organizationId string, deviceId string, newDeviceId string) error {
+	args := r.Called(organizationId, deviceId, newDeviceId)
+	return args.Error(0)
+}
+
+func (r *MockServiceBroker) GetQuota(organizationId string, deviceId string, serviceName string, requesterOrganizationId string, requesterId string) (*common.ServiceQuotaStatus, error) {
+	args := r.Called(organizationId, deviceId, serviceName, requesterOrganizationId, requesterId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.ServiceQuotaStatus), args.Error(1)
+}
+
+func (r *MockServiceBroker) RequestGroup(request *common.GroupServiceRequest) ([]*common.ServiceRequest, error) {
+	args := r.Called(request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*common.ServiceRequest), args.Error(1)
+}
+
+func (r *MockServiceBroker) GetGroupRequest(requestId string) (*common.GroupServiceRequestResult, error) {
+	args := r.Called(requestId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.GroupServiceRequestResult), args.Error(1)
+}
+
+func (r *MockServiceBroker) Publish(message *common.StreamMessage) error {
+	args := r.Called(message)
+	return args.Error(0)
+}
+
+func (r *MockServiceBroker) Relay(channelId string, chaincodeId string, requestId string) error {
+	args := r.Called(channelId, chaincodeId, requestId)
+	return args.Error(0)
+}
+
+func (r *MockServiceBroker) Rate(requestId string, rating int) (*common.ServiceRequest, error) {
+	args := r.Called(requestId, rating)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.ServiceRequest), args.Error(1)
+}
+
 type ServiceBrokerTestSuite struct {
 	suite.Suite
 }
@@ -48,15 +118,24 @@ type ServiceBrokerTestSuite struct {
 func (s *ServiceBrokerTestSuite) TestRequest() {
 	requestRegistry := new(MockStateRegistry)
 	indexRegistry := new(MockStateRegistry)
+	requesterIndexRegistry := new(MockStateRegistry)
+	usageRegistry := new(MockStateRegistry)
 	serviceRegistry := new(MockServiceRegistry)
-	transactionContext := new(MockTransactionContext)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	accountLedger := new(MockAccountLedger)
+	deviceRegistry := new(MockDeviceRegistry)
+	transactionContext := &MockTransactionContext{Timestamp: now}
 
 	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.accountLedger = accountLedger
+	transactionContext.deviceRegistry = deviceRegistry
 
 	serviceBroker := new(ServiceBroker)
 	serviceBroker.ctx = transactionContext
 	serviceBroker.indexRegistry = indexRegistry
+	serviceBroker.requesterIndexRegistry = requesterIndexRegistry
 	serviceBroker.requestRegistry = requestRegistry
+	serviceBroker.usageRegistry = usageRegistry
 
 	request := &common.ServiceRequest{
 		Id: "request1",
@@ -65,23 +144,80 @@ func (s *ServiceBrokerTestSuite) TestRequest() {
 			DeviceId:       "device1",
 			Name:           "service1",
 		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
 	}
 
 	requestRegistry.On("GetState", []string{"request1"}).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("GetState", []string{"request4"}).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("GetState", []string{"request5"}).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("GetState", []string{"request6"}).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("GetState", []string{"request7"}).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("GetState", []string{"request8"}).Return(nil, new(common.NotFoundError))
 	requestRegistry.On("GetState", mock.Anything).Return(new(common.ServiceRequest), nil)
-	requestRegistry.On("PutState", request).Return(nil)
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
 	indexRegistry.On("PutState", mock.Anything).Return(nil)
-	serviceRegistry.On("Get", "org1", "device1", "service1").Return(new(common.Service), nil)
+	requesterIndexRegistry.On("PutState", mock.Anything).Return(nil)
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"}, nil)
+	serviceRegistry.On("Get", "org1", "device1", "service3").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service3", Acl: &common.ServiceAcl{
+		Attributes: common.AttributePolicy{"role": {"consumer"}},
+	}}, nil)
+	serviceRegistry.On("Get", "org1", "device1", "service2").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service2", Acl: &common.ServiceAcl{
+		ServiceAclRule: common.ServiceAclRule{OrganizationIds: []string{"org2"}},
+		Methods:        map[string]*common.ServiceAclRule{"SET": {OwnOrganization: true}},
+	}}, nil)
+	serviceRegistry.On("Get", "org1", "device1", "service4").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service4",
+		Quota: &common.ServiceQuota{Scope: common.ServiceQuotaPerClient, MaxRequests: 1, Window: 3600},
+	}, nil)
+	serviceRegistry.On("Get", "org1", "device1", "service5").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service5",
+		Quota: &common.ServiceQuota{Scope: common.ServiceQuotaPerOrganization, MaxPending: 1},
+	}, nil)
+	usageRegistry.On("GetState", []string{"org1", "device1", "service4", "org2", "device2"}).Return(nil, new(common.NotFoundError)).Once()
+	usageRegistry.On("GetState", []string{"org1", "device1", "service4", "org2", "device2"}).Return(&serviceUsage{
+		OrganizationId: "org1", DeviceId: "device1", ServiceName: "service4", RequesterOrganizationId: "org2", RequesterId: "device2",
+		WindowStart: now.Add(-time.Minute), Requests: 1, Pending: 1,
+	}, nil)
+	usageRegistry.On("GetState", []string{"org1", "device1", "service5", "org2"}).Return(&serviceUsage{Pending: 1}, nil)
+	usageRegistry.On("PutState", mock.Anything).Return(nil)
+	stats := &common.ServiceStats{Requests: 1}
+	serviceRegistry.On("AddStats", mock.Anything).Run(func(args mock.Arguments) { stats.Add(args.Get(0).(*common.ServiceStats)) }).Return(nil)
+	serviceRegistry.On("Get", "org1", "device1", "service6").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service6", Price: 5}, nil)
+	accountLedger.On("Escrow", mock.MatchedBy(func(r *common.ServiceRequest) bool { return r.Id == "request7" })).Return(nil)
+	accountLedger.On("Escrow", mock.Anything).Return(fmt.Errorf("insufficient balance"))
+	serviceRegistry.On("Get", "org1", "device9", "service1").Return(&common.Service{OrganizationId: "org1", DeviceId: "device9", Name: "service1"}, nil)
 	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	deviceRegistry.On("IsRevoked", "org1", "device9", "").Return(true, nil)
+	deviceRegistry.On("IsRevoked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
 
 	err := serviceBroker.Request(request)
 	called := requestRegistry.AssertCalled(s.T(), "PutState", request)
 	assert.True(s.T(), called, "should put request to state registry")
 	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.ServiceRequestPending, request.Status, "should set request status to pending")
+	called = serviceRegistry.AssertCalled(s.T(), "AddStats", &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 1})
+	assert.True(s.T(), called, "should update service stats")
+	assert.Equal(s.T(), int64(2), stats.Requests, "should count the request")
 	called = indexRegistry.AssertCalled(s.T(), "PutState", mock.Anything)
 	assert.True(s.T(), called, "should put index to state registry")
 	index := indexRegistry.Calls[0].Arguments[0].(*serviceRequestIndex)
 	assert.Equal(s.T(), request.Id, index.RequestId, "should set request ID of the index")
+	called = requesterIndexRegistry.AssertCalled(s.T(), "PutState", mock.Anything)
+	assert.True(s.T(), called, "should put requester index to state registry")
+	requesterIndex := requesterIndexRegistry.Calls[0].Arguments[0].(*serviceRequesterIndex)
+	assert.Equal(s.T(), []string{"org2", "device2", request.Id}, requesterIndex.GetKeyComponents(), "should set key of the requester index")
+
+	request = &common.ServiceRequest{
+		Id: "request1",
+		Service: common.Service{
+			OrganizationId: "org1",
+			DeviceId:       "device1",
+			Name:           "service1",
+		},
+	}
+	err = serviceBroker.Request(request)
+	notCalled := requestRegistry.AssertNotCalled(s.T(), "PutState", request)
+	assert.True(s.T(), notCalled, "should not put request to state registry")
+	assert.EqualError(s.T(), err, "missing requester in request definition", "should return missing requester error")
 
 	request = &common.ServiceRequest{
 		Id: "request1",
@@ -90,9 +226,11 @@ func (s *ServiceBrokerTestSuite) TestRequest() {
 			DeviceId:       "device2",
 			Name:           "service2",
 		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
 	}
 	err = serviceBroker.Request(request)
-	notCalled := requestRegistry.AssertNotCalled(s.T(), "PutState", request)
+	notCalled = requestRegistry.AssertNotCalled(s.T(), "PutState", request)
 	assert.True(s.T(), notCalled, "should not put request to state registry")
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return service not found error")
 
@@ -103,41 +241,440 @@ func (s *ServiceBrokerTestSuite) TestRequest() {
 			DeviceId:       "device1",
 			Name:           "service1",
 		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
 	}
 	err = serviceBroker.Request(request)
 	notCalled = requestRegistry.AssertNotCalled(s.T(), "PutState", request)
 	assert.True(s.T(), notCalled, "should not put request to state registry")
 	assert.EqualError(s.T(), err, "request already exists", "should return request already exists error")
+
+	request = &common.ServiceRequest{
+		Id: "request3",
+		Service: common.Service{
+			OrganizationId: "org1",
+			DeviceId:       "device1",
+			Name:           "service2",
+		},
+		Method:                  "SET",
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	err = serviceBroker.Request(request)
+	notCalled = requestRegistry.AssertNotCalled(s.T(), "PutState", request)
+	assert.True(s.T(), notCalled, "should not put request to state registry")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should return access denied error")
+
+	request = &common.ServiceRequest{
+		Id: "request4",
+		Service: common.Service{
+			OrganizationId: "org1",
+			DeviceId:       "device1",
+			Name:           "service3",
+		},
+		Method:                  "GET",
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	err = serviceBroker.Request(request)
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should return access denied error for missing attributes")
+
+	transactionContext.identity = &mockClientIdentity{Attributes: map[string]string{"role": "consumer"}}
+	err = serviceBroker.Request(request)
+	assert.Nil(s.T(), err, "should return no error")
+
+	request = &common.ServiceRequest{
+		Id: "request5",
+		Service: common.Service{
+			OrganizationId: "org1",
+			DeviceId:       "device1",
+			Name:           "service4",
+		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	err = serviceBroker.Request(request)
+	assert.Nil(s.T(), err, "should return no error")
+	usage := usageRegistry.Calls[1].Arguments[0].(*serviceUsage)
+	assert.Equal(s.T(), []string{"org1", "device1", "service4", "org2", "device2"}, usage.GetKeyComponents(), "should count usage per client")
+	assert.Equal(s.T(), 1, usage.Requests, "should start a new window")
+	assert.True(s.T(), now.Equal(usage.WindowStart), "should start a new window at the transaction time")
+
+	request.Id = "request6"
+	err = serviceBroker.Request(request)
+	assert.IsType(s.T(), new(common.LimitExceededError), err, "should return limit exceeded error for too many requests")
+
+	request.Service.Name = "service5"
+	err = serviceBroker.Request(request)
+	assert.IsType(s.T(), new(common.LimitExceededError), err, "should return limit exceeded error for too many pending requests")
+	usageRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+	accountLedger.AssertNotCalled(s.T(), "Escrow", mock.Anything)
+
+	request = &common.ServiceRequest{
+		Id: "request7",
+		Service: common.Service{
+			OrganizationId: "org1",
+			DeviceId:       "device1",
+			Name:           "service6",
+		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+		Price:                   1,
+	}
+	err = serviceBroker.Request(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(5), request.Price, "should charge the price of the service")
+	called = accountLedger.AssertCalled(s.T(), "Escrow", request)
+	assert.True(s.T(), called, "should escrow the price of the service")
+
+	request.Id = "request8"
+	err = serviceBroker.Request(request)
+	assert.EqualError(s.T(), err, "insufficient balance", "should return insufficient balance error")
+
+	request = &common.ServiceRequest{
+		Id: "request9",
+		Service: common.Service{
+			OrganizationId: "org1",
+			DeviceId:       "device9",
+			Name:           "service1",
+		},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	err = serviceBroker.Request(request)
+	notCalled = requestRegistry.AssertNotCalled(s.T(), "PutState", request)
+	assert.True(s.T(), notCalled, "should not put request to state registry")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should return access denied error for revoked device")
+}
+
+func (s *ServiceBrokerTestSuite) TestRequestRemote() {
+	requestRegistry := new(MockStateRegistry)
+	indexRegistry := new(MockStateRegistry)
+	requesterIndexRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	accountLedger := new(MockAccountLedger)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	transactionContext := &MockTransactionContext{Timestamp: now}
+
+	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.deviceRegistry = deviceRegistry
+	transactionContext.accountLedger = accountLedger
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.indexRegistry = indexRegistry
+	serviceBroker.requesterIndexRegistry = requesterIndexRegistry
+	serviceBroker.requestRegistry = requestRegistry
+
+	service, _ := (&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1", Price: 5}).Serialize()
+	limited, _ := (&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1",
+		Quota: &common.ServiceQuota{Scope: common.ServiceQuotaPerClient, MaxPending: 1},
+	}).Serialize()
+	revocations, _ := json.Marshal([]*common.DeviceRevocation{
+		{OrganizationId: "org1", DeviceId: "device1", SerialNumber: "1a"},
+		{OrganizationId: "org1", DeviceId: "device9"},
+	})
+	revoked, _ := json.Marshal([]*common.DeviceRevocation{{OrganizationId: "org1", DeviceId: "device1"}})
+	stub := &mockChaincodeStub{Chaincodes: map[string]peer.Response{
+		"channel2/iotservice":                                shim.Success(service),
+		"channel2/iotservice/device_registry:GetRevocations": shim.Success(revocations),
+		"channel3/iotservice":                                shim.Success([]byte("{\"organizationId\":\"org9\",\"deviceId\":\"device1\",\"name\":\"service1\"}")),
+		"channel5/iotservice":                                shim.Success(service),
+		"channel5/iotservice/device_registry:GetRevocations": shim.Success(revoked),
+		"channel6/iotservice":                                shim.Success(limited),
+		"channel6/iotservice/device_registry:GetRevocations": shim.Success(nil),
+	}}
+	transactionContext.stub = stub
+
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	indexRegistry.On("PutState", mock.Anything).Return(nil)
+	requesterIndexRegistry.On("PutState", mock.Anything).Return(nil)
+	accountLedger.On("Escrow", mock.Anything).Return(nil)
+
+	request := &common.ServiceRequest{
+		Id:                      "request1",
+		Service:                 common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+		ChannelId:               "channel2",
+		ChaincodeId:             "iotservice",
+	}
+	err := serviceBroker.Request(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), [][]byte{[]byte("service_registry:Get"), []byte("org1"), []byte("device1"), []byte("service1")}, stub.Invocations[0], "should look up the service in the remote service registry")
+	assert.Equal(s.T(), [][]byte{[]byte("device_registry:GetRevocations"), []byte("org1")}, stub.Invocations[1], "should look up the revocations in the remote device registry")
+	requestRegistry.AssertCalled(s.T(), "PutState", request)
+	assert.Equal(s.T(), int64(5), request.Price, "should charge the price of the remote service")
+	accountLedger.AssertCalled(s.T(), "Escrow", request)
+	serviceRegistry.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything, mock.Anything)
+	serviceRegistry.AssertNotCalled(s.T(), "AddStats", mock.Anything)
+	deviceRegistry.AssertNotCalled(s.T(), "IsRevoked", mock.Anything, mock.Anything, mock.Anything)
+
+	request.Id, request.ChannelId = "request2", "channel3"
+	err = serviceBroker.Request(request)
+	assert.Error(s.T(), err, "should refuse a service other than the requested one")
+
+	request.Id, request.ChannelId = "request3", "channel4"
+	err = serviceBroker.Request(request)
+	assert.Error(s.T(), err, "should return error if the remote lookup fails")
+	assert.Regexp(s.T(), "channel4", err.Error())
+
+	request.Id, request.ChannelId = "request4", "channel5"
+	err = serviceBroker.Request(request)
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse devices revoked in the remote device registry")
+
+	request.Id, request.ChannelId = "request5", "channel6"
+	err = serviceBroker.Request(request)
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse remote services with a quota")
+	requestRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *ServiceBrokerTestSuite) TestRespondRemote() {
+	requestRegistry := new(MockStateRegistry)
+	responseRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	accountLedger := new(MockAccountLedger)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.accountLedger = accountLedger
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.requestRegistry = requestRegistry
+	serviceBroker.responseRegistry = responseRegistry
+
+	revoked, _ := json.Marshal([]*common.DeviceRevocation{{OrganizationId: "org1", DeviceId: "device1"}})
+	stub := &mockChaincodeStub{Chaincodes: map[string]peer.Response{
+		"channel2/iotservice/device_registry:GetRevocations": shim.Success([]byte("[]")),
+		"channel5/iotservice/device_registry:GetRevocations": shim.Success(revoked),
+	}}
+	transactionContext.stub = stub
+
+	requestTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	request1 := &common.ServiceRequest{
+		Id:          "request1",
+		Time:        requestTime,
+		Service:     common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"},
+		ChannelId:   "channel2",
+		ChaincodeId: "iotservice",
+	}
+	request2 := &common.ServiceRequest{
+		Id:          "request2",
+		Time:        requestTime,
+		Service:     common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"},
+		ChannelId:   "channel5",
+		ChaincodeId: "iotservice",
+	}
+	requestRegistry.On("GetState", []string{"request1"}).Return(request1, nil)
+	requestRegistry.On("GetState", []string{"request2"}).Return(request2, nil)
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	responseRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	responseRegistry.On("PutState", mock.Anything).Return(nil)
+
+	err := serviceBroker.Respond(&common.ServiceResponse{RequestId: "request1", Time: requestTime.Add(time.Second)})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.ServiceRequestCompleted, request1.Status, "should complete the request")
+	serviceRegistry.AssertNotCalled(s.T(), "AddStats", mock.Anything)
+
+	err = serviceBroker.Respond(&common.ServiceResponse{RequestId: "request2", Time: requestTime.Add(time.Second)})
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse responses of devices revoked in the remote device registry")
+	responseRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *ServiceBrokerTestSuite) TestRelay() {
+	relayRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceRegistry = serviceRegistry
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.relayRegistry = relayRegistry
+
+	requestTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	service := common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"}
+	pending, _ := json.Marshal(&common.ServiceRequestResponse{
+		Request: &common.ServiceRequest{Id: "request1", Time: requestTime, Service: service, ChannelId: "channel1", ChaincodeId: "iotservice"},
+	})
+	rated, _ := json.Marshal(&common.ServiceRequestResponse{
+		Request:  &common.ServiceRequest{Id: "request2", Time: requestTime, Service: service, ChannelId: "channel1", ChaincodeId: "iotservice", Status: common.ServiceRequestCompleted, Rating: 4},
+		Response: &common.ServiceResponse{RequestId: "request2", Time: requestTime.Add(2 * time.Second)},
+	})
+	local, _ := json.Marshal(&common.ServiceRequestResponse{Request: &common.ServiceRequest{Id: "request3", Service: service}})
+	other, _ := json.Marshal(&common.ServiceRequestResponse{
+		Request: &common.ServiceRequest{Id: "request4", Service: service, ChannelId: "channel3", ChaincodeId: "iotservice"},
+	})
+	forged, _ := json.Marshal(&common.ServiceRequestResponse{
+		Request: &common.ServiceRequest{Id: "request5", Time: requestTime, Service: service, ChannelId: "channel1", ChaincodeId: "iotservice", Status: common.ServiceRequestPending, Rating: 5},
+	})
+	foreign, _ := json.Marshal(&common.ServiceRequestResponse{
+		Request: &common.ServiceRequest{Id: "request6", Time: requestTime, Service: service, ChannelId: "channel1", ChaincodeId: "othercc"},
+	})
+	stub := &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{ChannelID: "channel1"}, ChaincodeId: "iotservice", Chaincodes: map[string]peer.Response{
+		"channel2/iotservice1": shim.Success(pending),
+		"channel2/iotservice2": shim.Success(rated),
+		"channel2/iotservice3": shim.Success(local),
+		"channel2/iotservice4": shim.Success(other),
+		"channel2/iotservice5": shim.Success(forged),
+		"channel2/iotservice6": shim.Success(foreign),
+	}}
+	transactionContext.stub = stub
+
+	stats := &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1"}
+	serviceRegistry.On("AddStats", mock.Anything).Run(func(args mock.Arguments) { stats.Add(args.Get(0).(*common.ServiceStats)) }).Return(nil)
+	relayRegistry.On("GetState", []string{"channel2", "iotservice1", "request1"}).Return(nil, new(common.NotFoundError)).Once()
+	relayRegistry.On("GetState", []string{"channel2", "iotservice1", "request1"}).Return(&serviceRequestRelay{ChannelId: "channel2", ChaincodeId: "iotservice1", RequestId: "request1"}, nil)
+	relayRegistry.On("GetState", []string{"channel2", "iotservice2", "request2"}).Return(&serviceRequestRelay{ChannelId: "channel2", ChaincodeId: "iotservice2", RequestId: "request2"}, nil)
+	relayRegistry.On("GetState", []string{"channel2", "iotservice5", "request5"}).Return(&serviceRequestRelay{ChannelId: "channel2", ChaincodeId: "iotservice5", RequestId: "request5"}, nil)
+	relayRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	relayRegistry.On("PutState", mock.Anything).Return(nil)
+
+	err := serviceBroker.Relay("channel2", "iotservice1", "request1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), [][]byte{[]byte("service_broker:Get"), []byte("request1")}, stub.Invocations[0], "should read the request from the remote service broker")
+	assert.Equal(s.T(), int64(1), stats.Requests, "should count the relayed request")
+	relayRegistry.AssertCalled(s.T(), "PutState", &serviceRequestRelay{ChannelId: "channel2", ChaincodeId: "iotservice1", RequestId: "request1"})
+
+	err = serviceBroker.Relay("channel2", "iotservice1", "request1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(1), stats.Requests, "should count each request once")
+	serviceRegistry.AssertNumberOfCalls(s.T(), "AddStats", 1)
+
+	err = serviceBroker.Relay("channel2", "iotservice2", "request2")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(1), stats.Responses, "should count the relayed response")
+	assert.Equal(s.T(), int64(0), stats.ErrorResponses, "should not count a successful relayed response as error")
+	assert.Equal(s.T(), int64(2000), stats.TotalLatency, "should count the latency of the relayed response")
+	assert.Equal(s.T(), int64(4), stats.TotalRating, "should count the relayed rating")
+	relayRegistry.AssertCalled(s.T(), "PutState", &serviceRequestRelay{ChannelId: "channel2", ChaincodeId: "iotservice2", RequestId: "request2", Responded: true, Rated: true})
+
+	err = serviceBroker.Relay("channel2", "iotservice5", "request5")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(1), stats.Ratings, "should not count the rating of a request not completed")
+	assert.Equal(s.T(), int64(4), stats.TotalRating, "should not count the rating of a request not completed")
+
+	err = serviceBroker.Relay("channel2", "iotservice6", "request6")
+	assert.Error(s.T(), err, "should refuse requests to services of another chaincode")
+	relayRegistry.AssertNotCalled(s.T(), "GetState", []string{"channel2", "iotservice6", "request6"})
+
+	err = serviceBroker.Relay("channel2", "iotservice3", "request3")
+	assert.Error(s.T(), err, "should refuse requests to services of the remote chaincode")
+
+	err = serviceBroker.Relay("channel2", "iotservice4", "request4")
+	assert.Error(s.T(), err, "should refuse requests to services of another channel")
+
+	err = serviceBroker.Relay("channel2", "iotservice1", "request9")
+	assert.Error(s.T(), err, "should refuse requests other than the relayed one")
+
+	err = serviceBroker.Relay("channel2", "", "request1")
+	assert.Error(s.T(), err, "should refuse relays without chaincode")
+	serviceRegistry.AssertNumberOfCalls(s.T(), "AddStats", 2)
 }
 
 func (s *ServiceBrokerTestSuite) TestRespond() {
 	requestRegistry := new(MockStateRegistry)
 	responseRegistry := new(MockStateRegistry)
+	usageRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
 	transactionContext := new(MockTransactionContext)
 
+	transactionContext.serviceRegistry = serviceRegistry
+
 	serviceBroker := new(ServiceBroker)
 	serviceBroker.ctx = transactionContext
 	serviceBroker.requestRegistry = requestRegistry
 	serviceBroker.responseRegistry = responseRegistry
+	serviceBroker.usageRegistry = usageRegistry
+
+	accountLedger := new(MockAccountLedger)
+	transactionContext.accountLedger = accountLedger
+	accountLedger.On("Release", mock.Anything).Return(nil)
+	accountLedger.On("Refund", mock.Anything).Return(nil)
 
-	response := &common.ServiceResponse{RequestId: "request1"}
+	requestTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	response := &common.ServiceResponse{RequestId: "request1", Time: requestTime.Add(2 * time.Second)}
+	request1 := &common.ServiceRequest{
+		Time:                    requestTime,
+		Service:                 common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+		Price:                   5,
+	}
+	usage := &serviceUsage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", RequesterOrganizationId: "org2", Pending: 2}
+	stats := &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 3}
+	request4 := &common.ServiceRequest{
+		Status:                  common.ServiceRequestInProgress,
+		Service:                 common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+		Price:                   5,
+	}
+	request5 := &common.ServiceRequest{Status: common.ServiceRequestCancelled}
 
-	requestRegistry.On("GetState", []string{"request1"}).Return(new(common.ServiceRequest), nil)
+	requestRegistry.On("GetState", []string{"request1"}).Return(request1, nil)
 	requestRegistry.On("GetState", []string{"request2"}).Return(new(common.ServiceRequest), nil)
+	requestRegistry.On("GetState", []string{"request4"}).Return(request4, nil)
+	requestRegistry.On("GetState", []string{"request5"}).Return(request5, nil)
 	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
-	responseRegistry.On("GetState", []string{"request1"}).Return(nil, new(common.NotFoundError))
-	responseRegistry.On("GetState", mock.Anything).Return(new(common.ServiceResponse), nil)
-	responseRegistry.On("PutState", response).Return(nil)
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	responseRegistry.On("GetState", []string{"request2"}).Return(new(common.ServiceResponse), nil)
+	responseRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	responseRegistry.On("PutState", mock.Anything).Return(nil)
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(&common.Service{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		Name:           "service1",
+		Quota:          &common.ServiceQuota{Scope: common.ServiceQuotaPerOrganization, MaxPending: 2},
+	}, nil)
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	serviceRegistry.On("AddStats", mock.MatchedBy(func(delta *common.ServiceStats) bool { return delta.ServiceName == "service1" })).Run(func(args mock.Arguments) { stats.Add(args.Get(0).(*common.ServiceStats)) }).Return(nil)
+	serviceRegistry.On("AddStats", mock.Anything).Return(new(common.NotFoundError))
+	usageRegistry.On("GetState", []string{"org1", "device1", "service1", "org2"}).Return(usage, nil)
+	usageRegistry.On("PutState", mock.Anything).Return(nil)
 
-	err := serviceBroker.Respond(response)
+	early := &common.ServiceResponse{RequestId: "request1", Time: requestTime.Add(-time.Second)}
+	err := serviceBroker.Respond(early)
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse response made before its request")
+	responseRegistry.AssertNotCalled(s.T(), "PutState", early)
+
+	err = serviceBroker.Respond(response)
 	called := responseRegistry.AssertCalled(s.T(), "PutState", response)
 	assert.True(s.T(), called, "should put response to state registry")
 	assert.Nil(s.T(), err, "should return no error")
+	called = requestRegistry.AssertCalled(s.T(), "PutState", request1)
+	assert.True(s.T(), called, "should put request to state registry")
+	assert.Equal(s.T(), common.ServiceRequestCompleted, request1.Status, "should complete the request")
+	called = usageRegistry.AssertCalled(s.T(), "PutState", usage)
+	assert.True(s.T(), called, "should put usage to state registry")
+	assert.Equal(s.T(), 1, usage.Pending, "should release the pending slot of the request")
+	called = accountLedger.AssertCalled(s.T(), "Release", request1)
+	assert.True(s.T(), called, "should pay the escrowed price to the device organization")
+	assert.Equal(s.T(), int64(1), stats.Responses, "should count the response")
+	assert.Equal(s.T(), int64(0), stats.ErrorResponses, "should not count a successful response as error")
+	assert.Equal(s.T(), int64(2000), stats.TotalLatency, "should add the latency of the response")
 
-	response = &common.ServiceResponse{RequestId: "request2"}
+	response = &common.ServiceResponse{RequestId: "request4", StatusCode: 500}
+	err = serviceBroker.Respond(response)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.ServiceRequestFailed, request4.Status, "should fail the request")
+	called = accountLedger.AssertCalled(s.T(), "Refund", request4)
+	assert.True(s.T(), called, "should refund the escrowed price to the requester")
+	assert.Equal(s.T(), int64(1), stats.ErrorResponses, "should count the error response")
+
+	response = &common.ServiceResponse{RequestId: "request5"}
 	err = serviceBroker.Respond(response)
 	notCalled := responseRegistry.AssertNotCalled(s.T(), "PutState", response)
 	assert.True(s.T(), notCalled, "should not put response to state registry")
+	assert.Error(s.T(), err, "should refuse to respond to a cancelled request")
+
+	response = &common.ServiceResponse{RequestId: "request2"}
+	err = serviceBroker.Respond(response)
+	notCalled = responseRegistry.AssertNotCalled(s.T(), "PutState", response)
+	assert.True(s.T(), notCalled, "should not put response to state registry")
 	assert.EqualError(s.T(), err, "response already exists", "should return response already exists error")
 
 	response = &common.ServiceResponse{RequestId: "request3"}
@@ -147,6 +684,71 @@ func (s *ServiceBrokerTestSuite) TestRespond() {
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return request not found error")
 }
 
+func (s *ServiceBrokerTestSuite) TestTransition() {
+	requestRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	accountLedger := new(MockAccountLedger)
+
+	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.accountLedger = accountLedger
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(new(common.Service), nil)
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.requestRegistry = requestRegistry
+
+	request1 := &common.ServiceRequest{Id: "request1", Status: common.ServiceRequestPending}
+	request2 := &common.ServiceRequest{Id: "request2", Status: common.ServiceRequestCompleted}
+	requestTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	request4 := &common.ServiceRequest{Id: "request4", Time: requestTime}
+	request5 := &common.ServiceRequest{Id: "request5", Time: requestTime, Timeout: 60, Price: 5}
+	transactionContext.Timestamp = requestTime.Add(30 * time.Second)
+
+	requestRegistry.On("GetState", []string{"request1"}).Return(request1, nil)
+	requestRegistry.On("GetState", []string{"request2"}).Return(request2, nil)
+	requestRegistry.On("GetState", []string{"request4"}).Return(request4, nil)
+	requestRegistry.On("GetState", []string{"request5"}).Return(request5, nil)
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	accountLedger.On("Refund", mock.Anything).Return(nil)
+
+	result, err := serviceBroker.Transition("request1", common.ServiceRequestAccepted)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.ServiceRequestAccepted, result.Status, "should change request status")
+	called := requestRegistry.AssertCalled(s.T(), "PutState", request1)
+	assert.True(s.T(), called, "should put request to state registry")
+
+	_, err = serviceBroker.Transition("request1", common.ServiceRequestRejected)
+	assert.Error(s.T(), err, "should refuse to reject an accepted request")
+	serviceRegistry.AssertNotCalled(s.T(), "Get", mock.Anything, mock.Anything, mock.Anything)
+	accountLedger.AssertNotCalled(s.T(), "Refund", mock.Anything)
+
+	_, err = serviceBroker.Transition("request1", common.ServiceRequestCompleted)
+	assert.Error(s.T(), err, "should refuse to complete a request without response")
+
+	_, err = serviceBroker.Transition("request2", common.ServiceRequestCancelled)
+	assert.Error(s.T(), err, "should refuse to cancel a completed request")
+	requestRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+
+	_, err = serviceBroker.Transition("request3", common.ServiceRequestCancelled)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	_, err = serviceBroker.Transition("request4", common.ServiceRequestExpired)
+	assert.Error(s.T(), err, "should refuse to expire a request without timeout")
+
+	_, err = serviceBroker.Transition("request5", common.ServiceRequestExpired)
+	assert.Error(s.T(), err, "should refuse to expire a request before its timeout")
+
+	transactionContext.Timestamp = requestTime.Add(2 * time.Minute)
+	result, err = serviceBroker.Transition("request5", common.ServiceRequestExpired)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.ServiceRequestExpired, result.Status, "should expire the request")
+	called = accountLedger.AssertCalled(s.T(), "Refund", request5)
+	assert.True(s.T(), called, "should refund the escrowed price to the requester")
+}
+
 func (s *ServiceBrokerTestSuite) TestGet() {
 	requestRegistry := new(MockStateRegistry)
 	responseRegistry := new(MockStateRegistry)
@@ -222,19 +824,66 @@ func (s *ServiceBrokerTestSuite) TestGetAll() {
 	assert.Nil(s.T(), err, "should return no error")
 }
 
+func (s *ServiceBrokerTestSuite) TestGetAllByRequester() {
+	requesterIndexRegistry := new(MockStateRegistry)
+	requestRegistry := new(MockStateRegistry)
+	responseRegistry := new(MockStateRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.requesterIndexRegistry = requesterIndexRegistry
+	serviceBroker.requestRegistry = requestRegistry
+	serviceBroker.responseRegistry = responseRegistry
+
+	indices := []StateInterface{
+		&serviceRequesterIndex{RequestId: "request1"},
+		&serviceRequesterIndex{RequestId: "request2"},
+	}
+	request1 := new(common.ServiceRequest)
+	request2 := new(common.ServiceRequest)
+	response1 := new(common.ServiceResponse)
+
+	requesterIndexRegistry.On("GetStates", []string{"org1", "device1"}).Return(indices, nil)
+	requesterIndexRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+	requestRegistry.On("GetState", []string{"request1"}).Return(request1, nil)
+	requestRegistry.On("GetState", []string{"request2"}).Return(request2, nil)
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	responseRegistry.On("GetState", []string{"request1"}).Return(response1, nil)
+	responseRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	results, err := serviceBroker.GetAllByRequester("org1", "device1")
+	assert.Equal(s.T(), len(indices), len(results), "should return the correct number of requests/responses")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), request1, results[0].Request, "should return the correct request")
+	assert.Equal(s.T(), response1, results[0].Response, "should return the correct response")
+	assert.Equal(s.T(), request2, results[1].Request, "should return the correct request")
+	assert.Nil(s.T(), results[1].Response, "should return the correct response")
+
+	results, err = serviceBroker.GetAllByRequester("org2", "device2")
+	assert.Zero(s.T(), len(results), "should return no request")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
 func (s *ServiceBrokerTestSuite) TestRemove() {
 	indexRegistry := new(MockStateRegistry)
+	requesterIndexRegistry := new(MockStateRegistry)
 	requestRegistry := new(MockStateRegistry)
 	responseRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
 	transactionContext := new(MockTransactionContext)
 
+	transactionContext.serviceRegistry = serviceRegistry
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+
 	serviceBroker := new(ServiceBroker)
 	serviceBroker.ctx = transactionContext
 	serviceBroker.indexRegistry = indexRegistry
+	serviceBroker.requesterIndexRegistry = requesterIndexRegistry
 	serviceBroker.requestRegistry = requestRegistry
 	serviceBroker.responseRegistry = responseRegistry
 
-	request1 := new(common.ServiceRequest)
+	request1 := &common.ServiceRequest{RequesterOrganizationId: "org2", RequesterId: "device2"}
 	request2 := new(common.ServiceRequest)
 	response1 := new(common.ServiceResponse)
 
@@ -246,6 +895,7 @@ func (s *ServiceBrokerTestSuite) TestRemove() {
 	requestRegistry.On("RemoveState", mock.Anything).Return(nil)
 	responseRegistry.On("RemoveState", mock.Anything).Return(nil)
 	indexRegistry.On("RemoveState", mock.Anything).Return(nil)
+	requesterIndexRegistry.On("RemoveState", mock.Anything).Return(nil)
 
 	err := serviceBroker.Remove("request1")
 	called := requestRegistry.AssertCalled(s.T(), "RemoveState", request1)
@@ -256,6 +906,8 @@ func (s *ServiceBrokerTestSuite) TestRemove() {
 	assert.True(s.T(), called, "should remove index from state registry")
 	index := indexRegistry.Calls[0].Arguments[0].(*serviceRequestIndex)
 	assert.Equal(s.T(), request1.Id, index.RequestId, "should remove correct index from state registry")
+	called = requesterIndexRegistry.AssertCalled(s.T(), "RemoveState", mock.Anything)
+	assert.True(s.T(), called, "should remove requester index from state registry")
 	assert.Nil(s.T(), err, "should return no error")
 
 	err = serviceBroker.Remove("request2")
@@ -267,6 +919,8 @@ func (s *ServiceBrokerTestSuite) TestRemove() {
 	assert.True(s.T(), called, "should remove index from state registry")
 	index = indexRegistry.Calls[1].Arguments[0].(*serviceRequestIndex)
 	assert.Equal(s.T(), request2.Id, index.RequestId, "should remove correct index from state registry")
+	notCalled = requesterIndexRegistry.AssertNumberOfCalls(s.T(), "RemoveState", 1)
+	assert.True(s.T(), notCalled, "should not remove missing requester index from state registry")
 	assert.Nil(s.T(), err, "should return no error")
 
 	err = serviceBroker.Remove("request3")
@@ -279,6 +933,384 @@ func (s *ServiceBrokerTestSuite) TestRemove() {
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
 }
 
+func (s *ServiceBrokerTestSuite) TestRekey() {
+	indexRegistry := new(MockStateRegistry)
+	requesterIndexRegistry := new(MockStateRegistry)
+	requestRegistry := new(MockStateRegistry)
+	usageRegistry := new(MockStateRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.indexRegistry = indexRegistry
+	serviceBroker.requesterIndexRegistry = requesterIndexRegistry
+	serviceBroker.requestRegistry = requestRegistry
+	serviceBroker.usageRegistry = usageRegistry
+
+	request1 := &common.ServiceRequest{
+		Id:                      "request1",
+		Service:                 common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+	request2 := &common.ServiceRequest{
+		Id:                      "request2",
+		Service:                 common.Service{Name: "service3", DeviceId: "device3", OrganizationId: "org3"},
+		RequesterOrganizationId: "org1",
+		RequesterId:             "device1",
+	}
+
+	request3 := &common.ServiceRequest{
+		Id:                      "request3",
+		Service:                 common.Service{Name: "service3", DeviceId: "device3", OrganizationId: "org3"},
+		RequesterOrganizationId: "org1",
+		RequesterId:             "device1",
+	}
+	usage := &serviceUsage{OrganizationId: "org3", DeviceId: "device3", ServiceName: "service3", RequesterOrganizationId: "org1", RequesterId: "device1", Requests: 2, Pending: 2}
+
+	requestRegistry.On("GetState", []string{"request1"}).Return(request1, nil)
+	requestRegistry.On("GetState", []string{"request2"}).Return(request2, nil)
+	requestRegistry.On("GetState", []string{"request3"}).Return(request3, nil)
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	usageRegistry.On("GetState", []string{"org3", "device3", "service3", "org1", "device1"}).Return(usage, nil)
+	usageRegistry.On("RemoveState", mock.Anything).Return(nil)
+	usageRegistry.On("PutState", mock.Anything).Return(nil)
+	indexRegistry.On("RemoveState", mock.Anything).Return(nil)
+	indexRegistry.On("PutState", mock.Anything).Return(nil)
+	requesterIndexRegistry.On("RemoveState", mock.Anything).Return(nil)
+	requesterIndexRegistry.On("PutState", mock.Anything).Return(nil)
+
+	err := serviceBroker.Rekey("request1", "org1", "device1", "device9")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device9", request1.Service.DeviceId, "should change requested device ID")
+	assert.Equal(s.T(), "device2", request1.RequesterId, "should not change requester ID")
+	index := indexRegistry.Calls[1].Arguments[0].(*serviceRequestIndex)
+	assert.Equal(s.T(), []string{"org1", "device9", "service1", "request1"}, index.GetKeyComponents(), "should re-index the request")
+	requesterIndexRegistry.AssertNotCalled(s.T(), "PutState", mock.Anything)
+
+	err = serviceBroker.Rekey("request2", "org1", "device1", "device9")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device3", request2.Service.DeviceId, "should not change requested device ID")
+	assert.Equal(s.T(), "device9", request2.RequesterId, "should change requester ID")
+	requesterIndex := requesterIndexRegistry.Calls[1].Arguments[0].(*serviceRequesterIndex)
+	assert.Equal(s.T(), []string{"org1", "device9", "request2"}, requesterIndex.GetKeyComponents(), "should re-index the request by requester")
+	usageRegistry.AssertCalled(s.T(), "RemoveState", usage)
+	assert.Equal(s.T(), []string{"org3", "device3", "service3", "org1", "device9"}, usage.GetKeyComponents(), "should move the quota usage of the requester")
+	assert.Equal(s.T(), 2, usage.Pending, "should keep the quota usage of the requester")
+
+	err = serviceBroker.Rekey("request3", "org1", "device1", "device9")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Len(s.T(), usageRegistry.Calls, 3, "should move the quota usage shared by requests only once")
+
+	err = serviceBroker.Rekey("request4", "org1", "device1", "device9")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *ServiceBrokerTestSuite) TestRekeyDevice() {
+	streamHeadRegistry := new(MockStateRegistry)
+	usageRegistry := new(MockStateRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.streamHeadRegistry = streamHeadRegistry
+	serviceBroker.usageRegistry = usageRegistry
+
+	head := &serviceStreamHead{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Sequence: 5}
+	usage := &serviceUsage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", RequesterOrganizationId: "org2", Requests: 3}
+
+	streamHeadRegistry.On("GetStates", []string{"org1", "device1"}).Return([]StateInterface{head}, nil)
+	streamHeadRegistry.On("RemoveState", mock.Anything).Return(nil)
+	streamHeadRegistry.On("PutState", mock.Anything).Return(nil)
+	usageRegistry.On("GetStates", []string{"org1", "device1"}).Return([]StateInterface{usage}, nil)
+	usageRegistry.On("RemoveState", mock.Anything).Return(nil)
+	usageRegistry.On("PutState", mock.Anything).Return(nil)
+
+	err := serviceBroker.RekeyDevice("org1", "device1", "device9")
+	assert.Nil(s.T(), err, "should return no error")
+	streamHeadRegistry.AssertCalled(s.T(), "RemoveState", head)
+	assert.Equal(s.T(), []string{"org1", "device9", "service1", "temperature"}, head.GetKeyComponents(), "should move the stream heads")
+	assert.Equal(s.T(), int64(5), head.Sequence, "should keep the stream sequence")
+	usageRegistry.AssertCalled(s.T(), "RemoveState", usage)
+	assert.Equal(s.T(), []string{"org1", "device9", "service1", "org2"}, usage.GetKeyComponents(), "should move the quota usages")
+	assert.Equal(s.T(), 3, usage.Requests, "should keep the quota usages")
+}
+
+func (s *ServiceBrokerTestSuite) TestRate() {
+	requestRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceRegistry = serviceRegistry
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.requestRegistry = requestRegistry
+
+	service := common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"}
+	request1 := &common.ServiceRequest{Id: "request1", Service: service, Status: common.ServiceRequestCompleted}
+	request2 := &common.ServiceRequest{Id: "request2", Service: service, Status: common.ServiceRequestFailed}
+	stats := &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Ratings: 1, TotalRating: 2}
+
+	requestRegistry.On("GetState", []string{"request1"}).Return(request1, nil)
+	requestRegistry.On("GetState", []string{"request2"}).Return(request2, nil)
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	serviceRegistry.On("AddStats", mock.Anything).Run(func(args mock.Arguments) { stats.Add(args.Get(0).(*common.ServiceStats)) }).Return(nil)
+
+	result, err := serviceBroker.Rate("request1", 4)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), 4, result.Rating, "should record the rating")
+	assert.Equal(s.T(), int64(2), stats.Ratings, "should count the rating")
+	assert.Equal(s.T(), int64(6), stats.TotalRating, "should add the rating")
+
+	_, err = serviceBroker.Rate("request1", 5)
+	assert.Error(s.T(), err, "should refuse to rate a request twice")
+
+	_, err = serviceBroker.Rate("request2", 5)
+	assert.Error(s.T(), err, "should refuse to rate a request that is not completed")
+
+	_, err = serviceBroker.Rate("request1", 6)
+	assert.Error(s.T(), err, "should refuse a rating out of range")
+
+	_, err = serviceBroker.Rate("request3", 5)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	requestRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *ServiceBrokerTestSuite) TestGetQuota() {
+	usageRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	transactionContext := &MockTransactionContext{Timestamp: now}
+
+	transactionContext.serviceRegistry = serviceRegistry
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.usageRegistry = usageRegistry
+
+	quota := &common.ServiceQuota{Scope: common.ServiceQuotaPerOrganization, MaxRequests: 10, Window: 3600, MaxPending: 2}
+	windowStart := now.Add(-time.Minute)
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1", Quota: quota}, nil)
+	serviceRegistry.On("Get", "org1", "device1", "service2").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service2"}, nil)
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	usageRegistry.On("GetState", []string{"org1", "device1", "service1", "org2"}).Return(&serviceUsage{WindowStart: windowStart, Requests: 3, Pending: 2}, nil)
+
+	status, err := serviceBroker.GetQuota("org1", "device1", "service1", "org2", "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), quota, status.Quota, "should return the quota of the service")
+	assert.Equal(s.T(), 7, status.RemainingRequests, "should return remaining requests in the window")
+	assert.Equal(s.T(), 0, status.RemainingPending, "should return remaining pending requests")
+	assert.True(s.T(), windowStart.Add(time.Hour).Equal(status.ResetTime), "should return the end of the window")
+
+	status, err = serviceBroker.GetQuota("org1", "device1", "service2", "org2", "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), -1, status.RemainingRequests, "should return unlimited requests")
+	assert.Equal(s.T(), -1, status.RemainingPending, "should return unlimited pending requests")
+
+	_, err = serviceBroker.GetQuota("org1", "device1", "service3", "org2", "device2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *ServiceBrokerTestSuite) TestRequestGroup() {
+	requestRegistry := new(MockStateRegistry)
+	indexRegistry := new(MockStateRegistry)
+	requesterIndexRegistry := new(MockStateRegistry)
+	groupRequestRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	groupRegistry := new(MockDeviceGroupRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.groupRegistry = groupRegistry
+	transactionContext.deviceRegistry = deviceRegistry
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.requestRegistry = requestRegistry
+	serviceBroker.indexRegistry = indexRegistry
+	serviceBroker.requesterIndexRegistry = requesterIndexRegistry
+	serviceBroker.groupRequestRegistry = groupRequestRegistry
+
+	group := &common.DeviceGroup{
+		OrganizationId: "org1",
+		Name:           "group1",
+		Members: []*common.DeviceGroupMember{
+			{OrganizationId: "org1", DeviceId: "device1"},
+			{OrganizationId: "org1", DeviceId: "device2"},
+			{OrganizationId: "org2", DeviceId: "device3"},
+			{OrganizationId: "org1", DeviceId: "device4"},
+			{OrganizationId: "org1", DeviceId: "device5"},
+		},
+	}
+	groupRegistry.On("Get", "org1", "group1").Return(group, nil)
+	groupRegistry.On("Get", "org1", "group4").Return(&common.DeviceGroup{
+		OrganizationId: "org1",
+		Name:           "group4",
+		Members:        []*common.DeviceGroupMember{{OrganizationId: "org1", DeviceId: "device5"}},
+	}, nil)
+	groupRegistry.On("Get", "org1", "group2").Return(&common.DeviceGroup{OrganizationId: "org1", Name: "group2"}, nil)
+	groupRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	groupRequestRegistry.On("GetState", []string{"2b2f3d4c-7cb6-4dc3-8b6a-0c1b7ce5cc6f"}).Return(new(common.GroupServiceRequest), nil)
+	groupRequestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	groupRequestRegistry.On("PutState", mock.Anything).Return(nil)
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("PutState", mock.Anything).Return(nil)
+	indexRegistry.On("PutState", mock.Anything).Return(nil)
+	requesterIndexRegistry.On("PutState", mock.Anything).Return(nil)
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(&common.Service{OrganizationId: "org1", DeviceId: "device1", Name: "service1"}, nil)
+	serviceRegistry.On("Get", "org2", "device3", "service1").Return(&common.Service{OrganizationId: "org2", DeviceId: "device3", Name: "service1"}, nil)
+	serviceRegistry.On("Get", "org1", "device4", "service1").Return(&common.Service{OrganizationId: "org1", DeviceId: "device4", Name: "service1"}, nil)
+	serviceRegistry.On("Get", "org1", "device5", "service1").Return(&common.Service{OrganizationId: "org1", DeviceId: "device5", Name: "service1",
+		Acl: &common.ServiceAcl{ServiceAclRule: common.ServiceAclRule{OrganizationIds: []string{"org1"}}},
+	}, nil)
+	deviceRegistry.On("IsRevoked", "org1", "device4", "").Return(true, nil)
+	deviceRegistry.On("IsRevoked", mock.Anything, mock.Anything, mock.Anything).Return(false, nil)
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	serviceRegistry.On("AddStats", mock.Anything).Return(nil)
+
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	request := &common.GroupServiceRequest{
+		Id:                      "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1",
+		Time:                    now,
+		GroupOrganizationId:     "org1",
+		GroupName:               "group1",
+		ServiceName:             "service1",
+		Method:                  "GET",
+		Arguments:               []string{},
+		RequesterOrganizationId: "org2",
+		RequesterId:             "device2",
+	}
+
+	requests, err := serviceBroker.RequestGroup(request)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), 2, len(requests), "should request only the members providing the service and not revoked")
+	assert.Equal(s.T(), "device1", requests[0].Service.DeviceId, "should request the first member")
+	assert.Equal(s.T(), "device3", requests[1].Service.DeviceId, "should request the last member")
+	assert.Equal(s.T(), request.Id, requests[0].GroupRequestId, "should link member requests to the group request")
+	assert.Equal(s.T(), []string{requests[0].Id, requests[1].Id}, request.RequestIds, "should record member request IDs")
+	assert.Len(s.T(), request.Failures, 1, "should record the members refusing the request")
+	assert.Regexp(s.T(), "not allowed", request.Failures["org1/device5"], "should record why the member refused the request")
+	groupRequestRegistry.AssertCalled(s.T(), "PutState", request)
+	requestRegistry.AssertNumberOfCalls(s.T(), "PutState", 2)
+
+	request.Id = "2b2f3d4c-7cb6-4dc3-8b6a-0c1b7ce5cc6f"
+	_, err = serviceBroker.RequestGroup(request)
+	assert.Error(s.T(), err, "should refuse existing group request")
+
+	request.Id = "5d7e0a36-3d5a-45b1-a9a4-51e0c5c1e8a2"
+	request.GroupName = "group2"
+	_, err = serviceBroker.RequestGroup(request)
+	assert.Error(s.T(), err, "should refuse group without members providing the service")
+
+	request.Id = "9c1e4f5a-7b2d-4e8f-a6c3-2d5b8e9f0a1b"
+	request.GroupName = "group4"
+	_, err = serviceBroker.RequestGroup(request)
+	assert.Error(s.T(), err, "should refuse group whose members all refuse the request")
+
+	request.GroupName = "group3"
+	_, err = serviceBroker.RequestGroup(request)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	request.RequesterId = ""
+	_, err = serviceBroker.RequestGroup(request)
+	assert.Error(s.T(), err, "should refuse missing requester")
+	groupRequestRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *ServiceBrokerTestSuite) TestGetGroupRequest() {
+	requestRegistry := new(MockStateRegistry)
+	responseRegistry := new(MockStateRegistry)
+	groupRequestRegistry := new(MockStateRegistry)
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = new(MockTransactionContext)
+	serviceBroker.requestRegistry = requestRegistry
+	serviceBroker.responseRegistry = responseRegistry
+	serviceBroker.groupRequestRegistry = groupRequestRegistry
+
+	request := &common.GroupServiceRequest{Id: "group1", RequestIds: []string{"request1", "request2", "request3", "request4"}}
+	groupRequestRegistry.On("GetState", []string{"group1"}).Return(request, nil)
+	groupRequestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	requestRegistry.On("GetState", []string{"request1"}).Return(&common.ServiceRequest{Id: "request1", Status: common.ServiceRequestCompleted}, nil)
+	requestRegistry.On("GetState", []string{"request2"}).Return(&common.ServiceRequest{Id: "request2", Status: common.ServiceRequestPending}, nil)
+	requestRegistry.On("GetState", []string{"request3"}).Return(&common.ServiceRequest{Id: "request3", Status: common.ServiceRequestPending}, nil)
+	requestRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	responseRegistry.On("GetState", []string{"request1"}).Return(&common.ServiceResponse{RequestId: "request1"}, nil)
+	responseRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	result, err := serviceBroker.GetGroupRequest("group1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), request, result.Request, "should return the group request")
+	assert.Equal(s.T(), 3, len(result.Responses), "should skip removed member requests")
+	assert.Equal(s.T(), "request1", result.Responses[0].Response.RequestId, "should return member responses")
+	assert.Equal(s.T(), map[common.ServiceRequestStatus]int{common.ServiceRequestCompleted: 1, common.ServiceRequestPending: 2}, result.Progress, "should count member requests by status")
+	assert.False(s.T(), result.IsFinished(), "should not be finished")
+
+	_, err = serviceBroker.GetGroupRequest("group2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *ServiceBrokerTestSuite) TestPublish() {
+	serviceRegistry := new(MockServiceRegistry)
+	streamHeadRegistry := new(MockStateRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceRegistry = serviceRegistry
+
+	serviceBroker := new(ServiceBroker)
+	serviceBroker.ctx = transactionContext
+	serviceBroker.streamHeadRegistry = streamHeadRegistry
+
+	lastPublishTime, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	service := &common.Service{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		Name:           "service1",
+		Streams:        []*common.ServiceStream{{Topic: "temperature"}, {Topic: "pressure"}},
+	}
+	head := &serviceStreamHead{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Sequence: 41, LastPublishTime: lastPublishTime}
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(service, nil)
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	streamHeadRegistry.On("GetState", []string{"org1", "device1", "service1", "temperature"}).Return(head, nil)
+	streamHeadRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	streamHeadRegistry.On("PutState", mock.Anything).Return(nil)
+
+	message := &common.StreamMessage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Time: lastPublishTime.Add(time.Minute), Data: "21.5"}
+	err := serviceBroker.Publish(message)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(42), message.Sequence, "should assign the next sequence number to the message")
+	assert.Equal(s.T(), int64(42), head.Sequence, "should advance the stream head")
+	assert.Equal(s.T(), message.Time, head.LastPublishTime, "should record the publish time")
+	streamHeadRegistry.AssertCalled(s.T(), "PutState", head)
+	serviceRegistry.AssertNotCalled(s.T(), "Register", mock.Anything)
+
+	message = &common.StreamMessage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "pressure", Time: lastPublishTime, Data: "1013"}
+	err = serviceBroker.Publish(message)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), int64(1), message.Sequence, "should start new streams from the first sequence number")
+
+	message = &common.StreamMessage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Time: lastPublishTime, Data: "21.5"}
+	err = serviceBroker.Publish(message)
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse messages older than the stream head")
+
+	message.Topic = "humidity"
+	err = serviceBroker.Publish(message)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should refuse undeclared topics")
+
+	message.ServiceName = "service2"
+	err = serviceBroker.Publish(message)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	message.Data = ""
+	err = serviceBroker.Publish(message)
+	assert.Error(s.T(), err, "should refuse invalid messages")
+	streamHeadRegistry.AssertNumberOfCalls(s.T(), "PutState", 2)
+}
+
 func TestServiceBrokerTestSuite(t *testing.T) {
 	suite.Run(t, new(ServiceBrokerTestSuite))
 }
@@ -1,6 +1,9 @@
 package contract
 
 import (
+	"encoding/json"
+	"fmt"
+
 	"github.com/nexus-lab/iot-service-blockchain/common"
 )
 
@@ -15,30 +18,102 @@ type ServiceRegistryInterface interface {
 	// GetAll return a list of services by their organization ID and device ID
 	GetAll(organizationId string, deviceId string) ([]*common.Service, error)
 
+	// Search return a page of services across organizations and devices matching the query
+	Search(query *common.ServiceQuery) (*common.ServiceQueryResult, error)
+
 	// Deregister remove a service from the ledger
 	Deregister(service *common.Service) error
+
+	// Rekey move a service and the requests made to it to a new device ID of the same device
+	Rekey(service *common.Service, newDeviceId string) error
+
+	// GetStats return the quality metrics of a service
+	GetStats(organizationId string, deviceId string, serviceName string) (*common.ServiceStats, error)
+
+	// AddStats count a change to the quality metrics of a service, recorded apart from the changes of other
+	// transactions so that concurrent transactions do not conflict
+	AddStats(stats *common.ServiceStats) error
+}
+
+// Change to the quality metrics of an IoT service made by a transaction
+type serviceStatsDelta struct {
+	common.ServiceStats
+	TransactionId string `json:"transactionId,omitempty"`
+}
+
+func (d *serviceStatsDelta) GetKeyComponents() []string {
+	components := d.ServiceStats.GetKeyComponents()
+	// stats written before they were recorded per transaction are read as a single change
+	if d.TransactionId != "" {
+		components = append(components, d.TransactionId)
+	}
+	return components
+}
+
+func (d *serviceStatsDelta) Serialize() ([]byte, error) {
+	return json.Marshal(d)
+}
+
+func (d *serviceStatsDelta) Validate() error {
+	return d.ServiceStats.Validate()
+}
+
+func deserializeServiceStatsDelta(data []byte) (*serviceStatsDelta, error) {
+	delta := new(serviceStatsDelta)
+
+	if err := json.Unmarshal(data, delta); err != nil {
+		return nil, err
+	}
+
+	return delta, nil
 }
 
 // ServiceRegistry core utilities for managing services on the ledger
 type ServiceRegistry struct {
 	ctx           TransactionContextInterface
 	stateRegistry StateRegistryInterface
+	statsRegistry StateRegistryInterface
+
+	// deltas changes to quality metrics made by the transaction, kept because the ledger does not read its own writes
+	deltas map[string]*serviceStatsDelta
 }
 
 // Register create or update a service in the ledger
 func (r *ServiceRegistry) Register(service *common.Service) error {
 	// check if device exists
-	_, err := r.ctx.GetDeviceRegistry().Get(service.OrganizationId, service.DeviceId)
+	device, err := r.ctx.GetDeviceRegistry().Get(service.OrganizationId, service.DeviceId)
 	if err != nil {
 		return err
 	}
+	// services cannot be registered under the previous ID of a rekeyed device
+	if device.Id != service.DeviceId {
+		return &common.NotFoundError{What: fmt.Sprintf("device %s, which has been rekeyed to %s", service.DeviceId, device.Id)}
+	}
 
-	return r.stateRegistry.PutState(service)
+	_, err = r.stateRegistry.GetState(service.OrganizationId, service.DeviceId, service.Name)
+	if _, ok := err.(*common.NotFoundError); err != nil && !ok {
+		return err
+	}
+	exists := err == nil
+
+	if err = r.stateRegistry.PutState(service); err != nil || exists {
+		return err
+	}
+
+	return r.ctx.GetDeviceRegistry().UpdateOrganization(service.OrganizationId, 0, 1)
 }
 
 // Get return a service by its organization ID, device ID, and name
 func (r *ServiceRegistry) Get(organizationId string, deviceId string, name string) (*common.Service, error) {
 	state, err := r.stateRegistry.GetState(organizationId, deviceId, name)
+	if _, ok := err.(*common.NotFoundError); ok {
+		// look up the service by the new ID of its device if the device has been rekeyed
+		newDeviceId, err_ := r.ctx.GetDeviceRegistry().Resolve(organizationId, deviceId)
+		if err_ != nil || newDeviceId == deviceId {
+			return nil, err
+		}
+		state, err = r.stateRegistry.GetState(organizationId, newDeviceId, name)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -61,6 +136,43 @@ func (r *ServiceRegistry) GetAll(organizationId string, deviceId string) ([]*com
 	return services, err
 }
 
+// Search return a page of services across organizations and devices matching the query
+func (r *ServiceRegistry) Search(query *common.ServiceQuery) (*common.ServiceQueryResult, error) {
+	if err := query.Validate(); err != nil {
+		return nil, err
+	}
+
+	// narrow down the scanned range by the organization and device if possible
+	keys := make([]string, 0)
+	if query.OrganizationId != "" {
+		keys = append(keys, query.OrganizationId)
+		if query.DeviceId != "" {
+			keys = append(keys, query.DeviceId)
+		}
+	}
+
+	// fetch only as many services as the page misses, so that the ledger bookmark marks where the next page starts
+	result := &common.ServiceQueryResult{Services: make([]*common.Service, 0)}
+	for bookmark := query.Bookmark; ; {
+		states, next, err := r.stateRegistry.GetPage(query.GetPageSize()-len(result.Services), bookmark, keys...)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, state := range states {
+			if service := state.(*common.Service); query.Match(service) {
+				result.Services = append(result.Services, service)
+			}
+		}
+
+		if next == "" || len(result.Services) == query.GetPageSize() {
+			result.Bookmark = next
+			return result, nil
+		}
+		bookmark = next
+	}
+}
+
 // Deregister remove a service from the ledger
 func (r *ServiceRegistry) Deregister(service *common.Service) error {
 	// remove related requests and responses
@@ -72,9 +184,138 @@ func (r *ServiceRegistry) Deregister(service *common.Service) error {
 		if err = r.ctx.GetServiceBroker().Remove(pair.Request.Id); err != nil {
 			return err
 		}
+
+		// notify listening clients of the cascaded removal together with the transaction event
+		r.ctx.AddEvent(newRequestEvent(service.OrganizationId, service.DeviceId, service.Name, pair.Request.Id, "remove", []byte(pair.Request.Id)))
 	}
 
-	return r.stateRegistry.RemoveState(service)
+	// remove quality metrics, which do not exist if the service has never been requested
+	if _, err = r.removeStats(service); err != nil {
+		return err
+	}
+
+	if err = r.stateRegistry.RemoveState(service); err != nil {
+		return err
+	}
+
+	return r.ctx.GetDeviceRegistry().UpdateOrganization(service.OrganizationId, 0, -1)
+}
+
+// Rekey move a service and the requests made to it to a new device ID of the same device
+func (r *ServiceRegistry) Rekey(service *common.Service, newDeviceId string) error {
+	pairs, err := r.ctx.GetServiceBroker().GetAll(service.OrganizationId, service.DeviceId, service.Name)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		if err = r.ctx.GetServiceBroker().Rekey(pair.Request.Id, service.OrganizationId, service.DeviceId, newDeviceId); err != nil {
+			return err
+		}
+	}
+
+	// move quality metrics, compacting the changes made to them into one
+	stats, err := r.removeStats(service)
+	if err != nil {
+		return err
+	}
+	if stats.Requests > 0 {
+		stats.DeviceId = newDeviceId
+		if err = r.putStats(stats); err != nil {
+			return err
+		}
+	}
+
+	if err = r.stateRegistry.RemoveState(service); err != nil {
+		return err
+	}
+
+	service.DeviceId = newDeviceId
+	return r.stateRegistry.PutState(service)
+}
+
+// GetStats return the quality metrics of a service
+func (r *ServiceRegistry) GetStats(organizationId string, deviceId string, serviceName string) (*common.ServiceStats, error) {
+	service, err := r.Get(organizationId, deviceId, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.getStats(service)
+}
+
+// sum the changes made to the quality metrics of a service
+func (r *ServiceRegistry) getStats(service *common.Service) (*common.ServiceStats, error) {
+	stats := &common.ServiceStats{OrganizationId: service.OrganizationId, DeviceId: service.DeviceId, ServiceName: service.Name}
+
+	states, err := r.statsRegistry.GetStates(service.OrganizationId, service.DeviceId, service.Name)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		stats.Add(&state.(*serviceStatsDelta).ServiceStats)
+	}
+	if delta, ok := r.deltas[getStateKey(stats)]; ok {
+		stats.Add(&delta.ServiceStats)
+	}
+
+	return stats, nil
+}
+
+// AddStats count a change to the quality metrics of a service, recorded apart from the changes of other
+// transactions so that concurrent transactions do not conflict
+func (r *ServiceRegistry) AddStats(stats *common.ServiceStats) error {
+	service, err := r.Get(stats.OrganizationId, stats.DeviceId, stats.ServiceName)
+	if err != nil {
+		return err
+	}
+
+	delta := &common.ServiceStats{OrganizationId: service.OrganizationId, DeviceId: service.DeviceId, ServiceName: service.Name}
+	if delta_, ok := r.deltas[getStateKey(delta)]; ok {
+		delta = &delta_.ServiceStats
+	}
+	delta.Add(stats)
+
+	return r.putStats(delta)
+}
+
+// write the change made by the transaction to the quality metrics of a service
+func (r *ServiceRegistry) putStats(stats *common.ServiceStats) error {
+	delta := &serviceStatsDelta{ServiceStats: *stats, TransactionId: r.ctx.GetStub().GetTxID()}
+	if err := r.statsRegistry.PutState(delta); err != nil {
+		return err
+	}
+
+	if r.deltas == nil {
+		r.deltas = make(map[string]*serviceStatsDelta)
+	}
+	r.deltas[getStateKey(stats)] = delta
+
+	return nil
+}
+
+// remove the changes made to the quality metrics of a service and return their sum
+func (r *ServiceRegistry) removeStats(service *common.Service) (*common.ServiceStats, error) {
+	stats, err := r.getStats(service)
+	if err != nil {
+		return nil, err
+	}
+
+	states, err := r.statsRegistry.GetStates(service.OrganizationId, service.DeviceId, service.Name)
+	if err != nil {
+		return nil, err
+	}
+	// the change made by the transaction is not returned by the ledger yet
+	if delta, ok := r.deltas[getStateKey(stats)]; ok {
+		states = append(states, delta)
+		delete(r.deltas, getStateKey(stats))
+	}
+	for _, state := range states {
+		if err = r.statsRegistry.RemoveState(state); err != nil {
+			return nil, err
+		}
+	}
+
+	return stats, nil
 }
 
 func createServiceRegistry(ctx TransactionContextInterface) *ServiceRegistry {
@@ -85,9 +326,17 @@ func createServiceRegistry(ctx TransactionContextInterface) *ServiceRegistry {
 		return common.DeserializeService(data)
 	}
 
+	statsRegistry := new(StateRegistry)
+	statsRegistry.ctx = ctx
+	statsRegistry.Name = "service_stats"
+	statsRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return deserializeServiceStatsDelta(data)
+	}
+
 	registry := new(ServiceRegistry)
 	registry.ctx = ctx
 	registry.stateRegistry = stateRegistry
+	registry.statsRegistry = statsRegistry
 
 	return registry
 }
@@ -1,8 +1,6 @@
 package contract
 
 import (
-	"fmt"
-
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 )
@@ -14,37 +12,55 @@ type ServiceRegistrySmartContract struct {
 
 // Register create or update an IoT service in the ledger
 func (s *ServiceRegistrySmartContract) Register(ctx TransactionContextInterface, data string) error {
-	var err error
-	var organizationId, deviceId string
-
 	service, err := common.DeserializeService([]byte(data))
 	if err != nil {
 		return err
 	}
 
-	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+	// only the device itself or its organization administrators can register its services
+	if err := checkDeviceManager(ctx, service.OrganizationId, service.DeviceId, "cannot register a service other than one of the requested device"); err != nil {
 		return err
 	}
-	if deviceId, err = ctx.GetDeviceId(); err != nil {
+
+	if service.LastUpdateTime, err = getTrustedTime(ctx, service.LastUpdateTime); err != nil {
 		return err
 	}
 
-	if service.OrganizationId != organizationId || service.DeviceId != deviceId {
-		return fmt.Errorf("cannot register a service other than one of the requested device")
+	// devices register their services again when they restart, which must not lift the restrictions set on them
+	existing, err := ctx.GetServiceRegistry().Get(service.OrganizationId, service.DeviceId, service.Name)
+	if _, ok := err.(*common.NotFoundError); err != nil && !ok {
+		return err
+	}
+	if existing != nil && existing.DeviceId == service.DeviceId {
+		keepServiceSettings(service, existing)
 	}
 
 	err = ctx.GetServiceRegistry().Register(service)
 
 	// notify listening clients of the update
 	if err == nil {
-		event := fmt.Sprintf("service://%s/%s/%s/register", service.OrganizationId, service.DeviceId, service.Name)
 		payload, _ := service.Serialize()
-		err = ctx.GetStub().SetEvent(event, payload)
+		err = ctx.SetEvent(newServiceEvent(service.OrganizationId, service.DeviceId, service.Name, "register", payload))
 	}
 
 	return err
 }
 
+// keepServiceSettings carry the settings of a registered service over to its new registration, the access control
+// list is only changed by UpdateAcl, while the price, quota and streams are kept unless the registration sets them
+func keepServiceSettings(service *common.Service, existing *common.Service) {
+	service.Acl = existing.Acl
+	if service.Price == 0 {
+		service.Price = existing.Price
+	}
+	if service.Quota == nil {
+		service.Quota = existing.Quota
+	}
+	if len(service.Streams) == 0 {
+		service.Streams = existing.Streams
+	}
+}
+
 // Get return a device by its organization ID, device ID, and name
 func (s *ServiceRegistrySmartContract) Get(ctx TransactionContextInterface, organizationId string, deviceId string, name string) (*common.Service, error) {
 	return ctx.GetServiceRegistry().Get(organizationId, deviceId, name)
@@ -55,34 +71,72 @@ func (s *ServiceRegistrySmartContract) GetAll(ctx TransactionContextInterface, o
 	return ctx.GetServiceRegistry().GetAll(organizationId, deviceId)
 }
 
-// Deregister remove an IoT service and its request/responses from the ledger
-func (s *ServiceRegistrySmartContract) Deregister(ctx TransactionContextInterface, data string) error {
-	var err error
-	var organizationId, deviceId string
+// Search return a page of IoT services across organizations and devices matching the query
+func (s *ServiceRegistrySmartContract) Search(ctx TransactionContextInterface, data string) (*common.ServiceQueryResult, error) {
+	query, err := common.DeserializeServiceQuery([]byte(data))
+	if err != nil {
+		return nil, err
+	}
 
-	service, err := common.DeserializeService([]byte(data))
+	return ctx.GetServiceRegistry().Search(query)
+}
+
+// GetStats return the quality metrics of a service by its organization ID, device ID, and name
+func (s *ServiceRegistrySmartContract) GetStats(ctx TransactionContextInterface, organizationId string, deviceId string, name string) (*common.ServiceStats, error) {
+	return ctx.GetServiceRegistry().GetStats(organizationId, deviceId, name)
+}
+
+// UpdateAcl replace the access control list of an IoT service
+func (s *ServiceRegistrySmartContract) UpdateAcl(ctx TransactionContextInterface, organizationId string, deviceId string, name string, data string) error {
+	acl, err := common.DeserializeServiceAcl([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	// only the device itself or its organization administrators can update access control lists of its services
+	if err := checkDeviceManager(ctx, organizationId, deviceId, "cannot update access control list of a service other than one of the requested device"); err != nil {
+		return err
+	}
+
+	service, err := ctx.GetServiceRegistry().Get(organizationId, deviceId, name)
 	if err != nil {
 		return err
 	}
 
-	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+	service.Acl = acl
+	if service.LastUpdateTime, err = ctx.GetTimestamp(); err != nil {
 		return err
 	}
-	if deviceId, err = ctx.GetDeviceId(); err != nil {
+
+	err = ctx.GetServiceRegistry().Register(service)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := service.Serialize()
+		err = ctx.SetEvent(newServiceEvent(service.OrganizationId, service.DeviceId, service.Name, "acl", payload))
+	}
+
+	return err
+}
+
+// Deregister remove an IoT service and its request/responses from the ledger
+func (s *ServiceRegistrySmartContract) Deregister(ctx TransactionContextInterface, data string) error {
+	service, err := common.DeserializeService([]byte(data))
+	if err != nil {
 		return err
 	}
 
-	if service.OrganizationId != organizationId || service.DeviceId != deviceId {
-		return fmt.Errorf("cannot deregister a service other than one of the requested device")
+	// only the device itself or its organization administrators can deregister its services
+	if err := checkDeviceManager(ctx, service.OrganizationId, service.DeviceId, "cannot deregister a service other than one of the requested device"); err != nil {
+		return err
 	}
 
 	err = ctx.GetServiceRegistry().Deregister(service)
 
 	// notify listening clients of the update
 	if err == nil {
-		event := fmt.Sprintf("service://%s/%s/%s/deregister", service.OrganizationId, service.DeviceId, service.Name)
 		payload, _ := service.Serialize()
-		err = ctx.GetStub().SetEvent(event, payload)
+		err = ctx.SetEvent(newServiceEvent(service.OrganizationId, service.DeviceId, service.Name, "deregister", payload))
 	}
 
 	return err
@@ -3,6 +3,7 @@ package contract
 import (
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
@@ -15,10 +16,12 @@ type ServiceRegistryContractTestSuite struct {
 }
 
 func (s *ServiceRegistryContractTestSuite) TestRegister() {
-	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:37:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
 	serviceRegistry := new(MockServiceRegistry)
 	ctx.serviceRegistry = serviceRegistry
 
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
 	serviceRegistry.On("Register", mock.AnythingOfType("*common.Service")).Return(nil)
 
 	contract := new(ServiceRegistrySmartContract)
@@ -29,8 +32,13 @@ func (s *ServiceRegistryContractTestSuite) TestRegister() {
 	service, _ := common.DeserializeService(ctx.stub.EventPayload)
 	assert.Equal(s.T(), fmt.Sprintf("service://%s/%s/%s/register", ctx.OrganizationId, ctx.DeviceId, "service1"), ctx.stub.EventName, "should emit event with name")
 	assert.Equal(s.T(), "service1", service.Name, "should emit event with payload")
+	assert.True(s.T(), now.Equal(service.LastUpdateTime), "should record transaction time")
 	ctx.stub.ResetEvent()
 
+	err = contract.Register(ctx, fmt.Sprintf("{\"name\":\"service1\",\"Version\":1,\"organizationId\":\"%s\",\"deviceId\":\"%s\",\"lastUpdateTime\":\"2021-12-12T17:20:00-05:00\"}", ctx.OrganizationId, ctx.DeviceId))
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
 	err = contract.Register(ctx, "{\"name\":\"service2\",\"Version\":1,\"description\":\"Service of Device2\",\"organizationId\":\"org2\",\"deviceId\":\"device2\",\"lastUpdateTime\":\"2021-12-12T17:36:00-05:00\"}")
 	assert.Error(s.T(), err, "should return mismatch device ID and organization ID error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
@@ -40,6 +48,43 @@ func (s *ServiceRegistryContractTestSuite) TestRegister() {
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
 }
 
+func (s *ServiceRegistryContractTestSuite) TestRegisterAgain() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:37:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	serviceRegistry := new(MockServiceRegistry)
+	ctx.serviceRegistry = serviceRegistry
+
+	acl := &common.ServiceAcl{ServiceAclRule: common.ServiceAclRule{OrganizationIds: []string{"org2"}}}
+	quota := &common.ServiceQuota{Scope: common.ServiceQuotaPerClient, MaxPending: 1}
+	existing := &common.Service{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		Name:           "service1",
+		Acl:            acl,
+		Price:          5,
+		Quota:          quota,
+		Streams:        []*common.ServiceStream{{Topic: "temperature"}},
+	}
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(existing, nil)
+	serviceRegistry.On("Register", mock.AnythingOfType("*common.Service")).Return(nil)
+
+	contract := new(ServiceRegistrySmartContract)
+	err := contract.Register(ctx, "{\"name\":\"service1\",\"version\":2,\"organizationId\":\"org1\",\"deviceId\":\"device1\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	service := serviceRegistry.Calls[1].Arguments[0].(*common.Service)
+	assert.Equal(s.T(), acl, service.Acl, "should keep the access control list")
+	assert.Equal(s.T(), int64(5), service.Price, "should keep the price")
+	assert.Equal(s.T(), quota, service.Quota, "should keep the quota")
+	assert.Equal(s.T(), existing.Streams, service.Streams, "should keep the streams")
+	assert.Equal(s.T(), int32(2), service.Version, "should update the other properties")
+
+	err = contract.Register(ctx, "{\"name\":\"service1\",\"version\":3,\"organizationId\":\"org1\",\"deviceId\":\"device1\",\"price\":7,\"acl\":{}}")
+	assert.Nil(s.T(), err, "should return no error")
+	service = serviceRegistry.Calls[3].Arguments[0].(*common.Service)
+	assert.Equal(s.T(), acl, service.Acl, "should only change the access control list through UpdateAcl")
+	assert.Equal(s.T(), int64(7), service.Price, "should change the price set by the registration")
+}
+
 func (s *ServiceRegistryContractTestSuite) TestGet() {
 	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
 	serviceRegistry := new(MockServiceRegistry)
@@ -53,6 +98,19 @@ func (s *ServiceRegistryContractTestSuite) TestGet() {
 	assert.True(s.T(), called, "should retrieve service from service registry")
 }
 
+func (s *ServiceRegistryContractTestSuite) TestGetStats() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	serviceRegistry := new(MockServiceRegistry)
+	ctx.serviceRegistry = serviceRegistry
+
+	serviceRegistry.On("GetStats", "org1", "device1", "service1").Return(new(common.ServiceStats), nil)
+
+	contract := new(ServiceRegistrySmartContract)
+	_, _ = contract.GetStats(ctx, "org1", "device1", "service1")
+	called := serviceRegistry.AssertCalled(s.T(), "GetStats", "org1", "device1", "service1")
+	assert.True(s.T(), called, "should retrieve service stats from service registry")
+}
+
 func (s *ServiceRegistryContractTestSuite) TestGetAll() {
 	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
 	serviceRegistry := new(MockServiceRegistry)
@@ -66,6 +124,64 @@ func (s *ServiceRegistryContractTestSuite) TestGetAll() {
 	assert.True(s.T(), called, "should retrieve services from service registry")
 }
 
+func (s *ServiceRegistryContractTestSuite) TestSearch() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	serviceRegistry := new(MockServiceRegistry)
+	ctx.serviceRegistry = serviceRegistry
+
+	query := &common.ServiceQuery{NamePrefix: "service", PageSize: 10}
+	serviceRegistry.On("Search", query).Return(new(common.ServiceQueryResult), nil)
+
+	contract := new(ServiceRegistrySmartContract)
+	_, err := contract.Search(ctx, "{\"namePrefix\":\"service\",\"pageSize\":10}")
+	assert.Nil(s.T(), err, "should return no error")
+	called := serviceRegistry.AssertCalled(s.T(), "Search", query)
+	assert.True(s.T(), called, "should search services from service registry")
+
+	_, err = contract.Search(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+}
+
+func (s *ServiceRegistryContractTestSuite) TestUpdateAcl() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:37:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	serviceRegistry := new(MockServiceRegistry)
+	ctx.serviceRegistry = serviceRegistry
+
+	service := &common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"}
+	serviceRegistry.On("Get", "org1", "device1", "service1").Return(service, nil)
+	serviceRegistry.On("Get", mock.Anything, mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	serviceRegistry.On("Register", service).Return(nil)
+
+	contract := new(ServiceRegistrySmartContract)
+	err := contract.UpdateAcl(ctx, "org1", "device1", "service1", "{\"ownOrganization\":true,\"methods\":{\"SET\":{\"clientIds\":[\"device1\"]}}}")
+	assert.Nil(s.T(), err, "should return no error")
+	called := serviceRegistry.AssertCalled(s.T(), "Register", service)
+	assert.True(s.T(), called, "should put service to service registry")
+	assert.True(s.T(), service.Acl.OwnOrganization, "should replace access control list of the service")
+	assert.Equal(s.T(), []string{"device1"}, service.Acl.Methods["SET"].ClientIds, "should replace access control list of the service")
+	assert.Equal(s.T(), now, service.LastUpdateTime, "should record transaction time")
+	assert.Equal(s.T(), fmt.Sprintf("service://%s/%s/%s/acl", ctx.OrganizationId, ctx.DeviceId, "service1"), ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.UpdateAcl(ctx, "org1", "device1", "service2", "{}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.UpdateAcl(ctx, "org1", "device1", "service1", "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.DeviceId = "device2"
+	err = contract.UpdateAcl(ctx, "org1", "device1", "service1", "{}")
+	assert.Error(s.T(), err, "should refuse to update access control list for another device")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.IsAdmin = true
+	err = contract.UpdateAcl(ctx, "org1", "device1", "service1", "{}")
+	assert.Nil(s.T(), err, "should allow organization administrators to update access control list")
+}
+
 func (s *ServiceRegistryContractTestSuite) TestDeregister() {
 	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
 	serviceRegistry := new(MockServiceRegistry)
@@ -96,6 +212,11 @@ func (s *ServiceRegistryContractTestSuite) TestDeregister() {
 	err = contract.Deregister(ctx, "{\"name\":\"service2\",\"organizationId\":\"org1\",\"deviceId\":\"device2\"}")
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
 	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.IsAdmin = true
+	err = contract.Deregister(ctx, "{\"name\":\"service1\",\"organizationId\":\"org1\",\"deviceId\":\"device1\"}")
+	assert.Nil(s.T(), err, "should allow organization administrators to deregister services of the organization")
+	assert.Equal(s.T(), "service://org1/device1/service1/deregister", ctx.stub.EventName, "should emit event with name")
 }
 
 func TestServiceRegistryContractTestSuite(t *testing.T) {
@@ -1,8 +1,10 @@
 package contract
 
 import (
+	"fmt"
 	"testing"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -31,11 +33,37 @@ func (r *MockServiceRegistry) GetAll(organizationId string, deviceId string) ([]
 	return args.Get(0).([]*common.Service), args.Error(1)
 }
 
+func (r *MockServiceRegistry) Search(query *common.ServiceQuery) (*common.ServiceQueryResult, error) {
+	args := r.Called(query)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.ServiceQueryResult), args.Error(1)
+}
+
 func (r *MockServiceRegistry) Deregister(service *common.Service) error {
 	args := r.Called(service)
 	return args.Error(0)
 }
 
+func (r *MockServiceRegistry) Rekey(service *common.Service, newDeviceId string) error {
+	args := r.Called(service, newDeviceId)
+	return args.Error(0)
+}
+
+func (r *MockServiceRegistry) GetStats(organizationId string, deviceId string, serviceName string) (*common.ServiceStats, error) {
+	args := r.Called(organizationId, deviceId, serviceName)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.ServiceStats), args.Error(1)
+}
+
+func (r *MockServiceRegistry) AddStats(stats *common.ServiceStats) error {
+	args := r.Called(stats)
+	return args.Error(0)
+}
+
 type ServiceRegistryTestSuite struct {
 	suite.Suite
 }
@@ -56,14 +84,27 @@ func (s *ServiceRegistryTestSuite) TestRegister() {
 	service.DeviceId = "device1"
 	service.Name = "service1"
 
+	stateRegistry.On("GetState", []string{"org1", "device1", "service1"}).Return(nil, new(common.NotFoundError)).Once()
+	stateRegistry.On("GetState", []string{"org1", "device1", "service1"}).Return(service, nil)
 	stateRegistry.On("PutState", service).Return(nil)
-	deviceRegistry.On("Get", "org1", "device1").Return(new(common.Device), nil)
+	deviceRegistry.On("UpdateOrganization", "org1", 0, 1).Return(nil)
+	deviceRegistry.On("Get", "org1", "device1").Return(&common.Device{Id: "device1", OrganizationId: "org1"}, nil)
+	deviceRegistry.On("Get", "org1", "device0").Return(&common.Device{Id: "device1", OrganizationId: "org1"}, nil)
 	deviceRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
 
 	err := serviceRegistry.Register(service)
 	called := stateRegistry.AssertCalled(s.T(), "PutState", service)
 	assert.True(s.T(), called, "should put service to state registry")
 	assert.Nil(s.T(), err, "should return no error")
+	called = deviceRegistry.AssertCalled(s.T(), "UpdateOrganization", "org1", 0, 1)
+	assert.True(s.T(), called, "should count new service in the organization summary")
+
+	err = serviceRegistry.Register(service)
+	assert.Nil(s.T(), err, "should return no error")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "UpdateOrganization", 1)
+
+	err = serviceRegistry.Register(&common.Service{OrganizationId: "org1", DeviceId: "device0", Name: "service1"})
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should refuse to register services under the previous ID of a rekeyed device")
 
 	service = new(common.Service)
 	service.OrganizationId = "org2"
@@ -75,19 +116,29 @@ func (s *ServiceRegistryTestSuite) TestRegister() {
 
 func (s *ServiceRegistryTestSuite) TestGet() {
 	stateRegistry := new(MockStateRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.deviceRegistry = deviceRegistry
 
 	serviceRegistry := new(ServiceRegistry)
-	serviceRegistry.ctx = new(MockTransactionContext)
+	serviceRegistry.ctx = transactionContext
 	serviceRegistry.stateRegistry = stateRegistry
 
 	service := new(common.Service)
 	stateRegistry.On("GetState", []string{"org1", "device1", "service1"}).Return(service, nil)
 	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	deviceRegistry.On("Resolve", "org1", "device0").Return("device1", nil)
+	deviceRegistry.On("Resolve", mock.Anything, mock.Anything).Return("device2", nil)
 
 	result, err := serviceRegistry.Get("org1", "device1", "service1")
 	assert.Equal(s.T(), service, result, "should return the correct service")
 	assert.Nil(s.T(), err, "should return no error")
 
+	result, err = serviceRegistry.Get("org1", "device0", "service1")
+	assert.Equal(s.T(), service, result, "should return the service by the previous ID of its device")
+	assert.Nil(s.T(), err, "should return no error")
+
 	result, err = serviceRegistry.Get("org2", "device2", "service2")
 	assert.Nil(s.T(), result, "should return no service")
 	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
@@ -116,16 +167,67 @@ func (s *ServiceRegistryTestSuite) TestGetAll() {
 	assert.Nil(s.T(), err, "should return no error")
 }
 
+func (s *ServiceRegistryTestSuite) TestSearch() {
+	stateRegistry := new(MockStateRegistry)
+
+	serviceRegistry := new(ServiceRegistry)
+	serviceRegistry.ctx = new(MockTransactionContext)
+	serviceRegistry.stateRegistry = stateRegistry
+
+	services := []StateInterface{
+		&common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1", Version: 1},
+		&common.Service{Name: "service2", DeviceId: "device1", OrganizationId: "org1", Version: 2},
+		&common.Service{Name: "other1", DeviceId: "device2", OrganizationId: "org1", Version: 1},
+		&common.Service{Name: "service3", DeviceId: "device1", OrganizationId: "org2", Version: 1},
+	}
+	stateRegistry.On("GetPage", 2, "", []string{}).Return(services[:2], "bookmark1", nil)
+	stateRegistry.On("GetPage", 2, "bookmark1", []string{}).Return(services[2:], "", nil)
+	stateRegistry.On("GetPage", 2, "bookmark2", []string{}).Return(services[1:3], "bookmark3", nil)
+	stateRegistry.On("GetPage", 1, "bookmark3", []string{}).Return(services[3:], "bookmark4", nil)
+	stateRegistry.On("GetPage", common.DefaultPageSize, "", []string{"org1"}).Return(services[:3], "", nil)
+	stateRegistry.On("GetPage", mock.Anything, "!", mock.Anything).Return(nil, "", fmt.Errorf("invalid bookmark !"))
+
+	result, err := serviceRegistry.Search(&common.ServiceQuery{NamePrefix: "service", PageSize: 2})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Service{services[0].(*common.Service), services[1].(*common.Service)}, result.Services, "should return the first page of matching services")
+	assert.Equal(s.T(), "bookmark1", result.Bookmark, "should return bookmark of the next page")
+
+	result, err = serviceRegistry.Search(&common.ServiceQuery{NamePrefix: "service", PageSize: 2, Bookmark: result.Bookmark})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Service{services[3].(*common.Service)}, result.Services, "should return the next page of matching services")
+	assert.Empty(s.T(), result.Bookmark, "should return no bookmark on the last page")
+
+	result, err = serviceRegistry.Search(&common.ServiceQuery{NamePrefix: "service", PageSize: 2, Bookmark: "bookmark2"})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Service{services[1].(*common.Service), services[3].(*common.Service)}, result.Services, "should fetch more services until the page is full")
+	assert.Equal(s.T(), "bookmark4", result.Bookmark, "should return bookmark after the last fetched service")
+
+	result, err = serviceRegistry.Search(&common.ServiceQuery{OrganizationId: "org1", Version: 1})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), []*common.Service{services[0].(*common.Service), services[2].(*common.Service)}, result.Services, "should return services of the organization matching the query")
+	assert.Empty(s.T(), result.Bookmark, "should return no bookmark on the last page")
+
+	_, err = serviceRegistry.Search(&common.ServiceQuery{Bookmark: "!"})
+	assert.Error(s.T(), err, "should return invalid bookmark error")
+
+	_, err = serviceRegistry.Search(&common.ServiceQuery{DeviceId: "device1"})
+	assert.Error(s.T(), err, "should return invalid query error")
+}
+
 func (s *ServiceRegistryTestSuite) TestDeregister() {
 	stateRegistry := new(MockStateRegistry)
 	serviceBroker := new(MockServiceBroker)
+	deviceRegistry := new(MockDeviceRegistry)
 	transactionContext := new(MockTransactionContext)
 
 	transactionContext.serviceBroker = serviceBroker
+	transactionContext.deviceRegistry = deviceRegistry
 
+	statsRegistry := new(MockStateRegistry)
 	serviceRegistry := new(ServiceRegistry)
 	serviceRegistry.ctx = transactionContext
 	serviceRegistry.stateRegistry = stateRegistry
+	serviceRegistry.statsRegistry = statsRegistry
 
 	service := new(common.Service)
 	service.DeviceId = "device1"
@@ -140,14 +242,153 @@ func (s *ServiceRegistryTestSuite) TestDeregister() {
 	serviceBroker.On("GetAll", "org1", "device1", "service1").Return(pairs, nil)
 	serviceBroker.On("Remove", mock.Anything).Return(nil)
 	stateRegistry.On("RemoveState", service).Return(nil)
+	deviceRegistry.On("UpdateOrganization", "org1", 0, -1).Return(nil)
+	delta := &serviceStatsDelta{ServiceStats: common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 1}, TransactionId: "tx1"}
+	statsRegistry.On("GetStates", []string{"org1", "device1", "service1"}).Return([]StateInterface{delta}, nil)
+	statsRegistry.On("RemoveState", mock.Anything).Return(nil)
 
 	err := serviceRegistry.Deregister(service)
 	called := stateRegistry.AssertCalled(s.T(), "RemoveState", service)
 	assert.True(s.T(), called, "should remove service from state registry")
 	assert.Nil(s.T(), err, "should return no error")
+	called = statsRegistry.AssertCalled(s.T(), "RemoveState", delta)
+	assert.True(s.T(), called, "should remove service stats from state registry")
+	called = deviceRegistry.AssertCalled(s.T(), "UpdateOrganization", "org1", 0, -1)
+	assert.True(s.T(), called, "should uncount service from the organization summary")
 
 	called = serviceBroker.AssertCalled(s.T(), "Remove", "request2")
 	assert.True(s.T(), called, "should remove service (request, response) pairs by the service broker")
+	assert.Equal(s.T(), 2, len(transactionContext.events), "should record cascaded removal of requests")
+	assert.Equal(s.T(), "request://org1/device1/service1/request2/remove", transactionContext.events[1].GetLegacyName(), "should record cascaded removal of requests")
+	assert.Equal(s.T(), []byte("request2"), transactionContext.events[1].GetLegacyPayload(), "should record cascaded removal of requests")
+}
+
+func (s *ServiceRegistryTestSuite) TestRekey() {
+	stateRegistry := new(MockStateRegistry)
+	serviceBroker := new(MockServiceBroker)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceBroker = serviceBroker
+
+	statsRegistry := new(MockStateRegistry)
+	serviceRegistry := new(ServiceRegistry)
+	serviceRegistry.ctx = transactionContext
+	serviceRegistry.stateRegistry = stateRegistry
+	serviceRegistry.statsRegistry = statsRegistry
+
+	service := &common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"}
+	deltas := []StateInterface{
+		&serviceStatsDelta{ServiceStats: common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 2}},
+		&serviceStatsDelta{ServiceStats: common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 1, Responses: 1}, TransactionId: "tx1"},
+	}
+	pairs := []*common.ServiceRequestResponse{
+		{Request: &common.ServiceRequest{Id: "request1"}},
+		{Request: &common.ServiceRequest{Id: "request2"}},
+	}
+
+	serviceBroker.On("GetAll", "org1", "device1", "service1").Return(pairs, nil)
+	serviceBroker.On("Rekey", mock.Anything, "org1", "device1", "device2").Return(nil)
+	stateRegistry.On("RemoveState", service).Return(nil)
+	stateRegistry.On("PutState", service).Return(nil)
+	statsRegistry.On("GetStates", []string{"org1", "device1", "service1"}).Return(deltas, nil)
+	statsRegistry.On("RemoveState", mock.Anything).Return(nil)
+	statsRegistry.On("PutState", mock.Anything).Return(nil)
+	transactionContext.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx2"}}
+
+	err := serviceRegistry.Rekey(service, "device2")
+	assert.Nil(s.T(), err, "should return no error")
+	called := serviceBroker.AssertCalled(s.T(), "Rekey", "request2", "org1", "device1", "device2")
+	assert.True(s.T(), called, "should move requests made to the service")
+	called = stateRegistry.AssertCalled(s.T(), "PutState", service)
+	assert.True(s.T(), called, "should put service to state registry")
+	assert.Equal(s.T(), "device2", service.DeviceId, "should change device ID of the service")
+	statsRegistry.AssertNumberOfCalls(s.T(), "RemoveState", 2)
+	moved := statsRegistry.Calls[len(statsRegistry.Calls)-1].Arguments[0].(*serviceStatsDelta)
+	assert.Equal(s.T(), []string{"org1", "device2", "service1", "tx2"}, moved.GetKeyComponents(), "should move stats of the service")
+	assert.Equal(s.T(), int64(3), moved.Requests, "should compact stats of the service")
+	assert.Equal(s.T(), int64(1), moved.Responses, "should compact stats of the service")
+}
+
+func (s *ServiceRegistryTestSuite) TestGetStats() {
+	stateRegistry := new(MockStateRegistry)
+	statsRegistry := new(MockStateRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.deviceRegistry = deviceRegistry
+	transactionContext.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx3"}}
+
+	serviceRegistry := new(ServiceRegistry)
+	serviceRegistry.ctx = transactionContext
+	serviceRegistry.stateRegistry = stateRegistry
+	serviceRegistry.statsRegistry = statsRegistry
+
+	// stats written before they were recorded per transaction are read as a change without transaction ID
+	deltas := []StateInterface{
+		&serviceStatsDelta{ServiceStats: common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 2, Responses: 1}},
+		&serviceStatsDelta{ServiceStats: common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 1, Ratings: 1, TotalRating: 4}, TransactionId: "tx1"},
+	}
+	stateRegistry.On("GetState", []string{"org1", "device1", "service1"}).Return(&common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"}, nil)
+	stateRegistry.On("GetState", []string{"org1", "device1", "service2"}).Return(&common.Service{Name: "service2", DeviceId: "device1", OrganizationId: "org1"}, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	statsRegistry.On("GetStates", []string{"org1", "device1", "service1"}).Return(deltas, nil)
+	statsRegistry.On("GetStates", mock.Anything).Return([]StateInterface{}, nil)
+	deviceRegistry.On("Resolve", mock.Anything, mock.Anything).Return("device1", nil)
+
+	result, err := serviceRegistry.GetStats("org1", "device1", "service1")
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 3, Responses: 1, Ratings: 1, TotalRating: 4}, result, "should sum the changes to stats of the service")
+
+	result, err = serviceRegistry.GetStats("org1", "device1", "service2")
+	assert.Equal(s.T(), &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service2"}, result, "should return empty stats")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = serviceRegistry.GetStats("org1", "device1", "service3")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return service not found error")
+
+	// reads do not see the writes of the same transaction, so changes written earlier are counted in as well
+	statsRegistry.On("PutState", mock.Anything).Return(nil)
+	_ = serviceRegistry.AddStats(&common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 1})
+	result, err = serviceRegistry.GetStats("org1", "device1", "service1")
+	assert.Equal(s.T(), int64(4), result.Requests, "should count in stats written in the same transaction")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *ServiceRegistryTestSuite) TestAddStats() {
+	stateRegistry := new(MockStateRegistry)
+	statsRegistry := new(MockStateRegistry)
+	deviceRegistry := new(MockDeviceRegistry)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.deviceRegistry = deviceRegistry
+	transactionContext.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+
+	serviceRegistry := new(ServiceRegistry)
+	serviceRegistry.ctx = transactionContext
+	serviceRegistry.stateRegistry = stateRegistry
+	serviceRegistry.statsRegistry = statsRegistry
+
+	stateRegistry.On("GetState", []string{"org1", "device1", "service1"}).Return(&common.Service{Name: "service1", DeviceId: "device1", OrganizationId: "org1"}, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	statsRegistry.On("PutState", mock.Anything).Return(nil)
+	deviceRegistry.On("Resolve", mock.Anything, mock.Anything).Return("device1", nil)
+
+	err := serviceRegistry.AddStats(&common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 1})
+	assert.Nil(s.T(), err, "should return no error")
+	delta := statsRegistry.Calls[0].Arguments[0].(*serviceStatsDelta)
+	assert.Equal(s.T(), []string{"org1", "device1", "service1", "tx1"}, delta.GetKeyComponents(), "should record the change under the transaction ID")
+	assert.Equal(s.T(), int64(1), delta.Requests, "should record the change")
+
+	err = serviceRegistry.AddStats(&common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Responses: 1})
+	assert.Nil(s.T(), err, "should return no error")
+	delta = statsRegistry.Calls[1].Arguments[0].(*serviceStatsDelta)
+	assert.Equal(s.T(), []string{"org1", "device1", "service1", "tx1"}, delta.GetKeyComponents(), "should record the change under the transaction ID")
+	assert.Equal(s.T(), int64(1), delta.Requests, "should merge changes made by the same transaction")
+	assert.Equal(s.T(), int64(1), delta.Responses, "should merge changes made by the same transaction")
+
+	err = serviceRegistry.AddStats(&common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service2", Requests: 1})
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return service not found error")
+	statsRegistry.AssertNumberOfCalls(s.T(), "PutState", 2)
 }
 
 func TestServiceRegistryTestSuite(t *testing.T) {
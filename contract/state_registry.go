@@ -4,8 +4,11 @@
 package contract
 
 import (
+	"encoding/base64"
 	"fmt"
+	"strings"
 
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 )
 
@@ -32,6 +35,14 @@ type StateRegistryInterface interface {
 	// GetStates return a list of states by key components
 	GetStates(keyComponents ...string) ([]StateInterface, error)
 
+	// GetPage return at most pageSize states by key components starting at the bookmark, and the bookmark of the
+	// next page, which is empty if there are no more states
+	GetPage(pageSize int, bookmark string, keyComponents ...string) ([]StateInterface, string, error)
+
+	// GetChunk return at most chunkSize states by key components after the resume key, and the resume key of the next
+	// chunk, which is empty if there are no more states; unlike GetPage it is allowed in write transactions
+	GetChunk(chunkSize int, resumeKey string, keyComponents ...string) ([]StateInterface, string, error)
+
 	// RemoveState remove a state from the ledger
 	RemoveState(state StateInterface) error
 }
@@ -54,6 +65,10 @@ func (r *StateRegistry) PutState(state StateInterface) error {
 		return err
 	}
 
+	if err = r.ctx.CheckRevocation(); err != nil {
+		return err
+	}
+
 	key, err := r.ctx.GetStub().CreateCompositeKey(r.Name, state.GetKeyComponents())
 	if err != nil {
 		return err
@@ -99,26 +114,89 @@ func (r *StateRegistry) GetStates(key ...string) ([]StateInterface, error) {
 	}
 	defer iterator.Close()
 
+	return r.readStates(iterator)
+}
+
+// GetPage return at most pageSize states by their partial composite key starting at the bookmark, and the bookmark
+// of the next page, which is empty if there are no more states
+func (r *StateRegistry) GetPage(pageSize int, bookmark string, key ...string) ([]StateInterface, string, error) {
+	start := ""
+	if bookmark != "" {
+		var err error
+		if start, err = decodeBookmark(bookmark); err != nil {
+			return nil, "", err
+		}
+	}
+
+	iterator, metadata, err := r.ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(r.Name, key, int32(pageSize), start)
+	if err != nil {
+		return nil, "", err
+	}
+	defer iterator.Close()
+
+	states, err := r.readStates(iterator)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// a page shorter than requested is the last one
+	if int(metadata.FetchedRecordsCount) < pageSize || metadata.Bookmark == "" {
+		return states, "", nil
+	}
+
+	return states, encodeBookmark(metadata.Bookmark), nil
+}
+
+// GetChunk return at most chunkSize states by their partial composite key after the resume key, and the resume key of
+// the next chunk, which is empty if there are no more states
+func (r *StateRegistry) GetChunk(chunkSize int, resumeKey string, key ...string) ([]StateInterface, string, error) {
+	after := ""
+	if resumeKey != "" {
+		var err error
+		if after, err = decodeBookmark(resumeKey); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// paginated queries are not allowed in write transactions, so skip the states up to the resume key instead
+	iterator, err := r.ctx.GetStub().GetStateByPartialCompositeKey(r.Name, key)
+	if err != nil {
+		return nil, "", err
+	}
+	defer iterator.Close()
+
 	states := make([]StateInterface, 0)
+	last := ""
 	for iterator.HasNext() {
 		result, err := iterator.Next()
 		if err != nil {
-			return nil, err
+			return nil, "", err
+		}
+		if result.Key <= after {
+			continue
+		}
+		if len(states) == chunkSize {
+			return states, encodeBookmark(last), nil
 		}
 
 		state, err := r.Deserialize(result.Value)
 		if err != nil {
-			return nil, err
+			return nil, "", err
 		}
 
 		states = append(states, state)
+		last = result.Key
 	}
 
-	return states, nil
+	return states, "", nil
 }
 
 // RemoveState remove a state from the ledger
 func (r *StateRegistry) RemoveState(state StateInterface) error {
+	if err := r.ctx.CheckRevocation(); err != nil {
+		return err
+	}
+
 	key, err := r.ctx.GetStub().CreateCompositeKey(r.Name, state.GetKeyComponents())
 	if err != nil {
 		return err
@@ -133,3 +211,42 @@ func (r *StateRegistry) RemoveState(state StateInterface) error {
 
 	return r.ctx.GetStub().DelState(key)
 }
+
+// readStates deserialize the states returned by a ledger query
+func (r *StateRegistry) readStates(iterator shim.StateQueryIteratorInterface) ([]StateInterface, error) {
+	states := make([]StateInterface, 0)
+	for iterator.HasNext() {
+		result, err := iterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		state, err := r.Deserialize(result.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		states = append(states, state)
+	}
+
+	return states, nil
+}
+
+// encodeBookmark return an opaque form of the ledger bookmark where the next page starts
+func encodeBookmark(bookmark string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(bookmark))
+}
+
+// decodeBookmark return the ledger bookmark where the next page starts
+func decodeBookmark(bookmark string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(bookmark)
+	if err != nil {
+		return "", fmt.Errorf("invalid bookmark %s", bookmark)
+	}
+	return string(data), nil
+}
+
+// getStateKey return a key identifying the state within its registry
+func getStateKey(state StateInterface) string {
+	return strings.Join(state.GetKeyComponents(), "\x00")
+}
@@ -6,8 +6,11 @@ import (
 	"testing"
 
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	//lint:ignore SA1019 ignore this
 	"github.com/hyperledger/fabric-chaincode-go/shimtest" //nolint:staticcheck // SA1019 ignore this
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -59,11 +62,75 @@ func (r *MockStateRegistry) GetStates(keyComponents ...string) ([]StateInterface
 	return args.Get(0).([]StateInterface), args.Error(1)
 }
 
+func (r *MockStateRegistry) GetPage(pageSize int, bookmark string, keyComponents ...string) ([]StateInterface, string, error) {
+	args := r.Called(pageSize, bookmark, keyComponents)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]StateInterface), args.String(1), args.Error(2)
+}
+
+func (r *MockStateRegistry) GetChunk(chunkSize int, resumeKey string, keyComponents ...string) ([]StateInterface, string, error) {
+	args := r.Called(chunkSize, resumeKey, keyComponents)
+	if args.Get(0) == nil {
+		return nil, args.String(1), args.Error(2)
+	}
+	return args.Get(0).([]StateInterface), args.String(1), args.Error(2)
+}
+
 func (r *MockStateRegistry) RemoveState(state StateInterface) error {
 	args := r.Called(state)
 	return args.Error(0)
 }
 
+type mockStateIterator struct {
+	results []*queryresult.KV
+}
+
+func (i *mockStateIterator) HasNext() bool {
+	return len(i.results) > 0
+}
+
+func (i *mockStateIterator) Next() (*queryresult.KV, error) {
+	result := i.results[0]
+	i.results = i.results[1:]
+	return result, nil
+}
+
+func (i *mockStateIterator) Close() error {
+	return nil
+}
+
+// pagingMockStub mock stub answering the paginated queries that shimtest leaves unimplemented
+type pagingMockStub struct {
+	*shimtest.MockStub
+}
+
+func (s *pagingMockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	iterator, err := s.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iterator.Close()
+
+	page := &mockStateIterator{results: make([]*queryresult.KV, 0)}
+	metadata := new(peer.QueryResponseMetadata)
+	for iterator.HasNext() {
+		result, _ := iterator.Next()
+		if result.Key < bookmark {
+			continue
+		}
+		if len(page.results) == int(pageSize) {
+			metadata.Bookmark = result.Key
+			break
+		}
+		page.results = append(page.results, result)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.results))
+
+	return page, metadata, nil
+}
+
 type StateRegistryTestSuite struct {
 	suite.Suite
 	stub     *shimtest.MockStub
@@ -75,8 +142,10 @@ func (s *StateRegistryTestSuite) SetupTest() {
 	identity, _ := cid.New(s.stub)
 
 	ctx := &TransactionContext{}
-	ctx.SetStub(s.stub)
+	ctx.SetStub(&pagingMockStub{s.stub})
 	ctx.SetClientIdentity(identity)
+	// the mock stub has no invoking identity to check against the revocation list
+	ctx.SetValue(revocationCheckedKey, true)
 
 	s.registry = &StateRegistry{
 		ctx:  ctx,
@@ -156,6 +225,60 @@ func (s *StateRegistryTestSuite) TestGetStates() {
 	assert.Equal(s.T(), 3, states[2].(*mockState).Value, "should get correct states")
 }
 
+func (s *StateRegistryTestSuite) TestGetPage() {
+	s.stub.MockTransactionStart("GetPage")
+	for i := 1; i <= 3; i++ {
+		key, _ := s.stub.CreateCompositeKey(s.registry.Name, []string{"A", fmt.Sprint(i)})
+		_ = s.stub.PutState(key, []byte(fmt.Sprintf("{\"Id\":\"%d\",\"Value\":%d}", i, i)))
+	}
+	s.stub.MockTransactionEnd("GetPage")
+
+	states, bookmark, err := s.registry.GetPage(2, "", "A")
+	assert.Nil(s.T(), err, "should get states from ledger without error")
+	assert.Equal(s.T(), 2, len(states), "should return a page of states")
+	assert.Equal(s.T(), 1, states[0].(*mockState).Value, "should get correct states")
+	assert.NotEmpty(s.T(), bookmark, "should return bookmark of the next page")
+
+	states, bookmark, err = s.registry.GetPage(2, bookmark, "A")
+	assert.Nil(s.T(), err, "should get states from ledger without error")
+	assert.Equal(s.T(), 1, len(states), "should return the states after the bookmark")
+	assert.Equal(s.T(), 3, states[0].(*mockState).Value, "should get correct states")
+	assert.Empty(s.T(), bookmark, "should return no bookmark on the last page")
+
+	_, bookmark, _ = s.registry.GetPage(3, "", "A")
+	assert.Empty(s.T(), bookmark, "should return no bookmark if there are no more states")
+
+	_, _, err = s.registry.GetPage(2, "!", "A")
+	assert.Error(s.T(), err, "should return invalid bookmark error")
+}
+
+func (s *StateRegistryTestSuite) TestGetChunk() {
+	s.stub.MockTransactionStart("GetChunk")
+	for i := 1; i <= 3; i++ {
+		key, _ := s.stub.CreateCompositeKey(s.registry.Name, []string{"A", fmt.Sprint(i)})
+		_ = s.stub.PutState(key, []byte(fmt.Sprintf("{\"Id\":\"%d\",\"Value\":%d}", i, i)))
+	}
+	s.stub.MockTransactionEnd("GetChunk")
+
+	states, resumeKey, err := s.registry.GetChunk(2, "", "A")
+	assert.Nil(s.T(), err, "should get states from ledger without error")
+	assert.Equal(s.T(), 2, len(states), "should return a chunk of states")
+	assert.Equal(s.T(), 1, states[0].(*mockState).Value, "should get correct states")
+	assert.NotEmpty(s.T(), resumeKey, "should return resume key of the next chunk")
+
+	states, resumeKey, err = s.registry.GetChunk(2, resumeKey, "A")
+	assert.Nil(s.T(), err, "should get states from ledger without error")
+	assert.Equal(s.T(), 1, len(states), "should return the states after the resume key")
+	assert.Equal(s.T(), 3, states[0].(*mockState).Value, "should get correct states")
+	assert.Empty(s.T(), resumeKey, "should return no resume key on the last chunk")
+
+	_, resumeKey, _ = s.registry.GetChunk(3, "", "A")
+	assert.Empty(s.T(), resumeKey, "should return no resume key if there are no more states")
+
+	_, _, err = s.registry.GetChunk(2, "!", "A")
+	assert.Error(s.T(), err, "should return invalid resume key error")
+}
+
 func (s *StateRegistryTestSuite) TestRemoveState() {
 	key, _ := s.stub.CreateCompositeKey(s.registry.Name, []string{"123456"})
 	s.stub.MockTransactionStart("RemoveState")
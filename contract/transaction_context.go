@@ -1,6 +1,9 @@
 package contract
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 )
@@ -15,6 +18,27 @@ type TransactionContextInterface interface {
 	// GetDeviceId returns the ID associated with the invoking identity which is unique within the MSP
 	GetDeviceId() (string, error)
 
+	// IsOrganizationAdmin check if the invoking identity is an administrator of its organization
+	IsOrganizationAdmin() (bool, error)
+
+	// GetTimestamp return the time at which the transaction was created
+	GetTimestamp() (time.Time, error)
+
+	// GetValue return a value stored in the transaction context by its key, nil if it does not exist
+	GetValue(key string) interface{}
+
+	// SetValue store a value in the transaction context, which is shared by the transaction hooks and the invoked function
+	SetValue(key string, value interface{})
+
+	// AddEvent record a change cascaded from the change requested by the client, to be emitted with the transaction event
+	AddEvent(event *common.Event)
+
+	// SetEvent emit the transaction event, bundled with the recorded cascaded changes if there are any
+	SetEvent(event *common.Event) error
+
+	// CheckRevocation reject changes to the ledger by the invoking identity if its device or certificate has been revoked
+	CheckRevocation() error
+
 	// GetDeviceRegistry get the default instance of device registry
 	GetDeviceRegistry() DeviceRegistryInterface
 
@@ -23,14 +47,50 @@ type TransactionContextInterface interface {
 
 	// GetServiceBroker get the default instance of service broker
 	GetServiceBroker() ServiceBrokerInterface
+
+	// GetAccountLedger get the default instance of account ledger
+	GetAccountLedger() AccountLedgerInterface
+
+	// GetDeviceGroupRegistry get the default instance of device group registry
+	GetDeviceGroupRegistry() DeviceGroupRegistryInterface
+
+	// GetWorkflowRegistry get the default instance of workflow registry
+	GetWorkflowRegistry() WorkflowRegistryInterface
+
+	// GetFirmwareRegistry get the default instance of firmware registry
+	GetFirmwareRegistry() FirmwareRegistryInterface
+
+	// GetMigrator get the default instance of migrator
+	GetMigrator() MigratorInterface
 }
 
+// OrganizationAdminOU organizational unit of organization administrator certificates, as defined by Fabric Node OUs
+const OrganizationAdminOU = "admin"
+
+// OrganizationAdminAttributes certificate attributes that identify organization administrators besides the admin
+// organizational unit, no identity is recognized by attributes if it is empty
+var OrganizationAdminAttributes common.AttributePolicy
+
+// revocationCheckedKey key of the transaction context value recording that the invoking identity is not revoked
+const revocationCheckedKey = "revocationChecked"
+
+// MaxClockSkew the maximum difference allowed between times supplied by clients and the transaction timestamp,
+// client times are not checked if it is zero
+var MaxClockSkew = 5 * time.Minute
+
 // TransactionContext an implementation of TransactionContextInterface
 type TransactionContext struct {
 	contractapi.TransactionContext
-	deviceRegistry  DeviceRegistryInterface
-	serviceRegistry ServiceRegistryInterface
-	serviceBroker   ServiceBrokerInterface
+	deviceRegistry   DeviceRegistryInterface
+	serviceRegistry  ServiceRegistryInterface
+	serviceBroker    ServiceBrokerInterface
+	accountLedger    AccountLedgerInterface
+	groupRegistry    DeviceGroupRegistryInterface
+	workflowRegistry WorkflowRegistryInterface
+	firmwareRegistry FirmwareRegistryInterface
+	migrator         MigratorInterface
+	events           []*common.Event
+	values           map[string]interface{}
 }
 
 // GetOrganizationId return the organization MSP ID
@@ -47,6 +107,184 @@ func (c *TransactionContext) GetDeviceId() (string, error) {
 	return common.GetClientId(cert)
 }
 
+// IsOrganizationAdmin check if the invoking identity is an administrator of its organization
+func (c *TransactionContext) IsOrganizationAdmin() (bool, error) {
+	cert, err := c.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return false, err
+	}
+	if cert == nil {
+		return false, fmt.Errorf("cannot determine identity")
+	}
+
+	for _, ou := range cert.Subject.OrganizationalUnit {
+		if ou == OrganizationAdminOU {
+			return true, nil
+		}
+	}
+
+	if len(OrganizationAdminAttributes) == 0 {
+		return false, nil
+	}
+
+	err = OrganizationAdminAttributes.Check(c.GetClientIdentity().GetAttributeValue)
+	if _, ok := err.(*common.AccessDeniedError); ok {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// GetTimestamp return the time at which the transaction was created
+func (c *TransactionContext) GetTimestamp() (time.Time, error) {
+	timestamp, err := c.GetStub().GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return timestamp.AsTime(), nil
+}
+
+// GetValue return a value stored in the transaction context by its key, nil if it does not exist
+func (c *TransactionContext) GetValue(key string) interface{} {
+	return c.values[key]
+}
+
+// SetValue store a value in the transaction context, which is shared by the transaction hooks and the invoked function
+func (c *TransactionContext) SetValue(key string, value interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+// AddEvent record a change cascaded from the change requested by the client, to be emitted with the transaction event
+func (c *TransactionContext) AddEvent(event *common.Event) {
+	c.events = append(c.events, event)
+}
+
+// SetEvent emit the transaction event, bundled with the recorded cascaded changes if there are any
+func (c *TransactionContext) SetEvent(event *common.Event) error {
+	events := append([]*common.Event{event}, c.events...)
+	c.events = nil
+	return setEvent(c, events)
+}
+
+// CheckRevocation reject changes to the ledger by the invoking identity if its device or certificate has been revoked
+func (c *TransactionContext) CheckRevocation() error {
+	if checked, _ := c.GetValue(revocationCheckedKey).(bool); checked {
+		return nil
+	}
+
+	organizationId, err := c.GetOrganizationId()
+	if err != nil {
+		return err
+	}
+	deviceId, err := c.GetDeviceId()
+	if err != nil {
+		return err
+	}
+	cert, err := c.GetClientIdentity().GetX509Certificate()
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		return fmt.Errorf("cannot determine identity")
+	}
+
+	if revoked, err := c.GetDeviceRegistry().IsRevoked(organizationId, deviceId, cert.SerialNumber.Text(16)); err != nil {
+		return err
+	} else if revoked {
+		return &common.AccessDeniedError{Reason: "device or certificate has been revoked"}
+	}
+
+	c.SetValue(revocationCheckedKey, true)
+	return nil
+}
+
+// getTrustedTime check the time supplied by the client against the transaction timestamp and return the latter
+// to be recorded in its place, a zero client time is not checked
+func getTrustedTime(ctx TransactionContextInterface, clientTime time.Time) (time.Time, error) {
+	now, err := ctx.GetTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if !clientTime.IsZero() && MaxClockSkew > 0 {
+		if skew := clientTime.Sub(now); skew > MaxClockSkew || skew < -MaxClockSkew {
+			return time.Time{}, &common.InvalidTimeError{Reason: fmt.Sprintf("%s differs from transaction time %s by more than %s", clientTime.Format(time.RFC3339), now.Format(time.RFC3339), MaxClockSkew)}
+		}
+	}
+
+	return now, nil
+}
+
+// canManageDevice check if the invoking identity is the device itself or an administrator of the device's organization
+func canManageDevice(ctx TransactionContextInterface, organizationId string, deviceId string) (bool, error) {
+	var err error
+	var organizationId_, deviceId_ string
+
+	if organizationId_, err = ctx.GetOrganizationId(); err != nil {
+		return false, err
+	}
+	if deviceId_, err = ctx.GetDeviceId(); err != nil {
+		return false, err
+	}
+
+	if organizationId != organizationId_ {
+		return false, nil
+	}
+	if deviceId == deviceId_ {
+		return true, nil
+	}
+
+	return ctx.IsOrganizationAdmin()
+}
+
+// checkDeviceManager reject the invoking identity unless it is the device itself or an administrator of the device's
+// organization, with the reason why other identities are refused
+func checkDeviceManager(ctx TransactionContextInterface, organizationId string, deviceId string, reason string) error {
+	if ok, err := canManageDevice(ctx, organizationId, deviceId); err != nil {
+		return err
+	} else if !ok {
+		return &common.AccessDeniedError{Reason: reason}
+	}
+	return nil
+}
+
+// checkDevice reject the invoking identity unless it is the device itself, with the reason why other identities are
+// refused
+func checkDevice(ctx TransactionContextInterface, organizationId string, deviceId string, reason string) error {
+	organizationId_, err := ctx.GetOrganizationId()
+	if err != nil {
+		return err
+	}
+	deviceId_, err := ctx.GetDeviceId()
+	if err != nil {
+		return err
+	}
+
+	if organizationId != organizationId_ || deviceId != deviceId_ {
+		return &common.AccessDeniedError{Reason: reason}
+	}
+	return nil
+}
+
+// checkOrganizationAdmin reject the invoking identity unless it is an administrator of the organization, with the
+// reason why other identities are refused
+func checkOrganizationAdmin(ctx TransactionContextInterface, organizationId string, reason string) error {
+	organizationId_, err := ctx.GetOrganizationId()
+	if err != nil {
+		return err
+	}
+
+	if ok, err := ctx.IsOrganizationAdmin(); err != nil {
+		return err
+	} else if !ok || organizationId != organizationId_ {
+		return &common.AccessDeniedError{Reason: reason}
+	}
+	return nil
+}
+
 // GetDeviceRegistry get the device registry instance
 func (c *TransactionContext) GetDeviceRegistry() DeviceRegistryInterface {
 	if c.deviceRegistry == nil {
@@ -73,3 +311,48 @@ func (c *TransactionContext) GetServiceBroker() ServiceBrokerInterface {
 
 	return c.serviceBroker
 }
+
+// GetAccountLedger get the account ledger instance
+func (c *TransactionContext) GetAccountLedger() AccountLedgerInterface {
+	if c.accountLedger == nil {
+		c.accountLedger = createAccountLedger(c)
+	}
+
+	return c.accountLedger
+}
+
+// GetDeviceGroupRegistry get the device group registry instance
+func (c *TransactionContext) GetDeviceGroupRegistry() DeviceGroupRegistryInterface {
+	if c.groupRegistry == nil {
+		c.groupRegistry = createDeviceGroupRegistry(c)
+	}
+
+	return c.groupRegistry
+}
+
+// GetWorkflowRegistry get the workflow registry instance
+func (c *TransactionContext) GetWorkflowRegistry() WorkflowRegistryInterface {
+	if c.workflowRegistry == nil {
+		c.workflowRegistry = createWorkflowRegistry(c)
+	}
+
+	return c.workflowRegistry
+}
+
+// GetFirmwareRegistry get the firmware registry instance
+func (c *TransactionContext) GetFirmwareRegistry() FirmwareRegistryInterface {
+	if c.firmwareRegistry == nil {
+		c.firmwareRegistry = createFirmwareRegistry(c)
+	}
+
+	return c.firmwareRegistry
+}
+
+// GetMigrator get the migrator instance
+func (c *TransactionContext) GetMigrator() MigratorInterface {
+	if c.migrator == nil {
+		c.migrator = createMigrator(c)
+	}
+
+	return c.migrator
+}
@@ -3,13 +3,19 @@ package contract
 import (
 	"crypto/x509"
 	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
 
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const (
@@ -36,7 +42,10 @@ bGllbnQifX0wCgYIKoZIzj0EAwIDSAAwRQIhALxDGVIsgP3VxXMzrv+l0ijGgX4T
 		"D1IYW1wc2hpcmUsTz1vcmcyLmV4YW1wbGUuY29t"
 )
 
-type mockClientIdentity struct{}
+type mockClientIdentity struct {
+	Attributes  map[string]string
+	Certificate *x509.Certificate
+}
 
 func (i *mockClientIdentity) GetID() (string, error) {
 	return CLIENT_ID, nil
@@ -47,7 +56,8 @@ func (i *mockClientIdentity) GetMSPID() (string, error) {
 }
 
 func (i *mockClientIdentity) GetAttributeValue(attrName string) (value string, found bool, err error) {
-	return "", false, nil
+	value, found = i.Attributes[attrName]
+	return value, found, nil
 }
 
 func (i *mockClientIdentity) AssertAttributeValue(attrName, attrValue string) error {
@@ -55,13 +65,63 @@ func (i *mockClientIdentity) AssertAttributeValue(attrName, attrValue string) er
 }
 
 func (i *mockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	if i.Certificate != nil {
+		return i.Certificate, nil
+	}
 	return common.ParseCertificate([]byte(CERTIFICATE))
 }
 
+// identity whose certificate cannot be determined
+type mockUncertifiedIdentity struct {
+	mockClientIdentity
+}
+
+func (i *mockUncertifiedIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	return nil, nil
+}
+
 type mockChaincodeStub struct {
 	shim.ChaincodeStub
+	Function     string
+	TxTimestamp  time.Time
 	EventName    string
 	EventPayload []byte
+
+	// Chaincodes responses of the chaincodes invoked by the transaction, keyed by channel, chaincode name and
+	// optionally function name
+	Chaincodes  map[string]peer.Response
+	Invocations [][][]byte
+
+	// ChaincodeId name of the chaincode invoked by the transaction proposal
+	ChaincodeId string
+}
+
+func (s *mockChaincodeStub) GetSignedProposal() (*peer.SignedProposal, error) {
+	input, _ := proto.Marshal(&peer.ChaincodeInvocationSpec{
+		ChaincodeSpec: &peer.ChaincodeSpec{ChaincodeId: &peer.ChaincodeID{Name: s.ChaincodeId}},
+	})
+	payload, _ := proto.Marshal(&peer.ChaincodeProposalPayload{Input: input})
+	proposal, _ := proto.Marshal(&peer.Proposal{Payload: payload})
+	return &peer.SignedProposal{ProposalBytes: proposal}, nil
+}
+
+func (s *mockChaincodeStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peer.Response {
+	s.Invocations = append(s.Invocations, args)
+	if response, ok := s.Chaincodes[channel+"/"+chaincodeName+"/"+string(args[0])]; ok {
+		return response
+	}
+	if response, ok := s.Chaincodes[channel+"/"+chaincodeName]; ok {
+		return response
+	}
+	return shim.Error("chaincode not found")
+}
+
+func (s *mockChaincodeStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	return timestamppb.New(s.TxTimestamp), nil
+}
+
+func (s *mockChaincodeStub) GetFunctionAndParameters() (string, []string) {
+	return s.Function, []string{}
 }
 
 func (s *mockChaincodeStub) SetEvent(name string, payload []byte) error {
@@ -77,14 +137,24 @@ func (s *mockChaincodeStub) ResetEvent() {
 
 type MockTransactionContext struct {
 	contractapi.TransactionContext
-	identity        *mockClientIdentity
-	stub            *mockChaincodeStub
-	deviceRegistry  DeviceRegistryInterface
-	serviceRegistry ServiceRegistryInterface
-	serviceBroker   ServiceBrokerInterface
+	identity         *mockClientIdentity
+	stub             *mockChaincodeStub
+	deviceRegistry   DeviceRegistryInterface
+	serviceRegistry  ServiceRegistryInterface
+	serviceBroker    ServiceBrokerInterface
+	accountLedger    AccountLedgerInterface
+	groupRegistry    DeviceGroupRegistryInterface
+	workflowRegistry WorkflowRegistryInterface
+	firmwareRegistry FirmwareRegistryInterface
+	migrator         MigratorInterface
+	events           []*common.Event
+	values           map[string]interface{}
 
 	DeviceId       string
 	OrganizationId string
+	IsAdmin        bool
+	IsRevoked      bool
+	Timestamp      time.Time
 }
 
 func (c *MockTransactionContext) GetOrganizationId() (string, error) {
@@ -95,6 +165,42 @@ func (c *MockTransactionContext) GetDeviceId() (string, error) {
 	return c.DeviceId, nil
 }
 
+func (c *MockTransactionContext) IsOrganizationAdmin() (bool, error) {
+	return c.IsAdmin, nil
+}
+
+func (c *MockTransactionContext) GetTimestamp() (time.Time, error) {
+	return c.Timestamp, nil
+}
+
+func (c *MockTransactionContext) GetValue(key string) interface{} {
+	return c.values[key]
+}
+
+func (c *MockTransactionContext) SetValue(key string, value interface{}) {
+	if c.values == nil {
+		c.values = make(map[string]interface{})
+	}
+	c.values[key] = value
+}
+
+func (c *MockTransactionContext) AddEvent(event *common.Event) {
+	c.events = append(c.events, event)
+}
+
+func (c *MockTransactionContext) SetEvent(event *common.Event) error {
+	events := append([]*common.Event{event}, c.events...)
+	c.events = nil
+	return setEvent(c, events)
+}
+
+func (c *MockTransactionContext) CheckRevocation() error {
+	if c.IsRevoked {
+		return &common.AccessDeniedError{Reason: "device or certificate has been revoked"}
+	}
+	return nil
+}
+
 func (c *MockTransactionContext) GetDeviceRegistry() DeviceRegistryInterface {
 	return c.deviceRegistry
 }
@@ -107,6 +213,26 @@ func (c *MockTransactionContext) GetServiceBroker() ServiceBrokerInterface {
 	return c.serviceBroker
 }
 
+func (c *MockTransactionContext) GetAccountLedger() AccountLedgerInterface {
+	return c.accountLedger
+}
+
+func (c *MockTransactionContext) GetDeviceGroupRegistry() DeviceGroupRegistryInterface {
+	return c.groupRegistry
+}
+
+func (c *MockTransactionContext) GetWorkflowRegistry() WorkflowRegistryInterface {
+	return c.workflowRegistry
+}
+
+func (c *MockTransactionContext) GetFirmwareRegistry() FirmwareRegistryInterface {
+	return c.firmwareRegistry
+}
+
+func (c *MockTransactionContext) GetMigrator() MigratorInterface {
+	return c.migrator
+}
+
 func (c *MockTransactionContext) GetClientIdentity() cid.ClientIdentity {
 	if c.identity == nil {
 		c.identity = new(mockClientIdentity)
@@ -143,6 +269,142 @@ func (s *TransactionContextTestSuite) TestGetDeviceId() {
 	assert.Nil(s.T(), err, "should return no error")
 }
 
+func (s *TransactionContextTestSuite) TestIsOrganizationAdmin() {
+	isAdmin, err := s.ctx.IsOrganizationAdmin()
+	assert.False(s.T(), isAdmin, "should not recognize client as administrator")
+	assert.Nil(s.T(), err, "should return no error")
+
+	OrganizationAdminAttributes = common.AttributePolicy{"role": {"admin"}}
+	defer func() { OrganizationAdminAttributes = nil }()
+
+	isAdmin, _ = s.ctx.IsOrganizationAdmin()
+	assert.False(s.T(), isAdmin, "should not recognize client without administrator attributes")
+
+	s.ctx.SetClientIdentity(&mockClientIdentity{Attributes: map[string]string{"role": "admin"}})
+	isAdmin, err = s.ctx.IsOrganizationAdmin()
+	assert.True(s.T(), isAdmin, "should recognize client with administrator attributes")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *TransactionContextTestSuite) TestGetTimestamp() {
+	expected, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	s.ctx.SetStub(&mockChaincodeStub{TxTimestamp: expected})
+
+	actual, err := s.ctx.GetTimestamp()
+	assert.True(s.T(), expected.Equal(actual), "should return transaction timestamp")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *TransactionContextTestSuite) TestSetEvent() {
+	stub := &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+	s.ctx.SetStub(stub)
+
+	err := s.ctx.SetEvent(newDeviceEvent("org1", "device1", "authorize", []byte("device9")))
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device://org1/device1/authorize", stub.EventName, "should emit event on its own without cascaded changes")
+	assert.Equal(s.T(), []byte("device9"), stub.EventPayload, "should emit event with payload")
+
+	s.ctx.AddEvent(newServiceEvent("org1", "device1", "service1", "deregister", []byte("{}")))
+	err = s.ctx.SetEvent(newDeviceEvent("org1", "device1", "deregister", []byte("{}")))
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "composite://tx1", stub.EventName, "should emit composite event with cascaded changes")
+	event, _ := common.DeserializeCompositeEvent(stub.EventPayload)
+	assert.Equal(s.T(), "tx1", event.TransactionId, "should emit composite event with transaction ID")
+	assert.Equal(s.T(), []*common.ChangeEvent{
+		{Name: "device://org1/device1/deregister", Payload: []byte("{}")},
+		{Name: "service://org1/device1/service1/deregister", Payload: []byte("{}")},
+	}, event.Events, "should emit the requested change followed by cascaded changes")
+
+	err = s.ctx.SetEvent(newDeviceEvent("org1", "device1", "register", []byte("{}")))
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), "device://org1/device1/register", stub.EventName, "should clear emitted cascaded changes")
+}
+
+func (s *TransactionContextTestSuite) TestCanManageDevice() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+
+	ok, err := canManageDevice(ctx, "org1", "device1")
+	assert.True(s.T(), ok, "should allow the device itself")
+	assert.Nil(s.T(), err, "should return no error")
+
+	ok, _ = canManageDevice(ctx, "org1", "device2")
+	assert.False(s.T(), ok, "should refuse other devices")
+
+	ctx.IsAdmin = true
+	ok, _ = canManageDevice(ctx, "org1", "device2")
+	assert.True(s.T(), ok, "should allow administrators of the device's organization")
+
+	ok, _ = canManageDevice(ctx, "org2", "device2")
+	assert.False(s.T(), ok, "should refuse administrators of other organizations")
+}
+
+func (s *TransactionContextTestSuite) TestCheckDeviceManager() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+
+	err := checkDeviceManager(ctx, "org1", "device1", "reason")
+	assert.Nil(s.T(), err, "should allow the device itself")
+
+	err = checkDeviceManager(ctx, "org1", "device2", "reason")
+	assert.Equal(s.T(), &common.AccessDeniedError{Reason: "reason"}, err, "should refuse other devices with the reason")
+
+	ctx.IsAdmin = true
+	err = checkDeviceManager(ctx, "org1", "device2", "reason")
+	assert.Nil(s.T(), err, "should allow administrators of the device's organization")
+}
+
+func (s *TransactionContextTestSuite) TestCheckDevice() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", IsAdmin: true}
+
+	err := checkDevice(ctx, "org1", "device1", "reason")
+	assert.Nil(s.T(), err, "should allow the device itself")
+
+	err = checkDevice(ctx, "org1", "device2", "reason")
+	assert.Equal(s.T(), &common.AccessDeniedError{Reason: "reason"}, err, "should refuse administrators of the device's organization")
+
+	err = checkDevice(ctx, "org2", "device1", "reason")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse devices of other organizations")
+}
+
+func (s *TransactionContextTestSuite) TestCheckOrganizationAdmin() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+
+	err := checkOrganizationAdmin(ctx, "org1", "reason")
+	assert.Equal(s.T(), &common.AccessDeniedError{Reason: "reason"}, err, "should refuse non-administrators")
+
+	ctx.IsAdmin = true
+	err = checkOrganizationAdmin(ctx, "org1", "reason")
+	assert.Nil(s.T(), err, "should allow administrators of the organization")
+
+	err = checkOrganizationAdmin(ctx, "org2", "reason")
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse administrators of other organizations")
+}
+
+func (s *TransactionContextTestSuite) TestCheckRevocation() {
+	deviceRegistry := new(MockDeviceRegistry)
+	s.ctx.deviceRegistry = deviceRegistry
+
+	cert, _ := common.ParseCertificate([]byte(CERTIFICATE))
+	serialNumber := cert.SerialNumber.Text(16)
+	deviceRegistry.On("IsRevoked", MSP_ID, CLIENT_ID, serialNumber).Return(true, nil).Once()
+	deviceRegistry.On("IsRevoked", MSP_ID, CLIENT_ID, serialNumber).Return(false, nil)
+
+	err := s.ctx.CheckRevocation()
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should refuse revoked identity")
+
+	err = s.ctx.CheckRevocation()
+	assert.Nil(s.T(), err, "should return no error")
+	err = s.ctx.CheckRevocation()
+	assert.Nil(s.T(), err, "should return no error")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "IsRevoked", 2)
+
+	s.ctx = new(TransactionContext)
+	s.ctx.SetClientIdentity(new(mockUncertifiedIdentity))
+	s.ctx.deviceRegistry = deviceRegistry
+	err = s.ctx.CheckRevocation()
+	assert.EqualError(s.T(), err, "cannot determine identity", "should refuse identity without certificate")
+	deviceRegistry.AssertNumberOfCalls(s.T(), "IsRevoked", 2)
+}
+
 func (s *TransactionContextTestSuite) TestGetDeviceRegistry() {
 	expected := createDeviceRegistry(s.ctx)
 	actual := s.ctx.GetDeviceRegistry().(*DeviceRegistry)
@@ -161,6 +423,67 @@ func (s *TransactionContextTestSuite) TestGetServiceBroker() {
 	assert.Equal(s.T(), expected.requestRegistry.(*StateRegistry).Name, actual.requestRegistry.(*StateRegistry).Name, "should return service broker")
 }
 
+func (s *TransactionContextTestSuite) TestGetAccountLedger() {
+	expected := createAccountLedger(s.ctx)
+	actual := s.ctx.GetAccountLedger().(*AccountLedger)
+	assert.Equal(s.T(), expected.accountRegistry.(*StateRegistry).Name, actual.accountRegistry.(*StateRegistry).Name, "should return account ledger")
+}
+
+func (s *TransactionContextTestSuite) TestGetDeviceGroupRegistry() {
+	expected := createDeviceGroupRegistry(s.ctx)
+	actual := s.ctx.GetDeviceGroupRegistry().(*DeviceGroupRegistry)
+	assert.Equal(s.T(), expected.stateRegistry.(*StateRegistry).Name, actual.stateRegistry.(*StateRegistry).Name, "should return device group registry")
+}
+
+func (s *TransactionContextTestSuite) TestGetWorkflowRegistry() {
+	expected := createWorkflowRegistry(s.ctx)
+	actual := s.ctx.GetWorkflowRegistry().(*WorkflowRegistry)
+	assert.Equal(s.T(), expected.stateRegistry.(*StateRegistry).Name, actual.stateRegistry.(*StateRegistry).Name, "should return workflow registry")
+}
+
+func (s *TransactionContextTestSuite) TestGetFirmwareRegistry() {
+	expected := createFirmwareRegistry(s.ctx)
+	actual := s.ctx.GetFirmwareRegistry().(*FirmwareRegistry)
+	assert.Equal(s.T(), expected.stateRegistry.(*StateRegistry).Name, actual.stateRegistry.(*StateRegistry).Name, "should return firmware registry")
+}
+
+func (s *TransactionContextTestSuite) TestGetTrustedTime() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	ctx := &MockTransactionContext{Timestamp: now}
+
+	trusted, err := getTrustedTime(ctx, now.Add(MaxClockSkew))
+	assert.Nil(s.T(), err, "should accept client time within the clock skew window")
+	assert.Equal(s.T(), now, trusted, "should return transaction time")
+
+	trusted, err = getTrustedTime(ctx, time.Time{})
+	assert.Nil(s.T(), err, "should accept missing client time")
+	assert.Equal(s.T(), now, trusted, "should return transaction time")
+
+	_, err = getTrustedTime(ctx, now.Add(MaxClockSkew+time.Second))
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time ahead of the clock skew window")
+	_, err = getTrustedTime(ctx, now.Add(-MaxClockSkew-time.Second))
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time behind the clock skew window")
+
+	skew := MaxClockSkew
+	MaxClockSkew = 0
+	defer func() { MaxClockSkew = skew }()
+
+	_, err = getTrustedTime(ctx, now.Add(time.Hour))
+	assert.Nil(s.T(), err, "should accept every client time if clock skew is not checked")
+}
+
+func (s *TransactionContextTestSuite) TestGetValue() {
+	assert.Nil(s.T(), s.ctx.GetValue("key1"), "should return nil for missing values")
+	s.ctx.SetValue("key1", "value1")
+	assert.Equal(s.T(), "value1", s.ctx.GetValue("key1"), "should return stored value")
+}
+
+func (s *TransactionContextTestSuite) TestGetMigrator() {
+	expected := createMigrator(s.ctx)
+	actual := s.ctx.GetMigrator().(*Migrator)
+	assert.Equal(s.T(), expected.stateRegistry.(*StateRegistry).Name, actual.stateRegistry.(*StateRegistry).Name, "should return migrator")
+}
+
 func TestTransactionContextTestSuite(t *testing.T) {
 	suite.Run(t, new(TransactionContextTestSuite))
 }
@@ -0,0 +1,256 @@
+package contract
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// WorkflowRegistryInterface core utilities for managing workflows and their runs on the ledger
+type WorkflowRegistryInterface interface {
+	// Register create or update a workflow in the ledger
+	Register(workflow *common.Workflow) error
+
+	// Get return a workflow by its organization ID and name
+	Get(organizationId string, name string) (*common.Workflow, error)
+
+	// GetAll return a list of workflows by their organization ID
+	GetAll(organizationId string) ([]*common.Workflow, error)
+
+	// Deregister remove a workflow from the ledger, leaving its runs untouched
+	Deregister(workflow *common.Workflow) error
+
+	// Start start a run of a workflow and request the services of the steps that depend on no other step
+	Start(run *common.WorkflowRun) ([]*common.ServiceRequest, error)
+
+	// Advance record the outcome of a request made by a workflow run and request the services of the steps that
+	// become ready, return no run if the request is not awaited by any run
+	Advance(request *common.ServiceRequest, response *common.ServiceResponse) (*common.WorkflowRun, []*common.ServiceRequest, error)
+
+	// GetRun return a workflow run by its ID
+	GetRun(runId string) (*common.WorkflowRun, error)
+}
+
+// WorkflowRegistry core utilities for managing workflows and their runs on the ledger
+type WorkflowRegistry struct {
+	ctx           TransactionContextInterface
+	stateRegistry StateRegistryInterface
+	runRegistry   StateRegistryInterface
+}
+
+// Register create or update a workflow in the ledger
+func (r *WorkflowRegistry) Register(workflow *common.Workflow) error {
+	return r.stateRegistry.PutState(workflow)
+}
+
+// Get return a workflow by its organization ID and name
+func (r *WorkflowRegistry) Get(organizationId string, name string) (*common.Workflow, error) {
+	state, err := r.stateRegistry.GetState(organizationId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.Workflow), nil
+}
+
+// GetAll return a list of workflows by their organization ID
+func (r *WorkflowRegistry) GetAll(organizationId string) ([]*common.Workflow, error) {
+	states, err := r.stateRegistry.GetStates(organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	workflows := make([]*common.Workflow, 0)
+	for _, state := range states {
+		workflows = append(workflows, state.(*common.Workflow))
+	}
+
+	return workflows, err
+}
+
+// Deregister remove a workflow from the ledger, leaving its runs untouched
+func (r *WorkflowRegistry) Deregister(workflow *common.Workflow) error {
+	return r.stateRegistry.RemoveState(workflow)
+}
+
+// Start start a run of a workflow and request the services of the steps that depend on no other step
+func (r *WorkflowRegistry) Start(run *common.WorkflowRun) ([]*common.ServiceRequest, error) {
+	if run.RequesterOrganizationId == "" || run.RequesterId == "" {
+		return nil, fmt.Errorf("missing requester in workflow run definition")
+	}
+	if err := run.Validate(); err != nil {
+		return nil, err
+	}
+
+	// check if run already exists
+	if _, err := r.runRegistry.GetState(run.Id); err == nil {
+		return nil, fmt.Errorf("workflow run already exists")
+	} else if _, ok := err.(*common.NotFoundError); !ok {
+		return nil, err
+	}
+
+	workflow, err := r.Get(run.WorkflowOrganizationId, run.WorkflowName)
+	if err != nil {
+		return nil, err
+	}
+
+	// keep the steps of the workflow so that later updates do not affect the run
+	run.Steps = workflow.Steps
+	run.StepStates = make([]*common.WorkflowStepState, 0)
+	for _, step := range workflow.Steps {
+		run.StepStates = append(run.StepStates, &common.WorkflowStepState{Name: step.Name, Status: common.WorkflowStepWaiting})
+	}
+
+	// the first requests are made by the client starting the run, so any failure rejects the run
+	requests, err := r.schedule(run, run.Time, false)
+	if err != nil {
+		return nil, err
+	}
+
+	run.UpdateStatus()
+	run.LastUpdateTime = run.Time
+	if err = r.runRegistry.PutState(run); err != nil {
+		return nil, err
+	}
+
+	return requests, nil
+}
+
+// Advance record the outcome of a request made by a workflow run and request the services of the steps that
+// become ready, return no run if the request is not awaited by any run
+func (r *WorkflowRegistry) Advance(request *common.ServiceRequest, response *common.ServiceResponse) (*common.WorkflowRun, []*common.ServiceRequest, error) {
+	run, err := r.GetRun(request.WorkflowRunId)
+	if _, ok := err.(*common.NotFoundError); ok {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	state := run.GetStepState(request.WorkflowStep)
+	if state == nil || state.RequestId != request.Id || state.Status != common.WorkflowStepRequested {
+		return nil, nil, nil
+	}
+
+	if response != nil {
+		state.StatusCode, state.ReturnValue = response.StatusCode, response.ReturnValue
+		state.Status = common.WorkflowStepCompleted
+		if response.StatusCode != 0 {
+			state.Status = common.WorkflowStepFailed
+			state.Error = fmt.Sprintf("request failed with status code %d", response.StatusCode)
+		}
+	} else {
+		state.Status = common.WorkflowStepFailed
+		state.Error = fmt.Sprintf("request %s", request.Status)
+	}
+
+	now, err := r.ctx.GetTimestamp()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// later requests are made on behalf of the client that started the run, so failures only fail their steps
+	requests, err := r.schedule(run, now, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	run.UpdateStatus()
+	run.LastUpdateTime = now
+	if err = r.runRegistry.PutState(run); err != nil {
+		return nil, nil, err
+	}
+
+	return run, requests, nil
+}
+
+// request the services of the steps that are ready, recording failures on the steps if deferred
+func (r *WorkflowRegistry) schedule(run *common.WorkflowRun, now time.Time, deferred bool) ([]*common.ServiceRequest, error) {
+	requests := make([]*common.ServiceRequest, 0)
+
+	// failed steps may skip the steps depending on them, so repeat until no step is ready
+	for steps := run.GetReadySteps(); len(steps) > 0; steps = run.GetReadySteps() {
+		for _, step := range steps {
+			state := run.GetStepState(step.Name)
+
+			request, err := r.request(run, step, now, deferred)
+			if err != nil {
+				if !deferred {
+					return nil, err
+				}
+				state.Status = common.WorkflowStepFailed
+				state.Error = err.Error()
+				continue
+			}
+
+			state.Status = common.WorkflowStepRequested
+			state.RequestId = request.Id
+			requests = append(requests, request)
+		}
+	}
+
+	return requests, nil
+}
+
+func (r *WorkflowRegistry) request(run *common.WorkflowRun, step *common.WorkflowStep, now time.Time, deferred bool) (*common.ServiceRequest, error) {
+	arguments, err := run.ResolveArguments(step)
+	if err != nil {
+		return nil, err
+	}
+
+	request := run.NewRequest(step, arguments, r.ctx.GetStub().GetTxID())
+	request.Time = now
+	if err = request.Validate(); err != nil {
+		return nil, err
+	}
+
+	// certificate attributes are checked against the submitting client, which is not the requester in later steps
+	if deferred {
+		service, err := r.ctx.GetServiceRegistry().Get(step.OrganizationId, step.DeviceId, step.ServiceName)
+		if err != nil {
+			return nil, err
+		}
+		if service.Acl != nil && len(service.Acl.Attributes) > 0 {
+			return nil, &common.AccessDeniedError{Reason: "services restricted by certificate attributes can only be requested by the first steps of a workflow"}
+		}
+	}
+
+	if err = r.ctx.GetServiceBroker().Request(request); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// GetRun return a workflow run by its ID
+func (r *WorkflowRegistry) GetRun(runId string) (*common.WorkflowRun, error) {
+	state, err := r.runRegistry.GetState(runId)
+	if err != nil {
+		return nil, err
+	}
+
+	return state.(*common.WorkflowRun), nil
+}
+
+func createWorkflowRegistry(ctx TransactionContextInterface) *WorkflowRegistry {
+	stateRegistry := new(StateRegistry)
+	stateRegistry.ctx = ctx
+	stateRegistry.Name = "workflows"
+	stateRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeWorkflow(data)
+	}
+
+	runRegistry := new(StateRegistry)
+	runRegistry.ctx = ctx
+	runRegistry.Name = "workflow_runs"
+	runRegistry.Deserialize = func(data []byte) (StateInterface, error) {
+		return common.DeserializeWorkflowRun(data)
+	}
+
+	registry := new(WorkflowRegistry)
+	registry.ctx = ctx
+	registry.stateRegistry = stateRegistry
+	registry.runRegistry = runRegistry
+
+	return registry
+}
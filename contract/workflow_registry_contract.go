@@ -0,0 +1,161 @@
+package contract
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// WorkflowRegistrySmartContract smart contract for managing workflows and their runs on the ledger
+type WorkflowRegistrySmartContract struct {
+	contractapi.Contract
+}
+
+// Register create or update a workflow in the ledger
+func (s *WorkflowRegistrySmartContract) Register(ctx TransactionContextInterface, data string) error {
+	var err error
+	var organizationId, deviceId string
+
+	workflow, err := common.DeserializeWorkflow([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	if organizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if deviceId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	existing, err := ctx.GetWorkflowRegistry().Get(workflow.OrganizationId, workflow.Name)
+	if err == nil {
+		// only the workflow owner or its organization administrators can update the workflow
+		if err := checkDeviceManager(ctx, existing.OrganizationId, existing.OwnerId, "cannot update a workflow other than one owned by the client"); err != nil {
+			return err
+		}
+		workflow.OwnerId = existing.OwnerId
+	} else if _, ok := err.(*common.NotFoundError); ok {
+		// clients can only create workflows in their own organization, which they own
+		if workflow.OrganizationId != organizationId {
+			return fmt.Errorf("cannot create a workflow of an organization other than the client's")
+		}
+		workflow.OwnerId = deviceId
+	} else {
+		return err
+	}
+
+	if workflow.LastUpdateTime, err = getTrustedTime(ctx, workflow.LastUpdateTime); err != nil {
+		return err
+	}
+
+	err = ctx.GetWorkflowRegistry().Register(workflow)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := workflow.Serialize()
+		err = ctx.SetEvent(newWorkflowEvent(workflow.OrganizationId, workflow.Name, "register", payload))
+	}
+
+	return err
+}
+
+// Get return a workflow by its organization ID and name
+func (s *WorkflowRegistrySmartContract) Get(ctx TransactionContextInterface, organizationId string, name string) (*common.Workflow, error) {
+	return ctx.GetWorkflowRegistry().Get(organizationId, name)
+}
+
+// GetAll return a list of workflows by their organization ID
+func (s *WorkflowRegistrySmartContract) GetAll(ctx TransactionContextInterface, organizationId string) ([]*common.Workflow, error) {
+	return ctx.GetWorkflowRegistry().GetAll(organizationId)
+}
+
+// Deregister remove a workflow from the ledger
+func (s *WorkflowRegistrySmartContract) Deregister(ctx TransactionContextInterface, organizationId string, name string) error {
+	workflow, err := ctx.GetWorkflowRegistry().Get(organizationId, name)
+	if err != nil {
+		return err
+	}
+
+	// only the workflow owner or its organization administrators can deregister the workflow
+	if err := checkDeviceManager(ctx, workflow.OrganizationId, workflow.OwnerId, "cannot deregister a workflow other than one owned by the client"); err != nil {
+		return err
+	}
+
+	err = ctx.GetWorkflowRegistry().Deregister(workflow)
+
+	// notify listening clients of the update
+	if err == nil {
+		payload, _ := workflow.Serialize()
+		err = ctx.SetEvent(newWorkflowEvent(workflow.OrganizationId, workflow.Name, "deregister", payload))
+	}
+
+	return err
+}
+
+// Start start a run of a workflow, whose requests are made on behalf of the calling client
+func (s *WorkflowRegistrySmartContract) Start(ctx TransactionContextInterface, data string) error {
+	run, err := common.DeserializeWorkflowRun([]byte(data))
+	if err != nil {
+		return err
+	}
+
+	// record the calling client as the requester regardless of what the client claims
+	if run.RequesterOrganizationId, err = ctx.GetOrganizationId(); err != nil {
+		return err
+	}
+	if run.RequesterId, err = ctx.GetDeviceId(); err != nil {
+		return err
+	}
+
+	if run.Time, err = getTrustedTime(ctx, run.Time); err != nil {
+		return err
+	}
+
+	requests, err := ctx.GetWorkflowRegistry().Start(run)
+	if err != nil {
+		return err
+	}
+
+	// notify the requested devices together with the start of the run
+	for _, request := range requests {
+		payload, _ := request.Serialize()
+		ctx.AddEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "request", payload))
+	}
+
+	payload, _ := run.Serialize()
+	return ctx.SetEvent(newWorkflowEvent(run.WorkflowOrganizationId, run.WorkflowName, "start", payload))
+}
+
+// GetRun return a workflow run by its ID
+func (s *WorkflowRegistrySmartContract) GetRun(ctx TransactionContextInterface, runId string) (*common.WorkflowRun, error) {
+	return ctx.GetWorkflowRegistry().GetRun(runId)
+}
+
+// advanceWorkflow advance the workflow run that made a finished request, if any, and record the changes as
+// cascaded events
+func advanceWorkflow(ctx TransactionContextInterface, request *common.ServiceRequest, response *common.ServiceResponse) error {
+	if request.WorkflowRunId == "" || (response == nil && !request.Status.IsFinal()) {
+		return nil
+	}
+
+	run, requests, err := ctx.GetWorkflowRegistry().Advance(request, response)
+	if err != nil || run == nil {
+		return err
+	}
+
+	for _, request := range requests {
+		payload, _ := request.Serialize()
+		ctx.AddEvent(newRequestEvent(request.Service.OrganizationId, request.Service.DeviceId, request.Service.Name, request.Id, "request", payload))
+	}
+
+	action := "advance"
+	if run.Status != common.WorkflowRunRunning {
+		action = string(run.Status)
+	}
+	payload, _ := run.Serialize()
+	ctx.AddEvent(newWorkflowEvent(run.WorkflowOrganizationId, run.WorkflowName, action, payload))
+
+	return nil
+}
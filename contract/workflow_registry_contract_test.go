@@ -0,0 +1,164 @@
+package contract
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkflowRegistryContractTestSuite struct {
+	suite.Suite
+}
+
+func (s *WorkflowRegistryContractTestSuite) TestRegister() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:37:00-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.workflowRegistry = workflowRegistry
+
+	existing := &common.Workflow{OrganizationId: "org1", Name: "workflow2", OwnerId: "device2"}
+	workflowRegistry.On("Get", "org1", "workflow2").Return(existing, nil)
+	workflowRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	workflowRegistry.On("Register", mock.AnythingOfType("*common.Workflow")).Return(nil)
+
+	contract := new(WorkflowRegistrySmartContract)
+	err := contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"workflow1\",\"ownerId\":\"device9\",\"steps\":[]}")
+	assert.Nil(s.T(), err, "should return no error")
+	workflow, _ := common.DeserializeWorkflow(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "workflow://org1/workflow1/register", ctx.stub.EventName, "should emit event with name")
+	assert.Equal(s.T(), "device1", workflow.OwnerId, "should record the client as the workflow owner")
+	assert.True(s.T(), now.Equal(workflow.LastUpdateTime), "should record transaction time")
+	ctx.stub.ResetEvent()
+
+	err = contract.Register(ctx, "{\"organizationId\":\"org2\",\"name\":\"workflow1\"}")
+	assert.Error(s.T(), err, "should refuse workflow of another organization")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"workflow2\",\"description\":\"updated\"}")
+	assert.Error(s.T(), err, "should refuse update by a client other than the owner")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	ctx.IsAdmin = true
+	err = contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"workflow2\",\"description\":\"updated\"}")
+	assert.Nil(s.T(), err, "should allow update by organization administrators")
+	workflow, _ = common.DeserializeWorkflow(ctx.stub.EventPayload)
+	assert.Equal(s.T(), "updated", workflow.Description, "should update the workflow")
+	assert.Equal(s.T(), "device2", workflow.OwnerId, "should keep the workflow owner")
+	ctx.stub.ResetEvent()
+
+	err = contract.Register(ctx, "{\"organizationId\":\"org1\",\"name\":\"workflow1\",\"lastUpdateTime\":\"2021-12-12T17:20:00-05:00\"}")
+	assert.IsType(s.T(), new(common.InvalidTimeError), err, "should refuse client time outside the clock skew window")
+
+	err = contract.Register(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *WorkflowRegistryContractTestSuite) TestGet() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.workflowRegistry = workflowRegistry
+
+	workflowRegistry.On("Get", "org1", "workflow1").Return(new(common.Workflow), nil)
+
+	contract := new(WorkflowRegistrySmartContract)
+	_, _ = contract.Get(ctx, "org1", "workflow1")
+	called := workflowRegistry.AssertCalled(s.T(), "Get", "org1", "workflow1")
+	assert.True(s.T(), called, "should retrieve workflow from workflow registry")
+}
+
+func (s *WorkflowRegistryContractTestSuite) TestGetAll() {
+	ctx := &MockTransactionContext{DeviceId: "device2", OrganizationId: "org2"}
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.workflowRegistry = workflowRegistry
+
+	workflowRegistry.On("GetAll", "org1").Return([]*common.Workflow{}, nil)
+
+	contract := new(WorkflowRegistrySmartContract)
+	_, _ = contract.GetAll(ctx, "org1")
+	called := workflowRegistry.AssertCalled(s.T(), "GetAll", "org1")
+	assert.True(s.T(), called, "should retrieve workflows from workflow registry")
+}
+
+func (s *WorkflowRegistryContractTestSuite) TestDeregister() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.workflowRegistry = workflowRegistry
+
+	workflow := &common.Workflow{OrganizationId: "org1", Name: "workflow1", OwnerId: "device1"}
+	workflowRegistry.On("Get", "org1", "workflow1").Return(workflow, nil)
+	workflowRegistry.On("Get", "org1", "workflow2").Return(&common.Workflow{OrganizationId: "org1", Name: "workflow2", OwnerId: "device2"}, nil)
+	workflowRegistry.On("Get", mock.Anything, mock.Anything).Return(nil, new(common.NotFoundError))
+	workflowRegistry.On("Deregister", workflow).Return(nil)
+
+	contract := new(WorkflowRegistrySmartContract)
+	err := contract.Deregister(ctx, "org1", "workflow1")
+	assert.Nil(s.T(), err, "should return no error")
+	workflowRegistry.AssertCalled(s.T(), "Deregister", workflow)
+	assert.Equal(s.T(), "workflow://org1/workflow1/deregister", ctx.stub.EventName, "should emit event with name")
+	ctx.stub.ResetEvent()
+
+	err = contract.Deregister(ctx, "org1", "workflow2")
+	assert.Error(s.T(), err, "should refuse workflow owned by another client")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Deregister(ctx, "org1", "workflow3")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *WorkflowRegistryContractTestSuite) TestStart() {
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:38:30-05:00")
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1", Timestamp: now}
+	ctx.stub = &mockChaincodeStub{ChaincodeStub: shim.ChaincodeStub{TxID: "tx1"}}
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.workflowRegistry = workflowRegistry
+
+	requests := []*common.ServiceRequest{
+		{Id: "request1", Service: common.Service{OrganizationId: "org1", DeviceId: "device2", Name: "service1"}},
+	}
+	workflowRegistry.On("Start", mock.MatchedBy(func(r *common.WorkflowRun) bool { return r.WorkflowName == "workflow1" })).Return(requests, nil)
+	workflowRegistry.On("Start", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	contract := new(WorkflowRegistrySmartContract)
+	err := contract.Start(ctx, "{\"id\":\"run1\",\"time\":\"2021-12-12T17:38:00-05:00\",\"workflowOrganizationId\":\"org1\",\"workflowName\":\"workflow1\",\"requesterId\":\"device9\"}")
+	assert.Nil(s.T(), err, "should return no error")
+	run := workflowRegistry.Calls[0].Arguments[0].(*common.WorkflowRun)
+	assert.Equal(s.T(), ctx.OrganizationId, run.RequesterOrganizationId, "should record requester organization ID")
+	assert.Equal(s.T(), ctx.DeviceId, run.RequesterId, "should ignore client-supplied requester client ID")
+	assert.Equal(s.T(), now, run.Time, "should record transaction time")
+	event, _ := common.DeserializeCompositeEvent(ctx.stub.EventPayload)
+	assert.Equal(s.T(), 2, len(event.Events), "should emit the run start with the first requests")
+	assert.Equal(s.T(), "workflow://org1/workflow1/start", event.Events[0].Name, "should emit workflow start event first")
+	assert.Equal(s.T(), "request://org1/device2/service1/request1/request", event.Events[1].Name, "should emit step request events")
+	ctx.stub.ResetEvent()
+
+	err = contract.Start(ctx, "{\"id\":\"run2\",\"workflowOrganizationId\":\"org1\",\"workflowName\":\"workflow2\"}")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+
+	err = contract.Start(ctx, "[]")
+	assert.Error(s.T(), err, "should return deserialization error")
+	assert.Empty(s.T(), ctx.stub.EventName, "should not emit event")
+}
+
+func (s *WorkflowRegistryContractTestSuite) TestGetRun() {
+	ctx := &MockTransactionContext{DeviceId: "device1", OrganizationId: "org1"}
+	workflowRegistry := new(MockWorkflowRegistry)
+	ctx.workflowRegistry = workflowRegistry
+
+	workflowRegistry.On("GetRun", "run1").Return(new(common.WorkflowRun), nil)
+
+	contract := new(WorkflowRegistrySmartContract)
+	_, _ = contract.GetRun(ctx, "run1")
+	called := workflowRegistry.AssertCalled(s.T(), "GetRun", "run1")
+	assert.True(s.T(), called, "should retrieve workflow run from workflow registry")
+}
+
+func TestWorkflowRegistryContractTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowRegistryContractTestSuite))
+}
@@ -0,0 +1,340 @@
+package contract
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type MockWorkflowRegistry struct {
+	mock.Mock
+}
+
+func (r *MockWorkflowRegistry) Register(workflow *common.Workflow) error {
+	args := r.Called(workflow)
+	return args.Error(0)
+}
+
+func (r *MockWorkflowRegistry) Get(organizationId string, name string) (*common.Workflow, error) {
+	args := r.Called(organizationId, name)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.Workflow), args.Error(1)
+}
+
+func (r *MockWorkflowRegistry) GetAll(organizationId string) ([]*common.Workflow, error) {
+	args := r.Called(organizationId)
+	return args.Get(0).([]*common.Workflow), args.Error(1)
+}
+
+func (r *MockWorkflowRegistry) Deregister(workflow *common.Workflow) error {
+	args := r.Called(workflow)
+	return args.Error(0)
+}
+
+func (r *MockWorkflowRegistry) Start(run *common.WorkflowRun) ([]*common.ServiceRequest, error) {
+	args := r.Called(run)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*common.ServiceRequest), args.Error(1)
+}
+
+func (r *MockWorkflowRegistry) Advance(request *common.ServiceRequest, response *common.ServiceResponse) (*common.WorkflowRun, []*common.ServiceRequest, error) {
+	args := r.Called(request, response)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(*common.WorkflowRun), args.Get(1).([]*common.ServiceRequest), args.Error(2)
+}
+
+func (r *MockWorkflowRegistry) GetRun(runId string) (*common.WorkflowRun, error) {
+	args := r.Called(runId)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*common.WorkflowRun), args.Error(1)
+}
+
+// a workflow reading a sensor and actuating a device if the reading is above a threshold
+func newTestWorkflow() *common.Workflow {
+	return &common.Workflow{
+		OrganizationId: "org1",
+		Name:           "workflow1",
+		Steps: []*common.WorkflowStep{
+			{Name: "read", OrganizationId: "org1", DeviceId: "sensor1", ServiceName: "temperature", Method: "GET", Arguments: []string{}},
+			{
+				Name: "actuate", OrganizationId: "org2", DeviceId: "fan1", ServiceName: "speed", Method: "SET",
+				Arguments: []string{"${read.returnValue}", "${input.speed}"},
+				DependsOn: []string{"read"},
+				Condition: &common.WorkflowCondition{Step: "read", Operator: "gt", Value: "30"},
+			},
+		},
+	}
+}
+
+type WorkflowRegistryTestSuite struct {
+	suite.Suite
+}
+
+func (s *WorkflowRegistryTestSuite) TestRegister() {
+	stateRegistry := new(MockStateRegistry)
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = new(MockTransactionContext)
+	workflowRegistry.stateRegistry = stateRegistry
+
+	workflow := newTestWorkflow()
+	stateRegistry.On("PutState", workflow).Return(nil)
+
+	err := workflowRegistry.Register(workflow)
+	called := stateRegistry.AssertCalled(s.T(), "PutState", workflow)
+	assert.True(s.T(), called, "should put workflow to state registry")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *WorkflowRegistryTestSuite) TestGet() {
+	stateRegistry := new(MockStateRegistry)
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = new(MockTransactionContext)
+	workflowRegistry.stateRegistry = stateRegistry
+
+	workflow := newTestWorkflow()
+	stateRegistry.On("GetState", []string{"org1", "workflow1"}).Return(workflow, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	result, err := workflowRegistry.Get("org1", "workflow1")
+	assert.Equal(s.T(), workflow, result, "should return the correct workflow")
+	assert.Nil(s.T(), err, "should return no error")
+
+	result, err = workflowRegistry.Get("org2", "workflow2")
+	assert.Nil(s.T(), result, "should return no workflow")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *WorkflowRegistryTestSuite) TestGetAll() {
+	stateRegistry := new(MockStateRegistry)
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = new(MockTransactionContext)
+	workflowRegistry.stateRegistry = stateRegistry
+
+	workflows := []StateInterface{new(common.Workflow), new(common.Workflow)}
+	stateRegistry.On("GetStates", []string{"org1"}).Return(workflows, nil)
+
+	results, err := workflowRegistry.GetAll("org1")
+	assert.Equal(s.T(), len(workflows), len(results), "should return the correct number of workflows")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *WorkflowRegistryTestSuite) TestDeregister() {
+	stateRegistry := new(MockStateRegistry)
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = new(MockTransactionContext)
+	workflowRegistry.stateRegistry = stateRegistry
+
+	workflow := newTestWorkflow()
+	stateRegistry.On("RemoveState", workflow).Return(nil)
+
+	err := workflowRegistry.Deregister(workflow)
+	called := stateRegistry.AssertCalled(s.T(), "RemoveState", workflow)
+	assert.True(s.T(), called, "should remove workflow from state registry")
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *WorkflowRegistryTestSuite) TestStart() {
+	stateRegistry := new(MockStateRegistry)
+	runRegistry := new(MockStateRegistry)
+	serviceBroker := new(MockServiceBroker)
+	transactionContext := new(MockTransactionContext)
+
+	transactionContext.serviceBroker = serviceBroker
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = transactionContext
+	workflowRegistry.stateRegistry = stateRegistry
+	workflowRegistry.runRegistry = runRegistry
+
+	workflow := newTestWorkflow()
+	stateRegistry.On("GetState", []string{"org1", "workflow1"}).Return(workflow, nil)
+	stateRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	runRegistry.On("GetState", []string{"5d7e0a36-3d5a-45b1-a9a4-51e0c5c1e8a2"}).Return(new(common.WorkflowRun), nil)
+	runRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	runRegistry.On("PutState", mock.Anything).Return(nil)
+	serviceBroker.On("Request", mock.MatchedBy(func(r *common.ServiceRequest) bool { return r.Service.DeviceId == "sensor1" })).Return(nil).Once()
+	serviceBroker.On("Request", mock.Anything).Return(&common.AccessDeniedError{})
+
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+	run := &common.WorkflowRun{
+		Id:                      "d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1",
+		Time:                    now,
+		WorkflowOrganizationId:  "org1",
+		WorkflowName:            "workflow1",
+		RequesterOrganizationId: "org3",
+		RequesterId:             "app1",
+	}
+
+	requests, err := workflowRegistry.Start(run)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), 1, len(requests), "should request only the steps that depend on no other step")
+	assert.Equal(s.T(), "sensor1", requests[0].Service.DeviceId, "should request the first step")
+	assert.Equal(s.T(), "app1", requests[0].RequesterId, "should request on behalf of the client starting the run")
+	assert.Equal(s.T(), run.Id, requests[0].WorkflowRunId, "should link the request to the run")
+	assert.Equal(s.T(), "read", requests[0].WorkflowStep, "should link the request to the step")
+	assert.Equal(s.T(), now, requests[0].Time, "should request at the run time")
+	assert.Equal(s.T(), common.WorkflowRunRunning, run.Status, "should be running")
+	assert.Equal(s.T(), &common.WorkflowStepState{Name: "read", Status: common.WorkflowStepRequested, RequestId: requests[0].Id}, run.StepStates[0], "should record the request of the first step")
+	assert.Equal(s.T(), &common.WorkflowStepState{Name: "actuate", Status: common.WorkflowStepWaiting}, run.StepStates[1], "should wait for the first step")
+	runRegistry.AssertCalled(s.T(), "PutState", run)
+
+	run.Id = "1b0f3a5c-6c59-4d4c-8c0e-3e1c1f5a7b9d"
+	_, err = workflowRegistry.Start(run)
+	assert.IsType(s.T(), new(common.AccessDeniedError), err, "should reject the run if its first requests fail")
+
+	run.Id = "5d7e0a36-3d5a-45b1-a9a4-51e0c5c1e8a2"
+	_, err = workflowRegistry.Start(run)
+	assert.Error(s.T(), err, "should refuse existing run")
+
+	run.Id = "8f14e45f-ceea-467f-a0e6-7f6b3f3b6e1d"
+	run.WorkflowName = "workflow2"
+	_, err = workflowRegistry.Start(run)
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	run.RequesterId = ""
+	_, err = workflowRegistry.Start(run)
+	assert.Error(s.T(), err, "should refuse missing requester")
+	runRegistry.AssertNumberOfCalls(s.T(), "PutState", 1)
+}
+
+func (s *WorkflowRegistryTestSuite) TestAdvance() {
+	runRegistry := new(MockStateRegistry)
+	serviceRegistry := new(MockServiceRegistry)
+	serviceBroker := new(MockServiceBroker)
+	now, _ := time.Parse(time.RFC3339, "2021-12-12T17:35:00-05:00")
+	transactionContext := &MockTransactionContext{Timestamp: now}
+
+	transactionContext.serviceRegistry = serviceRegistry
+	transactionContext.serviceBroker = serviceBroker
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = transactionContext
+	workflowRegistry.runRegistry = runRegistry
+
+	newRun := func(id string) *common.WorkflowRun {
+		return &common.WorkflowRun{
+			Id:                      id,
+			WorkflowOrganizationId:  "org1",
+			WorkflowName:            "workflow1",
+			Inputs:                  map[string]string{"speed": "high"},
+			RequesterOrganizationId: "org3",
+			RequesterId:             "app1",
+			Status:                  common.WorkflowRunRunning,
+			Steps:                   newTestWorkflow().Steps,
+			StepStates: []*common.WorkflowStepState{
+				{Name: "read", Status: common.WorkflowStepRequested, RequestId: "request1"},
+				{Name: "actuate", Status: common.WorkflowStepWaiting},
+			},
+		}
+	}
+	run1 := newRun("d7b8a3b4-2c53-4c2a-9c0b-0bd1a4d0b3a1")
+	run2 := newRun("5d7e0a36-3d5a-45b1-a9a4-51e0c5c1e8a2")
+	run3 := newRun("1b0f3a5c-6c59-4d4c-8c0e-3e1c1f5a7b9d")
+	run4 := newRun("8f14e45f-ceea-467f-a0e6-7f6b3f3b6e1d")
+	runRegistry.On("GetState", []string{run1.Id}).Return(run1, nil)
+	runRegistry.On("GetState", []string{run2.Id}).Return(run2, nil)
+	runRegistry.On("GetState", []string{run3.Id}).Return(run3, nil)
+	runRegistry.On("GetState", []string{run4.Id}).Return(run4, nil)
+	runRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+	runRegistry.On("PutState", mock.Anything).Return(nil)
+	serviceRegistry.On("Get", "org2", "fan1", "speed").Return(&common.Service{OrganizationId: "org2", DeviceId: "fan1", Name: "speed"}, nil).Once()
+	serviceRegistry.On("Get", "org2", "fan1", "speed").Return(&common.Service{OrganizationId: "org2", DeviceId: "fan1", Name: "speed", Acl: &common.ServiceAcl{
+		Attributes: common.AttributePolicy{"role": {"operator"}},
+	}}, nil)
+	serviceBroker.On("Request", mock.Anything).Return(nil)
+
+	// the reading is above the threshold, so the next step is requested with the reading
+	request := &common.ServiceRequest{Id: "request1", WorkflowRunId: run1.Id, WorkflowStep: "read"}
+	run, requests, err := workflowRegistry.Advance(request, &common.ServiceResponse{RequestId: "request1", ReturnValue: "31.5"})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), run1, run, "should return the run")
+	assert.Equal(s.T(), common.WorkflowStepCompleted, run.StepStates[0].Status, "should complete the step")
+	assert.Equal(s.T(), "31.5", run.StepStates[0].ReturnValue, "should record the response")
+	assert.Equal(s.T(), 1, len(requests), "should request the next step")
+	assert.Equal(s.T(), []string{"31.5", "high"}, requests[0].Arguments, "should map arguments from the response and inputs")
+	assert.Equal(s.T(), now, requests[0].Time, "should request at the transaction time")
+	assert.Equal(s.T(), common.WorkflowStepRequested, run.StepStates[1].Status, "should record the request of the next step")
+	assert.Equal(s.T(), common.WorkflowRunRunning, run.Status, "should keep running")
+	assert.True(s.T(), now.Equal(run.LastUpdateTime), "should record transaction time")
+
+	// the next step responds, which completes the run
+	request = &common.ServiceRequest{Id: requests[0].Id, WorkflowRunId: run1.Id, WorkflowStep: "actuate"}
+	run, requests, err = workflowRegistry.Advance(request, &common.ServiceResponse{RequestId: request.Id})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Zero(s.T(), len(requests), "should request no more steps")
+	assert.Equal(s.T(), common.WorkflowRunCompleted, run.Status, "should complete the run")
+
+	// the reading is below the threshold, so the next step is skipped
+	request = &common.ServiceRequest{Id: "request1", WorkflowRunId: run2.Id, WorkflowStep: "read"}
+	run, requests, err = workflowRegistry.Advance(request, &common.ServiceResponse{RequestId: "request1", ReturnValue: "29"})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Zero(s.T(), len(requests), "should request no more steps")
+	assert.Equal(s.T(), common.WorkflowStepSkipped, run.StepStates[1].Status, "should skip the next step")
+	assert.Equal(s.T(), common.WorkflowRunCompleted, run.Status, "should complete the run")
+
+	// the first request expires, which skips the next step and fails the run
+	request = &common.ServiceRequest{Id: "request1", WorkflowRunId: run3.Id, WorkflowStep: "read", Status: common.ServiceRequestExpired}
+	run, _, err = workflowRegistry.Advance(request, nil)
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), common.WorkflowStepFailed, run.StepStates[0].Status, "should fail the step")
+	assert.Equal(s.T(), "request expired", run.StepStates[0].Error, "should record the failure")
+	assert.Equal(s.T(), common.WorkflowStepSkipped, run.StepStates[1].Status, "should skip the next step")
+	assert.Equal(s.T(), common.WorkflowRunFailed, run.Status, "should fail the run")
+
+	// the next service restricts requesters by certificate attributes, which fails the step instead of the transaction
+	request = &common.ServiceRequest{Id: "request1", WorkflowRunId: run4.Id, WorkflowStep: "read"}
+	run, requests, err = workflowRegistry.Advance(request, &common.ServiceResponse{RequestId: "request1", ReturnValue: "35"})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Zero(s.T(), len(requests), "should request no more steps")
+	assert.Equal(s.T(), common.WorkflowStepFailed, run.StepStates[1].Status, "should fail the next step")
+	assert.NotEmpty(s.T(), run.StepStates[1].Error, "should record the failure")
+	assert.Equal(s.T(), common.WorkflowRunFailed, run.Status, "should fail the run")
+
+	// requests that are not awaited by any run are ignored
+	run, _, err = workflowRegistry.Advance(&common.ServiceRequest{Id: "request1", WorkflowRunId: run1.Id, WorkflowStep: "read"}, &common.ServiceResponse{})
+	assert.Nil(s.T(), run, "should ignore finished steps")
+	assert.Nil(s.T(), err, "should return no error")
+	run, _, err = workflowRegistry.Advance(&common.ServiceRequest{Id: "request1", WorkflowRunId: "run9", WorkflowStep: "read"}, &common.ServiceResponse{})
+	assert.Nil(s.T(), run, "should ignore unknown runs")
+	assert.Nil(s.T(), err, "should return no error")
+	runRegistry.AssertNumberOfCalls(s.T(), "PutState", 5)
+}
+
+func (s *WorkflowRegistryTestSuite) TestGetRun() {
+	runRegistry := new(MockStateRegistry)
+
+	workflowRegistry := new(WorkflowRegistry)
+	workflowRegistry.ctx = new(MockTransactionContext)
+	workflowRegistry.runRegistry = runRegistry
+
+	run := new(common.WorkflowRun)
+	runRegistry.On("GetState", []string{"run1"}).Return(run, nil)
+	runRegistry.On("GetState", mock.Anything).Return(nil, new(common.NotFoundError))
+
+	result, err := workflowRegistry.GetRun("run1")
+	assert.Equal(s.T(), run, result, "should return the correct run")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = workflowRegistry.GetRun("run2")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func TestWorkflowRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowRegistryTestSuite))
+}
@@ -0,0 +1,126 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// AccountEvent an event emitted by the account ledger contract notifying an account update
+type AccountEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
+	// Action name of the action performed on the account
+	Action string
+
+	// OrganizationId organization ID of the account
+	OrganizationId string
+
+	// Payload custom event payload
+	Payload interface{}
+}
+
+// AccountLedgerInterface core utilities for managing token accounts of organizations on the ledger
+type AccountLedgerInterface interface {
+	// Deposit add tokens to the account of an organization
+	Deposit(organizationId string, amount int64) error
+
+	// GetBalance return the account of an organization with its available and escrowed balances
+	GetBalance(organizationId string) (*common.Account, error)
+
+	// GetStatement return the changes made to the account of an organization in chronological order
+	GetStatement(organizationId string) ([]*common.AccountEntry, error)
+
+	// RegisterEvent registers for account ledger events
+	RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *AccountEvent, context.CancelFunc, error)
+}
+
+// AccountLedger core utilities for managing token accounts of organizations on the ledger
+type AccountLedger struct {
+	contract ContractInterface
+}
+
+// Deposit add tokens to the account of an organization
+func (l *AccountLedger) Deposit(organizationId string, amount int64) error {
+	_, err := l.contract.SubmitTransaction("Deposit", organizationId, strconv.FormatInt(amount, 10))
+	return err
+}
+
+// GetBalance return the account of an organization with its available and escrowed balances
+func (l *AccountLedger) GetBalance(organizationId string) (*common.Account, error) {
+	data, err := l.contract.SubmitTransaction("Get", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeAccount(data)
+}
+
+// GetStatement return the changes made to the account of an organization in chronological order
+func (l *AccountLedger) GetStatement(organizationId string) ([]*common.AccountEntry, error) {
+	data, err := l.contract.SubmitTransaction("GetStatement", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.AccountEntry, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// RegisterEvent registers for account ledger events
+func (l *AccountLedger) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *AccountEvent, context.CancelFunc, error) {
+	dest := make(chan *AccountEvent)
+	source, cancel, err := l.contract.RegisterEvent(options...)
+
+	go func() {
+		defer close(dest)
+
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityAccount {
+				continue
+			}
+
+			payload := event.GetLegacyPayload()
+			accountEvent := &AccountEvent{
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				Action:         event.Action,
+			}
+
+			if accountEvent.Action == "deposit" {
+				account, err := common.DeserializeAccount(payload)
+				if err != nil {
+					log.Printf("bad account event payload %#v, action is %s\n", payload, accountEvent.Action)
+					continue
+				}
+				accountEvent.Payload = account
+			} else {
+				accountEvent.Payload = payload
+			}
+
+			dest <- accountEvent
+		}
+	}()
+
+	return dest, cancel, err
+}
+
+// CreateAccountLedger the default factory for creating account ledgers
+func CreateAccountLedger(network *client.Network, chaincodeId string) AccountLedgerInterface {
+	return &AccountLedger{
+		contract: &Contract{
+			network:      network,
+			chaincodeId:  chaincodeId,
+			contractName: "account_ledger",
+		},
+	}
+}
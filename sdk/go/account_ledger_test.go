@@ -0,0 +1,112 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type AccountLedgerTestSuite struct {
+	suite.Suite
+}
+
+func (s *AccountLedgerTestSuite) TestDeposit() {
+	contract := new(MockContract)
+	accountLedger := &AccountLedger{contract}
+
+	contract.On("SubmitTransaction", "Deposit", "org1", "10").Return(nil, nil)
+	contract.On("SubmitTransaction", "Deposit", "org2", "10").Return(nil, errors.New(""))
+
+	err := accountLedger.Deposit("org1", 10)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = accountLedger.Deposit("org2", 10)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *AccountLedgerTestSuite) TestGetBalance() {
+	contract := new(MockContract)
+	accountLedger := &AccountLedger{contract}
+
+	expected := &common.Account{OrganizationId: "org1", Balance: 10, Escrow: 5}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "Get", "org1").Return(data, nil)
+	contract.On("SubmitTransaction", "Get", "org2").Return(nil, errors.New(""))
+
+	actual, err := accountLedger.GetBalance("org1")
+	assert.Equal(s.T(), expected, actual, "should return correct account")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = accountLedger.GetBalance("org2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *AccountLedgerTestSuite) TestGetStatement() {
+	contract := new(MockContract)
+	accountLedger := &AccountLedger{contract}
+
+	expected := []*common.AccountEntry{{Kind: common.AccountDeposit, Amount: 10}, {Kind: common.AccountEscrow, Amount: 5}}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetStatement", "org1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetStatement", "org2").Return(nil, errors.New(""))
+
+	actual, err := accountLedger.GetStatement("org1")
+	assert.Equal(s.T(), expected, actual, "should return correct account entries")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = accountLedger.GetStatement("org2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *AccountLedgerTestSuite) TestRegisterEvent() {
+	contract := new(MockContract)
+	accountLedger := &AccountLedger{contract}
+
+	eventChannel := make(chan *client.ChaincodeEvent)
+	go func() {
+		for i := 0; i < 5; i++ {
+			data, _ := (&common.Account{OrganizationId: fmt.Sprintf("org%d", i), Balance: int64(i)}).Serialize()
+			eventChannel <- &client.ChaincodeEvent{
+				EventName: fmt.Sprintf("account://org%d/deposit", i),
+				Payload:   data,
+			}
+		}
+	}()
+
+	var cancelFunc context.CancelFunc = func() {
+		close(eventChannel)
+	}
+
+	contract.On("RegisterEvent", mock.Anything).Return(eventChannel, cancelFunc, nil)
+
+	source, cancel, err := accountLedger.RegisterEvent()
+	defer cancel()
+	assert.Nil(s.T(), err, "should return no error")
+
+	for i := 0; i < 5; i++ {
+		event := <-source
+		assert.Equal(s.T(), "deposit", event.Action, "should return correct action")
+		assert.Equal(s.T(), fmt.Sprintf("org%d", i), event.OrganizationId, "should return correct organization ID")
+		assert.IsType(s.T(), new(common.Account), event.Payload, "should return parsed account as event payload")
+		assert.Equal(s.T(), int64(i), event.Payload.(*common.Account).Balance, "should return correct event payload")
+	}
+
+	contract = new(MockContract)
+	accountLedger = &AccountLedger{contract}
+	contract.On("RegisterEvent", mock.Anything).Return(nil, nil, errors.New(""))
+
+	_, _, err = accountLedger.RegisterEvent()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func TestAccountLedgerTestSuite(t *testing.T) {
+	suite.Run(t, new(AccountLedgerTestSuite))
+}
@@ -0,0 +1,175 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// DeviceGroupEvent an event emitted by the device group registry or service broker contract notifying a device group update
+type DeviceGroupEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
+	// Action name of the action performed on the device group
+	Action string
+
+	// OrganizationId organization ID of the device group
+	OrganizationId string
+
+	// GroupName name of the device group
+	GroupName string
+
+	// Payload custom event payload
+	Payload interface{}
+}
+
+// DeviceGroupRegistryInterface core utilities for managing device groups on the ledger
+type DeviceGroupRegistryInterface interface {
+	// Register create or update a device group in the ledger
+	Register(group *common.DeviceGroup) error
+
+	// Get return a device group by its organization ID and name
+	Get(organizationId string, name string) (*common.DeviceGroup, error)
+
+	// GetAll return a list of device groups by their organization ID
+	GetAll(organizationId string) ([]*common.DeviceGroup, error)
+
+	// Deregister remove a device group from the ledger
+	Deregister(organizationId string, name string) error
+
+	// Join add the calling device to a device group whose membership policy allows it
+	Join(organizationId string, name string) error
+
+	// AddMember add a registered device to a device group as the group owner
+	AddMember(organizationId string, name string, memberOrganizationId string, memberId string) error
+
+	// RemoveMember remove a member device from a device group as the group owner or the member itself
+	RemoveMember(organizationId string, name string, memberOrganizationId string, memberId string) error
+
+	// RegisterEvent registers for device group events
+	RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *DeviceGroupEvent, context.CancelFunc, error)
+}
+
+// DeviceGroupRegistry core utilities for managing device groups on the ledger
+type DeviceGroupRegistry struct {
+	contract ContractInterface
+}
+
+// Register create or update a device group in the ledger
+func (r *DeviceGroupRegistry) Register(group *common.DeviceGroup) error {
+	if group == nil {
+		return fmt.Errorf("cannot register an empty device group")
+	}
+
+	data, err := group.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Register", string(data))
+	return err
+}
+
+// Get return a device group by its organization ID and name
+func (r *DeviceGroupRegistry) Get(organizationId string, name string) (*common.DeviceGroup, error) {
+	data, err := r.contract.SubmitTransaction("Get", organizationId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeDeviceGroup(data)
+}
+
+// GetAll return a list of device groups by their organization ID
+func (r *DeviceGroupRegistry) GetAll(organizationId string) ([]*common.DeviceGroup, error) {
+	data, err := r.contract.SubmitTransaction("GetAll", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.DeviceGroup, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Deregister remove a device group from the ledger
+func (r *DeviceGroupRegistry) Deregister(organizationId string, name string) error {
+	_, err := r.contract.SubmitTransaction("Deregister", organizationId, name)
+	return err
+}
+
+// Join add the calling device to a device group whose membership policy allows it
+func (r *DeviceGroupRegistry) Join(organizationId string, name string) error {
+	_, err := r.contract.SubmitTransaction("Join", organizationId, name)
+	return err
+}
+
+// AddMember add a registered device to a device group as the group owner
+func (r *DeviceGroupRegistry) AddMember(organizationId string, name string, memberOrganizationId string, memberId string) error {
+	_, err := r.contract.SubmitTransaction("AddMember", organizationId, name, memberOrganizationId, memberId)
+	return err
+}
+
+// RemoveMember remove a member device from a device group as the group owner or the member itself
+func (r *DeviceGroupRegistry) RemoveMember(organizationId string, name string, memberOrganizationId string, memberId string) error {
+	_, err := r.contract.SubmitTransaction("RemoveMember", organizationId, name, memberOrganizationId, memberId)
+	return err
+}
+
+// RegisterEvent registers for device group events
+func (r *DeviceGroupRegistry) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *DeviceGroupEvent, context.CancelFunc, error) {
+	dest := make(chan *DeviceGroupEvent)
+	source, cancel, err := r.contract.RegisterEvent(options...)
+
+	go func() {
+		defer close(dest)
+
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityGroup {
+				continue
+			}
+
+			payload := event.GetLegacyPayload()
+			groupEvent := &DeviceGroupEvent{
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				GroupName:      event.GroupName,
+				Action:         event.Action,
+			}
+
+			var err error
+			if groupEvent.Action == "request" {
+				groupEvent.Payload, err = common.DeserializeGroupServiceRequest(payload)
+			} else {
+				groupEvent.Payload, err = common.DeserializeDeviceGroup(payload)
+			}
+			if err != nil {
+				log.Printf("bad device group event payload %#v, action is %s\n", payload, groupEvent.Action)
+				continue
+			}
+
+			dest <- groupEvent
+		}
+	}()
+
+	return dest, cancel, err
+}
+
+// CreateDeviceGroupRegistry the default factory for creating device group registries
+func CreateDeviceGroupRegistry(network *client.Network, chaincodeId string) DeviceGroupRegistryInterface {
+	return &DeviceGroupRegistry{
+		contract: &Contract{
+			network:      network,
+			chaincodeId:  chaincodeId,
+			contractName: "device_group_registry",
+		},
+	}
+}
@@ -0,0 +1,148 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type DeviceGroupRegistryTestSuite struct {
+	suite.Suite
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestRegister() {
+	contract := new(MockContract)
+	groupRegistry := &DeviceGroupRegistry{contract}
+
+	group := &common.DeviceGroup{OrganizationId: "org1", Name: "group1"}
+	data, _ := group.Serialize()
+	contract.On("SubmitTransaction", "Register", string(data)).Return(nil, nil)
+
+	err := groupRegistry.Register(group)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = groupRegistry.Register(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	group = &common.DeviceGroup{OrganizationId: "org2", Name: "group2"}
+	data, _ = group.Serialize()
+	contract.On("SubmitTransaction", "Register", string(data)).Return(nil, errors.New(""))
+
+	err = groupRegistry.Register(group)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestGet() {
+	contract := new(MockContract)
+	groupRegistry := &DeviceGroupRegistry{contract}
+
+	expected := &common.DeviceGroup{OrganizationId: "org1", Name: "group1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "Get", "org1", "group1").Return(data, nil)
+	contract.On("SubmitTransaction", "Get", "org2", "group2").Return(nil, new(common.NotFoundError))
+
+	actual, err := groupRegistry.Get("org1", "group1")
+	assert.Equal(s.T(), expected, actual, "should return correct device group")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = groupRegistry.Get("org2", "group2")
+	assert.Nil(s.T(), actual, "should return no device group")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestGetAll() {
+	contract := new(MockContract)
+	groupRegistry := &DeviceGroupRegistry{contract}
+
+	expected := []*common.DeviceGroup{new(common.DeviceGroup), new(common.DeviceGroup)}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetAll", "org1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetAll", "org2").Return(nil, errors.New(""))
+
+	actual, err := groupRegistry.GetAll("org1")
+	assert.Equal(s.T(), expected, actual, "should return correct device groups")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = groupRegistry.GetAll("org2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestMembership() {
+	contract := new(MockContract)
+	groupRegistry := &DeviceGroupRegistry{contract}
+
+	contract.On("SubmitTransaction", "Deregister", "org1", "group1").Return(nil, nil)
+	contract.On("SubmitTransaction", "Join", "org1", "group1").Return(nil, nil)
+	contract.On("SubmitTransaction", "AddMember", "org1", "group1", "org2", "device2").Return(nil, nil)
+	contract.On("SubmitTransaction", "RemoveMember", "org1", "group1", "org2", "device2").Return(nil, nil)
+	contract.On("SubmitTransaction", mock.Anything, "org2", "group2").Return(nil, errors.New(""))
+
+	assert.Nil(s.T(), groupRegistry.Deregister("org1", "group1"), "should return no error")
+	assert.Nil(s.T(), groupRegistry.Join("org1", "group1"), "should return no error")
+	assert.Nil(s.T(), groupRegistry.AddMember("org1", "group1", "org2", "device2"), "should return no error")
+	assert.Nil(s.T(), groupRegistry.RemoveMember("org1", "group1", "org2", "device2"), "should return no error")
+
+	assert.Error(s.T(), groupRegistry.Join("org2", "group2"), "should return error when sdk or smart contract fails")
+	assert.Error(s.T(), groupRegistry.Deregister("org2", "group2"), "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceGroupRegistryTestSuite) TestRegisterEvent() {
+	contract := new(MockContract)
+	groupRegistry := &DeviceGroupRegistry{contract}
+
+	eventChannel := make(chan *client.ChaincodeEvent)
+	go func() {
+		for i := 0; i < 3; i++ {
+			data, _ := (&common.DeviceGroup{OrganizationId: fmt.Sprintf("org%d", i), Name: fmt.Sprintf("group%d", i)}).Serialize()
+			eventChannel <- &client.ChaincodeEvent{
+				EventName: fmt.Sprintf("group://org%d/group%d/join", i, i),
+				Payload:   data,
+			}
+		}
+		eventChannel <- &client.ChaincodeEvent{EventName: "device://org1/device1/register", Payload: []byte("{}")}
+		data, _ := (&common.GroupServiceRequest{Id: "request1"}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{EventName: "group://org1/group1/request", Payload: data}
+	}()
+
+	var cancelFunc context.CancelFunc = func() {
+		close(eventChannel)
+	}
+
+	contract.On("RegisterEvent", mock.Anything).Return(eventChannel, cancelFunc, nil)
+
+	source, cancel, err := groupRegistry.RegisterEvent()
+	defer cancel()
+	assert.Nil(s.T(), err, "should return no error")
+
+	for i := 0; i < 3; i++ {
+		event := <-source
+		assert.Equal(s.T(), "join", event.Action, "should return correct action")
+		assert.Equal(s.T(), fmt.Sprintf("org%d", i), event.OrganizationId, "should return correct organization ID")
+		assert.Equal(s.T(), fmt.Sprintf("group%d", i), event.GroupName, "should return correct group name")
+		assert.IsType(s.T(), new(common.DeviceGroup), event.Payload, "should return parsed device group as event payload")
+	}
+
+	event := <-source
+	assert.Equal(s.T(), "request", event.Action, "should skip events of other entities")
+	assert.IsType(s.T(), new(common.GroupServiceRequest), event.Payload, "should return parsed group request as event payload")
+	assert.Equal(s.T(), "request1", event.Payload.(*common.GroupServiceRequest).Id, "should return correct event payload")
+
+	contract = new(MockContract)
+	groupRegistry = &DeviceGroupRegistry{contract}
+	contract.On("RegisterEvent", mock.Anything).Return(nil, nil, errors.New(""))
+
+	_, _, err = groupRegistry.RegisterEvent()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func TestDeviceGroupRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(DeviceGroupRegistryTestSuite))
+}
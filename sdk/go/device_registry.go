@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"regexp"
+	"strconv"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 	"github.com/nexus-lab/iot-service-blockchain/common"
@@ -13,6 +13,9 @@ import (
 
 // DeviceEvent an event emitted by the device registry contract notifying a device update
 type DeviceEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
 	// Action name of the action performed on the device
 	Action string
 
@@ -37,9 +40,70 @@ type DeviceRegistryInterface interface {
 	// GetAll return a list of devices by their organization ID
 	GetAll(organizationId string) ([]*common.Device, error)
 
+	// GetOrganizations return a list of organizations that have registered devices, with their device and service counts
+	GetOrganizations() ([]*common.OrganizationSummary, error)
+
+	// List return a page of devices across all organizations, starting from the bookmark of the previous page
+	List(pageSize int, bookmark string) (*common.DevicePage, error)
+
 	// Deregister remove a device from the ledger
 	Deregister(device *common.Device) error
 
+	// AuthorizeRekey allow a device to take over the identity of another device with a new certificate
+	AuthorizeRekey(organizationId string, deviceId string, newDeviceId string) error
+
+	// Rekey move the device that authorized the current identity to the current identity
+	Rekey() error
+
+	// Heartbeat report that the current identity is alive, with optional status fields
+	Heartbeat(status map[string]string) error
+
+	// Offline mark a device as offline, which other clients can only do after its heartbeat timeout
+	Offline(organizationId string, deviceId string) error
+
+	// GetPresence return the presence of a device by its organization ID and device ID
+	GetPresence(organizationId string, deviceId string) (*common.DevicePresence, error)
+
+	// GetAllByPresence return a list of devices by their organization ID and presence state, or all devices if the state is empty
+	GetAllByPresence(organizationId string, state common.DevicePresenceState) ([]*common.Device, error)
+
+	// GetTwin return the twin of a device by its organization ID and device ID
+	GetTwin(organizationId string, deviceId string) (*common.DeviceTwin, error)
+
+	// UpdateDesired merge the properties into the desired properties of a device twin, where properties with empty values are removed
+	UpdateDesired(organizationId string, deviceId string, properties map[string]string) error
+
+	// UpdateReported merge the properties into the reported properties of the current identity's twin, where properties with empty values are removed
+	UpdateReported(properties map[string]string) error
+
+	// SyncTwin pass the desired properties of a device that it has not reported to the handler, and report the properties
+	// returned by the handler as applied
+	SyncTwin(organizationId string, deviceId string, handler func(delta map[string]string) (map[string]string, error)) error
+
+	// AuthorizeEnrollment issue an enrollment allowing a device to register itself, which only organization
+	// administrators can do
+	AuthorizeEnrollment(enrollment *common.DeviceEnrollment) error
+
+	// GetEnrollment return a device enrollment by its organization ID and enrollment ID
+	GetEnrollment(organizationId string, enrollmentId string) (*common.DeviceEnrollment, error)
+
+	// GetEnrollments return a list of open and consumed device enrollments by their organization ID
+	GetEnrollments(organizationId string) ([]*common.DeviceEnrollment, error)
+
+	// Enroll register the current identity as a new device with the one-time claim code of its enrollment
+	Enroll(device *common.Device, claimCode string) error
+
+	// Revoke reject changes to the ledger by a device or one of its certificates and requests to the device, which
+	// only organization administrators can do
+	Revoke(revocation *common.DeviceRevocation) error
+
+	// Reinstate remove the revocation of a device or one of its certificates, which only organization administrators
+	// can do
+	Reinstate(organizationId string, deviceId string, serialNumber string) error
+
+	// GetRevocations return a list of device revocations by their organization ID
+	GetRevocations(organizationId string) ([]*common.DeviceRevocation, error)
+
 	// RegisterEvent registers for device registry events
 	RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *DeviceEvent, context.CancelFunc, error)
 }
@@ -89,6 +153,31 @@ func (r *DeviceRegistry) GetAll(organizationId string) ([]*common.Device, error)
 	return results, nil
 }
 
+// GetOrganizations return a list of organizations that have registered devices, with their device and service counts
+func (r *DeviceRegistry) GetOrganizations() ([]*common.OrganizationSummary, error) {
+	data, err := r.contract.SubmitTransaction("GetOrganizations")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.OrganizationSummary, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// List return a page of devices across all organizations, starting from the bookmark of the previous page
+func (r *DeviceRegistry) List(pageSize int, bookmark string) (*common.DevicePage, error) {
+	data, err := r.contract.SubmitTransaction("List", strconv.Itoa(pageSize), bookmark)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeDevicePage(data)
+}
+
 // Deregister remove a device from the ledger
 func (r *DeviceRegistry) Deregister(device *common.Device) error {
 	if device == nil {
@@ -104,36 +193,262 @@ func (r *DeviceRegistry) Deregister(device *common.Device) error {
 	return err
 }
 
+// AuthorizeRekey allow a device to take over the identity of another device with a new certificate
+func (r *DeviceRegistry) AuthorizeRekey(organizationId string, deviceId string, newDeviceId string) error {
+	_, err := r.contract.SubmitTransaction("AuthorizeRekey", organizationId, deviceId, newDeviceId)
+	return err
+}
+
+// Rekey move the device that authorized the current identity to the current identity
+func (r *DeviceRegistry) Rekey() error {
+	_, err := r.contract.SubmitTransaction("Rekey")
+	return err
+}
+
+// Heartbeat report that the current identity is alive, with optional status fields
+func (r *DeviceRegistry) Heartbeat(status map[string]string) error {
+	data := []byte{}
+	if len(status) > 0 {
+		var err error
+		if data, err = json.Marshal(status); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.contract.SubmitTransaction("Heartbeat", string(data))
+	return err
+}
+
+// Offline mark a device as offline, which other clients can only do after its heartbeat timeout
+func (r *DeviceRegistry) Offline(organizationId string, deviceId string) error {
+	_, err := r.contract.SubmitTransaction("Offline", organizationId, deviceId)
+	return err
+}
+
+// GetPresence return the presence of a device by its organization ID and device ID
+func (r *DeviceRegistry) GetPresence(organizationId string, deviceId string) (*common.DevicePresence, error) {
+	data, err := r.contract.SubmitTransaction("GetPresence", organizationId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeDevicePresence(data)
+}
+
+// GetAllByPresence return a list of devices by their organization ID and presence state, or all devices if the state is empty
+func (r *DeviceRegistry) GetAllByPresence(organizationId string, state common.DevicePresenceState) ([]*common.Device, error) {
+	data, err := r.contract.SubmitTransaction("GetAllByPresence", organizationId, string(state))
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.Device, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetTwin return the twin of a device by its organization ID and device ID
+func (r *DeviceRegistry) GetTwin(organizationId string, deviceId string) (*common.DeviceTwin, error) {
+	data, err := r.contract.SubmitTransaction("GetTwin", organizationId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeDeviceTwin(data)
+}
+
+// UpdateDesired merge the properties into the desired properties of a device twin, where properties with empty values are removed
+func (r *DeviceRegistry) UpdateDesired(organizationId string, deviceId string, properties map[string]string) error {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("UpdateDesired", organizationId, deviceId, string(data))
+	return err
+}
+
+// UpdateReported merge the properties into the reported properties of the current identity's twin, where properties with empty values are removed
+func (r *DeviceRegistry) UpdateReported(properties map[string]string) error {
+	data, err := json.Marshal(properties)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("UpdateReported", string(data))
+	return err
+}
+
+// SyncTwin pass the desired properties of a device that it has not reported to the handler, and report the properties
+// returned by the handler as applied
+func (r *DeviceRegistry) SyncTwin(organizationId string, deviceId string, handler func(delta map[string]string) (map[string]string, error)) error {
+	twin, err := r.GetTwin(organizationId, deviceId)
+	if err != nil {
+		return err
+	}
+
+	delta := twin.GetDelta()
+	if len(delta) == 0 {
+		return nil
+	}
+
+	applied, err := handler(delta)
+	if err != nil || len(applied) == 0 {
+		return err
+	}
+
+	return r.UpdateReported(applied)
+}
+
+// AuthorizeEnrollment issue an enrollment allowing a device to register itself, which only organization
+// administrators can do
+func (r *DeviceRegistry) AuthorizeEnrollment(enrollment *common.DeviceEnrollment) error {
+	if enrollment == nil {
+		return fmt.Errorf("cannot authorize an empty enrollment")
+	}
+
+	data, err := enrollment.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("AuthorizeEnrollment", string(data))
+	return err
+}
+
+// GetEnrollment return a device enrollment by its organization ID and enrollment ID
+func (r *DeviceRegistry) GetEnrollment(organizationId string, enrollmentId string) (*common.DeviceEnrollment, error) {
+	data, err := r.contract.SubmitTransaction("GetEnrollment", organizationId, enrollmentId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeDeviceEnrollment(data)
+}
+
+// GetEnrollments return a list of open and consumed device enrollments by their organization ID
+func (r *DeviceRegistry) GetEnrollments(organizationId string) ([]*common.DeviceEnrollment, error) {
+	data, err := r.contract.SubmitTransaction("GetEnrollments", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.DeviceEnrollment, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Enroll register the current identity as a new device with the one-time claim code of its enrollment
+func (r *DeviceRegistry) Enroll(device *common.Device, claimCode string) error {
+	if device == nil {
+		return fmt.Errorf("cannot enroll an empty device")
+	}
+
+	data, err := device.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Enroll", string(data), claimCode)
+	return err
+}
+
+// Revoke reject changes to the ledger by a device or one of its certificates and requests to the device, which only
+// organization administrators can do
+func (r *DeviceRegistry) Revoke(revocation *common.DeviceRevocation) error {
+	if revocation == nil {
+		return fmt.Errorf("cannot submit an empty revocation")
+	}
+
+	data, err := revocation.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Revoke", string(data))
+	return err
+}
+
+// Reinstate remove the revocation of a device or one of its certificates, which only organization administrators can do
+func (r *DeviceRegistry) Reinstate(organizationId string, deviceId string, serialNumber string) error {
+	_, err := r.contract.SubmitTransaction("Reinstate", organizationId, deviceId, serialNumber)
+	return err
+}
+
+// GetRevocations return a list of device revocations by their organization ID
+func (r *DeviceRegistry) GetRevocations(organizationId string) ([]*common.DeviceRevocation, error) {
+	data, err := r.contract.SubmitTransaction("GetRevocations", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.DeviceRevocation, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
 // RegisterEvent registers for device registry events
 func (r *DeviceRegistry) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *DeviceEvent, context.CancelFunc, error) {
 	dest := make(chan *DeviceEvent)
 	source, cancel, err := r.contract.RegisterEvent(options...)
-	pattern := regexp.MustCompile(`^device:\/\/(.+?)\/(.+?)\/(.+?)$`)
 
 	go func() {
 		defer close(dest)
 
-		for event := range source {
-			matches := pattern.FindStringSubmatch(event.EventName)
-			if len(matches) != 4 {
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityDevice {
 				continue
 			}
 
+			payload := event.GetLegacyPayload()
 			deviceEvent := &DeviceEvent{
-				OrganizationId: matches[1],
-				DeviceId:       matches[2],
-				Action:         matches[3],
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				DeviceId:       event.DeviceId,
+				Action:         event.Action,
 			}
 
-			if deviceEvent.Action == "register" || deviceEvent.Action == "deregister" {
-				device, err := common.DeserializeDevice(event.Payload)
+			if deviceEvent.Action == "register" || deviceEvent.Action == "deregister" || deviceEvent.Action == "rekey" {
+				device, err := common.DeserializeDevice(payload)
 				if err != nil {
-					log.Printf("bad device event payload %#v, action is %s\n", event.Payload, deviceEvent.Action)
+					log.Printf("bad device event payload %#v, action is %s\n", payload, deviceEvent.Action)
 					continue
 				}
 				deviceEvent.Payload = device
+			} else if deviceEvent.Action == "online" || deviceEvent.Action == "offline" {
+				presence, err := common.DeserializeDevicePresence(payload)
+				if err != nil {
+					log.Printf("bad device event payload %#v, action is %s\n", payload, deviceEvent.Action)
+					continue
+				}
+				deviceEvent.Payload = presence
+			} else if deviceEvent.Action == "desire" || deviceEvent.Action == "report" || deviceEvent.Action == "delta" {
+				twin, err := common.DeserializeDeviceTwin(payload)
+				if err != nil {
+					log.Printf("bad device event payload %#v, action is %s\n", payload, deviceEvent.Action)
+					continue
+				}
+				deviceEvent.Payload = twin
+			} else if deviceEvent.Action == "revoke" || deviceEvent.Action == "reinstate" {
+				revocation, err := common.DeserializeDeviceRevocation(payload)
+				if err != nil {
+					log.Printf("bad device event payload %#v, action is %s\n", payload, deviceEvent.Action)
+					continue
+				}
+				deviceEvent.Payload = revocation
+			} else if deviceEvent.Action == "authorize" {
+				deviceEvent.Payload = string(payload)
 			} else {
-				deviceEvent.Payload = event.Payload
+				deviceEvent.Payload = payload
 			}
 
 			dest <- deviceEvent
@@ -88,6 +88,42 @@ func (s *DeviceRegistryTestSuite) TestGetAll() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *DeviceRegistryTestSuite) TestGetOrganizations() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := []*common.OrganizationSummary{{OrganizationId: "org1", Devices: 2, Services: 3}}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetOrganizations").Return(data, nil).Once()
+
+	actual, err := deviceRegistry.GetOrganizations()
+	assert.Equal(s.T(), expected, actual, "should return correct organizations")
+	assert.Nil(s.T(), err, "should return no error")
+
+	contract.On("SubmitTransaction", "GetOrganizations").Return(nil, errors.New("")).Once()
+
+	_, err = deviceRegistry.GetOrganizations()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestList() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := &common.DevicePage{Devices: []*common.Device{{Id: "device1", OrganizationId: "org1"}}, Bookmark: "bookmark2"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "List", "10", "bookmark1").Return(data, nil)
+	contract.On("SubmitTransaction", "List", "10", "bookmark3").Return(nil, errors.New(""))
+
+	actual, err := deviceRegistry.List(10, "bookmark1")
+	assert.Equal(s.T(), expected.Bookmark, actual.Bookmark, "should return correct bookmark")
+	assert.Equal(s.T(), "device1", actual.Devices[0].Id, "should return correct devices")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.List(10, "bookmark3")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func (s *DeviceRegistryTestSuite) TestDeregister() {
 	contract := new(MockContract)
 	deviceRegistry := &DeviceRegistry{contract}
@@ -110,6 +146,279 @@ func (s *DeviceRegistryTestSuite) TestDeregister() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *DeviceRegistryTestSuite) TestAuthorizeRekey() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	contract.On("SubmitTransaction", "AuthorizeRekey", "org1", "device1", "device2").Return(nil, nil)
+	contract.On("SubmitTransaction", "AuthorizeRekey", mock.Anything, mock.Anything, mock.Anything).Return(nil, errors.New(""))
+
+	err := deviceRegistry.AuthorizeRekey("org1", "device1", "device2")
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = deviceRegistry.AuthorizeRekey("org1", "device1", "device3")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestRekey() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	contract.On("SubmitTransaction", "Rekey").Return(nil, nil).Once()
+	contract.On("SubmitTransaction", "Rekey").Return(nil, errors.New(""))
+
+	err := deviceRegistry.Rekey()
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = deviceRegistry.Rekey()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestHeartbeat() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	contract.On("SubmitTransaction", "Heartbeat", "{\"battery\":\"80\"}").Return(nil, nil)
+	contract.On("SubmitTransaction", "Heartbeat", "").Return(nil, errors.New(""))
+
+	err := deviceRegistry.Heartbeat(map[string]string{"battery": "80"})
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = deviceRegistry.Heartbeat(nil)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestOffline() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	contract.On("SubmitTransaction", "Offline", "org1", "device1").Return(nil, nil)
+	contract.On("SubmitTransaction", "Offline", "org2", "device2").Return(nil, errors.New(""))
+
+	err := deviceRegistry.Offline("org1", "device1")
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = deviceRegistry.Offline("org2", "device2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetPresence() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := &common.DevicePresence{OrganizationId: "org1", DeviceId: "device1", State: common.DevicePresenceOnline, Status: map[string]string{"battery": "80"}}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetPresence", "org1", "device1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetPresence", "org2", "device2").Return(nil, errors.New(""))
+
+	actual, err := deviceRegistry.GetPresence("org1", "device1")
+	assert.Equal(s.T(), expected.State, actual.State, "should return correct presence")
+	assert.Equal(s.T(), expected.Status, actual.Status, "should return correct presence")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.GetPresence("org2", "device2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetAllByPresence() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := []*common.Device{new(common.Device), new(common.Device)}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetAllByPresence", "org1", "online").Return(data, nil)
+	contract.On("SubmitTransaction", "GetAllByPresence", "org1", "offline").Return(nil, errors.New(""))
+
+	actual, err := deviceRegistry.GetAllByPresence("org1", common.DevicePresenceOnline)
+	assert.Equal(s.T(), expected, actual, "should return correct devices")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.GetAllByPresence("org1", common.DevicePresenceOffline)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetTwin() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := &common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Desired: map[string]string{"interval": "60"}, Version: 1}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetTwin", "org1", "device1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetTwin", "org2", "device2").Return(nil, errors.New(""))
+
+	actual, err := deviceRegistry.GetTwin("org1", "device1")
+	assert.Equal(s.T(), expected, actual, "should return correct twin")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.GetTwin("org2", "device2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestUpdateTwin() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	contract.On("SubmitTransaction", "UpdateDesired", "org1", "device1", "{\"interval\":\"60\"}").Return(nil, nil)
+	contract.On("SubmitTransaction", "UpdateReported", "{\"interval\":\"60\"}").Return(nil, nil)
+	contract.On("SubmitTransaction", mock.Anything, mock.Anything).Return(nil, errors.New(""))
+
+	assert.Nil(s.T(), deviceRegistry.UpdateDesired("org1", "device1", map[string]string{"interval": "60"}), "should return no error")
+	assert.Nil(s.T(), deviceRegistry.UpdateReported(map[string]string{"interval": "60"}), "should return no error")
+	assert.Error(s.T(), deviceRegistry.UpdateReported(map[string]string{"interval": "30"}), "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestSyncTwin() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	twin := &common.DeviceTwin{
+		OrganizationId: "org1",
+		DeviceId:       "device1",
+		Desired:        map[string]string{"interval": "60", "unit": "celsius", "mode": "eco"},
+		Reported:       map[string]string{"unit": "celsius"},
+	}
+	data, _ := twin.Serialize()
+	contract.On("SubmitTransaction", "GetTwin", "org1", "device1").Return(data, nil)
+	data, _ = (&common.DeviceTwin{OrganizationId: "org1", DeviceId: "device2"}).Serialize()
+	contract.On("SubmitTransaction", "GetTwin", "org1", "device2").Return(data, nil)
+	contract.On("SubmitTransaction", "UpdateReported", "{\"interval\":\"60\"}").Return(nil, nil)
+
+	var delta map[string]string
+	err := deviceRegistry.SyncTwin("org1", "device1", func(d map[string]string) (map[string]string, error) {
+		delta = d
+		return map[string]string{"interval": d["interval"]}, nil
+	})
+	assert.Nil(s.T(), err, "should return no error")
+	assert.Equal(s.T(), map[string]string{"interval": "60", "mode": "eco"}, delta, "should pass the delta to the handler")
+	called := contract.AssertCalled(s.T(), "SubmitTransaction", "UpdateReported", "{\"interval\":\"60\"}")
+	assert.True(s.T(), called, "should report the applied properties")
+
+	err = deviceRegistry.SyncTwin("org1", "device1", func(d map[string]string) (map[string]string, error) {
+		return nil, errors.New("")
+	})
+	assert.Error(s.T(), err, "should return handler error")
+
+	err = deviceRegistry.SyncTwin("org1", "device2", func(d map[string]string) (map[string]string, error) {
+		s.T().Error("should not call the handler without delta")
+		return nil, nil
+	})
+	assert.Nil(s.T(), err, "should return no error")
+}
+
+func (s *DeviceRegistryTestSuite) TestAuthorizeEnrollment() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	enrollment := &common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1", Subject: "CN=device1"}
+	data, _ := enrollment.Serialize()
+	contract.On("SubmitTransaction", "AuthorizeEnrollment", string(data)).Return(nil, nil)
+
+	err := deviceRegistry.AuthorizeEnrollment(enrollment)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = deviceRegistry.AuthorizeEnrollment(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	enrollment = &common.DeviceEnrollment{Id: "enrollment2", OrganizationId: "org2"}
+	data, _ = enrollment.Serialize()
+	contract.On("SubmitTransaction", "AuthorizeEnrollment", string(data)).Return(nil, errors.New(""))
+
+	err = deviceRegistry.AuthorizeEnrollment(enrollment)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetEnrollments() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := &common.DeviceEnrollment{Id: "enrollment1", OrganizationId: "org1", DeviceId: "device1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetEnrollment", "org1", "enrollment1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetEnrollment", "org1", "enrollment2").Return(nil, new(common.NotFoundError))
+	data, _ = json.Marshal([]*common.DeviceEnrollment{expected})
+	contract.On("SubmitTransaction", "GetEnrollments", "org1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetEnrollments", "org2").Return(nil, errors.New(""))
+
+	enrollment, err := deviceRegistry.GetEnrollment("org1", "enrollment1")
+	assert.Equal(s.T(), expected, enrollment, "should return correct enrollment")
+	assert.Nil(s.T(), err, "should return no error")
+
+	enrollment, err = deviceRegistry.GetEnrollment("org1", "enrollment2")
+	assert.Nil(s.T(), enrollment, "should return no enrollment")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+
+	enrollments, err := deviceRegistry.GetEnrollments("org1")
+	assert.Equal(s.T(), []*common.DeviceEnrollment{expected}, enrollments, "should return correct enrollments")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.GetEnrollments("org2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestEnroll() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	device := &common.Device{Name: "device1"}
+	data, _ := device.Serialize()
+	contract.On("SubmitTransaction", "Enroll", string(data), "code1").Return(nil, nil)
+	contract.On("SubmitTransaction", "Enroll", string(data), "code2").Return(nil, errors.New(""))
+
+	assert.Nil(s.T(), deviceRegistry.Enroll(device, "code1"), "should return no error")
+	assert.Error(s.T(), deviceRegistry.Enroll(nil, "code1"), "should return error if input is null")
+	assert.Error(s.T(), deviceRegistry.Enroll(device, "code2"), "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestRevoke() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	revocation := &common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1"}
+	data, _ := revocation.Serialize()
+	contract.On("SubmitTransaction", "Revoke", string(data)).Return(nil, nil)
+
+	err := deviceRegistry.Revoke(revocation)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = deviceRegistry.Revoke(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	revocation = &common.DeviceRevocation{OrganizationId: "org2", DeviceId: "device2"}
+	data, _ = revocation.Serialize()
+	contract.On("SubmitTransaction", "Revoke", string(data)).Return(nil, errors.New(""))
+
+	err = deviceRegistry.Revoke(revocation)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestReinstate() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	contract.On("SubmitTransaction", "Reinstate", "org1", "device1", "1a").Return(nil, nil)
+	contract.On("SubmitTransaction", "Reinstate", "org1", "device2", "").Return(nil, errors.New(""))
+
+	assert.Nil(s.T(), deviceRegistry.Reinstate("org1", "device1", "1a"), "should return no error")
+	assert.Error(s.T(), deviceRegistry.Reinstate("org1", "device2", ""), "should return error when sdk or smart contract fails")
+}
+
+func (s *DeviceRegistryTestSuite) TestGetRevocations() {
+	contract := new(MockContract)
+	deviceRegistry := &DeviceRegistry{contract}
+
+	expected := []*common.DeviceRevocation{{OrganizationId: "org1", DeviceId: "device1", SerialNumber: "1a"}}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetRevocations", "org1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetRevocations", "org2").Return(nil, errors.New(""))
+
+	revocations, err := deviceRegistry.GetRevocations("org1")
+	assert.Equal(s.T(), expected, revocations, "should return correct revocations")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = deviceRegistry.GetRevocations("org2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func (s *DeviceRegistryTestSuite) TestRegisterEvent() {
 	contract := new(MockContract)
 	deviceRegistry := &DeviceRegistry{contract}
@@ -123,6 +432,30 @@ func (s *DeviceRegistryTestSuite) TestRegisterEvent() {
 				Payload:   data,
 			}
 		}
+		eventChannel <- &client.ChaincodeEvent{
+			EventName: "device://org1/device1/authorize",
+			Payload:   []byte("device9"),
+		}
+		data, _ := (&common.Device{Id: "device9"}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{
+			EventName: "device://org1/device1/rekey",
+			Payload:   data,
+		}
+		data, _ = (&common.DevicePresence{OrganizationId: "org1", DeviceId: "device1", State: common.DevicePresenceOnline}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{
+			EventName: "device://org1/device1/online",
+			Payload:   data,
+		}
+		data, _ = (&common.DeviceTwin{OrganizationId: "org1", DeviceId: "device1", Desired: map[string]string{"interval": "60"}}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{
+			EventName: "device://org1/device1/delta",
+			Payload:   data,
+		}
+		data, _ = (&common.DeviceRevocation{OrganizationId: "org1", DeviceId: "device1", Reason: "stolen"}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{
+			EventName: "device://org1/device1/revoke",
+			Payload:   data,
+		}
 	}()
 
 	var cancelFunc context.CancelFunc = func() {
@@ -145,6 +478,26 @@ func (s *DeviceRegistryTestSuite) TestRegisterEvent() {
 		assert.Equal(s.T(), fmt.Sprintf("device%d", i), event.Payload.(*common.Device).Name, "should return correct event payload")
 	}
 
+	event := <-source
+	assert.Equal(s.T(), "authorize", event.Action, "should return correct action")
+	assert.Equal(s.T(), "device9", event.Payload, "should return new device ID as event payload")
+
+	event = <-source
+	assert.Equal(s.T(), "rekey", event.Action, "should return correct action")
+	assert.Equal(s.T(), "device9", event.Payload.(*common.Device).Id, "should return parsed device as event payload")
+
+	event = <-source
+	assert.Equal(s.T(), "online", event.Action, "should return correct action")
+	assert.Equal(s.T(), common.DevicePresenceOnline, event.Payload.(*common.DevicePresence).State, "should return parsed device presence as event payload")
+
+	event = <-source
+	assert.Equal(s.T(), "delta", event.Action, "should return correct action")
+	assert.Equal(s.T(), map[string]string{"interval": "60"}, event.Payload.(*common.DeviceTwin).GetDelta(), "should return parsed device twin as event payload")
+
+	event = <-source
+	assert.Equal(s.T(), "revoke", event.Action, "should return correct action")
+	assert.Equal(s.T(), "stolen", event.Payload.(*common.DeviceRevocation).Reason, "should return parsed device revocation as event payload")
+
 	contract = new(MockContract)
 	deviceRegistry = &DeviceRegistry{contract}
 	contract.On("RegisterEvent", mock.Anything).Return(nil, nil, errors.New(""))
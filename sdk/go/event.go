@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// EventMetadata details of the transaction that emitted an event
+type EventMetadata struct {
+	// Version version of the event envelope, zero if the event is emitted in the legacy format
+	Version int
+
+	// TransactionId ID of the transaction that emitted the event
+	TransactionId string
+
+	// Timestamp time at which the transaction was created, not available in the legacy format
+	Timestamp time.Time
+
+	// ActorOrganizationId organization ID of the client that submitted the transaction, not available in the legacy format
+	ActorOrganizationId string
+
+	// ActorId ID of the client that submitted the transaction, not available in the legacy format
+	ActorId string
+}
+
+// parsedEvent a change parsed from a chaincode event, together with the details of its transaction
+type parsedEvent struct {
+	*common.Event
+	EventMetadata
+}
+
+// parseEvents parse the individual changes from legacy URL-style events, legacy composite events and versioned
+// event envelopes of the source
+func parseEvents(source <-chan *client.ChaincodeEvent) <-chan *parsedEvent {
+	dest := make(chan *parsedEvent)
+
+	go func() {
+		defer close(dest)
+
+		for event := range source {
+			metadata := EventMetadata{TransactionId: event.TransactionID}
+
+			if strings.HasPrefix(event.EventName, "event://v") {
+				envelope, err := common.DeserializeEventEnvelope(event.Payload)
+				if err != nil {
+					log.Printf("bad event envelope payload %#v\n", event.Payload)
+					continue
+				}
+
+				metadata = EventMetadata{
+					Version:             envelope.Version,
+					TransactionId:       envelope.TransactionId,
+					Timestamp:           envelope.Timestamp,
+					ActorOrganizationId: envelope.ActorOrganizationId,
+					ActorId:             envelope.ActorId,
+				}
+				for _, change := range envelope.Events {
+					dest <- &parsedEvent{Event: change, EventMetadata: metadata}
+				}
+				continue
+			}
+
+			changes := []*common.ChangeEvent{{Name: event.EventName, Payload: event.Payload}}
+			if common.IsCompositeEvent(event.EventName) {
+				composite, err := common.DeserializeCompositeEvent(event.Payload)
+				if err != nil {
+					log.Printf("bad composite event payload %#v\n", event.Payload)
+					continue
+				}
+				changes = composite.Events
+			}
+
+			for _, change := range changes {
+				parsed, err := common.ParseLegacyEvent(change.Name, change.Payload)
+				if err != nil {
+					continue
+				}
+				dest <- &parsedEvent{Event: parsed, EventMetadata: metadata}
+			}
+		}
+	}()
+
+	return dest
+}
@@ -0,0 +1,70 @@
+package sdk
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type EventTestSuite struct {
+	suite.Suite
+}
+
+func (s *EventTestSuite) TestParseEvents() {
+	timestamp, _ := time.Parse(time.RFC3339, "2021-12-12T17:34:00-05:00")
+
+	source := make(chan *client.ChaincodeEvent)
+	go func() {
+		defer close(source)
+
+		source <- &client.ChaincodeEvent{TransactionID: "tx0", EventName: "device://org1/device1/authorize", Payload: []byte("device9")}
+		composite := &common.CompositeEvent{
+			TransactionId: "tx1",
+			Events: []*common.ChangeEvent{
+				{Name: "device://org1/device2/deregister", Payload: []byte("{}")},
+				{Name: "service://org1/device2/service1/deregister", Payload: []byte("{}")},
+			},
+		}
+		data, _ := composite.Serialize()
+		source <- &client.ChaincodeEvent{TransactionID: "tx1", EventName: composite.GetName(), Payload: data}
+		source <- &client.ChaincodeEvent{EventName: "composite://tx2", Payload: []byte("[]")}
+		envelope := &common.EventEnvelope{
+			Version:             common.EventEnvelopeVersion,
+			TransactionId:       "tx3",
+			Timestamp:           timestamp,
+			ActorOrganizationId: "org1",
+			ActorId:             "device3",
+			Events: []*common.Event{
+				{EntityType: common.EventEntityRequest, OrganizationId: "org1", DeviceId: "a/b+c=", ServiceName: "service1", RequestId: "request1", Action: "remove", Payload: json.RawMessage("\"request1\"")},
+			},
+		}
+		data, _ = envelope.Serialize()
+		source <- &client.ChaincodeEvent{TransactionID: "tx3", EventName: envelope.GetName(), Payload: data}
+		source <- &client.ChaincodeEvent{EventName: "event://v1", Payload: []byte("[]")}
+		source <- &client.ChaincodeEvent{EventName: "unknown://org1", Payload: []byte("")}
+	}()
+
+	events := make([]*parsedEvent, 0)
+	for event := range parseEvents(source) {
+		events = append(events, event)
+	}
+
+	assert.Equal(s.T(), 4, len(events), "should parse events and skip bad ones")
+	assert.Equal(s.T(), "device1", events[0].DeviceId, "should parse legacy events")
+	assert.Equal(s.T(), []byte("device9"), events[0].GetLegacyPayload(), "should parse legacy events with payload")
+	assert.Equal(s.T(), EventMetadata{TransactionId: "tx0"}, events[0].EventMetadata, "should parse legacy events with transaction ID")
+	assert.Equal(s.T(), "device2", events[1].DeviceId, "should emit the requested change of composite events first")
+	assert.Equal(s.T(), common.EventEntityService, events[2].EntityType, "should emit cascaded changes of composite events")
+	assert.Equal(s.T(), "tx1", events[2].TransactionId, "should keep transaction ID of composite events")
+	assert.Equal(s.T(), "a/b+c=", events[3].DeviceId, "should parse envelopes with identifiers containing slashes")
+	assert.Equal(s.T(), EventMetadata{Version: 1, TransactionId: "tx3", Timestamp: timestamp, ActorOrganizationId: "org1", ActorId: "device3"}, events[3].EventMetadata, "should parse envelopes with metadata")
+}
+
+func TestEventTestSuite(t *testing.T) {
+	suite.Run(t, new(EventTestSuite))
+}
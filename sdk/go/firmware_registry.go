@@ -0,0 +1,242 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// FirmwareEvent an event emitted by the firmware registry contract notifying a firmware release or campaign update
+type FirmwareEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
+	// Action name of the action performed on the firmware release or campaign
+	Action string
+
+	// OrganizationId organization ID of the firmware release or campaign
+	OrganizationId string
+
+	// FirmwareName name of the firmware, empty for campaign events
+	FirmwareName string
+
+	// CampaignId identity of the campaign, empty for firmware release events
+	CampaignId string
+
+	// Payload custom event payload
+	Payload interface{}
+}
+
+// FirmwareRegistryInterface core utilities for managing firmware releases and their update campaigns on the ledger
+type FirmwareRegistryInterface interface {
+	// Publish create a signed firmware release manifest in the ledger
+	Publish(release *common.FirmwareRelease) error
+
+	// Get return a firmware release by its organization ID, firmware name and version
+	Get(organizationId string, name string, version string) (*common.FirmwareRelease, error)
+
+	// GetAll return a list of firmware releases by their organization ID
+	GetAll(organizationId string) ([]*common.FirmwareRelease, error)
+
+	// CreateCampaign create a campaign updating devices of an organization to a firmware release
+	CreateCampaign(campaign *common.FirmwareCampaign) error
+
+	// GetCampaign return a firmware campaign by its ID
+	GetCampaign(campaignId string) (*common.FirmwareCampaign, error)
+
+	// GetUpdates return the updates of the target devices of a firmware campaign
+	GetUpdates(campaignId string) ([]*common.FirmwareUpdate, error)
+
+	// GetPending return the unfinished firmware update of a device
+	GetPending(organizationId string, deviceId string) (*common.FirmwareUpdate, error)
+
+	// FetchUpdate return the unfinished firmware update of a device together with its release manifest, after
+	// verifying the manifest signature and that it targets the device model
+	FetchUpdate(organizationId string, deviceId string, model string) (*common.FirmwareUpdate, *common.FirmwareRelease, error)
+
+	// Report record the status of the calling device's firmware update in a campaign
+	Report(campaignId string, status common.FirmwareUpdateStatus, message string) error
+
+	// RegisterEvent registers for firmware release and campaign events
+	RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *FirmwareEvent, context.CancelFunc, error)
+}
+
+// FirmwareRegistry core utilities for managing firmware releases and their update campaigns on the ledger
+type FirmwareRegistry struct {
+	contract ContractInterface
+}
+
+// Publish create a signed firmware release manifest in the ledger
+func (r *FirmwareRegistry) Publish(release *common.FirmwareRelease) error {
+	if release == nil {
+		return fmt.Errorf("cannot publish an empty firmware release")
+	}
+
+	data, err := release.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Publish", string(data))
+	return err
+}
+
+// Get return a firmware release by its organization ID, firmware name and version
+func (r *FirmwareRegistry) Get(organizationId string, name string, version string) (*common.FirmwareRelease, error) {
+	data, err := r.contract.SubmitTransaction("Get", organizationId, name, version)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeFirmwareRelease(data)
+}
+
+// GetAll return a list of firmware releases by their organization ID
+func (r *FirmwareRegistry) GetAll(organizationId string) ([]*common.FirmwareRelease, error) {
+	data, err := r.contract.SubmitTransaction("GetAll", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.FirmwareRelease, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CreateCampaign create a campaign updating devices of an organization to a firmware release
+func (r *FirmwareRegistry) CreateCampaign(campaign *common.FirmwareCampaign) error {
+	if campaign == nil {
+		return fmt.Errorf("cannot create an empty firmware campaign")
+	}
+
+	data, err := campaign.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("CreateCampaign", string(data))
+	return err
+}
+
+// GetCampaign return a firmware campaign by its ID
+func (r *FirmwareRegistry) GetCampaign(campaignId string) (*common.FirmwareCampaign, error) {
+	data, err := r.contract.SubmitTransaction("GetCampaign", campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeFirmwareCampaign(data)
+}
+
+// GetUpdates return the updates of the target devices of a firmware campaign
+func (r *FirmwareRegistry) GetUpdates(campaignId string) ([]*common.FirmwareUpdate, error) {
+	data, err := r.contract.SubmitTransaction("GetUpdates", campaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.FirmwareUpdate, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetPending return the unfinished firmware update of a device
+func (r *FirmwareRegistry) GetPending(organizationId string, deviceId string) (*common.FirmwareUpdate, error) {
+	data, err := r.contract.SubmitTransaction("GetPending", organizationId, deviceId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeFirmwareUpdate(data)
+}
+
+// FetchUpdate return the unfinished firmware update of a device together with its release manifest, after
+// verifying the manifest signature and that it targets the device model
+func (r *FirmwareRegistry) FetchUpdate(organizationId string, deviceId string, model string) (*common.FirmwareUpdate, *common.FirmwareRelease, error) {
+	update, err := r.GetPending(organizationId, deviceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	release, err := r.Get(update.FirmwareOrganizationId, update.FirmwareName, update.FirmwareVersion)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = release.Verify(); err != nil {
+		return nil, nil, err
+	}
+	if !release.SupportsModel(model) {
+		return nil, nil, fmt.Errorf("firmware release %s %s does not target model %q", release.Name, release.Version, model)
+	}
+
+	return update, release, nil
+}
+
+// Report record the status of the calling device's firmware update in a campaign
+func (r *FirmwareRegistry) Report(campaignId string, status common.FirmwareUpdateStatus, message string) error {
+	_, err := r.contract.SubmitTransaction("Report", campaignId, string(status), message)
+	return err
+}
+
+// RegisterEvent registers for firmware release and campaign events
+func (r *FirmwareRegistry) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *FirmwareEvent, context.CancelFunc, error) {
+	dest := make(chan *FirmwareEvent)
+	source, cancel, err := r.contract.RegisterEvent(options...)
+
+	go func() {
+		defer close(dest)
+
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityFirmware && event.EntityType != common.EventEntityCampaign {
+				continue
+			}
+
+			payload := event.GetLegacyPayload()
+			firmwareEvent := &FirmwareEvent{
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				FirmwareName:   event.FirmwareName,
+				CampaignId:     event.CampaignId,
+				Action:         event.Action,
+			}
+
+			var err error
+			if event.EntityType == common.EventEntityFirmware {
+				firmwareEvent.Payload, err = common.DeserializeFirmwareRelease(payload)
+			} else if firmwareEvent.Action == "create" {
+				firmwareEvent.Payload, err = common.DeserializeFirmwareCampaign(payload)
+			} else {
+				firmwareEvent.Payload, err = common.DeserializeFirmwareUpdate(payload)
+			}
+			if err != nil {
+				log.Printf("bad firmware event payload %#v, action is %s\n", payload, firmwareEvent.Action)
+				continue
+			}
+
+			dest <- firmwareEvent
+		}
+	}()
+
+	return dest, cancel, err
+}
+
+// CreateFirmwareRegistry the default factory for creating firmware registries
+func CreateFirmwareRegistry(network *client.Network, chaincodeId string) FirmwareRegistryInterface {
+	return &FirmwareRegistry{
+		contract: &Contract{
+			network:      network,
+			chaincodeId:  chaincodeId,
+			contractName: "firmware_registry",
+		},
+	}
+}
@@ -0,0 +1,290 @@
+package sdk
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type FirmwareRegistryTestSuite struct {
+	suite.Suite
+}
+
+// newTestFirmwareRelease create a firmware release manifest signed by a fresh key
+func newTestFirmwareRelease() *common.FirmwareRelease {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), Subject: pkix.Name{CommonName: "vendor1-admin"}}
+	cert, _ := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	release := &common.FirmwareRelease{
+		OrganizationId: "vendor1",
+		Name:           "firmware1",
+		Version:        "1.2.0",
+		Hash:           "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		Models:         []string{"model1", "model2"},
+		Location:       "https://example.com/firmware1-1.2.0.bin",
+		Certificate:    string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})),
+	}
+	release.Sign(func(digest []byte) ([]byte, error) {
+		return ecdsa.SignASN1(rand.Reader, key, digest)
+	})
+
+	return release
+}
+
+func (s *FirmwareRegistryTestSuite) TestPublish() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	release := &common.FirmwareRelease{OrganizationId: "vendor1", Name: "firmware1", Version: "1.2.0"}
+	data, _ := release.Serialize()
+	contract.On("SubmitTransaction", "Publish", string(data)).Return(nil, nil)
+
+	err := firmwareRegistry.Publish(release)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = firmwareRegistry.Publish(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	release = &common.FirmwareRelease{OrganizationId: "vendor2", Name: "firmware2", Version: "1.2.0"}
+	data, _ = release.Serialize()
+	contract.On("SubmitTransaction", "Publish", string(data)).Return(nil, errors.New(""))
+
+	err = firmwareRegistry.Publish(release)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGet() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	expected := &common.FirmwareRelease{OrganizationId: "vendor1", Name: "firmware1", Version: "1.2.0"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "Get", "vendor1", "firmware1", "1.2.0").Return(data, nil)
+	contract.On("SubmitTransaction", "Get", "vendor2", "firmware2", "1.2.0").Return(nil, new(common.NotFoundError))
+
+	actual, err := firmwareRegistry.Get("vendor1", "firmware1", "1.2.0")
+	assert.Equal(s.T(), expected, actual, "should return correct firmware release")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = firmwareRegistry.Get("vendor2", "firmware2", "1.2.0")
+	assert.Nil(s.T(), actual, "should return no firmware release")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetAll() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	expected := []*common.FirmwareRelease{new(common.FirmwareRelease), new(common.FirmwareRelease)}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetAll", "vendor1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetAll", "vendor2").Return(nil, errors.New(""))
+
+	actual, err := firmwareRegistry.GetAll("vendor1")
+	assert.Equal(s.T(), expected, actual, "should return correct firmware releases")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = firmwareRegistry.GetAll("vendor2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *FirmwareRegistryTestSuite) TestCreateCampaign() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	campaign := &common.FirmwareCampaign{Id: "campaign1", OrganizationId: "org1", DeviceIds: []string{"device1"}}
+	data, _ := campaign.Serialize()
+	contract.On("SubmitTransaction", "CreateCampaign", string(data)).Return(nil, nil)
+
+	err := firmwareRegistry.CreateCampaign(campaign)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = firmwareRegistry.CreateCampaign(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	campaign = &common.FirmwareCampaign{Id: "campaign2", OrganizationId: "org2"}
+	data, _ = campaign.Serialize()
+	contract.On("SubmitTransaction", "CreateCampaign", string(data)).Return(nil, errors.New(""))
+
+	err = firmwareRegistry.CreateCampaign(campaign)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetCampaign() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	expected := &common.FirmwareCampaign{Id: "campaign1", OrganizationId: "org1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetCampaign", "campaign1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetCampaign", "campaign2").Return(nil, new(common.NotFoundError))
+
+	actual, err := firmwareRegistry.GetCampaign("campaign1")
+	assert.Equal(s.T(), expected, actual, "should return correct firmware campaign")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = firmwareRegistry.GetCampaign("campaign2")
+	assert.Nil(s.T(), actual, "should return no firmware campaign")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetUpdates() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	expected := []*common.FirmwareUpdate{new(common.FirmwareUpdate), new(common.FirmwareUpdate)}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetUpdates", "campaign1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetUpdates", "campaign2").Return(nil, errors.New(""))
+
+	actual, err := firmwareRegistry.GetUpdates("campaign1")
+	assert.Equal(s.T(), expected, actual, "should return correct firmware updates")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = firmwareRegistry.GetUpdates("campaign2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *FirmwareRegistryTestSuite) TestGetPending() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	expected := &common.FirmwareUpdate{OrganizationId: "org1", DeviceId: "device1", CampaignId: "campaign1", Status: common.FirmwareUpdatePending}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetPending", "org1", "device1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetPending", "org1", "device2").Return(nil, new(common.NotFoundError))
+
+	actual, err := firmwareRegistry.GetPending("org1", "device1")
+	assert.Equal(s.T(), expected, actual, "should return correct firmware update")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = firmwareRegistry.GetPending("org1", "device2")
+	assert.Nil(s.T(), actual, "should return no firmware update")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestFetchUpdate() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	update := &common.FirmwareUpdate{
+		OrganizationId:         "org1",
+		DeviceId:               "device1",
+		CampaignId:             "campaign1",
+		FirmwareOrganizationId: "vendor1",
+		FirmwareName:           "firmware1",
+		FirmwareVersion:        "1.2.0",
+		Status:                 common.FirmwareUpdatePending,
+	}
+	data, _ := update.Serialize()
+	contract.On("SubmitTransaction", "GetPending", "org1", "device1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetPending", "org1", "device2").Return(nil, new(common.NotFoundError))
+
+	release := newTestFirmwareRelease()
+	data, _ = release.Serialize()
+	contract.On("SubmitTransaction", "Get", "vendor1", "firmware1", "1.2.0").Return(data, nil).Once()
+
+	actualUpdate, actualRelease, err := firmwareRegistry.FetchUpdate("org1", "device1", "model1")
+	assert.Equal(s.T(), update, actualUpdate, "should return the pending update")
+	assert.Equal(s.T(), release, actualRelease, "should return the release of the update")
+	assert.Nil(s.T(), err, "should return no error")
+
+	contract.On("SubmitTransaction", "Get", "vendor1", "firmware1", "1.2.0").Return(data, nil).Once()
+	_, _, err = firmwareRegistry.FetchUpdate("org1", "device1", "model3")
+	assert.Error(s.T(), err, "should refuse release not targeting the device model")
+
+	release.Location = "https://example.com/tampered.bin"
+	data, _ = release.Serialize()
+	contract.On("SubmitTransaction", "Get", "vendor1", "firmware1", "1.2.0").Return(data, nil).Once()
+	_, _, err = firmwareRegistry.FetchUpdate("org1", "device1", "model1")
+	assert.Error(s.T(), err, "should refuse release whose signature does not match")
+
+	_, _, err = firmwareRegistry.FetchUpdate("org1", "device2", "model1")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *FirmwareRegistryTestSuite) TestReport() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	contract.On("SubmitTransaction", "Report", "campaign1", "installing", "").Return(nil, nil)
+	contract.On("SubmitTransaction", "Report", "campaign2", "failed", "out of space").Return(nil, errors.New(""))
+
+	assert.Nil(s.T(), firmwareRegistry.Report("campaign1", common.FirmwareUpdateInstalling, ""), "should return no error")
+	assert.Error(s.T(), firmwareRegistry.Report("campaign2", common.FirmwareUpdateFailed, "out of space"), "should return error when sdk or smart contract fails")
+}
+
+func (s *FirmwareRegistryTestSuite) TestRegisterEvent() {
+	contract := new(MockContract)
+	firmwareRegistry := &FirmwareRegistry{contract}
+
+	eventChannel := make(chan *client.ChaincodeEvent)
+	go func() {
+		for i := 0; i < 3; i++ {
+			data, _ := (&common.FirmwareUpdate{OrganizationId: fmt.Sprintf("org%d", i), CampaignId: fmt.Sprintf("campaign%d", i)}).Serialize()
+			eventChannel <- &client.ChaincodeEvent{
+				EventName: fmt.Sprintf("campaign://org%d/campaign%d/report", i, i),
+				Payload:   data,
+			}
+		}
+		eventChannel <- &client.ChaincodeEvent{EventName: "device://org1/device1/register", Payload: []byte("{}")}
+		data, _ := (&common.FirmwareCampaign{Id: "campaign1", OrganizationId: "org1"}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{EventName: "campaign://org1/campaign1/create", Payload: data}
+		data, _ = (&common.FirmwareRelease{OrganizationId: "vendor1", Name: "firmware1"}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{EventName: "firmware://vendor1/firmware1/publish", Payload: data}
+	}()
+
+	var cancelFunc context.CancelFunc = func() {
+		close(eventChannel)
+	}
+
+	contract.On("RegisterEvent", mock.Anything).Return(eventChannel, cancelFunc, nil)
+
+	source, cancel, err := firmwareRegistry.RegisterEvent()
+	defer cancel()
+	assert.Nil(s.T(), err, "should return no error")
+
+	for i := 0; i < 3; i++ {
+		event := <-source
+		assert.Equal(s.T(), "report", event.Action, "should return correct action")
+		assert.Equal(s.T(), fmt.Sprintf("org%d", i), event.OrganizationId, "should return correct organization ID")
+		assert.Equal(s.T(), fmt.Sprintf("campaign%d", i), event.CampaignId, "should return correct campaign ID")
+		assert.IsType(s.T(), new(common.FirmwareUpdate), event.Payload, "should return parsed firmware update as event payload")
+	}
+
+	event := <-source
+	assert.Equal(s.T(), "create", event.Action, "should skip events of other entities")
+	assert.IsType(s.T(), new(common.FirmwareCampaign), event.Payload, "should return parsed firmware campaign as event payload")
+
+	event = <-source
+	assert.Equal(s.T(), "publish", event.Action, "should return correct action")
+	assert.Equal(s.T(), "firmware1", event.FirmwareName, "should return correct firmware name")
+	assert.IsType(s.T(), new(common.FirmwareRelease), event.Payload, "should return parsed firmware release as event payload")
+
+	contract = new(MockContract)
+	firmwareRegistry = &FirmwareRegistry{contract}
+	contract.On("RegisterEvent", mock.Anything).Return(nil, nil, errors.New(""))
+
+	_, _, err = firmwareRegistry.RegisterEvent()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func TestFirmwareRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(FirmwareRegistryTestSuite))
+}
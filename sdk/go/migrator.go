@@ -0,0 +1,77 @@
+package sdk
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// CompatibleSchemaVersion schema version of the ledger state understood by this SDK
+const CompatibleSchemaVersion = 1
+
+// MigratorInterface core utilities for migrating the ledger state after chaincode upgrades
+type MigratorInterface interface {
+	// GetVersion return the schema version of the ledger state and the version expected by the deployed chaincode
+	GetVersion() (*common.SchemaVersion, error)
+
+	// Migrate apply the next chunk of the pending migrations as an organization administrator
+	Migrate(chunkSize int) (*common.SchemaVersion, error)
+
+	// CheckCompatibility check if the deployed chaincode and its ledger state are compatible with this SDK
+	CheckCompatibility() error
+}
+
+// Migrator core utilities for migrating the ledger state after chaincode upgrades
+type Migrator struct {
+	contract ContractInterface
+}
+
+// GetVersion return the schema version of the ledger state and the version expected by the deployed chaincode
+func (m *Migrator) GetVersion() (*common.SchemaVersion, error) {
+	data, err := m.contract.SubmitTransaction("GetVersion")
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeSchemaVersion(data)
+}
+
+// Migrate apply the next chunk of the pending migrations as an organization administrator
+func (m *Migrator) Migrate(chunkSize int) (*common.SchemaVersion, error) {
+	data, err := m.contract.SubmitTransaction("Migrate", strconv.Itoa(chunkSize))
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeSchemaVersion(data)
+}
+
+// CheckCompatibility check if the deployed chaincode and its ledger state are compatible with this SDK
+func (m *Migrator) CheckCompatibility() error {
+	version, err := m.GetVersion()
+	if err != nil {
+		return err
+	}
+
+	if version.LatestVersion != CompatibleSchemaVersion {
+		return fmt.Errorf("chaincode schema version %d is incompatible with SDK schema version %d", version.LatestVersion, CompatibleSchemaVersion)
+	}
+	if !version.IsMigrated() {
+		return fmt.Errorf("ledger state of schema version %d has not been migrated to version %d", version.Version, version.LatestVersion)
+	}
+
+	return nil
+}
+
+// CreateMigrator the default factory for creating migrators
+func CreateMigrator(network *client.Network, chaincodeId string) MigratorInterface {
+	return &Migrator{
+		contract: &Contract{
+			network:      network,
+			chaincodeId:  chaincodeId,
+			contractName: "migration",
+		},
+	}
+}
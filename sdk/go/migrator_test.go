@@ -0,0 +1,76 @@
+package sdk
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigratorTestSuite struct {
+	suite.Suite
+}
+
+func (s *MigratorTestSuite) TestGetVersion() {
+	contract := new(MockContract)
+	migrator := &Migrator{contract}
+
+	expected := &common.SchemaVersion{Version: 1, LatestVersion: 2}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetVersion").Return(data, nil).Once()
+	contract.On("SubmitTransaction", "GetVersion").Return(nil, errors.New("")).Once()
+
+	actual, err := migrator.GetVersion()
+	assert.Equal(s.T(), expected, actual, "should return correct schema version")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = migrator.GetVersion()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *MigratorTestSuite) TestMigrate() {
+	contract := new(MockContract)
+	migrator := &Migrator{contract}
+
+	expected := &common.SchemaVersion{Version: 0, LatestVersion: 1, Bookmark: "b1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "Migrate", "10").Return(data, nil)
+	contract.On("SubmitTransaction", "Migrate", "20").Return(nil, errors.New(""))
+
+	actual, err := migrator.Migrate(10)
+	assert.Equal(s.T(), expected, actual, "should return correct schema version")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = migrator.Migrate(20)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *MigratorTestSuite) TestCheckCompatibility() {
+	contract := new(MockContract)
+	migrator := &Migrator{contract}
+
+	versions := []*common.SchemaVersion{
+		{Version: CompatibleSchemaVersion, LatestVersion: CompatibleSchemaVersion},
+		{Version: CompatibleSchemaVersion, LatestVersion: CompatibleSchemaVersion + 1},
+	}
+	for _, version := range versions {
+		data, _ := version.Serialize()
+		contract.On("SubmitTransaction", "GetVersion").Return(data, nil).Once()
+	}
+	contract.On("SubmitTransaction", "GetVersion").Return(nil, errors.New("")).Once()
+
+	err := migrator.CheckCompatibility()
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = migrator.CheckCompatibility()
+	assert.Error(s.T(), err, "should return error on incompatible chaincode")
+
+	err = migrator.CheckCompatibility()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func TestMigratorTestSuite(t *testing.T) {
+	suite.Run(t, new(MigratorTestSuite))
+}
@@ -2,6 +2,7 @@ package sdk
 
 import (
 	"crypto/x509"
+	"fmt"
 	"time"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
@@ -14,13 +15,18 @@ import (
 
 // Sdk the iot service blockchain sdk
 type Sdk struct {
-	grpcConnection  *grpc.ClientConn
-	gw              *client.Gateway
-	organizationId  string
-	deviceId        string
-	deviceRegistry  DeviceRegistryInterface
-	serviceRegistry ServiceRegistryInterface
-	serviceBroker   ServiceBrokerInterface
+	grpcConnection   *grpc.ClientConn
+	gw               *client.Gateway
+	organizationId   string
+	deviceId         string
+	deviceRegistry   DeviceRegistryInterface
+	serviceRegistry  ServiceRegistryInterface
+	serviceBroker    ServiceBrokerInterface
+	accountLedger    AccountLedgerInterface
+	groupRegistry    DeviceGroupRegistryInterface
+	workflowRegistry WorkflowRegistryInterface
+	firmwareRegistry FirmwareRegistryInterface
+	migrator         MigratorInterface
 }
 
 // SdkOptions SDK initialization options
@@ -166,6 +172,11 @@ func (s *Sdk) connectSmartContracts(network *client.Network, chaincodeId string)
 	s.deviceRegistry = CreateDeviceRegistry(network, chaincodeId)
 	s.serviceRegistry = CreateServiceRegistry(network, chaincodeId)
 	s.serviceBroker = CreateServiceBroker(network, chaincodeId)
+	s.accountLedger = CreateAccountLedger(network, chaincodeId)
+	s.groupRegistry = CreateDeviceGroupRegistry(network, chaincodeId)
+	s.workflowRegistry = CreateWorkflowRegistry(network, chaincodeId)
+	s.firmwareRegistry = CreateFirmwareRegistry(network, chaincodeId)
+	s.migrator = CreateMigrator(network, chaincodeId)
 }
 
 // GetDeviceId return the device/client ID of the current calling application
@@ -193,6 +204,47 @@ func (s *Sdk) GetServiceBroker() ServiceBrokerInterface {
 	return s.serviceBroker
 }
 
+// GetAccountLedger return the account ledger
+func (s *Sdk) GetAccountLedger() AccountLedgerInterface {
+	return s.accountLedger
+}
+
+// GetRemoteServiceBroker return the service broker of another channel or chaincode, through which devices receive and
+// respond to the requests made to their services from there
+func (s *Sdk) GetRemoteServiceBroker(networkName string, chaincodeId string) ServiceBrokerInterface {
+	return CreateServiceBroker(s.gw.GetNetwork(networkName), chaincodeId)
+}
+
+// RespondRemote respond to a request made from another channel or chaincode, submitting the response on the channel
+// of the request and relaying it on the current channel so that the quality metrics of the service count it
+func (s *Sdk) RespondRemote(networkName string, chaincodeId string, response *common.ServiceResponse) error {
+	if response == nil {
+		return fmt.Errorf("cannot send an empty response")
+	}
+
+	return respondRemote(s.GetRemoteServiceBroker(networkName, chaincodeId), s.serviceBroker, networkName, chaincodeId, response)
+}
+
+// GetDeviceGroupRegistry return the device group registry
+func (s *Sdk) GetDeviceGroupRegistry() DeviceGroupRegistryInterface {
+	return s.groupRegistry
+}
+
+// GetWorkflowRegistry return the workflow registry
+func (s *Sdk) GetWorkflowRegistry() WorkflowRegistryInterface {
+	return s.workflowRegistry
+}
+
+// GetFirmwareRegistry return the firmware registry
+func (s *Sdk) GetFirmwareRegistry() FirmwareRegistryInterface {
+	return s.firmwareRegistry
+}
+
+// GetMigrator return the migrator
+func (s *Sdk) GetMigrator() MigratorInterface {
+	return s.migrator
+}
+
 // Close close connection to the Hyperledger Fabric gateway
 func (s *Sdk) Close() error {
 	if s.gw != nil {
@@ -5,7 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"regexp"
+	"strconv"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 	"github.com/nexus-lab/iot-service-blockchain/common"
@@ -13,6 +13,9 @@ import (
 
 // ServiceRequestEvent an event emitted by the service broker contract notifying a service request/response update
 type ServiceRequestEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
 	// Action name of the action performed on the service request
 	Action string
 
@@ -32,25 +35,88 @@ type ServiceRequestEvent struct {
 	Payload interface{}
 }
 
+// StreamEvent an event emitted by the service broker contract carrying a message published on a service stream
+type StreamEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
+	// OrganizationId organization ID of the publishing service
+	OrganizationId string
+
+	// DeviceId device ID of the publishing service
+	DeviceId string
+
+	// ServiceName name of the publishing service
+	ServiceName string
+
+	// Topic topic of the stream
+	Topic string
+
+	// Sequence sequence number of the message within its stream
+	Sequence int64
+
+	// Message published message
+	Message *common.StreamMessage
+}
+
 // ServiceBrokerInterface core utilities for managing service requests on ledger
 type ServiceBrokerInterface interface {
 	// Request make a request to an IoT service
 	Request(request *common.ServiceRequest) error
 
+	// RequestGroup make a request to an IoT service of every member device of a device group
+	RequestGroup(request *common.GroupServiceRequest) error
+
+	// GetGroupRequest return a group request and the requests made to the member devices with their responses
+	GetGroupRequest(requestId string) (*common.GroupServiceRequestResult, error)
+
 	// Respond respond to an IoT service request
 	Respond(response *common.ServiceResponse) error
 
+	// Accept acknowledge an IoT service request as the requested device
+	Accept(requestId string) error
+
+	// Progress report that the requested device is working on an IoT service request
+	Progress(requestId string) error
+
+	// Reject refuse to handle an IoT service request as the requested device
+	Reject(requestId string) error
+
+	// Cancel withdraw an IoT service request that has not been responded to
+	Cancel(requestId string) error
+
+	// Expire close an IoT service request that has not been responded to before its timeout
+	Expire(requestId string) error
+
+	// Rate give a rating to a completed IoT service request as its requester
+	Rate(requestId string, rating int) error
+
 	// Get return an IoT service request and its response (if any) by the request ID
 	Get(requestId string) (*common.ServiceRequestResponse, error)
 
 	// GetAll return a list of IoT service requests and their responses (if any) by their service organization ID, service device ID, and service name
 	GetAll(organizationId string, deviceId string, serviceName string) ([]*common.ServiceRequestResponse, error)
 
+	// GetMyRequests return a list of IoT service requests made by the current client and their responses (if any)
+	GetMyRequests() ([]*common.ServiceRequestResponse, error)
+
+	// GetQuota return the remaining quota of the current client on an IoT service
+	GetQuota(organizationId string, deviceId string, serviceName string) (*common.ServiceQuotaStatus, error)
+
 	// Remove remove a service request and its response (if any) from the ledger
 	Remove(requestId string) error
 
 	// RegisterEvent registers for service request events
 	RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *ServiceRequestEvent, context.CancelFunc, error)
+
+	// Publish publish a telemetry message on a stream of an IoT service of the current device
+	Publish(message *common.StreamMessage) error
+
+	// Subscribe registers for the messages published on service streams, empty filters match any value
+	Subscribe(organizationId string, deviceId string, serviceName string, topic string, options ...client.ChaincodeEventsOption) (<-chan *StreamEvent, context.CancelFunc, error)
+
+	// Relay count in the quality metrics of an IoT service the progress of a request made to it from another channel or chaincode
+	Relay(channelId string, chaincodeId string, requestId string) error
 }
 
 // ServiceBroker core utilities for managing IoT service requests and responses on the ledger
@@ -73,6 +139,31 @@ func (r *ServiceBroker) Request(request *common.ServiceRequest) error {
 	return err
 }
 
+// RequestGroup make a request to an IoT service of every member device of a device group
+func (r *ServiceBroker) RequestGroup(request *common.GroupServiceRequest) error {
+	if request == nil {
+		return fmt.Errorf("cannot send an empty group request")
+	}
+
+	data, err := request.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("RequestGroup", string(data))
+	return err
+}
+
+// GetGroupRequest return a group request and the requests made to the member devices with their responses
+func (r *ServiceBroker) GetGroupRequest(requestId string) (*common.GroupServiceRequestResult, error) {
+	data, err := r.contract.SubmitTransaction("GetGroupRequest", requestId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeGroupServiceRequestResult(data)
+}
+
 // Respond respond to an IoT service request
 func (r *ServiceBroker) Respond(response *common.ServiceResponse) error {
 	if response == nil {
@@ -88,6 +179,42 @@ func (r *ServiceBroker) Respond(response *common.ServiceResponse) error {
 	return err
 }
 
+// Accept acknowledge an IoT service request as the requested device
+func (r *ServiceBroker) Accept(requestId string) error {
+	_, err := r.contract.SubmitTransaction("Accept", requestId)
+	return err
+}
+
+// Progress report that the requested device is working on an IoT service request
+func (r *ServiceBroker) Progress(requestId string) error {
+	_, err := r.contract.SubmitTransaction("Progress", requestId)
+	return err
+}
+
+// Reject refuse to handle an IoT service request as the requested device
+func (r *ServiceBroker) Reject(requestId string) error {
+	_, err := r.contract.SubmitTransaction("Reject", requestId)
+	return err
+}
+
+// Cancel withdraw an IoT service request that has not been responded to
+func (r *ServiceBroker) Cancel(requestId string) error {
+	_, err := r.contract.SubmitTransaction("Cancel", requestId)
+	return err
+}
+
+// Expire close an IoT service request that has not been responded to before its timeout
+func (r *ServiceBroker) Expire(requestId string) error {
+	_, err := r.contract.SubmitTransaction("Expire", requestId)
+	return err
+}
+
+// Rate give a rating to a completed IoT service request as its requester
+func (r *ServiceBroker) Rate(requestId string, rating int) error {
+	_, err := r.contract.SubmitTransaction("Rate", requestId, strconv.Itoa(rating))
+	return err
+}
+
 // Get return an IoT service request and its response by the request ID
 func (r *ServiceBroker) Get(requestId string) (*common.ServiceRequestResponse, error) {
 	data, err := r.contract.SubmitTransaction("Get", requestId)
@@ -113,53 +240,87 @@ func (r *ServiceBroker) GetAll(organizationId string, deviceId string, serviceNa
 	return results, nil
 }
 
+// GetMyRequests return a list of IoT service requests made by the current client and their responses
+func (r *ServiceBroker) GetMyRequests() ([]*common.ServiceRequestResponse, error) {
+	data, err := r.contract.SubmitTransaction("GetMyRequests")
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.ServiceRequestResponse, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// GetQuota return the remaining quota of the current client on an IoT service
+func (r *ServiceBroker) GetQuota(organizationId string, deviceId string, serviceName string) (*common.ServiceQuotaStatus, error) {
+	data, err := r.contract.SubmitTransaction("GetQuota", organizationId, deviceId, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeServiceQuotaStatus(data)
+}
+
 // Remove remove a (request, response) pair from the ledger
 func (r *ServiceBroker) Remove(requestId string) error {
 	_, err := r.contract.SubmitTransaction("Remove", requestId)
 	return err
 }
 
+// isServiceRequestAction check if the payload of an event with the action is a service request
+func isServiceRequestAction(action string) bool {
+	switch action {
+	case "request", "accept", "progress", "reject", "cancel", "expire", "rate":
+		return true
+	}
+	return false
+}
+
 // RegisterEvent registers for service request events
 func (r *ServiceBroker) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *ServiceRequestEvent, context.CancelFunc, error) {
 	dest := make(chan *ServiceRequestEvent)
 	source, cancel, err := r.contract.RegisterEvent(options...)
-	pattern := regexp.MustCompile(`^request:\/\/(.+?)\/(.+?)\/(.+?)\/(.+?)\/(.+?)$`)
 
 	go func() {
 		defer close(dest)
 
-		for event := range source {
-			matches := pattern.FindStringSubmatch(event.EventName)
-			if len(matches) != 6 {
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityRequest {
 				continue
 			}
 
+			payload := event.GetLegacyPayload()
 			serviceRequestEvent := &ServiceRequestEvent{
-				OrganizationId: matches[1],
-				DeviceId:       matches[2],
-				ServiceName:    matches[3],
-				RequestId:      matches[4],
-				Action:         matches[5],
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				DeviceId:       event.DeviceId,
+				ServiceName:    event.ServiceName,
+				RequestId:      event.RequestId,
+				Action:         event.Action,
 			}
 
-			if serviceRequestEvent.Action == "request" {
-				request, err := common.DeserializeServiceRequest(event.Payload)
+			if isServiceRequestAction(serviceRequestEvent.Action) {
+				request, err := common.DeserializeServiceRequest(payload)
 				if err != nil {
-					log.Printf("bad service request event payload %#v, action is %s\n", event.Payload, serviceRequestEvent.Action)
+					log.Printf("bad service request event payload %#v, action is %s\n", payload, serviceRequestEvent.Action)
 					continue
 				}
 				serviceRequestEvent.Payload = request
 			} else if serviceRequestEvent.Action == "respond" {
-				response, err := common.DeserializeServiceResponse(event.Payload)
+				response, err := common.DeserializeServiceResponse(payload)
 				if err != nil {
-					log.Printf("bad service response event payload %#v, action is %s\n", event.Payload, serviceRequestEvent.Action)
+					log.Printf("bad service response event payload %#v, action is %s\n", payload, serviceRequestEvent.Action)
 					continue
 				}
 				serviceRequestEvent.Payload = response
 			} else if serviceRequestEvent.Action == "remove" {
-				serviceRequestEvent.Payload = string(event.Payload)
+				serviceRequestEvent.Payload = string(payload)
 			} else {
-				serviceRequestEvent.Payload = event.Payload
+				serviceRequestEvent.Payload = payload
 			}
 
 			dest <- serviceRequestEvent
@@ -169,6 +330,80 @@ func (r *ServiceBroker) RegisterEvent(options ...client.ChaincodeEventsOption) (
 	return dest, cancel, err
 }
 
+// Publish publish a telemetry message on a stream of an IoT service of the current device
+func (r *ServiceBroker) Publish(message *common.StreamMessage) error {
+	if message == nil {
+		return fmt.Errorf("cannot publish an empty stream message")
+	}
+
+	data, err := message.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Publish", string(data))
+	return err
+}
+
+// Relay count in the quality metrics of an IoT service the progress of a request made to it from another channel or chaincode
+func (r *ServiceBroker) Relay(channelId string, chaincodeId string, requestId string) error {
+	_, err := r.contract.SubmitTransaction("Relay", channelId, chaincodeId, requestId)
+	return err
+}
+
+// respondRemote respond to a request made from another channel or chaincode through its service broker, then relay
+// the response to the service broker of the requested service
+func respondRemote(remote ServiceBrokerInterface, local ServiceBrokerInterface, channelId string, chaincodeId string, response *common.ServiceResponse) error {
+	if err := remote.Respond(response); err != nil {
+		return err
+	}
+
+	return local.Relay(channelId, chaincodeId, response.RequestId)
+}
+
+// Subscribe registers for the messages published on service streams, empty filters match any value
+func (r *ServiceBroker) Subscribe(organizationId string, deviceId string, serviceName string, topic string, options ...client.ChaincodeEventsOption) (<-chan *StreamEvent, context.CancelFunc, error) {
+	dest := make(chan *StreamEvent)
+	source, cancel, err := r.contract.RegisterEvent(options...)
+
+	match := func(filter string, value string) bool {
+		return filter == "" || filter == value
+	}
+
+	go func() {
+		defer close(dest)
+
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityStream || event.Action != "publish" {
+				continue
+			}
+			if !match(organizationId, event.OrganizationId) || !match(deviceId, event.DeviceId) ||
+				!match(serviceName, event.ServiceName) || !match(topic, event.Topic) {
+				continue
+			}
+
+			payload := event.GetLegacyPayload()
+			message, err := common.DeserializeStreamMessage(payload)
+			if err != nil {
+				log.Printf("bad stream event payload %#v, action is %s\n", payload, event.Action)
+				continue
+			}
+
+			dest <- &StreamEvent{
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				DeviceId:       event.DeviceId,
+				ServiceName:    event.ServiceName,
+				Topic:          event.Topic,
+				Sequence:       message.Sequence,
+				Message:        message,
+			}
+		}
+	}()
+
+	return dest, cancel, err
+}
+
 // CreateServiceBroker the default factory for creating service brokers
 func CreateServiceBroker(network *client.Network, chaincodeId string) ServiceBrokerInterface {
 	return &ServiceBroker{
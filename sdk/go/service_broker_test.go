@@ -40,6 +40,50 @@ func (s *ServiceBrokerTestSuite) TestRequest() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *ServiceBrokerTestSuite) TestRequestGroup() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	request := &common.GroupServiceRequest{Id: "request1", GroupOrganizationId: "org1", GroupName: "group1"}
+	data, _ := request.Serialize()
+	contract.On("SubmitTransaction", "RequestGroup", string(data)).Return(nil, nil)
+
+	err := serviceBroker.RequestGroup(request)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = serviceBroker.RequestGroup(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	request = &common.GroupServiceRequest{Id: "request2"}
+	data, _ = request.Serialize()
+	contract.On("SubmitTransaction", "RequestGroup", string(data)).Return(nil, errors.New(""))
+
+	err = serviceBroker.RequestGroup(request)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *ServiceBrokerTestSuite) TestGetGroupRequest() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	expected := &common.GroupServiceRequestResult{
+		Request:   &common.GroupServiceRequest{Id: "request1", RequestIds: []string{"request2"}},
+		Responses: []*common.ServiceRequestResponse{},
+		Progress:  map[common.ServiceRequestStatus]int{common.ServiceRequestPending: 1},
+	}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetGroupRequest", "request1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetGroupRequest", "request2").Return(nil, new(common.NotFoundError))
+
+	actual, err := serviceBroker.GetGroupRequest("request1")
+	assert.Equal(s.T(), expected, actual, "should return correct group request")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = serviceBroker.GetGroupRequest("request2")
+	assert.Nil(s.T(), actual, "should return no group request")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
 func (s *ServiceBrokerTestSuite) TestRespond() {
 	contract := new(MockContract)
 	serviceBroker := &ServiceBroker{contract}
@@ -62,6 +106,44 @@ func (s *ServiceBrokerTestSuite) TestRespond() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *ServiceBrokerTestSuite) TestTransition() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	transitions := map[string]func(string) error{
+		"Accept":   serviceBroker.Accept,
+		"Progress": serviceBroker.Progress,
+		"Reject":   serviceBroker.Reject,
+		"Cancel":   serviceBroker.Cancel,
+		"Expire":   serviceBroker.Expire,
+	}
+
+	for name, transition := range transitions {
+		contract.On("SubmitTransaction", name, "request1").Return(nil, nil)
+		contract.On("SubmitTransaction", name, "request2").Return(nil, errors.New(""))
+
+		err := transition("request1")
+		assert.Nil(s.T(), err, "should return no error")
+
+		err = transition("request2")
+		assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+	}
+}
+
+func (s *ServiceBrokerTestSuite) TestRate() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	contract.On("SubmitTransaction", "Rate", "request1", "5").Return(nil, nil)
+	contract.On("SubmitTransaction", "Rate", "request2", "5").Return(nil, errors.New(""))
+
+	err := serviceBroker.Rate("request1", 5)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = serviceBroker.Rate("request2", 5)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func (s *ServiceBrokerTestSuite) TestGet() {
 	contract := new(MockContract)
 	serviceBroker := &ServiceBroker{contract}
@@ -110,6 +192,42 @@ func (s *ServiceBrokerTestSuite) TestGetAll() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *ServiceBrokerTestSuite) TestGetMyRequests() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	expected := []*common.ServiceRequestResponse{new(common.ServiceRequestResponse), new(common.ServiceRequestResponse)}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetMyRequests").Return(data, nil).Once()
+
+	actual, err := serviceBroker.GetMyRequests()
+	assert.Equal(s.T(), expected, actual, "should return correct requests & responses")
+	assert.Nil(s.T(), err, "should return no error")
+
+	contract.On("SubmitTransaction", "GetMyRequests").Return(nil, errors.New("")).Once()
+
+	_, err = serviceBroker.GetMyRequests()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *ServiceBrokerTestSuite) TestGetQuota() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	expected := &common.ServiceQuotaStatus{RemainingRequests: 5, RemainingPending: -1}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetQuota", "org1", "device1", "service1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetQuota", "org2", "device2", "service2").Return(nil, errors.New(""))
+
+	actual, err := serviceBroker.GetQuota("org1", "device1", "service1")
+	assert.Equal(s.T(), expected.RemainingRequests, actual.RemainingRequests, "should return correct quota status")
+	assert.Equal(s.T(), expected.RemainingPending, actual.RemainingPending, "should return correct quota status")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = serviceBroker.GetQuota("org2", "device2", "service2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func (s *ServiceBrokerTestSuite) TestRemove() {
 	contract := new(MockContract)
 	serviceBroker := &ServiceBroker{contract}
@@ -153,6 +271,32 @@ func (s *ServiceBrokerTestSuite) TestRegisterEvent() {
 				Payload:   []byte(fmt.Sprintf("request%d", i)),
 			}
 		}
+
+		for i := 6; i < 8; i++ {
+			data, _ := (&common.ServiceRequest{Id: fmt.Sprintf("request%d", i), Status: common.ServiceRequestCancelled}).Serialize()
+			eventChannel <- &client.ChaincodeEvent{
+				EventName: fmt.Sprintf("request://org%d/device%d/service%d/request%d/cancel", i, i, i, i),
+				Payload:   data,
+			}
+		}
+
+		envelope := &common.EventEnvelope{
+			Version:             common.EventEnvelopeVersion,
+			TransactionId:       "tx8",
+			ActorOrganizationId: "org8",
+			ActorId:             "device9",
+			Events: []*common.Event{{
+				EntityType:     common.EventEntityRequest,
+				OrganizationId: "org8",
+				DeviceId:       "device8",
+				ServiceName:    "service8",
+				RequestId:      "request8",
+				Action:         "remove",
+				Payload:        common.NewEventPayload([]byte("request8")),
+			}},
+		}
+		data, _ := envelope.Serialize()
+		eventChannel <- &client.ChaincodeEvent{EventName: envelope.GetName(), Payload: data}
 	}()
 
 	var cancelFunc context.CancelFunc = func() {
@@ -166,7 +310,7 @@ func (s *ServiceBrokerTestSuite) TestRegisterEvent() {
 	assert.Nil(s.T(), err, "should return no error")
 	assert.IsType(s.T(), *new(context.CancelFunc), cancel, "should return correct cancel function")
 
-	for i := 0; i < 6; i++ {
+	for i := 0; i < 9; i++ {
 		event := <-source
 		assert.Equal(s.T(), fmt.Sprintf("org%d", i), event.OrganizationId, "should return correct organization ID")
 		assert.Equal(s.T(), fmt.Sprintf("device%d", i), event.DeviceId, "should return correct device ID")
@@ -181,9 +325,18 @@ func (s *ServiceBrokerTestSuite) TestRegisterEvent() {
 			assert.Equal(s.T(), "respond", event.Action, "should return correct action")
 			assert.IsType(s.T(), new(common.ServiceResponse), event.Payload, "should return parsed service request as event payload")
 			assert.Equal(s.T(), fmt.Sprintf("request%d", i), event.Payload.(*common.ServiceResponse).RequestId, "should return correct event payload")
-		} else {
+		} else if i < 6 {
 			assert.Equal(s.T(), "remove", event.Action, "should return correct action")
 			assert.Equal(s.T(), fmt.Sprintf("request%d", i), event.Payload, "should return correct event payload")
+		} else if i == 8 {
+			assert.Equal(s.T(), "remove", event.Action, "should return correct action")
+			assert.Equal(s.T(), "request8", event.Payload, "should return correct event payload")
+			assert.Equal(s.T(), "tx8", event.TransactionId, "should return correct transaction ID")
+			assert.Equal(s.T(), "device9", event.ActorId, "should return correct actor ID")
+		} else {
+			assert.Equal(s.T(), "cancel", event.Action, "should return correct action")
+			assert.IsType(s.T(), new(common.ServiceRequest), event.Payload, "should return parsed service request as event payload")
+			assert.Equal(s.T(), common.ServiceRequestCancelled, event.Payload.(*common.ServiceRequest).Status, "should return correct event payload")
 		}
 	}
 
@@ -195,6 +348,116 @@ func (s *ServiceBrokerTestSuite) TestRegisterEvent() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *ServiceBrokerTestSuite) TestRelay() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	contract.On("SubmitTransaction", "Relay", "channel2", "iotservice", "request1").Return(nil, nil)
+	contract.On("SubmitTransaction", "Relay", "channel2", "iotservice", "request2").Return(nil, errors.New(""))
+
+	err := serviceBroker.Relay("channel2", "iotservice", "request1")
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = serviceBroker.Relay("channel2", "iotservice", "request2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *ServiceBrokerTestSuite) TestRespondRemote() {
+	remoteContract, localContract := new(MockContract), new(MockContract)
+	remote, local := &ServiceBroker{remoteContract}, &ServiceBroker{localContract}
+
+	response := &common.ServiceResponse{RequestId: "request1"}
+	data, _ := response.Serialize()
+	remoteContract.On("SubmitTransaction", "Respond", string(data)).Return(nil, nil)
+	localContract.On("SubmitTransaction", "Relay", "channel2", "iotservice", "request1").Return(nil, nil)
+
+	err := respondRemote(remote, local, "channel2", "iotservice", response)
+	assert.Nil(s.T(), err, "should return no error")
+	remoteContract.AssertCalled(s.T(), "SubmitTransaction", "Respond", string(data))
+	localContract.AssertCalled(s.T(), "SubmitTransaction", "Relay", "channel2", "iotservice", "request1")
+
+	response = &common.ServiceResponse{RequestId: "request2"}
+	data, _ = response.Serialize()
+	remoteContract.On("SubmitTransaction", "Respond", string(data)).Return(nil, errors.New(""))
+
+	err = respondRemote(remote, local, "channel2", "iotservice", response)
+	assert.Error(s.T(), err, "should return error when the remote response fails")
+	localContract.AssertNotCalled(s.T(), "SubmitTransaction", "Relay", "channel2", "iotservice", "request2")
+}
+
+func (s *ServiceBrokerTestSuite) TestPublish() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	message := &common.StreamMessage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "temperature", Data: "21.5"}
+	data, _ := message.Serialize()
+	contract.On("SubmitTransaction", "Publish", string(data)).Return(nil, nil)
+
+	err := serviceBroker.Publish(message)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = serviceBroker.Publish(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	message = &common.StreamMessage{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Topic: "humidity", Data: "40"}
+	data, _ = message.Serialize()
+	contract.On("SubmitTransaction", "Publish", string(data)).Return(nil, errors.New(""))
+
+	err = serviceBroker.Publish(message)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *ServiceBrokerTestSuite) TestSubscribe() {
+	contract := new(MockContract)
+	serviceBroker := &ServiceBroker{contract}
+
+	eventChannel := make(chan *client.ChaincodeEvent)
+	go func() {
+		for i, topic := range []string{"temperature", "humidity", "temperature"} {
+			data, _ := (&common.StreamMessage{Topic: topic, Sequence: int64(i + 1), Data: "21.5"}).Serialize()
+			eventChannel <- &client.ChaincodeEvent{
+				EventName: fmt.Sprintf("stream://org1/device1/service1/%s/publish", topic),
+				Payload:   data,
+			}
+		}
+		eventChannel <- &client.ChaincodeEvent{EventName: "service://org1/device1/service1/register", Payload: []byte("{}")}
+		eventChannel <- &client.ChaincodeEvent{EventName: "stream://org1/device1/service1/temperature/publish", Payload: []byte("[]")}
+		data, _ := (&common.StreamMessage{Topic: "temperature", Sequence: 4, Hash: "9f86d081", Count: 60}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{EventName: "stream://org1/device1/service1/temperature/publish", Payload: data}
+	}()
+
+	var cancelFunc context.CancelFunc = func() {
+		close(eventChannel)
+	}
+
+	contract.On("RegisterEvent", mock.Anything).Return(eventChannel, cancelFunc, nil)
+
+	source, cancel, err := serviceBroker.Subscribe("org1", "", "service1", "temperature")
+	defer cancel()
+	assert.Nil(s.T(), err, "should return no error")
+
+	for _, sequence := range []int64{1, 3} {
+		event := <-source
+		assert.Equal(s.T(), "org1", event.OrganizationId, "should return correct organization ID")
+		assert.Equal(s.T(), "device1", event.DeviceId, "should return correct device ID")
+		assert.Equal(s.T(), "service1", event.ServiceName, "should return correct service name")
+		assert.Equal(s.T(), "temperature", event.Topic, "should skip messages of other topics")
+		assert.Equal(s.T(), sequence, event.Sequence, "should return correct sequence number")
+		assert.Equal(s.T(), "21.5", event.Message.Data, "should return parsed message")
+	}
+
+	event := <-source
+	assert.Equal(s.T(), int64(4), event.Sequence, "should skip events of other entities and bad payloads")
+	assert.Equal(s.T(), "9f86d081", event.Message.Hash, "should return parsed batch hash")
+
+	contract = new(MockContract)
+	serviceBroker = &ServiceBroker{contract}
+	contract.On("RegisterEvent", mock.Anything).Return(nil, nil, errors.New(""))
+
+	_, _, err = serviceBroker.Subscribe("", "", "", "")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func TestServiceBrokerTestSuite(t *testing.T) {
 	suite.Run(t, new(ServiceBrokerTestSuite))
 }
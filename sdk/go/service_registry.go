@@ -5,7 +5,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
-	"regexp"
 
 	"github.com/hyperledger/fabric-gateway/pkg/client"
 	"github.com/nexus-lab/iot-service-blockchain/common"
@@ -13,6 +12,9 @@ import (
 
 // ServiceEvent an event emitted by the service registry contract notifying a service update
 type ServiceEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
 	// Action name of the action performed on the service
 	Action string
 
@@ -40,6 +42,15 @@ type ServiceRegistryInterface interface {
 	// GetAll return a list of services by their organization ID and device ID
 	GetAll(organizationId string, deviceId string) ([]*common.Service, error)
 
+	// Search return a page of services across organizations and devices matching the query
+	Search(query *common.ServiceQuery) (*common.ServiceQueryResult, error)
+
+	// GetStats return the quality metrics of a service by its organization ID, device ID, and name
+	GetStats(organizationId string, deviceId string, serviceName string) (*common.ServiceStats, error)
+
+	// UpdateAcl replace the access control list of a service
+	UpdateAcl(organizationId string, deviceId string, serviceName string, acl *common.ServiceAcl) error
+
 	// Deregister remove a service from the ledger
 	Deregister(service *common.Service) error
 
@@ -77,6 +88,31 @@ func (r *ServiceRegistry) Get(organizationId string, deviceId string, serviceNam
 	return common.DeserializeService(data)
 }
 
+// Search return a page of services across organizations and devices matching the query
+func (r *ServiceRegistry) Search(query *common.ServiceQuery) (*common.ServiceQueryResult, error) {
+	data, err := query.Serialize()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = r.contract.SubmitTransaction("Search", string(data))
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeServiceQueryResult(data)
+}
+
+// GetStats return the quality metrics of a service by its organization ID, device ID, and name
+func (r *ServiceRegistry) GetStats(organizationId string, deviceId string, serviceName string) (*common.ServiceStats, error) {
+	data, err := r.contract.SubmitTransaction("GetStats", organizationId, deviceId, serviceName)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeServiceStats(data)
+}
+
 // GetAll return a list of services by their organization ID and device ID
 func (r *ServiceRegistry) GetAll(organizationId string, deviceId string) ([]*common.Service, error) {
 	data, err := r.contract.SubmitTransaction("GetAll", organizationId, deviceId)
@@ -92,6 +128,21 @@ func (r *ServiceRegistry) GetAll(organizationId string, deviceId string) ([]*com
 	return results, nil
 }
 
+// UpdateAcl replace the access control list of a service
+func (r *ServiceRegistry) UpdateAcl(organizationId string, deviceId string, serviceName string, acl *common.ServiceAcl) error {
+	if acl == nil {
+		acl = new(common.ServiceAcl)
+	}
+
+	data, err := acl.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("UpdateAcl", organizationId, deviceId, serviceName, string(data))
+	return err
+}
+
 // Deregister remove a service from the ledger
 func (r *ServiceRegistry) Deregister(service *common.Service) error {
 	if service == nil {
@@ -111,33 +162,33 @@ func (r *ServiceRegistry) Deregister(service *common.Service) error {
 func (r *ServiceRegistry) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *ServiceEvent, context.CancelFunc, error) {
 	dest := make(chan *ServiceEvent)
 	source, cancel, err := r.contract.RegisterEvent(options...)
-	pattern := regexp.MustCompile(`^service:\/\/(.+?)\/(.+?)\/(.+?)\/(.+?)$`)
 
 	go func() {
 		defer close(dest)
 
-		for event := range source {
-			matches := pattern.FindStringSubmatch(event.EventName)
-			if len(matches) != 5 {
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityService {
 				continue
 			}
 
+			payload := event.GetLegacyPayload()
 			serviceEvent := &ServiceEvent{
-				OrganizationId: matches[1],
-				DeviceId:       matches[2],
-				ServiceName:    matches[3],
-				Action:         matches[4],
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				DeviceId:       event.DeviceId,
+				ServiceName:    event.ServiceName,
+				Action:         event.Action,
 			}
 
-			if serviceEvent.Action == "register" || serviceEvent.Action == "deregister" {
-				service, err := common.DeserializeService(event.Payload)
+			if serviceEvent.Action == "register" || serviceEvent.Action == "deregister" || serviceEvent.Action == "acl" {
+				service, err := common.DeserializeService(payload)
 				if err != nil {
-					log.Printf("bad service event payload %#v, action is %s\n", event.Payload, serviceEvent.Action)
+					log.Printf("bad service event payload %#v, action is %s\n", payload, serviceEvent.Action)
 					continue
 				}
 				serviceEvent.Payload = service
 			} else {
-				serviceEvent.Payload = event.Payload
+				serviceEvent.Payload = payload
 			}
 
 			dest <- serviceEvent
@@ -64,6 +64,41 @@ func (s *ServiceRegistryTestSuite) TestGet() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *ServiceRegistryTestSuite) TestSearch() {
+	contract := new(MockContract)
+	serviceRegistry := &ServiceRegistry{contract}
+
+	expected := &common.ServiceQueryResult{Services: []*common.Service{{Name: "service1"}}, Bookmark: "bookmark1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "Search", "{\"namePrefix\":\"service\"}").Return(data, nil)
+	contract.On("SubmitTransaction", "Search", "{\"name\":\"service2\"}").Return(nil, errors.New(""))
+
+	actual, err := serviceRegistry.Search(&common.ServiceQuery{NamePrefix: "service"})
+	assert.Equal(s.T(), expected.Bookmark, actual.Bookmark, "should return correct bookmark")
+	assert.Equal(s.T(), "service1", actual.Services[0].Name, "should return correct services")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = serviceRegistry.Search(&common.ServiceQuery{Name: "service2"})
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *ServiceRegistryTestSuite) TestGetStats() {
+	contract := new(MockContract)
+	serviceRegistry := &ServiceRegistry{contract}
+
+	expected := &common.ServiceStats{OrganizationId: "org1", DeviceId: "device1", ServiceName: "service1", Requests: 3}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetStats", "org1", "device1", "service1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetStats", "org2", "device2", "service2").Return(nil, errors.New(""))
+
+	actual, err := serviceRegistry.GetStats("org1", "device1", "service1")
+	assert.Equal(s.T(), expected, actual, "should return correct stats")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = serviceRegistry.GetStats("org2", "device2", "service2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func (s *ServiceRegistryTestSuite) TestGetAll() {
 	contract := new(MockContract)
 	serviceRegistry := &ServiceRegistry{contract}
@@ -88,6 +123,27 @@ func (s *ServiceRegistryTestSuite) TestGetAll() {
 	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
 }
 
+func (s *ServiceRegistryTestSuite) TestUpdateAcl() {
+	contract := new(MockContract)
+	serviceRegistry := &ServiceRegistry{contract}
+
+	acl := &common.ServiceAcl{ServiceAclRule: common.ServiceAclRule{OwnOrganization: true}}
+	data, _ := acl.Serialize()
+	contract.On("SubmitTransaction", "UpdateAcl", "org1", "device1", "service1", string(data)).Return(nil, nil)
+	contract.On("SubmitTransaction", "UpdateAcl", "org1", "device1", "service1", "{}").Return(nil, nil)
+
+	err := serviceRegistry.UpdateAcl("org1", "device1", "service1", acl)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = serviceRegistry.UpdateAcl("org1", "device1", "service1", nil)
+	assert.Nil(s.T(), err, "should clear access control list if input is null")
+
+	contract.On("SubmitTransaction", "UpdateAcl", "org2", "device2", "service2", string(data)).Return(nil, errors.New(""))
+
+	err = serviceRegistry.UpdateAcl("org2", "device2", "service2", acl)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
 func (s *ServiceRegistryTestSuite) TestDeregister() {
 	contract := new(MockContract)
 	serviceRegistry := &ServiceRegistry{contract}
@@ -0,0 +1,179 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+)
+
+// WorkflowEvent an event emitted by the workflow registry or service broker contract notifying a workflow update
+type WorkflowEvent struct {
+	// EventMetadata details of the transaction that emitted the event
+	EventMetadata
+
+	// Action name of the action performed on the workflow
+	Action string
+
+	// OrganizationId organization ID of the workflow
+	OrganizationId string
+
+	// WorkflowName name of the workflow
+	WorkflowName string
+
+	// Payload custom event payload
+	Payload interface{}
+}
+
+// WorkflowRegistryInterface core utilities for managing workflows and their runs on the ledger
+type WorkflowRegistryInterface interface {
+	// Register create or update a workflow in the ledger
+	Register(workflow *common.Workflow) error
+
+	// Get return a workflow by its organization ID and name
+	Get(organizationId string, name string) (*common.Workflow, error)
+
+	// GetAll return a list of workflows by their organization ID
+	GetAll(organizationId string) ([]*common.Workflow, error)
+
+	// Deregister remove a workflow from the ledger
+	Deregister(organizationId string, name string) error
+
+	// Start start a run of a workflow, whose requests are made on behalf of the calling client
+	Start(run *common.WorkflowRun) error
+
+	// GetRun return a workflow run by its ID
+	GetRun(runId string) (*common.WorkflowRun, error)
+
+	// RegisterEvent registers for workflow events
+	RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *WorkflowEvent, context.CancelFunc, error)
+}
+
+// WorkflowRegistry core utilities for managing workflows and their runs on the ledger
+type WorkflowRegistry struct {
+	contract ContractInterface
+}
+
+// Register create or update a workflow in the ledger
+func (r *WorkflowRegistry) Register(workflow *common.Workflow) error {
+	if workflow == nil {
+		return fmt.Errorf("cannot register an empty workflow")
+	}
+
+	data, err := workflow.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Register", string(data))
+	return err
+}
+
+// Get return a workflow by its organization ID and name
+func (r *WorkflowRegistry) Get(organizationId string, name string) (*common.Workflow, error) {
+	data, err := r.contract.SubmitTransaction("Get", organizationId, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeWorkflow(data)
+}
+
+// GetAll return a list of workflows by their organization ID
+func (r *WorkflowRegistry) GetAll(organizationId string) ([]*common.Workflow, error) {
+	data, err := r.contract.SubmitTransaction("GetAll", organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*common.Workflow, 0)
+	if err = json.Unmarshal(data, &results); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// Deregister remove a workflow from the ledger
+func (r *WorkflowRegistry) Deregister(organizationId string, name string) error {
+	_, err := r.contract.SubmitTransaction("Deregister", organizationId, name)
+	return err
+}
+
+// Start start a run of a workflow, whose requests are made on behalf of the calling client
+func (r *WorkflowRegistry) Start(run *common.WorkflowRun) error {
+	if run == nil {
+		return fmt.Errorf("cannot start an empty workflow run")
+	}
+
+	data, err := run.Serialize()
+	if err != nil {
+		return err
+	}
+
+	_, err = r.contract.SubmitTransaction("Start", string(data))
+	return err
+}
+
+// GetRun return a workflow run by its ID
+func (r *WorkflowRegistry) GetRun(runId string) (*common.WorkflowRun, error) {
+	data, err := r.contract.SubmitTransaction("GetRun", runId)
+	if err != nil {
+		return nil, err
+	}
+
+	return common.DeserializeWorkflowRun(data)
+}
+
+// RegisterEvent registers for workflow events
+func (r *WorkflowRegistry) RegisterEvent(options ...client.ChaincodeEventsOption) (<-chan *WorkflowEvent, context.CancelFunc, error) {
+	dest := make(chan *WorkflowEvent)
+	source, cancel, err := r.contract.RegisterEvent(options...)
+
+	go func() {
+		defer close(dest)
+
+		for event := range parseEvents(source) {
+			if event.EntityType != common.EventEntityWorkflow {
+				continue
+			}
+
+			payload := event.GetLegacyPayload()
+			workflowEvent := &WorkflowEvent{
+				EventMetadata:  event.EventMetadata,
+				OrganizationId: event.OrganizationId,
+				WorkflowName:   event.WorkflowName,
+				Action:         event.Action,
+			}
+
+			var err error
+			if workflowEvent.Action == "register" || workflowEvent.Action == "deregister" {
+				workflowEvent.Payload, err = common.DeserializeWorkflow(payload)
+			} else {
+				workflowEvent.Payload, err = common.DeserializeWorkflowRun(payload)
+			}
+			if err != nil {
+				log.Printf("bad workflow event payload %#v, action is %s\n", payload, workflowEvent.Action)
+				continue
+			}
+
+			dest <- workflowEvent
+		}
+	}()
+
+	return dest, cancel, err
+}
+
+// CreateWorkflowRegistry the default factory for creating workflow registries
+func CreateWorkflowRegistry(network *client.Network, chaincodeId string) WorkflowRegistryInterface {
+	return &WorkflowRegistry{
+		contract: &Contract{
+			network:      network,
+			chaincodeId:  chaincodeId,
+			contractName: "workflow_registry",
+		},
+	}
+}
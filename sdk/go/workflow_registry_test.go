@@ -0,0 +1,180 @@
+package sdk
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-gateway/pkg/client"
+	"github.com/nexus-lab/iot-service-blockchain/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/suite"
+)
+
+type WorkflowRegistryTestSuite struct {
+	suite.Suite
+}
+
+func (s *WorkflowRegistryTestSuite) TestRegister() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	workflow := &common.Workflow{OrganizationId: "org1", Name: "workflow1"}
+	data, _ := workflow.Serialize()
+	contract.On("SubmitTransaction", "Register", string(data)).Return(nil, nil)
+
+	err := workflowRegistry.Register(workflow)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = workflowRegistry.Register(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	workflow = &common.Workflow{OrganizationId: "org2", Name: "workflow2"}
+	data, _ = workflow.Serialize()
+	contract.On("SubmitTransaction", "Register", string(data)).Return(nil, errors.New(""))
+
+	err = workflowRegistry.Register(workflow)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *WorkflowRegistryTestSuite) TestGet() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	expected := &common.Workflow{OrganizationId: "org1", Name: "workflow1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "Get", "org1", "workflow1").Return(data, nil)
+	contract.On("SubmitTransaction", "Get", "org2", "workflow2").Return(nil, new(common.NotFoundError))
+
+	actual, err := workflowRegistry.Get("org1", "workflow1")
+	assert.Equal(s.T(), expected, actual, "should return correct workflow")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = workflowRegistry.Get("org2", "workflow2")
+	assert.Nil(s.T(), actual, "should return no workflow")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *WorkflowRegistryTestSuite) TestGetAll() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	expected := []*common.Workflow{new(common.Workflow), new(common.Workflow)}
+	data, _ := json.Marshal(expected)
+	contract.On("SubmitTransaction", "GetAll", "org1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetAll", "org2").Return(nil, errors.New(""))
+
+	actual, err := workflowRegistry.GetAll("org1")
+	assert.Equal(s.T(), expected, actual, "should return correct workflows")
+	assert.Nil(s.T(), err, "should return no error")
+
+	_, err = workflowRegistry.GetAll("org2")
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *WorkflowRegistryTestSuite) TestDeregister() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	contract.On("SubmitTransaction", "Deregister", "org1", "workflow1").Return(nil, nil)
+	contract.On("SubmitTransaction", "Deregister", "org2", "workflow2").Return(nil, errors.New(""))
+
+	assert.Nil(s.T(), workflowRegistry.Deregister("org1", "workflow1"), "should return no error")
+	assert.Error(s.T(), workflowRegistry.Deregister("org2", "workflow2"), "should return error when sdk or smart contract fails")
+}
+
+func (s *WorkflowRegistryTestSuite) TestStart() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	run := &common.WorkflowRun{Id: "run1", WorkflowOrganizationId: "org1", WorkflowName: "workflow1"}
+	data, _ := run.Serialize()
+	contract.On("SubmitTransaction", "Start", string(data)).Return(nil, nil)
+
+	err := workflowRegistry.Start(run)
+	assert.Nil(s.T(), err, "should return no error")
+
+	err = workflowRegistry.Start(nil)
+	assert.Error(s.T(), err, "should return error if input is null")
+
+	run = &common.WorkflowRun{Id: "run2", WorkflowOrganizationId: "org2", WorkflowName: "workflow2"}
+	data, _ = run.Serialize()
+	contract.On("SubmitTransaction", "Start", string(data)).Return(nil, errors.New(""))
+
+	err = workflowRegistry.Start(run)
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func (s *WorkflowRegistryTestSuite) TestGetRun() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	expected := &common.WorkflowRun{Id: "run1", WorkflowOrganizationId: "org1", WorkflowName: "workflow1"}
+	data, _ := expected.Serialize()
+	contract.On("SubmitTransaction", "GetRun", "run1").Return(data, nil)
+	contract.On("SubmitTransaction", "GetRun", "run2").Return(nil, new(common.NotFoundError))
+
+	actual, err := workflowRegistry.GetRun("run1")
+	assert.Equal(s.T(), expected, actual, "should return correct workflow run")
+	assert.Nil(s.T(), err, "should return no error")
+
+	actual, err = workflowRegistry.GetRun("run2")
+	assert.Nil(s.T(), actual, "should return no workflow run")
+	assert.IsType(s.T(), new(common.NotFoundError), err, "should return not found error")
+}
+
+func (s *WorkflowRegistryTestSuite) TestRegisterEvent() {
+	contract := new(MockContract)
+	workflowRegistry := &WorkflowRegistry{contract}
+
+	eventChannel := make(chan *client.ChaincodeEvent)
+	go func() {
+		for i := 0; i < 3; i++ {
+			data, _ := (&common.WorkflowRun{Id: fmt.Sprintf("run%d", i), Status: common.WorkflowRunRunning}).Serialize()
+			eventChannel <- &client.ChaincodeEvent{
+				EventName: fmt.Sprintf("workflow://org%d/workflow%d/advance", i, i),
+				Payload:   data,
+			}
+		}
+		eventChannel <- &client.ChaincodeEvent{EventName: "device://org1/device1/register", Payload: []byte("{}")}
+		data, _ := (&common.Workflow{OrganizationId: "org1", Name: "workflow1"}).Serialize()
+		eventChannel <- &client.ChaincodeEvent{EventName: "workflow://org1/workflow1/register", Payload: data}
+	}()
+
+	var cancelFunc context.CancelFunc = func() {
+		close(eventChannel)
+	}
+
+	contract.On("RegisterEvent", mock.Anything).Return(eventChannel, cancelFunc, nil)
+
+	source, cancel, err := workflowRegistry.RegisterEvent()
+	defer cancel()
+	assert.Nil(s.T(), err, "should return no error")
+
+	for i := 0; i < 3; i++ {
+		event := <-source
+		assert.Equal(s.T(), "advance", event.Action, "should return correct action")
+		assert.Equal(s.T(), fmt.Sprintf("org%d", i), event.OrganizationId, "should return correct organization ID")
+		assert.Equal(s.T(), fmt.Sprintf("workflow%d", i), event.WorkflowName, "should return correct workflow name")
+		assert.IsType(s.T(), new(common.WorkflowRun), event.Payload, "should return parsed workflow run as event payload")
+		assert.Equal(s.T(), fmt.Sprintf("run%d", i), event.Payload.(*common.WorkflowRun).Id, "should return correct event payload")
+	}
+
+	event := <-source
+	assert.Equal(s.T(), "register", event.Action, "should skip events of other entities")
+	assert.IsType(s.T(), new(common.Workflow), event.Payload, "should return parsed workflow as event payload")
+
+	contract = new(MockContract)
+	workflowRegistry = &WorkflowRegistry{contract}
+	contract.On("RegisterEvent", mock.Anything).Return(nil, nil, errors.New(""))
+
+	_, _, err = workflowRegistry.RegisterEvent()
+	assert.Error(s.T(), err, "should return error when sdk or smart contract fails")
+}
+
+func TestWorkflowRegistryTestSuite(t *testing.T) {
+	suite.Run(t, new(WorkflowRegistryTestSuite))
+}
@@ -0,0 +1,269 @@
+package org.nexus_lab.iot_service_blockchain.sdk;
+
+import java.nio.charset.StandardCharsets;
+import java.util.ArrayDeque;
+import java.util.ArrayList;
+import java.util.Base64;
+import java.util.Deque;
+import java.util.List;
+import java.util.NoSuchElementException;
+import lombok.Data;
+import lombok.NoArgsConstructor;
+import lombok.RequiredArgsConstructor;
+import org.hyperledger.fabric.client.ChaincodeEvent;
+import org.hyperledger.fabric.client.CloseableIterator;
+
+/**
+ * A class that expands the composite events and versioned event envelopes bundling all changes
+ * made by a transaction into one event per change, as if each change were emitted on its own.
+ */
+public class CompositeEventIterator implements CloseableIterator<ChaincodeEvent> {
+  /** Prefix of the names of composite events. */
+  public static final String COMPOSITE_EVENT_PREFIX = "composite://";
+
+  /** Prefix of the names of versioned event envelopes. */
+  public static final String EVENT_ENVELOPE_PREFIX = "event://v";
+
+  private final CloseableIterator<ChaincodeEvent> sourceIterator;
+  private final Deque<ChaincodeEvent> pending = new ArrayDeque<>();
+
+  /**
+   * A class that expands the composite events bundling all changes made by a transaction.
+   *
+   * @param iterator source iterator of chaincode events
+   */
+  public CompositeEventIterator(CloseableIterator<ChaincodeEvent> iterator) {
+    this.sourceIterator = iterator;
+  }
+
+  @Override
+  public boolean hasNext() {
+    while (this.pending.isEmpty() && this.sourceIterator.hasNext()) {
+      this.expand(this.sourceIterator.next());
+    }
+    return !this.pending.isEmpty();
+  }
+
+  @Override
+  public ChaincodeEvent next() {
+    if (!this.hasNext()) {
+      throw new NoSuchElementException();
+    }
+    return this.pending.poll();
+  }
+
+  @Override
+  public void close() {
+    this.sourceIterator.close();
+  }
+
+  private void expand(ChaincodeEvent event) {
+    if (event.getEventName().startsWith(CompositeEventIterator.EVENT_ENVELOPE_PREFIX)) {
+      this.expandEnvelope(event);
+      return;
+    }
+    if (!event.getEventName().startsWith(CompositeEventIterator.COMPOSITE_EVENT_PREFIX)) {
+      this.pending.add(event);
+      return;
+    }
+
+    try {
+      CompositeEvent composite =
+          Json.deserialize(new String(event.getPayload()), CompositeEvent.class);
+      for (ChangeEvent change : composite.getEvents()) {
+        byte[] payload =
+            change.getPayload() == null
+                ? new byte[0]
+                : Base64.getDecoder().decode(change.getPayload());
+        this.pending.add(new ExpandedEvent(event, change.getName(), payload));
+      }
+    } catch (Exception e) {
+      System.err.println(
+          String.format("bad composite event payload %s", new String(event.getPayload())));
+    }
+  }
+
+  private void expandEnvelope(ChaincodeEvent event) {
+    try {
+      EventEnvelope envelope =
+          Json.deserialize(new String(event.getPayload()), EventEnvelope.class);
+      for (EnvelopeChange change : envelope.getEvents()) {
+        this.pending.add(
+            new ExpandedEvent(event, change.getLegacyName(), change.getLegacyPayload()));
+      }
+    } catch (Exception e) {
+      System.err.println(
+          String.format("bad event envelope payload %s", new String(event.getPayload())));
+    }
+  }
+
+  /** An event bundling all changes made by a transaction. */
+  @Data
+  @NoArgsConstructor
+  public static class CompositeEvent {
+    /** ID of the transaction that made the changes. */
+    private String transactionId;
+
+    /** Changes made by the transaction, starting with the change requested by the client. */
+    private List<ChangeEvent> events;
+  }
+
+  /** A change made by a transaction. */
+  @Data
+  @NoArgsConstructor
+  public static class ChangeEvent {
+    /** Name of the event as if it were emitted on its own. */
+    private String name;
+
+    /** Base64-encoded payload of the event as if it were emitted on its own. */
+    private String payload;
+  }
+
+  /** A versioned envelope of all changes made by a transaction. */
+  @Data
+  @NoArgsConstructor
+  public static class EventEnvelope {
+    /** Version of the envelope format. */
+    private int version;
+
+    /** ID of the transaction that made the changes. */
+    private String transactionId;
+
+    /** Changes made by the transaction, starting with the change requested by the client. */
+    private List<EnvelopeChange> events;
+  }
+
+  /** A change carried by a versioned event envelope. */
+  @Data
+  @NoArgsConstructor
+  public static class EnvelopeChange {
+    /** Type of the changed entity. */
+    private String entityType;
+
+    /** ID of the organization to which the entity belongs. */
+    private String organizationId;
+
+    /** ID of the device, or of the device providing the service or request. */
+    private String deviceId;
+
+    /** Name of the service, or of the service of the request. */
+    private String serviceName;
+
+    /** ID of the request. */
+    private String requestId;
+
+    /** Topic of the stream of the service. */
+    private String topic;
+
+    /** Name of the device group. */
+    private String groupName;
+
+    /** Name of the workflow. */
+    private String workflowName;
+
+    /** Name of the firmware. */
+    private String firmwareName;
+
+    /** ID of the firmware campaign. */
+    private String campaignId;
+
+    /** Name of the action performed on the entity. */
+    private String action;
+
+    /** Entity or action details, which is a string unless it is a JSON object or array. */
+    private Object payload;
+
+    /**
+     * Get the name of the change as if it were emitted as a legacy event.
+     *
+     * @return legacy event name
+     */
+    public String getLegacyName() {
+      List<String> components = new ArrayList<>();
+      components.add(this.organizationId);
+      switch (this.entityType) {
+        case "device":
+          components.add(this.deviceId);
+          break;
+        case "service":
+          components.add(this.deviceId);
+          components.add(this.serviceName);
+          break;
+        case "request":
+          components.add(this.deviceId);
+          components.add(this.serviceName);
+          components.add(this.requestId);
+          break;
+        case "stream":
+          components.add(this.deviceId);
+          components.add(this.serviceName);
+          components.add(this.topic);
+          break;
+        case "group":
+          components.add(this.groupName);
+          break;
+        case "workflow":
+          components.add(this.workflowName);
+          break;
+        case "firmware":
+          components.add(this.firmwareName);
+          break;
+        case "campaign":
+          components.add(this.campaignId);
+          break;
+        default:
+          break;
+      }
+      components.add(this.action);
+
+      return String.format("%s://%s", this.entityType, String.join("/", components));
+    }
+
+    /**
+     * Get the payload of the change as if it were emitted as a legacy event.
+     *
+     * @return legacy event payload
+     */
+    public byte[] getLegacyPayload() {
+      if (this.payload == null) {
+        return new byte[0];
+      }
+      if (this.payload instanceof String) {
+        return ((String) this.payload).getBytes(StandardCharsets.UTF_8);
+      }
+      return Json.serialize(this.payload).getBytes(StandardCharsets.UTF_8);
+    }
+  }
+
+  @RequiredArgsConstructor
+  private static final class ExpandedEvent implements ChaincodeEvent {
+    private final ChaincodeEvent composite;
+    private final String eventName;
+    private final byte[] payload;
+
+    @Override
+    public long getBlockNumber() {
+      return this.composite.getBlockNumber();
+    }
+
+    @Override
+    public String getTransactionId() {
+      return this.composite.getTransactionId();
+    }
+
+    @Override
+    public String getChaincodeName() {
+      return this.composite.getChaincodeName();
+    }
+
+    @Override
+    public String getEventName() {
+      return this.eventName;
+    }
+
+    @Override
+    public byte[] getPayload() {
+      return this.payload;
+    }
+  }
+}
@@ -56,13 +56,14 @@ public class DeviceRegistry implements DeviceRegistryInterface {
 
   @Override
   public CloseableIterator<DeviceEvent> registerEvent(CallOption... options) {
-    return new DeviceEventIterator(this.contract.registerEvent(options));
+    return new DeviceEventIterator(
+        new CompositeEventIterator(this.contract.registerEvent(options)));
   }
 
   private static final class DeviceEventIterator
       extends TransformCloseableIterator<ChaincodeEvent, DeviceEvent> {
     private static final Pattern EVENT_NAME_PATTERN =
-        Pattern.compile("^device:\\/\\/(.+?)\\/(.+?)\\/(.+?)$");
+        Pattern.compile("^device:\\/\\/([^/]+)\\/(.+)\\/([^/]+)$");
 
     public DeviceEventIterator(CloseableIterator<ChaincodeEvent> iterator) {
       super(iterator);
@@ -63,13 +63,14 @@ public class ServiceBroker implements ServiceBrokerInterface {
 
   @Override
   public CloseableIterator<ServiceRequestEvent> registerEvent(CallOption... options) {
-    return new ServiceRequestEventIterator(this.contract.registerEvent(options));
+    return new ServiceRequestEventIterator(
+        new CompositeEventIterator(this.contract.registerEvent(options)));
   }
 
   private static final class ServiceRequestEventIterator
       extends TransformCloseableIterator<ChaincodeEvent, ServiceRequestEvent> {
     private static final Pattern EVENT_NAME_PATTERN =
-        Pattern.compile("^request:\\/\\/(.+?)\\/(.+?)\\/(.+?)\\/(.+?)\\/(.+?)$");
+        Pattern.compile("^request:\\/\\/([^/]+)\\/(.+)\\/([^/]+)\\/([^/]+)\\/([^/]+)$");
 
     public ServiceRequestEventIterator(CloseableIterator<ChaincodeEvent> iterator) {
       super(iterator);
@@ -56,13 +56,14 @@ public class ServiceRegistry implements ServiceRegistryInterface {
 
   @Override
   public CloseableIterator<ServiceEvent> registerEvent(CallOption... options) {
-    return new ServiceEventIterator(this.contract.registerEvent(options));
+    return new ServiceEventIterator(
+        new CompositeEventIterator(this.contract.registerEvent(options)));
   }
 
   private static final class ServiceEventIterator
       extends TransformCloseableIterator<ChaincodeEvent, ServiceEvent> {
     private static final Pattern EVENT_NAME_PATTERN =
-        Pattern.compile("^service:\\/\\/(.+?)\\/(.+?)\\/(.+?)\\/(.+?)$");
+        Pattern.compile("^service:\\/\\/([^/]+)\\/(.+)\\/([^/]+)\\/([^/]+)$");
 
     public ServiceEventIterator(CloseableIterator<ChaincodeEvent> iterator) {
       super(iterator);
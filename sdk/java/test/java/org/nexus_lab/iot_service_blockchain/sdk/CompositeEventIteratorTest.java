@@ -0,0 +1,88 @@
+package org.nexus_lab.iot_service_blockchain.sdk;
+
+import static org.junit.Assert.assertArrayEquals;
+import static org.junit.Assert.assertEquals;
+import static org.junit.Assert.assertFalse;
+import static org.junit.Assert.assertThrows;
+
+import java.util.Base64;
+import java.util.NoSuchElementException;
+import org.hyperledger.fabric.client.CloseableIterator;
+import org.junit.Test;
+
+public class CompositeEventIteratorTest {
+  @Test
+  public void testExpand() {
+    String composite =
+        String.format(
+            "{\"transactionId\":\"tx1\",\"events\":[{\"name\":\"device://org1/device1/deregister\","
+                + "\"payload\":\"%s\"},{\"name\":\"service://org1/device1/service1/deregister\"}]}",
+            Base64.getEncoder().encodeToString("{}".getBytes()));
+
+    CloseableIterator<org.hyperledger.fabric.client.ChaincodeEvent> iterator =
+        new CompositeEventIterator(
+            Utils.createIterator(
+                3,
+                (i) -> {
+                  if (i == 0) {
+                    return new ChaincodeEvent("composite://tx1", composite.getBytes());
+                  } else if (i == 1) {
+                    return new ChaincodeEvent("composite://tx2", "[".getBytes());
+                  }
+                  return new ChaincodeEvent("device://org1/device2/register", "{}".getBytes());
+                }));
+
+    org.hyperledger.fabric.client.ChaincodeEvent event = iterator.next();
+    assertEquals("device://org1/device1/deregister", event.getEventName());
+    assertArrayEquals("{}".getBytes(), event.getPayload());
+
+    event = iterator.next();
+    assertEquals("service://org1/device1/service1/deregister", event.getEventName());
+    assertArrayEquals(new byte[0], event.getPayload());
+
+    event = iterator.next();
+    assertEquals("device://org1/device2/register", event.getEventName());
+
+    assertFalse(iterator.hasNext());
+    assertThrows(NoSuchElementException.class, () -> iterator.next());
+    iterator.close();
+  }
+
+  @Test
+  public void testExpandEnvelope() {
+    String envelope =
+        "{\"version\":1,\"transactionId\":\"tx1\",\"events\":["
+            + "{\"entityType\":\"device\",\"organizationId\":\"org1\",\"deviceId\":\"device1\","
+            + "\"action\":\"register\",\"payload\":{\"id\":\"device1\"}},"
+            + "{\"entityType\":\"request\",\"organizationId\":\"org1\",\"deviceId\":\"device1\","
+            + "\"serviceName\":\"service1\",\"requestId\":\"request1\",\"action\":\"remove\","
+            + "\"payload\":\"request1\"},"
+            + "{\"entityType\":\"account\",\"organizationId\":\"org1\",\"action\":\"deposit\"}]}";
+
+    CloseableIterator<org.hyperledger.fabric.client.ChaincodeEvent> iterator =
+        new CompositeEventIterator(
+            Utils.createIterator(
+                2,
+                (i) -> {
+                  if (i == 0) {
+                    return new ChaincodeEvent("event://v1", envelope.getBytes());
+                  }
+                  return new ChaincodeEvent("event://v1", "[".getBytes());
+                }));
+
+    org.hyperledger.fabric.client.ChaincodeEvent event = iterator.next();
+    assertEquals("device://org1/device1/register", event.getEventName());
+    assertArrayEquals("{\"id\":\"device1\"}".getBytes(), event.getPayload());
+
+    event = iterator.next();
+    assertEquals("request://org1/device1/service1/request1/remove", event.getEventName());
+    assertArrayEquals("request1".getBytes(), event.getPayload());
+
+    event = iterator.next();
+    assertEquals("account://org1/deposit", event.getEventName());
+    assertArrayEquals(new byte[0], event.getPayload());
+
+    assertFalse(iterator.hasNext());
+    iterator.close();
+  }
+}
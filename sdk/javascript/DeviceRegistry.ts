@@ -7,6 +7,7 @@ import { TextDecoder } from 'util';
 
 import Contract, { ContractInterface } from './Contract';
 import Device from './Device';
+import { expandCompositeEvents } from './event';
 
 /**
  * Interface of the event emitted by the device registry contract notifying a device update
@@ -116,12 +117,12 @@ export default class DeviceRegistry implements DeviceRegistryInterface {
     options?: ChaincodeEventsOptions,
   ): Promise<CloseableAsyncIterable<DeviceEvent>> {
     const events = await this.contract.registerEvent(options);
-    const pattern = /^device:\/\/(.+?)\/(.+?)\/(.+?)$/;
+    const pattern = /^device:\/\/([^/]+)\/(.+)\/([^/]+)$/;
     const decoder = this.utf8Decoder;
 
     return {
       async *[Symbol.asyncIterator]() {
-        for await (const event of events) {
+        for await (const event of expandCompositeEvents(events)) {
           // can reuse pattern here since it has no global('g') flag
           const matches = pattern.exec(event.eventName);
           if (matches === null || matches.length != 4) {
@@ -9,6 +9,7 @@ import Contract, { ContractInterface } from './Contract';
 import ServiceRequest from './ServiceRequest';
 import ServiceRequestResponse from './ServiceRequestResponse';
 import ServiceResponse from './ServiceResponse';
+import { expandCompositeEvents } from './event';
 
 /**
  * Interface of the event emitted by the service broker contract notifying a service request/response update
@@ -155,12 +156,12 @@ export default class ServiceBroker implements ServiceBrokerInterface {
     options?: ChaincodeEventsOptions,
   ): Promise<CloseableAsyncIterable<ServiceRequestEvent>> {
     const events = await this.contract.registerEvent(options);
-    const pattern = /^request:\/\/(.+?)\/(.+?)\/(.+?)\/(.+?)\/(.+?)$/;
+    const pattern = /^request:\/\/([^/]+)\/(.+)\/([^/]+)\/([^/]+)\/([^/]+)$/;
     const decoder = this.utf8Decoder;
 
     return {
       async *[Symbol.asyncIterator]() {
-        for await (const event of events) {
+        for await (const event of expandCompositeEvents(events)) {
           // can reuse pattern here since it has no global('g') flag
           const matches = pattern.exec(event.eventName);
           if (matches === null || matches.length != 6) {
@@ -7,6 +7,7 @@ import { TextDecoder } from 'util';
 
 import Contract, { ContractInterface } from './Contract';
 import Service from './Service';
+import { expandCompositeEvents } from './event';
 
 /**
  * Interface of the event emitted by the service registry contract notifying a service update
@@ -127,12 +128,12 @@ export default class ServiceRegistry implements ServiceRegistryInterface {
     options?: ChaincodeEventsOptions,
   ): Promise<CloseableAsyncIterable<ServiceEvent>> {
     const events = await this.contract.registerEvent(options);
-    const pattern = /^service:\/\/(.+?)\/(.+?)\/(.+?)\/(.+?)$/;
+    const pattern = /^service:\/\/([^/]+)\/(.+)\/([^/]+)\/([^/]+)$/;
     const decoder = this.utf8Decoder;
 
     return {
       async *[Symbol.asyncIterator]() {
-        for await (const event of events) {
+        for await (const event of expandCompositeEvents(events)) {
           // can reuse pattern here since it has no global('g') flag
           const matches = pattern.exec(event.eventName);
           if (matches === null || matches.length != 5) {
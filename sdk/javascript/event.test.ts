@@ -0,0 +1,87 @@
+import { ChaincodeEvent } from '@hyperledger/fabric-gateway';
+import { TextEncoder } from 'util';
+
+import { expandCompositeEvents } from './event';
+
+const utf8Encoder = new TextEncoder();
+
+test('expandCompositeEvents()', async () => {
+  const composite = {
+    transactionId: 'tx1',
+    events: [
+      { name: 'device://org1/device1/deregister', payload: Buffer.from('{}').toString('base64') },
+      { name: 'service://org1/device1/service1/deregister' },
+    ],
+  };
+  const source = async function* () {
+    yield {
+      eventName: 'composite://tx1',
+      payload: utf8Encoder.encode(JSON.stringify(composite)),
+    } as ChaincodeEvent;
+    yield { eventName: 'composite://tx2', payload: utf8Encoder.encode('[') } as ChaincodeEvent;
+    yield {
+      eventName: 'device://org1/device2/register',
+      payload: utf8Encoder.encode('{}'),
+    } as ChaincodeEvent;
+  };
+
+  const events: ChaincodeEvent[] = [];
+  for await (const event of expandCompositeEvents(source())) {
+    events.push(event);
+  }
+
+  expect(events.map((event) => event.eventName)).toEqual([
+    'device://org1/device1/deregister',
+    'service://org1/device1/service1/deregister',
+    'device://org1/device2/register',
+  ]);
+  expect(Buffer.from(events[0].payload).toString()).toEqual('{}');
+  expect(events[1].payload.length).toEqual(0);
+});
+
+test('expandCompositeEvents() with event envelopes', async () => {
+  const envelope = {
+    version: 1,
+    transactionId: 'tx1',
+    events: [
+      {
+        entityType: 'device',
+        organizationId: 'org1',
+        deviceId: 'device1',
+        action: 'register',
+        payload: { id: 'device1' },
+      },
+      {
+        entityType: 'request',
+        organizationId: 'org1',
+        deviceId: 'device1',
+        serviceName: 'service1',
+        requestId: 'request1',
+        action: 'remove',
+        payload: 'request1',
+      },
+      { entityType: 'account', organizationId: 'org1', action: 'deposit' },
+    ],
+  };
+  const source = async function* () {
+    yield {
+      eventName: 'event://v1',
+      payload: utf8Encoder.encode(JSON.stringify(envelope)),
+    } as ChaincodeEvent;
+    yield { eventName: 'event://v1', payload: utf8Encoder.encode('[') } as ChaincodeEvent;
+  };
+
+  const events: ChaincodeEvent[] = [];
+  for await (const event of expandCompositeEvents(source())) {
+    events.push(event);
+  }
+
+  expect(events.map((event) => event.eventName)).toEqual([
+    'device://org1/device1/register',
+    'request://org1/device1/service1/request1/remove',
+    'account://org1/deposit',
+  ]);
+  expect(Buffer.from(events[0].payload).toString()).toEqual('{"id":"device1"}');
+  expect(Buffer.from(events[1].payload).toString()).toEqual('request1');
+  expect(events[2].payload.length).toEqual(0);
+});
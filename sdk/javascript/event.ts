@@ -0,0 +1,114 @@
+import { ChaincodeEvent } from '@hyperledger/fabric-gateway';
+import { TextDecoder } from 'util';
+
+/**
+ * Prefix of the names of events bundling all changes made by a transaction
+ */
+export const COMPOSITE_EVENT_PREFIX = 'composite://';
+
+/**
+ * Prefix of the names of versioned event envelopes bundling all changes made by a transaction
+ */
+export const EVENT_ENVELOPE_PREFIX = 'event://v';
+
+/**
+ * Identifiers that follow the organization ID in the legacy event names of each entity type
+ */
+const LEGACY_NAME_COMPONENTS: Record<string, string[]> = {
+  device: ['deviceId'],
+  service: ['deviceId', 'serviceName'],
+  request: ['deviceId', 'serviceName', 'requestId'],
+  stream: ['deviceId', 'serviceName', 'topic'],
+  group: ['groupName'],
+  workflow: ['workflowName'],
+  firmware: ['firmwareName'],
+  campaign: ['campaignId'],
+};
+
+/**
+ * A change carried by a versioned event envelope
+ */
+interface EnvelopeChange {
+  entityType: string;
+  organizationId: string;
+  action: string;
+  payload?: unknown;
+  [identifier: string]: unknown;
+}
+
+/**
+ * Get the name and payload of a change carried by a versioned event envelope as if it were
+ * emitted as a legacy event
+ *
+ * @param change change carried by the envelope
+ * @returns legacy event name and payload
+ */
+function toLegacyEvent(change: EnvelopeChange): { name: string; payload: Uint8Array } {
+  const identifiers = (LEGACY_NAME_COMPONENTS[change.entityType] ?? []).map(
+    (key) => change[key] ?? '',
+  );
+  const components = [change.organizationId, ...identifiers, change.action];
+  const name = `${change.entityType}://${components.join('/')}`;
+
+  // payloads other than JSON objects and arrays are wrapped as JSON strings in the envelope
+  let payload = Buffer.alloc(0);
+  if (typeof change.payload === 'string') {
+    payload = Buffer.from(change.payload);
+  } else if (change.payload !== undefined && change.payload !== null) {
+    payload = Buffer.from(JSON.stringify(change.payload));
+  }
+
+  return { name, payload };
+}
+
+/**
+ * Expand the composite events and versioned event envelopes bundling all changes made by a
+ * transaction into one event per change, as if each change were emitted on its own
+ *
+ * @param events chaincode events
+ * @returns chaincode events of the individual changes
+ */
+export async function* expandCompositeEvents(
+  events: AsyncIterable<ChaincodeEvent>,
+): AsyncGenerator<ChaincodeEvent> {
+  const decoder = new TextDecoder();
+
+  for await (const event of events) {
+    if (event.eventName.startsWith(EVENT_ENVELOPE_PREFIX)) {
+      let changes: EnvelopeChange[];
+      try {
+        changes = JSON.parse(decoder.decode(event.payload)).events;
+      } catch {
+        console.error(`bad event envelope payload ${event.payload}`);
+        continue;
+      }
+
+      for (const change of changes) {
+        const { name, payload } = toLegacyEvent(change);
+        yield { ...event, eventName: name, payload };
+      }
+      continue;
+    }
+
+    if (!event.eventName.startsWith(COMPOSITE_EVENT_PREFIX)) {
+      yield event;
+      continue;
+    }
+
+    let changes: { name: string; payload?: string }[];
+    try {
+      changes = JSON.parse(decoder.decode(event.payload)).events;
+    } catch {
+      console.error(`bad composite event payload ${event.payload}`);
+      continue;
+    }
+
+    for (const change of changes) {
+      yield {
+        ...event,
+        eventName: change.name,
+        payload: Buffer.from(change.payload ?? '', 'base64'),
+      };
+    }
+  }
+}
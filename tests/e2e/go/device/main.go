@@ -9,6 +9,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/nexus-lab/iot-service-blockchain/common"
 	sdk "github.com/nexus-lab/iot-service-blockchain/sdk/go"
 )
@@ -17,15 +18,16 @@ const (
 	ORG_ID        = "Org1MSP"
 	ORG_DOMAIN    = "org1.example.com"
 	USER_NAME     = "User1@org1.example.com"
+	ADMIN_NAME    = "Admin@org1.example.com"
 	PEER_NAME     = "peer0.org1.example.com"
 	PEER_ENDPOINT = "localhost:7051"
 )
 
-func getCredentials() ([]byte, []byte, []byte) {
+func getCredentials(userName string) ([]byte, []byte, []byte) {
 	root := filepath.Join(os.Getenv("FABRIC_ROOT"), "test-network/organizations/peerOrganizations/", ORG_DOMAIN)
 	filepaths := []string{
-		"users/" + USER_NAME + "/msp/signcerts/cert.pem",
-		"users/" + USER_NAME + "/msp/keystore/priv_sk",
+		"users/" + userName + "/msp/signcerts/cert.pem",
+		"users/" + userName + "/msp/keystore/priv_sk",
 		"peers/" + PEER_NAME + "/tls/ca.crt",
 	}
 
@@ -41,7 +43,54 @@ func getCredentials() ([]byte, []byte, []byte) {
 	return files[0], files[1], files[2]
 }
 
-func registerDevice(isb *sdk.Sdk) {
+func connect(userName string) *sdk.Sdk {
+	certificate, privateKey, tlsCertificate := getCredentials(userName)
+
+	isb, err := sdk.NewSdk(
+		&sdk.SdkOptions{
+			OrganizationId:            ORG_ID,
+			Certificate:               certificate,
+			PrivateKey:                privateKey,
+			GatewayPeerEndpoint:       PEER_ENDPOINT,
+			GatewayPeerServerName:     PEER_NAME,
+			GatewayPeerTLSCertificate: tlsCertificate,
+			NetworkName:               "mychannel",
+			ChaincodeId:               "iotservice",
+		},
+	)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return isb
+}
+
+func authorizeEnrollment(claimCode string) {
+	certificate, _, _ := getCredentials(USER_NAME)
+	cert, err := common.ParseCertificate(certificate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	admin := connect(ADMIN_NAME)
+	defer admin.Close()
+
+	enrollment := &common.DeviceEnrollment{
+		Id:             uuid.New().String(),
+		OrganizationId: admin.GetOrganizationId(),
+		Subject:        common.GetSubjectDN(cert),
+		ClaimCodeHash:  common.HashClaimCode(claimCode),
+		ExpiryTime:     time.Now().Add(time.Hour),
+	}
+
+	err = admin.GetDeviceRegistry().AuthorizeEnrollment(enrollment)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("Authorized enrollment %#v\n", enrollment)
+}
+
+func registerDevice(isb *sdk.Sdk, claimCode string) {
 	expected := &common.Device{
 		Id:             isb.GetDeviceId(),
 		OrganizationId: isb.GetOrganizationId(),
@@ -50,7 +99,7 @@ func registerDevice(isb *sdk.Sdk) {
 		LastUpdateTime: time.Now(),
 	}
 
-	err := isb.GetDeviceRegistry().Register(expected)
+	err := isb.GetDeviceRegistry().Enroll(expected, claimCode)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -306,28 +355,15 @@ func deregisterDevice(isb *sdk.Sdk) {
 }
 
 func main() {
-	certificate, privateKey, tlsCertificate := getCredentials()
+	claimCode := uuid.New().String()
+	authorizeEnrollment(claimCode)
 
-	isb, err := sdk.NewSdk(
-		&sdk.SdkOptions{
-			OrganizationId:            ORG_ID,
-			Certificate:               certificate,
-			PrivateKey:                privateKey,
-			GatewayPeerEndpoint:       PEER_ENDPOINT,
-			GatewayPeerServerName:     PEER_NAME,
-			GatewayPeerTLSCertificate: tlsCertificate,
-			NetworkName:               "mychannel",
-			ChaincodeId:               "iotservice",
-		},
-	)
-	if err != nil {
-		log.Fatal(err)
-	}
+	isb := connect(USER_NAME)
 
 	log.Printf("Organization ID is %s\n", isb.GetOrganizationId())
 	log.Printf("Device ID is %s\n", isb.GetDeviceId())
 
-	registerDevice(isb)
+	registerDevice(isb, claimCode)
 	registerServices(isb)
 	handleRequests(isb)
 	checkAndRemoveRequests(isb)